@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// assertion is a threshold on a metric of the final report, given with -assert,
+// e.g. p99<200ms
+type assertion struct {
+	Text     string
+	Metric   string
+	Operator string  // <, <=, > or >=
+	Value    float64 // in the unit of the metric, see assertionMetrics
+}
+
+// assertionMetric is a metric assertions can be made on
+type assertionMetric struct {
+	unit  string // "latency" in microseconds, "percent" or "rate" per second
+	value func(report Report) float64
+}
+
+// assertionMetrics are the metrics of the report assertions can be made on
+var assertionMetrics = map[string]assertionMetric{
+	"min":          {"latency", func(r Report) float64 { return float64(r.Latency.Min) }},
+	"avg":          {"latency", func(r Report) float64 { return float64(r.Latency.Avg) }},
+	"p50":          {"latency", func(r Report) float64 { return float64(r.Latency.P50) }},
+	"p90":          {"latency", func(r Report) float64 { return float64(r.Latency.P90) }},
+	"p99":          {"latency", func(r Report) float64 { return float64(r.Latency.P99) }},
+	"p99.9":        {"latency", func(r Report) float64 { return float64(r.Latency.P999) }},
+	"max":          {"latency", func(r Report) float64 { return float64(r.Latency.Max) }},
+	"error_rate":   {"percent", func(r Report) float64 { return percentOf(r.FailedRequests, r.TotalRequests) }},
+	"success_rate": {"percent", func(r Report) float64 { return percentOf(r.SuccessfulRequests, r.TotalRequests) }},
+	"drop_rate":    {"percent", func(r Report) float64 { return percentOf(r.DroppedRequests, r.TotalRequests+r.DroppedRequests) }},
+	"rps":          {"rate", func(r Report) float64 { return r.RequestsPerSecond }},
+}
+
+// parseAssertions parses comma-separated assertions such as
+// "p99<200ms,error_rate<1%,rps>=100"
+// Latencies take a unit, rates are percentages with or without a % sign
+func parseAssertions(spec string) ([]assertion, error) {
+	var assertions []assertion
+	for _, text := range strings.Split(spec, ",") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		i := strings.IndexAny(text, "<>")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid assertion %q, want a metric, <, <=, > or >= and a threshold", text)
+		}
+		a := assertion{Text: text, Metric: strings.ToLower(strings.TrimSpace(text[:i])), Operator: text[i : i+1]}
+		threshold := text[i+1:]
+		if strings.HasPrefix(threshold, "=") {
+			a.Operator += "="
+			threshold = threshold[1:]
+		}
+		threshold = strings.TrimSpace(threshold)
+
+		metric, ok := assertionMetrics[a.Metric]
+		if !ok {
+			return nil, fmt.Errorf("unknown metric %q in assertion %q, want one of min, avg, p50, p90, p99, p99.9, max, error_rate, success_rate, drop_rate or rps", a.Metric, text)
+		}
+		var err error
+		switch metric.unit {
+		case "latency":
+			var d time.Duration
+			d, err = time.ParseDuration(threshold)
+			a.Value = float64(d.Microseconds())
+		case "percent":
+			a.Value, err = strconv.ParseFloat(strings.TrimSuffix(threshold, "%"), 64)
+		default:
+			a.Value, err = strconv.ParseFloat(threshold, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q in assertion %q", threshold, text)
+		}
+		assertions = append(assertions, a)
+	}
+	return assertions, nil
+}
+
+// check checks the assertion on the report of a run
+func (a assertion) check(report Report) check {
+	c := check{Name: a.Text}
+	if report.TotalRequests == 0 {
+		c.Failure = "no requests were sent"
+		return c
+	}
+
+	metric := assertionMetrics[a.Metric]
+	value := metric.value(report)
+	var ok bool
+	switch a.Operator {
+	case "<":
+		ok = value < a.Value
+	case "<=":
+		ok = value <= a.Value
+	case ">":
+		ok = value > a.Value
+	case ">=":
+		ok = value >= a.Value
+	}
+	if !ok {
+		c.Failure = fmt.Sprintf("%s was %s, want %s %s", a.Metric, formatMetric(metric.unit, value), a.Operator, formatMetric(metric.unit, a.Value))
+	}
+	return c
+}
+
+// formatMetric formats a value of a metric in its unit
+func formatMetric(unit string, value float64) string {
+	switch unit {
+	case "latency":
+		return formatLatency(time.Duration(value) * time.Microsecond)
+	case "percent":
+		return strconv.FormatFloat(value, 'f', 2, 64) + "%"
+	}
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+// percentOf returns n as a percentage of total, 0 if total is
+func percentOf(n, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
+
+// printAssertions prints the result of each assertion and reports whether they
+// all passed
+func printAssertions(checks []check) bool {
+	passed := true
+	fmt.Println("\nAssertions:")
+	for _, c := range checks {
+		if c.Failure == "" {
+			fmt.Printf("  PASS %s\n", c.Name)
+		} else {
+			fmt.Printf("  FAIL %s: %s\n", c.Name, c.Failure)
+			passed = false
+		}
+	}
+	return passed
+}
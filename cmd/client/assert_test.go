@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAssertions(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		expected []assertion
+	}{
+		{"empty", "", nil},
+		{"only commas", " , ,", nil},
+		{
+			"latency",
+			"p99<200ms",
+			[]assertion{{Text: "p99<200ms", Metric: "p99", Operator: "<", Value: 200000}},
+		},
+		{
+			"all operators",
+			"max<=1s, min > 1ms ,avg>=1.5ms,p99.9<2s",
+			[]assertion{
+				{Text: "max<=1s", Metric: "max", Operator: "<=", Value: 1000000},
+				{Text: "min > 1ms", Metric: "min", Operator: ">", Value: 1000},
+				{Text: "avg>=1.5ms", Metric: "avg", Operator: ">=", Value: 1500},
+				{Text: "p99.9<2s", Metric: "p99.9", Operator: "<", Value: 2000000},
+			},
+		},
+		{
+			"percentages with and without a sign",
+			"error_rate<1%,SUCCESS_RATE>=99.5",
+			[]assertion{
+				{Text: "error_rate<1%", Metric: "error_rate", Operator: "<", Value: 1},
+				{Text: "SUCCESS_RATE>=99.5", Metric: "success_rate", Operator: ">=", Value: 99.5},
+			},
+		},
+		{
+			"rate",
+			"rps>=100",
+			[]assertion{{Text: "rps>=100", Metric: "rps", Operator: ">=", Value: 100}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertions, err := parseAssertions(tt.spec)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(assertions, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, assertions)
+			}
+		})
+	}
+}
+
+func TestParseAssertionsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		err  string
+	}{
+		{"no operator", "p99=200ms", "invalid assertion \"p99=200ms\""},
+		{"unknown metric", "p95<200ms", "unknown metric \"p95\""},
+		{"missing metric", "<200ms", "unknown metric \"\""},
+		{"latency without a unit", "p99<200", "invalid threshold \"200\""},
+		{"missing threshold", "rps>=", "invalid threshold \"\""},
+		{"invalid percentage", "error_rate<one%", "invalid threshold \"one%\""},
+		{"unit on a rate", "rps>10/s", "invalid threshold \"10/s\""},
+		{"one invalid of several", "p99<200ms,rps>>1", "invalid threshold \">1\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertions, err := parseAssertions(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected an error containing %q, got %v", tt.err, err)
+			}
+			if assertions != nil {
+				t.Errorf("Expected no assertions, got %+v", assertions)
+			}
+		})
+	}
+}
+
+func TestAssertionCheck(t *testing.T) {
+	report := Report{TotalRequests: 200, SuccessfulRequests: 198, FailedRequests: 2, RequestsPerSecond: 100}
+	report.Latency.P99 = 150000
+
+	tests := []struct {
+		spec    string
+		failure string
+	}{
+		{"p99<200ms", ""},
+		{"p99<=150ms", ""},
+		{"p99<150ms", "p99 was"},
+		{"error_rate<1%", "error_rate was 1.00%, want < 1.00%"},
+		{"error_rate<=1%", ""},
+		{"rps>100", "rps was 100.00, want > 100.00"},
+		{"rps>=100", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			assertions, err := parseAssertions(tt.spec)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			c := assertions[0].check(report)
+			if tt.failure == "" && c.Failure != "" {
+				t.Errorf("Expected the assertion to pass, got %q", c.Failure)
+			}
+			if tt.failure != "" && !strings.HasPrefix(c.Failure, tt.failure) {
+				t.Errorf("Expected a failure starting with %q, got %q", tt.failure, c.Failure)
+			}
+		})
+	}
+
+	assertions, _ := parseAssertions("rps>=0")
+	if c := assertions[0].check(Report{}); c.Failure != "no requests were sent" {
+		t.Errorf("Expected an empty run to fail, got %q", c.Failure)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+)
+
+// reportTemplateText is the template of the HTML report, which embeds its styles
+// and charts so it can be opened or archived as a single file
+//
+//go:embed report.html.tmpl
+var reportTemplateText string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"latency": func(us int64) string {
+		return formatLatency(time.Duration(us) * time.Microsecond)
+	},
+	"percent": func(part, total uint64) string {
+		if total == 0 {
+			return "0.00%"
+		}
+		return fmt.Sprintf("%.2f%%", float64(part)/float64(total)*100)
+	},
+}).Parse(reportTemplateText))
+
+// Size of the charts in the HTML report, in SVG units
+const (
+	chartWidth  = 600
+	chartHeight = 200
+)
+
+// chart is a line chart of values over the seconds of a run
+type chart struct {
+	Title  string
+	Max    string // label of the top of the y axis
+	Width  int
+	Height int
+	Lines  []chartLine
+}
+
+// chartLine is one series of a chart
+type chartLine struct {
+	Name   string
+	Color  string
+	Points string // SVG polyline points
+}
+
+// htmlReport is the data of the HTML report template
+type htmlReport struct {
+	Report
+	Generated time.Time
+	Charts    []chart
+}
+
+// newChart builds a chart of the series, scaled so that the largest value
+// reaches the top
+// format labels the largest value
+func newChart(title string, format func(float64) string, names, colors []string, series ...[]float64) chart {
+	maxValue := 0.0
+	for _, values := range series {
+		for _, v := range values {
+			if v > maxValue {
+				maxValue = v
+			}
+		}
+	}
+
+	c := chart{Title: title, Max: format(maxValue), Width: chartWidth, Height: chartHeight}
+	for i, values := range series {
+		var points strings.Builder
+		for j, v := range values {
+			x := 0.0
+			if len(values) > 1 {
+				x = float64(j) / float64(len(values)-1) * chartWidth
+			}
+			y := float64(chartHeight)
+			if maxValue > 0 {
+				y -= v / maxValue * chartHeight
+			}
+			fmt.Fprintf(&points, "%.1f,%.1f ", x, y)
+		}
+		c.Lines = append(c.Lines, chartLine{Name: names[i], Color: colors[i], Points: strings.TrimSpace(points.String())})
+	}
+	return c
+}
+
+// writeHTMLReport writes the report as a standalone HTML page with charts of
+// the requests and the latency over the run
+func writeHTMLReport(path string, report Report) error {
+	requests := make([]float64, len(report.Throughput))
+	failed := make([]float64, len(report.Throughput))
+	p50 := make([]float64, len(report.Throughput))
+	p99 := make([]float64, len(report.Throughput))
+	for i, sample := range report.Throughput {
+		requests[i] = float64(sample.Requests)
+		failed[i] = float64(sample.Failed)
+		p50[i] = float64(sample.P50)
+		p99[i] = float64(sample.P99)
+	}
+
+	data := htmlReport{
+		Report:    report,
+		Generated: time.Now(),
+		Charts: []chart{
+			newChart("Requests per second", func(v float64) string { return fmt.Sprintf("%.0f/s", v) },
+				[]string{"Requests", "Failed"}, []string{"#2563eb", "#dc2626"}, requests, failed),
+			newChart("Latency", func(v float64) string { return formatLatency(time.Duration(v) * time.Microsecond) },
+				[]string{"p50", "p99"}, []string{"#16a34a", "#ea580c"}, p50, p99),
+		},
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = reportTemplate.Execute(file, data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// check is a pass or fail verdict on a run, reported as a JUnit test case
+type check struct {
+	Name    string
+	Failure string // why the check failed, empty if it passed
+}
+
+// reportChecks returns the checks made on every run
+func reportChecks(report Report) []check {
+	checks := []check{{Name: "requests succeed"}}
+	if report.FailedRequests > 0 {
+		checks[0].Failure = fmt.Sprintf("%d of %d requests failed", report.FailedRequests, report.TotalRequests)
+	}
+	if report.TargetRPS > 0 || report.DroppedRequests > 0 {
+		c := check{Name: "requests are sent at the target rate"}
+		if report.DroppedRequests > 0 {
+			c.Failure = fmt.Sprintf("%d requests were dropped because all clients were busy", report.DroppedRequests)
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// JUnit XML elements, as read by CI servers
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnitReport writes the checks of a run as a JUnit XML test suite, with
+// the main figures of the report as its properties
+func writeJUnitReport(path string, report Report, checks []check) error {
+	suite := junitTestSuite{
+		Name:  "load test",
+		Tests: len(checks),
+		Time:  strconv.FormatFloat(report.DurationSeconds, 'f', 3, 64),
+		Properties: []junitProperty{
+			{"model", report.Model},
+			{"clients", strconv.Itoa(report.Clients)},
+			{"total_requests", strconv.FormatUint(report.TotalRequests, 10)},
+			{"failed_requests", strconv.FormatUint(report.FailedRequests, 10)},
+			{"requests_per_second", strconv.FormatFloat(report.RequestsPerSecond, 'f', 2, 64)},
+			{"p50_us", strconv.FormatInt(report.Latency.P50, 10)},
+			{"p99_us", strconv.FormatInt(report.Latency.P99, 10)},
+			{"max_us", strconv.FormatInt(report.Latency.Max, 10)},
+		},
+	}
+	for _, c := range checks {
+		testCase := junitTestCase{Name: c.Name, ClassName: "client"}
+		if c.Failure != "" {
+			testCase.Failure = &junitFailure{Message: c.Failure}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0o644)
+}
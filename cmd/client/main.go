@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -11,12 +12,13 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/metrics"
+	"github.com/amirahmetzanov/go_project/internal/namespb"
 )
 
 // RequestPayload represents the JSON payload in the request
@@ -38,12 +40,19 @@ type ClientStats struct {
 	TotalRequests      uint64
 	SuccessfulRequests uint64
 	FailedRequests     uint64
-	TotalLatency       uint64 // in milliseconds
-	MaxLatency         uint64 // in milliseconds
-	MinLatency         uint64 // in milliseconds
+	DroppedRequests    uint64 // not sent in the open and constant models because all clients were busy
+	FirstAttemptFails  uint64 // requests whose first attempt got an error or status that is retried
+	Attempts           uint64 // attempts sent, the first ones and the retries
+	Retries            uint64 // attempts after the first
 	StatusCodes        map[int]uint64
 	Errors             map[string]uint64
+	Protocols          map[string]uint64 // responses by protocol, e.g. HTTP/2.0
+	latencies          *metrics.Histogram // latency of every request, retries included, guarded by mutex
+	recent             *metrics.Histogram // latency of the requests since the last throughput sample, guarded by mutex
+	latencySum         time.Duration      // guarded by mutex
+	throughput         []ThroughputSample // requests of every second, guarded by mutex
 	mutex              sync.RWMutex
+	parent             *ClientStats // also counts everything counted here, e.g. the totals of all targets
 }
 
 // NewClientStats creates a new client stats instance
@@ -51,21 +60,97 @@ func NewClientStats() *ClientStats {
 	return &ClientStats{
 		StatusCodes: make(map[int]uint64),
 		Errors:      make(map[string]uint64),
+		Protocols:   make(map[string]uint64),
+		latencies:   metrics.NewHistogram(),
+		recent:      metrics.NewHistogram(),
+	}
+}
+
+// NewChildStats creates client stats whose counts are also added to s
+func (s *ClientStats) NewChildStats() *ClientStats {
+	child := NewClientStats()
+	child.parent = s
+	return child
+}
+
+// RecordLatency counts the latency of a request, from its start to its last attempt
+func (s *ClientStats) RecordLatency(latency time.Duration) {
+	for ; s != nil; s = s.parent {
+		s.mutex.Lock()
+		s.latencies.Record(latency)
+		s.recent.Record(latency)
+		s.latencySum += latency
+		s.mutex.Unlock()
+	}
+}
+
+// IncrementTotal counts a request sent
+func (s *ClientStats) IncrementTotal() {
+	for ; s != nil; s = s.parent {
+		atomic.AddUint64(&s.TotalRequests, 1)
+	}
+}
+
+// IncrementSuccessful counts a successful request
+func (s *ClientStats) IncrementSuccessful() {
+	for ; s != nil; s = s.parent {
+		atomic.AddUint64(&s.SuccessfulRequests, 1)
+	}
+}
+
+// IncrementFailed counts a failed request
+func (s *ClientStats) IncrementFailed() {
+	for ; s != nil; s = s.parent {
+		atomic.AddUint64(&s.FailedRequests, 1)
+	}
+}
+
+// IncrementFirstAttemptFails counts a request whose first attempt failed
+func (s *ClientStats) IncrementFirstAttemptFails() {
+	for ; s != nil; s = s.parent {
+		atomic.AddUint64(&s.FirstAttemptFails, 1)
+	}
+}
+
+// IncrementAttempts counts an attempt sent
+func (s *ClientStats) IncrementAttempts() {
+	for ; s != nil; s = s.parent {
+		atomic.AddUint64(&s.Attempts, 1)
+	}
+}
+
+// IncrementRetries counts a retried attempt
+func (s *ClientStats) IncrementRetries() {
+	for ; s != nil; s = s.parent {
+		atomic.AddUint64(&s.Retries, 1)
 	}
 }
 
 // IncrementStatusCode increments the count for a specific status code
 func (s *ClientStats) IncrementStatusCode(code int) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	s.StatusCodes[code]++
+	for ; s != nil; s = s.parent {
+		s.mutex.Lock()
+		s.StatusCodes[code]++
+		s.mutex.Unlock()
+	}
+}
+
+// IncrementProtocol increments the count of responses in a protocol
+func (s *ClientStats) IncrementProtocol(proto string) {
+	for ; s != nil; s = s.parent {
+		s.mutex.Lock()
+		s.Protocols[proto]++
+		s.mutex.Unlock()
+	}
 }
 
 // IncrementError increments the count for a specific error
 func (s *ClientStats) IncrementError(err string) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	s.Errors[err]++
+	for ; s != nil; s = s.parent {
+		s.mutex.Lock()
+		s.Errors[err]++
+		s.mutex.Unlock()
+	}
 }
 
 // generateRandomSessionID generates a random session ID
@@ -86,154 +171,97 @@ func generateRandomLetter() string {
 	return string(rune('A' + rand.Intn(26)))
 }
 
-// sendRequest sends a single request to the server
-func sendRequest(serverURL string, stats *ClientStats, wg *sync.WaitGroup) {
-	defer wg.Done()
-	
-	// Generate random parameters
-	sessionID := generateRandomSessionID()
-	letter := generateRandomLetter()
-	numOfEntries := rand.Intn(20) + 1 // Random number between 1 and 20
+// encodeRequest encodes the payload as JSON or, with useProtobuf, as a protobuf message
+// It returns the body and its content type
+func encodeRequest(payload RequestPayload, useProtobuf bool) ([]byte, string, error) {
+	if useProtobuf {
+		message := namespb.GenerateRequest{
+			SessionID:    payload.SessionID,
+			Letter:       payload.Letter,
+			NumOfEntries: int32(payload.NumOfEntries),
+		}
+		return message.Marshal(), namespb.ContentType, nil
+	}
 	
-	// Create request payload
-	payload := RequestPayload{
-		SessionID:    sessionID,
-		Letter:       letter,
-		NumOfEntries: numOfEntries,
+	body, err := json.Marshal(payload)
+	return body, "application/json", err
+}
+
+// decodeResponse decodes a response body, encoded as JSON or as a protobuf message
+func decodeResponse(body io.Reader, useProtobuf bool) (ResponsePayload, error) {
+	var payload ResponsePayload
+	if !useProtobuf {
+		err := json.NewDecoder(body).Decode(&payload)
+		return payload, err
 	}
 	
-	// Convert payload to JSON
-	payloadBytes, err := json.Marshal(payload)
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return payload, err
+	}
+	var message namespb.GenerateResponse
+	if err := message.Unmarshal(data); err != nil {
+		return payload, err
+	}
+	payload.SessionID = message.SessionID
+	payload.Names = message.Names
+	payload.NumOfEntries = int(message.NumOfEntries)
+	return payload, nil
+}
+
+// sendRequest sends a single names request to the server
+// scheduled is the time the request was due in the open and constant models, and
+// its latency is measured from then; it's zero otherwise
+func sendRequest(serverURL string, payload RequestPayload, useProtobuf bool, scheduled time.Time, stats *ClientStats) {
+	sessionID := payload.SessionID
+	numOfEntries := payload.NumOfEntries
+	
+	// Encode the payload
+	payloadBytes, contentType, err := encodeRequest(payload, useProtobuf)
 	if err != nil {
 		log.Printf("Error marshaling payload: %v", err)
-		atomic.AddUint64(&stats.FailedRequests, 1)
+		stats.IncrementFailed()
 		stats.IncrementError(fmt.Sprintf("marshal: %v", err))
 		return
 	}
 	
-	// Implement exponential backoff for retries
-	maxRetries := 3
-	baseDelay := 100 * time.Millisecond
+	// Create request
+	req, err := http.NewRequest("POST", serverURL, bytes.NewReader(payloadBytes))
+	if err != nil {
+		log.Printf("Error creating request: %v", err)
+		stats.IncrementFailed()
+		stats.IncrementError(fmt.Sprintf("create: %v", err))
+		return
+	}
 	
-	var resp *http.Response
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		// Create request
-		req, err := http.NewRequest("POST", serverURL, bytes.NewBuffer(payloadBytes))
-		if err != nil {
-			log.Printf("Error creating request: %v", err)
-			atomic.AddUint64(&stats.FailedRequests, 1)
-			stats.IncrementError(fmt.Sprintf("create: %v", err))
-			return
-		}
-		
-		// Set headers
-		req.Header.Set("Content-Type", "application/json")
-		
-		// Send request and measure time
-		startTime := time.Now()
-		client := &http.Client{
-			Timeout: 10 * time.Second,
-		}
-		resp, err = client.Do(req)
-		latency := time.Since(startTime).Milliseconds()
-		
-		// Update total requests counter (only on first attempt)
-		if attempt == 0 {
-			atomic.AddUint64(&stats.TotalRequests, 1)
-		}
-		
-		// Update latency statistics
-		atomic.AddUint64(&stats.TotalLatency, uint64(latency))
-		
-		// Update min latency (atomically)
-		for {
-			min := atomic.LoadUint64(&stats.MinLatency)
-			if min == 0 || uint64(latency) < min {
-				if atomic.CompareAndSwapUint64(&stats.MinLatency, min, uint64(latency)) {
-					break
-				}
-			} else {
-				break
-			}
-		}
-		
-		// Update max latency (atomically)
-		for {
-			max := atomic.LoadUint64(&stats.MaxLatency)
-			if uint64(latency) > max {
-				if atomic.CompareAndSwapUint64(&stats.MaxLatency, max, uint64(latency)) {
-					break
-				}
-			} else {
-				break
-			}
-		}
-		
-		// Check for errors
-		if err != nil {
-			if attempt == maxRetries {
-				log.Printf("Error sending request after %d retries: %v", maxRetries, err)
-				atomic.AddUint64(&stats.FailedRequests, 1)
-				stats.IncrementError(fmt.Sprintf("send: %v", err))
-				return
-			}
-			// Retry after a backoff delay
-			backoffDelay := baseDelay * time.Duration(1<<attempt)
-			time.Sleep(backoffDelay)
-			continue
-		}
-		
-		// Update status code counter
-		stats.IncrementStatusCode(resp.StatusCode)
-		
-		// Check for rate limiting (429 status)
-		if resp.StatusCode == http.StatusTooManyRequests {
-			// Get retry-after header or use default backoff
-			retryAfter := resp.Header.Get("Retry-After")
-			var retryDelay time.Duration
-			if retryAfter != "" {
-				// Parse retry-after value
-				if retrySeconds, err := strconv.Atoi(retryAfter); err == nil {
-					retryDelay = time.Duration(retrySeconds) * time.Second
-				} else {
-					retryDelay = baseDelay * time.Duration(1<<attempt)
-				}
-			} else {
-				retryDelay = baseDelay * time.Duration(1<<attempt)
-			}
-			
-			// Retry if we haven't exhausted retries
-			if attempt < maxRetries {
-				// Close the current response body before retrying
-				resp.Body.Close()
-				
-				// Wait before retrying
-				time.Sleep(retryDelay)
-				continue
-			}
-		}
-		
-		// Break the loop if we get a successful response or a non-retryable error
-		break
+	// Set headers
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", contentType)
+	
+	// Send it, retrying failed attempts as set up by the retry flags
+	resp, err := sendWithRetries(req, http.StatusOK, scheduled, stats)
+	if err != nil {
+		log.Printf("Error sending request: %v", err)
+		stats.IncrementFailed()
+		stats.IncrementError(fmt.Sprintf("send: %v", err))
+		return
 	}
 	
 	// If we exhausted retries or got a non-200 response code
-	if resp == nil || resp.StatusCode != http.StatusOK {
-		if resp != nil {
-			log.Printf("Error response: %s", resp.Status)
-			resp.Body.Close()
-		}
-		atomic.AddUint64(&stats.FailedRequests, 1)
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Error response: %s", resp.Status)
+		resp.Body.Close()
+		stats.IncrementFailed()
 		return
 	}
 	
 	defer resp.Body.Close()
 	
 	// Parse response
-	var responsePayload ResponsePayload
-	if err := json.NewDecoder(resp.Body).Decode(&responsePayload); err != nil {
+	responsePayload, err := decodeResponse(resp.Body, useProtobuf)
+	if err != nil {
 		log.Printf("Error decoding response: %v", err)
-		atomic.AddUint64(&stats.FailedRequests, 1)
+		stats.IncrementFailed()
 		stats.IncrementError(fmt.Sprintf("decode: %v", err))
 		return
 	}
@@ -241,20 +269,52 @@ func sendRequest(serverURL string, stats *ClientStats, wg *sync.WaitGroup) {
 	// Validate response
 	if responsePayload.SessionID != sessionID {
 		log.Printf("Session ID mismatch: expected %s, got %s", sessionID, responsePayload.SessionID)
-		atomic.AddUint64(&stats.FailedRequests, 1)
+		stats.IncrementFailed()
 		stats.IncrementError("session_id_mismatch")
 		return
 	}
 	
 	if len(responsePayload.Names) != numOfEntries {
 		log.Printf("Number of entries mismatch: expected %d, got %d", numOfEntries, len(responsePayload.Names))
-		atomic.AddUint64(&stats.FailedRequests, 1)
+		stats.IncrementFailed()
 		stats.IncrementError("num_entries_mismatch")
 		return
 	}
 	
 	// Request was successful
-	atomic.AddUint64(&stats.SuccessfulRequests, 1)
+	stats.IncrementSuccessful()
+}
+
+// sendExpectingStatus sends a single request whose only check is the status of
+// the response, e.g. a payload the server must reject
+func sendExpectingStatus(method, url string, body []byte, contentType string, wantStatus int, scheduled time.Time, stats *ClientStats) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Error creating request: %v", err)
+		stats.IncrementFailed()
+		stats.IncrementError(fmt.Sprintf("create: %v", err))
+		return
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	
+	resp, err := sendWithRetries(req, wantStatus, scheduled, stats)
+	if err != nil {
+		stats.IncrementFailed()
+		stats.IncrementError(fmt.Sprintf("send: %v", err))
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	
+	if resp.StatusCode != wantStatus {
+		log.Printf("Unexpected response to %s %s: %s, expected %d", method, url, resp.Status, wantStatus)
+		stats.IncrementFailed()
+		stats.IncrementError("unexpected_status")
+		return
+	}
+	stats.IncrementSuccessful()
 }
 
 // printStats prints the current statistics
@@ -262,14 +322,10 @@ func printStats(stats *ClientStats, duration time.Duration) {
 	totalRequests := atomic.LoadUint64(&stats.TotalRequests)
 	successfulRequests := atomic.LoadUint64(&stats.SuccessfulRequests)
 	failedRequests := atomic.LoadUint64(&stats.FailedRequests)
-	totalLatency := atomic.LoadUint64(&stats.TotalLatency)
-	maxLatency := atomic.LoadUint64(&stats.MaxLatency)
-	minLatency := atomic.LoadUint64(&stats.MinLatency)
-	
-	var avgLatency uint64
-	if totalRequests > 0 {
-		avgLatency = totalLatency / totalRequests
-	}
+	droppedRequests := atomic.LoadUint64(&stats.DroppedRequests)
+	firstAttemptFails := atomic.LoadUint64(&stats.FirstAttemptFails)
+	attempts := atomic.LoadUint64(&stats.Attempts)
+	retried := atomic.LoadUint64(&stats.Retries)
 	
 	requestsPerSecond := float64(totalRequests) / duration.Seconds()
 	
@@ -277,10 +333,30 @@ func printStats(stats *ClientStats, duration time.Duration) {
 	fmt.Printf("Total Requests:       %d\n", totalRequests)
 	fmt.Printf("Successful Requests:  %d (%.2f%%)\n", successfulRequests, float64(successfulRequests)/float64(totalRequests)*100)
 	fmt.Printf("Failed Requests:      %d (%.2f%%)\n", failedRequests, float64(failedRequests)/float64(totalRequests)*100)
+	if droppedRequests > 0 {
+		fmt.Printf("Dropped Requests:     %d\n", droppedRequests)
+	}
+	if firstAttemptFails > 0 || retried > 0 {
+		fmt.Printf("1st Attempt Failures: %d (%.2f%%)\n", firstAttemptFails, float64(firstAttemptFails)/float64(totalRequests)*100)
+		fmt.Printf("Attempts:             %d\n", attempts)
+		fmt.Printf("Retries:              %d\n", retried)
+	}
 	fmt.Printf("Requests Per Second:  %.2f\n", requestsPerSecond)
-	fmt.Printf("Min Latency:          %d ms\n", minLatency)
-	fmt.Printf("Avg Latency:          %d ms\n", avgLatency)
-	fmt.Printf("Max Latency:          %d ms\n", maxLatency)
+	
+	// Print latency percentiles
+	stats.mutex.RLock()
+	var avgLatency time.Duration
+	if count := stats.latencies.Count(); count > 0 {
+		avgLatency = stats.latencySum / time.Duration(count)
+	}
+	fmt.Printf("Min Latency:          %s\n", formatLatency(stats.latencies.Percentile(0)))
+	fmt.Printf("Avg Latency:          %s\n", formatLatency(avgLatency))
+	for _, percentile := range []float64{50, 90, 99, 99.9} {
+		label := fmt.Sprintf("p%g Latency:", percentile)
+		fmt.Printf("%-22s%s\n", label, formatLatency(stats.latencies.Percentile(percentile)))
+	}
+	fmt.Printf("Max Latency:          %s\n", formatLatency(stats.latencies.Percentile(100)))
+	stats.mutex.RUnlock()
 	
 	// Print status code distribution
 	fmt.Println("\nStatus Code Distribution:")
@@ -305,89 +381,264 @@ func printStats(stats *ClientStats, duration time.Duration) {
 	fmt.Println("================================================")
 }
 
+// formatLatency formats a latency to the microsecond
+func formatLatency(latency time.Duration) string {
+	return latency.Round(time.Microsecond).String()
+}
+
+// printSpectrum prints the latency percentile spectrum, see latencySpectrum
+func printSpectrum(stats *ClientStats) {
+	stats.mutex.RLock()
+	points := stats.latencySpectrum()
+	stats.mutex.RUnlock()
+	if len(points) == 0 {
+		return
+	}
+	
+	fmt.Println("\nLatency Percentile Spectrum:")
+	fmt.Printf("  %-12s %-12s %s\n", "Percentile", "Latency", "1/(1-Percentile)")
+	for _, point := range points {
+		latency := formatLatency(time.Duration(point.Latency) * time.Microsecond)
+		if point.Percentile == 100 {
+			fmt.Printf("  %-12s %s\n", "100%", latency)
+			continue
+		}
+		fmt.Printf("  %-12s %-12s %.0f\n", fmt.Sprintf("%.5g%%", point.Percentile), latency, 100/(100-point.Percentile))
+	}
+}
+
 func main() {
 	// Define command line flags
-	serverURL := flag.String("url", "http://localhost:8080/generate", "Server URL")
+	var urls urlsFlag
+	flag.Var(&urls, "url", "Server URL, optionally followed by a comma and a weight; repeat it to spread the requests over several servers (default "+defaultURL+")")
 	numClients := flag.Int("clients", 100, "Number of concurrent clients")
 	duration := flag.Duration("duration", 60*time.Second, "Test duration")
 	rampUp := flag.Duration("ramp-up", 5*time.Second, "Ramp-up duration")
 	statsInterval := flag.Duration("stats-interval", 5*time.Second, "Stats printing interval")
+	useProtobuf := flag.Bool("protobuf", false, "Encode requests and responses as protobuf instead of JSON")
+	rps := flag.Float64("rps", 0, "Send requests at this rate per second, with up to -clients in flight (0 sends as fast as the clients can)")
+	model := flag.String("model", "", "Load model: closed, constant or open (default closed, or constant with -rps)")
+	output := flag.String("output", "", "Write the final statistics to this file, as CSV if it ends in .csv and as JSON otherwise")
+	htmlOutput := flag.String("html", "", "Write an HTML report with charts of the run to this file")
+	junitOutput := flag.String("junit", "", "Write the checks of the run to this file as JUnit XML")
+	letters := flag.String("letters", "", "Letters to ask for, e.g. ABC, or letters and prefixes separated by commas, e.g. A,Ma,Jo (default A to Z)")
+	entries := flag.String("entries", "1-20", "Range of num_of_entries to ask for, e.g. 1-20, or a single count")
+	entriesDistribution := flag.String("entries-dist", distributionUniform, "Distribution of num_of_entries over -entries: uniform, or zipf for mostly small counts")
+	invalidPercent := flag.Float64("invalid", 0, "Percentage of requests with an invalid payload, which the server must reject")
+	sessions := flag.Int("sessions", 0, "Reuse this many session IDs instead of a new one per request")
+	httpVersion := flag.String("http", httpAuto, "HTTP version: auto, 1.1, or 2 for HTTP/2 also over cleartext with prior knowledge")
+	warmup := flag.Duration("warmup", 0, "Send requests for this long before the test without recording them, so filling caches and scaling pools don't skew the statistics")
+	useTUI := flag.Bool("tui", false, "Show live statistics in a terminal UI instead of printing them every -stats-interval, if the output is a terminal")
+	assertSpec := flag.String("assert", "", "Comma-separated thresholds the run must meet, e.g. p99<200ms,error_rate<1%; the client exits with status 1 if one fails")
+	maxRetries := flag.Int("retries", retries.MaxRetries, "Retry a failed attempt up to this many times")
+	retryOn := flag.String("retry-on", "errors,429", "What to retry, comma-separated: errors for transport errors, 5xx, and status codes")
+	retryBackoff := flag.Duration("retry-backoff", retries.Backoff, "Delay before the first retry, doubled for each next one, of which a random half is waited; Retry-After headers take precedence")
+	retryMaxBackoff := flag.Duration("retry-max-backoff", retries.MaxBackoff, "Longest delay between retries, unless a Retry-After header asks for more")
+	replayFile := flag.String("replay", "", "Replay the requests of a server access log or request capture with their recorded timing instead of the load flags")
+	replaySpeed := flag.Float64("replay-speed", 1, "Replay the requests this many times faster than recorded")
+	scenarioFile := flag.String("scenario", "", "Run the phases and request mix of this YAML scenario file instead of the load and payload flags")
 	flag.Parse()
 	
+	// Set up the scenario, from the file or the flags
+	var scenario *Scenario
+	var err error
+	if *scenarioFile != "" {
+		scenario, err = loadScenario(*scenarioFile, *numClients)
+	} else {
+		var requests []RequestMix
+		requests, err = payloadMix(*letters, *entries, *entriesDistribution, *invalidPercent, *sessions)
+		if err == nil {
+			scenario, err = defaultScenario(*duration, *rampUp, *numClients, *rps, *model, requests)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid load test: %v\n", err)
+		os.Exit(2)
+	}
+	if *warmup < 0 {
+		fmt.Fprintln(os.Stderr, "Invalid -warmup: must not be negative")
+		os.Exit(2)
+	}
+	assertions, err := parseAssertions(*assertSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -assert: %v\n", err)
+		os.Exit(2)
+	}
+	
+	// Read the requests to replay, whose names requests without a body get one
+	// from the payload flags
+	var replay []replayRequest
+	var replayGenerate *RequestMix
+	if *replayFile != "" {
+		var skipped int
+		replay, skipped, err = loadReplay(*replayFile)
+		switch {
+		case *scenarioFile != "":
+			err = errors.New("-replay can't be combined with -scenario")
+		case *replaySpeed <= 0:
+			err = errors.New("-replay-speed must be positive")
+		case err == nil && *warmup > 0 && *warmup >= replayDuration(replay, *replaySpeed):
+			err = fmt.Errorf("-warmup of %s leaves nothing of the %s replay to record", *warmup, replayDuration(replay, *replaySpeed).Round(time.Millisecond))
+		case err == nil && scenario.Requests[0].Kind != kindGenerate:
+			err = errors.New("-invalid must be below 100 to fill in the bodies of names requests")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -replay: %v\n", err)
+			os.Exit(2)
+		}
+		replayGenerate = &scenario.Requests[0]
+		if skipped > 0 {
+			fmt.Printf("Skipped %d lines of %s that aren't requests\n", skipped, *replayFile)
+		}
+	}
+	
 	// Initialize random seed
 	rand.Seed(time.Now().UnixNano())
 	
 	// Initialize statistics
 	stats := NewClientStats()
 	
+	// Set up the targets
+	ts, err := parseTargets(urls, stats)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -url: %v\n", err)
+		os.Exit(2)
+	}
+	if httpClient, err = newHTTPClient(*httpVersion); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -http: %v\n", err)
+		os.Exit(2)
+	}
+	
+	// Set up the retry policy
+	retries.MaxRetries, retries.Backoff, retries.MaxBackoff = *maxRetries, *retryBackoff, *retryMaxBackoff
+	switch {
+	case *maxRetries < 0:
+		err = errors.New("-retries must not be negative")
+	case *retryBackoff < 0 || *retryMaxBackoff < *retryBackoff:
+		err = errors.New("-retry-backoff must not be negative or above -retry-max-backoff")
+	default:
+		err = retries.parseRetryOn(*retryOn)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid retry policy: %v\n", err)
+		os.Exit(2)
+	}
+	
 	// Print welcome message
-	fmt.Printf("Starting client simulator with %d concurrent clients for %s\n", *numClients, *duration)
-	fmt.Printf("Target server: %s\n", *serverURL)
-	fmt.Printf("Ramp-up duration: %s\n", *rampUp)
+	if replay != nil {
+		fmt.Printf("Starting client simulator replaying %d requests of %s over %s, with up to %d in flight\n", len(replay), *replayFile, replayDuration(replay, *replaySpeed).Round(time.Millisecond), *numClients)
+	} else if len(scenario.Phases) == 1 {
+		fmt.Printf("Starting client simulator with %s\n", scenario.Phases[0].describe())
+		fmt.Printf("Ramp-up duration: %s\n", scenario.Phases[0].RampUp)
+	} else {
+		fmt.Printf("Starting client simulator with %d phases for %s\n", len(scenario.Phases), scenario.Duration())
+	}
+	if scenario.Name != "" {
+		fmt.Printf("Scenario: %s\n", scenario.Name)
+	}
+	for _, t := range ts {
+		if len(ts) == 1 {
+			fmt.Printf("Target server: %s\n", t.URL)
+		} else {
+			fmt.Printf("Target server: %s (weight %g)\n", t.URL, t.Weight)
+		}
+	}
+	if *useProtobuf {
+		fmt.Println("Encoding: protobuf")
+	}
+	if *httpVersion != httpAuto {
+		fmt.Printf("HTTP version: %s\n", *httpVersion)
+	}
+	if *warmup > 0 {
+		fmt.Printf("Warm-up: %s, not recorded\n", *warmup)
+	}
 	fmt.Println("Press Ctrl+C to stop the test early")
 	
-	// Create a WaitGroup to wait for all goroutines to finish
-	var wg sync.WaitGroup
+	// The warm-up runs the load of the first phase before it, or is the start
+	// of the replay
+	testDuration := scenario.Duration()
+	scenario.Phases[0].Duration.Duration += *warmup
+	if replay != nil {
+		testDuration = replayDuration(replay, *replaySpeed) - *warmup
+	}
 	
-	// Start the timer
-	startTime := time.Now()
+	// Start the timer, which starts once the warm-up is done
+	startTime := time.Now().Add(*warmup)
 	
 	// Start the test
 	stopTest := make(chan struct{})
 	
-	// Calculate ramp-up interval
-	rampUpInterval := time.Duration(int64(*rampUp) / int64(*numClients))
-	
-	// Start client goroutines with ramp-up
-	for i := 0; i < *numClients; i++ {
-		// Add a delay for ramp-up
-		if *rampUp > 0 {
-			time.Sleep(rampUpInterval)
+	// Show live stats in the terminal UI, or print them every interval
+	var ui *tui
+	if *useTUI && !isTerminal(os.Stdout) {
+		fmt.Println("Not a terminal, printing stats every interval instead of the terminal UI")
+	} else if *useTUI {
+		first := scenario.Phases[0]
+		first.Duration.Duration -= *warmup
+		header := []string{"Load test: " + first.describe()}
+		if replay != nil {
+			header[0] = fmt.Sprintf("Replay: %d requests of %s", len(replay), *replayFile)
+		} else if len(scenario.Phases) > 1 {
+			header[0] = fmt.Sprintf("Load test: %d phases", len(scenario.Phases))
+		}
+		for _, t := range ts {
+			header = append(header, "Target server: "+t.URL)
 		}
-		
+		ui = newTUI(os.Stdout, stats, header, startTime, testDuration)
+		progress = ui.logs
+		go ui.run(stopTest)
+	}
+	ticker := time.NewTicker(*statsInterval)
+	if ui == nil {
 		go func() {
 			for {
 				select {
+				case <-ticker.C:
+					if elapsed := time.Since(startTime); elapsed > 0 {
+						printStats(stats, elapsed)
+					}
 				case <-stopTest:
 					return
-				default:
-					wg.Add(1)
-					sendRequest(*serverURL, stats, &wg)
-					
-					// Add some randomization to request timing with jitter
-					// This helps avoid synchronized bursts of requests
-					baseJitter := time.Duration(100)
-					jitter := time.Duration(rand.Intn(200)) * time.Millisecond
-					sleepTime := baseJitter*time.Millisecond + jitter
-					time.Sleep(sleepTime)
 				}
 			}
 		}()
 	}
 	
-	// Print stats every interval during the test
-	ticker := time.NewTicker(*statsInterval)
+	// Run the phases; they end once their requests in flight are done, so
+	// waiting for them waits for the requests
+	scenarioDone := make(chan struct{})
 	go func() {
-		for {
-			select {
-			case <-ticker.C:
-				printStats(stats, time.Since(startTime))
-			case <-stopTest:
-				return
-			}
+		defer close(scenarioDone)
+		if replay != nil {
+			runReplay(replay, *replaySpeed, replayGenerate, ts, *useProtobuf, *numClients, *warmup, stopTest, stats)
+		} else {
+			runScenario(scenario, ts, *useProtobuf, *warmup, stopTest, stats)
 		}
 	}()
 	
+	// Record the throughput of every second after the warm-up for the report
+	time.AfterFunc(*warmup, func() {
+		if *warmup > 0 {
+			fmt.Fprintln(progress, "Warm-up done, recording")
+		}
+		go stats.sampleThroughput(stopTest)
+		for _, t := range ts {
+			if t.stats != stats {
+				go t.stats.sampleThroughput(stopTest)
+			}
+		}
+	})
+	
 	// Setup signal handling for graceful shutdown
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
 	
-	// Wait for test duration or interrupt
+	// Wait for the scenario to finish or interrupt
 	select {
-	case <-time.After(*duration):
-		fmt.Println("Test duration reached, stopping...")
+	case <-scenarioDone:
+		fmt.Fprintln(progress, "Test duration reached, stopping...")
 	case sig := <-signalCh:
-		fmt.Printf("Received signal %v, stopping...\n", sig)
+		fmt.Fprintf(progress, "Received signal %v, stopping...\n", sig)
 	}
 	
 	// Stop all client goroutines
@@ -397,41 +648,104 @@ func main() {
 	ticker.Stop()
 	
 	// Wait for all requests to finish (with timeout)
-	waitCh := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(waitCh)
-	}()
-	
 	select {
-	case <-waitCh:
+	case <-scenarioDone:
 		// All requests completed
 	case <-time.After(5 * time.Second):
 		fmt.Println("Timed out waiting for requests to complete")
 	}
+	if ui != nil {
+		<-ui.done
+		progress = os.Stdout
+	}
 	
-	// Calculate the actual test duration
+	// Calculate the actual test duration, which is zero if it was stopped
+	// during the warm-up
 	actualDuration := time.Since(startTime)
+	if actualDuration < 0 {
+		actualDuration = 0
+	}
 	
 	// Print final statistics
 	fmt.Println("\nTest completed!")
 	printStats(stats, actualDuration)
+	printSpectrum(stats)
+	printProtocols(stats)
+	printTargets(ts, actualDuration)
+	
+	// Write the reports for post-processing
+	report := buildReport(stats, actualDuration)
+	report.Scenario = scenario.Name
+	report.HTTPVersion = *httpVersion
+	report.WarmupSeconds = warmup.Seconds()
+	for _, t := range ts {
+		if t.stats != stats {
+			report.Targets = append(report.Targets, TargetReport{URL: t.URL, Weight: t.Weight, Report: buildReport(t.stats, actualDuration)})
+		}
+	}
+	if replay != nil {
+		report.Scenario = *replayFile
+		report.Model = "replay"
+		report.Clients = *numClients
+	} else if len(scenario.Phases) == 1 {
+		report.Model = scenario.Phases[0].Model
+		report.Clients = scenario.Phases[0].Clients
+		report.TargetRPS = scenario.Phases[0].RPS
+	}
+	
+	// Check the assertions, which also go to the JUnit report
+	var assertionChecks []check
+	for _, a := range assertions {
+		assertionChecks = append(assertionChecks, a.check(report))
+	}
+	
+	for _, file := range []struct {
+		path  string
+		write func(string, Report) error
+	}{
+		{*output, writeReport},
+		{*htmlOutput, writeHTMLReport},
+		{*junitOutput, func(path string, report Report) error {
+			return writeJUnitReport(path, report, append(reportChecks(report), assertionChecks...))
+		}},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if err := file.write(file.path, report); err != nil {
+			fmt.Printf("Error writing report: %v\n", err)
+		} else {
+			fmt.Printf("\nReport written to %s\n", file.path)
+		}
+	}
 	
 	// Print server stats
-	fmt.Println("\nFetching server statistics...")
-	resp, err := http.Get(strings.TrimSuffix(*serverURL, "/generate") + "/stats")
+	for _, t := range ts {
+		printServerStats(serverBaseURL(t.URL))
+	}
+	
+	// Fail the run for automated performance gates
+	if len(assertionChecks) > 0 && !printAssertions(assertionChecks) {
+		os.Exit(1)
+	}
+}
+
+// printServerStats fetches and prints the statistics of a server
+func printServerStats(baseURL string) {
+	fmt.Printf("\nFetching server statistics of %s...\n", baseURL)
+	resp, err := http.Get(baseURL + "/stats")
 	if err != nil {
 		fmt.Printf("Error fetching server stats: %v\n", err)
+		return
+	}
+	defer resp.Body.Close()
+	
+	// Read the response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("Error reading server stats: %v\n", err)
 	} else {
-		defer resp.Body.Close()
-		
-		// Read the response body
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			fmt.Printf("Error reading server stats: %v\n", err)
-		} else {
-			fmt.Println("\nServer Statistics:")
-			fmt.Println(string(body))
-		}
+		fmt.Println("\nServer Statistics:")
+		fmt.Println(string(body))
 	}
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTP versions of the -http flag
+const (
+	httpAuto = "auto" // HTTP/2 if the server negotiates it over TLS, HTTP/1.1 otherwise
+	httpV1   = "1.1"
+	httpV2   = "2" // over TLS, or cleartext with prior knowledge for http:// URLs
+)
+
+// httpClient sends the requests of the run, see newHTTPClient
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// newHTTPClient returns a client speaking the given HTTP version
+// With HTTP/2, http:// URLs are sent cleartext HTTP/2 without an upgrade, which
+// only servers accepting h2c answer
+func newHTTPClient(version string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	protocols := new(http.Protocols)
+	switch version {
+	case httpAuto:
+		return &http.Client{Transport: transport, Timeout: 10 * time.Second}, nil
+	case httpV1, "1":
+		protocols.SetHTTP1(true)
+	case httpV2:
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		return nil, fmt.Errorf("unknown HTTP version %q, want %s, %s or %s", version, httpAuto, httpV1, httpV2)
+	}
+	transport.Protocols = protocols
+	return &http.Client{Transport: transport, Timeout: 10 * time.Second}, nil
+}
+
+// printProtocols prints the protocols the responses came in
+func printProtocols(stats *ClientStats) {
+	stats.mutex.RLock()
+	defer stats.mutex.RUnlock()
+
+	var total uint64
+	for _, count := range stats.Protocols {
+		total += count
+	}
+	fmt.Println("\nProtocols:")
+	if total == 0 {
+		fmt.Println("  No responses")
+		return
+	}
+	for _, proto := range sortedKeys(stats.Protocols) {
+		count := stats.Protocols[proto]
+		fmt.Printf("  %s: %d (%.2f%%)\n", proto, count, float64(count)/float64(total)*100)
+	}
+}
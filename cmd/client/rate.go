@@ -0,0 +1,89 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Load models selected with -model
+const (
+	modelClosed   = "closed"   // each client sends its next request after the previous one is answered
+	modelConstant = "constant" // requests arrive at evenly spaced times, -rps per second
+	modelOpen     = "open"     // requests arrive with exponential gaps, -rps per second on average
+)
+
+// runOpenLoop sends requests at the rate of -rps until stop is closed, whether or
+// not the earlier requests have been answered
+// With exponential, the gaps between requests are exponentially distributed, as
+// for requests from many independent users; otherwise they are even.
+// send is given the time each request was due, so that its latency includes the
+// wait for a free worker. At most workers requests are in flight, and as many
+// wait for a worker. Requests that come due while the queue is full are dropped
+// and passed to drop, since waiting for room would lower the offered load
+func runOpenLoop(rps float64, rampUp time.Duration, workers int, exponential bool, stop <-chan struct{}, drop func(), send func(scheduled time.Time)) {
+	jobs := make(chan time.Time, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for scheduled := range jobs {
+				send(scheduled)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(jobs)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	start := time.Now()
+	due := 0.0 // requests due by the next arrival, see arrivalTime
+	for {
+		if exponential {
+			due += rand.ExpFloat64()
+		} else {
+			due++
+		}
+		scheduled := start.Add(arrivalTime(rps, rampUp, due))
+
+		// Sleep until the request is due, unless it's late already, e.g. at high rates
+		if wait := time.Until(scheduled); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-stop:
+				return
+			case <-timer.C:
+			}
+		} else {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+
+		select {
+		case jobs <- scheduled:
+		default:
+			drop()
+		}
+	}
+}
+
+// arrivalTime returns the time after the start by which due requests are due,
+// with the rate rising linearly to rps over rampUp
+func arrivalTime(rps float64, rampUp time.Duration, due float64) time.Duration {
+	r := rampUp.Seconds()
+	var t float64
+	if due < rps*r/2 {
+		// Within the ramp-up, rps*t*t/(2*r) requests are due after t
+		t = math.Sqrt(2 * r * due / rps)
+	} else {
+		t = due/rps + r/2
+	}
+	return time.Duration(t * float64(time.Second))
+}
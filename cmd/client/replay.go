@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replayRequest is a request read from an access log or a request capture,
+// to be sent again at the same point of the replay
+type replayRequest struct {
+	Offset      time.Duration // since the first request
+	Method      string
+	Path        string // with the query, if any
+	Status      int    // the recorded status, 0 if unknown
+	SessionID   string // of names requests without a body, from JSON access logs
+	ContentType string
+	Body        []byte // nil if not recorded
+}
+
+// replayLine is a JSON line of a request capture or of the server's JSON access
+// log, whose time is when the request was answered, latency_ms after it started
+type replayLine struct {
+	Time        time.Time       `json:"time"`
+	Msg         string          `json:"msg"`
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Status      int             `json:"status"`
+	SessionID   string          `json:"session_id"`
+	LatencyMS   float64         `json:"latency_ms"`
+	ContentType string          `json:"content_type"`
+	Body        json.RawMessage `json:"body"` // a JSON body, or a string holding any other body
+}
+
+// commonLogLine matches the start time, quoted request line and status of a
+// common or combined access log line
+var commonLogLine = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] ("(?:[^"\\]|\\.)*") (\d{3}) `)
+
+// loadReplay reads the requests of a file in order of their start: an access
+// log of the server in the json, common or combined format, or a capture of
+// JSON lines with the time, method, path and optionally the status,
+// content_type and body of each request
+// Lines that aren't requests, e.g. other server logs, are skipped and counted
+func loadReplay(path string) ([]replayRequest, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	type timedRequest struct {
+		start time.Time
+		replayRequest
+	}
+	var requests []timedRequest
+	skipped := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var request timedRequest
+		if strings.HasPrefix(text, "{") {
+			var line replayLine
+			if err := json.Unmarshal([]byte(text), &line); err != nil {
+				return nil, 0, fmt.Errorf("line %d: %w", number, err)
+			}
+			if (line.Msg != "" && line.Msg != "Request completed") || line.Method == "" || line.Path == "" || line.Time.IsZero() {
+				skipped++
+				continue
+			}
+			request.start = line.Time.Add(-time.Duration(line.LatencyMS * float64(time.Millisecond)))
+			request.Method, request.Path, request.Status = line.Method, line.Path, line.Status
+			request.SessionID, request.ContentType = line.SessionID, line.ContentType
+			if len(line.Body) > 0 && string(line.Body) != "null" {
+				var text string
+				if json.Unmarshal(line.Body, &text) == nil {
+					request.Body = []byte(text)
+				} else {
+					request.Body = line.Body
+					if request.ContentType == "" {
+						request.ContentType = "application/json"
+					}
+				}
+			}
+		} else {
+			match := commonLogLine.FindStringSubmatch(text)
+			if match == nil {
+				skipped++
+				continue
+			}
+			if request.start, err = time.Parse(clfTimeFormat, match[1]); err != nil {
+				return nil, 0, fmt.Errorf("line %d: invalid time %q", number, match[1])
+			}
+			requestLine, err := strconv.Unquote(match[2])
+			fields := strings.Fields(requestLine)
+			if err != nil || len(fields) < 2 {
+				return nil, 0, fmt.Errorf("line %d: invalid request line %s", number, match[2])
+			}
+			request.Method, request.Path = fields[0], fields[1]
+			request.Status, _ = strconv.Atoi(match[3])
+		}
+		requests = append(requests, request)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(requests) == 0 {
+		return nil, skipped, errors.New("no requests found")
+	}
+
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].start.Before(requests[j].start)
+	})
+	result := make([]replayRequest, len(requests))
+	for i, request := range requests {
+		request.Offset = request.start.Sub(requests[0].start)
+		result[i] = request.replayRequest
+	}
+	return result, skipped, nil
+}
+
+// clfTimeFormat is the timestamp layout of common and combined access logs
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// replayDuration returns how long replaying the requests takes at speed
+func replayDuration(requests []replayRequest, speed float64) time.Duration {
+	return time.Duration(float64(requests[len(requests)-1].Offset) / speed)
+}
+
+// send sends the request to the server at baseURL, expecting the recorded status,
+// or 200 if it's unknown
+// Names requests logged without a body get one: the payload the server rejects
+// if it answered 400, and otherwise one from generate with the logged session ID,
+// checked like the requests of a load test
+func (r *replayRequest) send(baseURL string, generate *RequestMix, useProtobuf bool, scheduled time.Time, stats *ClientStats) {
+	wantStatus := r.Status
+	if wantStatus == 0 {
+		wantStatus = http.StatusOK
+	}
+	path, query, _ := strings.Cut(r.Path, "?")
+	if r.Body != nil || r.Method != http.MethodPost || path != "/generate" {
+		sendExpectingStatus(r.Method, baseURL+r.Path, r.Body, r.ContentType, wantStatus, scheduled, stats)
+		return
+	}
+
+	if wantStatus == http.StatusBadRequest {
+		sendExpectingStatus(r.Method, baseURL+r.Path, []byte(defaultInvalidBody), "application/json", wantStatus, scheduled, stats)
+		return
+	}
+	payload := generate.newPayload()
+	if r.SessionID != "" {
+		payload.SessionID = r.SessionID
+	}
+	if query == "" {
+		sendRequest(baseURL+r.Path, payload, useProtobuf, scheduled, stats)
+		return
+	}
+	// Other formats, e.g. ?format=csv, are only checked for their status
+	body, contentType, err := encodeRequest(payload, useProtobuf)
+	if err != nil {
+		stats.IncrementFailed()
+		stats.IncrementError(fmt.Sprintf("marshal: %v", err))
+		return
+	}
+	sendExpectingStatus(r.Method, baseURL+r.Path, body, contentType, http.StatusOK, scheduled, stats)
+}
+
+// runReplay sends the requests to the targets at their recorded offsets from
+// now, divided by speed, until they're all answered or stop is closed
+// As in the open model, at most workers requests are in flight and as many wait;
+// requests that come due while they're all taken are dropped. Requests started
+// in the first warmup aren't recorded in the stats
+func runReplay(requests []replayRequest, speed float64, generate *RequestMix, ts targets, useProtobuf bool, workers int, warmup time.Duration, stop <-chan struct{}, stats *ClientStats) {
+	recorded := warmupRecorder(warmup)
+
+	type job struct {
+		request   *replayRequest
+		scheduled time.Time
+	}
+	jobs := make(chan job, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				t := ts.pick()
+				j.request.send(serverBaseURL(t.URL), generate, useProtobuf, j.scheduled, recorded(t.stats))
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(jobs)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	start := time.Now()
+	for i := range requests {
+		scheduled := start.Add(time.Duration(float64(requests[i].Offset) / speed))
+		if wait := time.Until(scheduled); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-stop:
+				return
+			case <-timer.C:
+			}
+		} else {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+
+		select {
+		case jobs <- job{&requests[i], scheduled}:
+		default:
+			atomic.AddUint64(&recorded(stats).DroppedRequests, 1)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeReplay writes the content to a file in a temporary directory and
+// returns its path
+func writeReplay(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "replay.log")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []replayRequest
+		skipped  int
+	}{
+		{
+			"common log",
+			`127.0.0.1 - - [15/Oct/2026:10:00:00 +0000] "POST /generate HTTP/1.1" 200 120` + "\n" +
+				`127.0.0.1 - - [15/Oct/2026:10:00:01 +0000] "GET /health HTTP/1.1" 200 2` + "\n",
+			[]replayRequest{
+				{Offset: 0, Method: "POST", Path: "/generate", Status: 200},
+				{Offset: time.Second, Method: "GET", Path: "/health", Status: 200},
+			},
+			0,
+		},
+		{
+			"combined log in another time zone",
+			`10.0.0.1 - alice [15/Oct/2026:12:00:02 +0200] "GET /names?letter=A HTTP/2.0" 404 0 "-" "curl/8.0"` + "\n" +
+				`10.0.0.1 - - [15/Oct/2026:10:00:00 +0000] "POST /generate?format=csv HTTP/1.1" 400 30 "-" "curl/8.0"` + "\n",
+			[]replayRequest{
+				{Offset: 0, Method: "POST", Path: "/generate?format=csv", Status: 400},
+				{Offset: 2 * time.Second, Method: "GET", Path: "/names?letter=A", Status: 404},
+			},
+			0,
+		},
+		{
+			"escaped quotes in the request line",
+			`127.0.0.1 - - [15/Oct/2026:10:00:00 +0000] "GET /a\"b HTTP/1.1" 400 0` + "\n",
+			[]replayRequest{{Method: "GET", Path: `/a"b`, Status: 400}},
+			0,
+		},
+		{
+			"json access log ordered by start",
+			`{"time":"2026-10-15T10:00:01Z","msg":"Request completed","method":"POST","path":"/generate","status":200,"session_id":"s1","latency_ms":500}` + "\n" +
+				`{"time":"2026-10-15T10:00:00Z","msg":"Request completed","method":"GET","path":"/health","status":200}` + "\n" +
+				`{"time":"2026-10-15T10:00:00Z","level":"INFO","msg":"Server started"}` + "\n",
+			[]replayRequest{
+				{Offset: 0, Method: "GET", Path: "/health", Status: 200},
+				{Offset: 500 * time.Millisecond, Method: "POST", Path: "/generate", Status: 200, SessionID: "s1"},
+			},
+			1,
+		},
+		{
+			"capture with bodies",
+			`{"time":"2026-10-15T10:00:00Z","method":"POST","path":"/generate","body":{"count":2}}` + "\n" +
+				`{"time":"2026-10-15T10:00:03Z","method":"POST","path":"/generate","content_type":"text/plain","body":"hello"}` + "\n" +
+				`{"time":"2026-10-15T10:00:04Z","method":"GET","path":"/health","body":null}` + "\n",
+			[]replayRequest{
+				{Offset: 0, Method: "POST", Path: "/generate", ContentType: "application/json", Body: []byte(`{"count":2}`)},
+				{Offset: 3 * time.Second, Method: "POST", Path: "/generate", ContentType: "text/plain", Body: []byte("hello")},
+				{Offset: 4 * time.Second, Method: "GET", Path: "/health"},
+			},
+			0,
+		},
+		{
+			"blank and unrelated lines",
+			"\n  \nnot a request\n" +
+				`{"time":"2026-10-15T10:00:00Z","path":"/generate"}` + "\n" +
+				`{"method":"GET","path":"/health"}` + "\n" +
+				`127.0.0.1 - - [15/Oct/2026:10:00:00 +0000] "GET /health HTTP/1.1" 200 2` + "\n",
+			[]replayRequest{{Method: "GET", Path: "/health", Status: 200}},
+			3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, skipped, err := loadReplay(writeReplay(t, tt.content))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(requests, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, requests)
+			}
+			if skipped != tt.skipped {
+				t.Errorf("Expected %d skipped lines, got %d", tt.skipped, skipped)
+			}
+		})
+	}
+}
+
+func TestLoadReplayErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"empty", "", "no requests found"},
+		{"only other logs", `{"time":"2026-10-15T10:00:00Z","msg":"Server started"}` + "\n", "no requests found"},
+		{"malformed json", `{"time":"2026-10-15T10:00:00Z","method":"GET"` + "\n", "line 1: "},
+		{"invalid time", `127.0.0.1 - - [15/Oct/2026 10:00:00] "GET / HTTP/1.1" 200 2` + "\n", "line 1: invalid time \"15/Oct/2026 10:00:00\""},
+		{
+			"request line without a path",
+			`127.0.0.1 - - [15/Oct/2026:10:00:00 +0000] "GET /health HTTP/1.1" 200 2` + "\n" +
+				`127.0.0.1 - - [15/Oct/2026:10:00:01 +0000] "-" 408 0` + "\n",
+			"line 2: invalid request line \"-\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := loadReplay(writeReplay(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+
+	if _, _, err := loadReplay(filepath.Join(t.TempDir(), "missing.log")); !os.IsNotExist(err) {
+		t.Errorf("Expected a missing file to fail, got %v", err)
+	}
+}
+
+func TestReplayDuration(t *testing.T) {
+	requests := []replayRequest{{Offset: 0}, {Offset: time.Second}, {Offset: 10 * time.Second}}
+	tests := []struct {
+		speed    float64
+		expected time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 5 * time.Second},
+		{0.5, 20 * time.Second},
+	}
+	for _, tt := range tests {
+		if d := replayDuration(requests, tt.speed); d != tt.expected {
+			t.Errorf("Expected %s at speed %g, got %s", tt.expected, tt.speed, d)
+		}
+	}
+	if d := replayDuration(requests[:1], 1); d != 0 {
+		t.Errorf("Expected a single request to take no time, got %s", d)
+	}
+}
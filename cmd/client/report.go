@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Report holds the final statistics of a run, written with -output
+type Report struct {
+	Scenario           string             `json:"scenario,omitempty"`
+	Model              string             `json:"model,omitempty"` // the settings of runs with a single phase
+	Clients            int                `json:"clients,omitempty"`
+	TargetRPS          float64            `json:"target_rps,omitempty"`
+	DurationSeconds    float64            `json:"duration_seconds"`
+	WarmupSeconds      float64            `json:"warmup_seconds,omitempty"` // before the duration, not recorded
+	TotalRequests      uint64             `json:"total_requests"`
+	SuccessfulRequests uint64             `json:"successful_requests"`
+	FailedRequests     uint64             `json:"failed_requests"`
+	DroppedRequests    uint64             `json:"dropped_requests"`
+	FirstAttemptFails  uint64             `json:"first_attempt_failures"` // requests whose first attempt was retried or would have been
+	Attempts           uint64             `json:"attempts"`               // the first attempts and the retries
+	Retries            uint64             `json:"retries"`
+	RequestsPerSecond  float64            `json:"requests_per_second"`
+	Latency            LatencyReport      `json:"latency"`
+	StatusCodes        map[string]uint64  `json:"status_codes"`
+	Errors             map[string]uint64  `json:"errors"`
+	HTTPVersion        string             `json:"http_version,omitempty"` // the -http setting
+	Protocols          map[string]uint64  `json:"protocols"`              // responses by negotiated protocol
+	Throughput         []ThroughputSample `json:"throughput"`
+	Targets            []TargetReport     `json:"targets,omitempty"` // the statistics of each target, with several
+}
+
+// TargetReport holds the statistics of the requests sent to one target
+type TargetReport struct {
+	URL    string  `json:"url"`
+	Weight float64 `json:"weight"`
+	Report
+}
+
+// LatencyReport holds the latency distribution, in microseconds
+type LatencyReport struct {
+	Count    uint64          `json:"count"`
+	Min      int64           `json:"min_us"`
+	Avg      int64           `json:"avg_us"`
+	P50      int64           `json:"p50_us"`
+	P90      int64           `json:"p90_us"`
+	P99      int64           `json:"p99_us"`
+	P999     int64           `json:"p99_9_us"`
+	Max      int64           `json:"max_us"`
+	Spectrum []SpectrumPoint `json:"spectrum"`
+}
+
+// SpectrumPoint is the latency at one percentile of the spectrum
+type SpectrumPoint struct {
+	Percentile float64 `json:"percentile"`
+	Latency    int64   `json:"latency_us"`
+}
+
+// ThroughputSample counts the requests started in one second of the run, and
+// the latency of the requests that ended in it in microseconds
+type ThroughputSample struct {
+	Second     int    `json:"second"`
+	Requests   uint64 `json:"requests"`
+	Successful uint64 `json:"successful"`
+	Failed     uint64 `json:"failed"`
+	P50        int64  `json:"p50_us"`
+	P99        int64  `json:"p99_us"`
+}
+
+// sampleThroughput records the requests of every second until stop is closed
+func (s *ClientStats) sampleThroughput(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var last ThroughputSample
+	for second := 1; ; second++ {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current := ThroughputSample{
+			Requests:   atomic.LoadUint64(&s.TotalRequests),
+			Successful: atomic.LoadUint64(&s.SuccessfulRequests),
+			Failed:     atomic.LoadUint64(&s.FailedRequests),
+		}
+		s.mutex.Lock()
+		s.throughput = append(s.throughput, ThroughputSample{
+			Second:     second,
+			Requests:   current.Requests - last.Requests,
+			Successful: current.Successful - last.Successful,
+			Failed:     current.Failed - last.Failed,
+			P50:        s.recent.Percentile(50).Microseconds(),
+			P99:        s.recent.Percentile(99).Microseconds(),
+		})
+		s.recent.Reset()
+		s.mutex.Unlock()
+		last = current
+	}
+}
+
+// latencySpectrum returns the latency at percentiles ever closer to 100, halving
+// the share of slower requests each point until less than one request is left,
+// as in HdrHistogram's percentile distribution, and then at 100
+// The caller must hold the stats mutex
+func (s *ClientStats) latencySpectrum() []SpectrumPoint {
+	count := s.latencies.Count()
+	if count == 0 {
+		return nil
+	}
+
+	var points []SpectrumPoint
+	for slower := 1.0; slower*float64(count) >= 1; slower /= 2 {
+		percentile := 100 * (1 - slower)
+		points = append(points, SpectrumPoint{Percentile: percentile, Latency: s.latencies.Percentile(percentile).Microseconds()})
+	}
+	return append(points, SpectrumPoint{Percentile: 100, Latency: s.latencies.Percentile(100).Microseconds()})
+}
+
+// buildReport collects the statistics of a run that took duration
+func buildReport(stats *ClientStats, duration time.Duration) Report {
+	report := Report{
+		DurationSeconds:    duration.Seconds(),
+		TotalRequests:      atomic.LoadUint64(&stats.TotalRequests),
+		SuccessfulRequests: atomic.LoadUint64(&stats.SuccessfulRequests),
+		FailedRequests:     atomic.LoadUint64(&stats.FailedRequests),
+		DroppedRequests:    atomic.LoadUint64(&stats.DroppedRequests),
+		FirstAttemptFails:  atomic.LoadUint64(&stats.FirstAttemptFails),
+		Attempts:           atomic.LoadUint64(&stats.Attempts),
+		Retries:            atomic.LoadUint64(&stats.Retries),
+		StatusCodes:        make(map[string]uint64),
+		Errors:             make(map[string]uint64),
+		Protocols:          make(map[string]uint64),
+	}
+	if duration > 0 {
+		report.RequestsPerSecond = float64(report.TotalRequests) / duration.Seconds()
+	}
+
+	stats.mutex.RLock()
+	defer stats.mutex.RUnlock()
+
+	h := stats.latencies
+	report.Latency = LatencyReport{
+		Count:    h.Count(),
+		Min:      h.Percentile(0).Microseconds(),
+		P50:      h.Percentile(50).Microseconds(),
+		P90:      h.Percentile(90).Microseconds(),
+		P99:      h.Percentile(99).Microseconds(),
+		P999:     h.Percentile(99.9).Microseconds(),
+		Max:      h.Percentile(100).Microseconds(),
+		Spectrum: stats.latencySpectrum(),
+	}
+	if h.Count() > 0 {
+		report.Latency.Avg = (stats.latencySum / time.Duration(h.Count())).Microseconds()
+	}
+	for code, count := range stats.StatusCodes {
+		report.StatusCodes[strconv.Itoa(code)] = count
+	}
+	for err, count := range stats.Errors {
+		report.Errors[err] = count
+	}
+	for proto, count := range stats.Protocols {
+		report.Protocols[proto] = count
+	}
+	report.Throughput = append([]ThroughputSample(nil), stats.throughput...)
+	return report
+}
+
+// writeReport writes the report to path, as CSV if its extension is .csv and
+// as JSON otherwise
+func writeReport(path string, report Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = writeReportCSV(file, report)
+	} else {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeReportCSV writes the report as rows of metric, key and value, so every
+// part of it fits in one table
+func writeReportCSV(file *os.File, report Report) error {
+	w := csv.NewWriter(file)
+	row := func(metric, key string, value interface{}) {
+		var text string
+		switch v := value.(type) {
+		case float64:
+			text = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			text = v
+		default:
+			text = strconv.FormatInt(toInt64(v), 10)
+		}
+		w.Write([]string{metric, key, text})
+	}
+
+	row("metric", "key", "value")
+	row("scenario", "", report.Scenario)
+	row("model", "", report.Model)
+	row("clients", "", report.Clients)
+	row("target_rps", "", report.TargetRPS)
+	row("duration_seconds", "", report.DurationSeconds)
+	row("warmup_seconds", "", report.WarmupSeconds)
+	row("total_requests", "", report.TotalRequests)
+	row("successful_requests", "", report.SuccessfulRequests)
+	row("failed_requests", "", report.FailedRequests)
+	row("dropped_requests", "", report.DroppedRequests)
+	row("first_attempt_failures", "", report.FirstAttemptFails)
+	row("attempts", "", report.Attempts)
+	row("retries", "", report.Retries)
+	row("requests_per_second", "", report.RequestsPerSecond)
+	row("http_version", "", report.HTTPVersion)
+
+	latency := report.Latency
+	row("latency_count", "", latency.Count)
+	row("latency_us", "min", latency.Min)
+	row("latency_us", "avg", latency.Avg)
+	row("latency_us", "p50", latency.P50)
+	row("latency_us", "p90", latency.P90)
+	row("latency_us", "p99", latency.P99)
+	row("latency_us", "p99.9", latency.P999)
+	row("latency_us", "max", latency.Max)
+	for _, point := range latency.Spectrum {
+		row("latency_spectrum_us", strconv.FormatFloat(point.Percentile, 'f', -1, 64), point.Latency)
+	}
+
+	for _, code := range sortedKeys(report.StatusCodes) {
+		row("status_code", code, report.StatusCodes[code])
+	}
+	for _, err := range sortedKeys(report.Errors) {
+		row("error", err, report.Errors[err])
+	}
+	for _, proto := range sortedKeys(report.Protocols) {
+		row("protocol", proto, report.Protocols[proto])
+	}
+	for _, sample := range report.Throughput {
+		second := strconv.Itoa(sample.Second)
+		row("throughput_requests", second, sample.Requests)
+		row("throughput_successful", second, sample.Successful)
+		row("throughput_failed", second, sample.Failed)
+		row("throughput_p50_us", second, sample.P50)
+		row("throughput_p99_us", second, sample.P99)
+	}
+
+	for _, t := range report.Targets {
+		row("target_weight", t.URL, t.Weight)
+		row("target_total_requests", t.URL, t.TotalRequests)
+		row("target_successful_requests", t.URL, t.SuccessfulRequests)
+		row("target_failed_requests", t.URL, t.FailedRequests)
+		row("target_requests_per_second", t.URL, t.RequestsPerSecond)
+		row("target_latency_p50_us", t.URL, t.Latency.P50)
+		row("target_latency_p99_us", t.URL, t.Latency.P99)
+		row("target_latency_max_us", t.URL, t.Latency.Max)
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// toInt64 converts the integer values of a report to int64
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case uint64:
+		return int64(v)
+	}
+	return 0
+}
+
+// sortedKeys returns the keys of counts in increasing order
+func sortedKeys(counts map[string]uint64) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retryPolicy decides which failed attempts are retried and when, see -retries
+type retryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration // delay before the first retry, doubled for each next one
+	MaxBackoff time.Duration
+	OnErrors   bool // retry transport errors, e.g. refused connections and timeouts
+	On5xx      bool
+	Statuses   map[int]bool // other statuses to retry
+}
+
+// retries is the retry policy of the run, set from the flags
+var retries = retryPolicy{
+	MaxRetries: 3,
+	Backoff:    100 * time.Millisecond,
+	MaxBackoff: 5 * time.Second,
+	OnErrors:   true,
+	Statuses:   map[int]bool{http.StatusTooManyRequests: true},
+}
+
+// parseRetryOn sets what the policy retries from a comma-separated list of
+// errors, 5xx and status codes, e.g. errors,429,5xx
+func (p *retryPolicy) parseRetryOn(spec string) error {
+	p.OnErrors, p.On5xx, p.Statuses = false, false, make(map[int]bool)
+	for _, item := range strings.Split(spec, ",") {
+		switch item = strings.ToLower(strings.TrimSpace(item)); item {
+		case "":
+		case "errors":
+			p.OnErrors = true
+		case "5xx":
+			p.On5xx = true
+		default:
+			code, err := strconv.Atoi(item)
+			if err != nil || code < 100 || code > 599 {
+				return fmt.Errorf("invalid %q, want errors, 5xx or a status code", item)
+			}
+			p.Statuses[code] = true
+		}
+	}
+	return nil
+}
+
+// shouldRetry reports whether an attempt that got resp or err is retried, unless
+// it got wantStatus
+func (p retryPolicy) shouldRetry(resp *http.Response, err error, wantStatus int) bool {
+	switch {
+	case err != nil:
+		return p.OnErrors
+	case resp.StatusCode == wantStatus:
+		return false
+	case resp.StatusCode >= 500 && p.On5xx:
+		return true
+	}
+	return p.Statuses[resp.StatusCode]
+}
+
+// delay returns how long to wait before retry number attempt+1: the Retry-After
+// of the response if it has one, or the backoff doubled attempt times, capped
+// at the maximum, of which a random half is waited, so that clients failing
+// together don't retry together
+func (p retryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+				return time.Duration(seconds) * time.Second
+			}
+			if date, err := http.ParseTime(retryAfter); err == nil {
+				return max(time.Until(date), 0)
+			}
+		}
+	}
+
+	backoff := p.Backoff
+	for i := 0; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, p.MaxBackoff)
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// sendWithRetries sends a request, retrying it as the retry policy allows, and
+// counts each attempt. The latency of the request is recorded once, from
+// scheduled if it's set, to the last attempt, so it includes the failed
+// attempts and the backoff in between as the user would wait for them
+// It returns the response or error of the last attempt
+func sendWithRetries(req *http.Request, wantStatus int, scheduled time.Time, stats *ClientStats) (*http.Response, error) {
+	startTime := scheduled
+	if startTime.IsZero() {
+		startTime = time.Now()
+	}
+	stats.IncrementTotal()
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 {
+			attemptReq = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					stats.RecordLatency(time.Since(startTime))
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := httpClient.Do(attemptReq)
+		stats.IncrementAttempts()
+		if err == nil {
+			stats.IncrementStatusCode(resp.StatusCode)
+			stats.IncrementProtocol(resp.Proto)
+		}
+
+		retry := retries.shouldRetry(resp, err, wantStatus)
+		if retry && attempt == 0 {
+			stats.IncrementFirstAttemptFails()
+		}
+		if !retry || attempt >= retries.MaxRetries {
+			stats.RecordLatency(time.Since(startTime))
+			return resp, err
+		}
+
+		delay := retries.delay(attempt, resp)
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		stats.IncrementRetries()
+		time.Sleep(delay)
+	}
+}
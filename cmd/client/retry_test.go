@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRetryOn(t *testing.T) {
+	tests := []struct {
+		spec     string
+		onErrors bool
+		on5xx    bool
+		statuses map[int]bool
+		valid    bool
+	}{
+		{"", false, false, map[int]bool{}, true},
+		{"errors", true, false, map[int]bool{}, true},
+		{"errors, 5XX ,429", true, true, map[int]bool{429: true}, true},
+		{"408,503,,", false, false, map[int]bool{408: true, 503: true}, true},
+		{"timeouts", false, false, nil, false},
+		{"99", false, false, nil, false},
+		{"600", false, false, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			var p retryPolicy
+			err := p.parseRetryOn(tt.spec)
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("Expected %q to be rejected", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if p.OnErrors != tt.onErrors || p.On5xx != tt.on5xx || !reflect.DeepEqual(p.Statuses, tt.statuses) {
+				t.Errorf("Expected errors=%v 5xx=%v statuses=%v, got errors=%v 5xx=%v statuses=%v", tt.onErrors, tt.on5xx, tt.statuses, p.OnErrors, p.On5xx, p.Statuses)
+			}
+		})
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	policy := retryPolicy{OnErrors: true, On5xx: true, Statuses: map[int]bool{http.StatusTooManyRequests: true}}
+	noErrors := retryPolicy{Statuses: map[int]bool{}}
+
+	tests := []struct {
+		name       string
+		policy     retryPolicy
+		status     int // 0 for a transport error
+		wantStatus int
+		expected   bool
+	}{
+		{"transport error", policy, 0, http.StatusOK, true},
+		{"transport error not retried", noErrors, 0, http.StatusOK, false},
+		{"expected status", policy, http.StatusOK, http.StatusOK, false},
+		{"expected error status", policy, http.StatusServiceUnavailable, http.StatusServiceUnavailable, false},
+		{"5xx", policy, http.StatusBadGateway, http.StatusOK, true},
+		{"5xx not retried", noErrors, http.StatusBadGateway, http.StatusOK, false},
+		{"listed status", policy, http.StatusTooManyRequests, http.StatusOK, true},
+		{"other 4xx", policy, http.StatusBadRequest, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			err := errors.New("connection refused")
+			if tt.status != 0 {
+				resp, err = &http.Response{StatusCode: tt.status}, nil
+			}
+			if retry := tt.policy.shouldRetry(resp, err, tt.wantStatus); retry != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, retry)
+			}
+		})
+	}
+}
+
+func TestDelay(t *testing.T) {
+	policy := retryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	tests := []struct {
+		name       string
+		policy     retryPolicy
+		attempt    int
+		retryAfter string
+		min, max   time.Duration
+	}{
+		{"first retry", policy, 0, "", 50 * time.Millisecond, 100 * time.Millisecond},
+		{"doubled", policy, 2, "", 200 * time.Millisecond, 400 * time.Millisecond},
+		{"capped", policy, 10, "", 500 * time.Millisecond, time.Second},
+		{"no backoff", retryPolicy{}, 3, "", 0, 0},
+		{"retry-after seconds", policy, 0, "3", 3 * time.Second, 3 * time.Second},
+		{"retry-after zero", policy, 5, "0", 0, 0},
+		{"retry-after past date", policy, 0, "Wed, 21 Oct 2015 07:28:00 GMT", 0, 0},
+		{"retry-after invalid", policy, 0, "soon", 50 * time.Millisecond, 100 * time.Millisecond},
+		{"retry-after negative", policy, 0, "-1", 50 * time.Millisecond, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+			for i := 0; i < 20; i++ {
+				if d := tt.policy.delay(tt.attempt, resp); d < tt.min || d > tt.max {
+					t.Fatalf("Expected a delay in [%s, %s], got %s", tt.min, tt.max, d)
+				}
+			}
+		})
+	}
+
+	future := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	resp := &http.Response{Header: http.Header{"Retry-After": {future}}}
+	if d := policy.delay(0, resp); d <= 8*time.Second || d > 10*time.Second {
+		t.Errorf("Expected a delay until %s, got %s", future, d)
+	}
+	if d := policy.delay(0, nil); d < 50*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("Expected the backoff without a response, got %s", d)
+	}
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kinds of requests in a scenario
+const (
+	kindGenerate = "generate" // POST valid names requests to -url
+	kindInvalid  = "invalid"  // POST a payload the server must reject to -url
+	kindGet      = "get"      // GET a path of the server, e.g. /stats
+)
+
+// Scenario describes a load test: the mix of requests to send and the phases
+// to send them in, one after the other
+type Scenario struct {
+	Name     string       `json:"name"`
+	Phases   []Phase      `json:"phases"`
+	Requests []RequestMix `json:"requests"`
+
+	cumulativeWeights []float64
+}
+
+// Phase is a stretch of a scenario with a fixed load
+type Phase struct {
+	Name     string   `json:"name"`
+	Duration Duration `json:"duration"`
+	Model    string   `json:"model"`   // closed, constant or open, see -model
+	Clients  int      `json:"clients"` // concurrent clients, or requests in flight with a rate
+	RPS      float64  `json:"rps"`
+	RampUp   Duration `json:"ramp_up"`
+}
+
+// RequestMix is one kind of request of a scenario, sent in proportion to its weight
+type RequestMix struct {
+	Name                string   `json:"name"`
+	Weight              float64  `json:"weight"`
+	Kind                string   `json:"kind"`
+	Letters             []string `json:"letters"`     // letters to pick from, all of A to Z if empty
+	MinEntries          int      `json:"min_entries"` // range of num_of_entries, 1 to 20 if zero
+	MaxEntries          int      `json:"max_entries"`
+	EntriesDistribution string   `json:"entries_distribution"` // uniform or zipf, uniform if empty
+	Sessions            int      `json:"sessions"`             // session IDs to reuse, a new one per request if zero
+	Path                string   `json:"path"`                 // path of get requests, /stats if empty
+	Body                string   `json:"body"`                 // body of invalid requests
+	ExpectStatus        int      `json:"expect_status"`        // status that counts as success
+
+	entryWeights []float64 // cumulative weights of the counts from MinEntries on, for zipf
+	sessionIDs   []string
+}
+
+// Distributions of num_of_entries
+const (
+	distributionUniform = "uniform" // every count in the range equally often
+	distributionZipf    = "zipf"    // count n of the range 1/n as often as the first, so mostly small counts
+)
+
+// Duration is a time.Duration read from strings such as "30s"
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON reads a duration string, or a number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		d.Duration = time.Duration(seconds * float64(time.Second))
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	d.Duration = duration
+	return nil
+}
+
+// defaultInvalidBody asks for a negative number of names, which the server rejects
+const defaultInvalidBody = `{"session_id":"invalid","letter":"A","num_of_entries":-1}`
+
+// loadScenario reads a scenario from a YAML file
+// Phases without clients get defaultClients
+func loadScenario(path string, defaultClients int) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	value, err := parseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// Decode through JSON, so the fields are checked against the struct tags
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(encoded)))
+	decoder.DisallowUnknownFields()
+	var scenario Scenario
+	if err := decoder.Decode(&scenario); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := scenario.prepare(defaultClients); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &scenario, nil
+}
+
+// defaultScenario returns the scenario run without -scenario: a single phase of
+// the requests, set up by the flags
+func defaultScenario(duration, rampUp time.Duration, clients int, rps float64, model string, requests []RequestMix) (*Scenario, error) {
+	scenario := &Scenario{
+		Phases: []Phase{{
+			Duration: Duration{duration},
+			Model:    model,
+			Clients:  clients,
+			RPS:      rps,
+			RampUp:   Duration{rampUp},
+		}},
+		Requests: requests,
+	}
+	if err := scenario.prepare(clients); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+// prepare checks a scenario and fills in the defaults
+func (s *Scenario) prepare(defaultClients int) error {
+	if len(s.Phases) == 0 {
+		return errors.New("a scenario needs at least one phase")
+	}
+	for i := range s.Phases {
+		phase := &s.Phases[i]
+		if phase.Name == "" {
+			phase.Name = fmt.Sprintf("phase %d", i+1)
+		}
+		if phase.Clients == 0 {
+			phase.Clients = defaultClients
+		}
+		if err := checkModel(phase.Model, phase.RPS); err != nil {
+			return fmt.Errorf("%s: %w", phase.Name, err)
+		}
+		if phase.Model == "" {
+			phase.Model = modelClosed
+			if phase.RPS > 0 {
+				phase.Model = modelConstant
+			}
+		}
+		switch {
+		case phase.Duration.Duration <= 0:
+			return fmt.Errorf("%s: duration must be positive", phase.Name)
+		case phase.Clients < 1:
+			return fmt.Errorf("%s: clients must be at least 1", phase.Name)
+		case phase.RampUp.Duration < 0:
+			return fmt.Errorf("%s: ramp_up must not be negative", phase.Name)
+		}
+	}
+
+	if len(s.Requests) == 0 {
+		return errors.New("a scenario needs at least one request")
+	}
+	total := 0.0
+	for i := range s.Requests {
+		mix := &s.Requests[i]
+		if mix.Kind == "" {
+			mix.Kind = kindGenerate
+		}
+		if mix.Name == "" {
+			mix.Name = mix.Kind
+		}
+		if mix.Weight == 0 {
+			// A weight of 0 can't be told from a missing one
+			mix.Weight = 1
+		}
+		if mix.MinEntries == 0 && mix.MaxEntries == 0 {
+			mix.MinEntries, mix.MaxEntries = 1, 20
+		} else if mix.MinEntries == 0 {
+			mix.MinEntries = 1
+		} else if mix.MaxEntries == 0 {
+			mix.MaxEntries = mix.MinEntries
+		}
+		switch {
+		case mix.Weight < 0:
+			return fmt.Errorf("%s: weight must not be negative", mix.Name)
+		case mix.Kind != kindGenerate && mix.Kind != kindInvalid && mix.Kind != kindGet:
+			return fmt.Errorf("%s: unknown kind %q, want generate, invalid or get", mix.Name, mix.Kind)
+		case mix.MinEntries < 1 || mix.MaxEntries < mix.MinEntries:
+			return fmt.Errorf("%s: min_entries and max_entries must be a range of positive numbers", mix.Name)
+		case mix.EntriesDistribution != "" && mix.EntriesDistribution != distributionUniform && mix.EntriesDistribution != distributionZipf:
+			return fmt.Errorf("%s: unknown entries_distribution %q, want uniform or zipf", mix.Name, mix.EntriesDistribution)
+		case mix.Sessions < 0:
+			return fmt.Errorf("%s: sessions must not be negative", mix.Name)
+		}
+		if mix.EntriesDistribution == distributionZipf {
+			total := 0.0
+			for n := 1; n <= mix.MaxEntries-mix.MinEntries+1; n++ {
+				total += 1 / float64(n)
+				mix.entryWeights = append(mix.entryWeights, total)
+			}
+		}
+		for i := 0; i < mix.Sessions; i++ {
+			mix.sessionIDs = append(mix.sessionIDs, generateRandomSessionID())
+		}
+		switch mix.Kind {
+		case kindInvalid:
+			if mix.Body == "" {
+				mix.Body = defaultInvalidBody
+			}
+			if mix.ExpectStatus == 0 {
+				mix.ExpectStatus = 400
+			}
+		case kindGet:
+			if mix.Path == "" {
+				mix.Path = "/stats"
+			}
+		}
+		if mix.ExpectStatus == 0 {
+			mix.ExpectStatus = 200
+		}
+		total += mix.Weight
+		s.cumulativeWeights = append(s.cumulativeWeights, total)
+	}
+	return nil
+}
+
+// checkModel checks a load model and the rate it's given, see -model
+func checkModel(model string, rps float64) error {
+	switch {
+	case rps < 0:
+		return errors.New("rps must not be negative")
+	case model == modelClosed && rps > 0:
+		return errors.New("rps needs the constant or open model")
+	case (model == modelConstant || model == modelOpen) && rps == 0:
+		return fmt.Errorf("the %s model needs rps", model)
+	case model != "" && model != modelClosed && model != modelConstant && model != modelOpen:
+		return fmt.Errorf("unknown model %q, want closed, constant or open", model)
+	}
+	return nil
+}
+
+// Duration returns the total duration of the phases
+func (s *Scenario) Duration() time.Duration {
+	var total time.Duration
+	for _, phase := range s.Phases {
+		total += phase.Duration.Duration
+	}
+	return total
+}
+
+// pick returns a request of the mix, picked by weight
+func (s *Scenario) pick() *RequestMix {
+	target := rand.Float64() * s.cumulativeWeights[len(s.cumulativeWeights)-1]
+	for i, cumulative := range s.cumulativeWeights {
+		if target < cumulative {
+			return &s.Requests[i]
+		}
+	}
+	return &s.Requests[len(s.Requests)-1]
+}
+
+// send sends one request of this kind
+func (m *RequestMix) send(serverURL string, useProtobuf bool, scheduled time.Time, stats *ClientStats) {
+	switch m.Kind {
+	case kindInvalid:
+		sendExpectingStatus(http.MethodPost, serverURL, []byte(m.Body), "application/json", m.ExpectStatus, scheduled, stats)
+	case kindGet:
+		sendExpectingStatus(http.MethodGet, serverBaseURL(serverURL)+m.Path, nil, "", m.ExpectStatus, scheduled, stats)
+	default:
+		sendRequest(serverURL, m.newPayload(), useProtobuf, scheduled, stats)
+	}
+}
+
+// newPayload returns the payload of a names request of this kind
+func (m *RequestMix) newPayload() RequestPayload {
+	letter := generateRandomLetter()
+	if len(m.Letters) > 0 {
+		letter = m.Letters[rand.Intn(len(m.Letters))]
+	}
+	sessionID := generateRandomSessionID()
+	if len(m.sessionIDs) > 0 {
+		sessionID = m.sessionIDs[rand.Intn(len(m.sessionIDs))]
+	}
+	return RequestPayload{
+		SessionID:    sessionID,
+		Letter:       letter,
+		NumOfEntries: m.pickEntries(),
+	}
+}
+
+// pickEntries returns a num_of_entries of the range, following the distribution
+func (m *RequestMix) pickEntries() int {
+	if len(m.entryWeights) == 0 {
+		return m.MinEntries + rand.Intn(m.MaxEntries-m.MinEntries+1)
+	}
+	target := rand.Float64() * m.entryWeights[len(m.entryWeights)-1]
+	return m.MinEntries + sort.SearchFloat64s(m.entryWeights, target)
+}
+
+// payloadMix returns the requests set up by the payload flags: names requests
+// for the letters, a comma-separated list of letters or prefixes, or letters
+// written together such as ABC; the entries, a range such as 1-20 or a single
+// count; and invalidPercent percent of invalid requests
+func payloadMix(letters, entries, distribution string, invalidPercent float64, sessions int) ([]RequestMix, error) {
+	mix := RequestMix{Name: "names", EntriesDistribution: distribution, Sessions: sessions}
+	if strings.Contains(letters, ",") {
+		for _, letter := range strings.Split(letters, ",") {
+			if letter = strings.TrimSpace(letter); letter != "" {
+				mix.Letters = append(mix.Letters, letter)
+			}
+		}
+	} else {
+		for _, letter := range letters {
+			mix.Letters = append(mix.Letters, string(letter))
+		}
+	}
+
+	low, high, isRange := strings.Cut(entries, "-")
+	var err error
+	if mix.MinEntries, err = strconv.Atoi(strings.TrimSpace(low)); err != nil {
+		return nil, fmt.Errorf("invalid entries %q, want a range such as 1-20 or a single count", entries)
+	}
+	mix.MaxEntries = mix.MinEntries
+	if isRange {
+		if mix.MaxEntries, err = strconv.Atoi(strings.TrimSpace(high)); err != nil {
+			return nil, fmt.Errorf("invalid entries %q, want a range such as 1-20 or a single count", entries)
+		}
+	}
+	if mix.MinEntries < 1 || mix.MaxEntries < mix.MinEntries {
+		return nil, fmt.Errorf("invalid entries %q, want a range of positive counts", entries)
+	}
+
+	// A weight of 0 would be taken as the default, so leave out unused kinds
+	switch {
+	case invalidPercent < 0 || invalidPercent > 100:
+		return nil, fmt.Errorf("invalid percentage of invalid requests %g, want 0 to 100", invalidPercent)
+	case invalidPercent == 0:
+		return []RequestMix{mix}, nil
+	case invalidPercent == 100:
+		return []RequestMix{{Name: "invalid", Kind: kindInvalid}}, nil
+	}
+	mix.Weight = 100 - invalidPercent
+	return []RequestMix{mix, {Name: "invalid", Kind: kindInvalid, Weight: invalidPercent}}, nil
+}
+
+// serverBaseURL returns the URL of the server from the URL of its generate endpoint
+func serverBaseURL(serverURL string) string {
+	return strings.TrimSuffix(serverURL, "/generate")
+}
+
+// runScenario runs the phases of a scenario one after the other, until they
+// are done or stop is closed, sending each request to one of the targets
+// Requests started in the first warmup of the run aren't recorded in the stats.
+// Each phase ends once its requests in flight are answered
+func runScenario(scenario *Scenario, ts targets, useProtobuf bool, warmup time.Duration, stop <-chan struct{}, stats *ClientStats) {
+	recorded := warmupRecorder(warmup)
+	send := func(scheduled time.Time) {
+		t := ts.pick()
+		scenario.pick().send(t.URL, useProtobuf, scheduled, recorded(t.stats))
+	}
+	drop := func() {
+		atomic.AddUint64(&recorded(stats).DroppedRequests, 1)
+	}
+
+	for i, phase := range scenario.Phases {
+		if len(scenario.Phases) > 1 {
+			fmt.Fprintf(progress, "Phase %d/%d, %s: %s\n", i+1, len(scenario.Phases), phase.Name, phase.describe())
+		}
+
+		// End the phase after its duration, or with the whole test
+		phaseStop := make(chan struct{})
+		timer := time.NewTimer(phase.Duration.Duration)
+		go func() {
+			select {
+			case <-timer.C:
+			case <-stop:
+				timer.Stop()
+			}
+			close(phaseStop)
+		}()
+
+		if phase.Model == modelClosed {
+			runClosedLoop(phase.Clients, phase.RampUp.Duration, phaseStop, send)
+		} else {
+			runOpenLoop(phase.RPS, phase.RampUp.Duration, phase.Clients, phase.Model == modelOpen, phaseStop, drop, send)
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// warmupRecorder returns a function that gives the stats to record a request
+// started now in: stats itself, or stats that are thrown away during the first
+// warmup from now
+func warmupRecorder(warmup time.Duration) func(stats *ClientStats) *ClientStats {
+	warmupEnd := time.Now().Add(warmup)
+	warmupStats := NewClientStats()
+	return func(stats *ClientStats) *ClientStats {
+		if time.Now().Before(warmupEnd) {
+			return warmupStats
+		}
+		return stats
+	}
+}
+
+// describe returns the load of a phase, e.g. for printing
+func (p Phase) describe() string {
+	if p.Model == modelClosed {
+		return fmt.Sprintf("%d concurrent clients for %s", p.Clients, p.Duration)
+	}
+	return fmt.Sprintf("%g requests per second (%s model), with up to %d in flight, for %s", p.RPS, p.Model, p.Clients, p.Duration)
+}
+
+// runClosedLoop runs clients that each send a request, wait for the answer and
+// a short pause, and send the next, until stop is closed
+// The clients are started evenly over rampUp, and it returns once they're done
+func runClosedLoop(clients int, rampUp time.Duration, stop <-chan struct{}, send func(scheduled time.Time)) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	rampUpInterval := rampUp / time.Duration(clients)
+	for i := 0; i < clients; i++ {
+		// Add a delay for ramp-up
+		if rampUp > 0 {
+			select {
+			case <-stop:
+				return
+			case <-time.After(rampUpInterval):
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				send(time.Time{})
+
+				// Add some randomization to request timing with jitter
+				// This helps avoid synchronized bursts of requests
+				sleepTime := 100*time.Millisecond + time.Duration(rand.Intn(200))*time.Millisecond
+				select {
+				case <-stop:
+					return
+				case <-time.After(sleepTime):
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// defaultURL is the target without -url
+const defaultURL = "http://localhost:8080/generate"
+
+// urlsFlag collects the values of a repeated -url flag
+type urlsFlag []string
+
+func (f *urlsFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *urlsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// target is a server the requests are sent to, with its share of them
+type target struct {
+	URL    string
+	Weight float64
+	stats  *ClientStats // the requests sent to this target
+}
+
+// targets are the servers of a run, each sent requests in proportion to its weight
+type targets []*target
+
+// parseTargets parses -url values, URLs optionally followed by a comma and a
+// weight, e.g. http://localhost:8080/generate,3
+// With several targets, each counts its own requests, which stats also counts;
+// a single target counts them in stats
+func parseTargets(values []string, stats *ClientStats) (targets, error) {
+	if len(values) == 0 {
+		values = []string{defaultURL}
+	}
+
+	var result targets
+	for _, value := range values {
+		t := &target{URL: value, Weight: 1, stats: stats}
+		if i := strings.LastIndex(value, ","); i >= 0 {
+			if weight, err := strconv.ParseFloat(value[i+1:], 64); err == nil {
+				if weight <= 0 {
+					return nil, fmt.Errorf("the weight of %s must be positive", value[:i])
+				}
+				t.URL, t.Weight = value[:i], weight
+			}
+		}
+		if !strings.HasPrefix(t.URL, "http://") && !strings.HasPrefix(t.URL, "https://") {
+			return nil, fmt.Errorf("invalid URL %q, want an http or https URL", t.URL)
+		}
+		if len(values) > 1 {
+			t.stats = stats.NewChildStats()
+		}
+		result = append(result, t)
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no target URL")
+	}
+	return result, nil
+}
+
+// pick returns a target, picked by weight
+func (ts targets) pick() *target {
+	if len(ts) == 1 {
+		return ts[0]
+	}
+	total := 0.0
+	for _, t := range ts {
+		total += t.Weight
+	}
+	n := rand.Float64() * total
+	for _, t := range ts {
+		if n < t.Weight {
+			return t
+		}
+		n -= t.Weight
+	}
+	return ts[len(ts)-1]
+}
+
+// printTargets prints the statistics of each target side by side
+func printTargets(ts targets, duration time.Duration) {
+	if len(ts) < 2 {
+		return
+	}
+
+	fmt.Println("\nTargets:")
+	fmt.Printf("  %-40s %8s %9s %9s %10s %10s %10s\n", "URL", "Requests", "Success", "Req/s", "p50", "p99", "Max")
+	for _, t := range ts {
+		total := atomic.LoadUint64(&t.stats.TotalRequests)
+		successful := atomic.LoadUint64(&t.stats.SuccessfulRequests)
+		success := 0.0
+		if total > 0 {
+			success = float64(successful) / float64(total) * 100
+		}
+
+		t.stats.mutex.RLock()
+		p50 := formatLatency(t.stats.latencies.Percentile(50))
+		p99 := formatLatency(t.stats.latencies.Percentile(99))
+		max := formatLatency(t.stats.latencies.Percentile(100))
+		t.stats.mutex.RUnlock()
+
+		fmt.Printf("  %-40s %8d %8.2f%% %9.2f %10s %10s %10s\n", t.URL, total, success, float64(total)/duration.Seconds(), p50, p99, max)
+	}
+}
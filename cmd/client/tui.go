@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ANSI escape sequences used by the terminal UI
+const (
+	ansiAlternateScreen = "\x1b[?1049h"
+	ansiMainScreen      = "\x1b[?1049l"
+	ansiHideCursor      = "\x1b[?25l"
+	ansiShowCursor      = "\x1b[?25h"
+	ansiHome            = "\x1b[H"
+	ansiClearToEnd      = "\x1b[J"
+	ansiClearLine       = "\x1b[K"
+	ansiBold            = "\x1b[1m"
+	ansiRed             = "\x1b[31m"
+	ansiReset           = "\x1b[0m"
+)
+
+// sparkLevels are the bars of a sparkline, from lowest to highest
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// Size of the terminal UI
+const (
+	tuiSparkWidth = 60 // seconds shown in the sparklines
+	tuiTopErrors  = 5
+	tuiLogLines   = 5
+	tuiLineWidth  = 100 // longer lines, e.g. error messages, are cut
+)
+
+// progress is where messages about the progress of the run are written, e.g.
+// the start of each phase, which the terminal UI shows below the statistics
+var progress io.Writer = os.Stdout
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// tui shows the statistics of a run live in the terminal, redrawing them every
+// second on the alternate screen, which is left for the final summary
+type tui struct {
+	out      io.Writer
+	stats    *ClientStats
+	header   []string  // lines describing the run
+	start    time.Time // when recording starts, after the warm-up
+	duration time.Duration
+	logs     *logTail
+	done     chan struct{} // closed once the screen is restored
+}
+
+// newTUI creates a terminal UI of a run whose recorded part starts at start and
+// lasts duration
+func newTUI(out io.Writer, stats *ClientStats, header []string, start time.Time, duration time.Duration) *tui {
+	return &tui{
+		out:      out,
+		stats:    stats,
+		header:   header,
+		start:    start,
+		duration: duration,
+		logs:     &logTail{size: tuiLogLines},
+		done:     make(chan struct{}),
+	}
+}
+
+// run draws the statistics every second until stop is closed, showing what's
+// logged in the meantime below them, then restores the screen and the log
+func (u *tui) run(stop <-chan struct{}) {
+	defer close(u.done)
+
+	logOutput := log.Writer()
+	log.SetOutput(u.logs)
+	fmt.Fprint(u.out, ansiAlternateScreen+ansiHideCursor)
+	defer func() {
+		fmt.Fprint(u.out, ansiShowCursor+ansiMainScreen)
+		log.SetOutput(logOutput)
+		for _, line := range u.logs.lines() {
+			fmt.Fprintln(logOutput, line)
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		u.draw()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// draw redraws the screen
+func (u *tui) draw() {
+	var b bytes.Buffer
+	line := func(format string, args ...interface{}) {
+		text := fmt.Sprintf(format, args...)
+		if len([]rune(text)) > tuiLineWidth && !strings.Contains(text, "\x1b") {
+			text = string([]rune(text)[:tuiLineWidth-1]) + "…"
+		}
+		b.WriteString(text + ansiClearLine + "\n")
+	}
+
+	b.WriteString(ansiHome)
+	for _, header := range u.header {
+		line("%s", header)
+	}
+	elapsed := time.Since(u.start)
+	if elapsed < 0 {
+		line("")
+		line(ansiBold+"Warming up, %s left"+ansiReset, (-elapsed).Round(time.Second))
+		b.WriteString(ansiClearToEnd)
+		u.out.Write(b.Bytes())
+		return
+	}
+	line("Elapsed: %s of %s", elapsed.Round(time.Second), u.duration)
+	line("")
+
+	total := atomic.LoadUint64(&u.stats.TotalRequests)
+	successful := atomic.LoadUint64(&u.stats.SuccessfulRequests)
+	failed := atomic.LoadUint64(&u.stats.FailedRequests)
+	dropped := atomic.LoadUint64(&u.stats.DroppedRequests)
+
+	u.stats.mutex.RLock()
+	var current ThroughputSample
+	if n := len(u.stats.throughput); n > 0 {
+		current = u.stats.throughput[n-1]
+	}
+	throughput := u.stats.throughput
+	if len(throughput) > tuiSparkWidth {
+		throughput = throughput[len(throughput)-tuiSparkWidth:]
+	}
+	requests := make([]uint64, len(throughput))
+	errors := make([]uint64, len(throughput))
+	for i, sample := range throughput {
+		requests[i] = sample.Requests
+		errors[i] = sample.Failed
+	}
+	h := u.stats.latencies
+	latencies := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"min", h.Percentile(0)},
+		{"p50", h.Percentile(50)},
+		{"p90", h.Percentile(90)},
+		{"p99", h.Percentile(99)},
+		{"p99.9", h.Percentile(99.9)},
+		{"max", h.Percentile(100)},
+	}
+	codes := make([]int, 0, len(u.stats.StatusCodes))
+	for code := range u.stats.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	statusCodes := make([]string, len(codes))
+	for i, code := range codes {
+		count := u.stats.StatusCodes[code]
+		statusCodes[i] = fmt.Sprintf("%d: %d (%.1f%%)", code, count, percentOf(count, total))
+	}
+	type errorCount struct {
+		err   string
+		count uint64
+	}
+	var topErrors []errorCount
+	for err, count := range u.stats.Errors {
+		topErrors = append(topErrors, errorCount{err, count})
+	}
+	u.stats.mutex.RUnlock()
+
+	sort.Slice(topErrors, func(i, j int) bool {
+		if topErrors[i].count != topErrors[j].count {
+			return topErrors[i].count > topErrors[j].count
+		}
+		return topErrors[i].err < topErrors[j].err
+	})
+	if len(topErrors) > tuiTopErrors {
+		topErrors = topErrors[:tuiTopErrors]
+	}
+
+	line(ansiBold+"%-12s %10s %10s %10s %10s"+ansiReset, "Requests", "Total", "Successful", "Failed", "Dropped")
+	line("%-12s %10d %9.2f%% %9.2f%% %10d", "", total, percentOf(successful, total), percentOf(failed, total), dropped)
+	line("")
+	line(ansiBold+"%-12s"+ansiReset+" %.0f now, %.2f on average", "Req/s", float64(current.Requests), float64(total)/elapsed.Seconds())
+	line("%-12s %s", "", sparkline(requests))
+	line("")
+	var columns, values strings.Builder
+	for _, l := range latencies {
+		fmt.Fprintf(&columns, " %10s", l.name)
+		fmt.Fprintf(&values, " %10s", formatLatency(l.value))
+	}
+	line(ansiBold+"%-12s%s"+ansiReset, "Latency", columns.String())
+	line("%-12s%s", "all", values.String())
+	line("%-12s %10s %10s %10s %10s", "last second", "", formatLatency(time.Duration(current.P50)*time.Microsecond), "", formatLatency(time.Duration(current.P99)*time.Microsecond))
+	line("")
+	line(ansiBold+"%-12s"+ansiReset+" %s", "Status codes", strings.Join(statusCodes, "  "))
+	line("")
+	line(ansiBold+"%-12s"+ansiReset+" %d now", "Errors", current.Failed)
+	if failed > 0 {
+		line("%-12s "+ansiRed+"%s"+ansiReset, "", sparkline(errors))
+	} else {
+		line("%-12s %s", "", sparkline(errors))
+	}
+	for _, e := range topErrors {
+		line("  %8d  %s", e.count, e.err)
+	}
+	if logs := u.logs.lines(); len(logs) > 0 {
+		line("")
+		line(ansiBold + "Log" + ansiReset)
+		for _, l := range logs {
+			line("  %s", l)
+		}
+	}
+	b.WriteString(ansiClearToEnd)
+	u.out.Write(b.Bytes())
+}
+
+// sparkline draws values as bars scaled to the largest of them
+func sparkline(values []uint64) string {
+	var max uint64
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if max > 0 {
+			level = int(v * uint64(len(sparkLevels)-1) / max)
+		}
+		b.WriteRune(sparkLevels[level])
+	}
+	return b.String()
+}
+
+// logTail keeps the last lines written to it, e.g. by the log package while the
+// terminal UI is shown
+type logTail struct {
+	size  int
+	mutex sync.Mutex
+	tail  []string
+}
+
+func (t *logTail) Write(p []byte) (int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		t.tail = append(t.tail, line)
+	}
+	if len(t.tail) > t.size {
+		t.tail = append([]string(nil), t.tail[len(t.tail)-t.size:]...)
+	}
+	return len(p), nil
+}
+
+// lines returns the last lines written
+func (t *logTail) lines() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]string(nil), t.tail...)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{}
+	}{
+		{"empty", "", nil},
+		{"only comments", "# nothing\n---\n", nil},
+		{
+			"scalars",
+			"a: 1\nb: 1.5\nc: true\nd: ~\ne: text\nf: \"quoted # not a comment\"\ng: 'it''s'\nh:\n",
+			map[string]interface{}{"a": int64(1), "b": 1.5, "c": true, "d": nil, "e": "text", "f": "quoted # not a comment", "g": "it's", "h": nil},
+		},
+		{
+			"comments",
+			"# header\nname: load # trailing\nurl: http://host/#anchor\n",
+			map[string]interface{}{"name": "load", "url": "http://host/#anchor"},
+		},
+		{
+			"flow sequence",
+			"letters: [A, 'B, C', \"D\", 3]\nnone: []\n",
+			map[string]interface{}{"letters": []interface{}{"A", "B, C", "D", int64(3)}, "none": []interface{}{}},
+		},
+		{
+			"nested mapping",
+			"phase:\n  rps: 10\n  ramp:\n    from: 1\n",
+			map[string]interface{}{"phase": map[string]interface{}{"rps": int64(10), "ramp": map[string]interface{}{"from": int64(1)}}},
+		},
+		{
+			"sequence of mappings",
+			"phases:\n  - name: warm-up\n    rps: 5\n  - name: peak\n    rps: 50\n",
+			map[string]interface{}{"phases": []interface{}{
+				map[string]interface{}{"name": "warm-up", "rps": int64(5)},
+				map[string]interface{}{"name": "peak", "rps": int64(50)},
+			}},
+		},
+		{
+			"sequence at the key's indentation",
+			"phases:\n- 1\n- 2\nname: x\n",
+			map[string]interface{}{"phases": []interface{}{int64(1), int64(2)}, "name": "x"},
+		},
+		{
+			"block items on the next lines",
+			"-\n  a: 1\n-\n- - x\n  - y\n",
+			[]interface{}{map[string]interface{}{"a": int64(1)}, nil, []interface{}{"x", "y"}},
+		},
+		{
+			"windows line endings",
+			"a: 1\r\nb: two\r\n",
+			map[string]interface{}{"a": int64(1), "b": "two"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := parseYAML([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("Expected %#v, got %#v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"tab indentation", "a:\n\tb: 1\n", "line 2: tabs can't be used for indentation"},
+		{"missing colon", "a: 1\njust text\n", "line 2: expected \"key: value\""},
+		{"duplicate key", "a: 1\na: 2\n", "line 2: duplicate key \"a\""},
+		{"over-indented entry", "a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+		{"over-indented item", "- 1\n  - 2\n", "line 2: expected a sequence item"},
+		{"dedent below the first line", "  a: 1\nb: 2\n", "line 2: unexpected indentation"},
+		{"unterminated flow sequence", "a: [1, 2\n", "line 1: unterminated flow sequence"},
+		{"flow mapping", "a: {b: 1}\n", "line 1: flow mappings are not supported"},
+		{"bad double quotes", "a: \"unterminated\n", "line 1: invalid double-quoted string"},
+		{"bad single quotes", "a: 'unterminated\n", "line 1: invalid single-quoted string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAML([]byte(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
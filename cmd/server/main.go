@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -12,26 +15,90 @@ import (
 )
 
 func main() {
-	// Create a server with default options
+	// Start from the default options
 	options := server.DefaultServerOptions()
+	
+	// Flags override the config file and the environment
+	configPath := flag.String("config", "", "JSON file with server options")
+	port := flag.Int("port", 0, "Port to listen on (default $PORT, else 8080, or 443 with TLS)")
+	adminAddr := flag.String("admin-addr", "", "Address of a separate server for /stats, /debug and /admin, e.g. 127.0.0.1:9090")
+	maxConcurrent := flag.Int64("max-concurrent", options.MaxConcurrentRequests, "Maximum number of concurrent requests")
+	rateLimit := flag.Float64("rate-limit", options.RequestRateLimit, "Requests per second allowed")
+	cacheSize := flag.Int("cache-size", options.CacheSize, "Number of cached name lists")
+	workers := flag.Int("workers", options.Workers, "Workers of the name generator")
+	flag.Parse()
+	
+	if *configPath != "" {
+		if err := options.LoadConfigFile(*configPath); err != nil {
+			slog.Error("Error reading the config file", "error", err)
+			os.Exit(1)
+		}
+	}
+	
+	// Environment variables override the config file
+	if err := options.ApplyEnv(); err != nil {
+		slog.Error("Error reading the configuration", "error", err)
+		os.Exit(1)
+	}
+	
+	// Only the flags given on the command line are applied
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			options.Port = *port
+		case "admin-addr":
+			options.AdminAddr = *adminAddr
+		case "max-concurrent":
+			options.MaxConcurrentRequests = *maxConcurrent
+		case "rate-limit":
+			options.RequestRateLimit = *rateLimit
+		case "cache-size":
+			options.CacheSize = *cacheSize
+		case "workers":
+			options.Workers = *workers
+		}
+	})
+	if err := options.Validate(); err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
 	srv := server.NewServer(options)
 	
+	// Route all logging, including the log package, through the server's JSON logger
+	logger := srv.Logger()
+	slog.SetDefault(logger)
+	
 	// Create a channel to listen for interrupt signals
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	
+	// SIGHUP starts the binary again, which takes over the listening sockets
+	upgrade := make(chan os.Signal, 1)
+	signal.Notify(upgrade, syscall.SIGHUP)
+	
 	// Start the server in a goroutine
 	go func() {
-		if err := srv.Start(); err != nil {
-			log.Fatalf("Error starting server: %v", err)
+		if err := srv.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Error starting server", "error", err)
+			os.Exit(1)
 		}
 	}()
 	
-	log.Println("Server is ready to handle requests")
+	logger.Info("Server is ready to handle requests")
 	
-	// Wait for interrupt signal
-	<-stop
-	log.Println("Shutting down server...")
+	// Wait for interrupt signal; the new instance sends SIGTERM once it serves
+	for waiting := true; waiting; {
+		select {
+		case <-upgrade:
+			logger.Info("Received upgrade signal")
+			if _, err := srv.Upgrade(); err != nil {
+				logger.Error("Error starting the new server instance", "error", err)
+			}
+		case <-stop:
+			waiting = false
+		}
+	}
+	logger.Info("Received shutdown signal")
 	
 	// Create a deadline context for shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -39,6 +106,7 @@ func main() {
 	
 	// Attempt graceful shutdown
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Error during server shutdown: %v", err)
+		logger.Error("Error during server shutdown", "error", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,493 @@
+// Package acme obtains and renews TLS certificates from an ACME certificate
+// authority such as Let's Encrypt (RFC 8555)
+//
+// Domains are validated with the tls-alpn-01 challenge (RFC 8737), which is
+// answered by the TLS listener itself, so no other port has to be opened
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LetsEncryptURL is the directory of the Let's Encrypt production CA
+const LetsEncryptURL = "https://acme-v02.api.letsencrypt.org/directory"
+
+// alpnProto is the ALPN protocol the CA uses to validate a tls-alpn-01 challenge
+const alpnProto = "acme-tls/1"
+
+// idPeAcmeIdentifier is the certificate extension carrying the challenge response
+var idPeAcmeIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+// Defaults of the Manager
+const (
+	defaultRenewBefore  = 30 * 24 * time.Hour
+	defaultRetryBackoff = time.Minute
+	maxRetryBackoff     = time.Hour
+	defaultPollInterval = time.Second
+	orderTimeout        = 2 * time.Minute
+)
+
+// Manager obtains certificates for its domains on demand, keeps them in a
+// cache directory across restarts and renews them before they expire
+type Manager struct {
+	Domains      []string      // Domains certificates are issued for; other names are refused
+	CacheDir     string        // Directory the account key and certificates are stored in
+	DirectoryURL string        // ACME directory of the CA (default LetsEncryptURL)
+	Email        string        // Contact address of the account, for expiry notices
+	RenewBefore  time.Duration // How long before expiry a certificate is renewed (default 30 days)
+	RetryBackoff time.Duration // Wait after a failed order, doubled up to an hour for each further failure (default 1 minute)
+	HTTPClient   *http.Client  // Client for the CA (default http.DefaultClient)
+	Logger       *slog.Logger  // Logs issuance and renewal (default slog.Default())
+
+	mutex      sync.Mutex
+	client     *client
+	certs      map[string]*tls.Certificate // Certificates by domain
+	challenges map[string]*tls.Certificate // tls-alpn-01 responses by domain
+	obtaining  map[string]*orderCall       // Orders in progress by domain
+	failures   map[string]*orderFailure    // Last failed orders by domain
+}
+
+// orderCall is an order in progress, shared by the handshakes waiting for it
+type orderCall struct {
+	done chan struct{} // Closed once the order has completed
+	cert *tls.Certificate
+	err  error
+}
+
+// orderFailure is a failed order of a domain, which holds back the next one
+type orderFailure struct {
+	err      error
+	failures int // Orders that failed in a row
+	retryAt  time.Time
+}
+
+// TLSConfig returns a TLS configuration serving the certificates of the manager
+// and answering its challenges
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: m.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1", alpnProto},
+		MinVersion:     tls.VersionTLS12,
+	}
+}
+
+// GetCertificate returns the certificate for the server name of a handshake,
+// obtaining it from the CA on first use
+// It is meant for tls.Config.GetCertificate
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if name == "" {
+		return nil, errors.New("acme: missing server name")
+	}
+
+	// The CA validates a challenge by connecting with the acme-tls/1 protocol
+	for _, proto := range hello.SupportedProtos {
+		if proto == alpnProto {
+			m.mutex.Lock()
+			cert, ok := m.challenges[name]
+			m.mutex.Unlock()
+			if !ok {
+				return nil, fmt.Errorf("acme: no challenge for %s", name)
+			}
+			return cert, nil
+		}
+	}
+
+	if !m.allowed(name) {
+		return nil, fmt.Errorf("acme: %s is not a configured domain", name)
+	}
+	return m.certificate(name)
+}
+
+// allowed reports whether a certificate may be issued for a domain
+func (m *Manager) allowed(name string) bool {
+	for _, domain := range m.Domains {
+		if strings.EqualFold(domain, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// certificate returns the certificate of a domain from memory or the cache
+// directory, obtaining a new one if there is none or it has expired
+// A certificate that is due for renewal is served while it is renewed
+func (m *Manager) certificate(domain string) (*tls.Certificate, error) {
+	m.mutex.Lock()
+	cert, ok := m.certs[domain]
+	m.mutex.Unlock()
+	if !ok {
+		if loaded, err := m.load(domain); err == nil {
+			cert = loaded
+			m.store(domain, cert)
+		}
+	}
+
+	if cert != nil && time.Now().Before(cert.Leaf.NotAfter) {
+		if time.Until(cert.Leaf.NotAfter) < m.renewBefore() {
+			m.renew(domain)
+		}
+		return cert, nil
+	}
+	return m.obtain(domain)
+}
+
+// renew starts renewing the certificate of a domain in the background, unless
+// it is already being renewed or the last renewal failed too recently
+func (m *Manager) renew(domain string) {
+	if call, started, _ := m.startOrder(domain); started {
+		go m.runOrder(domain, call)
+	}
+}
+
+// obtain orders a certificate for a domain; concurrent calls share one order
+// After a failed order, calls fail right away until its retry backoff has passed
+func (m *Manager) obtain(domain string) (*tls.Certificate, error) {
+	call, started, err := m.startOrder(domain)
+	if err != nil {
+		return nil, err
+	}
+	if started {
+		m.runOrder(domain, call)
+	}
+	<-call.done
+	return call.cert, call.err
+}
+
+// startOrder returns the order in progress for a domain, or a new one that the
+// caller runs, unless the last order failed less than its backoff ago
+func (m *Manager) startOrder(domain string) (call *orderCall, started bool, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if call, busy := m.obtaining[domain]; busy {
+		return call, false, nil
+	}
+	if failure, failed := m.failures[domain]; failed && time.Now().Before(failure.retryAt) {
+		return nil, false, fmt.Errorf("acme: not ordering a certificate for %s again until %s: %w", domain, failure.retryAt.Format(time.RFC3339), failure.err)
+	}
+
+	if m.obtaining == nil {
+		m.obtaining = make(map[string]*orderCall)
+	}
+	call = &orderCall{done: make(chan struct{})}
+	m.obtaining[domain] = call
+	return call, true, nil
+}
+
+// runOrder runs an order started with startOrder and shares its outcome with
+// the callers waiting for it
+// Failed orders back off exponentially, so handshakes don't hammer the CA and
+// run into its rate limits
+func (m *Manager) runOrder(domain string, call *orderCall) {
+	m.logger().Info("Obtaining certificate", "domain", domain)
+	call.cert, call.err = m.order(domain)
+
+	m.mutex.Lock()
+	delete(m.obtaining, domain)
+	var failure orderFailure
+	if call.err != nil {
+		if m.failures == nil {
+			m.failures = make(map[string]*orderFailure)
+		}
+		if previous, ok := m.failures[domain]; ok {
+			failure.failures = previous.failures
+		}
+		failure.err = call.err
+		failure.failures++
+		failure.retryAt = time.Now().Add(m.retryBackoff(failure.failures))
+		m.failures[domain] = &failure
+	} else {
+		delete(m.failures, domain)
+		if m.certs == nil {
+			m.certs = make(map[string]*tls.Certificate)
+		}
+		m.certs[domain] = call.cert
+	}
+	m.mutex.Unlock()
+	close(call.done)
+
+	if call.err != nil {
+		m.logger().Error("Error obtaining certificate", "domain", domain, "error", call.err, "retry_at", failure.retryAt)
+		return
+	}
+	m.logger().Info("Obtained certificate", "domain", domain, "expires", call.cert.Leaf.NotAfter)
+}
+
+// order runs an ACME order for a domain and saves the issued certificate
+func (m *Manager) order(domain string) (*tls.Certificate, error) {
+	c, err := m.acmeClient()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.register(m.Email); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(orderTimeout)
+
+	o, err := c.newOrder(domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, url := range o.Authorizations {
+		if err := m.authorize(c, url, deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	// The certificate gets a key of its own, separate from the account key
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domain},
+		DNSNames: []string{domain},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := c.finalize(o, csr, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data := append(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), chain...)
+	cert, err := parseCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.save(certFile(domain), data); err != nil {
+		m.logger().Warn("Error saving certificate", "domain", domain, "error", err)
+	}
+	return cert, nil
+}
+
+// authorize proves control over the domain of an authorization with tls-alpn-01
+func (m *Manager) authorize(c *client, url string, deadline time.Time) error {
+	authz, err := c.authorization(url)
+	if err != nil {
+		return err
+	}
+	if authz.Status == "valid" {
+		return nil
+	}
+
+	var ch *challenge
+	for i := range authz.Challenges {
+		if authz.Challenges[i].Type == "tls-alpn-01" {
+			ch = &authz.Challenges[i]
+		}
+	}
+	if ch == nil {
+		return fmt.Errorf("acme: the CA offers no tls-alpn-01 challenge for %s", authz.Identifier.Value)
+	}
+
+	domain := authz.Identifier.Value
+	cert, err := challengeCertificate(domain, c.keyAuthorization(ch.Token))
+	if err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	if m.challenges == nil {
+		m.challenges = make(map[string]*tls.Certificate)
+	}
+	m.challenges[domain] = cert
+	m.mutex.Unlock()
+	defer func() {
+		m.mutex.Lock()
+		delete(m.challenges, domain)
+		m.mutex.Unlock()
+	}()
+
+	if err := c.accept(*ch); err != nil {
+		return err
+	}
+	return c.waitAuthorization(url, deadline)
+}
+
+// challengeCertificate creates the self-signed certificate answering a
+// tls-alpn-01 challenge, which carries the digest of the key authorization
+func challengeCertificate(domain, keyAuthorization string) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(keyAuthorization))
+	value, err := asn1.Marshal(digest[:])
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: domain},
+		DNSNames:        []string{domain},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(24 * time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: idPeAcmeIdentifier, Critical: true, Value: value}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// acmeClient returns the client of the account, loading or creating its key
+func (m *Manager) acmeClient() (*client, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.client != nil {
+		return m.client, nil
+	}
+
+	key, err := m.accountKey()
+	if err != nil {
+		return nil, err
+	}
+	httpClient := m.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	directoryURL := m.DirectoryURL
+	if directoryURL == "" {
+		directoryURL = LetsEncryptURL
+	}
+	m.client = &client{directoryURL: directoryURL, http: httpClient, key: key, pollInterval: defaultPollInterval}
+	return m.client, nil
+}
+
+// accountKeyFile is the name of the account key in the cache directory
+const accountKeyFile = "acme_account.key"
+
+// accountKey loads the account key from the cache directory, or creates one
+func (m *Manager) accountKey() (*ecdsa.PrivateKey, error) {
+	if data, err := m.read(accountKeyFile); err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("acme: invalid account key")
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.save(accountKeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// certFile is the name of the certificate of a domain in the cache directory
+func certFile(domain string) string {
+	return domain + ".pem"
+}
+
+// load reads the certificate of a domain from the cache directory
+func (m *Manager) load(domain string) (*tls.Certificate, error) {
+	data, err := m.read(certFile(domain))
+	if err != nil {
+		return nil, err
+	}
+	return parseCertificate(data)
+}
+
+// parseCertificate parses a PEM private key followed by its certificate chain
+func parseCertificate(data []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+	}
+	return &cert, nil
+}
+
+// store keeps the certificate of a domain in memory
+func (m *Manager) store(domain string, cert *tls.Certificate) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.certs == nil {
+		m.certs = make(map[string]*tls.Certificate)
+	}
+	m.certs[domain] = cert
+}
+
+// read reads a file from the cache directory
+func (m *Manager) read(name string) ([]byte, error) {
+	if m.CacheDir == "" {
+		return nil, os.ErrNotExist
+	}
+	return os.ReadFile(filepath.Join(m.CacheDir, name))
+}
+
+// save writes a file holding private keys to the cache directory
+// Without a cache directory, certificates only live in memory
+func (m *Manager) save(name string, data []byte) error {
+	if m.CacheDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(m.CacheDir, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.CacheDir, name), data, 0o600)
+}
+
+// renewBefore returns how long before expiry certificates are renewed
+func (m *Manager) renewBefore() time.Duration {
+	if m.RenewBefore > 0 {
+		return m.RenewBefore
+	}
+	return defaultRenewBefore
+}
+
+// retryBackoff returns how long to wait for the next order after a number of
+// failed ones in a row
+func (m *Manager) retryBackoff(failures int) time.Duration {
+	backoff := m.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	for i := 1; i < failures && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxRetryBackoff)
+}
+
+// logger returns the logger of the manager
+func (m *Manager) logger() *slog.Logger {
+	if m.Logger != nil {
+		return m.Logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,516 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCA is a minimal ACME server that validates tls-alpn-01 challenges by
+// asking the manager for the challenge certificate directly
+type fakeCA struct {
+	t        *testing.T
+	server   *httptest.Server
+	manager  *Manager
+	key      *ecdsa.PrivateKey
+	cert     *x509.Certificate
+	validity time.Duration
+
+	mutex      sync.Mutex
+	domain     string
+	token      string
+	thumbprint string
+	valid      bool
+	issued     []byte
+	orders     int
+	failing    bool // Orders are rejected
+}
+
+// newFakeCA starts a fake CA issuing certificates valid for validity
+func newFakeCA(t *testing.T, validity time.Duration) *fakeCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Fake CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	ca := &fakeCA{t: t, key: key, cert: cert, validity: validity}
+	ca.server = httptest.NewServer(http.HandlerFunc(ca.serveHTTP))
+	t.Cleanup(ca.server.Close)
+	return ca
+}
+
+// jws is a flattened JWS as sent by the client
+type jws struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// decode checks the signature of a request and returns its protected header and payload
+func (ca *fakeCA) decode(r *http.Request) (map[string]interface{}, []byte) {
+	var body jws
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		ca.t.Errorf("Invalid JWS: %v", err)
+		return nil, nil
+	}
+	header, _ := base64.RawURLEncoding.DecodeString(body.Protected)
+	payload, _ := base64.RawURLEncoding.DecodeString(body.Payload)
+	var protected map[string]interface{}
+	json.Unmarshal(header, &protected)
+	if protected["url"] != "http://"+r.Host+r.URL.Path {
+		ca.t.Errorf("Expected url %q in the protected header, got %v", "http://"+r.Host+r.URL.Path, protected["url"])
+	}
+
+	// Registered accounts are identified by their URL, new ones by their key
+	if r.URL.Path != "/new-account" && protected["kid"] != ca.server.URL+"/account/1" {
+		ca.t.Errorf("Expected the account URL as kid on %s, got %v", r.URL.Path, protected["kid"])
+	}
+	if r.URL.Path == "/new-account" {
+		k, _ := protected["jwk"].(map[string]interface{})
+		x, _ := base64.RawURLEncoding.DecodeString(fmt.Sprint(k["x"]))
+		y, _ := base64.RawURLEncoding.DecodeString(fmt.Sprint(k["y"]))
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		signature, _ := base64.RawURLEncoding.DecodeString(body.Signature)
+		digest := sha256.Sum256([]byte(body.Protected + "." + body.Payload))
+		if len(signature) != 64 || !ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+			ca.t.Error("Invalid JWS signature")
+		}
+		ca.mutex.Lock()
+		ca.thumbprint = thumbprint(key)
+		ca.mutex.Unlock()
+	}
+	return protected, payload
+}
+
+func (ca *fakeCA) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	url := ca.server.URL
+	w.Header().Set("Replay-Nonce", fmt.Sprint(time.Now().UnixNano()))
+	if r.URL.Path == "/directory" {
+		json.NewEncoder(w).Encode(directory{NewNonce: url + "/new-nonce", NewAccount: url + "/new-account", NewOrder: url + "/new-order"})
+		return
+	}
+	if r.URL.Path == "/new-nonce" {
+		return
+	}
+
+	_, payload := ca.decode(r)
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	switch r.URL.Path {
+	case "/new-account":
+		w.Header().Set("Location", url+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status":"valid"}`))
+	case "/new-order":
+		ca.orders++
+		if ca.failing {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"type":"urn:ietf:params:acme:error:rateLimited","detail":"too many orders"}`))
+			return
+		}
+		var request struct {
+			Identifiers []identifier `json:"identifiers"`
+		}
+		json.Unmarshal(payload, &request)
+		ca.domain = request.Identifiers[0].Value
+		ca.token = fmt.Sprintf("token%d", ca.orders)
+		ca.valid = false
+		ca.issued = nil
+		w.Header().Set("Location", url+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(ca.order())
+	case "/authz/1":
+		status := "pending"
+		if ca.valid {
+			status = "valid"
+		}
+		json.NewEncoder(w).Encode(authorization{
+			Status:     status,
+			Identifier: identifier{Type: "dns", Value: ca.domain},
+			Challenges: []challenge{
+				{Type: "http-01", URL: url + "/challenge/http", Token: ca.token},
+				{Type: "tls-alpn-01", URL: url + "/challenge/1", Token: ca.token},
+			},
+		})
+	case "/challenge/1":
+		ca.mutex.Unlock()
+		err := ca.validate()
+		ca.mutex.Lock()
+		if err != nil {
+			ca.t.Errorf("Challenge failed: %v", err)
+		}
+		ca.valid = err == nil
+		json.NewEncoder(w).Encode(challenge{Type: "tls-alpn-01", Status: "processing"})
+	case "/finalize/1":
+		var request struct {
+			CSR string `json:"csr"`
+		}
+		json.Unmarshal(payload, &request)
+		der, _ := base64.RawURLEncoding.DecodeString(request.CSR)
+		ca.issue(der)
+		json.NewEncoder(w).Encode(ca.order())
+	case "/order/1":
+		json.NewEncoder(w).Encode(ca.order())
+	case "/cert/1":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(ca.issued)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"type":"urn:ietf:params:acme:error:malformed","detail":"not found"}`))
+	}
+}
+
+// order returns the state of the current order
+func (ca *fakeCA) order() order {
+	o := order{Status: "pending", Authorizations: []string{ca.server.URL + "/authz/1"}, Finalize: ca.server.URL + "/finalize/1"}
+	if ca.valid {
+		o.Status = "ready"
+	}
+	if ca.issued != nil {
+		o.Status = "valid"
+		o.Certificate = ca.server.URL + "/cert/1"
+	}
+	return o
+}
+
+// validate connects to the manager like a CA and checks the challenge certificate
+func (ca *fakeCA) validate() error {
+	ca.mutex.Lock()
+	domain, keyAuthorization := ca.domain, ca.token+"."+ca.thumbprint
+	ca.mutex.Unlock()
+
+	cert, err := ca.manager.GetCertificate(&tls.ClientHelloInfo{ServerName: domain, SupportedProtos: []string{alpnProto}})
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	if len(leaf.DNSNames) != 1 || leaf.DNSNames[0] != domain {
+		return fmt.Errorf("expected SAN %s, got %v", domain, leaf.DNSNames)
+	}
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(idPeAcmeIdentifier) {
+			var value []byte
+			if _, err := asn1.Unmarshal(ext.Value, &value); err != nil {
+				return err
+			}
+			digest := sha256.Sum256([]byte(keyAuthorization))
+			if !ext.Critical || !bytes.Equal(value, digest[:]) {
+				return fmt.Errorf("invalid acmeIdentifier extension")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("missing acmeIdentifier extension")
+}
+
+// issue signs a certificate for a CSR
+func (ca *fakeCA) issue(der []byte) {
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		ca.t.Errorf("Invalid CSR: %v", err)
+		return
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(ca.orders + 1)),
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(ca.validity),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, ca.cert, csr.PublicKey, ca.key)
+	if err != nil {
+		ca.t.Errorf("Error issuing certificate: %v", err)
+		return
+	}
+	ca.issued = append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})...)
+}
+
+// orderCount returns the number of orders placed
+func (ca *fakeCA) orderCount() int {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+	return ca.orders
+}
+
+// setFailing makes the CA reject or accept orders
+func (ca *fakeCA) setFailing(failing bool) {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+	ca.failing = failing
+}
+
+// newTestManager returns a manager for example.com using a fake CA
+func newTestManager(t *testing.T, ca *fakeCA, cacheDir string) *Manager {
+	m := &Manager{
+		Domains:      []string{"example.com"},
+		CacheDir:     cacheDir,
+		DirectoryURL: ca.server.URL + "/directory",
+		Email:        "admin@example.com",
+	}
+	ca.manager = m
+	return m
+}
+
+func TestObtainCertificate(t *testing.T) {
+	ca := newFakeCA(t, 90*24*time.Hour)
+	dir := t.TempDir()
+	m := newTestManager(t, ca, dir)
+
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "Example.com."})
+	if err != nil {
+		t.Fatalf("Error obtaining certificate: %v", err)
+	}
+	if cert.Leaf.DNSNames[0] != "example.com" || cert.Leaf.Issuer.CommonName != "Fake CA" {
+		t.Errorf("Unexpected certificate for %v issued by %s", cert.Leaf.DNSNames, cert.Leaf.Issuer.CommonName)
+	}
+	if len(cert.Certificate) != 2 {
+		t.Errorf("Expected the chain to include the CA, got %d certificates", len(cert.Certificate))
+	}
+
+	// Later handshakes are served from memory
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if ca.orderCount() != 1 {
+		t.Errorf("Expected 1 order, got %d", ca.orderCount())
+	}
+
+	// The certificate and the account key are cached with restricted permissions
+	for _, name := range []string{"example.com.pem", accountKeyFile} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Expected %s in the cache directory: %v", name, err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("Expected %s to have mode 0600, got %v", name, info.Mode().Perm())
+		}
+	}
+
+	// No challenge certificate is left once the order completes
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com", SupportedProtos: []string{alpnProto}}); err == nil {
+		t.Error("Expected no challenge certificate after the order")
+	}
+}
+
+func TestCertificateFromCache(t *testing.T) {
+	ca := newFakeCA(t, 90*24*time.Hour)
+	dir := t.TempDir()
+	if _, err := newTestManager(t, ca, dir).GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new manager, as after a restart, uses the cached certificate
+	m := newTestManager(t, ca, dir)
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if ca.orderCount() != 1 {
+		t.Errorf("Expected the cached certificate to be used, got %d orders", ca.orderCount())
+	}
+}
+
+func TestRenewCertificate(t *testing.T) {
+	// Certificates valid for a day are due for renewal right away
+	ca := newFakeCA(t, 24*time.Hour)
+	m := newTestManager(t, ca, t.TempDir())
+
+	first, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old certificate is served while the new one is obtained
+	served, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if served != first {
+		t.Error("Expected the current certificate to be served during renewal")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m.mutex.Lock()
+		renewed := m.certs["example.com"] != first && m.obtaining["example.com"] == nil
+		m.mutex.Unlock()
+		if renewed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Certificate was not renewed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ca.orderCount() < 2 {
+		t.Errorf("Expected a second order, got %d", ca.orderCount())
+	}
+}
+
+func TestConcurrentHandshakes(t *testing.T) {
+	ca := newFakeCA(t, 90*24*time.Hour)
+	m := newTestManager(t, ca, t.TempDir())
+
+	// Handshakes arriving together wait for a single order
+	var wg sync.WaitGroup
+	certs := make([]*tls.Certificate, 10)
+	for i := range certs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			certs[i], _ = m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+		}(i)
+	}
+	wg.Wait()
+	for _, cert := range certs {
+		if cert == nil || cert != certs[0] {
+			t.Fatal("Expected every handshake to get the same certificate")
+		}
+	}
+	if ca.orderCount() != 1 {
+		t.Errorf("Expected 1 order, got %d", ca.orderCount())
+	}
+}
+
+func TestFailedOrderBackoff(t *testing.T) {
+	ca := newFakeCA(t, 90*24*time.Hour)
+	ca.setFailing(true)
+	m := newTestManager(t, ca, t.TempDir())
+	m.RetryBackoff = 200 * time.Millisecond
+
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"}); err == nil {
+		t.Fatal("Expected the order to fail")
+	}
+
+	// Handshakes during the backoff fail without placing another order
+	for i := 0; i < 5; i++ {
+		_, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+		if err == nil || !strings.Contains(err.Error(), "not ordering a certificate for example.com again") || !strings.Contains(err.Error(), "too many orders") {
+			t.Errorf("Expected the backoff error, got %v", err)
+		}
+	}
+	if ca.orderCount() != 1 {
+		t.Errorf("Expected 1 order during the backoff, got %d", ca.orderCount())
+	}
+
+	// A second failure doubles the backoff
+	time.Sleep(m.RetryBackoff)
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"}); err == nil || ca.orderCount() != 2 {
+		t.Fatalf("Expected a second failed order, got %v and %d orders", err, ca.orderCount())
+	}
+	m.mutex.Lock()
+	wait := time.Until(m.failures["example.com"].retryAt)
+	m.mutex.Unlock()
+	if wait <= m.RetryBackoff || wait > 2*m.RetryBackoff {
+		t.Errorf("Expected a backoff of %s, got %s", 2*m.RetryBackoff, wait)
+	}
+
+	// Once the backoff has passed, an order is placed again
+	ca.setFailing(false)
+	time.Sleep(wait)
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"}); err != nil {
+		t.Fatalf("Expected the certificate after the backoff, got %v", err)
+	}
+	m.mutex.Lock()
+	_, failed := m.failures["example.com"]
+	m.mutex.Unlock()
+	if failed || ca.orderCount() != 3 {
+		t.Errorf("Expected the failure to be cleared after 3 orders, got %v and %d orders", failed, ca.orderCount())
+	}
+}
+
+func TestFailedRenewalBackoff(t *testing.T) {
+	// Certificates valid for a day are due for renewal right away
+	ca := newFakeCA(t, 24*time.Hour)
+	m := newTestManager(t, ca, t.TempDir())
+	m.RetryBackoff = time.Hour
+	first, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed renewal isn't retried by every handshake, which keep getting
+	// the current certificate
+	ca.setFailing(true)
+	for i := 0; i < 20; i++ {
+		cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+		if err != nil || cert != first {
+			t.Fatalf("Expected the current certificate, got %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if ca.orderCount() != 2 {
+		t.Errorf("Expected a single renewal order, got %d", ca.orderCount()-1)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	m := &Manager{}
+	for failures, expected := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 4: 8 * time.Minute, 7: time.Hour, 100: time.Hour} {
+		if backoff := m.retryBackoff(failures); backoff != expected {
+			t.Errorf("Expected a backoff of %s after %d failures, got %s", expected, failures, backoff)
+		}
+	}
+}
+
+func TestUnknownDomain(t *testing.T) {
+	ca := newFakeCA(t, 90*24*time.Hour)
+	m := newTestManager(t, ca, "")
+
+	for _, name := range []string{"other.com", ""} {
+		_, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: name})
+		if err == nil {
+			t.Errorf("Expected an error for server name %q", name)
+		}
+	}
+	if ca.orderCount() != 0 {
+		t.Errorf("Expected no orders, got %d", ca.orderCount())
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	config := (&Manager{}).TLSConfig()
+	if config.GetCertificate == nil {
+		t.Fatal("Expected GetCertificate to be set")
+	}
+	if !strings.Contains(strings.Join(config.NextProtos, ","), alpnProto) {
+		t.Errorf("Expected %s in NextProtos, got %v", alpnProto, config.NextProtos)
+	}
+}
@@ -0,0 +1,385 @@
+package acme
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// joseContentType is the media type of signed ACME requests
+const joseContentType = "application/jose+json"
+
+// errBadNonce is the error type of a request signed with a stale nonce, which is retried
+const errBadNonce = "urn:ietf:params:acme:error:badNonce"
+
+// directory lists the endpoints of an ACME server
+type directory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+// Problem is an error reported by the ACME server
+type Problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+// Error returns the type and detail of the problem
+func (p *Problem) Error() string {
+	return fmt.Sprintf("acme: %s: %s", p.Type, p.Detail)
+}
+
+// order is a request for a certificate
+type order struct {
+	Status         string   `json:"status"`
+	Authorizations []string `json:"authorizations"`
+	Finalize       string   `json:"finalize"`
+	Certificate    string   `json:"certificate"`
+	URL            string   `json:"-"` // From the Location header
+}
+
+// authorization is the proof of control over a domain that an order needs
+type authorization struct {
+	Status     string      `json:"status"`
+	Identifier identifier  `json:"identifier"`
+	Challenges []challenge `json:"challenges"`
+}
+
+// identifier is a domain name in an order
+type identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// challenge is a way of proving control over a domain
+type challenge struct {
+	Type   string   `json:"type"`
+	URL    string   `json:"url"`
+	Token  string   `json:"token"`
+	Status string   `json:"status"`
+	Error  *Problem `json:"error,omitempty"`
+}
+
+// client sends requests signed with the account key to an ACME server
+type client struct {
+	directoryURL string
+	http         *http.Client
+	key          *ecdsa.PrivateKey // Account key, which must be P-256
+	pollInterval time.Duration
+
+	mutex  sync.Mutex
+	dir    *directory
+	kid    string // Account URL, set once the account is registered
+	nonces []string
+}
+
+// discover fetches the directory, once
+func (c *client) discover() (*directory, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.dir != nil {
+		return c.dir, nil
+	}
+
+	resp, err := c.http.Get(c.directoryURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("acme: directory returned %s", resp.Status)
+	}
+	var dir directory
+	if err := json.NewDecoder(resp.Body).Decode(&dir); err != nil {
+		return nil, fmt.Errorf("acme: invalid directory: %w", err)
+	}
+	c.dir = &dir
+	return c.dir, nil
+}
+
+// nonce returns a fresh nonce, from an earlier response or the newNonce endpoint
+func (c *client) nonce() (string, error) {
+	c.mutex.Lock()
+	if n := len(c.nonces); n > 0 {
+		nonce := c.nonces[n-1]
+		c.nonces = c.nonces[:n-1]
+		c.mutex.Unlock()
+		return nonce, nil
+	}
+	c.mutex.Unlock()
+
+	dir, err := c.discover()
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.http.Head(dir.NewNonce)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	nonce := resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", errors.New("acme: server sent no nonce")
+	}
+	return nonce, nil
+}
+
+// saveNonce keeps the nonce of a response for the next request
+func (c *client) saveNonce(resp *http.Response) {
+	if nonce := resp.Header.Get("Replay-Nonce"); nonce != "" {
+		c.mutex.Lock()
+		c.nonces = append(c.nonces, nonce)
+		c.mutex.Unlock()
+	}
+}
+
+// post sends a signed request; a nil payload makes it a POST-as-GET
+// It returns the headers and the body of the response, which is also decoded
+// into result if that is not nil
+func (c *client) post(url string, payload, result interface{}) (http.Header, []byte, error) {
+	// A stale nonce is answered with badNonce and a fresh nonce to retry with
+	for attempt := 0; ; attempt++ {
+		resp, err := c.postOnce(url, payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if resp.StatusCode >= 400 {
+			problem := &Problem{Status: resp.StatusCode}
+			json.Unmarshal(body, problem)
+			if problem.Type == errBadNonce && attempt == 0 {
+				continue
+			}
+			return nil, nil, problem
+		}
+		if result != nil {
+			if err := json.Unmarshal(body, result); err != nil {
+				return nil, nil, fmt.Errorf("acme: invalid response from %s: %w", url, err)
+			}
+		}
+		return resp.Header, body, nil
+	}
+}
+
+// postOnce signs and sends a request
+func (c *client) postOnce(url string, payload interface{}) (*http.Response, error) {
+	nonce, err := c.nonce()
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.sign(url, nonce, payload)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Post(url, joseContentType, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	c.saveNonce(resp)
+	return resp, nil
+}
+
+// sign encodes a request as a flattened JWS signed with ES256
+// New accounts are identified by their public key, registered ones by their URL
+func (c *client) sign(url, nonce string, payload interface{}) ([]byte, error) {
+	protected := map[string]interface{}{"alg": "ES256", "nonce": nonce, "url": url}
+	c.mutex.Lock()
+	if c.kid != "" {
+		protected["kid"] = c.kid
+	} else {
+		protected["jwk"] = jwk(&c.key.PublicKey)
+	}
+	c.mutex.Unlock()
+
+	header, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+	var encodedPayload string
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		encodedPayload = base64.RawURLEncoding.EncodeToString(data)
+	}
+	encodedHeader := base64.RawURLEncoding.EncodeToString(header)
+
+	digest := sha256.Sum256([]byte(encodedHeader + "." + encodedPayload))
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return json.Marshal(map[string]string{
+		"protected": encodedHeader,
+		"payload":   encodedPayload,
+		"signature": base64.RawURLEncoding.EncodeToString(signature),
+	})
+}
+
+// register creates the account, or looks it up if the key is already registered
+func (c *client) register(email string) error {
+	c.mutex.Lock()
+	registered := c.kid != ""
+	c.mutex.Unlock()
+	if registered {
+		return nil
+	}
+
+	dir, err := c.discover()
+	if err != nil {
+		return err
+	}
+	request := map[string]interface{}{"termsOfServiceAgreed": true}
+	if email != "" {
+		request["contact"] = []string{"mailto:" + email}
+	}
+	header, _, err := c.post(dir.NewAccount, request, nil)
+	if err != nil {
+		return err
+	}
+	kid := header.Get("Location")
+	if kid == "" {
+		return errors.New("acme: account has no URL")
+	}
+	c.mutex.Lock()
+	c.kid = kid
+	c.mutex.Unlock()
+	return nil
+}
+
+// newOrder orders a certificate for a domain
+func (c *client) newOrder(domain string) (*order, error) {
+	dir, err := c.discover()
+	if err != nil {
+		return nil, err
+	}
+	var o order
+	header, _, err := c.post(dir.NewOrder, map[string]interface{}{
+		"identifiers": []identifier{{Type: "dns", Value: domain}},
+	}, &o)
+	if err != nil {
+		return nil, err
+	}
+	o.URL = header.Get("Location")
+	return &o, nil
+}
+
+// authorization fetches an authorization
+func (c *client) authorization(url string) (*authorization, error) {
+	var authz authorization
+	_, _, err := c.post(url, nil, &authz)
+	return &authz, err
+}
+
+// accept tells the server a challenge is ready to be validated
+func (c *client) accept(ch challenge) error {
+	_, _, err := c.post(ch.URL, struct{}{}, nil)
+	return err
+}
+
+// waitAuthorization polls an authorization until it is valid or has failed
+func (c *client) waitAuthorization(url string, deadline time.Time) error {
+	for {
+		authz, err := c.authorization(url)
+		if err != nil {
+			return err
+		}
+		switch authz.Status {
+		case "valid":
+			return nil
+		case "pending", "processing":
+		default:
+			for _, ch := range authz.Challenges {
+				if ch.Error != nil {
+					return ch.Error
+				}
+			}
+			return fmt.Errorf("acme: authorization for %s is %s", authz.Identifier.Value, authz.Status)
+		}
+		if err := c.sleep(deadline); err != nil {
+			return err
+		}
+	}
+}
+
+// finalize submits the certificate signing request of an order and waits for
+// the certificate to be issued, returning its PEM chain
+func (c *client) finalize(o *order, csr []byte, deadline time.Time) ([]byte, error) {
+	if _, _, err := c.post(o.Finalize, map[string]string{"csr": base64.RawURLEncoding.EncodeToString(csr)}, o); err != nil {
+		return nil, err
+	}
+	for o.Status != "valid" {
+		if o.Status == "invalid" {
+			return nil, errors.New("acme: order is invalid")
+		}
+		if err := c.sleep(deadline); err != nil {
+			return nil, err
+		}
+		if _, _, err := c.post(o.URL, nil, o); err != nil {
+			return nil, err
+		}
+	}
+
+	_, chain, err := c.post(o.Certificate, nil, nil)
+	return chain, err
+}
+
+// sleep waits before polling again, failing if the deadline would pass
+func (c *client) sleep(deadline time.Time) error {
+	if time.Now().Add(c.pollInterval).After(deadline) {
+		return errors.New("acme: timed out waiting for the server")
+	}
+	time.Sleep(c.pollInterval)
+	return nil
+}
+
+// jwk returns the JSON Web Key of an ECDSA P-256 public key
+func jwk(key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"crv": "P-256",
+		"kty": "EC",
+		"x":   base64.RawURLEncoding.EncodeToString(padded(key.X)),
+		"y":   base64.RawURLEncoding.EncodeToString(padded(key.Y)),
+	}
+}
+
+// padded returns a P-256 coordinate as 32 bytes
+func padded(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, 32))
+}
+
+// thumbprint returns the RFC 7638 thumbprint of a public key
+// The members are hashed in lexicographic order without whitespace
+func thumbprint(key *ecdsa.PublicKey) string {
+	k := jwk(key)
+	data := fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s","y":"%s"}`, k["crv"], k["kty"], k["x"], k["y"])
+	sum := sha256.Sum256([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// keyAuthorization returns the key authorization of a challenge token
+func (c *client) keyAuthorization(token string) string {
+	return token + "." + thumbprint(&c.key.PublicKey)
+}
@@ -0,0 +1,102 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestClient returns a client with a fresh account key
+func newTestClient(t *testing.T, url string) *client {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &client{directoryURL: url + "/directory", http: http.DefaultClient, key: key}
+}
+
+func TestBadNonceRetry(t *testing.T) {
+	var nonces []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/directory":
+			json.NewEncoder(w).Encode(directory{NewNonce: "http://" + r.Host + "/new-nonce"})
+		case "/new-nonce":
+			w.Header().Set("Replay-Nonce", "stale")
+		default:
+			var body jws
+			json.NewDecoder(r.Body).Decode(&body)
+			header, _ := base64.RawURLEncoding.DecodeString(body.Protected)
+			var protected map[string]interface{}
+			json.Unmarshal(header, &protected)
+			nonces = append(nonces, protected["nonce"].(string))
+
+			w.Header().Set("Replay-Nonce", "fresh")
+			if protected["nonce"] == "stale" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"type":"` + errBadNonce + `","detail":"stale nonce"}`))
+				return
+			}
+			w.Write([]byte(`{"status":"valid"}`))
+		}
+	}))
+	defer server.Close()
+
+	c := newTestClient(t, server.URL)
+	var result struct{ Status string }
+	if _, _, err := c.post(server.URL+"/order", nil, &result); err != nil {
+		t.Fatalf("Expected the request to be retried with a fresh nonce: %v", err)
+	}
+	if strings.Join(nonces, ",") != "stale,fresh" || result.Status != "valid" {
+		t.Errorf("Expected nonces stale,fresh and status valid, got %v and %q", nonces, result.Status)
+	}
+}
+
+func TestProblem(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/directory" {
+			json.NewEncoder(w).Encode(directory{NewNonce: "http://" + r.Host + "/new-nonce"})
+			return
+		}
+		w.Header().Set("Replay-Nonce", "nonce")
+		if r.URL.Path == "/order" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"type":"urn:ietf:params:acme:error:unauthorized","detail":"no"}`))
+		}
+	}))
+	defer server.Close()
+
+	_, _, err := newTestClient(t, server.URL).post(server.URL+"/order", nil, nil)
+	var problem *Problem
+	if !errors.As(err, &problem) {
+		t.Fatalf("Expected a Problem, got %v", err)
+	}
+	if problem.Status != http.StatusForbidden || problem.Type != "urn:ietf:params:acme:error:unauthorized" {
+		t.Errorf("Unexpected problem %+v", problem)
+	}
+}
+
+func TestKeyAuthorization(t *testing.T) {
+	c := newTestClient(t, "")
+	got := c.keyAuthorization("token")
+	token, print, ok := strings.Cut(got, ".")
+	if !ok || token != "token" {
+		t.Fatalf("Expected token.thumbprint, got %q", got)
+	}
+
+	// A SHA-256 thumbprint is 32 bytes, unpadded base64url
+	decoded, err := base64.RawURLEncoding.DecodeString(print)
+	if err != nil || len(decoded) != 32 {
+		t.Errorf("Expected a 32 byte base64url thumbprint, got %q", print)
+	}
+	if thumbprint(&c.key.PublicKey) != print {
+		t.Error("Expected the thumbprint to be stable")
+	}
+}
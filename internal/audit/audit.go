@@ -0,0 +1,213 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// genesisHash is the previous hash of the first entry
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// maxLineSize bounds a line of the log file when it is read back
+const maxLineSize = 1 << 20
+
+// ErrClosed is returned when appending to a closed log
+var ErrClosed = errors.New("audit: log is closed")
+
+// Entry is a recorded action
+// Every entry carries the hash of the one before it, and its own hash covers
+// that and all its fields, so changing or removing an entry breaks the chain
+type Entry struct {
+	Seq        uint64            `json:"seq"`
+	Time       time.Time         `json:"time"`
+	Actor      string            `json:"actor"` // Who acted, e.g. "admin token" or "jwt:alice"
+	RemoteAddr string            `json:"remote_addr,omitempty"`
+	RequestID  string            `json:"request_id,omitempty"`
+	Action     string            `json:"action"` // What was done, e.g. "cache.flush"
+	Target     string            `json:"target,omitempty"`
+	Details    map[string]string `json:"details,omitempty"` // What changed
+	PrevHash   string            `json:"prev_hash"`
+	Hash       string            `json:"hash"`
+}
+
+// computeHash returns the hash of an entry, which covers every field but Hash
+func computeHash(entry Entry) string {
+	entry.Hash = ""
+	data, _ := json.Marshal(entry) // Can't fail for the field types of Entry
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Log is an append-only, hash-chained audit log
+// Entries are appended to a file of JSON lines, if there is one, and the most
+// recent ones are also kept in memory
+type Log struct {
+	mu       sync.Mutex
+	file     *os.File
+	recent   []Entry // Ring buffer of the last keep entries
+	next     int     // Position of the next entry once recent is full
+	keep     int
+	seq      uint64
+	head     string // Hash of the last entry
+	brokenAt uint64 // Sequence number where the chain was found broken, 0 if intact
+	closed   bool
+}
+
+// Open opens the audit log at path, creating it if needed, and checks the
+// chain of the entries already in it
+// An empty path keeps the log in memory only. keep is the number of recent
+// entries kept in memory. A broken chain doesn't stop the log from being used,
+// but is reported by Intact
+func Open(path string, keep int) (*Log, error) {
+	log := &Log{keep: keep, head: genesisHash}
+	if path == "" {
+		return log, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		// A line that can't be read, e.g. cut short by a crash, breaks the chain
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			if log.brokenAt == 0 {
+				log.brokenAt = log.seq + 1
+			}
+			continue
+		}
+		if log.brokenAt == 0 && (entry.Seq != log.seq+1 || entry.PrevHash != log.head || computeHash(entry) != entry.Hash) {
+			log.brokenAt = log.seq + 1
+		}
+		log.remember(entry)
+		log.seq, log.head = entry.Seq, entry.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	log.file = file
+	return log, nil
+}
+
+// Append records an action, filling in its sequence number, time and hashes
+func (l *Log) Append(entry Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return Entry{}, ErrClosed
+	}
+	entry.Seq = l.seq + 1
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	entry.PrevHash = l.head
+	entry.Hash = computeHash(entry)
+
+	if l.file != nil {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return Entry{}, err
+		}
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			return Entry{}, err
+		}
+		// The entry must survive a crash right after the action
+		if err := l.file.Sync(); err != nil {
+			return Entry{}, err
+		}
+	}
+	l.remember(entry)
+	l.seq, l.head = entry.Seq, entry.Hash
+	return entry, nil
+}
+
+// remember keeps an entry in memory, replacing the oldest once keep are kept
+func (l *Log) remember(entry Entry) {
+	if l.keep <= 0 {
+		return
+	}
+	if len(l.recent) < l.keep {
+		l.recent = append(l.recent, entry)
+		return
+	}
+	l.recent[l.next] = entry
+	l.next = (l.next + 1) % l.keep
+}
+
+// Recent returns up to limit of the latest entries, newest first
+// A limit of 0 or less returns all entries kept in memory
+func (l *Log) Recent(limit int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit <= 0 || limit > len(l.recent) {
+		limit = len(l.recent)
+	}
+	entries := make([]Entry, 0, limit)
+	for i := 0; i < limit; i++ {
+		entries = append(entries, l.recent[(l.next+len(l.recent)-1-i)%len(l.recent)])
+	}
+	return entries
+}
+
+// Head returns the hash of the last entry; recording it elsewhere lets a
+// truncated log be detected too
+func (l *Log) Head() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.head
+}
+
+// Intact reports whether the chain of the entries read from the file was
+// unbroken, and otherwise the sequence number of the first bad entry
+func (l *Log) Intact() (bool, uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.brokenAt == 0, l.brokenAt
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Verify checks the chain of the audit log file at path, returning an error
+// that names the first entry that was changed, removed or inserted
+func Verify(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	log, err := Open(path, 0)
+	if err != nil {
+		return err
+	}
+	defer log.Close()
+	if intact, brokenAt := log.Intact(); !intact {
+		return fmt.Errorf("audit: chain broken at entry %d", brokenAt)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAppendChainsEntries(t *testing.T) {
+	log, err := Open("", 10)
+	if err != nil {
+		t.Fatalf("Failed to open in-memory log: %v", err)
+	}
+	defer log.Close()
+
+	if log.Head() != genesisHash {
+		t.Errorf("Expected the genesis hash as head of an empty log, got %s", log.Head())
+	}
+	first, err := log.Append(Entry{Actor: "admin token", Action: "cache.flush"})
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	second, err := log.Append(Entry{Actor: "jwt:alice", Action: "dataset.upload", Target: "ru", Details: map[string]string{"names": "12"}})
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	if first.Seq != 1 || second.Seq != 2 {
+		t.Errorf("Expected sequence numbers 1 and 2, got %d and %d", first.Seq, second.Seq)
+	}
+	if first.PrevHash != genesisHash || second.PrevHash != first.Hash {
+		t.Error("Expected every entry to carry the hash of the one before it")
+	}
+	if second.Hash != computeHash(second) || log.Head() != second.Hash {
+		t.Error("Expected the head to be the hash of the last entry")
+	}
+	if first.Time.IsZero() || first.Time.Location().String() != "UTC" {
+		t.Errorf("Expected the time to be set in UTC, got %v", first.Time)
+	}
+}
+
+func TestRecent(t *testing.T) {
+	log, _ := Open("", 3)
+	defer log.Close()
+
+	if entries := log.Recent(0); len(entries) != 0 {
+		t.Errorf("Expected no entries, got %d", len(entries))
+	}
+	for _, action := range []string{"a", "b", "c", "d", "e"} {
+		log.Append(Entry{Action: action})
+	}
+
+	// Only the last 3 are kept, newest first
+	var actions []string
+	for _, entry := range log.Recent(0) {
+		actions = append(actions, entry.Action)
+	}
+	if strings.Join(actions, "") != "edc" {
+		t.Errorf("Expected entries e, d, c, got %v", actions)
+	}
+	if entries := log.Recent(2); len(entries) != 2 || entries[0].Action != "e" {
+		t.Errorf("Expected the 2 latest entries, got %+v", entries)
+	}
+}
+
+func TestReopenContinuesChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	log, err := Open(path, 10)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	log.Append(Entry{Action: "cache.flush"})
+	last, _ := log.Append(Entry{Action: "blocklist.add", Target: "bad"})
+	log.Close()
+
+	if _, err := log.Append(Entry{Action: "late"}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+
+	log, err = Open(path, 10)
+	if err != nil {
+		t.Fatalf("Failed to reopen log: %v", err)
+	}
+	defer log.Close()
+	if intact, _ := log.Intact(); !intact {
+		t.Error("Expected the reopened log to be intact")
+	}
+	if log.Head() != last.Hash {
+		t.Error("Expected the head to be restored from the file")
+	}
+	if entries := log.Recent(0); len(entries) != 2 || entries[0].Action != "blocklist.add" {
+		t.Errorf("Expected the recorded entries to be read back, got %+v", entries)
+	}
+	next, _ := log.Append(Entry{Action: "loglevel.change"})
+	if next.Seq != 3 || next.PrevHash != last.Hash {
+		t.Errorf("Expected the chain to continue after entry 2, got %+v", next)
+	}
+	if err := Verify(path); err != nil {
+		t.Errorf("Expected the log to verify, got %v", err)
+	}
+}
+
+func TestTamperingBreaksChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, _ := Open(path, 10)
+	log.Append(Entry{Actor: "admin token", Action: "cache.flush"})
+	log.Append(Entry{Actor: "admin token", Action: "dataset.delete", Target: "ru"})
+	log.Append(Entry{Actor: "admin token", Action: "cache.flush"})
+	log.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+
+	tests := []struct {
+		name     string
+		contents string
+		brokenAt uint64
+	}{
+		{"changed", lines[0] + strings.Replace(lines[1], `"ru"`, `"en"`, 1) + lines[2], 2},
+		{"removed", lines[0] + lines[2], 2},
+		{"reordered", lines[1] + lines[0] + lines[2], 1},
+		{"truncated line", lines[0] + lines[1] + lines[2][:20], 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := filepath.Join(t.TempDir(), "audit.log")
+			if err := os.WriteFile(tampered, []byte(tt.contents), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := Verify(tampered); err == nil {
+				t.Error("Expected Verify to report the broken chain")
+			}
+
+			// A broken log can still be opened and appended to
+			log, err := Open(tampered, 10)
+			if err != nil {
+				t.Fatalf("Failed to open tampered log: %v", err)
+			}
+			defer log.Close()
+			if intact, brokenAt := log.Intact(); intact || brokenAt != tt.brokenAt {
+				t.Errorf("Expected the chain to be broken at %d, got intact=%v at %d", tt.brokenAt, intact, brokenAt)
+			}
+			if _, err := log.Append(Entry{Action: "cache.flush"}); err != nil {
+				t.Errorf("Append failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.log")
+	if err := Verify(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing file to be reported, got %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected Verify not to create the file")
+	}
+}
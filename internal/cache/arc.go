@@ -0,0 +1,326 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// arcEntry is a value (or, in a ghost list, just a key) tracked by the ARC cache
+type arcEntry struct {
+	key        string
+	value      interface{}
+	expiration int64
+	list       *list.List // The list this entry currently belongs to
+}
+
+// ARCCache implements an Adaptive Replacement Cache (ARC)
+// Resident values are split between t1 (seen once recently) and t2 (seen at
+// least twice). The ghost lists b1 and b2 remember keys recently evicted from
+// t1 and t2, and hits on them shift the target size p of t1, so the cache
+// adapts between favoring recency and favoring frequency
+type ARCCache struct {
+	capacity          int
+	p                 int // Target size of t1
+	t1, t2            *list.List
+	b1, b2            *list.List
+	items             map[string]*list.Element
+	mu                sync.Mutex
+	defaultExpiration time.Duration
+	cleanupInterval   time.Duration
+	stopCleanup       chan bool
+	counters          counters
+}
+
+// NewARCCache creates a new ARC cache with the given capacity
+func NewARCCache(capacity int, defaultExpiration, cleanupInterval time.Duration) *ARCCache {
+	cache := &ARCCache{
+		capacity:          capacity,
+		t1:                list.New(),
+		t2:                list.New(),
+		b1:                list.New(),
+		b2:                list.New(),
+		items:             make(map[string]*list.Element, capacity*2),
+		defaultExpiration: defaultExpiration,
+		cleanupInterval:   cleanupInterval,
+		stopCleanup:       make(chan bool),
+	}
+
+	// Start the cleanup goroutine
+	if cleanupInterval > 0 {
+		go cache.startCleanupTimer()
+	}
+
+	return cache
+}
+
+// startCleanupTimer starts the cleanup timer
+func (c *ARCCache) startCleanupTimer() {
+	ticker := time.NewTicker(c.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.DeleteExpired()
+		case <-c.stopCleanup:
+			return
+		}
+	}
+}
+
+// resident reports whether an entry holds a value (as opposed to a ghost key)
+func (c *ARCCache) resident(entry *arcEntry) bool {
+	return entry.list == c.t1 || entry.list == c.t2
+}
+
+// moveTo moves an element to the front of the given list
+func (c *ARCCache) moveTo(element *list.Element, target *list.List) {
+	entry := element.Value.(*arcEntry)
+	entry.list.Remove(element)
+	entry.list = target
+	c.items[entry.key] = target.PushFront(entry)
+}
+
+// remove removes an element from whichever list it belongs to
+func (c *ARCCache) remove(element *list.Element) {
+	entry := element.Value.(*arcEntry)
+	entry.list.Remove(element)
+	delete(c.items, entry.key)
+}
+
+// Get gets an item from the cache, promoting it to the frequently used list
+func (c *ARCCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, found := c.items[key]
+	if !found || !c.resident(element.Value.(*arcEntry)) {
+		c.counters.miss()
+		return nil, false
+	}
+
+	// Check if the item has expired
+	entry := element.Value.(*arcEntry)
+	if entry.expiration > 0 && time.Now().UnixNano() > entry.expiration {
+		c.remove(element)
+		c.counters.miss()
+		c.counters.expiredRemoved(1)
+		return nil, false
+	}
+
+	// A repeated access makes the entry frequently used
+	c.moveTo(element, c.t2)
+	c.counters.hit()
+	return entry.value, true
+}
+
+// Peek gets an item from the cache without counting the lookup or promoting it
+func (c *ARCCache) Peek(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, found := c.items[key]
+	if !found || !c.resident(element.Value.(*arcEntry)) {
+		return nil, false
+	}
+	entry := element.Value.(*arcEntry)
+	if entry.expiration > 0 && time.Now().UnixNano() > entry.expiration {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Set adds an item to the cache with the default expiration
+func (c *ARCCache) Set(key string, value interface{}) {
+	c.SetWithExpiration(key, value, c.defaultExpiration)
+}
+
+// SetWithExpiration adds an item to the cache with a specific expiration
+func (c *ARCCache) SetWithExpiration(key string, value interface{}, d time.Duration) {
+	var expiration int64
+
+	if d == 0 {
+		// 0 means use default expiration
+		d = c.defaultExpiration
+	}
+
+	if d > 0 {
+		expiration = time.Now().Add(d).UnixNano()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, found := c.items[key]; found {
+		entry := element.Value.(*arcEntry)
+		switch entry.list {
+		case c.t1, c.t2:
+			// Already resident, update it and treat the write as an access
+			entry.value = value
+			entry.expiration = expiration
+			c.moveTo(element, c.t2)
+			return
+		case c.b1:
+			// Recently evicted from t1: favor recency by growing t1
+			c.p = minInt(c.capacity, c.p+maxInt(c.b2.Len()/c.b1.Len(), 1))
+			c.replace(false)
+		case c.b2:
+			// Recently evicted from t2: favor frequency by shrinking t1
+			c.p = maxInt(0, c.p-maxInt(c.b1.Len()/c.b2.Len(), 1))
+			c.replace(true)
+		}
+
+		// Bring the ghost back as a frequently used entry
+		entry.value = value
+		entry.expiration = expiration
+		c.moveTo(element, c.t2)
+		return
+	}
+
+	// A brand new key: keep the lists within their bounds
+	if c.t1.Len()+c.b1.Len() >= c.capacity {
+		if c.t1.Len() < c.capacity {
+			c.remove(c.b1.Back())
+			c.replace(false)
+		} else {
+			c.remove(c.t1.Back())
+			c.counters.evicted(1)
+		}
+	} else if total := c.t1.Len() + c.t2.Len() + c.b1.Len() + c.b2.Len(); total >= c.capacity {
+		if total >= 2*c.capacity {
+			c.remove(c.b2.Back())
+		}
+		c.replace(false)
+	}
+
+	// New keys start in the recently used list
+	entry := &arcEntry{
+		key:        key,
+		value:      value,
+		expiration: expiration,
+		list:       c.t1,
+	}
+	c.items[key] = c.t1.PushFront(entry)
+}
+
+// replace evicts a resident value into the matching ghost list if the cache is full
+func (c *ARCCache) replace(inB2 bool) {
+	if c.t1.Len()+c.t2.Len() < c.capacity {
+		return
+	}
+
+	var element *list.Element
+	var ghost *list.List
+	if c.t1.Len() > 0 && (c.t1.Len() > c.p || (inB2 && c.t1.Len() == c.p)) {
+		element, ghost = c.t1.Back(), c.b1
+	} else if c.t2.Len() > 0 {
+		element, ghost = c.t2.Back(), c.b2
+	} else {
+		element, ghost = c.t1.Back(), c.b1
+	}
+
+	// Drop the value but remember the key
+	entry := element.Value.(*arcEntry)
+	entry.value = nil
+	c.moveTo(element, ghost)
+	c.counters.evicted(1)
+}
+
+// Delete deletes an item from the cache
+func (c *ARCCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, found := c.items[key]; found {
+		c.remove(element)
+	}
+}
+
+// DeleteExpired deletes all expired items from the cache
+func (c *ARCCache) DeleteExpired() {
+	now := time.Now().UnixNano()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for _, element := range c.items {
+		entry := element.Value.(*arcEntry)
+		if c.resident(entry) && entry.expiration > 0 && now > entry.expiration {
+			c.remove(element)
+			removed++
+		}
+	}
+	c.counters.expiredRemoved(removed)
+}
+
+// Flush deletes all items from the cache
+func (c *ARCCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.p = 0
+	c.t1.Init()
+	c.t2.Init()
+	c.b1.Init()
+	c.b2.Init()
+	c.items = make(map[string]*list.Element, c.capacity*2)
+}
+
+// Keys returns the keys of all unexpired values, excluding ghost keys
+func (c *ARCCache) Keys() []string {
+	now := time.Now().UnixNano()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, c.t1.Len()+c.t2.Len())
+	for _, l := range []*list.List{c.t1, c.t2} {
+		for element := l.Front(); element != nil; element = element.Next() {
+			entry := element.Value.(*arcEntry)
+			if entry.expiration == 0 || now <= entry.expiration {
+				keys = append(keys, entry.key)
+			}
+		}
+	}
+	return keys
+}
+
+// Count returns the number of values in the cache, excluding ghost keys
+func (c *ARCCache) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.t1.Len() + c.t2.Len()
+}
+
+// Stats returns the cache usage counters
+func (c *ARCCache) Stats() Stats {
+	stats := c.counters.snapshot(c.Count())
+	stats.Capacity = c.capacity
+	return stats
+}
+
+// Shutdown stops the cleanup goroutine
+func (c *ARCCache) Shutdown() {
+	if c.cleanupInterval > 0 {
+		c.stopCleanup <- true
+	}
+}
+
+// minInt returns the minimum of two int values
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the maximum of two int values
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestARCCache(t *testing.T) {
+	// Create a new ARC cache with a capacity of 4
+	cache := NewARCCache(4, time.Minute, 0)
+	defer cache.Shutdown()
+
+	// Make key1 and key2 frequently used
+	cache.Set("key1", "value1")
+	cache.Set("key2", "value2")
+	cache.Get("key1")
+	cache.Get("key2")
+
+	// A scan of one-off keys should not push out the frequently used ones
+	for i := 0; i < 20; i++ {
+		cache.Set(fmt.Sprintf("scan%d", i), i)
+	}
+
+	for key, expected := range map[string]string{"key1": "value1", "key2": "value2"} {
+		if value, found := cache.Get(key); !found || value != expected {
+			t.Errorf("Expected '%s' for '%s' to survive the scan, got %v (found: %v)", expected, key, value, found)
+		}
+	}
+
+	// The cache never holds more values than its capacity
+	if cache.Count() > 4 {
+		t.Errorf("Expected at most 4 items, got %d", cache.Count())
+	}
+
+	// Ghost keys are not visible as values
+	if _, found := cache.Get("scan0"); found {
+		t.Error("Expected 'scan0' to be evicted")
+	}
+
+	// Setting a ghost key brings it back
+	cache.Set("scan0", "again")
+	if value, found := cache.Get("scan0"); !found || value != "again" {
+		t.Errorf("Expected 'again' for 'scan0', got %v (found: %v)", value, found)
+	}
+
+	// Test Delete
+	cache.Delete("key1")
+	if _, found := cache.Get("key1"); found {
+		t.Error("Expected 'key1' to be deleted")
+	}
+
+	// Test expiration
+	cache.SetWithExpiration("key3", "value3", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	cache.DeleteExpired()
+	if _, found := cache.Get("key3"); found {
+		t.Error("Expected 'key3' to be expired")
+	}
+
+	// Test Flush
+	cache.Flush()
+	if cache.Count() != 0 {
+		t.Errorf("Expected cache to be empty, got %d items", cache.Count())
+	}
+
+	if stats := cache.Stats(); stats.Evictions == 0 {
+		t.Errorf("Expected evictions to be counted, got %+v", stats)
+	}
+}
+
+func TestARCCacheBounded(t *testing.T) {
+	// Hammer a small cache with a mix of repeated and new keys
+	cache := NewARCCache(8, time.Minute, 0)
+	defer cache.Shutdown()
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", (i*7)%37)
+		if _, found := cache.Get(key); !found {
+			cache.Set(key, i)
+		}
+	}
+
+	// Values and ghost keys must both stay bounded
+	if cache.Count() > 8 {
+		t.Errorf("Expected at most 8 items, got %d", cache.Count())
+	}
+	if len(cache.items) > 16 {
+		t.Errorf("Expected at most 16 tracked keys, got %d", len(cache.items))
+	}
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,18 +20,125 @@ func (item Item) Expired() bool {
 	return time.Now().UnixNano() > item.Expiration
 }
 
-// Cache is a simple in-memory cache with expiration
-type Cache struct {
+// Cache is the interface implemented by all cache backends
+type Cache interface {
+	// Get returns the value stored under key and whether it was found
+	Get(key string) (interface{}, bool)
+
+	// Set stores a value under key with the backend's default expiration
+	Set(key string, value interface{})
+
+	// SetWithExpiration stores a value under key for the given duration
+	// (0 means the default expiration, a negative duration means no expiration)
+	SetWithExpiration(key string, value interface{}, d time.Duration)
+
+	// Delete removes the value stored under key
+	Delete(key string)
+
+	// Count returns the number of values currently stored
+	Count() int
+
+	// Shutdown releases any resources held by the backend
+	Shutdown()
+}
+
+// Stats holds cache usage counters
+type Stats struct {
+	Hits      uint64  // lookups that found a live value
+	Misses    uint64  // lookups that found nothing or an expired value
+	Evictions uint64  // values removed to make room for new ones
+	Expired   uint64  // expired values removed from the cache
+	Entries   int     // values currently stored
+	Capacity  int     // values the cache can hold, 0 if it is not bounded by a count
+	Bytes     int64   // approximate size of the stored values, if tracked
+	Shards    []Stats // the stats of each shard, for sharded caches
+}
+
+// HitRatio returns the fraction of lookups that were hits (0-1)
+func (s Stats) HitRatio() float64 {
+	lookups := s.Hits + s.Misses
+	if lookups == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(lookups)
+}
+
+// StatsProvider is implemented by caches that report usage counters
+type StatsProvider interface {
+	Stats() Stats
+}
+
+// Inspector is implemented by caches whose contents can be listed and flushed
+type Inspector interface {
+	// Keys returns the keys of all live values
+	Keys() []string
+
+	// Peek returns the live value stored under key, without counting the
+	// lookup or marking the value as used
+	Peek(key string) (interface{}, bool)
+
+	// Flush deletes all values
+	Flush()
+}
+
+// Pinger is implemented by caches backed by an external server, to check
+// that the server can be reached
+type Pinger interface {
+	Ping() error
+}
+
+// counters tracks cache usage with atomic counters
+type counters struct {
+	hits      uint64
+	misses    uint64
+	evictions uint64
+	expired   uint64
+}
+
+// hit records a successful lookup
+func (c *counters) hit() {
+	atomic.AddUint64(&c.hits, 1)
+}
+
+// miss records an unsuccessful lookup
+func (c *counters) miss() {
+	atomic.AddUint64(&c.misses, 1)
+}
+
+// evicted records values removed to make room for new ones
+func (c *counters) evicted(n int) {
+	atomic.AddUint64(&c.evictions, uint64(n))
+}
+
+// expiredRemoved records expired values removed from the cache
+func (c *counters) expiredRemoved(n int) {
+	atomic.AddUint64(&c.expired, uint64(n))
+}
+
+// snapshot returns the current counter values
+func (c *counters) snapshot(entries int) Stats {
+	return Stats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Expired:   atomic.LoadUint64(&c.expired),
+		Entries:   entries,
+	}
+}
+
+// MemoryCache is a simple in-memory cache with expiration
+type MemoryCache struct {
 	items             map[string]Item
 	mu                sync.RWMutex
 	defaultExpiration time.Duration
 	cleanupInterval   time.Duration
 	stopCleanup       chan bool
+	counters          counters
 }
 
 // NewCache creates a new cache with the given default expiration and cleanup interval
-func NewCache(defaultExpiration, cleanupInterval time.Duration) *Cache {
-	cache := &Cache{
+func NewCache(defaultExpiration, cleanupInterval time.Duration) *MemoryCache {
+	cache := &MemoryCache{
 		items:             make(map[string]Item),
 		defaultExpiration: defaultExpiration,
 		cleanupInterval:   cleanupInterval,
@@ -46,7 +154,7 @@ func NewCache(defaultExpiration, cleanupInterval time.Duration) *Cache {
 }
 
 // startCleanupTimer starts the cleanup timer
-func (c *Cache) startCleanupTimer() {
+func (c *MemoryCache) startCleanupTimer() {
 	ticker := time.NewTicker(c.cleanupInterval)
 	defer ticker.Stop()
 	
@@ -61,12 +169,12 @@ func (c *Cache) startCleanupTimer() {
 }
 
 // Set adds an item to the cache with the default expiration
-func (c *Cache) Set(key string, value interface{}) {
+func (c *MemoryCache) Set(key string, value interface{}) {
 	c.SetWithExpiration(key, value, c.defaultExpiration)
 }
 
 // SetWithExpiration adds an item to the cache with a specific expiration
-func (c *Cache) SetWithExpiration(key string, value interface{}, d time.Duration) {
+func (c *MemoryCache) SetWithExpiration(key string, value interface{}, d time.Duration) {
 	var expiration int64
 	
 	if d == 0 {
@@ -88,25 +196,40 @@ func (c *Cache) SetWithExpiration(key string, value interface{}, d time.Duration
 }
 
 // Get gets an item from the cache
-func (c *Cache) Get(key string) (interface{}, bool) {
+func (c *MemoryCache) Get(key string) (interface{}, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	
 	item, found := c.items[key]
 	if !found {
+		c.counters.miss()
 		return nil, false
 	}
 	
 	// Check if the item has expired
 	if item.Expired() {
+		c.counters.miss()
 		return nil, false
 	}
 	
+	c.counters.hit()
+	return item.Value, true
+}
+
+// Peek gets an item from the cache without counting the lookup
+func (c *MemoryCache) Peek(key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	item, found := c.items[key]
+	if !found || item.Expired() {
+		return nil, false
+	}
 	return item.Value, true
 }
 
 // Delete deletes an item from the cache
-func (c *Cache) Delete(key string) {
+func (c *MemoryCache) Delete(key string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
@@ -114,45 +237,71 @@ func (c *Cache) Delete(key string) {
 }
 
 // DeleteExpired deletes all expired items from the cache
-func (c *Cache) DeleteExpired() {
+func (c *MemoryCache) DeleteExpired() {
 	now := time.Now().UnixNano()
 	
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
+	removed := 0
 	for k, v := range c.items {
 		if v.Expiration > 0 && now > v.Expiration {
 			delete(c.items, k)
+			removed++
 		}
 	}
+	c.counters.expiredRemoved(removed)
 }
 
 // Flush deletes all items from the cache
-func (c *Cache) Flush() {
+func (c *MemoryCache) Flush() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
 	c.items = make(map[string]Item)
 }
 
+// Keys returns the keys of all unexpired items
+func (c *MemoryCache) Keys() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	keys := make([]string, 0, len(c.items))
+	for k, v := range c.items {
+		if !v.Expired() {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
 // Count returns the number of items in the cache
-func (c *Cache) Count() int {
+func (c *MemoryCache) Count() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	
 	return len(c.items)
 }
 
+// Stats returns the cache usage counters
+func (c *MemoryCache) Stats() Stats {
+	return c.counters.snapshot(c.Count())
+}
+
 // Shutdown stops the cleanup goroutine
-func (c *Cache) Shutdown() {
+func (c *MemoryCache) Shutdown() {
 	if c.cleanupInterval > 0 {
 		c.stopCleanup <- true
 	}
 }
 
 // LRUCache implements a Least Recently Used (LRU) cache
+// Besides the entry count, the cache can be bounded by the approximate number
+// of bytes its entries occupy
 type LRUCache struct {
 	capacity          int
+	maxBytes          int64 // 0 means no byte limit
+	bytes             int64 // approximate size of all entries
 	items             map[string]*LRUNode
 	head              *LRUNode // Most recently used
 	tail              *LRUNode // Least recently used
@@ -160,6 +309,7 @@ type LRUCache struct {
 	defaultExpiration time.Duration
 	cleanupInterval   time.Duration
 	stopCleanup       chan bool
+	counters          counters
 }
 
 // LRUNode represents a node in the LRU cache
@@ -167,14 +317,22 @@ type LRUNode struct {
 	key        string
 	value      interface{}
 	expiration int64
+	size       int64 // approximate size of the entry in bytes
 	prev       *LRUNode
 	next       *LRUNode
 }
 
 // NewLRUCache creates a new LRU cache with the given capacity
 func NewLRUCache(capacity int, defaultExpiration, cleanupInterval time.Duration) *LRUCache {
+	return NewLRUCacheWithMaxBytes(capacity, 0, defaultExpiration, cleanupInterval)
+}
+
+// NewLRUCacheWithMaxBytes creates a new LRU cache bounded by both an entry count and
+// an approximate size in bytes (0 means no byte limit)
+func NewLRUCacheWithMaxBytes(capacity int, maxBytes int64, defaultExpiration, cleanupInterval time.Duration) *LRUCache {
 	cache := &LRUCache{
 		capacity:          capacity,
+		maxBytes:          maxBytes,
 		items:             make(map[string]*LRUNode, capacity),
 		defaultExpiration: defaultExpiration,
 		cleanupInterval:   cleanupInterval,
@@ -211,6 +369,7 @@ func (c *LRUCache) Get(key string) (interface{}, bool) {
 	c.mu.RUnlock()
 	
 	if !found {
+		c.counters.miss()
 		return nil, false
 	}
 	
@@ -220,6 +379,8 @@ func (c *LRUCache) Get(key string) (interface{}, bool) {
 		c.removeNode(node)
 		delete(c.items, key)
 		c.mu.Unlock()
+		c.counters.miss()
+		c.counters.expiredRemoved(1)
 		return nil, false
 	}
 	
@@ -228,6 +389,20 @@ func (c *LRUCache) Get(key string) (interface{}, bool) {
 	c.moveToFront(node)
 	c.mu.Unlock()
 	
+	c.counters.hit()
+	return node.value, true
+}
+
+// Peek gets an item from the cache without counting the lookup or moving it
+// in the LRU list
+func (c *LRUCache) Peek(key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	node, found := c.items[key]
+	if !found || (node.expiration > 0 && time.Now().UnixNano() > node.expiration) {
+		return nil, false
+	}
 	return node.value, true
 }
 
@@ -252,13 +427,18 @@ func (c *LRUCache) SetWithExpiration(key string, value interface{}, d time.Durat
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
+	size := entrySize(key, value)
+	
 	// Check if the key already exists
 	if node, found := c.items[key]; found {
 		// Update the value and expiration
 		node.value = value
 		node.expiration = expiration
+		c.bytes += size - node.size
+		node.size = size
 		// Move the node to the front of the list
 		c.moveToFront(node)
+		c.evictOverBudget()
 		return
 	}
 	
@@ -267,7 +447,9 @@ func (c *LRUCache) SetWithExpiration(key string, value interface{}, d time.Durat
 		key:        key,
 		value:      value,
 		expiration: expiration,
+		size:       size,
 	}
+	c.bytes += size
 	
 	// Add the node to the cache
 	c.items[key] = node
@@ -290,6 +472,24 @@ func (c *LRUCache) SetWithExpiration(key string, value interface{}, d time.Durat
 		lru := c.tail
 		c.removeNode(lru)
 		delete(c.items, lru.key)
+		c.counters.evicted(1)
+	}
+	
+	c.evictOverBudget()
+}
+
+// evictOverBudget removes least recently used items until the cache is within its byte limit
+// An entry larger than the whole budget is not kept either
+func (c *LRUCache) evictOverBudget() {
+	if c.maxBytes <= 0 {
+		return
+	}
+	
+	for c.bytes > c.maxBytes && c.tail != nil {
+		lru := c.tail
+		c.removeNode(lru)
+		delete(c.items, lru.key)
+		c.counters.evicted(1)
 	}
 }
 
@@ -318,8 +518,10 @@ func (c *LRUCache) moveToFront(node *LRUNode) {
 	c.head = node
 }
 
-// removeNode removes a node from the linked list
+// removeNode removes a node from the linked list and releases its size
 func (c *LRUCache) removeNode(node *LRUNode) {
+	c.bytes -= node.size
+	
 	if node.prev != nil {
 		node.prev.next = node.next
 	} else {
@@ -356,12 +558,15 @@ func (c *LRUCache) DeleteExpired() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
+	removed := 0
 	for key, node := range c.items {
 		if node.expiration > 0 && now > node.expiration {
 			c.removeNode(node)
 			delete(c.items, key)
+			removed++
 		}
 	}
+	c.counters.expiredRemoved(removed)
 }
 
 // Flush deletes all items from the cache
@@ -372,6 +577,23 @@ func (c *LRUCache) Flush() {
 	c.items = make(map[string]*LRUNode, c.capacity)
 	c.head = nil
 	c.tail = nil
+	c.bytes = 0
+}
+
+// Keys returns the keys of all unexpired items, most recently used first
+func (c *LRUCache) Keys() []string {
+	now := time.Now().UnixNano()
+	
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	keys := make([]string, 0, len(c.items))
+	for node := c.head; node != nil; node = node.next {
+		if node.expiration == 0 || now <= node.expiration {
+			keys = append(keys, node.key)
+		}
+	}
+	return keys
 }
 
 // Count returns the number of items in the cache
@@ -382,6 +604,22 @@ func (c *LRUCache) Count() int {
 	return len(c.items)
 }
 
+// Bytes returns the approximate size of all entries in bytes
+func (c *LRUCache) Bytes() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	return c.bytes
+}
+
+// Stats returns the cache usage counters
+func (c *LRUCache) Stats() Stats {
+	stats := c.counters.snapshot(c.Count())
+	stats.Capacity = c.capacity
+	stats.Bytes = c.Bytes()
+	return stats
+}
+
 // Shutdown stops the cleanup goroutine
 func (c *LRUCache) Shutdown() {
 	if c.cleanupInterval > 0 {
@@ -389,39 +627,123 @@ func (c *LRUCache) Shutdown() {
 	}
 }
 
-// ConcurrentLRUCache implements a sharded LRU cache for better concurrency
+// EvictionPolicy selects how a full cache chooses the value to evict
+type EvictionPolicy string
+
+const (
+	// EvictionLRU evicts the least recently used value
+	EvictionLRU EvictionPolicy = "lru"
+	// EvictionLFU evicts the least frequently used value
+	EvictionLFU EvictionPolicy = "lfu"
+	// EvictionARC balances recency and frequency with an adaptive replacement cache
+	EvictionARC EvictionPolicy = "arc"
+)
+
+// Valid reports whether p is one of the eviction policies, empty meaning lru
+func (p EvictionPolicy) Valid() bool {
+	switch p {
+	case "", EvictionLRU, EvictionLFU, EvictionARC:
+		return true
+	}
+	return false
+}
+
+// shardCache is implemented by the single-shard caches used by ConcurrentLRUCache
+type shardCache interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{})
+	SetWithExpiration(key string, value interface{}, d time.Duration)
+	Delete(key string)
+	DeleteExpired()
+	Flush()
+	Count() int
+	Keys() []string
+	Peek(key string) (interface{}, bool)
+	Stats() Stats
+	Shutdown()
+}
+
+// shardOptions holds the optional settings of a ConcurrentLRUCache
+type shardOptions struct {
+	policy   EvictionPolicy
+	maxBytes int64
+}
+
+// Option configures optional ConcurrentLRUCache behavior
+type Option func(*shardOptions)
+
+// WithEvictionPolicy selects the eviction policy used by every shard
+func WithEvictionPolicy(policy EvictionPolicy) Option {
+	return func(o *shardOptions) {
+		o.policy = policy
+	}
+}
+
+// WithMaxBytes bounds the approximate total size of the cached values in bytes
+// The budget is split evenly between the shards and only applies to the LRU
+// policy; LFU and ARC shards are bounded by their entry count alone
+func WithMaxBytes(maxBytes int64) Option {
+	return func(o *shardOptions) {
+		o.maxBytes = maxBytes
+	}
+}
+
+// ConcurrentLRUCache implements a sharded cache for better concurrency
+// Shards evict least recently used values unless another policy is selected
 type ConcurrentLRUCache struct {
-	shards    []*LRUCache
+	shards    []shardCache
 	numShards int
 }
 
 // NewConcurrentLRUCache creates a new concurrent LRU cache with the given capacity
-func NewConcurrentLRUCache(totalCapacity int, numShards int, defaultExpiration, cleanupInterval time.Duration) *ConcurrentLRUCache {
+func NewConcurrentLRUCache(totalCapacity int, numShards int, defaultExpiration, cleanupInterval time.Duration, opts ...Option) *ConcurrentLRUCache {
 	if numShards <= 0 {
 		numShards = 16 // Default number of shards
 	}
 	
+	// Apply the options
+	options := shardOptions{policy: EvictionLRU}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	
 	// Calculate capacity per shard
 	shardCapacity := totalCapacity / numShards
 	if shardCapacity < 1 {
 		shardCapacity = 1
 	}
 	
+	// Calculate the byte budget per shard
+	var shardMaxBytes int64
+	if options.maxBytes > 0 {
+		shardMaxBytes = options.maxBytes / int64(numShards)
+		if shardMaxBytes < 1 {
+			shardMaxBytes = 1
+		}
+	}
+	
 	cache := &ConcurrentLRUCache{
-		shards:    make([]*LRUCache, numShards),
+		shards:    make([]shardCache, numShards),
 		numShards: numShards,
 	}
 	
 	// Create the shards
 	for i := 0; i < numShards; i++ {
-		cache.shards[i] = NewLRUCache(shardCapacity, defaultExpiration, cleanupInterval)
+		switch options.policy {
+		case EvictionLFU:
+			cache.shards[i] = NewLFUCache(shardCapacity, defaultExpiration, cleanupInterval)
+		case EvictionARC:
+			cache.shards[i] = NewARCCache(shardCapacity, defaultExpiration, cleanupInterval)
+		default:
+			cache.shards[i] = NewLRUCacheWithMaxBytes(shardCapacity, shardMaxBytes, defaultExpiration, cleanupInterval)
+		}
 	}
 	
 	return cache
 }
 
 // getShard returns the shard for a given key
-func (c *ConcurrentLRUCache) getShard(key string) *LRUCache {
+func (c *ConcurrentLRUCache) getShard(key string) shardCache {
 	// Simple hash function to distribute keys
 	hash := 0
 	for i := 0; i < len(key); i++ {
@@ -438,6 +760,11 @@ func (c *ConcurrentLRUCache) Get(key string) (interface{}, bool) {
 	return c.getShard(key).Get(key)
 }
 
+// Peek gets an item from the cache without counting the lookup
+func (c *ConcurrentLRUCache) Peek(key string) (interface{}, bool) {
+	return c.getShard(key).Peek(key)
+}
+
 // Set adds an item to the cache with the default expiration
 func (c *ConcurrentLRUCache) Set(key string, value interface{}) {
 	c.getShard(key).Set(key, value)
@@ -467,6 +794,15 @@ func (c *ConcurrentLRUCache) Flush() {
 	}
 }
 
+// Keys returns the keys of all unexpired items across all shards
+func (c *ConcurrentLRUCache) Keys() []string {
+	var keys []string
+	for i := 0; i < c.numShards; i++ {
+		keys = append(keys, c.shards[i].Keys()...)
+	}
+	return keys
+}
+
 // Count returns the number of items in the cache
 func (c *ConcurrentLRUCache) Count() int {
 	count := 0
@@ -476,9 +812,58 @@ func (c *ConcurrentLRUCache) Count() int {
 	return count
 }
 
+// Stats returns the usage counters summed across all shards
+func (c *ConcurrentLRUCache) Stats() Stats {
+	total := Stats{Shards: make([]Stats, c.numShards)}
+	for i := 0; i < c.numShards; i++ {
+		shard := c.shards[i].Stats()
+		total.Hits += shard.Hits
+		total.Misses += shard.Misses
+		total.Evictions += shard.Evictions
+		total.Expired += shard.Expired
+		total.Entries += shard.Entries
+		total.Capacity += shard.Capacity
+		total.Bytes += shard.Bytes
+		total.Shards[i] = shard
+	}
+	return total
+}
+
 // Shutdown stops all cleanup goroutines
 func (c *ConcurrentLRUCache) Shutdown() {
 	for i := 0; i < c.numShards; i++ {
 		c.shards[i].Shutdown()
 	}
 }
+
+// Sizer is implemented by values that report their approximate size in bytes
+// Memory-bounded caches use it for value types they don't know about
+type Sizer interface {
+	Size() int64
+}
+
+// entryOverhead approximates the memory used by a cache entry besides its key and value
+// (list node, map slot and interface header)
+const entryOverhead = 96
+
+// entrySize returns the approximate memory used by a cache entry in bytes
+func entrySize(key string, value interface{}) int64 {
+	size := int64(entryOverhead + len(key))
+	
+	switch v := value.(type) {
+	case Sizer:
+		size += v.Size()
+	case string:
+		size += int64(len(v))
+	case []byte:
+		size += int64(len(v))
+	case []string:
+		// Slice header plus a string header and the bytes of every element
+		size += 24
+		for _, s := range v {
+			size += int64(16 + len(s))
+		}
+	}
+	
+	return size
+}
@@ -1,6 +1,9 @@
 package cache
 
 import (
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -255,3 +258,257 @@ func TestConcurrentLRUCache(t *testing.T) {
 		t.Errorf("Expected cache to be empty after flush, got %d items", cache.Count())
 	}
 }
+
+func TestCacheStats(t *testing.T) {
+	// Create a cache without a cleanup goroutine so counters are deterministic
+	cache := NewCache(time.Minute, 0)
+	
+	cache.Set("key1", "value1")
+	cache.Get("key1")
+	cache.Get("key2")
+	cache.SetWithExpiration("key3", "value3", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	cache.Get("key3")
+	cache.DeleteExpired()
+	
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, got %d hits and %d misses", stats.Hits, stats.Misses)
+	}
+	if stats.Expired != 1 {
+		t.Errorf("Expected 1 expired removal, got %d", stats.Expired)
+	}
+	if stats.Entries != 1 {
+		t.Errorf("Expected 1 entry, got %d", stats.Entries)
+	}
+	if ratio := stats.HitRatio(); ratio < 0.33 || ratio > 0.34 {
+		t.Errorf("Expected hit ratio of 1/3, got %f", ratio)
+	}
+}
+
+func TestLRUCacheStats(t *testing.T) {
+	// Create an LRU cache with a capacity of 2
+	cache := NewLRUCache(2, time.Minute, 0)
+	
+	// Fill the cache and overflow it twice
+	cache.Set("key1", "value1")
+	cache.Set("key2", "value2")
+	cache.Set("key3", "value3")
+	cache.Set("key4", "value4")
+	
+	// key1 and key2 were evicted
+	cache.Get("key1")
+	cache.Get("key4")
+	
+	// An expired item is removed on lookup
+	cache.SetWithExpiration("key5", "value5", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	cache.Get("key5")
+	
+	stats := cache.Stats()
+	if stats.Evictions != 3 {
+		t.Errorf("Expected 3 evictions, got %d", stats.Evictions)
+	}
+	if stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, got %d hits and %d misses", stats.Hits, stats.Misses)
+	}
+	if stats.Expired != 1 {
+		t.Errorf("Expected 1 expired removal, got %d", stats.Expired)
+	}
+}
+
+func TestConcurrentLRUCacheStats(t *testing.T) {
+	// Create a sharded cache and spread some lookups across the shards
+	cache := NewConcurrentLRUCache(100, 4, time.Minute, 0)
+	
+	for i := 0; i < 10; i++ {
+		key := "key" + string(rune('a'+i))
+		cache.Set(key, i)
+		cache.Get(key)
+		cache.Get(key + "-missing")
+	}
+	
+	// Counters are summed across all shards
+	stats := cache.Stats()
+	if stats.Hits != 10 || stats.Misses != 10 {
+		t.Errorf("Expected 10 hits and 10 misses, got %d hits and %d misses", stats.Hits, stats.Misses)
+	}
+	if stats.Entries != 10 {
+		t.Errorf("Expected 10 entries, got %d", stats.Entries)
+	}
+	if stats.HitRatio() != 0.5 {
+		t.Errorf("Expected hit ratio of 0.5, got %f", stats.HitRatio())
+	}
+	
+	// Each shard reports its own share
+	if stats.Capacity != 100 || len(stats.Shards) != 4 {
+		t.Fatalf("Expected a capacity of 100 over 4 shards, got %d over %d", stats.Capacity, len(stats.Shards))
+	}
+	entries := 0
+	for _, shard := range stats.Shards {
+		if shard.Capacity != 25 {
+			t.Errorf("Expected a capacity of 25 per shard, got %d", shard.Capacity)
+		}
+		entries += shard.Entries
+	}
+	if entries != 10 {
+		t.Errorf("Expected the shards to hold the 10 entries, got %d", entries)
+	}
+}
+
+func TestConcurrentLRUCacheEvictionPolicy(t *testing.T) {
+	for _, policy := range []EvictionPolicy{EvictionLRU, EvictionLFU, EvictionARC} {
+		t.Run(string(policy), func(t *testing.T) {
+			cache := NewConcurrentLRUCache(100, 4, time.Minute, 0, WithEvictionPolicy(policy))
+			defer cache.Shutdown()
+			
+			// Every policy supports the same operations
+			cache.Set("key1", "value1")
+			if value, found := cache.Get("key1"); !found || value != "value1" {
+				t.Errorf("Expected 'value1' for 'key1', got %v (found: %v)", value, found)
+			}
+			cache.Delete("key1")
+			if _, found := cache.Get("key1"); found {
+				t.Error("Expected 'key1' to be deleted")
+			}
+		})
+	}
+	
+	// The selected policy is applied to every shard
+	cache := NewConcurrentLRUCache(100, 4, time.Minute, 0, WithEvictionPolicy(EvictionLFU))
+	defer cache.Shutdown()
+	for _, shard := range cache.shards {
+		if _, ok := shard.(*LFUCache); !ok {
+			t.Errorf("Expected LFU shard, got %T", shard)
+		}
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	caches := map[string]interface {
+		Inspector
+		SetWithExpiration(key string, value interface{}, d time.Duration)
+	}{
+		"memory":     NewCache(time.Minute, 0),
+		"lru":        NewLRUCache(10, time.Minute, 0),
+		"lfu":        NewLFUCache(10, time.Minute, 0),
+		"arc":        NewARCCache(10, time.Minute, 0),
+		"concurrent": NewConcurrentLRUCache(100, 4, time.Minute, 0),
+	}
+	
+	for name, cache := range caches {
+		t.Run(name, func(t *testing.T) {
+			cache.SetWithExpiration("key1", "value1", 0)
+			cache.SetWithExpiration("key2", "value2", 0)
+			cache.SetWithExpiration("key3", "value3", time.Millisecond)
+			time.Sleep(5 * time.Millisecond)
+			
+			// Peeking neither finds expired items nor counts the lookups
+			if value, found := cache.Peek("key1"); !found || value != "value1" {
+				t.Errorf("Expected to peek value1, got %v", value)
+			}
+			if _, found := cache.Peek("key3"); found {
+				t.Error("Expected not to peek the expired key3")
+			}
+			if stats := cache.(StatsProvider).Stats(); stats.Hits != 0 || stats.Misses != 0 {
+				t.Errorf("Expected no lookups to be counted, got %d hits and %d misses", stats.Hits, stats.Misses)
+			}
+			
+			// Expired items are not listed
+			keys := cache.Keys()
+			sort.Strings(keys)
+			if len(keys) != 2 || keys[0] != "key1" || keys[1] != "key2" {
+				t.Errorf("Expected keys [key1 key2], got %v", keys)
+			}
+			
+			cache.Flush()
+			if keys := cache.Keys(); len(keys) != 0 {
+				t.Errorf("Expected no keys after flush, got %v", keys)
+			}
+		})
+	}
+}
+
+func TestLRUCacheMaxBytes(t *testing.T) {
+	// Each entry below takes entrySize("keyN", 100 bytes) of the budget
+	value := strings.Repeat("x", 100)
+	size := entrySize("key1", value)
+	
+	// Room for three entries by size, but ten by count
+	cache := NewLRUCacheWithMaxBytes(10, 3*size, time.Minute, 0)
+	
+	cache.Set("key1", value)
+	cache.Set("key2", value)
+	cache.Set("key3", value)
+	if cache.Bytes() != 3*size {
+		t.Errorf("Expected %d bytes, got %d", 3*size, cache.Bytes())
+	}
+	
+	// Adding a fourth entry evicts the least recently used one
+	cache.Get("key1")
+	cache.Set("key4", value)
+	if _, found := cache.Get("key2"); found {
+		t.Error("Expected 'key2' to be evicted")
+	}
+	if cache.Count() != 3 || cache.Bytes() > 3*size {
+		t.Errorf("Expected 3 entries within %d bytes, got %d entries and %d bytes", 3*size, cache.Count(), cache.Bytes())
+	}
+	
+	// Growing an existing entry evicts others until the cache is back under budget
+	cache.Set("key1", value+value)
+	if cache.Bytes() > 3*size {
+		t.Errorf("Expected at most %d bytes, got %d", 3*size, cache.Bytes())
+	}
+	if _, found := cache.Get("key1"); !found {
+		t.Error("Expected 'key1' to be kept")
+	}
+	
+	// An entry larger than the whole budget is not kept
+	cache.Set("huge", strings.Repeat("x", int(4*size)))
+	if _, found := cache.Get("huge"); found {
+		t.Error("Expected 'huge' to be rejected")
+	}
+	
+	// Deleting and flushing release the space
+	cache.Delete("key1")
+	cache.Flush()
+	if cache.Bytes() != 0 {
+		t.Errorf("Expected 0 bytes after flush, got %d", cache.Bytes())
+	}
+	
+	stats := cache.Stats()
+	if stats.Evictions == 0 {
+		t.Error("Expected evictions to be counted")
+	}
+}
+
+// sizedValue is a value that reports its own size
+type sizedValue int64
+
+func (v sizedValue) Size() int64 {
+	return int64(v)
+}
+
+func TestEntrySizeSizer(t *testing.T) {
+	if got := entrySize("key", sizedValue(1000)) - entrySize("key", nil); got != 1000 {
+		t.Errorf("Expected a Sizer value to add 1000 bytes, got %d", got)
+	}
+}
+
+func TestConcurrentLRUCacheMaxBytes(t *testing.T) {
+	// A 4 KB budget split across 4 shards
+	cache := NewConcurrentLRUCache(1000, 4, time.Minute, 0, WithMaxBytes(4096))
+	
+	value := strings.Repeat("x", 100)
+	for i := 0; i < 200; i++ {
+		cache.Set("key"+strconv.Itoa(i), value)
+	}
+	
+	stats := cache.Stats()
+	if stats.Bytes > 4096 {
+		t.Errorf("Expected at most 4096 bytes, got %d", stats.Bytes)
+	}
+	if stats.Evictions == 0 {
+		t.Error("Expected the byte budget to cause evictions")
+	}
+}
@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lfuEntry is a value stored in the LFU cache
+type lfuEntry struct {
+	key        string
+	value      interface{}
+	expiration int64
+	frequency  int
+}
+
+// LFUCache implements a Least Frequently Used (LFU) cache
+// Entries are kept in one list per access frequency so that both lookups
+// and evictions run in constant time; ties are broken by recency
+type LFUCache struct {
+	capacity          int
+	items             map[string]*list.Element
+	frequencies       map[int]*list.List // Front is the most recently used entry with that frequency
+	minFrequency      int
+	mu                sync.Mutex
+	defaultExpiration time.Duration
+	cleanupInterval   time.Duration
+	stopCleanup       chan bool
+	counters          counters
+}
+
+// NewLFUCache creates a new LFU cache with the given capacity
+func NewLFUCache(capacity int, defaultExpiration, cleanupInterval time.Duration) *LFUCache {
+	cache := &LFUCache{
+		capacity:          capacity,
+		items:             make(map[string]*list.Element, capacity),
+		frequencies:       make(map[int]*list.List),
+		defaultExpiration: defaultExpiration,
+		cleanupInterval:   cleanupInterval,
+		stopCleanup:       make(chan bool),
+	}
+
+	// Start the cleanup goroutine
+	if cleanupInterval > 0 {
+		go cache.startCleanupTimer()
+	}
+
+	return cache
+}
+
+// startCleanupTimer starts the cleanup timer
+func (c *LFUCache) startCleanupTimer() {
+	ticker := time.NewTicker(c.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.DeleteExpired()
+		case <-c.stopCleanup:
+			return
+		}
+	}
+}
+
+// Get gets an item from the cache and increments its access frequency
+func (c *LFUCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, found := c.items[key]
+	if !found {
+		c.counters.miss()
+		return nil, false
+	}
+
+	// Check if the item has expired
+	entry := element.Value.(*lfuEntry)
+	if entry.expiration > 0 && time.Now().UnixNano() > entry.expiration {
+		c.removeElement(element)
+		c.counters.miss()
+		c.counters.expiredRemoved(1)
+		return nil, false
+	}
+
+	c.touch(element)
+	c.counters.hit()
+	return entry.value, true
+}
+
+// Peek gets an item from the cache without counting the lookup or its access
+func (c *LFUCache) Peek(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, found := c.items[key]
+	if !found {
+		return nil, false
+	}
+	entry := element.Value.(*lfuEntry)
+	if entry.expiration > 0 && time.Now().UnixNano() > entry.expiration {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Set adds an item to the cache with the default expiration
+func (c *LFUCache) Set(key string, value interface{}) {
+	c.SetWithExpiration(key, value, c.defaultExpiration)
+}
+
+// SetWithExpiration adds an item to the cache with a specific expiration
+func (c *LFUCache) SetWithExpiration(key string, value interface{}, d time.Duration) {
+	var expiration int64
+
+	if d == 0 {
+		// 0 means use default expiration
+		d = c.defaultExpiration
+	}
+
+	if d > 0 {
+		expiration = time.Now().Add(d).UnixNano()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Check if the key already exists
+	if element, found := c.items[key]; found {
+		entry := element.Value.(*lfuEntry)
+		entry.value = value
+		entry.expiration = expiration
+		c.touch(element)
+		return
+	}
+
+	// Make room by evicting the least frequently used item
+	if len(c.items) >= c.capacity {
+		c.evict()
+	}
+
+	// Add the new item with a frequency of 1
+	entry := &lfuEntry{
+		key:        key,
+		value:      value,
+		expiration: expiration,
+		frequency:  1,
+	}
+	c.items[key] = c.frequencyList(1).PushFront(entry)
+	c.minFrequency = 1
+}
+
+// frequencyList returns the list for the given frequency, creating it if needed
+func (c *LFUCache) frequencyList(frequency int) *list.List {
+	l, found := c.frequencies[frequency]
+	if !found {
+		l = list.New()
+		c.frequencies[frequency] = l
+	}
+	return l
+}
+
+// touch moves an element to the list for the next frequency
+func (c *LFUCache) touch(element *list.Element) {
+	entry := element.Value.(*lfuEntry)
+
+	// Remove the element from its current frequency list
+	current := c.frequencies[entry.frequency]
+	current.Remove(element)
+	if current.Len() == 0 {
+		delete(c.frequencies, entry.frequency)
+		if c.minFrequency == entry.frequency {
+			c.minFrequency++
+		}
+	}
+
+	// Add it to the front of the next frequency list
+	entry.frequency++
+	c.items[entry.key] = c.frequencyList(entry.frequency).PushFront(entry)
+}
+
+// evict removes the least recently used item among those with the lowest frequency
+func (c *LFUCache) evict() {
+	l, found := c.frequencies[c.minFrequency]
+	if !found {
+		// minFrequency is stale after a delete, find the real minimum
+		c.minFrequency = 0
+		for frequency := range c.frequencies {
+			if c.minFrequency == 0 || frequency < c.minFrequency {
+				c.minFrequency = frequency
+			}
+		}
+		if l, found = c.frequencies[c.minFrequency]; !found {
+			return
+		}
+	}
+
+	c.removeElement(l.Back())
+	c.counters.evicted(1)
+}
+
+// removeElement removes an element from the cache
+func (c *LFUCache) removeElement(element *list.Element) {
+	entry := element.Value.(*lfuEntry)
+
+	l := c.frequencies[entry.frequency]
+	l.Remove(element)
+	if l.Len() == 0 {
+		delete(c.frequencies, entry.frequency)
+	}
+	delete(c.items, entry.key)
+}
+
+// Delete deletes an item from the cache
+func (c *LFUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, found := c.items[key]; found {
+		c.removeElement(element)
+	}
+}
+
+// DeleteExpired deletes all expired items from the cache
+func (c *LFUCache) DeleteExpired() {
+	now := time.Now().UnixNano()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for _, element := range c.items {
+		entry := element.Value.(*lfuEntry)
+		if entry.expiration > 0 && now > entry.expiration {
+			c.removeElement(element)
+			removed++
+		}
+	}
+	c.counters.expiredRemoved(removed)
+}
+
+// Flush deletes all items from the cache
+func (c *LFUCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element, c.capacity)
+	c.frequencies = make(map[int]*list.List)
+	c.minFrequency = 0
+}
+
+// Keys returns the keys of all unexpired items
+func (c *LFUCache) Keys() []string {
+	now := time.Now().UnixNano()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.items))
+	for key, element := range c.items {
+		entry := element.Value.(*lfuEntry)
+		if entry.expiration == 0 || now <= entry.expiration {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Count returns the number of items in the cache
+func (c *LFUCache) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.items)
+}
+
+// Stats returns the cache usage counters
+func (c *LFUCache) Stats() Stats {
+	stats := c.counters.snapshot(c.Count())
+	stats.Capacity = c.capacity
+	return stats
+}
+
+// Shutdown stops the cleanup goroutine
+func (c *LFUCache) Shutdown() {
+	if c.cleanupInterval > 0 {
+		c.stopCleanup <- true
+	}
+}
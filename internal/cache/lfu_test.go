@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLFUCache(t *testing.T) {
+	// Create a new LFU cache with a capacity of 3
+	cache := NewLFUCache(3, time.Minute, 0)
+	defer cache.Shutdown()
+
+	// Add items to the cache
+	cache.Set("key1", "value1")
+	cache.Set("key2", "value2")
+	cache.Set("key3", "value3")
+
+	// Access key1 and key3 so that key2 is the least frequently used
+	cache.Get("key1")
+	cache.Get("key1")
+	cache.Get("key3")
+
+	// Adding another item should evict key2
+	cache.Set("key4", "value4")
+	if _, found := cache.Get("key2"); found {
+		t.Error("Expected 'key2' to be evicted")
+	}
+
+	// The frequently used items should still be in the cache
+	for key, expected := range map[string]string{"key1": "value1", "key3": "value3", "key4": "value4"} {
+		if value, found := cache.Get(key); !found || value != expected {
+			t.Errorf("Expected '%s' for '%s', got %v (found: %v)", expected, key, value, found)
+		}
+	}
+
+	// Ties are broken by recency: key3 and key4 now share the lowest frequency, key4 read last
+	cache.Get("key4")
+	cache.Set("key5", "value5")
+	if _, found := cache.Get("key3"); found {
+		t.Error("Expected 'key3' to be evicted as the least recently used of the least frequently used")
+	}
+
+	// Test updating an existing item
+	cache.Set("key1", "updated")
+	if value, _ := cache.Get("key1"); value != "updated" {
+		t.Errorf("Expected 'updated' for 'key1', got %v", value)
+	}
+
+	// Test Delete followed by an eviction
+	cache.Delete("key5")
+	cache.Set("key6", "value6")
+	cache.Set("key7", "value7")
+	if cache.Count() != 3 {
+		t.Errorf("Expected 3 items, got %d", cache.Count())
+	}
+
+	// Test expiration
+	cache.SetWithExpiration("key8", "value8", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, found := cache.Get("key8"); found {
+		t.Error("Expected 'key8' to be expired")
+	}
+
+	// Test Flush
+	cache.Flush()
+	if cache.Count() != 0 {
+		t.Errorf("Expected cache to be empty, got %d items", cache.Count())
+	}
+
+	stats := cache.Stats()
+	if stats.Evictions == 0 || stats.Expired != 1 {
+		t.Errorf("Expected evictions and 1 expired removal, got %+v", stats)
+	}
+}
@@ -0,0 +1,465 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Codec converts cache values to and from bytes for backends that store them remotely
+type Codec interface {
+	Marshal(value interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+// JSONCodec encodes values as JSON
+// New returns a pointer to a fresh value of the type stored in the cache,
+// so that decoded values have the same type as the values that were set
+type JSONCodec struct {
+	New func() interface{}
+}
+
+// Marshal encodes the value as JSON
+func (c JSONCodec) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Unmarshal decodes JSON into a fresh value created by New
+func (c JSONCodec) Unmarshal(data []byte) (interface{}, error) {
+	if c.New == nil {
+		var value interface{}
+		err := json.Unmarshal(data, &value)
+		return value, err
+	}
+
+	ptr := c.New()
+	if err := json.Unmarshal(data, ptr); err != nil {
+		return nil, err
+	}
+
+	// Dereference the pointer so callers get the value type back
+	return reflect.ValueOf(ptr).Elem().Interface(), nil
+}
+
+// RedisOptions configures a Redis cache backend
+type RedisOptions struct {
+	Addr              string        // host:port of the Redis server
+	Password          string        // optional AUTH password
+	DB                int           // database number selected after connecting
+	KeyPrefix         string        // prefix added to every key, isolating this cache in a shared database
+	DefaultExpiration time.Duration // TTL applied by Set (0 means no expiration)
+	PoolSize          int           // maximum number of idle connections kept open
+	DialTimeout       time.Duration
+	IOTimeout         time.Duration // read/write deadline for a single command
+	Codec             Codec         // value encoding (defaults to JSON)
+	CountInterval     time.Duration // how often Stats recounts the keys in the background (default 10s)
+	Logger            *slog.Logger  // logs failed commands (default slog.Default())
+}
+
+// redisError is an error reply returned by the Redis server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// errRedisNil is returned when a key does not exist
+var errRedisNil = errors.New("redis: nil")
+
+// redisConn is a single connection speaking the RESP protocol
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// RedisCache is a cache backend stored in Redis, letting multiple server
+// replicas share cached values
+type RedisCache struct {
+	options  RedisOptions
+	pool     chan *redisConn
+	counters counters
+
+	// The number of keys, as last counted for Stats
+	entries   atomic.Int64
+	countedAt atomic.Int64 // UnixNano of the last count, 0 if never counted
+	counting  atomic.Bool
+}
+
+// NewRedisCache creates a new Redis cache backend
+// Connections are established lazily, so use Ping to check connectivity
+func NewRedisCache(options RedisOptions) *RedisCache {
+	if options.PoolSize <= 0 {
+		options.PoolSize = 16
+	}
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = 2 * time.Second
+	}
+	if options.IOTimeout <= 0 {
+		options.IOTimeout = time.Second
+	}
+	if options.Codec == nil {
+		options.Codec = JSONCodec{}
+	}
+	if options.CountInterval <= 0 {
+		options.CountInterval = 10 * time.Second
+	}
+	if options.Logger == nil {
+		options.Logger = slog.Default()
+	}
+
+	return &RedisCache{
+		options: options,
+		pool:    make(chan *redisConn, options.PoolSize),
+	}
+}
+
+// dial opens a new connection and authenticates it
+func (c *RedisCache) dial() (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", c.options.Addr, c.options.DialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &redisConn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+	}
+
+	// Authenticate and select the database if configured
+	if c.options.Password != "" {
+		if _, err := rc.do(c.options.IOTimeout, "AUTH", c.options.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.options.DB != 0 {
+		if _, err := rc.do(c.options.IOTimeout, "SELECT", strconv.Itoa(c.options.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return rc, nil
+}
+
+// do sends a command and reads its reply
+func (rc *redisConn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	rc.conn.SetDeadline(time.Now().Add(timeout))
+
+	// Write the command as an array of bulk strings
+	fmt.Fprintf(rc.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(rc.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := rc.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	return rc.readReply()
+}
+
+// readReply reads a single RESP reply
+func (rc *redisConn) readReply() (interface{}, error) {
+	line, err := rc.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		// Simple string
+		return line[1:], nil
+	case '-':
+		// Error reply
+		return nil, redisError(line[1:])
+	case ':':
+		// Integer
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		// Bulk string
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errRedisNil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rc.reader, buf); err != nil {
+			return nil, err
+		}
+		return buf[:size], nil
+	case '*':
+		// Array
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errRedisNil
+		}
+		items := make([]interface{}, size)
+		for i := range items {
+			item, err := rc.readReply()
+			if err != nil && err != errRedisNil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %q", line[0])
+	}
+}
+
+// do runs a command on a pooled connection
+func (c *RedisCache) do(args ...string) (interface{}, error) {
+	// Take an idle connection or open a new one
+	var rc *redisConn
+	select {
+	case rc = <-c.pool:
+	default:
+		var err error
+		rc, err = c.dial()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := rc.do(c.options.IOTimeout, args...)
+	if err != nil && err != errRedisNil {
+		if _, ok := err.(redisError); !ok {
+			// The connection is in an unknown state, drop it
+			rc.conn.Close()
+			return nil, err
+		}
+	}
+
+	// Return the connection to the pool if there is room
+	select {
+	case c.pool <- rc:
+	default:
+		rc.conn.Close()
+	}
+
+	return reply, err
+}
+
+// key returns the namespaced Redis key
+func (c *RedisCache) key(key string) string {
+	return c.options.KeyPrefix + key
+}
+
+// Ping checks that the Redis server is reachable
+func (c *RedisCache) Ping() error {
+	_, err := c.do("PING")
+	return err
+}
+
+// Get gets an item from the cache
+func (c *RedisCache) Get(key string) (interface{}, bool) {
+	value, found := c.Peek(key)
+	if !found {
+		c.counters.miss()
+		return nil, false
+	}
+	c.counters.hit()
+	return value, true
+}
+
+// Peek gets an item from the cache without counting the lookup
+func (c *RedisCache) Peek(key string) (interface{}, bool) {
+	reply, err := c.do("GET", c.key(key))
+	if err == errRedisNil {
+		return nil, false
+	}
+	if err != nil {
+		c.options.Logger.Warn("Redis cache GET failed", "key", key, "error", err)
+		return nil, false
+	}
+
+	value, err := c.options.Codec.Unmarshal(reply.([]byte))
+	if err != nil {
+		c.options.Logger.Warn("Redis cache could not decode a value", "key", key, "error", err)
+		return nil, false
+	}
+	return value, true
+}
+
+// Set adds an item to the cache with the default expiration
+func (c *RedisCache) Set(key string, value interface{}) {
+	c.SetWithExpiration(key, value, c.options.DefaultExpiration)
+}
+
+// SetWithExpiration adds an item to the cache with a specific expiration
+// 0 means the default expiration, and durations under a millisecond are
+// rounded up to one, the shortest TTL Redis accepts
+func (c *RedisCache) SetWithExpiration(key string, value interface{}, d time.Duration) {
+	data, err := c.options.Codec.Marshal(value)
+	if err != nil {
+		c.options.Logger.Warn("Redis cache could not encode a value", "key", key, "error", err)
+		return
+	}
+
+	if d == 0 {
+		d = c.options.DefaultExpiration
+	}
+	args := []string{"SET", c.key(key), string(data)}
+	if d > 0 {
+		ms := (d + time.Millisecond - 1) / time.Millisecond
+		args = append(args, "PX", strconv.FormatInt(int64(ms), 10))
+	}
+
+	if _, err := c.do(args...); err != nil {
+		c.options.Logger.Warn("Redis cache SET failed", "key", key, "error", err)
+	}
+}
+
+// Delete deletes an item from the cache
+func (c *RedisCache) Delete(key string) {
+	if _, err := c.do("DEL", c.key(key)); err != nil {
+		c.options.Logger.Warn("Redis cache DEL failed", "key", key, "error", err)
+	}
+}
+
+// globEscaper escapes the characters that are special in a MATCH pattern
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// scan iterates over all keys belonging to this cache
+func (c *RedisCache) scan(fn func(keys []string) error) error {
+	match := globEscaper.Replace(c.options.KeyPrefix) + "*"
+	cursor := "0"
+	for {
+		reply, err := c.do("SCAN", cursor, "MATCH", match, "COUNT", "500")
+		if err != nil {
+			return err
+		}
+
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 2 {
+			return fmt.Errorf("redis: unexpected SCAN reply")
+		}
+		next, _ := parts[0].([]byte)
+		items, _ := parts[1].([]interface{})
+
+		keys := make([]string, 0, len(items))
+		for _, item := range items {
+			if k, ok := item.([]byte); ok {
+				keys = append(keys, string(k))
+			}
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
+
+// Count returns the number of items in the cache
+// Without a KeyPrefix the cache owns the whole database, so it asks for the
+// size of it, and otherwise it scans the keys under the prefix
+func (c *RedisCache) Count() int {
+	if c.options.KeyPrefix == "" {
+		reply, err := c.do("DBSIZE")
+		if err != nil {
+			c.options.Logger.Warn("Redis cache DBSIZE failed", "error", err)
+			return 0
+		}
+		size, _ := reply.(int64)
+		return int(size)
+	}
+
+	count := 0
+	err := c.scan(func(keys []string) error {
+		count += len(keys)
+		return nil
+	})
+	if err != nil {
+		c.options.Logger.Warn("Redis cache SCAN failed", "error", err)
+	}
+	return count
+}
+
+// Keys returns the keys of all items belonging to this cache, without the prefix
+func (c *RedisCache) Keys() []string {
+	var keys []string
+	err := c.scan(func(batch []string) error {
+		for _, key := range batch {
+			keys = append(keys, strings.TrimPrefix(key, c.options.KeyPrefix))
+		}
+		return nil
+	})
+	if err != nil {
+		c.options.Logger.Warn("Redis cache SCAN failed", "error", err)
+	}
+	return keys
+}
+
+// Flush deletes all items belonging to this cache
+func (c *RedisCache) Flush() {
+	err := c.scan(func(keys []string) error {
+		_, err := c.do(append([]string{"DEL"}, keys...)...)
+		return err
+	})
+	if err != nil {
+		c.options.Logger.Warn("Redis cache flush failed", "error", err)
+	}
+}
+
+// Stats returns the lookup counters observed by this replica
+// Evictions and expirations happen inside Redis and are not tracked. Counting
+// the keys takes a round trip per batch of them, so the entries are the last
+// count, which is redone in the background once it is CountInterval old
+func (c *RedisCache) Stats() Stats {
+	c.recount()
+	return c.counters.snapshot(int(c.entries.Load()))
+}
+
+// recount counts the keys in the background if the last count is stale and
+// no count is running
+func (c *RedisCache) recount() {
+	if time.Since(time.Unix(0, c.countedAt.Load())) < c.options.CountInterval {
+		return
+	}
+	if !c.counting.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer c.counting.Store(false)
+		c.entries.Store(int64(c.Count()))
+		c.countedAt.Store(time.Now().UnixNano())
+	}()
+}
+
+// Shutdown closes all pooled connections
+func (c *RedisCache) Shutdown() {
+	for {
+		select {
+		case rc := <-c.pool:
+			rc.conn.Close()
+		default:
+			return
+		}
+	}
+}
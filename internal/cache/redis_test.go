@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a minimal in-memory server speaking enough RESP for the cache
+type fakeRedis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	data     map[string]string
+	ttls     map[string]string
+}
+
+// newFakeRedis starts a fake Redis server on a random local port
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error starting fake redis: %v", err)
+	}
+
+	server := &fakeRedis{
+		listener: listener,
+		data:     make(map[string]string),
+		ttls:     make(map[string]string),
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+// serve handles commands on a single connection
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		// Read the command array
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			line, _ = reader.ReadString('\n')
+			size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+
+		conn.Write([]byte(f.handle(args)))
+	}
+}
+
+// handle executes a command and returns the encoded reply
+func (f *fakeRedis) handle(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		value, ok := f.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		f.data[args[1]] = args[2]
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			f.ttls[args[1]] = args[4]
+		}
+		return "+OK\r\n"
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := f.data[key]; ok {
+				delete(f.data, key)
+				deleted++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "SCAN":
+		// Return every matching key in a single page, for a pattern of an
+		// escaped prefix followed by *
+		prefix := strings.TrimSuffix(args[3], "*")
+		prefix = strings.NewReplacer(`\\`, `\`, `\*`, "*", `\?`, "?", `\[`, "[", `\]`, "]").Replace(prefix)
+		var keys []string
+		for key := range f.data {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		reply := fmt.Sprintf("*2\r\n$1\r\n0\r\n*%d\r\n", len(keys))
+		for _, key := range keys {
+			reply += fmt.Sprintf("$%d\r\n%s\r\n", len(key), key)
+		}
+		return reply
+	case "DBSIZE":
+		return fmt.Sprintf(":%d\r\n", len(f.data))
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	server := newFakeRedis(t)
+	defer server.listener.Close()
+
+	// Create a cache that decodes values back into string slices
+	cache := NewRedisCache(RedisOptions{
+		Addr:              server.listener.Addr().String(),
+		KeyPrefix:         "test:",
+		DefaultExpiration: time.Minute,
+		Codec: JSONCodec{
+			New: func() interface{} { return new([]string) },
+		},
+	})
+	defer cache.Shutdown()
+
+	// The backend must satisfy the Cache interface
+	var _ Cache = cache
+
+	if err := cache.Ping(); err != nil {
+		t.Fatalf("Expected ping to succeed, got %v", err)
+	}
+
+	// Test Set and Get
+	cache.Set("A:2", []string{"Adam", "Anna"})
+	value, found := cache.Get("A:2")
+	if !found {
+		t.Fatal("Expected 'A:2' to be found")
+	}
+	names, ok := value.([]string)
+	if !ok || len(names) != 2 || names[0] != "Adam" || names[1] != "Anna" {
+		t.Errorf("Expected [Adam Anna], got %#v", value)
+	}
+
+	// Keys are namespaced and carry the default expiration
+	if _, ok := server.data["test:A:2"]; !ok {
+		t.Error("Expected key to be stored with the configured prefix")
+	}
+	if ttl := server.ttls["test:A:2"]; ttl != "60000" {
+		t.Errorf("Expected TTL of 60000ms, got %q", ttl)
+	}
+
+	// A zero duration means the default expiration, and shorter durations
+	// than a millisecond are rounded up to one
+	cache.SetWithExpiration("C:1", []string{"Cole"}, 0)
+	if ttl := server.ttls["test:C:1"]; ttl != "60000" {
+		t.Errorf("Expected the default TTL of 60000ms, got %q", ttl)
+	}
+	cache.SetWithExpiration("D:1", []string{"Dean"}, time.Microsecond)
+	if ttl := server.ttls["test:D:1"]; ttl != "1" {
+		t.Errorf("Expected a TTL of 1ms, got %q", ttl)
+	}
+	cache.SetWithExpiration("E:1", []string{"Eden"}, 1500*time.Microsecond)
+	if ttl := server.ttls["test:E:1"]; ttl != "2" {
+		t.Errorf("Expected a TTL of 2ms, got %q", ttl)
+	}
+	cache.SetWithExpiration("F:1", []string{"Finn"}, -1)
+	if _, ok := server.ttls["test:F:1"]; ok {
+		t.Error("Expected a negative duration to store the key without a TTL")
+	}
+	for _, key := range []string{"C:1", "D:1", "E:1", "F:1"} {
+		cache.Delete(key)
+	}
+
+	// Test that a non-existent key is not found
+	if _, found := cache.Get("B:1"); found {
+		t.Error("Expected 'B:1' to not be found")
+	}
+
+	// Test Count ignores keys outside the prefix
+	server.data["other:key"] = "1"
+	cache.Set("B:1", []string{"Bella"})
+	if count := cache.Count(); count != 2 {
+		t.Errorf("Expected 2 items, got %d", count)
+	}
+
+	// Test Keys strips the prefix
+	keys := cache.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "A:2" || keys[1] != "B:1" {
+		t.Errorf("Expected keys [A:2 B:1], got %v", keys)
+	}
+
+	// Test Delete
+	cache.Delete("A:2")
+	if _, found := cache.Get("A:2"); found {
+		t.Error("Expected 'A:2' to be deleted")
+	}
+
+	// Test Flush only removes this cache's keys
+	cache.Flush()
+	if count := cache.Count(); count != 0 {
+		t.Errorf("Expected cache to be empty after flush, got %d items", count)
+	}
+	if _, ok := server.data["other:key"]; !ok {
+		t.Error("Expected flush to leave keys outside the prefix untouched")
+	}
+}
+
+func TestRedisCacheGlobPrefix(t *testing.T) {
+	server := newFakeRedis(t)
+	defer server.listener.Close()
+
+	// A prefix with glob characters only matches itself
+	cache := NewRedisCache(RedisOptions{Addr: server.listener.Addr().String(), KeyPrefix: "app[1]*:"})
+	defer cache.Shutdown()
+
+	cache.Set("key", "value")
+	server.data["app1x:key"] = "1"
+	if count := cache.Count(); count != 1 {
+		t.Errorf("Expected 1 item under the prefix, got %d", count)
+	}
+	cache.Flush()
+	if _, ok := server.data["app1x:key"]; !ok {
+		t.Error("Expected flush to leave keys matching the prefix as a pattern untouched")
+	}
+}
+
+func TestRedisCacheStats(t *testing.T) {
+	server := newFakeRedis(t)
+	defer server.listener.Close()
+
+	// Without a prefix the keys are counted with DBSIZE
+	cache := NewRedisCache(RedisOptions{Addr: server.listener.Addr().String(), CountInterval: time.Hour})
+	defer cache.Shutdown()
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	if count := cache.Count(); count != 2 {
+		t.Errorf("Expected 2 items, got %d", count)
+	}
+
+	// Stats counts in the background and then reuses the count until it is stale
+	cache.Stats()
+	deadline := time.Now().Add(time.Second)
+	for cache.Stats().Entries != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 2 entries, got %d", cache.Stats().Entries)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cache.Set("c", 3)
+	if entries := cache.Stats().Entries; entries != 2 {
+		t.Errorf("Expected the last count of 2 entries, got %d", entries)
+	}
+}
+
+func TestRedisCacheUnavailable(t *testing.T) {
+	// Reserve a port and close it so nothing is listening
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error reserving port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	cache := NewRedisCache(RedisOptions{Addr: addr, DialTimeout: 100 * time.Millisecond})
+	defer cache.Shutdown()
+
+	// Errors surface through Ping and degrade to cache misses elsewhere
+	if err := cache.Ping(); err == nil {
+		t.Error("Expected ping to fail when redis is unavailable")
+	}
+	cache.Set("key", "value")
+	if _, found := cache.Get("key"); found {
+		t.Error("Expected a miss when redis is unavailable")
+	}
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrCallPanicked is returned to callers waiting on a Group.Do call whose function panicked
+var ErrCallPanicked = errors.New("cache: coalesced call panicked")
+
+// call is an in-flight or completed Group.Do call
+type call struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+	dups  int
+}
+
+// Group coalesces concurrent calls for the same key, so that expensive work
+// such as filling a missing cache entry runs once while other callers wait
+// for its result
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// Do runs fn for the given key, unless a call for the key is already in flight,
+// in which case it waits for that call and returns its result
+// shared reports whether the result was given to more than one caller
+func (g *Group) Do(key string, fn func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+
+	// Wait for the call already in flight
+	if c, found := g.calls[key]; found {
+		c.dups++
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.value, c.err, true
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// Run the call and release the waiters, even if fn panics
+	returned := false
+	defer func() {
+		if !returned {
+			c.err = ErrCallPanicked
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.value, c.err = fn()
+	returned = true
+
+	g.mu.Lock()
+	shared = c.dups > 0
+	g.mu.Unlock()
+
+	return c.value, c.err, shared
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupDo(t *testing.T) {
+	var group Group
+
+	value, err, shared := group.Do("key", func() (interface{}, error) {
+		return "value", nil
+	})
+	if value != "value" || err != nil || shared {
+		t.Errorf("Expected ('value', nil, false), got (%v, %v, %v)", value, err, shared)
+	}
+
+	// Errors are returned to the caller
+	expected := errors.New("failed")
+	if _, err, _ := group.Do("key", func() (interface{}, error) { return nil, expected }); err != expected {
+		t.Errorf("Expected error %v, got %v", expected, err)
+	}
+}
+
+func TestGroupDoCoalesces(t *testing.T) {
+	var group Group
+	var calls int32
+	release := make(chan struct{})
+
+	// Launch many callers for the same key while the first call is blocked
+	var wg sync.WaitGroup
+	results := make(chan interface{}, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, _, _ := group.Do("key", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return "value", nil
+			})
+			results <- value
+		}()
+	}
+
+	// Give the callers time to join the in-flight call
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected the function to run once, ran %d times", n)
+	}
+	for value := range results {
+		if value != "value" {
+			t.Errorf("Expected 'value', got %v", value)
+		}
+	}
+
+	// Once the call has finished, the next call runs again
+	group.Do("key", func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, nil
+	})
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected the function to run again, ran %d times", n)
+	}
+}
+
+func TestGroupDoPanic(t *testing.T) {
+	var group Group
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	// The first caller panics while a second caller is waiting
+	go func() {
+		defer func() { recover() }()
+		group.Do("key", func() (interface{}, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+
+	<-started
+	done := make(chan error)
+	go func() {
+		_, err, _ := group.Do("key", func() (interface{}, error) { return nil, nil })
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-done:
+		if err != ErrCallPanicked {
+			t.Errorf("Expected ErrCallPanicked, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Waiting caller was not released after a panic")
+	}
+}
@@ -0,0 +1,278 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	randv2 "math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// dataset holds the name lists of a single locale, prepared for sampling
+type dataset struct {
+	source     Dataset                // Names and weights the dataset was built from
+	names      map[string][]string    // Names by initial letter
+	letters    []string               // Letters with at least one name, used when no letter is requested
+	weights    map[string][]float64   // Weight of each name by letter, nil for letters without weights
+	cumulative map[string][]float64   // Running sums of the weights, for weighted picks
+	prefixes   map[string]prefixIndex // Sorted names by letter, for prefix searches
+	version    string                 // Hash of the names and weights, see datasetVersion
+}
+
+// prefixIndex lists the names of a letter in case-folded order, so that the names
+// sharing a prefix form a contiguous range that can be found by binary search
+type prefixIndex struct {
+	keys      []string // Case-folded names, sorted
+	positions []int    // Position of each key's name in the letter's name list
+}
+
+// candidates holds the names matching a letter or prefix, with their weights
+type candidates struct {
+	names      []string
+	weights    []float64 // nil when the names are unweighted
+	cumulative []float64 // nil when the names are unweighted
+}
+
+// newDataset prepares a dataset for sampling
+// Duplicate names are dropped so that unique sampling never repeats a name, and
+// so are blocked names, compared case-insensitively with the locale's case rules,
+// which are also used to index the names for prefix searches
+func newDataset(locale string, data Dataset, blocklist map[string]string) dataset {
+	blocked := make(map[string]bool, len(blocklist))
+	for _, name := range blocklist {
+		blocked[foldName(locale, name)] = true
+	}
+
+	prepared := dataset{
+		source:     data,
+		names:      make(map[string][]string, len(data.Names)),
+		letters:    make([]string, 0, len(data.Names)),
+		weights:    make(map[string][]float64),
+		cumulative: make(map[string][]float64),
+		prefixes:   make(map[string]prefixIndex),
+	}
+
+	for letter, list := range data.Names {
+		seen := make(map[string]bool, len(list))
+		distinct := make([]string, 0, len(list))
+		for _, name := range list {
+			if !seen[name] && !blocked[foldName(locale, name)] {
+				seen[name] = true
+				distinct = append(distinct, name)
+			}
+		}
+		if len(distinct) == 0 {
+			continue
+		}
+		prepared.names[letter] = distinct
+		prepared.letters = append(prepared.letters, letter)
+
+		// Only letters with at least one explicit weight are sampled by weight
+		if weights, cumulative := letterWeights(distinct, data.Weights); weights != nil {
+			prepared.weights[letter] = weights
+			prepared.cumulative[letter] = cumulative
+		}
+
+		prepared.prefixes[letter] = newPrefixIndex(locale, distinct)
+	}
+	sort.Strings(prepared.letters)
+	prepared.version = datasetVersion(prepared)
+
+	return prepared
+}
+
+// datasetVersion returns a short hash of the names and weights of a dataset,
+// which changes whenever the names it serves do, blocked names included
+func datasetVersion(d dataset) string {
+	hash := sha256.New()
+	for _, letter := range d.letters {
+		hash.Write([]byte("\x00" + letter + "\n"))
+		weights := d.weights[letter]
+		for i, name := range d.names[letter] {
+			hash.Write([]byte(name + "\t"))
+			if weights != nil {
+				hash.Write([]byte(strconv.FormatFloat(weights[i], 'g', -1, 64)))
+			}
+			hash.Write([]byte("\n"))
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// letterWeights returns the weights of names and their running sums
+// It returns nil when none of the names has a weight, or when all weights are zero
+func letterWeights(names []string, byName map[string]float64) (weights, cumulative []float64) {
+	weighted := false
+	weights = make([]float64, len(names))
+	for i, name := range names {
+		weight, ok := byName[name]
+		if !ok {
+			weight = 1
+		}
+		weighted = weighted || ok
+		weights[i] = weight
+	}
+	if !weighted {
+		return nil, nil
+	}
+
+	cumulative = runningSums(weights)
+	if cumulative == nil {
+		return nil, nil
+	}
+	return weights, cumulative
+}
+
+// runningSums returns the running sums of weights, or nil if they add up to zero
+func runningSums(weights []float64) []float64 {
+	sums := make([]float64, len(weights))
+	total := 0.0
+	for i, weight := range weights {
+		total += weight
+		sums[i] = total
+	}
+	if total == 0 {
+		return nil
+	}
+	return sums
+}
+
+// newPrefixIndex sorts the names of a letter by their case-folded form
+func newPrefixIndex(locale string, names []string) prefixIndex {
+	index := prefixIndex{
+		keys:      make([]string, len(names)),
+		positions: make([]int, len(names)),
+	}
+	for i := range names {
+		index.positions[i] = i
+	}
+
+	folded := make([]string, len(names))
+	for i, name := range names {
+		folded[i] = foldName(locale, name)
+	}
+	sort.SliceStable(index.positions, func(a, b int) bool {
+		return folded[index.positions[a]] < folded[index.positions[b]]
+	})
+	for i, position := range index.positions {
+		index.keys[i] = folded[position]
+	}
+
+	return index
+}
+
+// match returns the names of the dataset starting with prefix, which is a single
+// letter or a longer prefix such as "Ma", already folded with foldName
+// The letter's whole list is returned for single letters without copying
+func (d dataset) match(letter, prefix string) candidates {
+	names := d.names[letter]
+	all := candidates{
+		names:      names,
+		weights:    d.weights[letter],
+		cumulative: d.cumulative[letter],
+	}
+	if len(names) == 0 || utf8.RuneCountInString(prefix) <= 1 {
+		return all
+	}
+
+	// Find the range of folded names starting with the prefix
+	index := d.prefixes[letter]
+	start := sort.SearchStrings(index.keys, prefix)
+	end := start
+	for end < len(index.keys) && strings.HasPrefix(index.keys[end], prefix) {
+		end++
+	}
+	if start == end {
+		return candidates{}
+	}
+
+	// Keep the names in their original order, so results don't depend on the index
+	positions := append([]int(nil), index.positions[start:end]...)
+	sort.Ints(positions)
+
+	matched := candidates{names: make([]string, len(positions))}
+	if all.weights != nil {
+		matched.weights = make([]float64, len(positions))
+	}
+	for i, position := range positions {
+		matched.names[i] = names[position]
+		if all.weights != nil {
+			matched.weights[i] = all.weights[position]
+		}
+	}
+	if matched.weights != nil {
+		matched.cumulative = runningSums(matched.weights)
+		if matched.cumulative == nil {
+			matched.weights = nil
+		}
+	}
+
+	return matched
+}
+
+// random is a source of random numbers to pick names with: a *rand.Rand or
+// seededRand for seeded sequences, which must stay reproducible, or sharedRand
+type random interface {
+	Intn(n int) int
+	Float64() float64
+	Perm(n int) []int
+}
+
+// sharedRand picks random numbers with math/rand/v2, whose generators the
+// runtime keeps per thread, so workers pick names without locking or allocating
+type sharedRand struct{}
+
+func (sharedRand) Intn(n int) int   { return randv2.IntN(n) }
+func (sharedRand) Float64() float64 { return randv2.Float64() }
+func (sharedRand) Perm(n int) []int { return randv2.Perm(n) }
+
+// seededRand picks reproducible random numbers with a math/rand/v2 generator,
+// such as a PCG, which is cheap to seed for every name of a sequence
+type seededRand struct {
+	r *randv2.Rand
+}
+
+func (s seededRand) Intn(n int) int   { return s.r.IntN(n) }
+func (s seededRand) Float64() float64 { return s.r.Float64() }
+func (s seededRand) Perm(n int) []int { return s.r.Perm(n) }
+
+// pickIndex picks a random index in [0, n), proportionally to the weights
+// whose running sums are given, or uniformly if there are none
+func pickIndex(r random, n int, cumulative []float64) int {
+	if cumulative == nil {
+		return r.Intn(n)
+	}
+
+	// Find the first name whose running sum exceeds a random point in the total
+	target := r.Float64() * cumulative[len(cumulative)-1]
+	return sort.Search(len(cumulative), func(i int) bool {
+		return cumulative[i] > target
+	})
+}
+
+// sampleWithoutReplacement picks count distinct indexes in [0, n)
+// With weights, names are drawn proportionally to their weight using the
+// Efraimidis-Spirakis method: each index gets the key u^(1/weight) for a
+// random u, and the indexes with the largest keys are picked
+func sampleWithoutReplacement(r random, n, count int, weights []float64, unweighted bool) []int {
+	if weights == nil || unweighted {
+		return r.Perm(n)[:count]
+	}
+
+	indexes := make([]int, n)
+	keys := make([]float64, n)
+	for i, weight := range weights {
+		indexes[i] = i
+		if weight > 0 {
+			keys[i] = math.Pow(r.Float64(), 1/weight)
+		}
+	}
+
+	sort.Slice(indexes, func(a, b int) bool {
+		return keys[indexes[a]] > keys[indexes[b]]
+	})
+	return indexes[:count]
+}
@@ -2,11 +2,19 @@ package generator
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/rand"
+	randv2 "math/rand/v2"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
+	"github.com/amirahmetzanov/go_project/internal/cache"
 	"github.com/amirahmetzanov/go_project/internal/workerpool"
 )
 
@@ -40,29 +48,339 @@ var NamesByLetter = map[string][]string{
 	"Z": {"Zachary", "Zoe", "Zane", "Zelda", "Zeus", "Zara", "Zion", "Zara", "Zack", "Zahara", "Zeke", "Zella", "Zev", "Zinnia", "Zen", "Zendaya", "Zavier", "Zia", "Zach", "Zuri"},
 }
 
+// ErrUnknownLetter is returned when no names match the requested letter, prefix or locale
+var ErrUnknownLetter = errors.New("generator: no names for letter")
+
+// ErrPoolSaturated is returned when names couldn't be picked because the worker
+// pool's queue was full or the pool was shut down
+var ErrPoolSaturated = errors.New("generator: worker pool saturated")
+
+// Options holds optional generation parameters
+type Options struct {
+	Locale     string // Dataset to generate from, DefaultLocale if empty
+	Unique     bool   // Return each name at most once, truncating count to the number of names available
+	Unweighted bool   // Ignore popularity weights and pick every name with the same probability
+	Seed       int64  // Non-zero seeds make the names a deterministic sequence, which can be paged through
+	Offset     int    // Position in the seeded sequence of the first name to return
+	
+	// UniformLetter picks a random letter, when none is given, with the same
+	// probability for every letter rather than by frequency, e.g. for tests
+	UniformLetter bool
+}
+
 // NameGenerator holds the worker pool for name generation
 type NameGenerator struct {
 	pool              *workerpool.WorkerPool
+	datasetsMutex     sync.RWMutex
+	datasets          map[string]dataset            // Name lists by locale, NamesByLocale unless replaced
+	blocklist         map[string]string             // Names never to return by their lowercase form, guarded by datasetsMutex
+	blocklistVersion  uint64                        // Counts the changes to the blocklist, guarded by datasetsMutex
+	namesDir          string                        // Directory the names were last loaded from, guarded by datasetsMutex
+	letterFrequencies map[string]map[string]float64 // Shares of the letters by locale, guarded by datasetsMutex
 	nameCacheMutex    sync.RWMutex
-	nameCache         map[string][]string // Cache for previously generated names
+	nameCache         cache.Cache   // Cache for previously generated names, nil unless set with SetCache
+	nameCacheVersion  atomic.Uint64 // Part of the cache keys, bumped to invalidate all cached names
 	nameGeneratorSeed int64
 }
 
 // NewNameGenerator creates a new name generator with a worker pool
-func NewNameGenerator(numWorkers int) *NameGenerator {
+// The options configure the pool, e.g. workerpool.WithAutoscaling
+func NewNameGenerator(numWorkers int, opts ...workerpool.Option) *NameGenerator {
 	// Create a new worker pool
-	pool := workerpool.New(numWorkers)
+	pool := workerpool.New(numWorkers, opts...)
 	
 	// Create a new name generator
 	generator := &NameGenerator{
 		pool:              pool,
-		nameCache:         make(map[string][]string),
+		datasets:          make(map[string]dataset, len(NamesByLocale)),
+		blocklist:         make(map[string]string),
 		nameGeneratorSeed: time.Now().UnixNano(),
+		letterFrequencies: make(map[string]map[string]float64, len(LetterFrequencies)),
+	}
+	for locale, frequencies := range LetterFrequencies {
+		generator.letterFrequencies[locale] = frequencies
+	}
+	for locale, names := range NamesByLocale {
+		generator.datasets[locale] = newDataset(locale, Dataset{Names: names}, nil)
 	}
 	
 	return generator
 }
 
+// SetNames replaces the name lists of the default locale
+// Passing nil restores the built-in NamesByLetter lists
+func (g *NameGenerator) SetNames(names map[string][]string) {
+	g.SetLocaleNames(DefaultLocale, names)
+}
+
+// SetLocaleNames replaces the name lists of a locale, adding the locale if it is new
+// Passing nil restores the built-in lists of the locale, or removes it if there are none
+func (g *NameGenerator) SetLocaleNames(locale string, names map[string][]string) {
+	g.SetLocaleDataset(locale, Dataset{Names: names})
+}
+
+// SetLocaleDataset replaces the names and weights of a locale, adding the locale if it is new
+// A dataset without names restores the built-in lists of the locale, or removes it if there are none
+func (g *NameGenerator) SetLocaleDataset(locale string, data Dataset) {
+	locale, language := normalizeLocale(locale)
+	if data.Names == nil {
+		data = Dataset{Names: NamesByLocale[locale]}
+	}
+	
+	g.datasetsMutex.Lock()
+	if data.Names == nil {
+		delete(g.datasets, locale)
+	} else {
+		g.datasets[locale] = newDataset(language, data, g.blocklist)
+	}
+	g.datasetsMutex.Unlock()
+	
+	// Previously generated names may no longer be in the dataset
+	g.clearNameCache()
+}
+
+// LoadNames replaces name lists with the ones found in dir (see LoadLocales)
+// Locales without files keep their current lists, and nothing changes if the
+// directory can't be loaded. Reload reads dir again
+func (g *NameGenerator) LoadNames(dir string) error {
+	locales, err := LoadLocales(dir)
+	if err != nil {
+		return err
+	}
+	
+	for locale, data := range locales {
+		g.SetLocaleDataset(locale, data)
+	}
+	g.datasetsMutex.Lock()
+	g.namesDir = dir
+	g.datasetsMutex.Unlock()
+	return nil
+}
+
+// SetBlocklist replaces the names that are never returned, in any locale
+// Names are compared case-insensitively, and only the first spelling of a
+// name is kept
+func (g *NameGenerator) SetBlocklist(names []string) {
+	g.datasetsMutex.Lock()
+	g.blocklist = make(map[string]string, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if key := strings.ToLower(name); name != "" && g.blocklist[key] == "" {
+			g.blocklist[key] = name
+		}
+	}
+	g.blocklistVersion++
+	g.rebuildDatasets()
+	g.datasetsMutex.Unlock()
+	
+	g.clearNameCache()
+}
+
+// Block adds names to the blocklist and returns the ones that weren't on it yet,
+// in any case
+func (g *NameGenerator) Block(names ...string) []string {
+	g.datasetsMutex.Lock()
+	var added []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if key := strings.ToLower(name); name != "" && g.blocklist[key] == "" {
+			g.blocklist[key] = name
+			added = append(added, name)
+		}
+	}
+	if len(added) > 0 {
+		g.blocklistVersion++
+		g.rebuildDatasets()
+	}
+	g.datasetsMutex.Unlock()
+	
+	if len(added) > 0 {
+		g.clearNameCache()
+	}
+	return added
+}
+
+// Blocklist returns the blocked names in sorted order
+func (g *NameGenerator) Blocklist() []string {
+	g.datasetsMutex.RLock()
+	defer g.datasetsMutex.RUnlock()
+	
+	names := make([]string, 0, len(g.blocklist))
+	for _, name := range g.blocklist {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rebuildDatasets filters all datasets with the current blocklist
+// The caller must hold datasetsMutex for writing
+func (g *NameGenerator) rebuildDatasets() {
+	for locale, data := range g.datasets {
+		_, language := normalizeLocale(locale)
+		g.datasets[locale] = newDataset(language, data.source, g.blocklist)
+	}
+}
+
+// SetCache sets the cache for generated names, so that repeated requests for the
+// same letter and count return the same names
+// The cache may be shared with other users, since the keys start with "names:"
+// Passing nil disables caching, which is the default
+func (g *NameGenerator) SetCache(c cache.Cache) {
+	g.nameCacheMutex.Lock()
+	g.nameCache = c
+	g.nameCacheMutex.Unlock()
+	g.nameCacheVersion.Add(1)
+}
+
+// getNameCache returns the cache for generated names, or nil
+func (g *NameGenerator) getNameCache() cache.Cache {
+	g.nameCacheMutex.RLock()
+	defer g.nameCacheMutex.RUnlock()
+	return g.nameCache
+}
+
+// nameCachePrefix returns the prefix of the keys of the currently valid cached names
+func (g *NameGenerator) nameCachePrefix() string {
+	return "names:" + strconv.FormatUint(g.nameCacheVersion.Load(), 10) + ":"
+}
+
+// clearNameCache drops previously generated names, which may no longer be valid
+// Bumping the version invalidates them in any cache, and caches that can be
+// inspected also free their memory right away
+func (g *NameGenerator) clearNameCache() {
+	g.nameCacheVersion.Add(1)
+	c := g.getNameCache()
+	if inspector, ok := c.(cache.Inspector); ok {
+		for _, key := range inspector.Keys() {
+			if strings.HasPrefix(key, "names:") {
+				c.Delete(key)
+			}
+		}
+	}
+}
+
+// SetLetterFrequencies sets the shares of names starting with each letter of a
+// locale, used to pick a letter when none is requested (see LetterFrequencies)
+// Letters are matched with the case rules of the locale, and letters without a
+// share are never picked. Passing nil restores the built-in shares, or the total
+// weight of the names of each letter if the locale has none
+func (g *NameGenerator) SetLetterFrequencies(locale string, frequencies map[string]float64) {
+	locale, language := normalizeLocale(locale)
+	
+	g.datasetsMutex.Lock()
+	defer g.datasetsMutex.Unlock()
+	if frequencies == nil {
+		if builtin, ok := LetterFrequencies[locale]; ok {
+			g.letterFrequencies[locale] = builtin
+		} else {
+			delete(g.letterFrequencies, locale)
+		}
+		return
+	}
+	shares := make(map[string]float64, len(frequencies))
+	for letter, share := range frequencies {
+		shares[initialLetter(language, strings.TrimSpace(letter))] += max(share, 0)
+	}
+	g.letterFrequencies[locale] = shares
+}
+
+// letterCumulative returns the running sums of the shares of the letters of a
+// dataset, in the order of its letters, or nil to pick letters uniformly
+func (g *NameGenerator) letterCumulative(locale string, data dataset) []float64 {
+	g.datasetsMutex.RLock()
+	frequencies, ok := g.letterFrequencies[locale]
+	g.datasetsMutex.RUnlock()
+	
+	cumulative := make([]float64, len(data.letters))
+	sum := 0.0
+	for i, letter := range data.letters {
+		switch {
+		case ok:
+			sum += frequencies[letter]
+		case data.cumulative[letter] != nil:
+			// The total weight of the names
+			sum += data.cumulative[letter][len(data.cumulative[letter])-1]
+		default:
+			// Names without weights count as 1
+			sum += float64(len(data.names[letter]))
+		}
+		cumulative[i] = sum
+	}
+	if sum <= 0 {
+		return nil
+	}
+	return cumulative
+}
+
+// Locales returns the supported locales in sorted order
+func (g *NameGenerator) Locales() []string {
+	g.datasetsMutex.RLock()
+	defer g.datasetsMutex.RUnlock()
+	
+	locales := make([]string, 0, len(g.datasets))
+	for locale := range g.datasets {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// LocaleStats describes the names loaded for a locale
+type LocaleStats struct {
+	Locale   string
+	Version  string         // Hash of the names and weights, which changes whenever they do
+	Names    int            // Names of all letters
+	Letters  map[string]int // Names by initial letter
+	Weighted bool           // Whether the names of some letters are picked by weight
+}
+
+// DatasetStats describes the names loaded for each locale, sorted by locale
+func (g *NameGenerator) DatasetStats() []LocaleStats {
+	g.datasetsMutex.RLock()
+	defer g.datasetsMutex.RUnlock()
+	
+	stats := make([]LocaleStats, 0, len(g.datasets))
+	for locale, data := range g.datasets {
+		entry := LocaleStats{
+			Locale:   locale,
+			Version:  data.version,
+			Letters:  make(map[string]int, len(data.letters)),
+			Weighted: len(data.weights) > 0,
+		}
+		for _, letter := range data.letters {
+			entry.Letters[letter] = len(data.names[letter])
+			entry.Names += len(data.names[letter])
+		}
+		stats = append(stats, entry)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Locale < stats[j].Locale
+	})
+	return stats
+}
+
+// ResolveLocale maps a requested locale such as "de-DE" to a supported one
+// An empty locale resolves to DefaultLocale
+func (g *NameGenerator) ResolveLocale(locale string) (string, bool) {
+	if strings.TrimSpace(locale) == "" {
+		return DefaultLocale, true
+	}
+	
+	tag, language := normalizeLocale(locale)
+	
+	g.datasetsMutex.RLock()
+	defer g.datasetsMutex.RUnlock()
+	
+	if _, ok := g.datasets[tag]; ok {
+		return tag, true
+	}
+	if _, ok := g.datasets[language]; ok {
+		return language, true
+	}
+	return "", false
+}
+
 // DefaultGenerator is the default global name generator instance
 var (
 	DefaultGenerator     *NameGenerator
@@ -82,22 +400,22 @@ func GetDefaultGenerator() *NameGenerator {
 
 // getCacheKey returns a cache key for the given letter and count
 func getCacheKey(letter string, count int) string {
-	return letter + ":" + string(rune(count))
+	return letter + ":" + strconv.Itoa(count)
 }
 
 // GenerateNames generates a list of random names starting with the specified letter
 // This is now just a wrapper around the default generator
-func GenerateNames(letter string, count int) []string {
+func GenerateNames(letter string, count int) ([]string, error) {
 	return GetDefaultGenerator().Generate(letter, count)
 }
 
 // GenerateNamesWithContext generates names with a context for cancellation
-func GenerateNamesWithContext(ctx context.Context, letter string, count int) []string {
+func GenerateNamesWithContext(ctx context.Context, letter string, count int) ([]string, error) {
 	return GetDefaultGenerator().GenerateWithContext(ctx, letter, count)
 }
 
 // Generate generates a list of random names starting with the specified letter
-func (g *NameGenerator) Generate(letter string, count int) []string {
+func (g *NameGenerator) Generate(letter string, count int) ([]string, error) {
 	// Create a default context with a reasonable timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -106,66 +424,66 @@ func (g *NameGenerator) Generate(letter string, count int) []string {
 }
 
 // GenerateWithContext generates a list of random names with a context for cancellation
-func (g *NameGenerator) GenerateWithContext(ctx context.Context, letter string, count int) []string {
+func (g *NameGenerator) GenerateWithContext(ctx context.Context, letter string, count int) ([]string, error) {
+	return g.GenerateWithOptions(ctx, letter, count, Options{})
+}
+
+// GenerateWithOptions generates a list of random names using the given options
+// letter may also be a longer prefix such as "Ma", matched case-insensitively
+// The names are picked with the priority of ctx (see workerpool.WithPriority)
+// Letters, prefixes and locales without names fail with ErrUnknownLetter. When
+// ctx is done or the pool can't take the tasks (ErrPoolSaturated), the names
+// picked so far are returned with the error
+func (g *NameGenerator) GenerateWithOptions(ctx context.Context, letter string, count int, opts Options) ([]string, error) {
 	// If count is zero or negative, return empty slice
 	if count <= 0 {
-		return []string{}
+		return []string{}, nil
 	}
 	
-	// If no letter is specified, choose one randomly
-	if letter == "" {
-		letters := []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M", "N", "O", "P", "Q", "R", "S", "T", "U", "V", "W", "X", "Y", "Z"}
-		letter = letters[rand.Intn(len(letters))]
-	} else {
-		// Convert letter to uppercase
-		letter = strings.ToUpper(string(letter[0]))
+	// Find the names for the letter or prefix
+	matches, locale, query, ok := g.match(letter, opts)
+	if !ok {
+		return []string{}, ErrUnknownLetter
 	}
+	namesList := matches.names
 	
-	// Get the list of names for the specified letter
-	namesList, ok := NamesByLetter[letter]
-	if !ok || len(namesList) == 0 {
-		// If no names exist for this letter, return an empty slice
-		return []string{}
+	// Seeded sequences are reproducible and aren't cached
+	if opts.Seed != 0 {
+		return g.generateSeeded(ctx, matches, count, opts)
 	}
 	
 	// If count is greater than the available names, limit it
-	if count > len(namesList) {
-		count = len(namesList)
-	}
+	count = pageSize(len(namesList), count, opts)
 	
 	// Check if the names are already in the cache
-	cacheKey := getCacheKey(letter, count)
-	g.nameCacheMutex.RLock()
-	cachedNames, found := g.nameCache[cacheKey]
-	g.nameCacheMutex.RUnlock()
-	
-	if found && len(cachedNames) >= count {
-		// Return a copy of the cached names to avoid data races
-		result := make([]string, count)
-		copy(result, cachedNames[:count])
-		return result
+	nameCache := g.getNameCache()
+	cacheKey := g.nameCachePrefix() + locale + ":" + getCacheKey(query, count)
+	if opts.Unique {
+		cacheKey += ":unique"
+	}
+	if opts.Unweighted {
+		cacheKey += ":unweighted"
+	}
+	if nameCache != nil {
+		value, found := nameCache.Get(cacheKey)
+		if cachedNames, ok := value.([]string); found && ok && len(cachedNames) >= count {
+			// Return a copy of the cached names to avoid data races
+			result := make([]string, count)
+			copy(result, cachedNames[:count])
+			return result, nil
+		}
 	}
 	
 	// Generate random names in parallel using the worker pool
 	names := make([]string, count)
-	tasks := make([]workerpool.Task, count)
-	
-	// Create a task for each name generation
-	for i := 0; i < count; i++ {
-		index := i // Capture the index in the closure
-		tasks[i] = func() interface{} {
-			// Create a source of randomness that's isolated to this task
-			taskRand := rand.New(rand.NewSource(time.Now().UnixNano() + int64(index)))
-			randomIndex := taskRand.Intn(len(namesList))
-			return namesList[randomIndex]
-		}
-	}
+	tasks := nameTasks(matches, count, opts)
 	
 	// Submit tasks in batch and get results
-	resultCh := g.pool.SubmitBatch(tasks)
+	resultCh := workerpool.SubmitBatch(ctx, g.pool, tasks)
 	
 	// Process results as they come in
 	i := 0
+	var err error
 	for result := range resultCh {
 		if i >= count {
 			break
@@ -175,26 +493,284 @@ func (g *NameGenerator) GenerateWithContext(ctx context.Context, letter string,
 		select {
 		case <-ctx.Done():
 			// Context canceled, return what we have so far
-			return names[:i]
+			return names[:i], ctx.Err()
 		default:
 			// Continue processing
 		}
 		
-		// Get the name from the result
-		name, ok := result.Value.(string)
-		if ok {
-			names[i] = name
-			i++
+		// Get the name from the result, keeping the first failure
+		if result.Err != nil {
+			if err == nil {
+				err = taskError(ctx, result.Err)
+			}
+			continue
 		}
+		names[i] = result.Value.name
+		i++
+	}
+	if i < count {
+		return names[:i], err
 	}
 	
 	// Update the cache with the generated names
-	g.nameCacheMutex.Lock()
-	g.nameCache[cacheKey] = make([]string, len(names))
-	copy(g.nameCache[cacheKey], names)
-	g.nameCacheMutex.Unlock()
+	if nameCache != nil {
+		cachedNames := make([]string, count)
+		copy(cachedNames, names)
+		nameCache.Set(cacheKey, cachedNames)
+	}
 	
-	return names
+	return names, nil
+}
+
+// taskError returns the error a name generation fails with when one of its
+// tasks failed with err
+// Rejected tasks mean the pool is saturated, and timed out ones that the pool's
+// task timeout has passed
+func taskError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, workerpool.ErrQueueFull), errors.Is(err, workerpool.ErrPoolClosed):
+		return fmt.Errorf("%w: %w", ErrPoolSaturated, err)
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case errors.Is(err, workerpool.ErrTaskTimeout):
+		return fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+// Stream generates names like GenerateWithOptions, but sends each name on the
+// returned channel as soon as the worker pool produces it, so callers can start
+// using the first names before the last ones are picked
+// Names of seeded sequences arrive in sequence order, other names in any order.
+// If no names match, or a name can't be picked, the last result carries the
+// error (ErrUnknownLetter, ErrPoolSaturated or the error of ctx) instead of a
+// name. The channel is closed when all names were sent, after an error, or when
+// ctx is done. Streamed names aren't cached
+func (g *NameGenerator) Stream(ctx context.Context, letter string, count int, opts Options) <-chan workerpool.ResultOf[string] {
+	namesCh := make(chan workerpool.ResultOf[string], 1)
+	if count <= 0 {
+		close(namesCh)
+		return namesCh
+	}
+	
+	matches, _, _, ok := g.match(letter, opts)
+	if !ok {
+		namesCh <- workerpool.ResultOf[string]{Err: ErrUnknownLetter}
+		close(namesCh)
+		return namesCh
+	}
+	count = pageSize(len(matches.names), count, opts)
+	tasks := nameTasks(matches, count, opts)
+	
+	go func() {
+		defer close(namesCh)
+		
+		// send delivers a result unless the caller has gone away
+		send := func(result workerpool.ResultOf[string]) bool {
+			select {
+			case namesCh <- result:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		
+		// Seeded names are held back until the names before them have been sent
+		next := 0
+		pending := make(map[int]string)
+		for result := range workerpool.SubmitBatch(ctx, g.pool, tasks) {
+			if result.Err != nil {
+				// The names after a failed one would leave a gap in the sequence
+				send(workerpool.ResultOf[string]{Err: taskError(ctx, result.Err)})
+				return
+			}
+			picked := result.Value
+			if opts.Seed == 0 {
+				if !send(workerpool.ResultOf[string]{Value: picked.name}) {
+					return
+				}
+				continue
+			}
+			
+			pending[picked.index] = picked.name
+			for name, ok := pending[next]; ok; name, ok = pending[next] {
+				if !send(workerpool.ResultOf[string]{Value: name}) {
+					return
+				}
+				delete(pending, next)
+				next++
+			}
+		}
+	}()
+	
+	return namesCh
+}
+
+// match returns the names matching a letter or prefix in the dataset of opts.Locale,
+// along with the resolved locale and the letter or folded prefix that was matched
+// A random letter is chosen if letter is empty
+func (g *NameGenerator) match(letter string, opts Options) (matches candidates, locale, query string, ok bool) {
+	// Find the dataset for the locale
+	locale, ok = g.ResolveLocale(opts.Locale)
+	if !ok {
+		return candidates{}, "", "", false
+	}
+	_, language := normalizeLocale(locale)
+	g.datasetsMutex.RLock()
+	data := g.datasets[locale]
+	g.datasetsMutex.RUnlock()
+	
+	// If no letter is specified, choose one randomly
+	prefix := ""
+	if letter == "" {
+		if len(data.letters) == 0 {
+			return candidates{}, "", "", false
+		}
+		var letterRand random = sharedRand{}
+		if opts.Seed != 0 {
+			letterRand = rand.New(rand.NewSource(opts.Seed))
+		}
+		var cumulative []float64
+		if !opts.UniformLetter {
+			cumulative = g.letterCumulative(locale, data)
+		}
+		letter = data.letters[pickIndex(letterRand, len(data.letters), cumulative)]
+	} else {
+		// Convert letter to uppercase using the rules of the locale
+		prefix = foldName(language, strings.TrimSpace(letter))
+		letter = initialLetter(language, strings.TrimSpace(letter))
+	}
+	
+	// Get the list of names for the specified letter or prefix
+	matches = data.match(letter, prefix)
+	if len(matches.names) == 0 {
+		return candidates{}, "", "", false
+	}
+	
+	// Longer prefixes are told apart from their letter
+	query = letter
+	if utf8.RuneCountInString(prefix) > 1 {
+		query = prefix
+	}
+	
+	return matches, locale, query, true
+}
+
+// pageSize returns how many of count names can be generated from n matching names
+// Random names are limited to n, and unique names of a seeded sequence to the
+// ones left after opts.Offset. Other seeded sequences have no end
+func pageSize(n, count int, opts Options) int {
+	switch {
+	case opts.Seed != 0 && opts.Unique:
+		left := n - opts.Offset
+		if left < 0 {
+			left = 0
+		}
+		if count > left {
+			return left
+		}
+	case opts.Seed == 0 && count > n:
+		return n
+	}
+	return count
+}
+
+// nameTasks returns a task for each of count names to pick from matches
+func nameTasks(matches candidates, count int, opts Options) []func() (pickedName, error) {
+	namesList := matches.names
+	tasks := make([]func() (pickedName, error), count)
+	
+	// Popularity weights of the names, unless the request asks for uniform sampling
+	var cumulative []float64
+	if !opts.Unweighted {
+		cumulative = matches.cumulative
+	}
+	
+	// For unique names, draw the whole sample without replacement up front
+	// Seeded sequences always draw the same permutation of all names and page through it
+	var sample []int
+	if opts.Unique && opts.Seed != 0 {
+		sample = sampleWithoutReplacement(rand.New(rand.NewSource(opts.Seed)), len(namesList), len(namesList), matches.weights, opts.Unweighted)
+		sample = sample[opts.Offset:]
+	} else if opts.Unique {
+		sample = sampleWithoutReplacement(sharedRand{}, len(namesList), count, matches.weights, opts.Unweighted)
+	}
+	
+	// Create a task for each name generation
+	for i := 0; i < count; i++ {
+		index := i // Capture the index in the closure
+		if sample != nil {
+			tasks[i] = func() (pickedName, error) {
+				return pickedName{index: index, name: namesList[sample[index]]}, nil
+			}
+			continue
+		}
+		tasks[i] = func() (pickedName, error) {
+			// Names of seeded sequences only depend on the seed and their position
+			// Others come from the generators of the workers' threads
+			var taskRand random = sharedRand{}
+			if opts.Seed != 0 {
+				taskRand = positionRand(opts.Seed, opts.Offset+index)
+			}
+			return pickedName{index: index, name: namesList[pickIndex(taskRand, len(namesList), cumulative)]}, nil
+		}
+	}
+	
+	return tasks
+}
+
+// generateSeeded returns count names of the sequence determined by opts.Seed,
+// starting at opts.Offset
+// Each name only depends on the seed and its position, so pages of the sequence
+// can be generated independently. Unique sequences are a fixed permutation of the
+// matching names and end after the last of them
+func (g *NameGenerator) generateSeeded(ctx context.Context, matches candidates, count int, opts Options) ([]string, error) {
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+	count = pageSize(len(matches.names), count, opts)
+	if count == 0 {
+		return []string{}, nil
+	}
+	
+	// Results arrive in any order, so each carries its index in the page
+	names := make([]string, count)
+	var wg sync.WaitGroup
+	var failed atomic.Pointer[error]
+	done := func(result workerpool.ResultOf[pickedName]) {
+		defer wg.Done()
+		if result.Err != nil {
+			err := taskError(ctx, result.Err)
+			failed.CompareAndSwap(nil, &err)
+			return
+		}
+		names[result.Value.index] = result.Value.name
+	}
+	wg.Add(count)
+	for _, task := range nameTasks(matches, count, opts) {
+		workerpool.SubmitFunc(ctx, g.pool, task, done)
+	}
+	wg.Wait()
+	
+	// A partial page would have gaps, so return nothing
+	if err := failed.Load(); err != nil {
+		return []string{}, *err
+	}
+	
+	return names, nil
+}
+
+// pickedName is a name picked by a task, with the task's index
+type pickedName struct {
+	index int
+	name  string
+}
+
+// positionRand returns a source of randomness for a position in a seeded sequence
+// A PCG only holds two words of state, unlike the sources of math/rand, so
+// seeding one per name stays cheap
+func positionRand(seed int64, position int) random {
+	return seededRand{randv2.New(randv2.NewPCG(uint64(seed), uint64(position)))}
 }
 
 // Shutdown gracefully shuts down the name generator's worker pool
@@ -202,6 +778,43 @@ func (g *NameGenerator) Shutdown() {
 	g.pool.Shutdown()
 }
 
+// Queued returns the number of generation tasks waiting for a worker
+func (g *NameGenerator) Queued() int {
+	return g.pool.Queued()
+}
+
+// Workers returns the number of workers of the name generator's pool
+func (g *NameGenerator) Workers() int {
+	return g.pool.Workers()
+}
+
+// ScaleEvents returns how many times the name generator's pool added and
+// stopped workers
+func (g *NameGenerator) ScaleEvents() (up, down uint64) {
+	return g.pool.ScaleEvents()
+}
+
+// Panics returns how many tasks of the name generator's pool panicked
+func (g *NameGenerator) Panics() uint64 {
+	return g.pool.Panics()
+}
+
+// Timeouts returns how many tasks of the name generator's pool ran longer than
+// the pool's task timeout
+func (g *NameGenerator) Timeouts() uint64 {
+	return g.pool.Timeouts()
+}
+
+// Stats returns the load of the name generator's pool and totals of its tasks
+func (g *NameGenerator) Stats() workerpool.Stats {
+	return g.pool.Stats()
+}
+
+// Running reports whether the name generator's worker pool is still running
+func (g *NameGenerator) Running() bool {
+	return g.pool.Running()
+}
+
 // ShutdownNow immediately shuts down the name generator's worker pool
 func (g *NameGenerator) ShutdownNow() {
 	g.pool.ShutdownNow()
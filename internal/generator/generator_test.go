@@ -2,10 +2,16 @@ package generator
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+	"github.com/amirahmetzanov/go_project/internal/workerpool"
 )
 
 func TestGenerateNames(t *testing.T) {
@@ -55,7 +61,7 @@ func TestGenerateNames(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := GenerateNames(tt.letter, tt.count)
+			got, _ := GenerateNames(tt.letter, tt.count)
 			
 			// Check the count
 			if len(got) != tt.wantCount {
@@ -112,12 +118,15 @@ func TestGenerateWithContext(t *testing.T) {
 		cancel() // Cancel immediately
 		
 		// Try to generate names with the canceled context
-		names := generator.GenerateWithContext(ctx, "A", 100)
+		names, err := generator.GenerateWithContext(ctx, "A", 100)
 		
-		// Should return an empty slice or a partial result
+		// Should return an empty slice or a partial result, with the context's error
 		if len(names) >= 100 {
 			t.Errorf("Expected context cancellation to limit results, got %d names", len(names))
 		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
 	})
 	
 	// Test context timeout
@@ -127,27 +136,47 @@ func TestGenerateWithContext(t *testing.T) {
 		defer cancel()
 		
 		// Try to generate names with the timed-out context
-		names := generator.GenerateWithContext(ctx, "A", 10000) // Use a large number to ensure it takes longer than the timeout
+		names, _ := generator.GenerateWithContext(ctx, "A", 10000) // Use a large number to ensure it takes longer than the timeout
 		
 		// Should return a partial result
 		if len(names) >= 10000 {
 			t.Errorf("Expected context timeout to limit results, got %d names", len(names))
 		}
 	})
+	
+	// Test unknown letters
+	t.Run("UnknownLetter", func(t *testing.T) {
+		names, err := generator.GenerateWithContext(context.Background(), "Ø", 5)
+		if len(names) != 0 || !errors.Is(err, ErrUnknownLetter) {
+			t.Errorf("Expected ErrUnknownLetter, got %v and %v", names, err)
+		}
+	})
+	
+	// Test a saturated pool
+	t.Run("PoolSaturated", func(t *testing.T) {
+		stopped := NewNameGenerator(1)
+		stopped.Shutdown()
+		names, err := stopped.GenerateWithContext(context.Background(), "A", 5)
+		if len(names) != 0 || !errors.Is(err, ErrPoolSaturated) {
+			t.Errorf("Expected ErrPoolSaturated, got %v and %v", names, err)
+		}
+	})
 }
 
 func TestCaching(t *testing.T) {
 	// Create a new name generator
 	generator := NewNameGenerator(4)
 	defer generator.Shutdown()
+	nameCache := cache.NewLRUCache(10, time.Minute, 0)
+	generator.SetCache(nameCache)
 	
 	// Generate names first time
 	letter := "C"
 	count := 10
-	firstNames := generator.Generate(letter, count)
+	firstNames, _ := generator.Generate(letter, count)
 	
 	// Generate names second time with same parameters
-	secondNames := generator.Generate(letter, count)
+	secondNames, _ := generator.Generate(letter, count)
 	
 	// Check that the results are the same (from cache)
 	if len(firstNames) != len(secondNames) {
@@ -161,6 +190,341 @@ func TestCaching(t *testing.T) {
 	}
 }
 
+func TestCachingCounts(t *testing.T) {
+	generator := NewNameGenerator(4)
+	defer generator.Shutdown()
+	nameCache := cache.NewLRUCache(2, time.Minute, 0)
+	generator.SetCache(nameCache)
+	
+	// Counts are spelled out in the keys, so that every count has its own entry
+	if key := getCacheKey("C", 0xD800); key != "C:55296" {
+		t.Errorf("Expected key C:55296, got %q", key)
+	}
+	generator.Generate("C", 1)
+	generator.Generate("C", 10)
+	if nameCache.Count() != 2 {
+		t.Errorf("Expected 2 cached entries, got %v", nameCache.Keys())
+	}
+	
+	// The cache stays bounded by its capacity
+	generator.Generate("D", 5)
+	if nameCache.Count() != 2 {
+		t.Errorf("Expected the cache to keep 2 entries, got %v", nameCache.Keys())
+	}
+	
+	// Changing the names drops the cached ones
+	generator.Block("Zoe")
+	if nameCache.Count() != 0 {
+		t.Errorf("Expected no cached entries after a blocklist change, got %v", nameCache.Keys())
+	}
+	
+	// Without a cache, the names are generated again every time
+	generator.SetCache(nil)
+	for i := 0; i < 10; i++ {
+		if names, _ := generator.Generate("C", 3); len(names) != 3 {
+			t.Fatalf("Expected 3 names, got %v", names)
+		}
+	}
+}
+
+func TestGenerateUnique(t *testing.T) {
+	generator := NewNameGenerator(4)
+	defer generator.Shutdown()
+	
+	tests := []struct {
+		letter    string
+		count     int
+		wantCount int
+	}{
+		{"A", 20, 20},
+		{"B", 5, 5},
+		{"C", 50, 20}, // Truncated to the number of names available
+		{"Z", 20, 19}, // The built-in Z list contains Zara twice
+	}
+	
+	for _, tt := range tests {
+		names, _ := generator.GenerateWithOptions(context.Background(), tt.letter, tt.count, Options{Unique: true})
+		if len(names) != tt.wantCount {
+			t.Errorf("Expected %d unique %s names, got %d", tt.wantCount, tt.letter, len(names))
+		}
+		
+		seen := make(map[string]bool)
+		for _, name := range names {
+			if seen[name] {
+				t.Errorf("Duplicate name %q in unique %s names %v", name, tt.letter, names)
+			}
+			seen[name] = true
+		}
+	}
+}
+
+func TestGeneratePrefix(t *testing.T) {
+	generator := NewNameGenerator(4)
+	defer generator.Shutdown()
+	
+	tests := []struct {
+		locale string
+		prefix string
+		want   string // Every name must start with this
+		count  int    // Number of matching names
+	}{
+		{"en", "Ma", "Ma", 11},
+		{"en", "ma", "Ma", 11},
+		{"en", "Mar", "Mar", 4},
+		{"en", "Zara", "Zara", 1},
+		{"en", "Mx", "", 0},
+		{"tr", "iş", "", 0}, // Işıl starts with I, not İ
+		{"tr", "ış", "Iş", 1},
+		{"ja", "さく", "サク", 1},
+	}
+	
+	for _, tt := range tests {
+		names, _ := generator.GenerateWithOptions(context.Background(), tt.prefix, 50, Options{Locale: tt.locale, Unique: true})
+		if len(names) != tt.count {
+			t.Errorf("Expected %d %s names for prefix %q, got %v", tt.count, tt.locale, tt.prefix, names)
+		}
+		for _, name := range names {
+			if !strings.HasPrefix(name, tt.want) {
+				t.Errorf("Expected name starting with %q for prefix %q, got %q", tt.want, tt.prefix, name)
+			}
+		}
+	}
+	
+	// Weights carry over to the matching names
+	generator.SetLocaleDataset(DefaultLocale, Dataset{
+		Names:   map[string][]string{"M": {"Mark", "Maria", "Mia"}},
+		Weights: map[string]float64{"Mark": 0, "Maria": 1, "Mia": 5},
+	})
+	for i := 0; i < 20; i++ {
+		if names, _ := generator.GenerateWithOptions(context.Background(), "Ma", 1, Options{}); len(names) != 1 || names[0] != "Maria" {
+			t.Fatalf("Expected Maria, got %v", names)
+		}
+	}
+}
+
+func TestGenerateWeighted(t *testing.T) {
+	generator := NewNameGenerator(4)
+	defer generator.Shutdown()
+	
+	dataset := Dataset{
+		Names:   map[string][]string{"A": {"Ada", "Alan", "Anna"}},
+		Weights: map[string]float64{"Ada": 99, "Alan": 1, "Anna": 0},
+	}
+	
+	// draw picks one name at a time, resetting the dataset to clear the cache
+	draw := func(opts Options) map[string]int {
+		counts := make(map[string]int)
+		for i := 0; i < 600; i++ {
+			generator.SetLocaleDataset(DefaultLocale, dataset)
+			names, _ := generator.GenerateWithOptions(context.Background(), "A", 1, opts)
+			for _, name := range names {
+				counts[name]++
+			}
+		}
+		return counts
+	}
+	
+	// Names are picked proportionally to their weight, and never with weight 0
+	counts := draw(Options{})
+	if counts["Ada"] < 560 || counts["Anna"] != 0 {
+		t.Errorf("Expected about 99%% Ada and no Anna, got %v", counts)
+	}
+	
+	// Unweighted requests sample uniformly
+	counts = draw(Options{Unweighted: true})
+	for _, name := range []string{"Ada", "Alan", "Anna"} {
+		if counts[name] < 120 || counts[name] > 280 {
+			t.Errorf("Expected about 200 %s names, got %v", name, counts)
+		}
+	}
+	
+	// Unique samples still contain every name once
+	if names, _ := generator.GenerateWithOptions(context.Background(), "A", 3, Options{Unique: true}); len(names) != 3 {
+		t.Errorf("Expected 3 unique names, got %v", names)
+	}
+}
+
+func TestGenerateSeeded(t *testing.T) {
+	generator := NewNameGenerator(4)
+	defer generator.Shutdown()
+	ctx := context.Background()
+	
+	// Seeded sequences are reproducible and can exceed the number of names
+	all, _ := generator.GenerateWithOptions(ctx, "A", 60, Options{Seed: 42})
+	if len(all) != 60 {
+		t.Fatalf("Expected 60 names, got %d", len(all))
+	}
+	if again, _ := generator.GenerateWithOptions(ctx, "A", 60, Options{Seed: 42}); strings.Join(again, ",") != strings.Join(all, ",") {
+		t.Errorf("Expected the same names for the same seed, got %v and %v", all, again)
+	}
+	if other, _ := generator.GenerateWithOptions(ctx, "A", 60, Options{Seed: 43}); strings.Join(other, ",") == strings.Join(all, ",") {
+		t.Error("Expected different names for a different seed")
+	}
+	
+	// Pages of the sequence match the whole sequence
+	var paged []string
+	for offset := 0; offset < 60; offset += 25 {
+		page, _ := generator.GenerateWithOptions(ctx, "A", 25, Options{Seed: 42, Offset: offset})
+		paged = append(paged, page...)
+	}
+	if strings.Join(paged[:60], ",") != strings.Join(all, ",") {
+		t.Errorf("Expected pages to match the sequence, got %v and %v", paged, all)
+	}
+	
+	// Unique sequences are a permutation of the names and end after the last one
+	seen := make(map[string]bool)
+	for offset := 0; ; offset += 7 {
+		page, _ := generator.GenerateWithOptions(ctx, "B", 7, Options{Seed: 7, Offset: offset, Unique: true})
+		if len(page) == 0 {
+			break
+		}
+		for _, name := range page {
+			if seen[name] {
+				t.Errorf("Duplicate name %q at offset %d", name, offset)
+			}
+			seen[name] = true
+		}
+	}
+	if len(seen) != 20 {
+		t.Errorf("Expected all 20 B names, got %d", len(seen))
+	}
+}
+
+func TestStream(t *testing.T) {
+	generator := NewNameGenerator(4)
+	defer generator.Shutdown()
+	ctx := context.Background()
+	
+	// Streamed seeded names arrive in sequence order
+	var streamed []string
+	for result := range generator.Stream(ctx, "A", 40, Options{Seed: 9}) {
+		if result.Err != nil {
+			t.Fatalf("Expected no error, got %v", result.Err)
+		}
+		streamed = append(streamed, result.Value)
+	}
+	expected, _ := generator.GenerateWithOptions(ctx, "A", 40, Options{Seed: 9})
+	if strings.Join(streamed, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected the streamed names to match, got %v and %v", streamed, expected)
+	}
+	
+	// Unseeded streams stop at the number of names
+	count := 0
+	for result := range generator.Stream(ctx, "B", 50, Options{}) {
+		if !strings.HasPrefix(result.Value, "B") {
+			t.Errorf("Expected name to start with B, got %q (%v)", result.Value, result.Err)
+		}
+		count++
+	}
+	if count != 20 {
+		t.Errorf("Expected 20 names, got %d", count)
+	}
+	
+	// Unknown letters end the stream with an error right away
+	var results []workerpool.ResultOf[string]
+	for result := range generator.Stream(ctx, "1", 5, Options{}) {
+		results = append(results, result)
+	}
+	if len(results) != 1 || !errors.Is(results[0].Err, ErrUnknownLetter) {
+		t.Errorf("Expected a single ErrUnknownLetter, got %+v", results)
+	}
+	
+	// Cancelling the context closes the stream
+	cancelCtx, cancel := context.WithCancel(ctx)
+	names := generator.Stream(cancelCtx, "C", 1000, Options{Seed: 1})
+	<-names
+	cancel()
+	received := 0
+	for range names {
+		received++
+	}
+	if received >= 999 {
+		t.Errorf("Expected the stream to stop after cancel, got %d more names", received)
+	}
+}
+
+func TestStreamSaturated(t *testing.T) {
+	stopped := NewNameGenerator(1)
+	stopped.Shutdown()
+	
+	// Names that can't be picked end the stream with the error, not a short list
+	var results []workerpool.ResultOf[string]
+	for result := range stopped.Stream(context.Background(), "A", 5, Options{}) {
+		results = append(results, result)
+	}
+	if len(results) != 1 || !errors.Is(results[0].Err, ErrPoolSaturated) {
+		t.Errorf("Expected a single ErrPoolSaturated, got %+v", results)
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	generator := NewNameGenerator(4)
+	defer generator.Shutdown()
+	ctx := context.Background()
+	
+	// Blocked names are never returned, whatever their case or locale
+	generator.SetBlocklist([]string{"zara", " Zoe ", "ZACHARIAS", "", "Zara"})
+	names, _ := generator.GenerateWithOptions(ctx, "Z", 50, Options{Unique: true})
+	if len(names) != 17 {
+		t.Errorf("Expected 17 Z names without Zara and Zoe, got %v", names)
+	}
+	for _, name := range names {
+		if name == "Zara" || name == "Zoe" {
+			t.Errorf("Expected %q to be blocked", name)
+		}
+	}
+	if names, err := generator.GenerateWithOptions(ctx, "Z", 50, Options{Locale: "de", Unique: true}); len(names) != 0 || err != ErrUnknownLetter {
+		t.Errorf("Expected all German Z names to be blocked, got %v", names)
+	}
+	
+	// Blocking more names also applies to names generated before
+	generator.Generate("Q", 20)
+	if added := generator.Block("Quinn", "zoe", "Zoe", "QUINN"); len(added) != 1 || added[0] != "Quinn" {
+		t.Errorf("Expected only Quinn to be added, got %v", added)
+	}
+	names, _ = generator.Generate("Q", 20)
+	for _, name := range names {
+		if name == "Quinn" {
+			t.Error("Expected Quinn to be blocked")
+		}
+	}
+	if got := strings.Join(generator.Blocklist(), ","); got != "Quinn,ZACHARIAS,Zoe,zara" {
+		t.Errorf("Unexpected blocklist %q", got)
+	}
+	
+	// Datasets set later are filtered too
+	generator.SetNames(map[string][]string{"Z": {"Zara", "Zed"}})
+	if names, _ := generator.GenerateWithOptions(ctx, "Z", 5, Options{Unique: true}); len(names) != 1 || names[0] != "Zed" {
+		t.Errorf("Expected only Zed, got %v", names)
+	}
+	
+	// Clearing the blocklist restores the names
+	generator.SetBlocklist(nil)
+	if names, _ := generator.GenerateWithOptions(ctx, "Z", 5, Options{Unique: true}); len(names) != 2 {
+		t.Errorf("Expected Zara and Zed, got %v", names)
+	}
+}
+
+func TestSampleWithoutReplacement(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	weights := []float64{99, 1, 0}
+	
+	// The heaviest index is almost always drawn first, and weight 0 always last
+	first := 0
+	for i := 0; i < 1000; i++ {
+		sample := sampleWithoutReplacement(r, 3, 3, weights, false)
+		if sample[0] == 0 {
+			first++
+		}
+		if sample[2] != 2 {
+			t.Fatalf("Expected the zero weight index last, got %v", sample)
+		}
+	}
+	if first < 950 {
+		t.Errorf("Expected index 0 first about 99%% of the time, got %d/1000", first)
+	}
+}
+
 func TestConcurrentGeneration(t *testing.T) {
 	// Create a new name generator
 	generator := NewNameGenerator(4)
@@ -185,7 +549,7 @@ func TestConcurrentGeneration(t *testing.T) {
 			letter := string(rune('A' + id%26))
 			count := 5
 			
-			names := generator.Generate(letter, count)
+			names, _ := generator.Generate(letter, count)
 			
 			// Check if the correct number of names was generated
 			if len(names) != count {
@@ -218,6 +582,100 @@ func TestConcurrentGeneration(t *testing.T) {
 	}
 }
 
+func TestRandomLetterFrequencies(t *testing.T) {
+	generator := NewNameGenerator(2)
+	defer generator.Shutdown()
+	
+	// initials counts the initials of names of random letters
+	initials := func(n int, opts Options) map[string]int {
+		counts := make(map[string]int)
+		for i := 0; i < n; i++ {
+			names, _ := generator.GenerateWithOptions(context.Background(), "", 1, opts)
+			for _, name := range names {
+				counts[name[:1]]++
+			}
+		}
+		return counts
+	}
+	
+	// English letters follow the built-in shares
+	if counts := initials(2000, Options{}); counts["J"] <= 5*counts["X"] || counts["J"] <= counts["Q"]+counts["U"]+counts["X"] {
+		t.Errorf("Expected J far more often than X, got %v", counts)
+	}
+	
+	// Letters without a share are never picked, unless letters are picked uniformly
+	generator.SetLetterFrequencies("en", map[string]float64{"a": 1, "B": 0})
+	if counts := initials(50, Options{}); counts["A"] != 50 {
+		t.Errorf("Expected only A names, got %v", counts)
+	}
+	if counts := initials(200, Options{UniformLetter: true}); len(counts) < 10 {
+		t.Errorf("Expected names of many letters, got %v", counts)
+	}
+	generator.SetLetterFrequencies("en", nil)
+	if counts := initials(200, Options{}); len(counts) < 10 {
+		t.Errorf("Expected the built-in shares to be restored, got %v", counts)
+	}
+	
+	// Locales without shares pick letters by the weight of their names
+	generator.SetLocaleDataset("eo", Dataset{
+		Names:   map[string][]string{"A": {"Anna"}, "Z": {"Zamenhof"}},
+		Weights: map[string]float64{"Zamenhof": 99},
+	})
+	if counts := initials(200, Options{Locale: "eo"}); counts["Z"] < 150 {
+		t.Errorf("Expected mostly Z names, got %v", counts)
+	}
+	
+	// Seeded sequences pick the same letter every time
+	first, _ := generator.GenerateWithOptions(context.Background(), "", 3, Options{Seed: 42})
+	for i := 0; i < 5; i++ {
+		if names, _ := generator.GenerateWithOptions(context.Background(), "", 3, Options{Seed: 42}); strings.Join(names, ",") != strings.Join(first, ",") {
+			t.Fatalf("Expected the same seeded names, got %v and %v", names, first)
+		}
+	}
+}
+
+func TestDatasetStats(t *testing.T) {
+	generator := NewNameGenerator(1)
+	defer generator.Shutdown()
+	
+	stats := generator.DatasetStats()
+	var locales []string
+	for _, locale := range stats {
+		locales = append(locales, locale.Locale)
+	}
+	if strings.Join(locales, ",") != strings.Join(generator.Locales(), ",") {
+		t.Fatalf("Expected stats for every locale, got %v", locales)
+	}
+	en := stats[1]
+	if en.Locale != "en" || en.Letters["A"] != len(NamesByLetter["A"]) || len(en.Letters) != 26 || en.Weighted || len(en.Version) != 16 {
+		t.Errorf("Unexpected stats for en: %+v", en)
+	}
+	names := 0
+	for _, count := range en.Letters {
+		names += count
+	}
+	if en.Names != names {
+		t.Errorf("Expected %d names in all, got %d", names, en.Names)
+	}
+	
+	// The version follows the names served, blocked ones included
+	generator.Block("Adam")
+	blocked := generator.DatasetStats()[1]
+	if blocked.Version == en.Version || blocked.Letters["A"] != en.Letters["A"]-1 {
+		t.Errorf("Expected a new version without Adam, got %+v", blocked)
+	}
+	generator.SetBlocklist(nil)
+	if restored := generator.DatasetStats()[1]; restored.Version != en.Version {
+		t.Errorf("Expected the same names to have the same version, got %s and %s", restored.Version, en.Version)
+	}
+	
+	// Weights change the version too
+	generator.SetLocaleDataset("en", Dataset{Names: NamesByLetter, Weights: map[string]float64{"Adam": 2}})
+	if weighted := generator.DatasetStats()[1]; !weighted.Weighted || weighted.Version == en.Version {
+		t.Errorf("Expected a new weighted version, got %+v", weighted)
+	}
+}
+
 func BenchmarkGenerateNames(b *testing.B) {
 	// Reset the generator to ensure we start fresh
 	DefaultGenerator = nil
@@ -245,3 +703,48 @@ func BenchmarkGenerateNamesParallel(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkGenerateNamesSeeded(b *testing.B) {
+	gen := NewNameGenerator(4)
+	defer gen.Shutdown()
+	ctx := context.Background()
+	
+	// Every name of a seeded page gets a generator of its own
+	for _, count := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("Count=%d", count), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				gen.GenerateWithOptions(ctx, "A", count, Options{Seed: 42, Offset: i})
+			}
+		})
+	}
+}
+
+func BenchmarkNameTasks(b *testing.B) {
+	weights := make([]float64, 100)
+	cumulative := make([]float64, 100)
+	names := make([]string, 100)
+	sum := 0.0
+	for i := range names {
+		names[i] = fmt.Sprintf("Name%d", i)
+		weights[i] = float64(i + 1)
+		sum += weights[i]
+		cumulative[i] = sum
+	}
+	matches := candidates{names: names, weights: weights, cumulative: cumulative}
+	
+	// Run the tasks directly, to measure picking the names without the pool
+	for _, unweighted := range []bool{false, true} {
+		b.Run(fmt.Sprintf("Unweighted=%t", unweighted), func(b *testing.B) {
+			tasks := nameTasks(matches, 100, Options{Unweighted: unweighted})
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					for _, task := range tasks {
+						task()
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package generator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNoNames is returned when a dataset directory contains no names
+var ErrNoNames = errors.New("generator: no names found")
+
+// Dataset is a set of names grouped by initial letter, with optional popularity weights
+type Dataset struct {
+	Names   map[string][]string // Names by initial letter
+	Weights map[string]float64  // Popularity by name; names without a weight count as 1
+}
+
+// weightedName is a name read from a dataset file
+type weightedName struct {
+	Name   string   `json:"name"`
+	Weight *float64 `json:"weight"` // nil when the file doesn't give one
+}
+
+// UnmarshalJSON accepts either a plain name or an object with a name and a weight
+func (n *weightedName) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &n.Name); err == nil {
+		return nil
+	}
+
+	type plain weightedName
+	return json.Unmarshal(data, (*plain)(n))
+}
+
+// LoadLocales reads the datasets of several locales from dir
+// Files directly in dir belong to DefaultLocale, and each subdirectory holds the
+// files of the locale it is named after (for example dir/de/names.csv)
+func LoadLocales(dir string) (map[string]Dataset, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	locales := make(map[string]Dataset)
+
+	// Files directly in the directory
+	dataset, err := LoadDataset(dir, DefaultLocale)
+	if err != nil && err != ErrNoNames {
+		return nil, err
+	}
+	if err == nil {
+		locales[DefaultLocale] = dataset
+	}
+
+	// One subdirectory per locale
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		locale, _ := normalizeLocale(entry.Name())
+		dataset, err := LoadDataset(filepath.Join(dir, entry.Name()), locale)
+		if err == ErrNoNames {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		locales[locale] = dataset
+	}
+
+	if len(locales) == 0 {
+		return nil, ErrNoNames
+	}
+
+	return locales, nil
+}
+
+// LoadNames reads name lists from all .json and .csv files in dir and groups them
+// by initial letter, using the case rules of the locale (see LoadDataset)
+func LoadNames(dir, locale string) (map[string][]string, error) {
+	dataset, err := LoadDataset(dir, locale)
+	if err != nil {
+		return nil, err
+	}
+	return dataset.Names, nil
+}
+
+// LoadDataset reads names and their weights from all .json and .csv files in dir
+// and groups them by initial letter, using the case rules of the locale
+//
+// JSON files contain either an object mapping letters to names
+// ({"A": ["Adam", "Anna"], ...}) or a flat array of names (["Adam", "Bella"]).
+// A name may also be given as an object with a popularity weight
+// ({"name": "Adam", "weight": 12.5}).
+// CSV files contain one name per row in the first column. A header row starting
+// with "name" is skipped, and a column named "weight" holds the weights; without
+// a header, a numeric second column is used as the weight.
+// Names from all files are merged, and duplicates are dropped
+func LoadDataset(dir, locale string) (Dataset, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return Dataset{}, err
+	}
+
+	// Read the files in a stable order so merged lists are deterministic
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".json", ".csv":
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	dataset := Dataset{
+		Names:   make(map[string][]string),
+		Weights: make(map[string]float64),
+	}
+	seen := make(map[string]bool)
+	add := func(entry weightedName) error {
+		name := strings.TrimSpace(entry.Name)
+		if name == "" || seen[name] {
+			return nil
+		}
+		if entry.Weight != nil {
+			if *entry.Weight < 0 || math.IsNaN(*entry.Weight) || math.IsInf(*entry.Weight, 0) {
+				return fmt.Errorf("invalid weight %v for %q", *entry.Weight, name)
+			}
+			dataset.Weights[name] = *entry.Weight
+		}
+		seen[name] = true
+		letter := initialLetter(locale, name)
+		dataset.Names[letter] = append(dataset.Names[letter], name)
+		return nil
+	}
+
+	for _, file := range files {
+		var fileNames []weightedName
+		if strings.EqualFold(filepath.Ext(file), ".json") {
+			fileNames, err = readJSONNames(file)
+		} else {
+			fileNames, err = readCSVNames(file)
+		}
+		if err != nil {
+			return Dataset{}, fmt.Errorf("generator: loading %s: %w", file, err)
+		}
+
+		for _, entry := range fileNames {
+			if err := add(entry); err != nil {
+				return Dataset{}, fmt.Errorf("generator: loading %s: %w", file, err)
+			}
+		}
+	}
+
+	if len(dataset.Names) == 0 {
+		return Dataset{}, ErrNoNames
+	}
+	if len(dataset.Weights) == 0 {
+		dataset.Weights = nil
+	}
+
+	return dataset, nil
+}
+
+// LoadBlocklist reads a blocklist file with one name per line
+// Blank lines and lines starting with # are ignored
+func LoadBlocklist(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, nil
+}
+
+// readJSONNames reads names from a JSON file holding either a letter map or a flat array
+func readJSONNames(file string) ([]weightedName, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Try a flat array first
+	var list []weightedName
+	if err := json.Unmarshal(data, &list); err == nil {
+		return list, nil
+	}
+
+	// Otherwise expect a map of letters to names
+	// Names are regrouped by their own initial, so the keys are informational
+	var byLetter map[string][]weightedName
+	if err := json.Unmarshal(data, &byLetter); err != nil {
+		return nil, err
+	}
+
+	letters := make([]string, 0, len(byLetter))
+	for letter := range byLetter {
+		letters = append(letters, letter)
+	}
+	sort.Strings(letters)
+
+	for _, letter := range letters {
+		list = append(list, byLetter[letter]...)
+	}
+	return list, nil
+}
+
+// readCSVNames reads names, and weights if present, from a CSV file
+func readCSVNames(file string) ([]weightedName, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1 // Rows may have extra columns
+	reader.TrimLeadingSpace = true
+
+	var list []weightedName
+	header := false
+	weightColumn := -1
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 {
+			continue
+		}
+
+		// Use the header row to find the weight column
+		if row == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "name") {
+			header = true
+			for i, column := range record {
+				if strings.EqualFold(strings.TrimSpace(column), "weight") {
+					weightColumn = i
+				}
+			}
+			continue
+		}
+
+		entry := weightedName{Name: record[0]}
+		column := weightColumn
+		if !header {
+			column = 1
+		}
+		if column >= 0 && column < len(record) {
+			value := strings.TrimSpace(record[column])
+			weight, err := strconv.ParseFloat(value, 64)
+			switch {
+			case err == nil:
+				entry.Weight = &weight
+			case header && value != "":
+				// A declared weight column must hold numbers
+				return nil, fmt.Errorf("line %d: invalid weight %q", row+1, value)
+			}
+		}
+		list = append(list, entry)
+	}
+	return list, nil
+}
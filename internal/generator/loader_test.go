@@ -0,0 +1,216 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes a dataset file into dir
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("Error writing %s: %v", name, err)
+	}
+}
+
+func TestLoadNames(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.json", `{"A": ["Ada", "Alan"], "B": ["Barbara"]}`)
+	writeFile(t, dir, "b.json", `["Brian", "Ada", "öskar"]`)
+	writeFile(t, dir, "c.csv", "name,origin\nCarmen,es\n Claude ,fr\n\nBrian,en\n")
+	writeFile(t, dir, "notes.txt", "Zed")
+
+	names, err := LoadNames(dir, DefaultLocale)
+	if err != nil {
+		t.Fatalf("Error loading names: %v", err)
+	}
+
+	// Names are grouped by initial letter, merged across files and deduplicated
+	expected := map[string]string{
+		"A": "Ada,Alan",
+		"B": "Barbara,Brian",
+		"C": "Carmen,Claude",
+		"Ö": "öskar",
+	}
+	if len(names) != len(expected) {
+		t.Errorf("Expected %d letters, got %v", len(expected), names)
+	}
+	for letter, want := range expected {
+		if got := strings.Join(names[letter], ","); got != want {
+			t.Errorf("Expected %s names %q, got %q", letter, want, got)
+		}
+	}
+}
+
+func TestLoadDatasetWeights(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.json", `["Ada", {"name": "Alan", "weight": 12.5}]`)
+	writeFile(t, dir, "b.csv", "name,origin,weight\nBrian,en,3\nBeth,en,\n")
+	writeFile(t, dir, "c.csv", "Carmen,0\nClaude,fr\n")
+
+	dataset, err := LoadDataset(dir, DefaultLocale)
+	if err != nil {
+		t.Fatalf("Error loading dataset: %v", err)
+	}
+
+	// Names without a weight, or with a non-numeric second column and no header, have none
+	expected := map[string]float64{"Alan": 12.5, "Brian": 3, "Carmen": 0}
+	if len(dataset.Weights) != len(expected) {
+		t.Errorf("Expected weights %v, got %v", expected, dataset.Weights)
+	}
+	for name, want := range expected {
+		if got, ok := dataset.Weights[name]; !ok || got != want {
+			t.Errorf("Expected weight %v for %s, got %v", want, name, got)
+		}
+	}
+	if got := strings.Join(dataset.Names["C"], ","); got != "Carmen,Claude" {
+		t.Errorf("Expected C names Carmen,Claude, got %q", got)
+	}
+
+	// Datasets without any weight have a nil weight map
+	dir = t.TempDir()
+	writeFile(t, dir, "a.json", `["Ada"]`)
+	if dataset, err := LoadDataset(dir, DefaultLocale); err != nil || dataset.Weights != nil {
+		t.Errorf("Expected no weights, got %v (err %v)", dataset.Weights, err)
+	}
+}
+
+func TestLoadNamesErrors(t *testing.T) {
+	// A missing directory
+	if _, err := LoadNames(filepath.Join(t.TempDir(), "missing"), DefaultLocale); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+
+	// A directory without names
+	if _, err := LoadNames(t.TempDir(), DefaultLocale); err != ErrNoNames {
+		t.Errorf("Expected ErrNoNames, got %v", err)
+	}
+
+	// A malformed file
+	dir := t.TempDir()
+	writeFile(t, dir, "bad.json", `{"A": 1}`)
+	if _, err := LoadNames(dir, DefaultLocale); err == nil {
+		t.Error("Expected an error for a malformed file")
+	}
+
+	// Invalid weights
+	for file, content := range map[string]string{
+		"negative.json": `[{"name": "Ada", "weight": -1}]`,
+		"header.csv":    "name,weight\nAda,lots\n",
+	} {
+		dir := t.TempDir()
+		writeFile(t, dir, file, content)
+		if _, err := LoadDataset(dir, DefaultLocale); err == nil {
+			t.Errorf("Expected an error for %s", file)
+		}
+	}
+}
+
+func TestNameGeneratorLoadNames(t *testing.T) {
+	generator := NewNameGenerator(2)
+	defer generator.Shutdown()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "names.csv", "Zed\nZora\n")
+
+	if err := generator.LoadNames(dir); err != nil {
+		t.Fatalf("Error loading names: %v", err)
+	}
+
+	// Only the loaded names are used
+	names, _ := generator.Generate("z", 10)
+	for _, name := range names {
+		if name != "Zed" && name != "Zora" {
+			t.Errorf("Expected a loaded name, got %q", name)
+		}
+	}
+	if names, err := generator.Generate("A", 5); len(names) != 0 || err != ErrUnknownLetter {
+		t.Errorf("Expected ErrUnknownLetter for a letter missing from the dataset, got %v and %v", names, err)
+	}
+	if names, _ := generator.Generate("", 1); len(names) != 1 || !strings.HasPrefix(names[0], "Z") {
+		t.Errorf("Expected a random letter to be chosen from the dataset, got %v", names)
+	}
+
+	// A failed load keeps the current names
+	if err := generator.LoadNames(t.TempDir()); err == nil {
+		t.Error("Expected an error loading an empty directory")
+	}
+	if names, _ := generator.Generate("Z", 1); len(names) != 1 {
+		t.Errorf("Expected the loaded names to be kept, got %v", names)
+	}
+
+	// Restore the built-in names
+	generator.SetNames(nil)
+	if names, _ := generator.Generate("A", 5); len(names) != 5 {
+		t.Errorf("Expected 5 built-in names, got %v", names)
+	}
+}
+
+func TestNameGeneratorReloadNames(t *testing.T) {
+	generator := NewNameGenerator(2)
+	defer generator.Shutdown()
+
+	dir := t.TempDir()
+	for _, locale := range []string{"de", "eo"} {
+		if err := os.Mkdir(filepath.Join(dir, locale), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, dir, "names.csv", "Zed\n")
+	writeFile(t, filepath.Join(dir, "de"), "names.csv", "Zacharias\n")
+	writeFile(t, filepath.Join(dir, "eo"), "names.csv", "Zamenhof\n")
+	if _, err := generator.ReloadNames(dir); err != nil {
+		t.Fatalf("Error reloading names: %v", err)
+	}
+	if names, _ := generator.GenerateWithOptions(context.Background(), "Z", 1, Options{Locale: "eo"}); len(names) != 1 || names[0] != "Zamenhof" {
+		t.Errorf("Expected the eo names, got %v", names)
+	}
+
+	// Locales whose files were removed go back to their built-in lists, or disappear
+	if err := os.RemoveAll(filepath.Join(dir, "de")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "eo")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generator.ReloadNames(dir); err != nil {
+		t.Fatalf("Error reloading names: %v", err)
+	}
+	if names, _ := generator.GenerateWithOptions(context.Background(), "Z", 5, Options{Locale: "de", Unique: true}); len(names) != 2 {
+		t.Errorf("Expected the 2 built-in German Z names, got %v", names)
+	}
+	if _, ok := generator.ResolveLocale("eo"); ok {
+		t.Error("Expected the eo locale to be removed")
+	}
+	if names, _ := generator.Generate("Z", 5); len(names) != 1 || names[0] != "Zed" {
+		t.Errorf("Expected the loaded default names to be kept, got %v", names)
+	}
+
+	// An empty directory restores all built-in lists
+	if _, err := generator.ReloadNames(t.TempDir()); err != nil {
+		t.Fatalf("Error reloading names: %v", err)
+	}
+	if names, _ := generator.Generate("A", 5); len(names) != 5 {
+		t.Errorf("Expected 5 built-in names, got %v", names)
+	}
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "blocklist.txt", "# Names we never return\nZara\n\n  Quinn  \r\n")
+
+	names, err := LoadBlocklist(filepath.Join(dir, "blocklist.txt"))
+	if err != nil {
+		t.Fatalf("Error loading blocklist: %v", err)
+	}
+	if got := strings.Join(names, ","); got != "Zara,Quinn" {
+		t.Errorf("Expected Zara,Quinn, got %q", got)
+	}
+
+	if _, err := LoadBlocklist(filepath.Join(dir, "missing.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected a not-exist error, got %v", err)
+	}
+}
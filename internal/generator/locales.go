@@ -0,0 +1,125 @@
+package generator
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultLocale is the locale used when a request doesn't specify one
+const DefaultLocale = "en"
+
+// germanNames contains the built-in German names
+var germanNames = []string{
+	"Alexander", "Anna", "Anton", "Amelie", "Ägidius", "Änne", "Ben", "Bettina", "Bernd", "Brigitte",
+	"Christian", "Claudia", "Clara", "Dieter", "Doris", "Daniel", "Emil", "Emma", "Elke", "Erik",
+	"Felix", "Frieda", "Friedrich", "Franziska", "Gerhard", "Greta", "Günther", "Gisela", "Hans", "Hannah",
+	"Heinz", "Helga", "Ida", "Ingrid", "Ingo", "Jakob", "Johanna", "Jürgen", "Jana", "Karl",
+	"Katharina", "Klaus", "Karin", "Lukas", "Lena", "Ludwig", "Lotte", "Maximilian", "Marie", "Matthias",
+	"Monika", "Niklas", "Nina", "Norbert", "Nele", "Otto", "Olga", "Oskar", "Ottilie", "Paul",
+	"Paula", "Peter", "Petra", "Quirin", "Rainer", "Renate", "Rolf", "Rosa", "Sebastian", "Sophie",
+	"Stefan", "Sabine", "Theo", "Tanja", "Thomas", "Trude", "Uwe", "Ursula", "Ulrich", "Ute",
+	"Volker", "Vera", "Valentin", "Verena", "Wolfgang", "Wilhelmine", "Werner", "Waltraud", "Xaver", "Yannick",
+	"Yvonne", "Zacharias", "Zoe",
+}
+
+// turkishNames contains the built-in Turkish names
+// Names starting with I and İ are different letters in Turkish
+var turkishNames = []string{
+	"Ahmet", "Ayşe", "Ali", "Aylin", "Burak", "Büşra", "Berk", "Banu", "Can", "Ceren",
+	"Cem", "Ceyda", "Çağlar", "Çiğdem", "Çetin", "Deniz", "Derya", "Doğan", "Duygu", "Emre",
+	"Elif", "Ege", "Esra", "Fatih", "Fatma", "Furkan", "Filiz", "Gökhan", "Gül", "Görkem",
+	"Gamze", "Hakan", "Hülya", "Hasan", "Hande", "Irmak", "Işıl", "Ilgaz", "İbrahim", "İpek",
+	"İsmail", "İrem", "Kemal", "Kübra", "Kaan", "Kader", "Levent", "Leyla", "Mehmet", "Merve",
+	"Murat", "Melek", "Nihat", "Nur", "Necati", "Nesrin", "Onur", "Oya", "Orhan", "Ozan",
+	"Özge", "Ömer", "Özlem", "Pınar", "Polat", "Rıza", "Rabia", "Serkan", "Selin", "Sinan",
+	"Seda", "Şule", "Şahin", "Şebnem", "Şükrü", "Tarık", "Tuba", "Tolga", "Tülay", "Uğur",
+	"Umut", "Ufuk", "Ümit", "Ülkü", "Ünal", "Volkan", "Vildan", "Yusuf", "Yasemin", "Yiğit",
+	"Yeliz", "Zeynep", "Zafer", "Zehra", "Ziya",
+}
+
+// japaneseNames contains the built-in Japanese names, written in katakana
+// Their "letter" is the first kana
+var japaneseNames = []string{
+	"アオイ", "アカリ", "アキラ", "イツキ", "イオリ", "エマ", "エイタ", "カイト", "カナ", "ケンタ",
+	"コハル", "コウキ", "サクラ", "サトシ", "ソウタ", "ソラ", "タクミ", "タイガ", "ツムギ", "ナツキ",
+	"ナナ", "ハルト", "ハナ", "ヒナタ", "ヒロシ", "マコト", "ミオ", "ミナト", "メイ", "ユイ",
+	"ユウト", "ユナ", "ヨウタ", "リン", "リコ", "レン", "ワカナ",
+}
+
+// NamesByLocale contains the built-in name lists for each supported locale, by initial letter
+var NamesByLocale = map[string]map[string][]string{
+	DefaultLocale: NamesByLetter,
+	"de":          groupNames("de", germanNames),
+	"tr":          groupNames("tr", turkishNames),
+	"ja":          groupNames("ja", japaneseNames),
+}
+
+// LetterFrequencies contains the built-in shares of names starting with each letter,
+// by locale, used to pick a letter when none is requested
+// The English shares are approximate, after the first names of the US population.
+// Locales without shares pick letters by the total weight of their names
+var LetterFrequencies = map[string]map[string]float64{
+	DefaultLocale: {
+		"A": 7.9, "B": 4.1, "C": 6.5, "D": 6.6, "E": 3.7, "F": 1.0, "G": 2.9, "H": 2.3, "I": 0.9,
+		"J": 10.4, "K": 6.1, "L": 5.3, "M": 9.3, "N": 1.9, "O": 0.6, "P": 1.7, "Q": 0.1, "R": 5.7,
+		"S": 5.9, "T": 4.6, "U": 0.1, "V": 1.1, "W": 1.6, "X": 0.1, "Y": 0.3, "Z": 0.4,
+	},
+}
+
+// groupNames groups names by their initial letter in the given locale
+func groupNames(locale string, names []string) map[string][]string {
+	grouped := make(map[string][]string)
+	for _, name := range names {
+		letter := initialLetter(locale, name)
+		grouped[letter] = append(grouped[letter], name)
+	}
+	return grouped
+}
+
+// initialLetter returns the first letter of a name or letter parameter, upper-cased
+// using the case rules of the locale
+func initialLetter(locale, s string) string {
+	r, _ := utf8.DecodeRuneInString(s)
+
+	switch locale {
+	case "tr", "az":
+		// Turkish and Azerbaijani map i to İ and ı to I
+		return string(unicode.TurkishCase.ToUpper(r))
+	case "ja":
+		// Hiragana initials match the katakana names
+		if r >= 'ぁ' && r <= 'ゖ' {
+			r += 'ァ' - 'ぁ'
+		}
+	}
+	return strings.ToUpper(string(r))
+}
+
+// foldName lower-cases a name or prefix for case-insensitive matching, using the
+// case rules of the locale
+func foldName(locale, s string) string {
+	switch locale {
+	case "tr", "az":
+		return strings.ToLowerSpecial(unicode.TurkishCase, s)
+	case "ja":
+		// Hiragana matches the katakana names
+		return strings.Map(func(r rune) rune {
+			if r >= 'ぁ' && r <= 'ゖ' {
+				r += 'ァ' - 'ぁ'
+			}
+			return r
+		}, s)
+	}
+	return strings.ToLower(s)
+}
+
+// normalizeLocale reduces a locale tag such as "de-DE" or "tr_TR" to a lower-case tag
+// and the language it starts with
+func normalizeLocale(locale string) (tag, language string) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	language = tag
+	if i := strings.IndexByte(tag, '-'); i >= 0 {
+		language = tag[:i]
+	}
+	return tag, language
+}
@@ -0,0 +1,130 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitialLetter(t *testing.T) {
+	tests := []struct {
+		locale string
+		input  string
+		want   string
+	}{
+		{"en", "a", "A"},
+		{"en", "i", "I"},
+		{"de", "ä", "Ä"},
+		{"de", "über", "Ü"},
+		{"tr", "i", "İ"}, // Dotted i upper-cases to dotted İ in Turkish
+		{"tr", "ı", "I"}, // Dotless ı upper-cases to I
+		{"tr", "ş", "Ş"},
+		{"ja", "は", "ハ"}, // Hiragana matches katakana names
+		{"ja", "ハ", "ハ"},
+	}
+
+	for _, tt := range tests {
+		if got := initialLetter(tt.locale, tt.input); got != tt.want {
+			t.Errorf("initialLetter(%q, %q) = %q, want %q", tt.locale, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestResolveLocale(t *testing.T) {
+	generator := NewNameGenerator(1)
+	defer generator.Shutdown()
+
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"", DefaultLocale, true},
+		{"de", "de", true},
+		{"de-DE", "de", true},
+		{"TR_tr", "tr", true},
+		{"ja", "ja", true},
+		{"xx", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := generator.ResolveLocale(tt.input)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ResolveLocale(%q) = (%q, %v), want (%q, %v)", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if locales := strings.Join(generator.Locales(), ","); locales != "de,en,ja,tr" {
+		t.Errorf("Expected locales de,en,ja,tr, got %s", locales)
+	}
+}
+
+func TestGenerateWithLocale(t *testing.T) {
+	generator := NewNameGenerator(2)
+	defer generator.Shutdown()
+
+	tests := []struct {
+		locale string
+		letter string
+		prefix string
+	}{
+		{"de", "ä", "Ä"},
+		{"tr", "i", "İ"},
+		{"tr", "ı", "I"},
+		{"tr", "ş", "Ş"},
+		{"ja", "ゆ", "ユ"},
+		{"en", "a", "A"},
+	}
+
+	for _, tt := range tests {
+		names, _ := generator.GenerateWithOptions(context.Background(), tt.letter, 2, Options{Locale: tt.locale})
+		if len(names) != 2 {
+			t.Errorf("Expected 2 %s names for %q, got %v", tt.locale, tt.letter, names)
+		}
+		for _, name := range names {
+			if !strings.HasPrefix(name, tt.prefix) {
+				t.Errorf("Expected %s name starting with %q, got %q", tt.locale, tt.prefix, name)
+			}
+		}
+	}
+
+	// Unsupported locales produce no names
+	if names, err := generator.GenerateWithOptions(context.Background(), "A", 2, Options{Locale: "xx"}); len(names) != 0 || err != ErrUnknownLetter {
+		t.Errorf("Expected ErrUnknownLetter for an unsupported locale, got %v and %v", names, err)
+	}
+}
+
+func TestLoadLocales(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "names.csv", "Ada\n")
+	if err := os.Mkdir(filepath.Join(dir, "tr"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "tr"), "names.csv", "ilknur\nIşık\n")
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	locales, err := LoadLocales(dir)
+	if err != nil {
+		t.Fatalf("Error loading locales: %v", err)
+	}
+
+	// Subdirectories without names are skipped
+	if len(locales) != 2 {
+		t.Errorf("Expected 2 locales, got %v", locales)
+	}
+	if names := locales[DefaultLocale].Names["A"]; len(names) != 1 || names[0] != "Ada" {
+		t.Errorf("Expected [Ada] for the default locale, got %v", names)
+	}
+
+	// Names are grouped with the case rules of their locale
+	if names := locales["tr"].Names["İ"]; len(names) != 1 || names[0] != "ilknur" {
+		t.Errorf("Expected [ilknur] under İ, got %v", names)
+	}
+	if names := locales["tr"].Names["I"]; len(names) != 1 || names[0] != "Işık" {
+		t.Errorf("Expected [Işık] under I, got %v", names)
+	}
+}
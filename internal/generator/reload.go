@@ -0,0 +1,286 @@
+package generator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+)
+
+// ErrNoNamesDir is returned by Reload when no names were loaded from a directory
+var ErrNoNamesDir = errors.New("generator: no names directory loaded")
+
+// Changes lists the letters whose names or weights changed in a reload, by locale
+// Letters are sorted, and a locale that was added or removed lists all its letters
+type Changes map[string][]string
+
+// Affects reports whether names generated for a letter or prefix of a locale
+// may be outdated by the changes
+// An empty letter, standing for a random one, is affected by any change of the locale
+func (c Changes) Affects(locale, letter string) bool {
+	if strings.TrimSpace(locale) == "" {
+		locale = DefaultLocale
+	}
+	locale, language := normalizeLocale(locale)
+	letters, ok := c[locale]
+	if !ok {
+		return false
+	}
+	if letter = strings.TrimSpace(letter); letter == "" {
+		return true
+	}
+	initial := initialLetter(language, letter)
+	i := sort.SearchStrings(letters, initial)
+	return i < len(letters) && letters[i] == initial
+}
+
+// Reload reads the directory the names were last loaded from again, see ReloadNames
+func (g *NameGenerator) Reload() (Changes, error) {
+	g.datasetsMutex.RLock()
+	dir := g.namesDir
+	g.datasetsMutex.RUnlock()
+	if dir == "" {
+		return nil, ErrNoNamesDir
+	}
+	return g.ReloadNames(dir)
+}
+
+// ReloadNames makes the name lists match the files in dir (see LoadLocales) and
+// returns the letters that changed
+// Unlike LoadNames, locales without files go back to their built-in lists, or are
+// removed if they have none, so that deleting a file takes effect. All locales are
+// swapped at once, so names are never generated from a partly reloaded directory,
+// and only the cached names of the changed letters are dropped. Nothing changes
+// if the directory can't be loaded
+func (g *NameGenerator) ReloadNames(dir string) (Changes, error) {
+	locales, err := LoadLocales(dir)
+	if err == ErrNoNames {
+		locales = map[string]Dataset{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Prepare the datasets aside, so names are generated meanwhile
+	g.datasetsMutex.RLock()
+	blocklist := make(map[string]string, len(g.blocklist))
+	for key, name := range g.blocklist {
+		blocklist[key] = name
+	}
+	version := g.blocklistVersion
+	g.datasetsMutex.RUnlock()
+	datasets := newDatasets(locales, blocklist)
+
+	g.datasetsMutex.Lock()
+	if g.blocklistVersion != version {
+		// Names were blocked meanwhile, which the datasets must leave out too
+		datasets = newDatasets(locales, g.blocklist)
+	}
+	changes := diffDatasets(g.datasets, datasets)
+	g.datasets = datasets
+	g.namesDir = dir
+	g.datasetsMutex.Unlock()
+
+	g.dropCachedNames(changes)
+	return changes, nil
+}
+
+// newDatasets prepares the datasets of the loaded locales, and the built-in lists
+// of the other locales
+func newDatasets(locales map[string]Dataset, blocklist map[string]string) map[string]dataset {
+	datasets := make(map[string]dataset, len(NamesByLocale)+len(locales))
+	for locale, names := range NamesByLocale {
+		if _, ok := locales[locale]; !ok {
+			_, language := normalizeLocale(locale)
+			datasets[locale] = newDataset(language, Dataset{Names: names}, blocklist)
+		}
+	}
+	for locale, data := range locales {
+		_, language := normalizeLocale(locale)
+		datasets[locale] = newDataset(language, data, blocklist)
+	}
+	return datasets
+}
+
+// diffDatasets returns the letters whose names or weights differ between two
+// sets of datasets
+func diffDatasets(old, updated map[string]dataset) Changes {
+	changes := Changes{}
+	diff := func(locale string) {
+		before, after := old[locale], updated[locale]
+		var letters []string
+		for letter, names := range after.names {
+			if !slices.Equal(names, before.names[letter]) || !slices.Equal(after.weights[letter], before.weights[letter]) {
+				letters = append(letters, letter)
+			}
+		}
+		for letter := range before.names {
+			if _, ok := after.names[letter]; !ok {
+				letters = append(letters, letter)
+			}
+		}
+		if len(letters) > 0 {
+			sort.Strings(letters)
+			changes[locale] = letters
+		}
+	}
+
+	for locale := range updated {
+		diff(locale)
+	}
+	for locale := range old {
+		if _, ok := updated[locale]; !ok {
+			diff(locale)
+		}
+	}
+	return changes
+}
+
+// dropCachedNames drops the cached names of the changed letters
+func (g *NameGenerator) dropCachedNames(changes Changes) {
+	if len(changes) == 0 {
+		return
+	}
+
+	c := g.getNameCache()
+	inspector, ok := c.(cache.Inspector)
+	if !ok {
+		// Without a way to find the keys of the changed letters, drop all names
+		g.clearNameCache()
+		return
+	}
+	prefix := g.nameCachePrefix()
+	for _, key := range inspector.Keys() {
+		// Keys start with the prefix, the locale and the letter or prefix, see GenerateWithOptions
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		locale, rest, _ := strings.Cut(rest, ":")
+		query, _, _ := strings.Cut(rest, ":")
+		if changes.Affects(locale, query) {
+			c.Delete(key)
+		}
+	}
+}
+
+// Watcher reloads the names of a generator when the dataset files in its directory
+// change, comparing their names, sizes and modification times every interval
+type Watcher struct {
+	generator *NameGenerator
+	dir       string
+	interval  time.Duration
+	onReload  func(Changes, error) // Called after every reload, may be nil
+	files     string               // Dataset files seen at the last check
+
+	startOnce sync.Once
+	stopCh    chan struct{}
+	done      chan struct{} // Closed once the watcher has stopped
+}
+
+// NewWatcher returns a watcher of the dataset files in dir (see LoadLocales)
+// onReload is called with the result of every reload, from the watcher's goroutine
+func NewWatcher(generator *NameGenerator, dir string, interval time.Duration, onReload func(Changes, error)) *Watcher {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &Watcher{
+		generator: generator,
+		dir:       dir,
+		interval:  interval,
+		onReload:  onReload,
+		stopCh:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start starts watching
+// The files found now are taken as already loaded, so only later changes reload
+func (w *Watcher) Start() {
+	w.startOnce.Do(func() {
+		w.files = datasetFiles(w.dir)
+		go w.run()
+	})
+}
+
+// run checks the files every interval until stopCh is closed
+func (w *Watcher) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.check()
+		case <-w.stopCh:
+			return
+		}
+	}
+}
+
+// check reloads the names if the dataset files changed since the last check,
+// and reports whether they did
+func (w *Watcher) check() bool {
+	files := datasetFiles(w.dir)
+	if files == w.files {
+		return false
+	}
+	w.files = files
+
+	changes, err := w.generator.ReloadNames(w.dir)
+	if w.onReload != nil {
+		w.onReload(changes, err)
+	}
+	return true
+}
+
+// Stop stops watching
+func (w *Watcher) Stop() {
+	close(w.stopCh)
+	// Without a watch running there is nothing to wait for, and none can start
+	w.startOnce.Do(func() { close(w.done) })
+	<-w.done
+}
+
+// datasetFiles describes the dataset files in dir and its locale subdirectories,
+// so that comparing two descriptions tells whether any file changed
+// A directory that can't be read is described by the error
+func datasetFiles(dir string) string {
+	var files strings.Builder
+	var describe func(dir string, subdirs bool)
+	describe = func(dir string, subdirs bool) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			files.WriteString("!" + err.Error() + "\n")
+			return
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				if subdirs {
+					describe(filepath.Join(dir, entry.Name()), false)
+				}
+				continue
+			}
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".json", ".csv":
+			default:
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			files.WriteString(filepath.Join(dir, entry.Name()) + " " + strconv.FormatInt(info.Size(), 10) +
+				" " + strconv.FormatInt(info.ModTime().UnixNano(), 10) + "\n")
+		}
+	}
+	describe(dir, true)
+	return files.String()
+}
@@ -0,0 +1,176 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+)
+
+func TestReloadChanges(t *testing.T) {
+	generator := NewNameGenerator(2)
+	defer generator.Shutdown()
+	nameCache := cache.NewLRUCache(10, time.Minute, 0)
+	generator.SetCache(nameCache)
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "de"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "de"), "names.csv", "Zacharias\nQuirin\n")
+	if err := generator.LoadNames(dir); err != nil {
+		t.Fatalf("Error loading names: %v", err)
+	}
+
+	// Cache names of both letters
+	de := Options{Locale: "de", Unique: true}
+	generator.GenerateWithOptions(context.Background(), "Z", 1, de)
+	generator.GenerateWithOptions(context.Background(), "Q", 1, de)
+	if nameCache.Count() != 2 {
+		t.Fatalf("Expected 2 cached entries, got %v", nameCache.Keys())
+	}
+
+	// Only the changed letter is reported, and only its names are dropped
+	writeFile(t, filepath.Join(dir, "de"), "names.csv", "Zacharias\nQuentin\nQuirin\n")
+	changes, err := generator.Reload()
+	if err != nil {
+		t.Fatalf("Error reloading names: %v", err)
+	}
+	if !reflect.DeepEqual(changes, Changes{"de": {"Q"}}) {
+		t.Errorf("Expected Q of de to change, got %v", changes)
+	}
+	key := generator.nameCachePrefix() + "de:" + getCacheKey("Z", 1) + ":unique"
+	if _, ok := nameCache.Get(key); !ok || nameCache.Count() != 1 {
+		t.Errorf("Expected only the Z names to stay cached, got %v", nameCache.Keys())
+	}
+	if names, _ := generator.GenerateWithOptions(context.Background(), "Q", 5, de); len(names) != 2 {
+		t.Errorf("Expected the 2 reloaded Q names, got %v", names)
+	}
+
+	// Reloading unchanged files changes nothing
+	if changes, err := generator.Reload(); err != nil || len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v %v", changes, err)
+	}
+
+	// Removing the files restores the built-in German names, changing more letters
+	if err := os.RemoveAll(filepath.Join(dir, "de")); err != nil {
+		t.Fatal(err)
+	}
+	changes, err = generator.Reload()
+	if err != nil {
+		t.Fatalf("Error reloading names: %v", err)
+	}
+	if len(changes["de"]) != len(NamesByLocale["de"]) {
+		t.Errorf("Expected every German letter to change, got %v", changes)
+	}
+}
+
+func TestReloadKeepsBlocklist(t *testing.T) {
+	generator := NewNameGenerator(2)
+	defer generator.Shutdown()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "names.csv", "Zed\nZoltan\n")
+	generator.Block("Zoltan")
+	if _, err := generator.ReloadNames(dir); err != nil {
+		t.Fatalf("Error reloading names: %v", err)
+	}
+	if names, _ := generator.GenerateWithOptions(context.Background(), "Z", 5, Options{Unique: true}); len(names) != 1 || names[0] != "Zed" {
+		t.Errorf("Expected the blocked name to stay filtered, got %v", names)
+	}
+}
+
+func TestReloadWithoutNamesDir(t *testing.T) {
+	generator := NewNameGenerator(1)
+	defer generator.Shutdown()
+
+	if _, err := generator.Reload(); err != ErrNoNamesDir {
+		t.Errorf("Expected ErrNoNamesDir, got %v", err)
+	}
+	if _, err := generator.ReloadNames(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+	if names, _ := generator.Generate("A", 3); len(names) != 3 {
+		t.Errorf("Expected the built-in names to be kept, got %v", names)
+	}
+}
+
+func TestChangesAffects(t *testing.T) {
+	changes := Changes{"en": {"M", "Q"}, "tr": {"İ"}}
+	tests := []struct {
+		locale, letter string
+		expected       bool
+	}{
+		{"en", "Q", true},
+		{"en", "q", true},
+		{"en", "ma", true},
+		{"", "M", true},
+		{"en", "", true},
+		{"en", "A", false},
+		{"de", "Q", false},
+		{"de", "", false},
+		{"tr", "i", true},
+		{"tr", "I", false},
+	}
+	for _, test := range tests {
+		if affected := changes.Affects(test.locale, test.letter); affected != test.expected {
+			t.Errorf("Affects(%q, %q) = %v, expected %v", test.locale, test.letter, affected, test.expected)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	generator := NewNameGenerator(2)
+	defer generator.Shutdown()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "names.csv", "Zed\n")
+	if err := generator.LoadNames(dir); err != nil {
+		t.Fatalf("Error loading names: %v", err)
+	}
+
+	var reloaded []Changes
+	watcher := NewWatcher(generator, dir, time.Hour, func(changes Changes, err error) {
+		if err != nil {
+			t.Errorf("Error reloading names: %v", err)
+		}
+		reloaded = append(reloaded, changes)
+	})
+	watcher.Start()
+	defer watcher.Stop()
+
+	// Nothing is reloaded until a file changes
+	if watcher.check() {
+		t.Error("Expected no reload for unchanged files")
+	}
+	writeFile(t, dir, "names.csv", "Zed\nZelda\n")
+	if !watcher.check() {
+		t.Error("Expected a reload for a changed file")
+	}
+	if len(reloaded) != 1 || !reflect.DeepEqual(reloaded[0], Changes{"en": {"Z"}}) {
+		t.Errorf("Expected Z of en to change, got %v", reloaded)
+	}
+	if names, _ := generator.GenerateWithOptions(context.Background(), "Z", 5, Options{Unique: true}); len(names) != 2 {
+		t.Errorf("Expected the 2 reloaded names, got %v", names)
+	}
+
+	// Files of other types are ignored, new locales are picked up
+	writeFile(t, dir, "notes.txt", "Zorro\n")
+	if watcher.check() {
+		t.Error("Expected no reload for a file that isn't a dataset")
+	}
+	if err := os.Mkdir(filepath.Join(dir, "eo"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "eo"), "names.csv", "Zamenhof\n")
+	if !watcher.check() {
+		t.Error("Expected a reload for a new locale")
+	}
+	if _, ok := generator.ResolveLocale("eo"); !ok {
+		t.Error("Expected the eo locale to be loaded")
+	}
+}
@@ -0,0 +1,341 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AlertRule is a threshold on a metric, e.g. "p99>250ms"
+type AlertRule struct {
+	Metric    string  // p50, p90, p99, error_rate, memory or the name of a numeric gauge
+	Above     bool    // Whether the rule fires above the threshold rather than below it
+	Threshold float64 // Milliseconds for the percentiles, percent for error_rate, bytes for memory
+	text      string  // The rule as it was written
+}
+
+// ParseAlertRule parses a rule of the form metric>threshold or metric<threshold
+// The percentiles take a duration such as 250ms, error_rate a percentage such
+// as 5%, and memory a size such as 512MB
+func ParseAlertRule(rule string) (AlertRule, error) {
+	i := strings.IndexAny(rule, "<>")
+	if i < 0 {
+		return AlertRule{}, fmt.Errorf("alert rule %q: expected metric>threshold or metric<threshold", rule)
+	}
+	parsed := AlertRule{
+		Metric: strings.TrimSpace(rule[:i]),
+		Above:  rule[i] == '>',
+		text:   strings.Join(strings.Fields(rule), ""),
+	}
+	if parsed.Metric == "" {
+		return AlertRule{}, fmt.Errorf("alert rule %q: missing metric", rule)
+	}
+
+	raw := strings.TrimSpace(rule[i+1:])
+	var err error
+	switch parsed.Metric {
+	case "p50", "p90", "p99":
+		var d time.Duration
+		d, err = time.ParseDuration(raw)
+		parsed.Threshold = float64(d) / float64(time.Millisecond)
+	case "error_rate":
+		parsed.Threshold, err = strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64)
+	case "memory":
+		parsed.Threshold, err = parseBytes(raw)
+	default:
+		parsed.Threshold, err = strconv.ParseFloat(raw, 64)
+	}
+	if err != nil {
+		return AlertRule{}, fmt.Errorf("alert rule %q: invalid threshold %q", rule, raw)
+	}
+	return parsed, nil
+}
+
+// String returns the rule as it was written, without spaces
+func (r AlertRule) String() string {
+	return r.text
+}
+
+// parseBytes parses a size such as 512MB, with binary multiples
+func parseBytes(s string) (float64, error) {
+	multiple := 1.0
+	upper := strings.ToUpper(s)
+	for _, unit := range []struct {
+		suffix   string
+		multiple float64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(upper, unit.suffix) {
+			multiple = unit.multiple
+			s = strings.TrimSpace(s[:len(s)-len(unit.suffix)])
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return n * multiple, err
+}
+
+// AlertConfig configures an Alerter
+type AlertConfig struct {
+	WebhookURL string                  // Endpoint the notifications are posted to (empty sends none)
+	Format     string                  // "slack" for Slack incoming webhooks, otherwise a JSON AlertNotification
+	Interval   time.Duration           // How often the rules are evaluated (default 30s)
+	Cooldown   time.Duration           // Least time between two notifications that a rule fires (default 5m)
+	Timeout    time.Duration           // Timeout of a webhook request (default 5s)
+	OnNotify   func(AlertNotification) // Called for every notification, e.g. to log it
+	OnError    func(error)             // Called when a webhook fails or a rule refers to an unknown metric
+}
+
+// AlertNotification tells that a rule started firing or was resolved
+type AlertNotification struct {
+	Status    string    `json:"status"` // "firing" or "resolved"
+	Rule      string    `json:"rule"`
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"` // In the unit of the threshold
+	Threshold float64   `json:"threshold"`
+	StartedAt time.Time `json:"started_at"` // When the rule started firing
+	Time      time.Time `json:"time"`
+}
+
+// alertState is what an Alerter knows about a rule between evaluations
+type alertState struct {
+	firing    bool
+	notified  bool      // Whether the current firing was notified
+	startedAt time.Time // When the rule started firing
+	lastSent  time.Time // When the rule was last notified as firing
+	value     float64
+	unknown   bool // Whether the metric of the rule was found missing, reported once
+}
+
+// Alerter evaluates alert rules against the metrics of a collector every
+// interval and posts a notification when a rule starts firing and when it is
+// resolved. A rule that fires again within the cooldown of its last
+// notification is only notified once the cooldown has passed, so flapping
+// rules don't flood the webhook
+type Alerter struct {
+	collector *MetricsCollector
+	rules     []AlertRule
+	config    AlertConfig
+	client    *http.Client
+
+	mutex        sync.Mutex
+	states       []alertState // Per rule
+	lastRequests uint64       // Requests at the last evaluation, for the error rate
+	lastFailed   uint64       // Failed requests at the last evaluation
+
+	startOnce sync.Once
+	stopCh    chan struct{}
+	done      chan struct{} // Closed once the evaluations have stopped
+}
+
+// NewAlerter creates an alerter that evaluates rules against collector
+func NewAlerter(collector *MetricsCollector, rules []AlertRule, config AlertConfig) *Alerter {
+	if config.Interval <= 0 {
+		config.Interval = 30 * time.Second
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 5 * time.Minute
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	return &Alerter{
+		collector: collector,
+		rules:     rules,
+		config:    config,
+		client:    &http.Client{Timeout: config.Timeout},
+		states:    make([]alertState, len(rules)),
+		stopCh:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start evaluates the rules every interval until Stop is called
+func (a *Alerter) Start() {
+	a.startOnce.Do(func() {
+		go a.run()
+	})
+}
+
+// run evaluates the rules every interval until stopCh is closed
+func (a *Alerter) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.Evaluate(time.Now())
+		case <-a.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops the evaluations
+func (a *Alerter) Stop() {
+	close(a.stopCh)
+	// Without evaluations running there is nothing to wait for, and none can start
+	a.startOnce.Do(func() { close(a.done) })
+	<-a.done
+}
+
+// Evaluate checks every rule against the current metrics and sends the
+// notifications that are due
+func (a *Alerter) Evaluate(now time.Time) {
+	a.mutex.Lock()
+	values := a.values()
+	var notifications []AlertNotification
+	var errs []error
+	for i, rule := range a.rules {
+		state := &a.states[i]
+		value, ok := values[rule.Metric]
+		if !ok {
+			if !state.unknown {
+				state.unknown = true
+				errs = append(errs, fmt.Errorf("alert rule %s: unknown metric %q", rule, rule.Metric))
+			}
+			continue
+		}
+		state.value = value
+		firing := value > rule.Threshold
+		if !rule.Above {
+			firing = value < rule.Threshold
+		}
+
+		switch {
+		case firing && !state.firing:
+			state.firing, state.notified, state.startedAt = true, false, now
+		case !firing && state.firing:
+			state.firing = false
+			if state.notified {
+				notifications = append(notifications, a.notification("resolved", rule, *state, now))
+			}
+			continue
+		}
+		if firing && !state.notified && now.Sub(state.lastSent) >= a.config.Cooldown {
+			state.notified, state.lastSent = true, now
+			notifications = append(notifications, a.notification("firing", rule, *state, now))
+		}
+	}
+	a.mutex.Unlock()
+
+	// Webhooks are sent without holding the lock, so a slow one doesn't block Firing
+	for _, notification := range notifications {
+		if a.config.OnNotify != nil {
+			a.config.OnNotify(notification)
+		}
+		if err := a.send(notification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, err := range errs {
+		if a.config.OnError != nil {
+			a.config.OnError(err)
+		}
+	}
+}
+
+// Firing returns the rules that are firing, in the order they were given
+func (a *Alerter) Firing() []AlertNotification {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var firing []AlertNotification
+	for i, rule := range a.rules {
+		if a.states[i].firing {
+			firing = append(firing, a.notification("firing", rule, a.states[i], a.states[i].startedAt))
+		}
+	}
+	return firing
+}
+
+// values reads the metrics the rules can refer to, in the units of their
+// thresholds; the error rate covers the requests since the last evaluation
+func (a *Alerter) values() map[string]float64 {
+	current := a.collector.snapshot()
+	values := make(map[string]float64, len(current.gauges)+len(current.counters)+5)
+	for name, value := range current.counters {
+		values[name] = float64(value)
+	}
+	for name, value := range current.gauges {
+		values[name] = value
+	}
+	for _, p := range []float64{50, 90, 99} {
+		values[fmt.Sprintf("p%.0f", p)] = float64(a.collector.GetResponseTimePercentile(p)) / float64(time.Millisecond)
+	}
+	values["memory"] = current.gauges["memory_usage"]
+
+	requests, failed := current.counters["requests_total"], current.counters["requests_failed"]
+	values["error_rate"] = 0
+	if requests > a.lastRequests {
+		values["error_rate"] = float64(failed-a.lastFailed) / float64(requests-a.lastRequests) * 100
+	}
+	a.lastRequests, a.lastFailed = requests, failed
+	return values
+}
+
+// notification describes the state of a rule
+func (a *Alerter) notification(status string, rule AlertRule, state alertState, now time.Time) AlertNotification {
+	return AlertNotification{
+		Status:    status,
+		Rule:      rule.String(),
+		Metric:    rule.Metric,
+		Value:     state.value,
+		Threshold: rule.Threshold,
+		StartedAt: state.startedAt,
+		Time:      now,
+	}
+}
+
+// send posts a notification to the webhook
+func (a *Alerter) send(notification AlertNotification) error {
+	if a.config.WebhookURL == "" {
+		return nil
+	}
+	var payload interface{} = notification
+	if a.config.Format == "slack" {
+		payload = map[string]string{"text": slackText(notification)}
+	}
+	// Rules are sent as written, without escaping their < and >
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(payload); err != nil {
+		return err
+	}
+
+	resp, err := a.client.Post(a.config.WebhookURL, "application/json", &body)
+	if err != nil {
+		return fmt.Errorf("alert webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("alert webhook: status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// slackText formats a notification as the text of a Slack message
+func slackText(n AlertNotification) string {
+	value := strconv.FormatFloat(n.Value, 'f', 2, 64)
+	switch {
+	case n.Metric == "p50" || n.Metric == "p90" || n.Metric == "p99":
+		value = time.Duration(n.Value * float64(time.Millisecond)).Round(time.Microsecond).String()
+	case n.Metric == "error_rate":
+		value += "%"
+	case n.Metric == "memory":
+		value = strconv.FormatFloat(n.Value/(1<<20), 'f', 1, 64) + " MB"
+	}
+	if n.Status == "resolved" {
+		return fmt.Sprintf("[RESOLVED] %s, now %s, after %s", n.Rule, value, n.Time.Sub(n.StartedAt).Round(time.Second))
+	}
+	return fmt.Sprintf("[FIRING] %s, now %s", n.Rule, value)
+}
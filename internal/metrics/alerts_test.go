@@ -0,0 +1,211 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	for _, tc := range []struct {
+		rule      string
+		metric    string
+		above     bool
+		threshold float64
+	}{
+		{"p99>250ms", "p99", true, 250},
+		{"p50 > 1.5s", "p50", true, 1500},
+		{"error_rate>5%", "error_rate", true, 5},
+		{"memory>512MB", "memory", true, 512 << 20},
+		{"memory > 2gb", "memory", true, 2 << 30},
+		{"requests_per_second_1m<0.5", "requests_per_second_1m", false, 0.5},
+	} {
+		rule, err := ParseAlertRule(tc.rule)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", tc.rule, err)
+			continue
+		}
+		if rule.Metric != tc.metric || rule.Above != tc.above || rule.Threshold != tc.threshold {
+			t.Errorf("Expected %q to be %s %v %v, got %+v", tc.rule, tc.metric, tc.above, tc.threshold, rule)
+		}
+	}
+	if rule, _ := ParseAlertRule("p99 > 250ms"); rule.String() != "p99>250ms" {
+		t.Errorf("Expected the rule without spaces, got %s", rule)
+	}
+
+	for _, invalid := range []string{"p99", ">250ms", "p99>250", "error_rate>high", "memory>lots"} {
+		if _, err := ParseAlertRule(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+// webhookServer stands in for a webhook, keeping the bodies it receives
+type webhookServer struct {
+	mutex  sync.Mutex
+	bodies []string
+}
+
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.bodies = append(s.bodies, string(body))
+}
+
+func (s *webhookServer) received() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func TestAlerter(t *testing.T) {
+	webhook := &webhookServer{}
+	server := httptest.NewServer(webhook)
+	defer server.Close()
+
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	depth := int64(0)
+	var depthMutex sync.Mutex
+	collector.RegisterGauge("queue_depth", func() interface{} {
+		depthMutex.Lock()
+		defer depthMutex.Unlock()
+		return depth
+	})
+	setDepth := func(d int64) {
+		depthMutex.Lock()
+		depth = d
+		depthMutex.Unlock()
+	}
+
+	rule, _ := ParseAlertRule("queue_depth>10")
+	var notified []AlertNotification
+	alerter := NewAlerter(collector, []AlertRule{rule}, AlertConfig{
+		WebhookURL: server.URL,
+		Cooldown:   time.Minute,
+		OnNotify:   func(n AlertNotification) { notified = append(notified, n) },
+	})
+	start := time.Now()
+
+	// Below the threshold nothing is sent
+	alerter.Evaluate(start)
+	if len(webhook.received()) != 0 || len(alerter.Firing()) != 0 {
+		t.Fatalf("Expected no alert, got %v", webhook.received())
+	}
+
+	// Crossing it sends one notification, not one per evaluation
+	setDepth(25)
+	alerter.Evaluate(start.Add(time.Second))
+	alerter.Evaluate(start.Add(2 * time.Second))
+	bodies := webhook.received()
+	if len(bodies) != 1 {
+		t.Fatalf("Expected one notification, got %v", bodies)
+	}
+	var firing AlertNotification
+	if err := json.Unmarshal([]byte(bodies[0]), &firing); err != nil || firing.Status != "firing" || firing.Rule != "queue_depth>10" || firing.Value != 25 {
+		t.Errorf("Expected the firing rule, got %s (%v)", bodies[0], err)
+	}
+	if active := alerter.Firing(); len(active) != 1 || !active[0].StartedAt.Equal(start.Add(time.Second)) {
+		t.Errorf("Expected the rule to be firing since the first evaluation above the threshold, got %+v", active)
+	}
+
+	// Going back below it sends the resolution
+	setDepth(0)
+	alerter.Evaluate(start.Add(3 * time.Second))
+	bodies = webhook.received()
+	var resolved AlertNotification
+	if len(bodies) != 2 || json.Unmarshal([]byte(bodies[1]), &resolved) != nil || resolved.Status != "resolved" {
+		t.Fatalf("Expected the resolution, got %v", bodies)
+	}
+
+	// Firing again within the cooldown waits for it to pass
+	setDepth(30)
+	alerter.Evaluate(start.Add(4 * time.Second))
+	if len(webhook.received()) != 2 {
+		t.Errorf("Expected no notification within the cooldown, got %v", webhook.received())
+	}
+	alerter.Evaluate(start.Add(time.Second + time.Minute))
+	if bodies = webhook.received(); len(bodies) != 3 || !strings.Contains(bodies[2], `"status":"firing"`) {
+		t.Errorf("Expected the rule to be notified after the cooldown, got %v", bodies)
+	}
+	if len(notified) != 3 {
+		t.Errorf("Expected OnNotify for every notification, got %d", len(notified))
+	}
+}
+
+func TestAlerterErrorRate(t *testing.T) {
+	webhook := &webhookServer{}
+	server := httptest.NewServer(webhook)
+	defer server.Close()
+
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	rate, _ := ParseAlertRule("error_rate>20%")
+	unknown, _ := ParseAlertRule("no_such_metric>1")
+	var errs []error
+	alerter := NewAlerter(collector, []AlertRule{rate, unknown}, AlertConfig{
+		WebhookURL: server.URL,
+		Format:     "slack",
+		OnError:    func(err error) { errs = append(errs, err) },
+	})
+
+	// The error rate covers the requests since the last evaluation
+	for i := 0; i < 4; i++ {
+		collector.RecordRequest()(nil)
+	}
+	collector.RecordRequest()(CategorizeError(CategoryInternal, nil))
+	collector.RecordRequest()(CategorizeError(CategoryInternal, nil))
+	alerter.Evaluate(time.Now())
+	bodies := webhook.received()
+	var message map[string]string
+	if len(bodies) != 1 || json.Unmarshal([]byte(bodies[0]), &message) != nil || message["text"] != "[FIRING] error_rate>20%, now 33.33%" {
+		t.Errorf("Expected a Slack message for the error rate, got %v", bodies)
+	}
+
+	for i := 0; i < 10; i++ {
+		collector.RecordRequest()(nil)
+	}
+	alerter.Evaluate(time.Now())
+	if bodies = webhook.received(); len(bodies) != 2 || !strings.HasPrefix(bodies[1], `{"text":"[RESOLVED] error_rate>20%, now 0.00%`) {
+		t.Errorf("Expected the error rate to be resolved, got %v", bodies)
+	}
+
+	// A rule on a metric that doesn't exist is reported once
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "no_such_metric") {
+		t.Errorf("Expected the unknown metric to be reported once, got %v", errs)
+	}
+}
+
+func TestAlerterStart(t *testing.T) {
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	rule, _ := ParseAlertRule("goroutines>0")
+	fired := make(chan AlertNotification, 1)
+	alerter := NewAlerter(collector, []AlertRule{rule}, AlertConfig{
+		Interval: 10 * time.Millisecond,
+		OnNotify: func(n AlertNotification) {
+			select {
+			case fired <- n:
+			default:
+			}
+		},
+	})
+	alerter.Start()
+	defer alerter.Stop()
+
+	// The rules are evaluated on every tick
+	select {
+	case n := <-fired:
+		if n.Status != "firing" || n.Metric != "goroutines" {
+			t.Errorf("Expected the goroutines rule to fire, got %+v", n)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the rules to be evaluated")
+	}
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrorCategory classifies why a request failed, so failures can be counted
+// by their cause
+type ErrorCategory int
+
+const (
+	CategoryInternal    ErrorCategory = iota // The server failed, the default for errors without a category
+	CategoryValidation                       // The request was invalid
+	CategoryRateLimited                      // The request was rejected by a rate limit
+	CategoryTimeout                          // The request took too long
+	categoryCount
+)
+
+// ErrorCategories lists the error categories in the order they are reported
+var ErrorCategories = []ErrorCategory{CategoryValidation, CategoryRateLimited, CategoryTimeout, CategoryInternal}
+
+// String returns the name of the category as used in the metric names
+func (c ErrorCategory) String() string {
+	switch c {
+	case CategoryValidation:
+		return "validation"
+	case CategoryRateLimited:
+		return "rate_limited"
+	case CategoryTimeout:
+		return "timeout"
+	default:
+		return "internal"
+	}
+}
+
+// categorizedError is an error with the category it is counted under
+type categorizedError struct {
+	category ErrorCategory
+	err      error
+}
+
+func (e *categorizedError) Error() string {
+	if e.err == nil {
+		return e.category.String()
+	}
+	return e.category.String() + ": " + e.err.Error()
+}
+
+func (e *categorizedError) Unwrap() error {
+	return e.err
+}
+
+// CategorizeError returns an error that fails a request under the given
+// category when passed to the function returned by RecordRequest
+// err may be nil when there is nothing more to say than the category
+func CategorizeError(category ErrorCategory, err error) error {
+	return &categorizedError{category: category, err: err}
+}
+
+// ErrorCategoryOf returns the category of an error: the one given to
+// CategorizeError, CategoryTimeout for an exceeded context deadline, and
+// CategoryInternal otherwise
+func ErrorCategoryOf(err error) ErrorCategory {
+	var categorized *categorizedError
+	switch {
+	case errors.As(err, &categorized) && categorized.category >= 0 && categorized.category < categoryCount:
+		return categorized.category
+	case errors.Is(err, context.DeadlineExceeded):
+		return CategoryTimeout
+	default:
+		return CategoryInternal
+	}
+}
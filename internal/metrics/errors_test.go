@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorCategoryOf(t *testing.T) {
+	invalid := errors.New("letter must be a single letter")
+	for _, tc := range []struct {
+		err      error
+		expected ErrorCategory
+	}{
+		{CategorizeError(CategoryValidation, invalid), CategoryValidation},
+		{fmt.Errorf("generate: %w", CategorizeError(CategoryRateLimited, nil)), CategoryRateLimited},
+		{fmt.Errorf("lookup: %w", context.DeadlineExceeded), CategoryTimeout},
+		{errors.New("disk full"), CategoryInternal},
+		{CategorizeError(ErrorCategory(42), invalid), CategoryInternal},
+	} {
+		if got := ErrorCategoryOf(tc.err); got != tc.expected {
+			t.Errorf("Expected %v to be a %v error, got %v", tc.err, tc.expected, got)
+		}
+	}
+
+	// The wrapped error is kept
+	err := CategorizeError(CategoryValidation, invalid)
+	if !errors.Is(err, invalid) || err.Error() != "validation: letter must be a single letter" {
+		t.Errorf("Expected the wrapped error, got %v", err)
+	}
+	if err := CategorizeError(CategoryRateLimited, nil); err.Error() != "rate_limited" {
+		t.Errorf("Expected the category as the message, got %v", err)
+	}
+}
+
+func TestFailureCounts(t *testing.T) {
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+
+	collector.RecordRequest()(CategorizeError(CategoryValidation, nil))
+	collector.RecordRequest()(CategorizeError(CategoryValidation, nil))
+	collector.RecordRequest()(CategorizeError(CategoryTimeout, nil))
+	collector.RecordRequest()(errors.New("boom"))
+	collector.RecordRequest()(nil)
+
+	expected := map[ErrorCategory]uint64{CategoryValidation: 2, CategoryRateLimited: 0, CategoryTimeout: 1, CategoryInternal: 1}
+	metrics := collector.GetCurrentMetrics()
+	for category, count := range expected {
+		if got := collector.GetFailureCount(category); got != count {
+			t.Errorf("Expected %d %v failures, got %d", count, category, got)
+		}
+		if got := metrics["requests_failed_"+category.String()]; got != count {
+			t.Errorf("Expected requests_failed_%v to be %d, got %v", category, count, got)
+		}
+		if got := collector.snapshot().counters["requests_failed_"+category.String()]; got != count {
+			t.Errorf("Expected the %v failures in the snapshot, got %d", category, got)
+		}
+	}
+	if collector.GetRequestFailed() != 4 {
+		t.Errorf("Expected 4 failed requests, got %d", collector.GetRequestFailed())
+	}
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"math/bits"
+	"time"
+)
+
+// Layout of the histogram buckets, as in an HDR histogram
+// Durations below subBucketCount nanoseconds get a bucket each; above that,
+// every power of two is split into subBucketHalf buckets of equal width, so a
+// bucket is never wider than 1/subBucketHalf of the durations it holds
+const (
+	subBucketBits  = 7
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+	bucketCount    = subBucketCount + (63-subBucketBits)*subBucketHalf
+)
+
+// Histogram counts durations in buckets of bounded relative width, so
+// percentiles are read in time proportional to the number of buckets, however
+// many durations were recorded, and are within 1% of the exact value
+// A Histogram is not safe for concurrent use
+type Histogram struct {
+	counts [bucketCount]uint32
+	total  uint64
+}
+
+// NewHistogram creates an empty histogram
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// bucketIndex returns the bucket a duration is counted in
+// Negative durations are counted as 0
+func bucketIndex(d time.Duration) int {
+	if d < subBucketCount {
+		if d < 0 {
+			return 0
+		}
+		return int(d)
+	}
+	v := uint64(d)
+	shift := bits.Len64(v) - subBucketBits
+	return subBucketCount + (shift-1)*subBucketHalf + int(v>>shift) - subBucketHalf
+}
+
+// bucketValue returns the duration a bucket stands for, the middle of its range
+func bucketValue(index int) time.Duration {
+	if index < subBucketCount {
+		return time.Duration(index)
+	}
+	shift := (index-subBucketCount)/subBucketHalf + 1
+	mantissa := uint64((index-subBucketCount)%subBucketHalf + subBucketHalf)
+	lower := mantissa << shift
+	return time.Duration(lower + (uint64(1)<<shift)/2)
+}
+
+// Record counts a duration
+func (h *Histogram) Record(d time.Duration) {
+	h.counts[bucketIndex(d)]++
+	h.total++
+}
+
+// Remove uncounts a duration that was recorded before, e.g. once it drops out
+// of a window of recent durations
+func (h *Histogram) Remove(d time.Duration) {
+	if i := bucketIndex(d); h.counts[i] > 0 {
+		h.counts[i]--
+		h.total--
+	}
+}
+
+// Count returns the number of durations counted
+func (h *Histogram) Count() uint64 {
+	return h.total
+}
+
+// Percentile returns the nth percentile of the counted durations, or 0 if
+// there are none
+// Like the nearest-rank method on sorted durations, the result is the duration
+// at rank (count-1)*n/100, counting from 0
+func (h *Histogram) Percentile(percentile float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	if percentile < 0 {
+		percentile = 0
+	} else if percentile > 100 {
+		percentile = 100
+	}
+	rank := uint64(float64(h.total-1) * percentile / 100.0)
+
+	var seen uint64
+	for i, count := range h.counts {
+		seen += uint64(count)
+		if seen > rank {
+			return bucketValue(i)
+		}
+	}
+	return 0
+}
+
+// Reset clears the histogram
+func (h *Histogram) Reset() {
+	*h = Histogram{}
+}
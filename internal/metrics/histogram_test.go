@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	// Every duration falls in a bucket whose value is within 1% of it
+	for _, d := range []time.Duration{0, 1, 127, 128, 129, 255, 256, 1000, time.Microsecond + 1, 123456789, time.Hour, 1<<63 - 1} {
+		got := bucketValue(bucketIndex(d))
+		if d < subBucketCount && got != d {
+			t.Errorf("Expected %d to be counted exactly, got %d", d, got)
+		}
+		if !withinPercent(got, d) {
+			t.Errorf("Expected the bucket of %v to be within 1%%, got %v", d, got)
+		}
+	}
+	if i := bucketIndex(1<<63 - 1); i != bucketCount-1 {
+		t.Errorf("Expected the largest duration in the last bucket, got %d of %d", i, bucketCount)
+	}
+	if i := bucketIndex(-time.Second); i != 0 {
+		t.Errorf("Expected negative durations to be counted as 0, got bucket %d", i)
+	}
+
+	// Buckets are in increasing order of duration
+	for i := 1; i < bucketCount; i++ {
+		if bucketValue(i) <= bucketValue(i-1) {
+			t.Fatalf("Expected bucket %d to be above bucket %d", i, i-1)
+		}
+	}
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	h := NewHistogram()
+	if h.Percentile(50) != 0 || h.Count() != 0 {
+		t.Error("Expected an empty histogram to have no percentiles")
+	}
+
+	// Compare with the exact percentiles of random durations
+	random := rand.New(rand.NewSource(1))
+	durations := make([]time.Duration, 10000)
+	for i := range durations {
+		durations[i] = time.Duration(random.ExpFloat64() * float64(20*time.Millisecond))
+		h.Record(durations[i])
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	for _, p := range []float64{0, 50, 90, 99, 99.9, 100} {
+		exact := durations[int(float64(len(durations)-1)*p/100)]
+		if got := h.Percentile(p); !withinPercent(got, exact) {
+			t.Errorf("Expected P%v to be about %v, got %v", p, exact, got)
+		}
+	}
+
+	// Removed durations no longer count
+	for _, d := range durations[5000:] {
+		h.Remove(d)
+	}
+	if h.Count() != 5000 || !withinPercent(h.Percentile(100), durations[4999]) {
+		t.Errorf("Expected the 5000 shortest durations, got %d up to %v", h.Count(), h.Percentile(100))
+	}
+	h.Remove(time.Hour) // Never recorded
+	if h.Count() != 5000 {
+		t.Errorf("Expected removing an unrecorded duration to do nothing, got %d", h.Count())
+	}
+
+	h.Reset()
+	if h.Count() != 0 || h.Percentile(99) != 0 {
+		t.Error("Expected a reset histogram to be empty")
+	}
+}
+
+func BenchmarkPercentile(b *testing.B) {
+	timeSlice := NewConcurrentTimeSlice()
+	for i := 0; i < 10000; i++ {
+		timeSlice.Add(time.Duration(i) * time.Microsecond)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		timeSlice.GetPercentile(99)
+	}
+}
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// HistoryPoint is a snapshot of the numeric metrics at a point in time
+type HistoryPoint struct {
+	Time   time.Time          `json:"time"`
+	Values map[string]float64 `json:"values"`
+}
+
+// historySample is a snapshot as kept by History: the values in the order of
+// names, which is shared by the samples taken while the metrics didn't change
+type historySample struct {
+	time   time.Time
+	names  []string
+	values []float64
+}
+
+// History keeps snapshots of the metrics of a collector taken every interval
+// for a retention period, so trends can be shown and not just current values
+// The snapshots are kept in a ring buffer, the oldest being replaced once it
+// is full
+type History struct {
+	collector *MetricsCollector
+	interval  time.Duration
+
+	mutex   sync.RWMutex
+	samples []historySample // Ring buffer of the snapshots
+	next    int             // Position of the next snapshot
+	full    bool            // Whether the buffer has wrapped around
+	names   []string        // Names of the latest snapshot
+
+	startOnce sync.Once
+	stopCh    chan struct{}
+	done      chan struct{} // Closed once the snapshots have stopped
+}
+
+// NewHistory creates a history of the metrics of collector, taking a snapshot
+// every interval and keeping them for retention
+func NewHistory(collector *MetricsCollector, interval, retention time.Duration) *History {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	size := int(retention / interval)
+	if size < 1 {
+		size = 1
+	}
+	return &History{
+		collector: collector,
+		interval:  interval,
+		samples:   make([]historySample, size),
+		stopCh:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Interval returns the time between two snapshots
+func (h *History) Interval() time.Duration {
+	return h.interval
+}
+
+// Retention returns how long snapshots are kept
+func (h *History) Retention() time.Duration {
+	return time.Duration(len(h.samples)) * h.interval
+}
+
+// Start takes a snapshot every interval until Stop is called
+func (h *History) Start() {
+	h.startOnce.Do(func() {
+		go h.run()
+	})
+}
+
+// run takes a snapshot every interval until stopCh is closed
+func (h *History) run() {
+	defer close(h.done)
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.Record(time.Now())
+		case <-h.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops the snapshots
+func (h *History) Stop() {
+	close(h.stopCh)
+	// Without snapshots running there is nothing to wait for, and none can start
+	h.startOnce.Do(func() { close(h.done) })
+	<-h.done
+}
+
+// Record takes a snapshot of the metrics at the given time
+func (h *History) Record(at time.Time) {
+	values := h.collector.Values()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// The names are only copied when metrics were added or removed
+	names := h.names
+	if len(names) != len(values) {
+		names = nil
+	}
+	for _, name := range names {
+		if _, ok := values[name]; !ok {
+			names = nil
+			break
+		}
+	}
+	if names == nil {
+		names = sortedKeys(values)
+		h.names = names
+	}
+
+	sample := historySample{time: at, names: names, values: make([]float64, len(names))}
+	for i, name := range names {
+		sample.values[i] = values[name]
+	}
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Points returns the snapshots taken since the given time, oldest first
+// If names are given, the points only hold those metrics
+func (h *History) Points(since time.Time, names ...string) []HistoryPoint {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	var wanted map[string]bool
+	if len(names) > 0 {
+		wanted = make(map[string]bool, len(names))
+		for _, name := range names {
+			wanted[name] = true
+		}
+	}
+
+	start, count := 0, h.next
+	if h.full {
+		start, count = h.next, len(h.samples)
+	}
+	points := []HistoryPoint{}
+	for i := 0; i < count; i++ {
+		sample := h.samples[(start+i)%len(h.samples)]
+		if sample.time.Before(since) {
+			continue
+		}
+		point := HistoryPoint{Time: sample.time, Values: make(map[string]float64, len(sample.names))}
+		for j, name := range sample.names {
+			if wanted == nil || wanted[name] {
+				point.Values[name] = sample.values[j]
+			}
+		}
+		points = append(points, point)
+	}
+	return points
+}
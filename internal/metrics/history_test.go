@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+
+	// Room for 3 snapshots
+	history := NewHistory(collector, 10*time.Second, 30*time.Second)
+	if history.Interval() != 10*time.Second || history.Retention() != 30*time.Second {
+		t.Errorf("Expected snapshots every 10s for 30s, got %v for %v", history.Interval(), history.Retention())
+	}
+	if points := history.Points(time.Time{}); points == nil || len(points) != 0 {
+		t.Errorf("Expected no points, got %v", points)
+	}
+
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 4; i++ {
+		collector.RecordRequest()(nil)
+		history.Record(start.Add(time.Duration(i) * 10 * time.Second))
+	}
+
+	// The oldest snapshot was replaced, the rest are returned oldest first
+	points := history.Points(time.Time{})
+	if len(points) != 3 {
+		t.Fatalf("Expected 3 points, got %d", len(points))
+	}
+	for i, point := range points {
+		if !point.Time.Equal(start.Add(time.Duration(i+1) * 10 * time.Second)) {
+			t.Errorf("Expected point %d at %v, got %v", i, start.Add(time.Duration(i+1)*10*time.Second), point.Time)
+		}
+		if point.Values["requests_total"] != float64(i+2) {
+			t.Errorf("Expected %d requests at point %d, got %v", i+2, i, point.Values["requests_total"])
+		}
+		if _, ok := point.Values["response_time_p99_ms"]; !ok {
+			t.Errorf("Expected the percentiles at point %d", i)
+		}
+	}
+
+	// Points can be limited to a window and to some metrics
+	points = history.Points(start.Add(25*time.Second), "requests_total", "goroutines")
+	if len(points) != 1 || len(points[0].Values) != 2 || points[0].Values["goroutines"] <= 0 {
+		t.Errorf("Expected the last point with 2 metrics, got %+v", points)
+	}
+
+	// Metrics registered later show up from then on
+	collector.RegisterGauge("queue_depth", func() interface{} { return 5 })
+	history.Record(start.Add(40 * time.Second))
+	points = history.Points(time.Time{})
+	if _, ok := points[1].Values["queue_depth"]; ok || points[2].Values["queue_depth"] != 5 {
+		t.Errorf("Expected the new gauge in the last point only, got %v and %v", points[1].Values, points[2].Values)
+	}
+}
+
+func TestHistoryStart(t *testing.T) {
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	history := NewHistory(collector, 10*time.Millisecond, time.Second)
+	history.Start()
+
+	// Snapshots are taken on every tick
+	deadline := time.Now().Add(time.Second)
+	for len(history.Points(time.Time{})) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	history.Stop()
+	if n := len(history.Points(time.Time{})); n < 2 {
+		t.Errorf("Expected snapshots to be taken, got %d", n)
+	}
+}
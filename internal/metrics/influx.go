@@ -0,0 +1,266 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxInfluxBatch is the number of points sent in one write request
+const maxInfluxBatch = 5000
+
+// influxAttempts is how often a batch is tried before it is kept for the next push
+const influxAttempts = 3
+
+// InfluxConfig configures an InfluxEmitter
+type InfluxConfig struct {
+	URL         string            // Write endpoint, e.g. http://localhost:8086/api/v2/write?org=acme&bucket=namegen
+	Token       string            // API token sent as "Authorization: Token ..." (empty sends none)
+	Measurement string            // Measurement of the points (default "namegen")
+	Tags        map[string]string // Tags of every point, e.g. host=web-1
+	Interval    time.Duration     // How often a snapshot is taken and pushed (default 10s)
+	Timeout     time.Duration     // Timeout of a write request (default 5s)
+	MaxPending  int               // Points kept while the endpoint is unreachable; the oldest are dropped (default 8640)
+	OnError     func(error)       // Called when a push fails, e.g. to log it
+}
+
+// InfluxEmitter pushes snapshots of the metrics of a collector to InfluxDB in
+// line protocol
+// Every interval the current metrics become a point, and the points not yet
+// written are sent in batches; when the endpoint can't be reached they are
+// kept and sent with the next push
+type InfluxEmitter struct {
+	collector *MetricsCollector
+	config    InfluxConfig
+	client    *http.Client
+	prefix    string // Escaped measurement and tags of every point
+
+	mutex   sync.Mutex
+	pending []string // Points not yet written, oldest first
+	dropped uint64   // Points dropped because MaxPending were pending
+
+	startOnce sync.Once
+	stopCh    chan struct{}
+	done      chan struct{} // Closed once the pushes have stopped
+}
+
+// NewInfluxEmitter creates an emitter that pushes the metrics of collector as
+// configured
+func NewInfluxEmitter(collector *MetricsCollector, config InfluxConfig) *InfluxEmitter {
+	if config.Measurement == "" {
+		config.Measurement = "namegen"
+	}
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.MaxPending <= 0 {
+		config.MaxPending = 8640 // A day of points at the default interval
+	}
+
+	// Tags are sorted by key, as InfluxDB recommends
+	prefix := escapeInflux(config.Measurement, ", ")
+	keys := make([]string, 0, len(config.Tags))
+	for key := range config.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if config.Tags[key] != "" {
+			prefix += "," + escapeInflux(key, ",= ") + "=" + escapeInflux(config.Tags[key], ",= ")
+		}
+	}
+
+	return &InfluxEmitter{
+		collector: collector,
+		config:    config,
+		client:    &http.Client{Timeout: config.Timeout},
+		prefix:    prefix,
+		stopCh:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start pushes a snapshot every interval until Stop is called
+func (e *InfluxEmitter) Start() {
+	e.startOnce.Do(func() {
+		go e.run()
+	})
+}
+
+// run pushes a snapshot every interval until stopCh is closed
+func (e *InfluxEmitter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.Record(time.Now())
+			e.report(e.Flush())
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops the pushes and pushes a last snapshot
+func (e *InfluxEmitter) Stop() error {
+	close(e.stopCh)
+	// Without pushes running there is nothing to wait for, and none can start
+	e.startOnce.Do(func() { close(e.done) })
+	<-e.done
+
+	e.Record(time.Now())
+	return e.Flush()
+}
+
+// report passes a push error to OnError
+func (e *InfluxEmitter) report(err error) {
+	if err != nil && e.config.OnError != nil {
+		e.config.OnError(err)
+	}
+}
+
+// Record takes a snapshot of the metrics as a point at the given time, to be
+// written by the next Flush
+func (e *InfluxEmitter) Record(at time.Time) {
+	current := e.collector.snapshot()
+	var fields []string
+	for _, name := range sortedKeys(current.counters) {
+		fields = append(fields, escapeInflux(name, ",= ")+"="+strconv.FormatUint(current.counters[name], 10)+"u")
+	}
+	for _, name := range sortedKeys(current.gauges) {
+		if value := current.gauges[name]; !math.IsNaN(value) && !math.IsInf(value, 0) {
+			fields = append(fields, escapeInflux(name, ",= ")+"="+strconv.FormatFloat(value, 'f', -1, 64))
+		}
+	}
+	for _, p := range []float64{50, 90, 99} {
+		d := e.collector.GetResponseTimePercentile(p)
+		fields = append(fields, fmt.Sprintf("response_time_p%.0f_ms=%s", p, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)))
+	}
+	point := e.prefix + " " + strings.Join(fields, ",") + " " + strconv.FormatInt(at.UnixNano(), 10)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.pending = append(e.pending, point)
+	if excess := len(e.pending) - e.config.MaxPending; excess > 0 {
+		e.pending = e.pending[excess:]
+		e.dropped += uint64(excess)
+	}
+}
+
+// Flush writes the pending points in batches, trying each batch a few times
+// Batches the endpoint rejects as invalid are dropped; the rest of the points
+// are kept for the next Flush when a batch can't be written
+func (e *InfluxEmitter) Flush() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for len(e.pending) > 0 {
+		n := len(e.pending)
+		if n > maxInfluxBatch {
+			n = maxInfluxBatch
+		}
+		err := e.write(e.pending[:n])
+		var rejected *influxRejected
+		if err != nil && !errors.As(err, &rejected) {
+			return err
+		}
+		e.pending = e.pending[n:]
+		if err != nil {
+			return err
+		}
+	}
+	e.pending = nil
+	return nil
+}
+
+// Pending returns the number of points not yet written, and the number dropped
+// so far because too many were pending
+func (e *InfluxEmitter) Pending() (pending int, dropped uint64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return len(e.pending), e.dropped
+}
+
+// influxRejected is the error of a batch the endpoint won't accept however
+// often it is sent, e.g. for a bad token or malformed points
+type influxRejected struct {
+	status int
+	body   string
+}
+
+func (e *influxRejected) Error() string {
+	return fmt.Sprintf("influx: write rejected with status %d: %s", e.status, e.body)
+}
+
+// write sends a batch of points, retrying with backoff when the endpoint is
+// unreachable, overloaded or fails
+func (e *InfluxEmitter) write(points []string) error {
+	body := []byte(strings.Join(points, "\n") + "\n")
+
+	var err error
+	backoff := 100 * time.Millisecond
+	for attempt := 1; attempt <= influxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var req *http.Request
+		req, err = http.NewRequest(http.MethodPost, e.config.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		if e.config.Token != "" {
+			req.Header.Set("Authorization", "Token "+e.config.Token)
+		}
+
+		var resp *http.Response
+		resp, err = e.client.Do(req)
+		if err != nil {
+			err = fmt.Errorf("influx: %w", err)
+			continue
+		}
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode/100 == 2:
+			return nil
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5:
+			err = fmt.Errorf("influx: write failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+		default:
+			return &influxRejected{status: resp.StatusCode, body: strings.TrimSpace(string(message))}
+		}
+	}
+	return err
+}
+
+// escapeInflux escapes the given characters of a line protocol name with backslashes
+func escapeInflux(s, special string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\n' {
+			r = ' '
+		}
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
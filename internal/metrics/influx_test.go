@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// influxServer stands in for InfluxDB, answering writes with the status the
+// test sets and keeping the points it accepts
+type influxServer struct {
+	mutex  sync.Mutex
+	status int
+	writes int
+	points []string
+	auth   string
+}
+
+func (s *influxServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.writes++
+	s.auth = r.Header.Get("Authorization")
+	if s.status != http.StatusNoContent {
+		http.Error(w, "unavailable", s.status)
+		return
+	}
+	s.points = append(s.points, strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")...)
+	w.WriteHeader(s.status)
+}
+
+func TestInfluxEmitter(t *testing.T) {
+	influx := &influxServer{status: http.StatusNoContent}
+	server := httptest.NewServer(influx)
+	defer server.Close()
+
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	collector.RegisterGauge("queue_depth", func() interface{} { return int64(2) })
+	done := collector.RecordRequest()
+	done(nil)
+	collector.RecordStatus(200)
+
+	emitter := NewInfluxEmitter(collector, InfluxConfig{
+		URL:   server.URL + "/api/v2/write?org=acme&bucket=namegen",
+		Token: "secret",
+		Tags:  map[string]string{"host": "web 1", "env": "prod"},
+	})
+	at := time.Unix(1700000000, 0)
+	emitter.Record(at)
+	if err := emitter.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if len(influx.points) != 1 || influx.auth != "Token secret" {
+		t.Fatalf("Expected one point with the token, got %v (%q)", influx.points, influx.auth)
+	}
+	point := influx.points[0]
+	if !strings.HasPrefix(point, `namegen,env=prod,host=web\ 1 `) || !strings.HasSuffix(point, " 1700000000000000000") {
+		t.Errorf("Expected the measurement, sorted tags and time, got %s", point)
+	}
+	for _, field := range []string{"requests_total=1u", "responses_2xx=1u", "queue_depth=2", "goroutines=", "response_time_p99_ms="} {
+		if !strings.Contains(point, field) {
+			t.Errorf("Expected field %s in %s", field, point)
+		}
+	}
+	if pending, _ := emitter.Pending(); pending != 0 {
+		t.Errorf("Expected no pending points, got %d", pending)
+	}
+}
+
+func TestInfluxEmitterRetry(t *testing.T) {
+	influx := &influxServer{status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(influx)
+	defer server.Close()
+
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	emitter := NewInfluxEmitter(collector, InfluxConfig{URL: server.URL, MaxPending: 3})
+
+	// While the endpoint fails, every batch is tried a few times and kept
+	emitter.Record(time.Unix(1, 0))
+	if err := emitter.Flush(); err == nil {
+		t.Fatal("Expected the flush to fail")
+	}
+	if influx.writes != influxAttempts {
+		t.Errorf("Expected %d attempts, got %d", influxAttempts, influx.writes)
+	}
+
+	// Only the latest MaxPending points are kept
+	for i := 2; i <= 4; i++ {
+		emitter.Record(time.Unix(int64(i), 0))
+	}
+	if pending, dropped := emitter.Pending(); pending != 3 || dropped != 1 {
+		t.Errorf("Expected 3 pending and 1 dropped point, got %d and %d", pending, dropped)
+	}
+
+	// Once the endpoint is back, the kept points are written in order
+	influx.mutex.Lock()
+	influx.status = http.StatusNoContent
+	influx.mutex.Unlock()
+	if err := emitter.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if len(influx.points) != 3 || !strings.HasSuffix(influx.points[0], " 2000000000") || !strings.HasSuffix(influx.points[2], " 4000000000") {
+		t.Errorf("Expected the points at 2s to 4s, got %v", influx.points)
+	}
+}
+
+func TestInfluxEmitterRejected(t *testing.T) {
+	influx := &influxServer{status: http.StatusBadRequest}
+	server := httptest.NewServer(influx)
+	defer server.Close()
+
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	var reported []error
+	emitter := NewInfluxEmitter(collector, InfluxConfig{
+		URL:      server.URL,
+		Interval: 10 * time.Millisecond,
+		OnError:  func(err error) { reported = append(reported, err) },
+	})
+
+	// Points the endpoint rejects are dropped rather than retried
+	emitter.Record(time.Now())
+	err := emitter.Flush()
+	var rejected *influxRejected
+	if !errors.As(err, &rejected) || rejected.status != http.StatusBadRequest || influx.writes != 1 {
+		t.Errorf("Expected one rejected write, got %v after %d writes", err, influx.writes)
+	}
+	if pending, _ := emitter.Pending(); pending != 0 {
+		t.Errorf("Expected the rejected points to be dropped, got %d pending", pending)
+	}
+
+	// Errors of the periodic pushes are reported
+	emitter.Start()
+	time.Sleep(50 * time.Millisecond)
+	emitter.Stop()
+	if len(reported) == 0 {
+		t.Error("Expected the failed pushes to be reported")
+	}
+}
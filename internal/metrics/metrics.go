@@ -2,8 +2,8 @@ package metrics
 
 import (
 	"fmt"
+	"math/rand"
 	"runtime"
-	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,25 +15,76 @@ type MetricsCollector struct {
 	requestsTotal     uint64
 	requestsSucceeded uint64
 	requestsFailed    uint64
+	failures          [categoryCount]uint64 // Failed requests per error category
+	statusClasses     [6]uint64 // Responses per status class, indexed by status code / 100
+	tooManyRequests   uint64    // Responses with status 429, also counted as 4xx
 	responseTimes     *ConcurrentTimeSlice
 	maxConcurrent     int64
 	currentConcurrent int64
 	memoryUsage       uint64
+	runtimeStats      RuntimeStats // Sampled with the memory usage, guarded by mutex
 	cpuUsage          float64
+	rate1m            ewmaRate  // Requests per second over about the last minute, guarded by mutex
+	rate5m            ewmaRate  // Requests per second over about the last 5 minutes, guarded by mutex
+	rateCount         uint64    // Requests at the last rate update, guarded by mutex
+	rateTime          time.Time // Time of the last rate update, guarded by mutex
+	gauges            map[string]func() interface{}
+	gaugeGroups       []func() map[string]interface{} // Gauges sampled together, see RegisterGauges
+	timingHooks       []func(time.Duration) // Called with the response time of every request
 	mutex             sync.RWMutex
 	stopCh            chan struct{}
 }
 
-// ConcurrentTimeSlice is a thread-safe slice of response times
+// RuntimeStats describes the Go runtime: goroutines, heap and garbage collection
+type RuntimeStats struct {
+	Goroutines   int
+	HeapObjects  uint64        // Allocated heap objects
+	HeapInUse    uint64        // Bytes in in-use heap spans
+	NextGC       uint64        // Heap size at which the next collection runs
+	NumGC        uint32        // Completed collections
+	GCPauseTotal time.Duration // Stop-the-world pauses of all collections
+	LastGCPause  time.Duration // Pause of the most recent collection
+}
+
+// defaultTimeSamples is the number of response times kept by default
+const defaultTimeSamples = 10000
+
+// ConcurrentTimeSlice is a thread-safe sample of the response times
+// Once the slice is full it keeps a uniform random sample of all the times
+// added (reservoir sampling), so a burst of requests can't crowd out the rest
+// of the period. The times are also counted in a histogram, so percentiles are
+// read without copying and sorting the samples
 type ConcurrentTimeSlice struct {
-	times []time.Duration
-	mutex sync.RWMutex
+	times     []time.Duration // The sample, at most capacity times
+	capacity  int
+	seen      int64         // Times added, including those not kept
+	random    *rand.Rand    // Picks the times replaced once the sample is full
+	histogram *Histogram    // Counts the times in the sample
+	sum       time.Duration // Sum of the times in the sample
+	mutex     sync.RWMutex
 }
 
-// NewConcurrentTimeSlice creates a new concurrent time slice
+// NewConcurrentTimeSlice creates a new concurrent time slice that keeps up to
+// 10,000 response times
 func NewConcurrentTimeSlice() *ConcurrentTimeSlice {
+	return NewConcurrentTimeSliceWithCapacity(defaultTimeSamples)
+}
+
+// NewConcurrentTimeSliceWithCapacity creates a new concurrent time slice that
+// keeps up to capacity response times (the default if not positive)
+func NewConcurrentTimeSliceWithCapacity(capacity int) *ConcurrentTimeSlice {
+	if capacity <= 0 {
+		capacity = defaultTimeSamples
+	}
+	preallocated := capacity
+	if preallocated > 1000 {
+		preallocated = 1000 // Pre-allocate for performance
+	}
 	return &ConcurrentTimeSlice{
-		times: make([]time.Duration, 0, 1000), // Pre-allocate for performance
+		times:     make([]time.Duration, 0, preallocated),
+		capacity:  capacity,
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		histogram: NewHistogram(),
 	}
 }
 
@@ -42,37 +93,33 @@ func (s *ConcurrentTimeSlice) Add(t time.Duration) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	
-	s.times = append(s.times, t)
-	
-	// Limit the size of the slice to prevent memory leaks
-	// Keep the most recent 10,000 samples
-	if len(s.times) > 10000 {
-		s.times = s.times[len(s.times)-10000:]
+	// Limit the number of samples to prevent memory leaks
+	// Once full, the nth time replaces a random kept one with probability
+	// capacity/n, so every time added so far is kept with the same probability
+	s.seen++
+	if len(s.times) < s.capacity {
+		s.times = append(s.times, t)
+	} else {
+		i := s.random.Int63n(s.seen)
+		if i >= int64(s.capacity) {
+			return
+		}
+		replaced := s.times[i]
+		s.histogram.Remove(replaced)
+		s.sum -= replaced
+		s.times[i] = t
 	}
+	s.histogram.Record(t)
+	s.sum += t
 }
 
-// GetPercentile returns the nth percentile of response times
+// GetPercentile returns the nth percentile of response times, within 1% of
+// the exact value
 func (s *ConcurrentTimeSlice) GetPercentile(percentile float64) time.Duration {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 	
-	if len(s.times) == 0 {
-		return 0
-	}
-	
-	// Make a copy of the times slice to avoid modifying the original
-	timesCopy := make([]time.Duration, len(s.times))
-	copy(timesCopy, s.times)
-	
-	// Sort the copy
-	sort.Slice(timesCopy, func(i, j int) bool {
-		return timesCopy[i] < timesCopy[j]
-	})
-	
-	// Calculate the index for the percentile
-	index := int(float64(len(timesCopy)-1) * percentile / 100.0)
-	
-	return timesCopy[index]
+	return s.histogram.Percentile(percentile)
 }
 
 // Len returns the number of response times in the slice
@@ -83,6 +130,14 @@ func (s *ConcurrentTimeSlice) Len() int {
 	return len(s.times)
 }
 
+// Seen returns the number of response times added, including those not kept
+func (s *ConcurrentTimeSlice) Seen() int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	return s.seen
+}
+
 // Average returns the average response time
 func (s *ConcurrentTimeSlice) Average() time.Duration {
 	s.mutex.RLock()
@@ -92,24 +147,31 @@ func (s *ConcurrentTimeSlice) Average() time.Duration {
 		return 0
 	}
 	
-	var sum time.Duration
-	for _, t := range s.times {
-		sum += t
-	}
-	
-	return sum / time.Duration(len(s.times))
+	return s.sum / time.Duration(len(s.times))
 }
 
 // NewMetricsCollector creates a new metrics collector
 func NewMetricsCollector(maxConcurrent int64) *MetricsCollector {
+	return NewMetricsCollectorWithSamples(maxConcurrent, defaultTimeSamples)
+}
+
+// NewMetricsCollectorWithSamples creates a new metrics collector that keeps a
+// sample of up to samples response times for the percentiles
+func NewMetricsCollectorWithSamples(maxConcurrent int64, samples int) *MetricsCollector {
 	collector := &MetricsCollector{
 		startTime:         time.Now(),
-		responseTimes:     NewConcurrentTimeSlice(),
+		responseTimes:     NewConcurrentTimeSliceWithCapacity(samples),
+		rate1m:            ewmaRate{window: time.Minute},
+		rate5m:            ewmaRate{window: 5 * time.Minute},
 		maxConcurrent:     maxConcurrent,
 		currentConcurrent: 0,
+		gauges:            make(map[string]func() interface{}),
 		stopCh:            make(chan struct{}),
 	}
 	
+	// Take an initial sample so system metrics are available before the first tick
+	collector.updateMemoryUsage()
+	
 	// Start a goroutine to periodically update system metrics
 	go collector.updateSystemMetrics()
 	
@@ -126,6 +188,7 @@ func (m *MetricsCollector) updateSystemMetrics() {
 		case <-ticker.C:
 			m.updateMemoryUsage()
 			m.updateCPUUsage()
+			m.updateRates(time.Now())
 		case <-m.stopCh:
 			return
 		}
@@ -138,6 +201,42 @@ func (m *MetricsCollector) updateMemoryUsage() {
 	runtime.ReadMemStats(&memStats)
 	
 	atomic.StoreUint64(&m.memoryUsage, memStats.Alloc)
+	
+	// The same sample describes the heap and the garbage collector
+	stats := RuntimeStats{
+		Goroutines:   runtime.NumGoroutine(),
+		HeapObjects:  memStats.HeapObjects,
+		HeapInUse:    memStats.HeapInuse,
+		NextGC:       memStats.NextGC,
+		NumGC:        memStats.NumGC,
+		GCPauseTotal: time.Duration(memStats.PauseTotalNs),
+	}
+	if memStats.NumGC > 0 {
+		stats.LastGCPause = time.Duration(memStats.PauseNs[(memStats.NumGC+255)%256])
+	}
+	m.mutex.Lock()
+	m.runtimeStats = stats
+	m.mutex.Unlock()
+}
+
+// updateRates folds the requests since the last update into the request rates
+func (m *MetricsCollector) updateRates(now time.Time) {
+	count := atomic.LoadUint64(&m.requestsTotal)
+	
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	
+	if m.rateTime.IsZero() {
+		m.rateTime = m.startTime
+	}
+	elapsed := now.Sub(m.rateTime)
+	if elapsed <= 0 {
+		return
+	}
+	rate := float64(count-m.rateCount) / elapsed.Seconds()
+	m.rate1m.update(rate, elapsed)
+	m.rate5m.update(rate, elapsed)
+	m.rateCount, m.rateTime = count, now
 }
 
 // updateCPUUsage updates the CPU usage metric
@@ -181,19 +280,39 @@ func (m *MetricsCollector) RecordRequest() func(err error) {
 		// Record the response time
 		responseTime := time.Since(startTime)
 		m.responseTimes.Add(responseTime)
+		m.mutex.RLock()
+		for _, hook := range m.timingHooks {
+			hook(responseTime)
+		}
+		m.mutex.RUnlock()
 		
 		// Decrement the concurrent requests counter
 		atomic.AddInt64(&m.currentConcurrent, -1)
 		
-		// Increment the success or failure counter
+		// Increment the success or failure counter, counting failures by category
 		if err == nil {
 			atomic.AddUint64(&m.requestsSucceeded, 1)
 		} else {
 			atomic.AddUint64(&m.requestsFailed, 1)
+			atomic.AddUint64(&m.failures[ErrorCategoryOf(err)], 1)
 		}
 	}
 }
 
+// RecordStatus counts the status code of a response by its class, e.g. 2xx
+// Responses with status 429 are also counted on their own, as they show the
+// rate limit being hit rather than clients sending bad requests
+func (m *MetricsCollector) RecordStatus(code int) {
+	class := code / 100
+	if class < 1 || class >= len(m.statusClasses) {
+		return
+	}
+	atomic.AddUint64(&m.statusClasses[class], 1)
+	if code == 429 {
+		atomic.AddUint64(&m.tooManyRequests, 1)
+	}
+}
+
 // GetCurrentMetrics returns the current metrics
 func (m *MetricsCollector) GetCurrentMetrics() map[string]interface{} {
 	// Get the current values of the metrics
@@ -202,14 +321,19 @@ func (m *MetricsCollector) GetCurrentMetrics() map[string]interface{} {
 	requestsFailed := atomic.LoadUint64(&m.requestsFailed)
 	currentConcurrent := atomic.LoadInt64(&m.currentConcurrent)
 	memoryUsage := atomic.LoadUint64(&m.memoryUsage)
+	responses2xx := atomic.LoadUint64(&m.statusClasses[2])
+	responses3xx := atomic.LoadUint64(&m.statusClasses[3])
+	responses4xx := atomic.LoadUint64(&m.statusClasses[4])
+	responses5xx := atomic.LoadUint64(&m.statusClasses[5])
 	
 	m.mutex.RLock()
 	cpuUsage := m.cpuUsage
+	runtimeStats := m.runtimeStats
+	rate1m, rate5m := m.rate1m.rate, m.rate5m.rate
 	m.mutex.RUnlock()
 	
 	// Calculate derived metrics
 	uptime := time.Since(m.startTime)
-	requestsPerSecond := float64(requestsTotal) / uptime.Seconds()
 	
 	// Calculate response time percentiles
 	p50 := m.responseTimes.GetPercentile(50)
@@ -226,24 +350,191 @@ func (m *MetricsCollector) GetCurrentMetrics() map[string]interface{} {
 	// Calculate server load as a ratio of current concurrent requests to maximum
 	serverLoad := float64(currentConcurrent) / float64(m.maxConcurrent)
 	
-	// Return the metrics as a map
-	return map[string]interface{}{
-		"uptime":              uptime.String(),
-		"requests_total":      requestsTotal,
-		"requests_succeeded":  requestsSucceeded,
-		"requests_failed":     requestsFailed,
-		"requests_per_second": fmt.Sprintf("%.2f", requestsPerSecond),
-		"success_rate":        fmt.Sprintf("%.2f%%", successRate),
-		"concurrent_requests": currentConcurrent,
-		"max_concurrent":      m.maxConcurrent,
-		"server_load":         fmt.Sprintf("%.2f/10", serverLoad*10),
-		"memory_usage":        fmt.Sprintf("%.2f MB", float64(memoryUsage)/1024/1024),
-		"cpu_usage":           fmt.Sprintf("%.2f%%", cpuUsage*100),
-		"p50_response_time":   p50.String(),
-		"p90_response_time":   p90.String(),
-		"p99_response_time":   p99.String(),
-		"avg_response_time":   avgResponseTime.String(),
+	// Build the metrics map
+	result := map[string]interface{}{
+		"uptime":                 uptime.String(),
+		"requests_total":         requestsTotal,
+		"requests_succeeded":     requestsSucceeded,
+		"requests_failed":        requestsFailed,
+		"requests_per_second_1m": fmt.Sprintf("%.2f", rate1m),
+		"requests_per_second_5m": fmt.Sprintf("%.2f", rate5m),
+		"success_rate":           fmt.Sprintf("%.2f%%", successRate),
+		"concurrent_requests":    currentConcurrent,
+		"max_concurrent":         m.maxConcurrent,
+		"server_load":            fmt.Sprintf("%.2f/10", serverLoad*10),
+		"memory_usage":           fmt.Sprintf("%.2f MB", float64(memoryUsage)/1024/1024),
+		"cpu_usage":              fmt.Sprintf("%.2f%%", cpuUsage*100),
+		"goroutines":             runtimeStats.Goroutines,
+		"heap_objects":           runtimeStats.HeapObjects,
+		"heap_in_use":            fmt.Sprintf("%.2f MB", float64(runtimeStats.HeapInUse)/1024/1024),
+		"next_gc":                fmt.Sprintf("%.2f MB", float64(runtimeStats.NextGC)/1024/1024),
+		"gc_runs":                runtimeStats.NumGC,
+		"gc_pause_total":         runtimeStats.GCPauseTotal.String(),
+		"gc_last_pause":          runtimeStats.LastGCPause.String(),
+		"p50_response_time":      p50.String(),
+		"p90_response_time":      p90.String(),
+		"p99_response_time":      p99.String(),
+		"avg_response_time":      avgResponseTime.String(),
+		"responses_total":        atomic.LoadUint64(&m.statusClasses[1]) + responses2xx + responses3xx + responses4xx + responses5xx,
+		"responses_2xx":          responses2xx,
+		"responses_3xx":          responses3xx,
+		"responses_4xx":          responses4xx,
+		"responses_5xx":          responses5xx,
+		"responses_429":          atomic.LoadUint64(&m.tooManyRequests),
+	}
+	
+	for _, category := range ErrorCategories {
+		result["requests_failed_"+category.String()] = m.GetFailureCount(category)
+	}
+	
+	// Sample the registered gauges
+	for name, value := range m.sampleGauges() {
+		result[name] = value
+	}
+	
+	return result
+}
+
+// sampleGauges reads the registered gauges, taking each group's values from
+// a single call
+func (m *MetricsCollector) sampleGauges() map[string]interface{} {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	
+	values := make(map[string]interface{}, len(m.gauges))
+	for name, gauge := range m.gauges {
+		values[name] = gauge()
+	}
+	for _, group := range m.gaugeGroups {
+		for name, value := range group() {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// RegisterGauge registers a named value that is sampled every time the current
+// metrics are read, letting other components surface their state on the dashboard
+func (m *MetricsCollector) RegisterGauge(name string, gauge func() interface{}) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	
+	m.gauges[name] = gauge
+}
+
+// RegisterGauges registers several named values that are sampled by a single
+// call every time the current metrics are read, for state that is costly to
+// read, like the statistics of a cache
+func (m *MetricsCollector) RegisterGauges(gauges func() map[string]interface{}) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	
+	m.gaugeGroups = append(m.gaugeGroups, gauges)
+}
+
+// OnResponseTime registers a function that is called with the response time of
+// every request as it completes, e.g. to forward the timings to a metrics
+// server; it must return quickly
+func (m *MetricsCollector) OnResponseTime(hook func(time.Duration)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	
+	m.timingHooks = append(m.timingHooks, hook)
+}
+
+// snapshot is a reading of the numeric metrics, for pushing them elsewhere
+type snapshot struct {
+	counters map[string]uint64  // Counts since the collector started
+	gauges   map[string]float64 // Current values, including the numeric registered gauges
+}
+
+// snapshot reads the numeric metrics
+func (m *MetricsCollector) snapshot() snapshot {
+	m.mutex.RLock()
+	cpuUsage := m.cpuUsage
+	runtimeStats := m.runtimeStats
+	rate1m, rate5m := m.rate1m.rate, m.rate5m.rate
+	m.mutex.RUnlock()
+	
+	current := snapshot{
+		counters: map[string]uint64{
+			"requests_total":     atomic.LoadUint64(&m.requestsTotal),
+			"requests_succeeded": atomic.LoadUint64(&m.requestsSucceeded),
+			"requests_failed":    atomic.LoadUint64(&m.requestsFailed),
+			"responses_2xx":      atomic.LoadUint64(&m.statusClasses[2]),
+			"responses_3xx":      atomic.LoadUint64(&m.statusClasses[3]),
+			"responses_4xx":      atomic.LoadUint64(&m.statusClasses[4]),
+			"responses_5xx":      atomic.LoadUint64(&m.statusClasses[5]),
+			"responses_429":      atomic.LoadUint64(&m.tooManyRequests),
+			"gc_runs":            uint64(runtimeStats.NumGC),
+		},
+		gauges: map[string]float64{
+			"concurrent_requests":    float64(atomic.LoadInt64(&m.currentConcurrent)),
+			"memory_usage":           float64(atomic.LoadUint64(&m.memoryUsage)),
+			"cpu_usage":              cpuUsage,
+			"requests_per_second_1m": rate1m,
+			"requests_per_second_5m": rate5m,
+			"goroutines":             float64(runtimeStats.Goroutines),
+			"heap_objects":           float64(runtimeStats.HeapObjects),
+			"heap_in_use":            float64(runtimeStats.HeapInUse),
+			"next_gc":                float64(runtimeStats.NextGC),
+			"gc_pause_total_ms":      float64(runtimeStats.GCPauseTotal) / float64(time.Millisecond),
+		},
+	}
+	
+	for _, category := range ErrorCategories {
+		current.counters["requests_failed_"+category.String()] = m.GetFailureCount(category)
+	}
+	
+	// Registered gauges are included when their values are numbers
+	for name, gauge := range m.sampleGauges() {
+		if value, ok := toFloat(gauge); ok {
+			current.gauges[name] = value
+		}
+	}
+	
+	return current
+}
+
+// Values returns the numeric metrics by name: the counters, the gauges and the
+// response time percentiles in milliseconds (response_time_p50_ms, ...)
+func (m *MetricsCollector) Values() map[string]float64 {
+	current := m.snapshot()
+	values := make(map[string]float64, len(current.counters)+len(current.gauges)+3)
+	for name, value := range current.counters {
+		values[name] = float64(value)
+	}
+	for name, value := range current.gauges {
+		values[name] = value
+	}
+	for _, p := range []float64{50, 90, 99} {
+		values[fmt.Sprintf("response_time_p%.0f_ms", p)] = float64(m.GetResponseTimePercentile(p)) / float64(time.Millisecond)
+	}
+	
+	return values
+}
+
+// toFloat converts a numeric gauge value to a float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
 	}
+	return 0, false
 }
 
 // GetStatsReport returns a formatted string with the server statistics
@@ -255,32 +546,66 @@ func (m *MetricsCollector) GetStatsReport() string {
 ### requests_total - %d
 ### requests_succeeded - %d
 ### requests_failed - %d
-### requests_per_second - %s
+### requests_failed_validation - %d
+### requests_failed_rate_limited - %d
+### requests_failed_timeout - %d
+### requests_failed_internal - %d
+### requests_per_second_1m - %s
+### requests_per_second_5m - %s
 ### success_rate - %s
 ### concurrent_requests - %d
 ### max_concurrent - %d
 ### server_load - %s
 ### memory_usage - %s
 ### cpu_usage - %s
+### goroutines - %d
+### heap_objects - %d
+### heap_in_use - %s
+### next_gc - %s
+### gc_runs - %d
+### gc_pause_total - %s
+### gc_last_pause - %s
 ### p50_response_time - %s
 ### p90_response_time - %s
 ### p99_response_time - %s
-### avg_response_time - %s`,
+### avg_response_time - %s
+### responses_2xx - %d
+### responses_3xx - %d
+### responses_4xx - %d
+### responses_5xx - %d
+### responses_429 - %d`,
 		metrics["uptime"],
 		metrics["requests_total"],
 		metrics["requests_succeeded"],
 		metrics["requests_failed"],
-		metrics["requests_per_second"],
+		metrics["requests_failed_validation"],
+		metrics["requests_failed_rate_limited"],
+		metrics["requests_failed_timeout"],
+		metrics["requests_failed_internal"],
+		metrics["requests_per_second_1m"],
+		metrics["requests_per_second_5m"],
 		metrics["success_rate"],
 		metrics["concurrent_requests"],
 		metrics["max_concurrent"],
 		metrics["server_load"],
 		metrics["memory_usage"],
 		metrics["cpu_usage"],
+		metrics["goroutines"],
+		metrics["heap_objects"],
+		metrics["heap_in_use"],
+		metrics["next_gc"],
+		metrics["gc_runs"],
+		metrics["gc_pause_total"],
+		metrics["gc_last_pause"],
 		metrics["p50_response_time"],
 		metrics["p90_response_time"],
 		metrics["p99_response_time"],
-		metrics["avg_response_time"])
+		metrics["avg_response_time"],
+		metrics["responses_2xx"],
+		metrics["responses_3xx"],
+		metrics["responses_4xx"],
+		metrics["responses_5xx"],
+		metrics["responses_429"])
 }
 
 // Shutdown stops the metrics collector
@@ -303,6 +628,29 @@ func (m *MetricsCollector) GetRequestFailed() uint64 {
 	return atomic.LoadUint64(&m.requestsFailed)
 }
 
+// GetFailureCount returns the number of requests that failed with an error of
+// the given category
+func (m *MetricsCollector) GetFailureCount(category ErrorCategory) uint64 {
+	if category < 0 || category >= categoryCount {
+		return 0
+	}
+	return atomic.LoadUint64(&m.failures[category])
+}
+
+// GetStatusClassCount returns the number of responses with a status code of
+// the given class, e.g. 5 for 5xx
+func (m *MetricsCollector) GetStatusClassCount(class int) uint64 {
+	if class < 1 || class >= len(m.statusClasses) {
+		return 0
+	}
+	return atomic.LoadUint64(&m.statusClasses[class])
+}
+
+// GetTooManyRequests returns the number of responses with status 429
+func (m *MetricsCollector) GetTooManyRequests() uint64 {
+	return atomic.LoadUint64(&m.tooManyRequests)
+}
+
 // GetCurrentConcurrent returns the current number of concurrent requests
 func (m *MetricsCollector) GetCurrentConcurrent() int64 {
 	return atomic.LoadInt64(&m.currentConcurrent)
@@ -318,6 +666,14 @@ func (m *MetricsCollector) GetMemoryUsage() uint64 {
 	return atomic.LoadUint64(&m.memoryUsage)
 }
 
+// GetRuntimeStats returns the last sample of the Go runtime statistics
+func (m *MetricsCollector) GetRuntimeStats() RuntimeStats {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	
+	return m.runtimeStats
+}
+
 // GetCPUUsage returns the current CPU usage (0-1)
 func (m *MetricsCollector) GetCPUUsage() float64 {
 	m.mutex.RLock()
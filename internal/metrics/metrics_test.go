@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"errors"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -37,17 +39,18 @@ func TestConcurrentTimeSlice(t *testing.T) {
 		t.Errorf("Expected average to be 200ms, got %v", timeSlice.Average())
 	}
 	
-	if timeSlice.GetPercentile(50) != 200*time.Millisecond {
-		t.Errorf("Expected P50 to be 200ms, got %v", timeSlice.GetPercentile(50))
+	// Percentiles come from a histogram, so they are within 1% of the exact value
+	if !withinPercent(timeSlice.GetPercentile(50), 200*time.Millisecond) {
+		t.Errorf("Expected P50 to be about 200ms, got %v", timeSlice.GetPercentile(50))
 	}
 	
 	// Test other percentiles
-	if timeSlice.GetPercentile(0) != 100*time.Millisecond {
-		t.Errorf("Expected P0 to be 100ms, got %v", timeSlice.GetPercentile(0))
+	if !withinPercent(timeSlice.GetPercentile(0), 100*time.Millisecond) {
+		t.Errorf("Expected P0 to be about 100ms, got %v", timeSlice.GetPercentile(0))
 	}
 	
-	if timeSlice.GetPercentile(100) != 300*time.Millisecond {
-		t.Errorf("Expected P100 to be 300ms, got %v", timeSlice.GetPercentile(100))
+	if !withinPercent(timeSlice.GetPercentile(100), 300*time.Millisecond) {
+		t.Errorf("Expected P100 to be about 300ms, got %v", timeSlice.GetPercentile(100))
 	}
 	
 	// Add many more times to test the limit
@@ -56,23 +59,60 @@ func TestConcurrentTimeSlice(t *testing.T) {
 	}
 	
 	// The slice should be limited to 10,000 elements
-	if timeSlice.Len() != 10000 {
-		t.Errorf("Expected length to be 10000, got %d", timeSlice.Len())
+	if timeSlice.Len() != 10000 || timeSlice.Seen() != 10004 {
+		t.Errorf("Expected 10000 of 10004 times to be kept, got %d of %d", timeSlice.Len(), timeSlice.Seen())
 	}
 	
-	// The slice should contain the most recent 10,000 elements (4 through 10003)
-	expectedMin := 4 * time.Millisecond
-	expectedMax := 10003 * time.Millisecond
+	// The average matches the kept times
+	var sum time.Duration
+	for _, d := range timeSlice.times {
+		sum += d
+	}
+	if timeSlice.Average() != sum/10000 {
+		t.Errorf("Expected the average of the kept times %v, got %v", sum/10000, timeSlice.Average())
+	}
+}
+
+func TestConcurrentTimeSliceSampling(t *testing.T) {
+	timeSlice := NewConcurrentTimeSliceWithCapacity(1000)
+	
+	// A long period of fast requests followed by a burst of slow ones
+	for i := 0; i < 99000; i++ {
+		timeSlice.Add(time.Duration(i%100+1) * time.Millisecond)
+	}
+	for i := 0; i < 1000; i++ {
+		timeSlice.Add(time.Second)
+	}
+	if timeSlice.Len() != 1000 {
+		t.Errorf("Expected 1000 times to be kept, got %d", timeSlice.Len())
+	}
 	
-	if timeSlice.GetPercentile(0) < expectedMin {
-		t.Errorf("Expected P0 to be at least %v, got %v", expectedMin, timeSlice.GetPercentile(0))
+	// The sample covers the whole period, so the burst shows in the tail only
+	if p50 := timeSlice.GetPercentile(50); p50 < 40*time.Millisecond || p50 > 60*time.Millisecond {
+		t.Errorf("Expected P50 to be about 50ms, got %v", p50)
+	}
+	if p90 := timeSlice.GetPercentile(90); p90 > 100*time.Millisecond {
+		t.Errorf("Expected P90 to be at most 100ms, got %v", p90)
+	}
+	if p100 := timeSlice.GetPercentile(100); !withinPercent(p100, time.Second) {
+		t.Errorf("Expected P100 to be about 1s, got %v", p100)
 	}
 	
-	if timeSlice.GetPercentile(100) > expectedMax {
-		t.Errorf("Expected P100 to be at most %v, got %v", expectedMax, timeSlice.GetPercentile(100))
+	// The histogram counts the kept times only
+	if timeSlice.histogram.Count() != 1000 {
+		t.Errorf("Expected the histogram to count 1000 times, got %d", timeSlice.histogram.Count())
 	}
 }
 
+// withinPercent reports whether got is within 1% of want
+func withinPercent(got, want time.Duration) bool {
+	diff := got - want
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff*100 <= want
+}
+
 func TestMetricsCollector(t *testing.T) {
 	// Create a new metrics collector
 	collector := NewMetricsCollector(100)
@@ -209,3 +249,151 @@ func TestMetricsCollector(t *testing.T) {
 		t.Error("Expected P50 response time to be positive")
 	}
 }
+
+func TestRegisterGauge(t *testing.T) {
+	// Create a new metrics collector
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	
+	// Register a gauge backed by a local variable
+	value := int64(3)
+	collector.RegisterGauge("queue_depth", func() interface{} {
+		return value
+	})
+	
+	// The gauge should be sampled when the metrics are read
+	if got := collector.GetCurrentMetrics()["queue_depth"]; got != int64(3) {
+		t.Errorf("Expected queue_depth to be 3, got %v", got)
+	}
+	
+	value = 7
+	if got := collector.GetCurrentMetrics()["queue_depth"]; got != int64(7) {
+		t.Errorf("Expected queue_depth to be 7, got %v", got)
+	}
+}
+
+func TestRegisterGauges(t *testing.T) {
+	// Create a new metrics collector
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	
+	// Register a group of gauges that counts how often it is sampled
+	samples := 0
+	collector.RegisterGauges(func() map[string]interface{} {
+		samples++
+		return map[string]interface{}{"cache_hits": 4, "cache_misses": 1, "cache_hit_ratio": "80.00%"}
+	})
+	
+	// All gauges of the group come from a single sample per read
+	metrics := collector.GetCurrentMetrics()
+	if metrics["cache_hits"] != 4 || metrics["cache_misses"] != 1 || metrics["cache_hit_ratio"] != "80.00%" {
+		t.Errorf("Expected the gauges of the group, got %v", metrics)
+	}
+	if samples != 1 {
+		t.Errorf("Expected the group to be sampled once, got %d", samples)
+	}
+	
+	// Numeric gauges of the group are also among the values
+	values := collector.Values()
+	if values["cache_hits"] != 4 || values["cache_misses"] != 1 {
+		t.Errorf("Expected the numeric gauges of the group, got %v", values)
+	}
+	if samples != 2 {
+		t.Errorf("Expected the group to be sampled once more, got %d", samples)
+	}
+}
+
+func TestRecordStatus(t *testing.T) {
+	// Create a new metrics collector
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	
+	for _, code := range []int{200, 201, 204, 301, 400, 404, 429, 429, 500, 503, 0, 999} {
+		collector.RecordStatus(code)
+	}
+	
+	// Codes are counted by class, and 429 on its own too; invalid codes are ignored
+	expected := map[string]uint64{
+		"responses_total": 10,
+		"responses_2xx":   3,
+		"responses_3xx":   1,
+		"responses_4xx":   4,
+		"responses_5xx":   2,
+		"responses_429":   2,
+	}
+	metrics := collector.GetCurrentMetrics()
+	for key, value := range expected {
+		if metrics[key] != value {
+			t.Errorf("Expected %s to be %d, got %v", key, value, metrics[key])
+		}
+	}
+	
+	if collector.GetStatusClassCount(4) != 4 || collector.GetTooManyRequests() != 2 {
+		t.Errorf("Expected 4 4xx and 2 429 responses, got %d and %d", collector.GetStatusClassCount(4), collector.GetTooManyRequests())
+	}
+	if collector.GetStatusClassCount(9) != 0 {
+		t.Error("Expected no responses for an invalid class")
+	}
+	
+	// The counts are in the stats report too
+	report := collector.GetStatsReport()
+	if !strings.Contains(report, "### responses_5xx - 2") || !strings.Contains(report, "### responses_429 - 2") {
+		t.Errorf("Expected the status counts in the report, got %s", report)
+	}
+}
+
+func TestRuntimeStats(t *testing.T) {
+	// Create a new metrics collector
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	
+	// Run a collection, so there is a pause to report
+	runtime.GC()
+	collector.UpdateMemoryUsage()
+	
+	stats := collector.GetRuntimeStats()
+	if stats.Goroutines <= 0 || stats.HeapObjects == 0 || stats.HeapInUse == 0 || stats.NextGC == 0 {
+		t.Errorf("Expected the goroutines and the heap to be sampled, got %+v", stats)
+	}
+	if stats.NumGC == 0 || stats.GCPauseTotal <= 0 || stats.LastGCPause <= 0 || stats.LastGCPause > stats.GCPauseTotal {
+		t.Errorf("Expected the collection to be counted, got %+v", stats)
+	}
+	
+	// The statistics are part of the metrics and the report
+	metrics := collector.GetCurrentMetrics()
+	for _, key := range []string{"goroutines", "heap_objects", "heap_in_use", "next_gc", "gc_runs", "gc_pause_total", "gc_last_pause"} {
+		if _, ok := metrics[key]; !ok {
+			t.Errorf("Expected %s in the metrics", key)
+		}
+	}
+	if metrics["gc_runs"] != stats.NumGC {
+		t.Errorf("Expected gc_runs to be %d, got %v", stats.NumGC, metrics["gc_runs"])
+	}
+	if report := collector.GetStatsReport(); !strings.Contains(report, "### gc_pause_total - ") || strings.Contains(report, "%!") {
+		t.Errorf("Expected the runtime statistics in the report, got %s", report)
+	}
+}
+
+func TestValues(t *testing.T) {
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	
+	collector.RegisterGauge("queue_depth", func() interface{} { return 7 })
+	collector.RegisterGauge("cache_hit_ratio", func() interface{} { return "75.00%" })
+	collector.RecordRequest()(nil)
+	collector.RecordRequest()(errors.New("failed"))
+	
+	// Counters, numeric gauges and percentiles, by name
+	values := collector.Values()
+	if values["requests_total"] != 2 || values["requests_failed"] != 1 || values["queue_depth"] != 7 {
+		t.Errorf("Expected the counters and gauges, got %v", values)
+	}
+	if _, ok := values["cache_hit_ratio"]; ok {
+		t.Error("Expected gauges that aren't numbers to be left out")
+	}
+	for _, name := range []string{"response_time_p50_ms", "response_time_p90_ms", "response_time_p99_ms", "memory_usage"} {
+		if _, ok := values[name]; !ok {
+			t.Errorf("Expected %s in the values", name)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"math"
+	"time"
+)
+
+// ewmaRate is an exponentially weighted moving average of a rate, like the
+// load averages of Unix: every update moves it towards the latest rate by a
+// share that grows with the time since the previous update, so rates older
+// than the window fade out
+type ewmaRate struct {
+	window time.Duration
+	rate   float64 // Per second
+}
+
+// update folds in the rate measured over the elapsed time
+func (r *ewmaRate) update(rate float64, elapsed time.Duration) {
+	if elapsed <= 0 {
+		return
+	}
+	alpha := 1 - math.Exp(-float64(elapsed)/float64(r.window))
+	r.rate += alpha * (rate - r.rate)
+}
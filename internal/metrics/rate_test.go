@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEWMARate(t *testing.T) {
+	r := ewmaRate{window: time.Minute}
+
+	// A steady rate is approached, and after a few windows reached
+	for i := 0; i < 300; i++ {
+		r.update(100, time.Second)
+	}
+	if math.Abs(r.rate-100) > 1 {
+		t.Errorf("Expected a rate of about 100, got %.2f", r.rate)
+	}
+
+	// After one window without requests, about 1/e of the rate is left
+	for i := 0; i < 60; i++ {
+		r.update(0, time.Second)
+	}
+	if math.Abs(r.rate-100/math.E) > 1 {
+		t.Errorf("Expected a rate of about %.2f, got %.2f", 100/math.E, r.rate)
+	}
+
+	// The time between updates counts, not their number
+	once := ewmaRate{window: time.Minute}
+	once.update(100, time.Minute)
+	if math.Abs(once.rate-100*(1-1/math.E)) > 0.01 {
+		t.Errorf("Expected one update over a window to count as many short ones, got %.2f", once.rate)
+	}
+}
+
+func TestRequestRates(t *testing.T) {
+	// Without the background updates, so the test controls the time
+	start := time.Now()
+	collector := &MetricsCollector{
+		startTime:     start,
+		responseTimes: NewConcurrentTimeSlice(),
+		maxConcurrent: 100,
+		rate1m:        ewmaRate{window: time.Minute},
+		rate5m:        ewmaRate{window: 5 * time.Minute},
+		gauges:        make(map[string]func() interface{}),
+	}
+
+	// 10 requests a second for 5 minutes
+	now := start
+	for i := 0; i < 300; i++ {
+		for j := 0; j < 10; j++ {
+			collector.RecordRequest()(nil)
+		}
+		now = now.Add(time.Second)
+		collector.updateRates(now)
+	}
+	metrics := collector.GetCurrentMetrics()
+	if metrics["requests_per_second_1m"] != "9.93" {
+		t.Errorf("Expected a 1 minute rate close to 10, got %v", metrics["requests_per_second_1m"])
+	}
+
+	// A minute later without requests, the 1 minute rate has dropped much faster
+	for i := 0; i < 60; i++ {
+		now = now.Add(time.Second)
+		collector.updateRates(now)
+	}
+	rate1m, rate5m := collector.rate1m.rate, collector.rate5m.rate
+	if rate1m > 4 || rate5m < 4 {
+		t.Errorf("Expected the 1 minute rate to drop below the 5 minute rate, got %.2f and %.2f", rate1m, rate5m)
+	}
+
+	// The rates are part of the report and the pushed gauges
+	report := collector.GetStatsReport()
+	if !strings.Contains(report, "### requests_per_second_1m - ") || !strings.Contains(report, "### requests_per_second_5m - ") {
+		t.Errorf("Expected the rates in the report, got %s", report)
+	}
+	if _, ok := collector.snapshot().gauges["requests_per_second_5m"]; !ok {
+		t.Error("Expected the 5 minute rate in the snapshot")
+	}
+}
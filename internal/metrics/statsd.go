@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxStatsDPacket is the largest datagram sent, small enough not to be
+// fragmented on an Ethernet network
+const maxStatsDPacket = 1432
+
+// maxStatsDTimings is the number of response times sent per interval; when
+// there are more, a random sample is sent with its sample rate
+const maxStatsDTimings = 1000
+
+// StatsDEmitter pushes the metrics of a collector to a StatsD server over UDP
+// Counters are sent as the increase since the last push, gauges as their
+// current value, and the response times as timings. DogStatsD tags are
+// appended to every metric if given
+type StatsDEmitter struct {
+	collector *MetricsCollector
+	conn      net.Conn
+	prefix    string
+	tags      string // Tag suffix of every line, e.g. "|#env:prod"
+	interval  time.Duration
+
+	flushMutex sync.Mutex
+	last       map[string]uint64 // Counters at the last push
+
+	timingsMutex sync.Mutex
+	timings      []time.Duration // Sample of the response times since the last push
+	timingsSeen  int             // Response times since the last push
+	random       *rand.Rand
+
+	startOnce sync.Once
+	stopCh    chan struct{}
+	done      chan struct{} // Closed once the pushes have stopped
+}
+
+// NewStatsDEmitter creates an emitter that sends the metrics of collector to
+// the StatsD server at addr every interval, with names starting with prefix
+func NewStatsDEmitter(collector *MetricsCollector, addr, prefix string, interval time.Duration, tags []string) (*StatsDEmitter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	emitter := &StatsDEmitter{
+		collector: collector,
+		conn:      conn,
+		prefix:    strings.TrimSuffix(prefix, "."),
+		interval:  interval,
+		last:      make(map[string]uint64),
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		stopCh:    make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if len(tags) > 0 {
+		cleaned := make([]string, len(tags))
+		for i, tag := range tags {
+			cleaned[i] = strings.NewReplacer("|", "_", ",", "_", "#", "_").Replace(strings.TrimSpace(tag))
+		}
+		emitter.tags = "|#" + strings.Join(cleaned, ",")
+	}
+	collector.OnResponseTime(emitter.addTiming)
+	return emitter, nil
+}
+
+// Start pushes the metrics every interval until Stop is called
+func (e *StatsDEmitter) Start() {
+	e.startOnce.Do(func() {
+		go e.run()
+	})
+}
+
+// run pushes the metrics every interval until stopCh is closed
+func (e *StatsDEmitter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.Flush()
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops the pushes, sends the metrics a last time and closes the connection
+func (e *StatsDEmitter) Stop() error {
+	close(e.stopCh)
+	// Without pushes running there is nothing to wait for, and none can start
+	e.startOnce.Do(func() { close(e.done) })
+	<-e.done
+	e.Flush()
+	return e.conn.Close()
+}
+
+// addTiming keeps a response time for the next push
+// Once maxStatsDTimings are kept, each new one replaces a random kept one with
+// decreasing probability, so the kept ones are a uniform sample
+func (e *StatsDEmitter) addTiming(d time.Duration) {
+	e.timingsMutex.Lock()
+	defer e.timingsMutex.Unlock()
+
+	e.timingsSeen++
+	if len(e.timings) < maxStatsDTimings {
+		e.timings = append(e.timings, d)
+		return
+	}
+	if i := e.random.Intn(e.timingsSeen); i < maxStatsDTimings {
+		e.timings[i] = d
+	}
+}
+
+// Flush sends the current metrics
+// Write errors are ignored: StatsD is fire and forget, and the next push sends
+// the counters that were lost
+func (e *StatsDEmitter) Flush() {
+	e.flushMutex.Lock()
+	defer e.flushMutex.Unlock()
+
+	for _, packet := range e.packets() {
+		e.conn.Write(packet)
+	}
+}
+
+// packets formats the current metrics as lines of the StatsD protocol, joined
+// into datagrams of at most maxStatsDPacket bytes
+func (e *StatsDEmitter) packets() [][]byte {
+	current := e.collector.snapshot()
+	var lines []string
+
+	// Counters are sent as their increase, since StatsD sums what it receives
+	for _, name := range sortedKeys(current.counters) {
+		value, last := current.counters[name], e.last[name]
+		e.last[name] = value
+		if value <= last {
+			continue
+		}
+		lines = append(lines, e.line(name, strconv.FormatUint(value-last, 10), "c", ""))
+	}
+	for _, name := range sortedKeys(current.gauges) {
+		lines = append(lines, e.line(name, strconv.FormatFloat(current.gauges[name], 'f', -1, 64), "g", ""))
+	}
+
+	e.timingsMutex.Lock()
+	timings, seen := e.timings, e.timingsSeen
+	e.timings, e.timingsSeen = nil, 0
+	e.timingsMutex.Unlock()
+	rate := ""
+	if seen > len(timings) {
+		rate = "|@" + strconv.FormatFloat(float64(len(timings))/float64(seen), 'f', 4, 64)
+	}
+	for _, d := range timings {
+		lines = append(lines, e.line("response_time", strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64), "ms", rate))
+	}
+
+	var packets [][]byte
+	var packet bytes.Buffer
+	for _, line := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxStatsDPacket {
+			packets = append(packets, append([]byte(nil), packet.Bytes()...))
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		packets = append(packets, packet.Bytes())
+	}
+	return packets
+}
+
+// line formats a metric, e.g. "namegen.requests_total:3|c|#env:prod"
+func (e *StatsDEmitter) line(name, value, kind, rate string) string {
+	name = strings.NewReplacer(":", "_", "|", "_", "@", "_", "\n", "_").Replace(name)
+	if e.prefix != "" {
+		name = e.prefix + "." + name
+	}
+	return name + ":" + value + "|" + kind + rate + e.tags
+}
+
+// sortedKeys returns the keys of a map in order, so the lines are sent in the
+// same order every time
+func sortedKeys[V uint64 | float64](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
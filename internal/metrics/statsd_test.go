@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenStatsD starts a UDP listener standing in for a StatsD server
+func listenStatsD(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readLines reads the datagrams sent so far and returns their lines
+func readLines(t *testing.T, conn *net.UDPConn) []string {
+	var lines []string
+	buf := make([]byte, 65536)
+	for {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return lines
+		}
+		if n > maxStatsDPacket {
+			t.Errorf("Expected datagrams of at most %d bytes, got %d", maxStatsDPacket, n)
+		}
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+}
+
+func TestStatsDEmitter(t *testing.T) {
+	server := listenStatsD(t)
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+	collector.RegisterGauge("queue_depth", func() interface{} { return int64(4) })
+	collector.RegisterGauge("cache_hit_ratio", func() interface{} { return "50.00%" })
+
+	emitter, err := NewStatsDEmitter(collector, server.LocalAddr().String(), "namegen.", time.Hour, []string{"env:test", "region|eu"})
+	if err != nil {
+		t.Fatalf("Failed to create emitter: %v", err)
+	}
+	defer emitter.Stop()
+
+	for i := 0; i < 3; i++ {
+		done := collector.RecordRequest()
+		done(nil)
+		collector.RecordStatus(200)
+	}
+	emitter.Flush()
+	lines := readLines(t, server)
+	joined := strings.Join(lines, "\n")
+
+	for _, expected := range []string{
+		"namegen.requests_total:3|c|#env:test,region_eu",
+		"namegen.responses_2xx:3|c|#env:test,region_eu",
+		"namegen.queue_depth:4|g|#env:test,region_eu",
+		"namegen.concurrent_requests:0|g|#env:test,region_eu",
+	} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("Expected line %s, got:\n%s", expected, joined)
+		}
+	}
+	timings := 0
+	for _, line := range lines {
+		if strings.HasPrefix(line, "namegen.response_time:") && strings.HasSuffix(line, "|ms|#env:test,region_eu") {
+			timings++
+		}
+	}
+	if timings != 3 {
+		t.Errorf("Expected 3 timings, got %d", timings)
+	}
+
+	// Unchanged counters aren't sent again, and gauges that aren't numbers never are
+	if strings.Contains(joined, "requests_failed") || strings.Contains(joined, "cache_hit_ratio") {
+		t.Errorf("Unexpected lines:\n%s", joined)
+	}
+
+	// Counters are sent as their increase since the last push
+	done := collector.RecordRequest()
+	done(nil)
+	emitter.Flush()
+	joined = strings.Join(readLines(t, server), "\n")
+	if !strings.Contains(joined, "namegen.requests_total:1|c") || strings.Contains(joined, "responses_2xx") {
+		t.Errorf("Expected only the new request to be counted, got:\n%s", joined)
+	}
+}
+
+func TestStatsDTimingSample(t *testing.T) {
+	server := listenStatsD(t)
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+
+	emitter, err := NewStatsDEmitter(collector, server.LocalAddr().String(), "", time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Failed to create emitter: %v", err)
+	}
+	defer emitter.Stop()
+
+	// More timings than are sent are sampled, and sent with their sample rate
+	for i := 0; i < 2*maxStatsDTimings; i++ {
+		emitter.addTiming(time.Duration(i) * time.Microsecond)
+	}
+	packets := emitter.packets()
+	if len(packets) < 2 {
+		t.Errorf("Expected the lines to be split into several datagrams, got %d", len(packets))
+	}
+	timings := 0
+	for _, packet := range packets {
+		if len(packet) > maxStatsDPacket {
+			t.Errorf("Expected datagrams of at most %d bytes, got %d", maxStatsDPacket, len(packet))
+		}
+		for _, line := range strings.Split(string(packet), "\n") {
+			if strings.HasPrefix(line, "response_time:") {
+				timings++
+				if !strings.HasSuffix(line, "|ms|@0.5000") {
+					t.Errorf("Expected a sample rate of 0.5, got %s", line)
+				}
+			}
+		}
+	}
+	if timings != maxStatsDTimings {
+		t.Errorf("Expected %d timings, got %d", maxStatsDTimings, timings)
+	}
+}
+
+func TestStatsDEmitterStart(t *testing.T) {
+	server := listenStatsD(t)
+	collector := NewMetricsCollector(100)
+	defer collector.Shutdown()
+
+	emitter, err := NewStatsDEmitter(collector, server.LocalAddr().String(), "app", 10*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("Failed to create emitter: %v", err)
+	}
+	emitter.Start()
+
+	// The metrics are pushed on every tick
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 65536)
+	n, err := server.Read(buf)
+	if err != nil || !strings.Contains(string(buf[:n]), "app.goroutines:") {
+		t.Errorf("Expected a push, got %q (%v)", buf[:n], err)
+	}
+	if err := emitter.Stop(); err != nil {
+		t.Errorf("Stop failed: %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+// Package namespb implements the protobuf messages of the /generate endpoint
+// described in names.proto
+//
+// The messages are few and flat, so they are encoded with a small hand-written
+// codec instead of generated code, keeping the module free of dependencies
+package namespb
+
+// ContentType is the media type of protobuf-encoded requests and responses
+const ContentType = "application/x-protobuf"
+
+// GenerateRequest asks for names starting with a letter or prefix
+type GenerateRequest struct {
+	SessionID    string
+	Letter       string
+	Prefix       string
+	NumOfEntries int32
+	Locale       string
+	Unique       bool
+	Weighted     *bool // Nil when not set
+	Seed         int64
+	Offset       int32
+	Cursor       string
+	Letters      []LetterSpec
+}
+
+// LetterSpec is a letter of a multi-letter request, with an optional count
+type LetterSpec struct {
+	Letter       string
+	NumOfEntries int32
+}
+
+// GenerateResponse holds the generated names
+type GenerateResponse struct {
+	SessionID    string
+	Names        []string
+	NumOfEntries int32
+	Locale       string
+	Seed         int64
+	NextCursor   string
+	Groups       []LetterGroup
+}
+
+// LetterGroup holds the names generated for one letter
+type LetterGroup struct {
+	Letter       string
+	Names        []string
+	NumOfEntries int32
+}
+
+// Marshal encodes the request
+func (m *GenerateRequest) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.SessionID)
+	b = appendString(b, 2, m.Letter)
+	b = appendString(b, 3, m.Prefix)
+	b = appendInt(b, 4, int64(m.NumOfEntries))
+	b = appendString(b, 5, m.Locale)
+	b = appendBool(b, 6, m.Unique)
+	if m.Weighted != nil {
+		// Optional fields are written even when false, so their presence is kept
+		b = appendTag(b, 7, wireVarint)
+		if *m.Weighted {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+	}
+	b = appendInt(b, 8, m.Seed)
+	b = appendInt(b, 9, int64(m.Offset))
+	b = appendString(b, 10, m.Cursor)
+	for _, letter := range m.Letters {
+		b = appendBytes(b, 11, letter.Marshal())
+	}
+	return b
+}
+
+// Unmarshal decodes a request, replacing the contents of m
+// Unknown fields are skipped
+func (m *GenerateRequest) Unmarshal(b []byte) error {
+	*m = GenerateRequest{}
+	d := decoder{b: b}
+	for {
+		f, ok, err := d.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		switch f.num {
+		case 1, 2, 3, 5, 10, 11:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+		case 4, 6, 7, 8, 9:
+			if err := f.expect(wireVarint); err != nil {
+				return err
+			}
+		}
+
+		switch f.num {
+		case 1:
+			m.SessionID = string(f.bytes)
+		case 2:
+			m.Letter = string(f.bytes)
+		case 3:
+			m.Prefix = string(f.bytes)
+		case 4:
+			m.NumOfEntries = int32(f.varint)
+		case 5:
+			m.Locale = string(f.bytes)
+		case 6:
+			m.Unique = f.varint != 0
+		case 7:
+			weighted := f.varint != 0
+			m.Weighted = &weighted
+		case 8:
+			m.Seed = int64(f.varint)
+		case 9:
+			m.Offset = int32(f.varint)
+		case 10:
+			m.Cursor = string(f.bytes)
+		case 11:
+			var letter LetterSpec
+			if err := letter.Unmarshal(f.bytes); err != nil {
+				return err
+			}
+			m.Letters = append(m.Letters, letter)
+		}
+	}
+}
+
+// Marshal encodes the letter
+func (m *LetterSpec) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.Letter)
+	b = appendInt(b, 2, int64(m.NumOfEntries))
+	return b
+}
+
+// Unmarshal decodes a letter, replacing the contents of m
+func (m *LetterSpec) Unmarshal(b []byte) error {
+	*m = LetterSpec{}
+	d := decoder{b: b}
+	for {
+		f, ok, err := d.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		switch f.num {
+		case 1:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+			m.Letter = string(f.bytes)
+		case 2:
+			if err := f.expect(wireVarint); err != nil {
+				return err
+			}
+			m.NumOfEntries = int32(f.varint)
+		}
+	}
+}
+
+// Marshal encodes the response
+func (m *GenerateResponse) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.SessionID)
+	for _, name := range m.Names {
+		b = appendBytes(b, 2, []byte(name))
+	}
+	b = appendInt(b, 3, int64(m.NumOfEntries))
+	b = appendString(b, 4, m.Locale)
+	b = appendInt(b, 5, m.Seed)
+	b = appendString(b, 6, m.NextCursor)
+	for _, group := range m.Groups {
+		b = appendBytes(b, 7, group.Marshal())
+	}
+	return b
+}
+
+// Unmarshal decodes a response, replacing the contents of m
+// Unknown fields are skipped
+func (m *GenerateResponse) Unmarshal(b []byte) error {
+	*m = GenerateResponse{}
+	d := decoder{b: b}
+	for {
+		f, ok, err := d.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		switch f.num {
+		case 1, 2, 4, 6, 7:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+		case 3, 5:
+			if err := f.expect(wireVarint); err != nil {
+				return err
+			}
+		}
+
+		switch f.num {
+		case 1:
+			m.SessionID = string(f.bytes)
+		case 2:
+			m.Names = append(m.Names, string(f.bytes))
+		case 3:
+			m.NumOfEntries = int32(f.varint)
+		case 4:
+			m.Locale = string(f.bytes)
+		case 5:
+			m.Seed = int64(f.varint)
+		case 6:
+			m.NextCursor = string(f.bytes)
+		case 7:
+			var group LetterGroup
+			if err := group.Unmarshal(f.bytes); err != nil {
+				return err
+			}
+			m.Groups = append(m.Groups, group)
+		}
+	}
+}
+
+// Marshal encodes the group
+func (m *LetterGroup) Marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, m.Letter)
+	for _, name := range m.Names {
+		b = appendBytes(b, 2, []byte(name))
+	}
+	b = appendInt(b, 3, int64(m.NumOfEntries))
+	return b
+}
+
+// Unmarshal decodes a group, replacing the contents of m
+func (m *LetterGroup) Unmarshal(b []byte) error {
+	*m = LetterGroup{}
+	d := decoder{b: b}
+	for {
+		f, ok, err := d.next()
+		if err != nil || !ok {
+			return err
+		}
+
+		switch f.num {
+		case 1:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+			m.Letter = string(f.bytes)
+		case 2:
+			if err := f.expect(wireBytes); err != nil {
+				return err
+			}
+			m.Names = append(m.Names, string(f.bytes))
+		case 3:
+			if err := f.expect(wireVarint); err != nil {
+				return err
+			}
+			m.NumOfEntries = int32(f.varint)
+		}
+	}
+}
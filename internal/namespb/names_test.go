@@ -0,0 +1,103 @@
+package namespb
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateRequestWireFormat(t *testing.T) {
+	// Fields are encoded as protoc would, and default values are omitted
+	request := GenerateRequest{SessionID: "s", Letter: "A", NumOfEntries: 5}
+	want := []byte{0x0a, 0x01, 's', 0x12, 0x01, 'A', 0x20, 0x05}
+	if got := request.Marshal(); !bytes.Equal(got, want) {
+		t.Errorf("Expected % x, got % x", want, got)
+	}
+
+	// Negative numbers take ten bytes, and set optional fields are kept when false
+	weighted := false
+	request = GenerateRequest{Offset: -1, Weighted: &weighted}
+	want = []byte{0x38, 0x00, 0x48, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	if got := request.Marshal(); !bytes.Equal(got, want) {
+		t.Errorf("Expected % x, got % x", want, got)
+	}
+}
+
+func TestGenerateRequestRoundTrip(t *testing.T) {
+	weighted := true
+	request := GenerateRequest{
+		SessionID:    "session",
+		Letter:       "A",
+		Prefix:       "Ma",
+		NumOfEntries: 300,
+		Locale:       "tr-TR",
+		Unique:       true,
+		Weighted:     &weighted,
+		Seed:         -42,
+		Offset:       1000,
+		Cursor:       "NDI6MTAw",
+		Letters:      []LetterSpec{{Letter: "B"}, {Letter: "Çe", NumOfEntries: 3}},
+	}
+
+	var decoded GenerateRequest
+	if err := decoded.Unmarshal(request.Marshal()); err != nil {
+		t.Fatalf("Error decoding request: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, request) {
+		t.Errorf("Expected %+v, got %+v", request, decoded)
+	}
+}
+
+func TestGenerateResponseRoundTrip(t *testing.T) {
+	response := GenerateResponse{
+		SessionID:    "session",
+		Names:        []string{"Anna", "", "Ömer"},
+		NumOfEntries: 3,
+		Locale:       "de",
+		Seed:         1 << 40,
+		NextCursor:   "cursor",
+		Groups:       []LetterGroup{{Letter: "A", Names: []string{"Anna"}, NumOfEntries: 1}, {Letter: "B"}},
+	}
+
+	var decoded GenerateResponse
+	if err := decoded.Unmarshal(response.Marshal()); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, response) {
+		t.Errorf("Expected %+v, got %+v", response, decoded)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	// Unknown fields of any wire type are skipped
+	b := (&GenerateRequest{SessionID: "s"}).Marshal()
+	b = appendInt(b, 99, 7)
+	b = appendString(b, 100, "future")
+	b = append(appendTag(b, 101, wireFixed32), 1, 2, 3, 4)
+	b = append(appendTag(b, 102, wireFixed64), 1, 2, 3, 4, 5, 6, 7, 8)
+	var request GenerateRequest
+	if err := request.Unmarshal(b); err != nil || request.SessionID != "s" {
+		t.Errorf("Expected unknown fields to be skipped, got %+v (err %v)", request, err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated string", []byte{0x0a, 0x05, 'a'}},
+		{"truncated varint", []byte{0x20, 0x80}},
+		{"wrong wire type", []byte{0x08, 0x01}},
+		{"group wire type", []byte{0x0b}},
+		{"field number zero", []byte{0x00, 0x01}},
+		{"bad nested message", []byte{0x5a, 0x02, 0x0a, 0x05}},
+	}
+	for _, tt := range tests {
+		if err := request.Unmarshal(tt.data); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+	if err := request.Unmarshal([]byte{0x0a, 0x05, 'a'}); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected ErrTruncated, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package namespb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Wire types of the protobuf encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// ErrTruncated is returned when a message ends in the middle of a field
+var ErrTruncated = errors.New("namespb: truncated message")
+
+// appendVarint appends v in base 128, least significant group first
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// appendTag appends the key of a field
+func appendTag(b []byte, num int, wireType int) []byte {
+	return appendVarint(b, uint64(num)<<3|uint64(wireType))
+}
+
+// appendInt appends an integer field, omitting the default value
+// Negative numbers are sign-extended to ten bytes, as for int32 and int64
+func appendInt(b []byte, num int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	return appendVarint(appendTag(b, num, wireVarint), uint64(v))
+}
+
+// appendBool appends a bool field, omitting false
+func appendBool(b []byte, num int, v bool) []byte {
+	if !v {
+		return b
+	}
+	return append(appendTag(b, num, wireVarint), 1)
+}
+
+// appendBytes appends a length-delimited field, even if it is empty
+func appendBytes(b []byte, num int, v []byte) []byte {
+	b = appendVarint(appendTag(b, num, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+// appendString appends a string field, omitting the empty string
+func appendString(b []byte, num int, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = appendVarint(appendTag(b, num, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+// field is a decoded field of a message
+type field struct {
+	num      int
+	wireType int
+	varint   uint64 // Value of varint fields
+	bytes    []byte // Value of length-delimited fields
+}
+
+// decoder reads the fields of an encoded message in order
+type decoder struct {
+	b []byte
+}
+
+// varint reads a base 128 integer
+func (d *decoder) varint() (uint64, error) {
+	var v uint64
+	for i := 0; i < 10; i++ {
+		if i >= len(d.b) {
+			return 0, ErrTruncated
+		}
+		c := d.b[i]
+		v |= uint64(c&0x7f) << (7 * i)
+		if c < 0x80 {
+			d.b = d.b[i+1:]
+			return v, nil
+		}
+	}
+	return 0, errors.New("namespb: varint overflows 64 bits")
+}
+
+// skip drops n bytes
+func (d *decoder) skip(n uint64) ([]byte, error) {
+	if n > uint64(len(d.b)) {
+		return nil, ErrTruncated
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v, nil
+}
+
+// next reads the next field, reporting false at the end of the message
+// Fixed-width fields are read but not decoded, as no message uses them
+func (d *decoder) next() (field, bool, error) {
+	if len(d.b) == 0 {
+		return field{}, false, nil
+	}
+	key, err := d.varint()
+	if err != nil {
+		return field{}, false, err
+	}
+	f := field{num: int(key >> 3), wireType: int(key & 7)}
+	if f.num <= 0 {
+		return field{}, false, fmt.Errorf("namespb: invalid field number %d", f.num)
+	}
+
+	switch f.wireType {
+	case wireVarint:
+		f.varint, err = d.varint()
+	case wireBytes:
+		var n uint64
+		if n, err = d.varint(); err == nil {
+			f.bytes, err = d.skip(n)
+		}
+	case wireFixed64:
+		_, err = d.skip(8)
+	case wireFixed32:
+		_, err = d.skip(4)
+	default:
+		err = fmt.Errorf("namespb: unsupported wire type %d", f.wireType)
+	}
+	if err != nil {
+		return field{}, false, err
+	}
+	return f, true, nil
+}
+
+// expect checks that a known field has the wire type of its declaration
+func (f field) expect(wireType int) error {
+	if f.wireType != wireType {
+		return fmt.Errorf("namespb: field %d has wire type %d, want %d", f.num, f.wireType, wireType)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package ratelimit
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -72,7 +73,7 @@ func (l *TokenBucketLimiter) Allow(ctx context.Context) bool {
 
 			// No token available, wait a bit and try again
 			// Calculate time until next token
-			waitTime := time.Duration(1000/l.rate) * time.Millisecond
+			waitTime := time.Duration(1000/l.Rate()) * time.Millisecond
 
 			// Wait for the next token or context cancellation
 			select {
@@ -102,6 +103,32 @@ func (l *TokenBucketLimiter) TryAllow() bool {
 	return false
 }
 
+// Rate returns the number of tokens added per second
+func (l *TokenBucketLimiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// Capacity returns the maximum number of tokens
+func (l *TokenBucketLimiter) Capacity() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.capacity
+}
+
+// SetRate changes the rate and the capacity of the bucket
+// The tokens added so far at the old rate are kept, up to the new capacity
+func (l *TokenBucketLimiter) SetRate(rate float64, capacity int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill()
+	l.rate = rate
+	l.capacity = capacity
+	l.tokens = min(l.tokens, capacity)
+}
+
 // SlidingWindowLimiter implements a sliding window rate limiter
 type SlidingWindowLimiter struct {
 	maxRequests    int64         // maximum number of requests per window
@@ -119,6 +146,13 @@ func NewSlidingWindowLimiter(maxRequests int64, windowDuration time.Duration) *S
 	}
 }
 
+// SetMaxRequests changes the number of requests allowed per window
+func (l *SlidingWindowLimiter) SetMaxRequests(maxRequests int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.maxRequests = maxRequests
+}
+
 // pruneExpiredRequests removes expired requests from the window
 func (l *SlidingWindowLimiter) pruneExpiredRequests() {
 	now := time.Now()
@@ -405,3 +439,78 @@ func (l *AdaptiveRateLimiter) TryAllow() bool {
 func (l *AdaptiveRateLimiter) Shutdown() {
 	close(l.stopCh)
 }
+
+// AdmissionQueue wraps a rate limiter with a bounded queue of waiting requests.
+// Requests that cannot be admitted immediately wait up to maxWait for capacity,
+// and only requests arriving while the queue is full are rejected outright
+type AdmissionQueue struct {
+	limiter  RateLimiter
+	capacity int64         // maximum number of waiting requests
+	maxWait  time.Duration // how long a queued request may wait for capacity
+	depth    int64         // current number of waiting requests
+	rejected uint64        // requests rejected because the queue was full
+	timedOut uint64        // queued requests that gave up waiting
+}
+
+// NewAdmissionQueue creates a new admission queue in front of the given limiter
+func NewAdmissionQueue(limiter RateLimiter, capacity int64, maxWait time.Duration) *AdmissionQueue {
+	return &AdmissionQueue{
+		limiter:  limiter,
+		capacity: capacity,
+		maxWait:  maxWait,
+	}
+}
+
+// Allow admits the request immediately if the limiter has capacity, otherwise
+// queues it until capacity frees up, maxWait elapses or the context is canceled.
+// Returns false without waiting if the queue is already full
+func (q *AdmissionQueue) Allow(ctx context.Context) bool {
+	// Fast path: capacity is available right now
+	if q.limiter.TryAllow() {
+		return true
+	}
+
+	// Reserve a slot in the queue, rejecting if it is full
+	if atomic.AddInt64(&q.depth, 1) > q.capacity {
+		atomic.AddInt64(&q.depth, -1)
+		atomic.AddUint64(&q.rejected, 1)
+		return false
+	}
+	defer atomic.AddInt64(&q.depth, -1)
+
+	// Wait for capacity, bounded by the queue deadline
+	waitCtx, cancel := context.WithTimeout(ctx, q.maxWait)
+	defer cancel()
+
+	if !q.limiter.Allow(waitCtx) {
+		atomic.AddUint64(&q.timedOut, 1)
+		return false
+	}
+
+	return true
+}
+
+// TryAllow checks if a request is allowed without blocking or queueing
+func (q *AdmissionQueue) TryAllow() bool {
+	return q.limiter.TryAllow()
+}
+
+// Depth returns the number of requests currently waiting in the queue
+func (q *AdmissionQueue) Depth() int64 {
+	return atomic.LoadInt64(&q.depth)
+}
+
+// Capacity returns the maximum number of requests that may wait in the queue
+func (q *AdmissionQueue) Capacity() int64 {
+	return q.capacity
+}
+
+// Rejected returns the number of requests rejected because the queue was full
+func (q *AdmissionQueue) Rejected() uint64 {
+	return atomic.LoadUint64(&q.rejected)
+}
+
+// TimedOut returns the number of queued requests that gave up waiting
+func (q *AdmissionQueue) TimedOut() uint64 {
+	return atomic.LoadUint64(&q.timedOut)
+}
@@ -294,3 +294,113 @@ func TestRateLimiterConcurrent(t *testing.T) {
 		t.Errorf("Expected about 60 allowed requests, got %d", allowed)
 	}
 }
+
+func TestAdmissionQueue(t *testing.T) {
+	// Create a limiter with a single token that refills every 100ms
+	limiter := NewTokenBucketLimiter(10, 1)
+
+	// Create a queue that holds one waiting request for up to 500ms
+	queue := NewAdmissionQueue(limiter, 1, 500*time.Millisecond)
+
+	// The first request is admitted immediately
+	if !queue.Allow(context.Background()) {
+		t.Fatal("Expected first request to be admitted immediately")
+	}
+
+	// The second request waits in the queue until a token is refilled
+	admitted := make(chan bool, 1)
+	go func() {
+		admitted <- queue.Allow(context.Background())
+	}()
+
+	// Wait for the second request to enter the queue
+	deadline := time.Now().Add(50 * time.Millisecond)
+	for queue.Depth() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if queue.Depth() != 1 {
+		t.Fatalf("Expected queue depth to be 1, got %d", queue.Depth())
+	}
+
+	// A third request overflows the queue and is rejected without waiting
+	start := time.Now()
+	if queue.Allow(context.Background()) {
+		t.Error("Expected request to be rejected when the queue is full")
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("Expected overflow rejection to be immediate, took %v", elapsed)
+	}
+	if queue.Rejected() != 1 {
+		t.Errorf("Expected 1 rejected request, got %d", queue.Rejected())
+	}
+
+	// The queued request is eventually admitted
+	if !<-admitted {
+		t.Error("Expected queued request to be admitted once capacity freed up")
+	}
+	if queue.Depth() != 0 {
+		t.Errorf("Expected queue to be empty, got depth %d", queue.Depth())
+	}
+}
+
+func TestAdmissionQueueTimeout(t *testing.T) {
+	// Create a limiter that refills far slower than the queue deadline
+	limiter := NewTokenBucketLimiter(1, 1)
+	queue := NewAdmissionQueue(limiter, 10, 50*time.Millisecond)
+
+	// Drain the only token
+	if !queue.Allow(context.Background()) {
+		t.Fatal("Expected first request to be admitted immediately")
+	}
+
+	// The next request waits for the deadline and then gives up
+	if queue.Allow(context.Background()) {
+		t.Error("Expected queued request to time out")
+	}
+	if queue.TimedOut() != 1 {
+		t.Errorf("Expected 1 timed out request, got %d", queue.TimedOut())
+	}
+}
+
+func TestTokenBucketLimiterSetRate(t *testing.T) {
+	limiter := NewTokenBucketLimiter(10, 5)
+	
+	// Lowering the capacity drops the tokens above it
+	limiter.SetRate(1, 2)
+	if limiter.Rate() != 1 || limiter.Capacity() != 2 {
+		t.Errorf("Expected a rate of 1 and a capacity of 2, got %v and %d", limiter.Rate(), limiter.Capacity())
+	}
+	for i := 0; i < 2; i++ {
+		if !limiter.TryAllow() {
+			t.Errorf("Expected token %d to be allowed, but it was denied", i)
+		}
+	}
+	if limiter.TryAllow() {
+		t.Errorf("Expected the 3rd token to be denied, but it was allowed")
+	}
+	
+	// Raising the rate refills the bucket faster
+	limiter.SetRate(100, 2)
+	time.Sleep(30 * time.Millisecond)
+	if !limiter.TryAllow() {
+		t.Errorf("Expected a token to be allowed at the new rate, but it was denied")
+	}
+}
+
+func TestSlidingWindowLimiterSetMaxRequests(t *testing.T) {
+	limiter := NewSlidingWindowLimiter(1, time.Second)
+	if !limiter.TryAllow() || limiter.TryAllow() {
+		t.Fatalf("Expected only 1 request to be allowed")
+	}
+	
+	// The requests already in the window count towards the new limit
+	limiter.SetMaxRequests(3)
+	for i := 0; i < 2; i++ {
+		if !limiter.TryAllow() {
+			t.Errorf("Expected request %d to be allowed, but it was denied", i)
+		}
+	}
+	if limiter.TryAllow() {
+		t.Errorf("Expected the 4th request to be denied, but it was allowed")
+	}
+}
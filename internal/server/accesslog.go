@@ -0,0 +1,222 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Access log formats
+const (
+	AccessLogJSON     = "json"     // A JSON object per request, with the fields of the request logger
+	AccessLogCommon   = "common"   // NCSA Common Log Format
+	AccessLogCombined = "combined" // Common Log Format with referer and user agent
+)
+
+// clfTimeFormat is the timestamp layout of the Common Log Format
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLog writes a line per answered request in the configured format
+type accessLog struct {
+	format string
+	out    io.Writer    // Destination of common and combined lines
+	json   *slog.Logger // Destination of JSON lines
+	file   *rotatingFile
+}
+
+// newAccessLog creates the access log selected in the options
+// Without an access log file, JSON lines go to the server logger and the
+// other formats to the log output
+func newAccessLog(options ServerOptions, logger *slog.Logger) (*accessLog, error) {
+	format := strings.ToLower(options.AccessLogFormat)
+	switch format {
+	case "":
+		format = AccessLogJSON
+	case AccessLogJSON, AccessLogCommon, AccessLogCombined:
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", options.AccessLogFormat)
+	}
+
+	log := &accessLog{format: format, json: logger, out: options.LogOutput}
+	if log.out == nil {
+		log.out = os.Stderr
+	}
+	if options.AccessLogFile != "" {
+		file, err := openRotatingFile(options.AccessLogFile, options.AccessLogMaxSize, options.AccessLogMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		log.file = file
+		log.out = file
+		log.json = slog.New(slog.NewJSONHandler(file, nil))
+	}
+	return log, nil
+}
+
+// write logs an answered request
+func (l *accessLog) write(r *http.Request, rw *responseWriter, start time.Time, fields []interface{}) {
+	if l.format == AccessLogJSON {
+		l.json.With(fields...).Info("Request completed",
+			"remote_addr", r.RemoteAddr,
+			"proto", r.Proto,
+			"status", rw.statusCode,
+			"bytes", rw.bytes,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+		)
+		return
+	}
+
+	// host ident authuser [date] "request line" status bytes
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user := "-"
+	if name, _, ok := r.BasicAuth(); ok && name != "" {
+		user = name
+	}
+	size := "-"
+	if rw.bytes > 0 {
+		size = strconv.FormatInt(rw.bytes, 10)
+	}
+
+	var line strings.Builder
+	fmt.Fprintf(&line, "%s - %s [%s] %s %d %s",
+		host,
+		user,
+		start.Format(clfTimeFormat),
+		strconv.Quote(r.Method+" "+r.RequestURI+" "+r.Proto),
+		rw.statusCode,
+		size,
+	)
+	if l.format == AccessLogCombined {
+		fmt.Fprintf(&line, " %s %s", quoteOrDash(r.Referer()), quoteOrDash(r.UserAgent()))
+	}
+	line.WriteByte('\n')
+	io.WriteString(l.out, line.String())
+}
+
+// quoteOrDash quotes a header value for a log line, or returns "-" if it is empty
+func quoteOrDash(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	return strconv.Quote(s)
+}
+
+// Close closes the access log file, if there is one
+func (l *accessLog) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// rotatingFile is a log file that is rotated once it reaches a maximum size
+// The current file keeps its name; older files get the suffixes .1 (newest)
+// to .N (oldest), and files beyond maxBackups are removed
+type rotatingFile struct {
+	mutex      sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// Defaults of the access log rotation
+const (
+	defaultAccessLogMaxSize    = 100 << 20 // 100 MB
+	defaultAccessLogMaxBackups = 5
+)
+
+// openRotatingFile opens or creates a log file, appending to its current contents
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if maxSize <= 0 {
+		maxSize = defaultAccessLogMaxSize
+	}
+	if maxBackups <= 0 {
+		maxBackups = defaultAccessLogMaxBackups
+	}
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the current file
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends to the file, rotating it first if p would take it over the maximum size
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			if f.file == nil {
+				return 0, err
+			}
+			// Keep appending to the current file until a rotation succeeds
+			n, writeErr := f.file.Write(p)
+			f.size += int64(n)
+			return n, errors.Join(err, writeErr)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file to .1, shifting older files up, and starts a new file
+// If the file can't be moved, the current file is opened again to append to it,
+// and the error is returned
+func (f *rotatingFile) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err == nil {
+		os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		err = os.Rename(f.path, f.path+".1")
+	}
+	return errors.Join(err, f.open())
+}
+
+// Close closes the current file
+func (f *rotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
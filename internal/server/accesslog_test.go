@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogFormats(t *testing.T) {
+	tests := []struct {
+		format string
+		want   *regexp.Regexp
+	}{
+		{AccessLogCommon, regexp.MustCompile(`^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "POST /generate\?format=csv HTTP/1\.1" 200 \d+$`)},
+		{AccessLogCombined, regexp.MustCompile(`^192\.0\.2\.1 - - \[.+\] "POST /generate\?format=csv HTTP/1\.1" 200 \d+ "http://example\.com/" "test-agent"$`)},
+	}
+	for _, tt := range tests {
+		logs := &logBuffer{}
+		options := DefaultServerOptions()
+		options.LogOutput = logs
+		options.AccessLogFormat = tt.format
+		server := NewServer(options)
+
+		req := httptest.NewRequest(http.MethodPost, "/generate?format=csv", strings.NewReader(`{"session_id":"s","letter":"A"}`))
+		req.Header.Set("Referer", "http://example.com/")
+		req.Header.Set("User-Agent", "test-agent")
+		server.createRouter().ServeHTTP(httptest.NewRecorder(), req)
+
+		var lines []string
+		for _, line := range strings.Split(strings.TrimSpace(logs.buf.String()), "\n") {
+			if !strings.HasPrefix(line, "{") {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) != 1 || !tt.want.MatchString(lines[0]) {
+			t.Errorf("%s: expected a line matching %s, got %q", tt.format, tt.want, lines)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		server.Shutdown(ctx)
+		cancel()
+	}
+
+	if _, err := newAccessLog(ServerOptions{AccessLogFormat: "apache"}, nil); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
+
+func TestAccessLogFile(t *testing.T) {
+	logs := &logBuffer{}
+	file := filepath.Join(t.TempDir(), "access.log")
+	options := DefaultServerOptions()
+	options.LogOutput = logs
+	options.AccessLogFile = file
+	server := NewServer(options)
+
+	req := httptest.NewRequest(http.MethodPost, "/generate", strings.NewReader(`{"session_id":"abc","letter":"A"}`))
+	req.Header.Set(requestIDHeader, "req-7")
+	rr := httptest.NewRecorder()
+	server.createRouter().ServeHTTP(rr, req)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+
+	// Requests are logged to the file instead of the server log, with their fields
+	if entries := logs.entries(t, "Request completed"); len(entries) != 0 {
+		t.Errorf("Expected no access log lines in the server log, got %v", entries)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Error reading access log: %v", err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("Expected a JSON line, got %q: %v", data, err)
+	}
+	if entry["request_id"] != "req-7" || entry["session_id"] != "abc" || entry["bytes"] != float64(rr.Body.Len()) {
+		t.Errorf("Unexpected access log line: %v", entry)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := openRotatingFile(path, 100, 2)
+	if err != nil {
+		t.Fatalf("Error opening file: %v", err)
+	}
+
+	// Each file holds at most three 30 byte lines
+	for i := 0; i < 10; i++ {
+		if _, err := fmt.Fprintf(f, "line %02d %s\n", i, strings.Repeat("x", 21)); err != nil {
+			t.Fatalf("Error writing line %d: %v", i, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Error closing file: %v", err)
+	}
+
+	// The newest lines are in the current file, older ones in the backups
+	expected := map[string]string{path: "line 09", path + ".1": "line 06", path + ".2": "line 03"}
+	for name, first := range expected {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", name, err)
+		}
+		if len(data) > 100 || !strings.HasPrefix(string(data), first) {
+			t.Errorf("Expected %s to start with %q and hold at most 100 bytes, got %q", name, first, data)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("Expected only two backups to be kept")
+	}
+
+	// Writes after closing fail, and reopening appends
+	if _, err := f.Write([]byte("late\n")); err == nil {
+		t.Error("Expected writing to a closed file to fail")
+	}
+	f, err = openRotatingFile(path, 100, 2)
+	if err != nil || f.size != 30 {
+		t.Errorf("Expected to continue the file at 30 bytes, got %d (err %v)", f.size, err)
+	}
+	f.Close()
+}
+
+func TestRotatingFileRenameError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := openRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatalf("Error opening file: %v", err)
+	}
+	defer f.Close()
+
+	// A directory that isn't empty can't be replaced by the rotated file
+	if err := os.MkdirAll(filepath.Join(path+".1", "keep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("first line\n"))
+	if _, err := f.Write([]byte("second line\n")); err == nil {
+		t.Error("Expected the failed rotation to be reported")
+	}
+
+	// Logging goes on in the current file
+	if _, err := f.Write([]byte("third line\n")); err == nil {
+		t.Error("Expected the rotation to be retried and fail again")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "first line\nsecond line\nthird line\n" {
+		t.Errorf("Expected all lines in the current file, got %q (err %v)", data, err)
+	}
+
+	// Once the way is clear, the next write rotates the file
+	os.RemoveAll(path + ".1")
+	if _, err := f.Write([]byte("fourth line\n")); err != nil {
+		t.Errorf("Expected the rotation to succeed, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "fourth line\n" {
+		t.Errorf("Expected a new file, got %q", data)
+	}
+}
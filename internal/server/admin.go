@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+)
+
+// CacheKeysResponse lists the keys currently held in the cache
+type CacheKeysResponse struct {
+	Count int      `json:"count"`
+	Keys  []string `json:"keys"`
+}
+
+// CacheEntryResponse represents a single cache entry
+type CacheEntryResponse struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// handleAdminCache handles cache inspection and management requests
+//
+//	GET    /admin/cache        lists the cached keys (optionally filtered by ?prefix=)
+//	DELETE /admin/cache        flushes the whole cache
+//	GET    /admin/cache/{key}  returns a single entry
+//	DELETE /admin/cache/{key}  deletes a single entry
+func (s *Server) handleAdminCache(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/cache"), "/")
+
+	// Listing and flushing need a cache that can enumerate its contents
+	inspector, ok := s.cache.(cache.Inspector)
+	if key == "" && !ok {
+		writeProblem(w, r, http.StatusNotImplemented, "Cache backend does not support inspection")
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		keys := inspector.Keys()
+		if prefix := r.URL.Query().Get("prefix"); prefix != "" {
+			filtered := keys[:0]
+			for _, k := range keys {
+				if strings.HasPrefix(k, prefix) {
+					filtered = append(filtered, k)
+				}
+			}
+			keys = filtered
+		}
+		if keys == nil {
+			keys = []string{}
+		}
+		sort.Strings(keys)
+		writeJSON(w, http.StatusOK, CacheKeysResponse{Count: len(keys), Keys: keys})
+
+	case key == "" && r.Method == http.MethodDelete:
+		inspector.Flush()
+		s.requestLogger(r).Info("Cache flushed", "remote_addr", r.RemoteAddr)
+		s.auditAction(r, "cache.flush", "", nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet:
+		value, found := s.cache.Get(key)
+		if !found {
+			writeProblem(w, r, http.StatusNotFound, "Cache entry not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, CacheEntryResponse{Key: key, Value: value})
+
+	case r.Method == http.MethodDelete:
+		s.cache.Delete(key)
+		s.requestLogger(r).Info("Cache entry deleted", "key", key, "remote_addr", r.RemoteAddr)
+		s.auditAction(r, "cache.delete", key, nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Error encoding response", "error", err)
+	}
+}
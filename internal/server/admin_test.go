@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// adminRequest sends a request to the router with the given bearer token
+func adminRequest(handler http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAdminAuth(t *testing.T) {
+	// The admin API is disabled without a token
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	if rr := adminRequest(server.createRouter(), http.MethodGet, "/admin/cache", "anything"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status Forbidden when no admin token is configured, got %v", rr.Code)
+	}
+
+	// With a token configured, requests must present it
+	server.options.AdminToken = "secret"
+	router := server.createRouter()
+
+	if rr := adminRequest(router, http.MethodGet, "/admin/cache", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status Unauthorized without a token, got %v", rr.Code)
+	}
+	if rr := adminRequest(router, http.MethodGet, "/admin/cache", "wrong"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status Unauthorized with a wrong token, got %v", rr.Code)
+	}
+	if rr := adminRequest(router, http.MethodGet, "/admin/cache", "secret"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status OK with the admin token, got %v", rr.Code)
+	}
+}
+
+func TestHandleAdminCache(t *testing.T) {
+	// Create a server with an admin token
+	options := DefaultServerOptions()
+	options.AdminToken = "secret"
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	server.storeNames("A:2", []string{"Alice", "Adam"}, time.Minute)
+	server.storeNames("B:1", []string{"Bob"}, time.Minute)
+
+	// List all keys
+	rr := adminRequest(router, http.MethodGet, "/admin/cache", "secret")
+	var keys CacheKeysResponse
+	if err := json.NewDecoder(rr.Body).Decode(&keys); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if keys.Count != 2 || len(keys.Keys) != 2 || keys.Keys[0] != "A:2" || keys.Keys[1] != "B:1" {
+		t.Errorf("Expected keys [A:2 B:1], got %v (count %d)", keys.Keys, keys.Count)
+	}
+
+	// Filter keys by prefix
+	rr = adminRequest(router, http.MethodGet, "/admin/cache?prefix=B", "secret")
+	keys = CacheKeysResponse{}
+	json.NewDecoder(rr.Body).Decode(&keys)
+	if keys.Count != 1 || keys.Keys[0] != "B:1" {
+		t.Errorf("Expected keys [B:1], got %v", keys.Keys)
+	}
+
+	// Fetch a single entry
+	rr = adminRequest(router, http.MethodGet, "/admin/cache/A:2", "secret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v", rr.Code)
+	}
+	var entry struct {
+		Key   string      `json:"key"`
+		Value cachedNames `json:"value"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&entry); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if entry.Key != "A:2" || len(entry.Value.Names) != 2 || entry.Value.Names[0] != "Alice" || entry.Value.FreshUntil.IsZero() {
+		t.Errorf("Unexpected cache entry %+v", entry)
+	}
+
+	// Missing entries are reported as not found
+	if rr := adminRequest(router, http.MethodGet, "/admin/cache/Z:9", "secret"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status NotFound, got %v", rr.Code)
+	}
+
+	// Delete a single entry
+	if rr := adminRequest(router, http.MethodDelete, "/admin/cache/A:2", "secret"); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status NoContent, got %v", rr.Code)
+	}
+	if _, found := server.cache.Get("A:2"); found {
+		t.Error("Expected 'A:2' to be deleted")
+	}
+
+	// Flush the whole cache
+	if rr := adminRequest(router, http.MethodDelete, "/admin/cache", "secret"); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status NoContent, got %v", rr.Code)
+	}
+	if server.cache.Count() != 0 {
+		t.Errorf("Expected cache to be empty after flush, got %d items", server.cache.Count())
+	}
+
+	// Other methods are rejected
+	if rr := adminRequest(router, http.MethodPost, "/admin/cache", "secret"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status MethodNotAllowed, got %v", rr.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/amirahmetzanov/go_project/internal/ui"
+)
+
+// adminListenerName is the LISTEN_FDNAMES name of a passed socket for the admin
+// server, as set with FileDescriptorName= in a systemd socket unit
+const adminListenerName = "admin"
+
+// registerAdminRoutes adds the operational endpoints: the dashboard, the
+// debugging endpoints and the admin API
+func (s *Server) registerAdminRoutes(mux *router) {
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("/stats/data", s.handleStats)
+	mux.HandleFunc("/stats.json", s.handleStatsJSON)
+	mux.HandleFunc("/stats/cluster", s.handleStatsCluster)
+	mux.HandleFunc("/stats/cluster/data", s.handleStatsCluster)
+	mux.HandleFunc("/stats/requests", s.handleStatsRequests)
+	mux.HandleFunc("/stats/requests/data", s.handleStatsRequests)
+	mux.HandleFunc("/stats/history", s.handleStatsHistory)
+	mux.HandleFunc("/stats/export", s.handleStatsExport)
+	mux.HandleFunc("/stats/stream", s.handleStatsStream)
+	mux.HandleMethod(http.MethodGet, "/static/", ui.StaticHandler().ServeHTTP)
+	mux.HandleFunc("/debug/vars", s.handleVars)
+	mux.HandleFunc("/admin/cache", s.adminAuth(s.handleAdminCache))
+	mux.HandleFunc("/admin/cache/", s.adminAuth(s.handleAdminCache))
+	mux.HandleFunc("/admin/datasets", s.adminAuth(s.handleAdminDatasets))
+	mux.HandleFunc("/admin/datasets/", s.adminAuth(s.handleAdminDatasets))
+	mux.HandleFunc("/admin/blocklist", s.adminAuth(s.handleAdminBlocklist))
+	mux.HandleFunc("/admin/loglevel", s.adminAuth(s.handleAdminLogLevel))
+	mux.HandleFunc("/admin/ratelimit", s.adminAuth(s.handleAdminRateLimit))
+	mux.HandleFunc("/admin/maintenance", s.adminAuth(s.handleAdminMaintenance))
+	mux.HandleFunc("/admin/requests", s.adminAuth(s.handleAdminRequests))
+	mux.HandleFunc("/admin/audit", s.requireRole(RoleAdmin, s.handleAdminAudit))
+	if s.options.EnablePprof {
+		s.registerPprof(mux)
+	}
+}
+
+// createAdminRouter creates the handler of the admin server
+// Its requests are logged, filtered and authenticated like the others, but
+// neither count towards the request metrics nor take a share of the rate limit,
+// and they are still answered while the server drains
+func (s *Server) createAdminRouter() http.Handler {
+	mux := newRouter()
+	s.registerAdminRoutes(mux)
+	return Chain(
+		s.loggingMiddleware,
+		s.recoveryMiddleware,
+		s.ipFilterMiddleware,
+		s.authMiddleware,
+		s.timeoutMiddleware,
+	)(mux)
+}
+
+// setAdminListener records the listener the admin server accepts connections on
+func (s *Server) setAdminListener(listener net.Listener) {
+	s.listenersMutex.Lock()
+	s.adminListener = listener
+	s.listenersMutex.Unlock()
+}
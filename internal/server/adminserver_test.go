@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAdminServerRoutes(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.AdminAddr = "127.0.0.1:0"
+	options.AdminToken = "secret"
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	if server.adminServer == nil {
+		t.Fatal("Expected an admin server")
+	}
+	public, admin := server.httpServer.Handler, server.adminServer.Handler
+
+	// The operational endpoints are only on the admin server
+	for _, path := range []string{"/stats", "/stats/data", "/static/dashboard.js", "/debug/vars", "/admin/loglevel"} {
+		if rr := adminRequest(public, http.MethodGet, path, "secret"); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for %s on the public server, got %v", path, rr.Code)
+		}
+		if rr := adminRequest(admin, http.MethodGet, path, "secret"); rr.Code != http.StatusOK {
+			t.Errorf("Expected status OK for %s on the admin server, got %v", path, rr.Code)
+		}
+	}
+	for _, path := range []string{"/healthz", "/names/A"} {
+		if rr := adminRequest(public, http.MethodGet, path, ""); rr.Code != http.StatusOK {
+			t.Errorf("Expected status OK for %s on the public server, got %v", path, rr.Code)
+		}
+		if rr := adminRequest(admin, http.MethodGet, path, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for %s on the admin server, got %v", path, rr.Code)
+		}
+	}
+
+	// The admin API still needs the token
+	if rr := adminRequest(admin, http.MethodGet, "/admin/loglevel", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %v", rr.Code)
+	}
+
+	// Requests to the admin server don't show up in the request metrics
+	before := server.metrics.GetCurrentMetrics()["requests_total"]
+	adminRequest(admin, http.MethodGet, "/stats/data", "")
+	if after := server.metrics.GetCurrentMetrics()["requests_total"]; before == nil || after != before {
+		t.Errorf("Expected the admin request not to be counted, got %v then %v", before, after)
+	}
+
+	// The dashboard can be watched while the server drains
+	server.draining.Store(true)
+	if rr := adminRequest(admin, http.MethodGet, "/stats/data", ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected the admin server to answer while draining, got %v", rr.Code)
+	}
+	server.draining.Store(false)
+}
+
+func TestStartAdminServer(t *testing.T) {
+	// Find a free port for the public server
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := free.Addr().(*net.TCPAddr).Port
+	free.Close()
+
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.Port = port
+	options.AdminAddr = "127.0.0.1:0"
+	server := NewServer(options)
+	done := make(chan error, 1)
+	go func() { done <- server.Start() }()
+
+	// Wait for the admin server to listen
+	var adminAddress string
+	for deadline := time.Now().Add(5 * time.Second); adminAddress == "" && time.Now().Before(deadline); {
+		server.listenersMutex.Lock()
+		if server.adminListener != nil {
+			adminAddress = server.adminListener.Addr().String()
+		}
+		server.listenersMutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if adminAddress == "" {
+		t.Fatal("Expected the admin server to listen")
+	}
+
+	get := func(url string) int {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("Error requesting %s: %v", url, err)
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode
+	}
+	if status := get("http://" + adminAddress + "/debug/vars"); status != http.StatusOK {
+		t.Errorf("Expected status OK from the admin server, got %v", status)
+	}
+	public := "http://127.0.0.1:" + strconv.Itoa(port)
+	if status := get(public + "/debug/vars"); status != http.StatusNotFound {
+		t.Errorf("Expected 404 from the public server, got %v", status)
+	}
+
+	resp, err := http.Get(public + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var health HealthResponse
+	json.NewDecoder(resp.Body).Decode(&health)
+	resp.Body.Close()
+	if health.Status != "ok" {
+		t.Errorf("Expected the public server to be healthy, got %q", health.Status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != http.ErrServerClosed {
+		t.Errorf("Expected ErrServerClosed, got %v", err)
+	}
+	if _, err := net.Dial("tcp", adminAddress); err == nil {
+		t.Error("Expected the admin server to be closed")
+	}
+}
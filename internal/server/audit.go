@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/amirahmetzanov/go_project/internal/audit"
+)
+
+// auditKeep is the number of audit entries kept in memory for /admin/audit
+const auditKeep = 1000
+
+// AuditLogResponse lists the most recent admin actions, newest first
+type AuditLogResponse struct {
+	Count    int           `json:"count"`
+	Intact   bool          `json:"intact"`              // Whether the chain read from the log file was unbroken
+	BrokenAt uint64        `json:"broken_at,omitempty"` // Sequence number of the first changed, removed or inserted entry
+	Head     string        `json:"head"`                // Hash of the last entry
+	Entries  []audit.Entry `json:"entries"`
+}
+
+// openAuditLog opens the audit log file, keeping the log in memory if there is
+// none or it can't be opened, so admin actions are still recorded
+func openAuditLog(options ServerOptions, logger *slog.Logger) *audit.Log {
+	log, err := audit.Open(options.AuditLogFile, auditKeep)
+	if err != nil {
+		logger.Error("Error opening the audit log, keeping it in memory", "file", options.AuditLogFile, "error", err)
+		log, _ = audit.Open("", auditKeep)
+		return log
+	}
+	if intact, brokenAt := log.Intact(); !intact {
+		logger.Warn("Audit log chain is broken", "file", options.AuditLogFile, "broken_at", brokenAt)
+	}
+	return log
+}
+
+// auditAction records an admin action of a request in the audit log
+// The action has already happened, so a failure to record it is logged rather
+// than failing the request
+func (s *Server) auditAction(r *http.Request, action, target string, details map[string]string) {
+	entry := audit.Entry{
+		Actor:      requestActor(r),
+		RemoteAddr: r.RemoteAddr,
+		Action:     action,
+		Target:     target,
+		Details:    details,
+	}
+	if log, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+		fields := log.snapshot()
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fields[i] == "request_id" {
+				entry.RequestID = fmt.Sprint(fields[i+1])
+			}
+		}
+	}
+	if _, err := s.auditLog.Append(entry); err != nil {
+		s.requestLogger(r).Error("Error recording admin action in the audit log", "action", action, "error", err)
+	}
+}
+
+// handleAdminAudit lists the most recent admin actions, newest first
+//
+//	GET /admin/audit           returns all entries kept in memory
+//	GET /admin/audit?limit=20  returns the latest 20
+func (s *Server) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var invalid validationErrors
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			invalid.add("limit", "limit must be a positive integer")
+		}
+		limit = n
+	}
+	if reqErr := invalid.err(); reqErr != nil {
+		reqErr.write(w, r)
+		return
+	}
+
+	entries := s.auditLog.Recent(limit)
+	intact, brokenAt := s.auditLog.Intact()
+	writeJSON(w, http.StatusOK, AuditLogResponse{
+		Count:    len(entries),
+		Intact:   intact,
+		BrokenAt: brokenAt,
+		Head:     s.auditLog.Head(),
+		Entries:  entries,
+	})
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/audit"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.AdminToken = "secret"
+	options.ReaderToken = "reader"
+	options.AuditLogFile = path
+	server := NewServer(options)
+	handler := server.httpServer.Handler
+
+	// Reads and rejected changes aren't recorded
+	adminRequest(handler, http.MethodGet, "/admin/cache", "secret")
+	adminRequest(handler, http.MethodDelete, "/admin/cache", "reader")
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/cache", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set(requestIDHeader, "req-1")
+	req.RemoteAddr = "10.0.0.1:5000"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"level":"debug"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the log level to change, got %v", rr.Code)
+	}
+
+	rr = adminRequest(handler, http.MethodGet, "/admin/audit", "secret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v: %s", rr.Code, rr.Body)
+	}
+	var response AuditLogResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Count != 2 || !response.Intact || response.Head != response.Entries[0].Hash {
+		t.Fatalf("Expected 2 entries of an intact log, got %+v", response)
+	}
+	level, flush := response.Entries[0], response.Entries[1]
+	if flush.Action != "cache.flush" || flush.Actor != "admin token" || flush.RequestID != "req-1" || flush.RemoteAddr != "10.0.0.1:5000" {
+		t.Errorf("Unexpected flush entry %+v", flush)
+	}
+	if level.Action != "loglevel.change" || level.Details["from"] != "INFO" || level.Details["to"] != "DEBUG" {
+		t.Errorf("Unexpected log level entry %+v", level)
+	}
+
+	if rr := adminRequest(handler, http.MethodGet, "/admin/audit?limit=1", "secret"); !strings.Contains(rr.Body.String(), `"count":1`) {
+		t.Errorf("Expected one entry with a limit, got %s", rr.Body)
+	}
+	if rr := adminRequest(handler, http.MethodGet, "/admin/audit?limit=x", "secret"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid limit, got %v", rr.Code)
+	}
+
+	// Only admins may read the audit log
+	if rr := adminRequest(handler, http.MethodGet, "/admin/audit", "reader"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a reader, got %v", rr.Code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+
+	// The entries were written to the file, and a restart continues the chain
+	if err := audit.Verify(path); err != nil {
+		t.Errorf("Expected the audit log file to verify, got %v", err)
+	}
+	server = NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	handler = server.httpServer.Handler
+	adminRequest(handler, http.MethodDelete, "/admin/cache/some-key", "secret")
+	rr = adminRequest(handler, http.MethodGet, "/admin/audit", "secret")
+	response = AuditLogResponse{}
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Count != 3 || response.Entries[0].Seq != 3 || response.Entries[0].Target != "some-key" ||
+		response.Entries[0].PrevHash != level.Hash {
+		t.Errorf("Expected the chain to continue after a restart, got %+v", response)
+	}
+}
+
+func TestAuditActorJWT(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.AuthMode = AuthJWT
+	options.JWTSecret = "jwt-secret"
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	handler := server.httpServer.Handler
+
+	token := signHS256(t, "jwt-secret", map[string]interface{}{
+		"sub":   "alice",
+		"roles": "admin",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	if rr := adminRequest(handler, http.MethodDelete, "/admin/cache", token); rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected the cache to be flushed, got %v: %s", rr.Code, rr.Body)
+	}
+	entries := server.auditLog.Recent(0)
+	if len(entries) != 1 || entries[0].Actor != "jwt:alice" {
+		t.Errorf("Expected the JWT subject as actor, got %+v", entries)
+	}
+}
@@ -0,0 +1,330 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Authentication modes of the public API
+const (
+	AuthNone = "none" // Anyone may call the API
+	AuthJWT  = "jwt"  // Requests need a valid JWT bearer token
+)
+
+// jwtLeeway is the clock skew tolerated when checking the exp and nbf claims
+const jwtLeeway = 30 * time.Second
+
+// Claims are the claims of a verified JWT
+// The registered claims are decoded into fields; all claims, including custom
+// ones such as a tier or quota, are in Values
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time // Zero if the token doesn't expire
+	NotBefore time.Time
+	IssuedAt  time.Time
+	Values    map[string]interface{}
+}
+
+// String returns a claim that is a string, or "" if it is missing or not a string
+func (c *Claims) String(name string) string {
+	value, _ := c.Values[name].(string)
+	return value
+}
+
+// claimsKey is the context key of the Claims of a request
+type claimsKey struct{}
+
+// ClaimsFromContext returns the claims of the token a request was authenticated with
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// jwtVerifier checks the signature and the registered claims of JWTs
+type jwtVerifier struct {
+	hmacKey  []byte         // Key of HS256, HS384 and HS512 tokens
+	rsaKey   *rsa.PublicKey // Key of RS256 tokens
+	issuer   string         // Required iss claim, if set
+	audience string         // Audience the aud claim must contain, if set
+	now      func() time.Time
+}
+
+// newJWTVerifier creates a verifier from the JWT options
+// Tokens are accepted with any algorithm a key is configured for
+func newJWTVerifier(options ServerOptions) (*jwtVerifier, error) {
+	v := &jwtVerifier{issuer: options.JWTIssuer, audience: options.JWTAudience, now: time.Now}
+	if options.JWTSecret != "" {
+		v.hmacKey = []byte(options.JWTSecret)
+	}
+	if options.JWTPublicKeyFile != "" {
+		key, err := loadRSAPublicKey(options.JWTPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.rsaKey = key
+	}
+	if v.hmacKey == nil && v.rsaKey == nil {
+		return nil, errors.New("JWT authentication needs a secret or a public key")
+	}
+	return v, nil
+}
+
+// loadRSAPublicKey reads an RSA public key from a PEM file holding a PKIX or
+// PKCS #1 public key or a certificate
+func loadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an RSA public key", path)
+	}
+	return rsaKey, nil
+}
+
+// verify checks a token and returns its claims
+func (v *jwtVerifier) verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errors.New("malformed token header")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	if err := v.verifySignature(header.Alg, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if err := decodeSegment(parts[1], &values); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	claims, err := parseClaims(values)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verifySignature checks the signature of the signed part of a token
+// The algorithm is only trusted if a key is configured for it, so a token
+// can't pick "none" or sign with the public key as an HMAC secret
+func (v *jwtVerifier) verifySignature(alg, signed string, signature []byte) error {
+	var newHash func() hash.Hash
+	switch alg {
+	case "HS256":
+		newHash = sha256.New
+	case "HS384":
+		newHash = sha512.New384
+	case "HS512":
+		newHash = sha512.New
+	case "RS256":
+		if v.rsaKey == nil {
+			return fmt.Errorf("unsupported algorithm %s", alg)
+		}
+		digest := sha256.Sum256([]byte(signed))
+		if rsa.VerifyPKCS1v15(v.rsaKey, crypto.SHA256, digest[:], signature) != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	if v.hmacKey == nil {
+		return fmt.Errorf("unsupported algorithm %s", alg)
+	}
+	mac := hmac.New(newHash, v.hmacKey)
+	mac.Write([]byte(signed))
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// checkClaims checks the time, issuer and audience claims
+func (v *jwtVerifier) checkClaims(claims *Claims) error {
+	now := v.now()
+	if !claims.ExpiresAt.IsZero() && now.After(claims.ExpiresAt.Add(jwtLeeway)) {
+		return errors.New("token has expired")
+	}
+	if !claims.NotBefore.IsZero() && now.Add(jwtLeeway).Before(claims.NotBefore) {
+		return errors.New("token is not valid yet")
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return errors.New("token has the wrong issuer")
+	}
+	if v.audience != "" {
+		for _, audience := range claims.Audience {
+			if audience == v.audience {
+				return nil
+			}
+		}
+		return errors.New("token has the wrong audience")
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// parseClaims decodes the registered claims of a token
+func parseClaims(values map[string]interface{}) (*Claims, error) {
+	claims := &Claims{Values: values}
+	var ok bool
+	if claims.Subject, ok = optionalString(values, "sub"); !ok {
+		return nil, errors.New("invalid sub claim")
+	}
+	if claims.Issuer, ok = optionalString(values, "iss"); !ok {
+		return nil, errors.New("invalid iss claim")
+	}
+
+	// aud is a single string or an array of strings
+	switch aud := values["aud"].(type) {
+	case nil:
+	case string:
+		claims.Audience = []string{aud}
+	case []interface{}:
+		for _, a := range aud {
+			s, ok := a.(string)
+			if !ok {
+				return nil, errors.New("invalid aud claim")
+			}
+			claims.Audience = append(claims.Audience, s)
+		}
+	default:
+		return nil, errors.New("invalid aud claim")
+	}
+
+	for name, t := range map[string]*time.Time{"exp": &claims.ExpiresAt, "nbf": &claims.NotBefore, "iat": &claims.IssuedAt} {
+		switch seconds := values[name].(type) {
+		case nil:
+		case float64:
+			*t = time.Unix(0, int64(seconds*float64(time.Second)))
+		default:
+			return nil, fmt.Errorf("invalid %s claim", name)
+		}
+	}
+	return claims, nil
+}
+
+// optionalString returns a claim that must be a string if present
+func optionalString(values map[string]interface{}, name string) (string, bool) {
+	switch value := values[name].(type) {
+	case nil:
+		return "", true
+	case string:
+		return value, true
+	default:
+		return "", false
+	}
+}
+
+// isPublicPath reports whether a path is served without a JWT
+// The admin API and the profiles check roles themselves, and health checks and
+// the API documentation are open to all
+func isPublicPath(path string) bool {
+	switch path {
+	case "/healthz", "/readyz", "/openapi.json", "/docs":
+		return true
+	}
+	return strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/debug/pprof/") || strings.HasPrefix(path, "/static/docs/")
+}
+
+// authMiddleware requires a valid JWT bearer token when JWT authentication is on,
+// and puts the claims of the token on the request context
+// /stats.json also takes the peer token, so peers can read it without a JWT
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.options.AuthMode != AuthJWT || isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Peers read the stats for the cluster view with the shared peer token
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if found && r.URL.Path == "/stats.json" && s.options.StatsPeerToken != "" &&
+			subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(s.options.StatsPeerToken)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// A server whose keys couldn't be loaded rejects every token
+		var claims *Claims
+		err := errors.New("missing bearer token")
+		if found && s.jwtVerifier != nil {
+			claims, err = s.jwtVerifier.verify(strings.TrimSpace(token))
+		} else if found {
+			err = errors.New("no keys to verify tokens with")
+		}
+		if err != nil {
+			s.requestLogger(r).Debug("Rejected token", "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			writeProblem(w, r, http.StatusUnauthorized, "Invalid token: "+err.Error())
+			return
+		}
+
+		if claims.Subject != "" {
+			addLogFields(r, "subject", claims.Subject)
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+	})
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signHS256 creates an HS256 token with the given claims
+func signHS256(t *testing.T, secret string, claims map[string]interface{}) string {
+	signed := encodeTokenSegments(t, "HS256", claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signRS256 creates an RS256 token with the given claims
+func signRS256(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	signed := encodeTokenSegments(t, "RS256", claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// encodeTokenSegments encodes the header and the claims of a token
+func encodeTokenSegments(t *testing.T, alg string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("Failed to encode claims: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// writePublicKey writes the public half of key to a PEM file
+func writePublicKey(t *testing.T, key *rsa.PrivateKey) string {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to encode public key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644); err != nil {
+		t.Fatalf("Failed to write public key: %v", err)
+	}
+	return path
+}
+
+func TestJWTVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	options := DefaultServerOptions()
+	options.JWTSecret = "secret"
+	options.JWTPublicKeyFile = writePublicKey(t, key)
+	options.JWTIssuer = "https://auth.example.com"
+	options.JWTAudience = "namegen"
+	verifier, err := newJWTVerifier(options)
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+	now := time.Unix(1_800_000_000, 0)
+	verifier.now = func() time.Time { return now }
+
+	// claims returns valid claims, changed by the given key-value pairs
+	claims := func(changes ...interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":  "user-1",
+			"iss":  "https://auth.example.com",
+			"aud":  []string{"other", "namegen"},
+			"exp":  now.Add(time.Hour).Unix(),
+			"tier": "pro",
+		}
+		for i := 0; i < len(changes); i += 2 {
+			if changes[i+1] == nil {
+				delete(c, changes[i].(string))
+			} else {
+				c[changes[i].(string)] = changes[i+1]
+			}
+		}
+		return c
+	}
+
+	// Valid HMAC and RSA tokens are accepted
+	for name, token := range map[string]string{
+		"HS256": signHS256(t, "secret", claims()),
+		"RS256": signRS256(t, key, claims()),
+	} {
+		verified, err := verifier.verify(token)
+		if err != nil {
+			t.Errorf("%s: expected the token to be valid, got %v", name, err)
+			continue
+		}
+		if verified.Subject != "user-1" || verified.String("tier") != "pro" || !verified.ExpiresAt.Equal(now.Add(time.Hour)) {
+			t.Errorf("%s: unexpected claims %+v", name, verified)
+		}
+	}
+
+	// A single audience may be given as a string, and a token without exp doesn't expire
+	if _, err := verifier.verify(signHS256(t, "secret", claims("aud", "namegen", "exp", nil))); err != nil {
+		t.Errorf("Expected a string audience to be accepted, got %v", err)
+	}
+
+	// Tokens that are forged, expired or meant for someone else are rejected
+	public := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+	for name, token := range map[string]string{
+		"wrong secret":    signHS256(t, "guess", claims()),
+		"expired":         signHS256(t, "secret", claims("exp", now.Add(-time.Minute).Unix())),
+		"not valid yet":   signHS256(t, "secret", claims("nbf", now.Add(time.Minute).Unix())),
+		"wrong issuer":    signHS256(t, "secret", claims("iss", "https://evil.example.com")),
+		"wrong audience":  signHS256(t, "secret", claims("aud", "other")),
+		"no audience":     signHS256(t, "secret", claims("aud", nil)),
+		"invalid exp":     signHS256(t, "secret", claims("exp", "tomorrow")),
+		"unsigned":        encodeTokenSegments(t, "none", claims()) + ".",
+		"public key HMAC": signHS256(t, string(public), claims()),
+		"malformed":       "not-a-token",
+	} {
+		if _, err := verifier.verify(token); err == nil {
+			t.Errorf("%s: expected the token to be rejected", name)
+		}
+	}
+
+	// Expiry is checked with some leeway for clock skew
+	if _, err := verifier.verify(signHS256(t, "secret", claims("exp", now.Add(-jwtLeeway/2).Unix()))); err != nil {
+		t.Errorf("Expected a token within the leeway to be accepted, got %v", err)
+	}
+
+	// Only the algorithms a key is configured for are accepted
+	hmacOnly, _ := newJWTVerifier(ServerOptions{JWTSecret: "secret"})
+	if _, err := hmacOnly.verify(signRS256(t, key, claims("exp", nil))); err == nil {
+		t.Error("Expected an RS256 token to be rejected without a public key")
+	}
+	if _, err := newJWTVerifier(ServerOptions{}); err == nil {
+		t.Error("Expected an error without keys")
+	}
+	if _, err := newJWTVerifier(ServerOptions{JWTPublicKeyFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("Expected an error for a missing public key file")
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	logs := &logBuffer{}
+	options := DefaultServerOptions()
+	options.AuthMode = "JWT"
+	options.JWTSecret = "secret"
+	options.LogOutput = logs
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	generate := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/generate", strings.NewReader(`{"session_id":"s1","letter":"A","num_of_entries":2}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Requests without a valid token are rejected with a problem
+	for name, token := range map[string]string{
+		"missing": "",
+		"forged":  signHS256(t, "guess", map[string]interface{}{"sub": "user-1"}),
+	} {
+		rr := generate(token)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected status Unauthorized, got %v", name, rr.Code)
+		}
+		if rr.Header().Get("WWW-Authenticate") == "" || rr.Header().Get("Content-Type") != problemContentType {
+			t.Errorf("%s: expected a bearer challenge and a problem, got %v", name, rr.Header())
+		}
+	}
+
+	// A valid token is accepted, and its subject is logged with the request
+	if rr := generate(signHS256(t, "secret", map[string]interface{}{"sub": "user-1"})); rr.Code != http.StatusOK {
+		t.Errorf("Expected status OK with a valid token, got %v: %s", rr.Code, rr.Body.String())
+	}
+	entries := logs.entries(t, "Request completed")
+	if len(entries) == 0 || entries[len(entries)-1]["subject"] != "user-1" {
+		t.Errorf("Expected the subject to be logged, got %v", entries)
+	}
+
+	// The API documentation stays open
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected the OpenAPI document without a token, got %v", rr.Code)
+	}
+
+	// Handlers find the claims on the request context
+	var tier string
+	handler := server.authMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := ClaimsFromContext(r.Context()); ok {
+			tier = claims.String("tier")
+		}
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	req.Header.Set("Authorization", "Bearer "+signHS256(t, "secret", map[string]interface{}{"tier": "pro"}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if tier != "pro" {
+		t.Errorf("Expected the tier claim on the context, got %q", tier)
+	}
+}
+
+func TestAuthMiddlewareFailsClosed(t *testing.T) {
+	options := DefaultServerOptions()
+	options.AuthMode = "jwt" // No secret or public key
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	req.Header.Set("Authorization", "Bearer "+signHS256(t, "", map[string]interface{}{"sub": "user-1"}))
+	rr := httptest.NewRecorder()
+	server.createRouter().ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status Unauthorized without keys, got %v", rr.Code)
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/workerpool"
+)
+
+// maxBatchSize is the largest number of generate requests a batch may contain
+const maxBatchSize = 100
+
+// batchQueueTimeout is how long the requests of a batch wait for room in the
+// batch pool's queue before they fail with 503
+const batchQueueTimeout = time.Second
+
+// BatchResult is the outcome of one request of a batch
+// Exactly one of Result and Error is set
+type BatchResult struct {
+	Index  int              `json:"index"`  // Position of the request in the batch
+	Status int              `json:"status"` // HTTP status the request would have had on its own
+	Result *ResponsePayload `json:"result,omitempty"`
+	Error  string           `json:"error,omitempty"`
+	Errors []FieldError     `json:"errors,omitempty"` // Invalid fields of a request that failed validation
+}
+
+// batchItem is a request of a batch, tagged with its position
+// Results arrive from the pool in any order, so the position travels with them
+type batchItem struct {
+	index  int
+	result BatchResult
+}
+
+// handleGenerateBatch handles requests for several generations at once
+// The requests of a batch are generated concurrently, and each succeeds or fails
+// on its own: the batch is answered with 200 OK and a result per request, in order
+func (s *Server) handleGenerateBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Decode the requests one by one, so a malformed request only fails itself
+	s.limitBody(w, r)
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		s.writeBodyError(w, r, err, "Request body must be an array of generate requests")
+		return
+	}
+	if len(raw) == 0 || len(raw) > maxBatchSize {
+		writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("A batch must contain 1-%d requests", maxBatchSize))
+		return
+	}
+
+	results := make([]BatchResult, len(raw))
+	tasks := make([]workerpool.Task, 0, len(raw))
+	for i, item := range raw {
+		var payload RequestPayload
+		if err := json.Unmarshal(item, &payload); err != nil {
+			results[i] = BatchResult{Index: i, Status: http.StatusBadRequest, Error: "Invalid request body"}
+			continue
+		}
+
+		index := i
+		tasks = append(tasks, func() interface{} {
+			response, reqErr := s.generate(payload)
+			if reqErr != nil {
+				return batchItem{index: index, result: BatchResult{Index: index, Status: reqErr.status, Error: reqErr.message, Errors: reqErr.fields}}
+			}
+			return batchItem{index: index, result: BatchResult{Index: index, Status: http.StatusOK, Result: &response}}
+		})
+	}
+
+	// Requests whose result never arrived, e.g. during shutdown, are reported as unavailable
+	pending := make(map[int]bool, len(tasks))
+	for i := range raw {
+		if results[i].Status == 0 {
+			pending[i] = true
+		}
+	}
+	// A slow request fails with 504 shortly before the batch's deadline, leaving
+	// a tenth of it to answer with the results of the others
+	var timeout time.Duration
+	if deadline, ok := r.Context().Deadline(); ok {
+		remaining := time.Until(deadline)
+		timeout = max(remaining-remaining/10, time.Nanosecond)
+	}
+	panics, timeouts, rejected := 0, 0, 0
+	for result := range s.batchPool.SubmitBatchTimeout(tasks, workerpool.PriorityNormal, timeout) {
+		var panicked *workerpool.PanicError
+		if errors.As(result.Err, &panicked) {
+			panics++
+			s.requestLogger(r).Error("Batch request panicked", "error", panicked, "stack", string(panicked.Stack))
+		}
+		if errors.Is(result.Err, workerpool.ErrTaskTimeout) {
+			timeouts++
+			s.requestLogger(r).Warn("Batch request timed out", "timeout", timeout.String())
+		}
+		if errors.Is(result.Err, workerpool.ErrQueueFull) {
+			rejected++
+		}
+		if item, ok := result.Value.(batchItem); ok && pending[item.index] {
+			results[item.index] = item.result
+			delete(pending, item.index)
+		}
+	}
+	// A batch of which no request could be queued is turned away as a whole
+	if rejected > 0 && rejected == len(tasks) {
+		s.requestLogger(r).Warn("Batch rejected, the batch pool is saturated", "requests", len(tasks))
+		w.Header().Set("Retry-After", "1")
+		writeProblem(w, r, http.StatusServiceUnavailable, "Server is too busy, please try again later")
+		return
+	}
+
+	// Failed tasks don't say which request they ran, so the failure is only
+	// reported when all missing results failed the same way
+	missing := BatchResult{Status: http.StatusServiceUnavailable, Error: "Request was not processed"}
+	switch len(pending) {
+	case rejected:
+		missing = BatchResult{Status: http.StatusServiceUnavailable, Error: "Server is too busy, please try again later"}
+	case timeouts:
+		missing = BatchResult{Status: http.StatusGatewayTimeout, Error: "Request timed out"}
+	case panics:
+		missing = BatchResult{Status: http.StatusInternalServerError, Error: "Internal server error"}
+	}
+	for index := range pending {
+		missing.Index = index
+		results[index] = missing
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
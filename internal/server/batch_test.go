@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/workerpool"
+)
+
+func TestHandleGenerateBatch(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	// Valid and invalid requests are answered separately, in order
+	body := `[
+		{"session_id":"s1","letter":"A","num_of_entries":3},
+		{"session_id":"s2","letter":"B","num_of_entries":2,"locale":"de"},
+		{"letter":"C"},
+		{"session_id":"s4","letter":"D","locale":"xx"},
+		"not a request",
+		{"session_id":"s6","letter":["E","F"]}
+	]`
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/generate/batch", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v: %s", rr.Code, rr.Body)
+	}
+	var results []BatchResult
+	if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if len(results) != 6 {
+		t.Fatalf("Expected 6 results, got %d", len(results))
+	}
+
+	expected := []int{http.StatusOK, http.StatusOK, http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest, http.StatusOK}
+	for i, status := range expected {
+		result := results[i]
+		if result.Index != i || result.Status != status {
+			t.Errorf("Expected result %d to have status %d, got %+v", i, status, result)
+		}
+		if status == http.StatusOK && (result.Result == nil || result.Error != "") {
+			t.Errorf("Expected result %d to succeed, got %+v", i, result)
+		}
+		if status != http.StatusOK && (result.Result != nil || result.Error == "") {
+			t.Errorf("Expected result %d to fail with an error, got %+v", i, result)
+		}
+	}
+
+	if first := results[0].Result; first.SessionID != "s1" || len(first.Names) != 3 || !strings.HasPrefix(first.Names[0], "A") {
+		t.Errorf("Unexpected result for the first request: %+v", first)
+	}
+	if second := results[1].Result; second.Locale != "de" || len(second.Names) != 2 || !strings.HasPrefix(second.Names[0], "B") {
+		t.Errorf("Unexpected result for the second request: %+v", second)
+	}
+	if last := results[5].Result; len(last.Groups) != 2 {
+		t.Errorf("Expected grouped names for the letter array, got %+v", last)
+	}
+	if results[2].Error != "session_id is required" || len(results[2].Errors) != 1 || results[2].Errors[0].Field != "session_id" {
+		t.Errorf("Expected the validation error, got %+v", results[2])
+	}
+}
+
+func TestHandleGenerateBatchErrors(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"not an array", http.MethodPost, `{"session_id":"s"}`, http.StatusBadRequest},
+		{"empty batch", http.MethodPost, `[]`, http.StatusBadRequest},
+		{"too many requests", http.MethodPost, "[" + strings.Repeat(`{"session_id":"s"},`, maxBatchSize) + `{"session_id":"s"}]`, http.StatusBadRequest},
+		{"wrong method", http.MethodGet, ``, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		server.handleGenerateBatch(rr, httptest.NewRequest(tt.method, "/generate/batch", strings.NewReader(tt.body)))
+		if rr.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, rr.Code)
+		}
+	}
+}
+
+func TestHandleGenerateBatchSaturated(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	// Occupy the only worker and the room in the queue of a small batch pool
+	server.batchPool.Shutdown()
+	server.batchPool = workerpool.New(1, workerpool.WithQueueSize(1), workerpool.WithQueueTimeout(10*time.Millisecond))
+	release := make(chan struct{})
+	started := make(chan struct{})
+	server.batchPool.Submit(func() interface{} { close(started); <-release; return nil })
+	<-started
+	server.batchPool.Submit(func() interface{} { return nil })
+	defer close(release)
+
+	rr := httptest.NewRecorder()
+	body := `[{"session_id":"s1","letter":"A"},{"session_id":"s2","letter":"B"}]`
+	server.handleGenerateBatch(rr, httptest.NewRequest(http.MethodPost, "/generate/batch", strings.NewReader(body)))
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected 503 with Retry-After while the pool is saturated, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if rejected := server.batchPool.Rejected(); rejected != 2 {
+		t.Errorf("Expected 2 rejected requests, got %d", rejected)
+	}
+}
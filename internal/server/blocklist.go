@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+)
+
+// BlocklistRequest adds names to the blocklist
+type BlocklistRequest struct {
+	Names []string `json:"names"`
+}
+
+// BlocklistResponse lists the blocked names
+type BlocklistResponse struct {
+	Count int      `json:"count"`
+	Names []string `json:"names"`
+	Added []string `json:"added,omitempty"` // Names that were added by the request
+}
+
+// handleAdminBlocklist handles requests to view and extend the blocklist
+//
+//	GET  /admin/blocklist  lists the blocked names
+//	POST /admin/blocklist  blocks the names in a BlocklistRequest
+func (s *Server) handleAdminBlocklist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		names := s.nameGenerator.Blocklist()
+		writeJSON(w, http.StatusOK, BlocklistResponse{Count: len(names), Names: names})
+
+	case http.MethodPost:
+		var request BlocklistRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Names) == 0 {
+			writeProblem(w, r, http.StatusBadRequest, "Request body must list the names to block")
+			return
+		}
+
+		added := s.nameGenerator.Block(request.Names...)
+		if len(added) > 0 {
+			s.dropBlockedNames(added)
+			if err := s.appendBlocklist(added); err != nil {
+				s.requestLogger(r).Error("Error saving blocklist", "error", err)
+				writeProblem(w, r, http.StatusInternalServerError, "Names blocked but the blocklist file could not be updated")
+				return
+			}
+			s.requestLogger(r).Info("Names blocked", "names", added, "remote_addr", r.RemoteAddr)
+			s.auditAction(r, "blocklist.add", "", map[string]string{"names": strings.Join(added, ",")})
+		}
+
+		names := s.nameGenerator.Blocklist()
+		writeJSON(w, http.StatusOK, BlocklistResponse{Count: len(names), Names: names, Added: added})
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// appendBlocklist saves newly blocked names to the blocklist file, if there is one
+func (s *Server) appendBlocklist(names []string) error {
+	if s.options.BlocklistFile == "" {
+		return nil
+	}
+
+	s.blocklistMutex.Lock()
+	defer s.blocklistMutex.Unlock()
+
+	f, err := os.OpenFile(s.options.BlocklistFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, strings.Join(names, "\n")); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dropBlockedNames deletes the cached entries that contain any of the names
+func (s *Server) dropBlockedNames(names []string) {
+	inspector, ok := s.cache.(cache.Inspector)
+	if !ok {
+		return
+	}
+
+	// Peek at the entries, so the purge doesn't count as cache lookups
+	for _, key := range inspector.Keys() {
+		value, found := inspector.Peek(key)
+		if !found {
+			continue
+		}
+		entry, ok := value.(cachedNames)
+		if !ok {
+			continue
+		}
+		for _, cached := range entry.Names {
+			if containsFold(names, cached) {
+				s.cache.Delete(key)
+				break
+			}
+		}
+	}
+}
+
+// containsFold reports whether names contains name, ignoring case
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
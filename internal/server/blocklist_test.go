@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+	"github.com/amirahmetzanov/go_project/internal/generator"
+)
+
+func TestHandleAdminBlocklist(t *testing.T) {
+	// Create a server with a blocklist file
+	file := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(file, []byte("Zara\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultServerOptions()
+	options.AdminToken = "secret"
+	options.BlocklistFile = file
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	// The blocklist file is loaded on startup
+	rr := adminRequest(router, http.MethodGet, "/admin/blocklist", "secret")
+	var list BlocklistResponse
+	if err := json.NewDecoder(rr.Body).Decode(&list); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if list.Count != 1 || list.Names[0] != "Zara" {
+		t.Errorf("Expected [Zara], got %+v", list)
+	}
+
+	// Cached names containing a newly blocked name are dropped
+	zKey := getCacheKey("Z", 2, generator.Options{Locale: "en"})
+	aKey := getCacheKey("A", 1, generator.Options{Locale: "en"})
+	server.storeNames(zKey, []string{"Zoe", "Zeus"}, time.Minute)
+	server.storeNames(aKey, []string{"Ada"}, time.Minute)
+
+	// Block more names, names already blocked in another case are left out
+	before := server.cache.(cache.StatsProvider).Stats()
+	req := httptest.NewRequest(http.MethodPost, "/admin/blocklist", strings.NewReader(`{"names":["zoe","zara","ZOE"]}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v", rr.Code)
+	}
+	list = BlocklistResponse{}
+	json.NewDecoder(rr.Body).Decode(&list)
+	if list.Count != 2 || len(list.Added) != 1 || list.Added[0] != "zoe" {
+		t.Errorf("Expected zoe to be added, got %+v", list)
+	}
+
+	// Dropping the cached names doesn't count as cache lookups
+	if after := server.cache.(cache.StatsProvider).Stats(); after.Hits != before.Hits || after.Misses != before.Misses {
+		t.Errorf("Expected no cache lookups, got %d hits and %d misses", after.Hits-before.Hits, after.Misses-before.Misses)
+	}
+
+	if _, found := server.cache.Get(zKey); found {
+		t.Error("Expected the cached names containing Zoe to be dropped")
+	}
+	if _, found := server.cache.Get(aKey); !found {
+		t.Error("Expected other cached names to be kept")
+	}
+	names, _ := server.nameGenerator.Generate("Z", 20)
+	for _, name := range names {
+		if name == "Zoe" || name == "Zara" {
+			t.Errorf("Expected %q to be blocked", name)
+		}
+	}
+
+	// Additions are saved to the blocklist file
+	if names, err := generator.LoadBlocklist(file); err != nil || strings.Join(names, ",") != "Zara,zoe" {
+		t.Errorf("Expected the file to hold Zara,zoe, got %v (err %v)", names, err)
+	}
+
+	// Requests without names are rejected
+	req = httptest.NewRequest(http.MethodPost, "/admin/blocklist", strings.NewReader(`{"names":[]}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest, got %v", rr.Code)
+	}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/ui"
+)
+
+// StatsJSONResponse is the numeric metrics of an instance, as served on
+// /stats.json for the cluster view of its peers
+type StatsJSONResponse struct {
+	Instance string             `json:"instance"` // Host name of the instance
+	Time     time.Time          `json:"time"`
+	Uptime   string             `json:"uptime"`
+	Values   map[string]float64 `json:"values"` // Counters, gauges and response time percentiles by name
+}
+
+// ClusterStats are the headline figures of an instance, or of the cluster
+type ClusterStats struct {
+	RequestsTotal     float64 `json:"requests_total"`
+	RequestsFailed    float64 `json:"requests_failed"`
+	RequestsPerSecond float64 `json:"requests_per_second_1m"`
+	Concurrent        float64 `json:"concurrent_requests"`
+	MemoryMB          float64 `json:"memory_mb"`
+	P99MS             float64 `json:"response_time_p99_ms"` // For the cluster, the slowest of the instances
+}
+
+// ErrorRate returns the percentage of the requests that failed
+func (c ClusterStats) ErrorRate() float64 {
+	if c.RequestsTotal == 0 {
+		return 0
+	}
+	return c.RequestsFailed / c.RequestsTotal * 100
+}
+
+// add adds the figures of an instance to those of the cluster
+func (c *ClusterStats) add(instance ClusterStats) {
+	c.RequestsTotal += instance.RequestsTotal
+	c.RequestsFailed += instance.RequestsFailed
+	c.RequestsPerSecond += instance.RequestsPerSecond
+	c.Concurrent += instance.Concurrent
+	c.MemoryMB += instance.MemoryMB
+	if instance.P99MS > c.P99MS {
+		c.P99MS = instance.P99MS
+	}
+}
+
+// ClusterInstance is an instance of the cluster view
+type ClusterInstance struct {
+	Name   string       `json:"name"`
+	URL    string       `json:"url,omitempty"`   // Empty for the instance serving the view
+	Error  string       `json:"error,omitempty"` // Why the stats of a peer couldn't be read
+	Uptime string       `json:"uptime,omitempty"`
+	Stats  ClusterStats `json:"stats"`
+}
+
+// ClusterView is this instance and its peers, with the totals of those that answered
+type ClusterView struct {
+	Instances []ClusterInstance `json:"instances"`
+	Up        int               `json:"up"` // Instances whose stats could be read
+	Total     ClusterStats      `json:"total"`
+}
+
+// clusterStats picks the headline figures out of the numeric metrics
+func clusterStats(values map[string]float64) ClusterStats {
+	return ClusterStats{
+		RequestsTotal:     values["requests_total"],
+		RequestsFailed:    values["requests_failed"],
+		RequestsPerSecond: values["requests_per_second_1m"],
+		Concurrent:        values["concurrent_requests"],
+		MemoryMB:          values["memory_usage"] / 1024 / 1024,
+		P99MS:             values["response_time_p99_ms"],
+	}
+}
+
+// statsJSON returns the numeric metrics of this instance
+func (s *Server) statsJSON() StatsJSONResponse {
+	name, err := os.Hostname()
+	if err != nil {
+		name = "localhost"
+	}
+	s.metrics.UpdateMemoryUsage()
+	return StatsJSONResponse{
+		Instance: name,
+		Time:     time.Now(),
+		Uptime:   s.metrics.GetUptime().String(),
+		Values:   s.metrics.Values(),
+	}
+}
+
+// handleStatsJSON serves the numeric metrics of this instance
+func (s *Server) handleStatsJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.statsJSON())
+}
+
+// clusterView reads the stats of the peers concurrently and adds them up
+// with those of this instance; a peer that doesn't answer in time is shown
+// with its error and left out of the totals
+func (s *Server) clusterView(r *http.Request) ClusterView {
+	local := s.statsJSON()
+	instances := make([]ClusterInstance, len(s.options.StatsPeers)+1)
+	instances[0] = ClusterInstance{Name: local.Instance + " (this instance)", Uptime: local.Uptime, Stats: clusterStats(local.Values)}
+
+	client := &http.Client{Timeout: s.options.StatsPeerTimeout}
+	var wg sync.WaitGroup
+	for i, peer := range s.options.StatsPeers {
+		wg.Add(1)
+		go func(instance *ClusterInstance, peer string) {
+			defer wg.Done()
+			*instance = ClusterInstance{Name: peer, URL: peer}
+			if u, err := url.Parse(peer); err == nil && u.Host != "" {
+				instance.Name = u.Host
+			}
+			stats, err := fetchPeerStats(r.Context(), client, peer, s.options.StatsPeerToken)
+			if err != nil {
+				instance.Error = err.Error()
+				s.requestLogger(r).Warn("Error reading the stats of a peer", "peer", peer, "error", err)
+				return
+			}
+			instance.Name, instance.Uptime, instance.Stats = stats.Instance, stats.Uptime, clusterStats(stats.Values)
+		}(&instances[i+1], peer)
+	}
+	wg.Wait()
+
+	view := ClusterView{Instances: instances}
+	for _, instance := range instances {
+		if instance.Error == "" {
+			view.Up++
+			view.Total.add(instance.Stats)
+		}
+	}
+	return view
+}
+
+// fetchPeerStats reads /stats.json of a peer, sending the peer token if one
+// is configured
+func fetchPeerStats(ctx context.Context, client *http.Client, peer, token string) (StatsJSONResponse, error) {
+	var stats StatsJSONResponse
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(peer, "/")+"/stats.json", nil)
+	if err != nil {
+		return stats, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return stats, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return stats, fmt.Errorf("invalid stats: %w", err)
+	}
+	return stats, nil
+}
+
+// handleStatsCluster serves the aggregated view of this instance and its
+// peers: the page on /stats/cluster, its instances and totals on
+// /stats/cluster/data for the page to refresh
+func (s *Server) handleStatsCluster(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if len(s.options.StatsPeers) == 0 {
+		writeProblem(w, r, http.StatusNotImplemented, "No peers are configured (StatsPeers is empty)")
+		return
+	}
+
+	name := "statsCluster"
+	if r.URL.Path == "/stats/cluster/data" {
+		name = "statsClusterData"
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := ui.StatsTemplate.ExecuteTemplate(w, name, s.clusterView(r)); err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to render the cluster view")
+		s.requestLogger(r).Error("Error rendering the cluster view", "error", err)
+	}
+}
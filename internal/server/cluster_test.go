@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsJSON(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	handler := server.createRouter()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/names/A", nil))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stats.json", nil))
+	var stats StatsJSONResponse
+	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode /stats.json: %v", err)
+	}
+	hostname, _ := os.Hostname()
+	if stats.Instance != hostname || stats.Uptime == "" {
+		t.Errorf("Expected the host name and uptime, got %+v", stats)
+	}
+	if stats.Values["requests_succeeded"] != 1 || stats.Values["memory_usage"] == 0 {
+		t.Errorf("Expected the numeric metrics, got %v", stats.Values)
+	}
+	if _, ok := stats.Values["response_time_p99_ms"]; !ok {
+		t.Error("Expected the response time percentiles")
+	}
+}
+
+func TestStatsCluster(t *testing.T) {
+	// A peer that answers, with two requests, and one that is gone
+	peerOptions := DefaultServerOptions()
+	peerOptions.LogOutput = &logBuffer{}
+	peer := NewServer(peerOptions)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		peer.Shutdown(ctx)
+	}()
+	peerServer := httptest.NewServer(peer.createRouter())
+	defer peerServer.Close()
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(peerServer.URL + "/names/A")
+		if err != nil {
+			t.Fatalf("Failed to request names from the peer: %v", err)
+		}
+		resp.Body.Close()
+	}
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+
+	options := DefaultServerOptions()
+	options.StatsPeers = []string{peerServer.URL + "/", gone.URL}
+	options.StatsPeerTimeout = time.Second
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	handler := server.createRouter()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/names/A", nil))
+
+	// The reachable instances are added up, the other is shown with its error
+	view := server.clusterView(httptest.NewRequest("GET", "/stats/cluster", nil))
+	if len(view.Instances) != 3 || view.Up != 2 {
+		t.Fatalf("Expected 2 of 3 instances up, got %+v", view)
+	}
+	if view.Total.RequestsTotal < 3 || view.Instances[1].Stats.RequestsTotal < 2 {
+		t.Errorf("Expected the requests of both instances in the total, got %+v", view.Total)
+	}
+	if !strings.HasSuffix(view.Instances[0].Name, "(this instance)") || view.Instances[2].Error == "" || view.Instances[2].URL != gone.URL {
+		t.Errorf("Expected this instance first and the unreachable peer with its error, got %+v", view.Instances)
+	}
+
+	// The page and the data it refreshes
+	for _, path := range []string{"/stats/cluster", "/stats/cluster/data"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		body := rr.Body.String()
+		if rr.Code != http.StatusOK || !strings.Contains(body, "2 / 3") || !strings.Contains(body, "Unreachable") {
+			t.Errorf("Expected %s to show 2 of 3 instances up, got %d %s", path, rr.Code, body)
+		}
+		if strings.Contains(body, "<html") != (path == "/stats/cluster") {
+			t.Errorf("Expected only %s to be a whole page", "/stats/cluster")
+		}
+	}
+
+	// The dashboard links the cluster view
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stats", nil))
+	if !strings.Contains(rr.Body.String(), `href="/stats/cluster"`) {
+		t.Error("Expected the dashboard to link the cluster view")
+	}
+}
+
+func TestStatsClusterAuth(t *testing.T) {
+	// A peer that requires a JWT, and takes the peer token for its stats
+	peerOptions := DefaultServerOptions()
+	peerOptions.AuthMode = AuthJWT
+	peerOptions.JWTSecret = "jwt-secret"
+	peerOptions.StatsPeerToken = "peer-secret"
+	peerOptions.LogOutput = &logBuffer{}
+	peer := NewServer(peerOptions)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		peer.Shutdown(ctx)
+	}()
+	peerServer := httptest.NewServer(peer.createRouter())
+	defer peerServer.Close()
+
+	// The peer token only opens /stats.json
+	for path, expected := range map[string]int{"/stats.json": http.StatusOK, "/names/A": http.StatusUnauthorized, "/stats": http.StatusUnauthorized} {
+		req, _ := http.NewRequest(http.MethodGet, peerServer.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer peer-secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expected {
+			t.Errorf("Expected status %d for %s with the peer token, got %d", expected, path, resp.StatusCode)
+		}
+	}
+
+	for token, up := range map[string]int{"peer-secret": 2, "wrong": 1, "": 1} {
+		options := DefaultServerOptions()
+		options.StatsPeers = []string{peerServer.URL}
+		options.StatsPeerTimeout = time.Second
+		options.StatsPeerToken = token
+		options.LogOutput = &logBuffer{}
+		server := NewServer(options)
+		view := server.clusterView(httptest.NewRequest("GET", "/stats/cluster", nil))
+		server.Shutdown(context.Background())
+
+		if view.Up != up {
+			t.Errorf("Expected %d instances up with token %q, got %+v", up, token, view.Instances)
+		}
+		if up == 1 && view.Instances[1].Error != "status 401" {
+			t.Errorf("Expected the peer to reject token %q, got %q", token, view.Instances[1].Error)
+		}
+	}
+}
+
+func TestStatsClusterWithoutPeers(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	handler := server.createRouter()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stats/cluster", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d without peers, got %d", http.StatusNotImplemented, rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stats", nil))
+	if strings.Contains(rr.Body.String(), `href="/stats/cluster"`) {
+		t.Error("Expected no link to the cluster view without peers")
+	}
+}
+
+func TestClusterStatsAdd(t *testing.T) {
+	var total ClusterStats
+	total.add(ClusterStats{RequestsTotal: 100, RequestsFailed: 5, RequestsPerSecond: 10, P99MS: 120})
+	total.add(ClusterStats{RequestsTotal: 300, RequestsFailed: 15, RequestsPerSecond: 30, P99MS: 80})
+	if total.RequestsTotal != 400 || total.RequestsPerSecond != 40 || total.ErrorRate() != 5 {
+		t.Errorf("Expected the counts and rates to be summed, got %+v", total)
+	}
+	if total.P99MS != 120 {
+		t.Errorf("Expected the slowest P99, got %v", total.P99MS)
+	}
+	if (ClusterStats{}).ErrorRate() != 0 {
+		t.Error("Expected no error rate without requests")
+	}
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+)
+
+// EnvPrefix is the prefix of the environment variables that configure the server
+const EnvPrefix = "NAMEGEN_"
+
+// durationType is the type of the time.Duration options
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// envAliases are the variables without the prefix that some options were
+// configured with before every option had one; the prefixed variable wins
+var envAliases = map[string]string{
+	"Port":             "PORT",
+	"AdminToken":       "ADMIN_TOKEN",
+	"ReaderToken":      "READER_TOKEN",
+	"NamesDir":         "NAMES_DIR",
+	"BlocklistFile":    "BLOCKLIST_FILE",
+	"LogLevel":         "LOG_LEVEL",
+	"AccessLogFormat":  "ACCESS_LOG_FORMAT",
+	"AccessLogFile":    "ACCESS_LOG_FILE",
+	"AuthMode":         "AUTH_MODE",
+	"JWTSecret":        "JWT_SECRET",
+	"JWTPublicKeyFile": "JWT_PUBLIC_KEY_FILE",
+	"JWTIssuer":        "JWT_ISSUER",
+	"JWTAudience":      "JWT_AUDIENCE",
+	"IPAllowList":      "IP_ALLOWLIST",
+	"IPDenyList":       "IP_DENYLIST",
+	"EnablePprof":      "ENABLE_PPROF",
+	"TLSDomains":       "ACME_DOMAINS",
+	"ACMECacheDir":     "ACME_CACHE_DIR",
+	"ACMEDirectoryURL": "ACME_DIRECTORY_URL",
+	"ACMEEmail":        "ACME_EMAIL",
+}
+
+// ApplyEnv sets the options from the environment: every field has a variable
+// named after it with EnvPrefix, e.g. NAMEGEN_MAX_CONCURRENT_REQUESTS for
+// MaxConcurrentRequests, and variables that are set override the current value
+// The older short names, such as ADMIN_TOKEN, are read too
+// Durations are written like "500ms", lists separated by commas, and
+// RouteTimeouts as "/generate=2s,/stats/data=500ms". Empty variables only
+// clear text options. All invalid variables are reported at once, and the
+// options are left unchanged in that case
+func (o *ServerOptions) ApplyEnv() error {
+	updated := *o
+	value := reflect.ValueOf(&updated).Elem()
+
+	var invalid []string
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := EnvName(field.Name)
+		raw, ok := os.LookupEnv(name)
+		if alias, hasAlias := envAliases[field.Name]; !ok && hasAlias {
+			name = alias
+			raw, ok = os.LookupEnv(alias)
+		}
+		if !ok || !envSupported(field.Type) || (raw == "" && field.Type.Kind() != reflect.String) {
+			continue
+		}
+		if err := setFromEnv(value.Field(i), raw); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("invalid environment variables: %s", strings.Join(invalid, "; "))
+	}
+	*o = updated
+	return nil
+}
+
+// LoadConfigFile sets the options from a JSON file whose keys are the names of
+// the options in snake case, e.g.
+//
+//	{"max_concurrent_requests": 10000, "cache_expiration": "30m",
+//	 "ip_allow_list": ["10.0.0.0/8"], "route_timeouts": {"/generate": "2s"}}
+//
+// Values are written like the environment variables, but lists and route
+// timeouts may also be JSON arrays and objects. Unknown keys are errors, so
+// misspelled options don't go unnoticed
+func (o *ServerOptions) LoadConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var entries map[string]interface{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	updated := *o
+	value := reflect.ValueOf(&updated).Elem()
+	fields := make(map[string]int)
+	for i := 0; i < value.NumField(); i++ {
+		if envSupported(value.Type().Field(i).Type) {
+			fields[optionKey(value.Type().Field(i).Name)] = i
+		}
+	}
+
+	var invalid []string
+	for key, entry := range entries {
+		i, ok := fields[key]
+		if !ok {
+			invalid = append(invalid, fmt.Sprintf("%s: unknown option", key))
+			continue
+		}
+		if err := setFromEnv(value.Field(i), configString(entry)); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return fmt.Errorf("invalid options in %s: %s", path, strings.Join(invalid, "; "))
+	}
+	*o = updated
+	return nil
+}
+
+// Validate reports unknown cache backends and eviction policies and options
+// that can't be used together
+// CacheMaxBytes only bounds the shards of the lru eviction policy, so it is an
+// error with lfu or arc rather than leaving their memory unbounded
+func (o ServerOptions) Validate() error {
+	switch o.CacheBackend {
+	case "", "memory", "redis":
+	default:
+		return fmt.Errorf("unknown cache backend %q, expected memory or redis", o.CacheBackend)
+	}
+	if !cache.EvictionPolicy(o.CacheEvictionPolicy).Valid() {
+		return fmt.Errorf("unknown cache eviction policy %q, expected lru, lfu or arc", o.CacheEvictionPolicy)
+	}
+	if o.CacheMaxBytes > 0 && (o.CacheBackend == "" || o.CacheBackend == "memory") {
+		switch cache.EvictionPolicy(o.CacheEvictionPolicy) {
+		case cache.EvictionLFU, cache.EvictionARC:
+			return fmt.Errorf("cache_max_bytes requires the lru cache eviction policy, not %s", o.CacheEvictionPolicy)
+		}
+	}
+	// Without the cache directory every restart orders new certificates
+	if len(o.TLSDomains) > 0 && o.ACMECacheDir == "" {
+		return fmt.Errorf("acme_domains requires acme_cache_dir, to keep the certificates across restarts")
+	}
+	return nil
+}
+
+// configString writes a JSON value of a config file like an environment variable
+func configString(entry interface{}) string {
+	switch v := entry.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = configString(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		pairs := make([]string, 0, len(v))
+		for key, item := range v {
+			pairs = append(pairs, key+"="+configString(item))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	}
+	return fmt.Sprint(entry)
+}
+
+// EnvName returns the environment variable of an option field, splitting the
+// words and acronyms of its name, e.g. "JWTSecret" becomes "NAMEGEN_JWT_SECRET"
+func EnvName(field string) string {
+	return EnvPrefix + strings.ToUpper(optionKey(field))
+}
+
+// optionKey returns the snake case name of an option field, e.g. "jwt_secret"
+func optionKey(field string) string {
+	runes := []rune(field)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			acronymEnds := unicode.IsUpper(previous) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || acronymEnds {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// envSupported reports whether an option of a type can be set from the
+// environment; writers such as LogOutput can't
+func envSupported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && t.Elem() == durationType
+	}
+	return false
+}
+
+// setFromEnv parses the value of an environment variable into an option
+func setFromEnv(field reflect.Value, raw string) error {
+	switch {
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", raw)
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(raw)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		field.SetBool(b)
+	case field.Kind() == reflect.Int || field.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		field.SetInt(n)
+	case field.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		field.SetFloat(f)
+	case field.Kind() == reflect.Slice:
+		field.Set(reflect.ValueOf(splitEnvList(raw)))
+	case field.Kind() == reflect.Map:
+		timeouts := make(map[string]time.Duration)
+		for _, entry := range splitEnvList(raw) {
+			path, value, ok := strings.Cut(entry, "=")
+			d, err := time.ParseDuration(value)
+			if !ok || err != nil {
+				return fmt.Errorf("%q is not a path=duration pair", entry)
+			}
+			timeouts[path] = d
+		}
+		field.Set(reflect.ValueOf(timeouts))
+	}
+	return nil
+}
+
+// splitEnvList splits a comma-separated list, dropping empty entries and spaces
+func splitEnvList(raw string) []string {
+	var list []string
+	for _, entry := range strings.Split(raw, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"MaxConcurrentRequests": "NAMEGEN_MAX_CONCURRENT_REQUESTS",
+		"JWTSecret":             "NAMEGEN_JWT_SECRET",
+		"IPAllowList":           "NAMEGEN_IP_ALLOW_LIST",
+		"RedisDB":               "NAMEGEN_REDIS_DB",
+		"ACMEDirectoryURL":      "NAMEGEN_ACME_DIRECTORY_URL",
+		"EnablePprof":           "NAMEGEN_ENABLE_PPROF",
+	}
+	for field, expected := range tests {
+		if name := EnvName(field); name != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, field, name)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("NAMEGEN_MAX_CONCURRENT_REQUESTS", "100")
+	t.Setenv("NAMEGEN_REQUEST_RATE_LIMIT", "12.5")
+	t.Setenv("NAMEGEN_MAX_ENTRIES", "50")
+	t.Setenv("NAMEGEN_CACHE_EXPIRATION", "90s")
+	t.Setenv("NAMEGEN_CACHE_BACKEND", "redis")
+	t.Setenv("NAMEGEN_ENABLE_PPROF", "true")
+	t.Setenv("NAMEGEN_IP_DENY_LIST", "10.0.0.0/8, 192.168.1.1,")
+	t.Setenv("NAMEGEN_ROUTE_TIMEOUTS", "/generate=1s,/names/{letter}=250ms")
+
+	options := DefaultServerOptions()
+	options.AdminToken = "from-code"
+	if err := options.ApplyEnv(); err != nil {
+		t.Fatalf("Error applying the environment: %v", err)
+	}
+
+	if options.MaxConcurrentRequests != 100 || options.RequestRateLimit != 12.5 || options.MaxEntries != 50 {
+		t.Errorf("Expected the numbers to be set, got %d, %v, %d", options.MaxConcurrentRequests, options.RequestRateLimit, options.MaxEntries)
+	}
+	if options.CacheExpiration != 90*time.Second || options.CacheBackend != "redis" || !options.EnablePprof {
+		t.Errorf("Unexpected options %v, %q, %v", options.CacheExpiration, options.CacheBackend, options.EnablePprof)
+	}
+	if !reflect.DeepEqual(options.IPDenyList, []string{"10.0.0.0/8", "192.168.1.1"}) {
+		t.Errorf("Expected the deny list to be split, got %q", options.IPDenyList)
+	}
+	expected := map[string]time.Duration{"/generate": time.Second, "/names/{letter}": 250 * time.Millisecond}
+	if !reflect.DeepEqual(options.RouteTimeouts, expected) {
+		t.Errorf("Expected route timeouts %v, got %v", expected, options.RouteTimeouts)
+	}
+
+	// Options without a variable keep their value
+	if options.AdminToken != "from-code" || options.CacheSize != DefaultServerOptions().CacheSize {
+		t.Errorf("Expected unset variables to leave the options alone, got %q and %d", options.AdminToken, options.CacheSize)
+	}
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	t.Setenv("NAMEGEN_MAX_ENTRIES", "many")
+	t.Setenv("NAMEGEN_CACHE_EXPIRATION", "10")
+	t.Setenv("NAMEGEN_ENABLE_PPROF", "yes please")
+	t.Setenv("NAMEGEN_ROUTE_TIMEOUTS", "/generate")
+	t.Setenv("NAMEGEN_CACHE_SIZE", "10")
+
+	options := DefaultServerOptions()
+	err := options.ApplyEnv()
+	if err == nil {
+		t.Fatal("Expected an error for invalid variables")
+	}
+	for _, name := range []string{"NAMEGEN_MAX_ENTRIES", "NAMEGEN_CACHE_EXPIRATION", "NAMEGEN_ENABLE_PPROF", "NAMEGEN_ROUTE_TIMEOUTS"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected %s to be reported, got %v", name, err)
+		}
+	}
+
+	// Nothing is applied when a variable is invalid
+	if options.CacheSize != DefaultServerOptions().CacheSize {
+		t.Errorf("Expected the options to be unchanged, got cache size %d", options.CacheSize)
+	}
+}
+
+func TestEveryOptionHasAVariable(t *testing.T) {
+	// Only writers can't be configured from the environment
+	optionsType := reflect.TypeOf(ServerOptions{})
+	for i := 0; i < optionsType.NumField(); i++ {
+		field := optionsType.Field(i)
+		if !envSupported(field.Type) && field.Name != "LogOutput" {
+			t.Errorf("Option %s of type %v can't be set from the environment", field.Name, field.Type)
+		}
+	}
+}
+
+func TestApplyEnvAliases(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "short")
+	t.Setenv("IP_ALLOWLIST", "10.0.0.0/8")
+	t.Setenv("PORT", "")
+	t.Setenv("READER_TOKEN", "short")
+	t.Setenv("NAMEGEN_READER_TOKEN", "prefixed")
+
+	options := DefaultServerOptions()
+	options.Port = 9000
+	if err := options.ApplyEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if options.AdminToken != "short" || len(options.IPAllowList) != 1 {
+		t.Errorf("Expected the short names to be read, got %q and %q", options.AdminToken, options.IPAllowList)
+	}
+	if options.ReaderToken != "prefixed" {
+		t.Errorf("Expected the prefixed variable to win, got %q", options.ReaderToken)
+	}
+
+	// An empty PORT doesn't clear the port
+	if options.Port != 9000 {
+		t.Errorf("Expected the port to be kept, got %d", options.Port)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := `{
+		"port": 9090,
+		"workers": 4,
+		"request_rate_limit": 250.5,
+		"cache_expiration": "30m",
+		"enable_pprof": true,
+		"admin_token": "from-file",
+		"ip_deny_list": ["10.0.0.0/8", "192.168.0.0/16"],
+		"route_timeouts": {"/generate": "1s", "/names/{letter}": "300ms"}
+	}`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	options := DefaultServerOptions()
+	if err := options.LoadConfigFile(path); err != nil {
+		t.Fatalf("Error loading the config file: %v", err)
+	}
+	if options.Port != 9090 || options.Workers != 4 || options.RequestRateLimit != 250.5 {
+		t.Errorf("Unexpected numbers %d, %d, %v", options.Port, options.Workers, options.RequestRateLimit)
+	}
+	if options.CacheExpiration != 30*time.Minute || !options.EnablePprof || options.AdminToken != "from-file" {
+		t.Errorf("Unexpected options %v, %v, %q", options.CacheExpiration, options.EnablePprof, options.AdminToken)
+	}
+	if !reflect.DeepEqual(options.IPDenyList, []string{"10.0.0.0/8", "192.168.0.0/16"}) {
+		t.Errorf("Expected the deny list from the array, got %q", options.IPDenyList)
+	}
+	expected := map[string]time.Duration{"/generate": time.Second, "/names/{letter}": 300 * time.Millisecond}
+	if !reflect.DeepEqual(options.RouteTimeouts, expected) {
+		t.Errorf("Expected route timeouts %v, got %v", expected, options.RouteTimeouts)
+	}
+
+	// The environment takes precedence over the file
+	t.Setenv("NAMEGEN_WORKERS", "8")
+	if err := options.ApplyEnv(); err != nil || options.Workers != 8 {
+		t.Errorf("Expected the environment to override the file, got %d, %v", options.Workers, err)
+	}
+}
+
+func TestLoadConfigFileInvalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"unknown option": `{"max_conncurrent_requests": 10}`,
+		"invalid value":  `{"cache_expiration": 30}`,
+		"log output":     `{"log_output": "stdout"}`,
+		"not an object":  `["port", 8080]`,
+	}
+	for name, config := range tests {
+		path := filepath.Join(dir, strings.ReplaceAll(name, " ", "_")+".json")
+		os.WriteFile(path, []byte(config), 0o600)
+		options := DefaultServerOptions()
+		if err := options.LoadConfigFile(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if !reflect.DeepEqual(options, DefaultServerOptions()) {
+			t.Errorf("%s: expected the options to be unchanged", name)
+		}
+	}
+
+	options := DefaultServerOptions()
+	if err := options.LoadConfigFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultServerOptions().Validate(); err != nil {
+		t.Errorf("Expected the default options to be valid, got %v", err)
+	}
+
+	// The memory budget only applies to the lru eviction policy, and unknown
+	// backends and policies are rejected
+	tests := []struct {
+		backend, policy string
+		valid           bool
+	}{
+		{"memory", "lru", true},
+		{"memory", "lfu", false},
+		{"", "arc", false},
+		{"redis", "lfu", true},
+		{"memory", "", true},
+		{"memory", "lfuu", false},
+		{"redis", "lfuu", false},
+		{"redis", "lru", true},
+		{"memcached", "lru", false},
+		{"Redis", "lru", false},
+	}
+	for _, tt := range tests {
+		options := DefaultServerOptions()
+		options.CacheBackend = tt.backend
+		options.CacheEvictionPolicy = tt.policy
+		options.CacheMaxBytes = 64 << 20
+		if err := options.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s/%s: expected valid to be %v, got %v", tt.backend, tt.policy, tt.valid, err)
+		}
+	}
+
+	// Certificates from ACME need a directory to survive restarts
+	options := DefaultServerOptions()
+	options.TLSDomains = []string{"names.example.com"}
+	if err := options.Validate(); err == nil || !strings.Contains(err.Error(), "acme_cache_dir") {
+		t.Errorf("Expected ACME domains without a cache directory to be rejected, got %v", err)
+	}
+	options.ACMECacheDir = t.TempDir()
+	if err := options.Validate(); err != nil {
+		t.Errorf("Expected ACME domains with a cache directory to be valid, got %v", err)
+	}
+
+	// Servers built with invalid options log them and fall back
+	logs := &logBuffer{}
+	options = DefaultServerOptions()
+	options.CacheBackend = "memcached"
+	options.CacheEvictionPolicy = "lfuu"
+	options.LogOutput = logs
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	if _, ok := server.cache.(*cache.ConcurrentLRUCache); !ok {
+		t.Errorf("Expected the memory cache, got %T", server.cache)
+	}
+	for _, msg := range []string{"Invalid options, falling back for the invalid settings", "Unknown cache backend, falling back to memory", "Unknown cache eviction policy, falling back to lru"} {
+		if len(logs.entries(t, msg)) != 1 {
+			t.Errorf("Expected %q to be logged once, got %v", msg, logs.entries(t, msg))
+		}
+	}
+}
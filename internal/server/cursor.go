@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor returns an opaque cursor pointing at a position in the sequence of a seed
+func encodeCursor(seed int64, position int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", seed, position)))
+}
+
+// decodeCursor returns the seed and position encoded in a cursor
+func decodeCursor(cursor string) (seed int64, position int, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+
+	// Reject anything that doesn't round-trip, such as trailing data
+	if _, err := fmt.Sscanf(string(data), "%d:%d", &seed, &position); err != nil || encodeCursor(seed, position) != cursor {
+		return 0, 0, ErrInvalidCursor
+	}
+	if seed == 0 || position < 0 {
+		return 0, 0, ErrInvalidCursor
+	}
+
+	return seed, position, nil
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	// Cursors round-trip
+	for _, tt := range []struct {
+		seed     int64
+		position int
+	}{
+		{1, 0},
+		{42, 100},
+		{-9223372036854775808, 2147483647},
+	} {
+		seed, position, err := decodeCursor(encodeCursor(tt.seed, tt.position))
+		if err != nil || seed != tt.seed || position != tt.position {
+			t.Errorf("Expected seed %d and position %d, got %d, %d (err %v)", tt.seed, tt.position, seed, position, err)
+		}
+	}
+
+	// Malformed cursors are rejected
+	for _, cursor := range []string{
+		"",
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("42")),
+		base64.RawURLEncoding.EncodeToString([]byte("42:10:extra")),
+		base64.RawURLEncoding.EncodeToString([]byte("0:10")),
+		base64.RawURLEncoding.EncodeToString([]byte("42:-1")),
+	} {
+		if _, _, err := decodeCursor(cursor); err != ErrInvalidCursor {
+			t.Errorf("Expected ErrInvalidCursor for %q, got %v", cursor, err)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+	"github.com/amirahmetzanov/go_project/internal/generator"
+)
+
+// maxDatasetSize is the largest dataset file that can be uploaded
+const maxDatasetSize = 10 << 20 // 10 MB
+
+var (
+	// datasetNamePattern matches the names uploaded datasets can be stored under
+	datasetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+	// localePattern matches locale tags such as "de" or "pt-br"
+	localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+)
+
+// DatasetInfo describes a dataset file in the names directory
+type DatasetInfo struct {
+	ID       string    `json:"id"` // Locale and file name, e.g. "de/popular.csv"
+	Locale   string    `json:"locale"`
+	Format   string    `json:"format"` // "json" or "csv"
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// DatasetsResponse lists the dataset files in the names directory
+type DatasetsResponse struct {
+	Count    int           `json:"count"`
+	Datasets []DatasetInfo `json:"datasets"`
+}
+
+// DatasetsReloadResponse lists the letters whose names changed in a reload, by locale
+type DatasetsReloadResponse struct {
+	Changes generator.Changes `json:"changes"`
+}
+
+// DatasetStatsResponse describes the names loaded by the generator, so clients
+// can discover the supported values of letter and locale
+type DatasetStatsResponse struct {
+	DefaultLocale string               `json:"default_locale"` // Used by requests without a locale
+	Count         int                  `json:"count"`          // Number of locales
+	Locales       []LocaleDatasetStats `json:"locales"`
+}
+
+// LocaleDatasetStats describes the names loaded for a locale
+type LocaleDatasetStats struct {
+	Locale   string         `json:"locale"`
+	Version  string         `json:"version"` // Changes whenever the names or weights of the locale do
+	Names    int            `json:"names"`
+	Weighted bool           `json:"weighted"` // Whether names of some letters are picked by popularity
+	Letters  map[string]int `json:"letters"`  // Names by initial letter
+}
+
+// handleDatasetStats serves the locales and letters that names are generated for
+//
+//	GET /datasets/stats
+func (s *Server) handleDatasetStats(w http.ResponseWriter, r *http.Request) {
+	stats := s.nameGenerator.DatasetStats()
+	response := DatasetStatsResponse{
+		DefaultLocale: generator.DefaultLocale,
+		Count:         len(stats),
+		Locales:       make([]LocaleDatasetStats, len(stats)),
+	}
+	for i, locale := range stats {
+		response.Locales[i] = LocaleDatasetStats{
+			Locale:   locale.Locale,
+			Version:  locale.Version,
+			Names:    locale.Names,
+			Weighted: locale.Weighted,
+			Letters:  locale.Letters,
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleAdminDatasets handles requests to manage the name datasets at runtime
+// Datasets are files in NamesDir, and every change is loaded into the generator immediately
+//
+//	GET    /admin/datasets                          lists the dataset files
+//	POST   /admin/datasets?name={name}&locale={tag} uploads a JSON or CSV file (by Content-Type)
+//	DELETE /admin/datasets/{locale}/{file}          deletes a dataset file
+//	POST   /admin/datasets/reload                   reloads the files changed outside the API
+func (s *Server) handleAdminDatasets(w http.ResponseWriter, r *http.Request) {
+	if s.options.NamesDir == "" {
+		writeProblem(w, r, http.StatusNotImplemented, "Datasets can only be managed when a names directory is configured")
+		return
+	}
+
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/datasets"), "/")
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		datasets, err := s.listDatasets()
+		if err != nil {
+			s.requestLogger(r).Error("Error listing datasets", "error", err)
+			writeProblem(w, r, http.StatusInternalServerError, "Failed to list datasets")
+			return
+		}
+		writeJSON(w, http.StatusOK, DatasetsResponse{Count: len(datasets), Datasets: datasets})
+
+	case id == "" && r.Method == http.MethodPost:
+		s.uploadDataset(w, r)
+
+	case id == "reload" && r.Method == http.MethodPost:
+		s.reloadDatasetFiles(w, r)
+
+	case id != "" && r.Method == http.MethodDelete:
+		s.deleteDataset(w, r, id)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// uploadDataset validates an uploaded dataset, stores it and loads it
+func (s *Server) uploadDataset(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if !datasetNamePattern.MatchString(name) {
+		writeProblem(w, r, http.StatusBadRequest, "Dataset name must be 1-64 letters, digits, '-' or '_'")
+		return
+	}
+	locale := strings.ToLower(strings.ReplaceAll(r.URL.Query().Get("locale"), "_", "-"))
+	if locale == "" {
+		locale = generator.DefaultLocale
+	}
+	if !localePattern.MatchString(locale) {
+		writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid locale %q", locale))
+		return
+	}
+
+	// The format is given by the content type
+	var format string
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		format = "json"
+	case "text/csv":
+		format = "csv"
+	default:
+		writeProblem(w, r, http.StatusUnsupportedMediaType, "Content-Type must be application/json or text/csv")
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDatasetSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Dataset exceeds %d bytes", maxDatasetSize))
+			return
+		}
+		writeProblem(w, r, http.StatusBadRequest, "Failed to read dataset")
+		return
+	}
+
+	// Parse the file on its own first, so a bad upload never reaches the names directory
+	staging, err := os.MkdirTemp("", "dataset-")
+	if err != nil {
+		s.requestLogger(r).Error("Error creating staging directory", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to store dataset")
+		return
+	}
+	defer os.RemoveAll(staging)
+
+	file := name + "." + format
+	staged := filepath.Join(staging, file)
+	if err := os.WriteFile(staged, data, 0o644); err != nil {
+		s.requestLogger(r).Error("Error staging dataset", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to store dataset")
+		return
+	}
+	if _, err := generator.LoadDataset(staging, locale); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid dataset: %v", strings.TrimPrefix(err.Error(), "generator: ")))
+		return
+	}
+
+	s.datasetsMutex.Lock()
+	defer s.datasetsMutex.Unlock()
+
+	dir := s.localeDir(locale)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		s.requestLogger(r).Error("Error creating dataset directory", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to store dataset")
+		return
+	}
+
+	// Write next to the target and rename, so the directory never holds a partial file
+	target := filepath.Join(dir, file)
+	tmp := filepath.Join(dir, "."+file+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		s.requestLogger(r).Error("Error writing dataset", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to store dataset")
+		return
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		s.requestLogger(r).Error("Error writing dataset", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to store dataset")
+		return
+	}
+
+	if _, err := s.reloadDatasets(); err != nil {
+		s.requestLogger(r).Error("Error loading datasets after upload", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Dataset stored but could not be loaded")
+		return
+	}
+	s.requestLogger(r).Info("Dataset uploaded", "locale", locale, "file", file, "remote_addr", r.RemoteAddr)
+	sum := sha256.Sum256(data)
+	s.auditAction(r, "dataset.upload", locale+"/"+file, map[string]string{
+		"bytes":  strconv.Itoa(len(data)),
+		"sha256": hex.EncodeToString(sum[:]),
+	})
+
+	info, err := datasetInfo(locale, target)
+	if err != nil {
+		s.requestLogger(r).Error("Error reading dataset", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Dataset stored but could not be read")
+		return
+	}
+	w.Header().Set("Location", "/admin/datasets/"+info.ID)
+	writeJSON(w, http.StatusCreated, info)
+}
+
+// deleteDataset deletes a dataset file and unloads its names
+func (s *Server) deleteDataset(w http.ResponseWriter, r *http.Request, id string) {
+	// IDs are "{locale}/{file}", which also rules out paths outside the names directory
+	locale, file, ok := strings.Cut(id, "/")
+	ext := filepath.Ext(file)
+	if !ok || !localePattern.MatchString(locale) || (ext != ".json" && ext != ".csv") ||
+		!datasetNamePattern.MatchString(strings.TrimSuffix(file, ext)) {
+		writeProblem(w, r, http.StatusNotFound, "Dataset not found")
+		return
+	}
+
+	s.datasetsMutex.Lock()
+	defer s.datasetsMutex.Unlock()
+
+	if err := os.Remove(filepath.Join(s.localeDir(locale), file)); err != nil {
+		if os.IsNotExist(err) {
+			writeProblem(w, r, http.StatusNotFound, "Dataset not found")
+			return
+		}
+		s.requestLogger(r).Error("Error deleting dataset", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to delete dataset")
+		return
+	}
+
+	if _, err := s.reloadDatasets(); err != nil {
+		s.requestLogger(r).Error("Error loading datasets after delete", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Dataset deleted but names could not be reloaded")
+		return
+	}
+	s.requestLogger(r).Info("Dataset deleted", "dataset", id, "remote_addr", r.RemoteAddr)
+	s.auditAction(r, "dataset.delete", id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// localeDir returns the directory holding the dataset files of a locale
+func (s *Server) localeDir(locale string) string {
+	if locale == generator.DefaultLocale {
+		return s.options.NamesDir
+	}
+	return filepath.Join(s.options.NamesDir, locale)
+}
+
+// reloadDatasetFiles reloads the names directory, for files changed by other means
+// than the API, and returns the letters whose names changed
+func (s *Server) reloadDatasetFiles(w http.ResponseWriter, r *http.Request) {
+	s.datasetsMutex.Lock()
+	defer s.datasetsMutex.Unlock()
+
+	changes, err := s.reloadDatasets()
+	if err != nil {
+		s.requestLogger(r).Error("Error reloading datasets", "error", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to reload datasets, the current names are kept")
+		return
+	}
+	s.requestLogger(r).Info("Datasets reloaded", "changes", changes, "remote_addr", r.RemoteAddr)
+	s.auditAction(r, "dataset.reload", "", nil)
+
+	writeJSON(w, http.StatusOK, DatasetsReloadResponse{Changes: changes})
+}
+
+// reloadDatasets loads the names directory into the generator and drops the cached
+// names of the letters that changed, which may no longer match their dataset
+func (s *Server) reloadDatasets() (generator.Changes, error) {
+	changes, err := s.nameGenerator.ReloadNames(s.options.NamesDir)
+	if err != nil {
+		return nil, err
+	}
+	s.dropCachedNames(changes)
+	return changes, nil
+}
+
+// namesReloaded handles a reload of the names directory by the watcher
+func (s *Server) namesReloaded(changes generator.Changes, err error) {
+	if err != nil {
+		s.logger.Error("Error reloading names, keeping the current names", "dir", s.options.NamesDir, "error", err)
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+	s.dropCachedNames(changes)
+	s.logger.Info("Reloaded changed names", "dir", s.options.NamesDir, "changes", changes)
+}
+
+// dropCachedNames drops the cached names of the changed letters
+func (s *Server) dropCachedNames(changes generator.Changes) {
+	inspector, ok := s.cache.(cache.Inspector)
+	if !ok || len(changes) == 0 {
+		return
+	}
+	for _, key := range inspector.Keys() {
+		// Keys start with the locale and the letter or prefix, see getCacheKey
+		locale, rest, _ := strings.Cut(key, ":")
+		letter, _, _ := strings.Cut(rest, ":")
+		if changes.Affects(locale, letter) {
+			s.cache.Delete(key)
+		}
+	}
+}
+
+// listDatasets returns the dataset files in the names directory, sorted by ID
+func (s *Server) listDatasets() ([]DatasetInfo, error) {
+	entries, err := os.ReadDir(s.options.NamesDir)
+	if os.IsNotExist(err) {
+		return []DatasetInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	datasets := []DatasetInfo{}
+	add := func(locale, dir string) error {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			ext := strings.ToLower(filepath.Ext(file.Name()))
+			if file.IsDir() || (ext != ".json" && ext != ".csv") {
+				continue
+			}
+			info, err := datasetInfo(locale, filepath.Join(dir, file.Name()))
+			if err != nil {
+				return err
+			}
+			datasets = append(datasets, info)
+		}
+		return nil
+	}
+
+	if err := add(generator.DefaultLocale, s.options.NamesDir); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if err := add(entry.Name(), filepath.Join(s.options.NamesDir, entry.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Slice(datasets, func(i, j int) bool {
+		return datasets[i].ID < datasets[j].ID
+	})
+	return datasets, nil
+}
+
+// datasetInfo describes the dataset file at path
+func datasetInfo(locale, path string) (DatasetInfo, error) {
+	name := filepath.Base(path)
+	info := DatasetInfo{
+		ID:     locale + "/" + name,
+		Locale: locale,
+		Format: strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), "."),
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return info, err
+	}
+	info.Size = stat.Size()
+	info.Modified = stat.ModTime().UTC()
+	return info, nil
+}
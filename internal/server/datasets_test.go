@@ -0,0 +1,305 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/generator"
+)
+
+// uploadDataset posts a dataset file to the admin API
+func uploadDataset(handler http.Handler, query, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/datasets?"+query, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestHandleAdminDatasets(t *testing.T) {
+	// Create a server with an admin token and an empty names directory
+	options := DefaultServerOptions()
+	options.AdminToken = "secret"
+	options.NamesDir = t.TempDir()
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	// Cached German names are dropped when the German dataset changes
+	cachedKey := getCacheKey("Q", 1, generator.Options{Locale: "de"})
+	server.storeNames(cachedKey, []string{"Quirin"}, time.Minute)
+
+	// Upload a CSV dataset for a locale
+	rr := uploadDataset(router, "name=popular&locale=DE", "text/csv", "name,weight\nQuentin,2\nQuirina,1\n")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status Created, got %v: %s", rr.Code, rr.Body)
+	}
+	var info DatasetInfo
+	if err := json.NewDecoder(rr.Body).Decode(&info); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if info.ID != "de/popular.csv" || info.Format != "csv" || info.Size == 0 || rr.Header().Get("Location") != "/admin/datasets/de/popular.csv" {
+		t.Errorf("Unexpected dataset info %+v (Location %q)", info, rr.Header().Get("Location"))
+	}
+	if _, err := os.Stat(filepath.Join(options.NamesDir, "de", "popular.csv")); err != nil {
+		t.Errorf("Expected the dataset to be stored: %v", err)
+	}
+	if _, found := server.cache.Get(cachedKey); found {
+		t.Error("Expected the cached German names to be dropped")
+	}
+
+	// The names are used right away
+	names, _ := server.nameGenerator.GenerateWithOptions(context.Background(), "Q", 5, generator.Options{Locale: "de-DE", Unique: true})
+	if len(names) != 2 {
+		t.Errorf("Expected the 2 uploaded names, got %v", names)
+	}
+
+	// Upload a JSON dataset for the default locale
+	if rr := uploadDataset(router, "name=extra", "application/json; charset=utf-8", `["Xerxes"]`); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status Created, got %v: %s", rr.Code, rr.Body)
+	}
+
+	// List the datasets
+	rr = adminRequest(router, http.MethodGet, "/admin/datasets", "secret")
+	var list DatasetsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&list); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if list.Count != 2 || list.Datasets[0].ID != "de/popular.csv" || list.Datasets[1].ID != "en/extra.json" {
+		t.Errorf("Unexpected datasets %+v", list.Datasets)
+	}
+
+	// Delete a dataset, which restores the built-in names
+	if rr := adminRequest(router, http.MethodDelete, "/admin/datasets/en/extra.json", "secret"); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status NoContent, got %v", rr.Code)
+	}
+	if names, _ := server.nameGenerator.Generate("A", 3); len(names) != 3 {
+		t.Errorf("Expected the built-in names after delete, got %v", names)
+	}
+	if rr := adminRequest(router, http.MethodDelete, "/admin/datasets/en/extra.json", "secret"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status NotFound for a deleted dataset, got %v", rr.Code)
+	}
+
+	// The uploads and the delete are in the audit log, newest first
+	var actions []string
+	for _, entry := range server.auditLog.Recent(0) {
+		actions = append(actions, entry.Action+" "+entry.Target)
+	}
+	if strings.Join(actions, ", ") != "dataset.delete en/extra.json, dataset.upload en/extra.json, dataset.upload de/popular.csv" {
+		t.Errorf("Unexpected audit log entries %v", actions)
+	}
+
+	// The datasets are only reachable with the admin token
+	if rr := adminRequest(router, http.MethodGet, "/admin/datasets", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status Unauthorized, got %v", rr.Code)
+	}
+}
+
+func TestHandleAdminDatasetsErrors(t *testing.T) {
+	options := DefaultServerOptions()
+	options.AdminToken = "secret"
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	// Without a names directory there is nowhere to store datasets
+	if rr := adminRequest(server.createRouter(), http.MethodGet, "/admin/datasets", "secret"); rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status NotImplemented, got %v", rr.Code)
+	}
+
+	server.options.NamesDir = t.TempDir()
+	router := server.createRouter()
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"missing name", "", "text/csv", "Ada\n", http.StatusBadRequest},
+		{"path in name", "name=../evil", "text/csv", "Ada\n", http.StatusBadRequest},
+		{"invalid locale", "name=a&locale=../x", "text/csv", "Ada\n", http.StatusBadRequest},
+		{"unknown format", "name=a", "text/plain", "Ada\n", http.StatusUnsupportedMediaType},
+		{"malformed JSON", "name=a", "application/json", `{"A": 1}`, http.StatusBadRequest},
+		{"no names", "name=a", "text/csv", "name\n", http.StatusBadRequest},
+		{"invalid weight", "name=a", "text/csv", "name,weight\nAda,-1\n", http.StatusBadRequest},
+		{"too large", "name=a", "text/csv", strings.Repeat("Ada\n", maxDatasetSize/4+1), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		if rr := uploadDataset(router, tt.query, tt.contentType, tt.body); rr.Code != tt.want {
+			t.Errorf("%s: expected status %v, got %v", tt.name, tt.want, rr.Code)
+		}
+	}
+
+	// Rejected uploads leave the directory untouched
+	if entries, _ := os.ReadDir(server.options.NamesDir); len(entries) != 0 {
+		t.Errorf("Expected no files after rejected uploads, got %d", len(entries))
+	}
+
+	// Deleting files outside the names directory is not possible
+	for _, path := range []string{"/admin/datasets/en/../../etc.csv", "/admin/datasets/../names.csv", "/admin/datasets/en/names.txt"} {
+		rr := httptest.NewRecorder()
+		server.handleAdminDatasets(rr, httptest.NewRequest(http.MethodDelete, path, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("Expected status NotFound for %s, got %v", path, rr.Code)
+		}
+	}
+}
+
+func TestHandleAdminDatasetsReload(t *testing.T) {
+	options := DefaultServerOptions()
+	options.AdminToken = "secret"
+	options.NamesDir = t.TempDir()
+	options.NamesWatchInterval = 0
+	if err := os.Mkdir(filepath.Join(options.NamesDir, "de"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(options.NamesDir, "de", "names.csv"), []byte("Quirin\nZacharias\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	quirin := getCacheKey("Q", 1, generator.Options{Locale: "de"})
+	zacharias := getCacheKey("z", 1, generator.Options{Locale: "de"})
+	server.storeNames(quirin, []string{"Quirin"}, time.Minute)
+	server.storeNames(zacharias, []string{"Zacharias"}, time.Minute)
+
+	// A file changed outside the API is loaded on request
+	if err := os.WriteFile(filepath.Join(options.NamesDir, "de", "names.csv"), []byte("Quentin\nZacharias\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rr := adminRequest(router, http.MethodPost, "/admin/datasets/reload", "secret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v: %s", rr.Code, rr.Body)
+	}
+	var response DatasetsReloadResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if len(response.Changes) != 1 || strings.Join(response.Changes["de"], ",") != "Q" {
+		t.Errorf("Expected Q of de to change, got %v", response.Changes)
+	}
+
+	// Only the names of the changed letter are dropped from the cache
+	if _, found := server.cache.Get(quirin); found {
+		t.Error("Expected the cached Q names to be dropped")
+	}
+	if _, found := server.cache.Get(zacharias); !found {
+		t.Error("Expected the cached Z names to be kept")
+	}
+	if names, _ := server.nameGenerator.GenerateWithOptions(context.Background(), "Q", 1, generator.Options{Locale: "de"}); len(names) != 1 || names[0] != "Quentin" {
+		t.Errorf("Expected the reloaded name, got %v", names)
+	}
+	if entries := server.auditLog.Recent(1); len(entries) != 1 || entries[0].Action != "dataset.reload" {
+		t.Errorf("Expected the reload in the audit log, got %v", entries)
+	}
+
+	// Readers may not reload
+	if rr := adminRequest(router, http.MethodPost, "/admin/datasets/reload", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status Unauthorized, got %v", rr.Code)
+	}
+}
+
+func TestNamesWatcher(t *testing.T) {
+	options := DefaultServerOptions()
+	options.NamesDir = t.TempDir()
+	options.NamesWatchInterval = 10 * time.Millisecond
+	if err := os.WriteFile(filepath.Join(options.NamesDir, "names.csv"), []byte("Zed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	cached := getCacheKey("Z", 1, generator.Options{Locale: generator.DefaultLocale})
+	server.storeNames(cached, []string{"Zed"}, time.Minute)
+
+	// Changed files are picked up without a request
+	if err := os.WriteFile(filepath.Join(options.NamesDir, "names.csv"), []byte("Zelda\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		// The cached names are dropped right after the reload
+		_, found := server.cache.Get(cached)
+		names, _ := server.nameGenerator.Generate("Z", 1)
+		if !found && len(names) == 1 && names[0] == "Zelda" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the changed file to be reloaded and the cached names dropped, got %v (cached: %v)", names, found)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandleDatasetStats(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/datasets/stats", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v: %s", rr.Code, rr.Body)
+	}
+	var response DatasetStatsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if response.DefaultLocale != "en" || response.Count != len(server.nameGenerator.Locales()) || len(response.Locales) != response.Count {
+		t.Fatalf("Unexpected response %+v", response)
+	}
+	var en LocaleDatasetStats
+	for _, locale := range response.Locales {
+		if locale.Locale == "en" {
+			en = locale
+		}
+	}
+	if en.Letters["A"] != len(generator.NamesByLetter["A"]) || en.Names == 0 || en.Version == "" {
+		t.Errorf("Unexpected stats for en: %+v", en)
+	}
+
+	// Blocking a name makes a new version
+	server.nameGenerator.Block("Adam")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/datasets/stats", nil))
+	if strings.Contains(rr.Body.String(), `"version":"`+en.Version+`"`) {
+		t.Errorf("Expected a new version for en, got %s", rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/datasets/stats", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status MethodNotAllowed, got %v", rr.Code)
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// drainPollInterval is how often Shutdown checks for requests still in flight
+const drainPollInterval = 10 * time.Millisecond
+
+// drainMiddleware rejects new requests once the server is draining, except for
+// health checks, and asks clients to reconnect elsewhere
+func (s *Server) drainMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.draining.Load() && !isHealthPath(r.URL.Path) {
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", "1")
+			writeProblem(w, r, http.StatusServiceUnavailable, "Server is shutting down")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// drain waits until no requests are in flight, or until ctx is done
+// Requests are counted by the metrics collector, so the health checks and
+// rejected requests arriving meanwhile are included, but only briefly
+func (s *Server) drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for s.metrics.GetCurrentConcurrent() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	router := server.createRouter()
+
+	health := func() (int, string) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var response HealthResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return rr.Code, response.Status
+	}
+	if code, status := health(); code != http.StatusOK || status != "ok" {
+		t.Fatalf("Expected a healthy server, got %d %q", code, status)
+	}
+
+	// Hold a request in flight while the server shuts down
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := server.metricsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- server.Shutdown(ctx)
+	}()
+
+	// While draining, health checks report it and other requests are turned away
+	deadline := time.Now().Add(time.Second)
+	for !server.draining.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if code, status := health(); code != http.StatusServiceUnavailable || status != "draining" {
+		t.Errorf("Expected /healthz to report draining, got %d %q", code, status)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/stats/data", nil))
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Connection") != "close" {
+		t.Errorf("Expected new requests to be rejected, got %d %v", rr.Code, rr.Header())
+	}
+
+	// Shutdown waits for the request in flight
+	select {
+	case err := <-stopped:
+		t.Fatalf("Expected Shutdown to wait for the request in flight, returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("Expected Shutdown to succeed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Shutdown to finish once the request completed")
+	}
+}
+
+func TestShutdownDrainDeadline(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+
+	// A request that never finishes doesn't block Shutdown past its deadline
+	done := server.metrics.RecordRequest()
+	defer done(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	server.Shutdown(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Shutdown to give up at the deadline, took %v", elapsed)
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/amirahmetzanov/go_project/internal/namespb"
+)
+
+// responseFormat is a representation /generate can answer with
+type responseFormat struct {
+	name        string   // Value of the format query parameter
+	contentType string   // Media type sent in the Content-Type header
+	mediaTypes  []string // Media types of the Accept header that select the format
+	// encode writes the response, nil for formats that are streamed
+	encode func(w http.ResponseWriter, response ResponsePayload) error
+}
+
+// streamed reports whether the names are written as they are generated
+func (f *responseFormat) streamed() bool {
+	return f.encode == nil
+}
+
+// responseFormats lists the supported formats, the first being the default
+var responseFormats = []*responseFormat{
+	{name: "json", contentType: "application/json", mediaTypes: []string{"application/json"}, encode: encodeJSON},
+	{name: "csv", contentType: "text/csv; charset=utf-8", mediaTypes: []string{"text/csv"}, encode: encodeCSV},
+	{name: "xml", contentType: "application/xml; charset=utf-8", mediaTypes: []string{"application/xml", "text/xml"}, encode: encodeXML},
+	{name: "protobuf", contentType: namespb.ContentType, mediaTypes: []string{namespb.ContentType, "application/protobuf"}, encode: encodeProtobuf},
+	{name: "ndjson", contentType: ndjsonContentType, mediaTypes: []string{ndjsonContentType, "application/ndjson"}},
+}
+
+// negotiateFormat picks the response format of a request
+// The format query parameter takes precedence over the Accept header; among the
+// media types of the header, the one with the highest quality wins, ties going
+// to the first. Without either, protobuf requests get protobuf responses and
+// other requests get JSON. It reports false if the client accepts none of the formats
+func negotiateFormat(r *http.Request) (*responseFormat, bool) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, format := range responseFormats {
+			if strings.EqualFold(format.name, name) {
+				return format, true
+			}
+		}
+		return nil, false
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		if isProtobuf(r) {
+			return formatForMediaType(namespb.ContentType), true
+		}
+		return responseFormats[0], true
+	}
+
+	var best *responseFormat
+	bestQuality := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if format := formatForMediaType(mediaType); format != nil && quality > bestQuality {
+			best, bestQuality = format, quality
+		}
+	}
+	return best, best != nil
+}
+
+// formatForMediaType returns the format a media type selects, or nil
+// Wildcards select the default format
+func formatForMediaType(mediaType string) *responseFormat {
+	if mediaType == "*/*" || mediaType == "application/*" {
+		return responseFormats[0]
+	}
+	for _, format := range responseFormats {
+		for _, t := range format.mediaTypes {
+			if t == mediaType {
+				return format
+			}
+		}
+	}
+	return nil
+}
+
+// isProtobuf reports whether the request body is a protobuf message
+func isProtobuf(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == namespb.ContentType || mediaType == "application/protobuf"
+}
+
+// limitBody caps the request body at the configured size, so a client can't
+// make the server buffer an arbitrarily large payload
+func (s *Server) limitBody(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.options.MaxRequestBodySize)
+}
+
+// writeBodyError reports a request body that couldn't be read or decoded,
+// with 413 if it exceeded the size limit
+func (s *Server) writeBodyError(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds the limit of %d bytes", tooLarge.Limit))
+		return
+	}
+	writeProblem(w, r, http.StatusBadRequest, detail)
+}
+
+// decodePayload reads a generate request, encoded as JSON or, by Content-Type, protobuf
+func decodePayload(r *http.Request) (RequestPayload, error) {
+	var payload RequestPayload
+	if !isProtobuf(r) {
+		err := json.NewDecoder(r.Body).Decode(&payload)
+		return payload, err
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return payload, err
+	}
+	var request namespb.GenerateRequest
+	if err := request.Unmarshal(body); err != nil {
+		return payload, err
+	}
+	return payloadFromProto(&request), nil
+}
+
+// writeResponse writes a generate response in the negotiated format
+func writeResponse(w http.ResponseWriter, format *responseFormat, response ResponsePayload) error {
+	w.Header().Set("Content-Type", format.contentType)
+	return format.encode(w, response)
+}
+
+// encodeJSON writes the response as a JSON object
+func encodeJSON(w http.ResponseWriter, response ResponsePayload) error {
+	return json.NewEncoder(w).Encode(response)
+}
+
+// encodeCSV writes the names as CSV with a header row
+// Names of a letter array are written with their letter in a first column. The
+// paging fields have no place in the rows, so they are sent as headers
+func encodeCSV(w http.ResponseWriter, response ResponsePayload) error {
+	if response.Locale != "" {
+		w.Header().Set("X-Locale", response.Locale)
+	}
+	if response.Seed != 0 {
+		w.Header().Set("X-Seed", strconv.FormatInt(response.Seed, 10))
+	}
+	if response.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", response.NextCursor)
+	}
+
+	writer := csv.NewWriter(w)
+	if len(response.Groups) > 0 {
+		writer.Write([]string{"letter", "name"})
+		for _, group := range response.Groups {
+			for _, name := range group.Names {
+				writer.Write([]string{group.Letter, name})
+			}
+		}
+	} else {
+		writer.Write([]string{"name"})
+		for _, name := range response.Names {
+			writer.Write([]string{name})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// encodeXML writes the response as a <response> document
+func encodeXML(w http.ResponseWriter, response ResponsePayload) error {
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	if err := encoder.EncodeElement(response, xml.StartElement{Name: xml.Name{Local: "response"}}); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
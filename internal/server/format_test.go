@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		accept string
+		query  string
+		want   string // Name of the format, empty if none is acceptable
+	}{
+		{"", "", "json"},
+		{"*/*", "", "json"},
+		{"application/json", "", "json"},
+		{"text/csv", "", "csv"},
+		{"text/xml", "", "xml"},
+		{"application/xml;q=0.5, text/csv;q=0.8", "", "csv"},
+		{"text/html, application/xml;q=0.9, */*;q=0.8", "", "xml"},
+		{"application/x-ndjson", "", "ndjson"},
+		{"application/x-protobuf", "", "protobuf"},
+		{"text/csv;q=0", "", ""},
+		{"image/png", "", ""},
+		{"application/json", "csv", "csv"},
+		{"", "XML", "xml"},
+		{"", "yaml", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/generate?format="+tt.query, nil)
+		req.Header.Set("Accept", tt.accept)
+		format, ok := negotiateFormat(req)
+		got := ""
+		if ok {
+			got = format.name
+		}
+		if got != tt.want {
+			t.Errorf("negotiateFormat(Accept %q, format %q) = %q, want %q", tt.accept, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateNamesFormats(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	generate := func(target, accept, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		server.handleGenerateNames(rr, req)
+		return rr
+	}
+	body := `{"session_id":"s","letter":"A","num_of_entries":3,"seed":8}`
+
+	// The same names are returned in every format
+	rr := generate("/generate", "application/json", body)
+	var response ResponsePayload
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error parsing JSON: %v", err)
+	}
+	if len(response.Names) != 3 {
+		t.Fatalf("Expected 3 names, got %v", response.Names)
+	}
+	expected := strings.Join(response.Names, ",")
+
+	// CSV has a header row, and the paging fields as headers
+	rr = generate("/generate", "text/csv", body)
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Expected CSV content type, got %q", ct)
+	}
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Error parsing CSV: %v", err)
+	}
+	var csvNames []string
+	for _, record := range records[1:] {
+		csvNames = append(csvNames, record[0])
+	}
+	if records[0][0] != "name" || strings.Join(csvNames, ",") != expected {
+		t.Errorf("Expected the names %s in CSV, got %v", expected, records)
+	}
+	if rr.Header().Get("X-Seed") != "8" || rr.Header().Get("X-Next-Cursor") != response.NextCursor {
+		t.Errorf("Expected the seed and cursor headers, got %v", rr.Header())
+	}
+
+	// XML has the fields of the JSON object
+	rr = generate("/generate?format=xml", "", body)
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+		t.Errorf("Expected XML content type, got %q", ct)
+	}
+	var xmlResponse ResponsePayload
+	if err := xml.NewDecoder(rr.Body).Decode(&xmlResponse); err != nil {
+		t.Fatalf("Error parsing XML: %v", err)
+	}
+	if strings.Join(xmlResponse.Names, ",") != expected || xmlResponse.SessionID != "s" || xmlResponse.Seed != 8 || xmlResponse.NextCursor != response.NextCursor {
+		t.Errorf("Expected the XML to match the JSON, got %+v and %+v", xmlResponse, response)
+	}
+
+	// Letter arrays keep their groups
+	rr = generate("/generate", "text/csv", `{"session_id":"s","letter":["A","B"],"num_of_entries":2}`)
+	records, err = csv.NewReader(rr.Body).ReadAll()
+	if err != nil || len(records) != 5 || records[0][0] != "letter" || records[1][0] != "A" || records[4][0] != "B" {
+		t.Errorf("Expected grouped CSV rows, got %v (err %v)", records, err)
+	}
+	rr = generate("/generate", "application/xml", `{"session_id":"s","letter":["A","B"],"num_of_entries":2}`)
+	xmlResponse = ResponsePayload{}
+	if err := xml.NewDecoder(rr.Body).Decode(&xmlResponse); err != nil || len(xmlResponse.Groups) != 2 || xmlResponse.Groups[1].Letter != "B" || len(xmlResponse.Groups[1].Names) != 2 {
+		t.Errorf("Expected grouped XML, got %+v (err %v)", xmlResponse, err)
+	}
+
+	// Unsupported formats are not acceptable
+	if rr := generate("/generate", "image/png", body); rr.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected status NotAcceptable, got %v", rr.Code)
+	}
+	if rr := generate("/generate?format=yaml", "", body); rr.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected status NotAcceptable, got %v", rr.Code)
+	}
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	options := DefaultServerOptions()
+	options.MaxRequestBodySize = 256
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Requests within the limit are served
+	if rr := post("/generate", `{"session_id":"s1","letter":"A","num_of_entries":2}`); rr.Code != http.StatusOK {
+		t.Errorf("Expected status OK for a small body, got %v", rr.Code)
+	}
+
+	// Larger bodies are rejected with a problem, on both endpoints
+	large := `{"session_id":"` + strings.Repeat("x", 300) + `","letter":"A"}`
+	for _, tt := range []struct{ path, body string }{
+		{"/generate", large},
+		{"/generate/batch", "[" + large + "]"},
+	} {
+		rr := post(tt.path, tt.body)
+		if rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected status RequestEntityTooLarge, got %v", tt.path, rr.Code)
+			continue
+		}
+		var problem Problem
+		if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil || !strings.Contains(problem.Detail, "256 bytes") {
+			t.Errorf("%s: expected the limit in the problem, got %+v", tt.path, problem)
+		}
+	}
+
+	// Malformed bodies within the limit are still a 400
+	if rr := post("/generate", `{"letter":`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest for a malformed body, got %v", rr.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+)
+
+// HealthResponse is the body of /healthz
+type HealthResponse struct {
+	Status string `json:"status"` // "ok", or "draining" while the server shuts down
+}
+
+// ReadyResponse is the body of /readyz
+type ReadyResponse struct {
+	Status string            `json:"status"` // "ready", "not ready", "maintenance" or "draining"
+	Checks map[string]string `json:"checks"` // Result of each dependency check, "ok" or the problem found
+}
+
+// isHealthPath reports whether a path is a health check, which is answered
+// even while the server drains
+func isHealthPath(path string) bool {
+	return path == "/healthz" || path == "/readyz"
+}
+
+// handleHealthz is the liveness check: it succeeds as long as the server runs,
+// and reports draining while it shuts down
+// Load balancers should stop sending requests once it answers 503
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, HealthResponse{Status: "draining"})
+		return
+	}
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// handleReadyz is the readiness check: it succeeds once the worker pools and the
+// cache can serve requests, and fails in maintenance mode and while the server drains
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	checks := s.readinessChecks()
+	response := ReadyResponse{Status: "ready", Checks: checks}
+	for _, result := range checks {
+		if result != "ok" {
+			response.Status = "not ready"
+		}
+	}
+	if s.maintenance.Load() != nil {
+		response.Status = "maintenance"
+	}
+	if s.draining.Load() {
+		response.Status = "draining"
+	}
+
+	if response.Status != "ready" {
+		s.requestLogger(r).Debug("Not ready", "status", response.Status, "checks", checks)
+		writeJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// readinessChecks checks the components requests depend on
+func (s *Server) readinessChecks() map[string]string {
+	checks := map[string]string{
+		"generator":  "ok",
+		"batch_pool": "ok",
+		"cache":      "ok",
+	}
+	if s.nameGenerator == nil || !s.nameGenerator.Running() {
+		checks["generator"] = "worker pool stopped"
+	}
+	if s.batchPool == nil || !s.batchPool.Running() {
+		checks["batch_pool"] = "worker pool stopped"
+	}
+
+	// External backends, such as Redis, must be reachable
+	if s.cache == nil {
+		checks["cache"] = "not initialized"
+	} else if pinger, ok := s.cache.(cache.Pinger); ok {
+		if err := pinger.Ping(); err != nil {
+			checks["cache"] = err.Error()
+		}
+	}
+	return checks
+}
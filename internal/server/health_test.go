@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// readyz sends a readiness check to the router
+func readyz(router http.Handler) (int, ReadyResponse) {
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var response ReadyResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	return rr.Code, response
+}
+
+func TestReadyz(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	router := server.createRouter()
+
+	// A new server is ready
+	code, response := readyz(router)
+	if code != http.StatusOK || response.Status != "ready" {
+		t.Errorf("Expected the server to be ready, got %d %+v", code, response)
+	}
+	for _, check := range []string{"generator", "batch_pool", "cache"} {
+		if response.Checks[check] != "ok" {
+			t.Errorf("Expected check %s to pass, got %q", check, response.Checks[check])
+		}
+	}
+
+	// It stops being ready as soon as it drains
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	code, response = readyz(router)
+	if code != http.StatusServiceUnavailable || response.Status != "draining" {
+		t.Errorf("Expected the server to be draining, got %d %+v", code, response)
+	}
+	if response.Checks["generator"] == "ok" || response.Checks["batch_pool"] == "ok" {
+		t.Errorf("Expected the stopped worker pools to fail their checks, got %v", response.Checks)
+	}
+}
+
+func TestReadyzUnreachableRedis(t *testing.T) {
+	// Find an address nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	options := DefaultServerOptions()
+	options.CacheBackend = "redis"
+	options.RedisAddr = addr
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	code, response := readyz(server.createRouter())
+	if code != http.StatusServiceUnavailable || response.Status != "not ready" {
+		t.Errorf("Expected the server not to be ready, got %d %+v", code, response)
+	}
+	if response.Checks["cache"] == "ok" {
+		t.Error("Expected the cache check to fail")
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/metrics"
+)
+
+// defaultHistoryWindow is how far back /stats/history and /stats/export go
+// without a window
+const defaultHistoryWindow = time.Hour
+
+// StatsHistoryResponse lists the metrics snapshots of a window, oldest first
+type StatsHistoryResponse struct {
+	Interval string                 `json:"interval"` // Time between two snapshots
+	Window   string                 `json:"window"`   // How far back the snapshots go, at most the retention
+	Count    int                    `json:"count"`
+	Points   []metrics.HistoryPoint `json:"points"`
+}
+
+// handleStatsHistory serves the metrics snapshots of the history
+//
+//	GET /stats/history                                   returns the last hour
+//	GET /stats/history?window=24h                        returns the last day
+//	GET /stats/history?metrics=requests_per_second_1m    returns one metric
+func (s *Server) handleStatsHistory(w http.ResponseWriter, r *http.Request) {
+	window, points, ok := s.historyPoints(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, StatsHistoryResponse{
+		Interval: s.history.Interval().String(),
+		Window:   window.String(),
+		Count:    len(points),
+		Points:   points,
+	})
+}
+
+// handleStatsExport serves the metrics snapshots of the history as CSV, a row
+// per snapshot and a column per metric, for spreadsheets
+//
+//	GET /stats/export                                   returns the last hour
+//	GET /stats/export?window=24h                        returns the last day
+//	GET /stats/export?metrics=requests_per_second_1m    returns one metric
+func (s *Server) handleStatsExport(w http.ResponseWriter, r *http.Request) {
+	_, points, ok := s.historyPoints(w, r)
+	if !ok {
+		return
+	}
+
+	// The columns are every metric of the window, as metrics may have been
+	// added along the way
+	seen := make(map[string]bool)
+	var names []string
+	for _, point := range points {
+		for name := range point.Values {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"metrics-%s.csv\"", time.Now().UTC().Format("20060102-150405")))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write(append([]string{"time"}, names...))
+	row := make([]string, len(names)+1)
+	for _, point := range points {
+		row[0] = point.Time.UTC().Format(time.RFC3339)
+		for i, name := range names {
+			row[i+1] = ""
+			if value, ok := point.Values[name]; ok {
+				row[i+1] = strconv.FormatFloat(value, 'f', -1, 64)
+			}
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		s.requestLogger(r).Warn("Error writing the metrics export", "error", err)
+	}
+}
+
+// historyPoints reads the window and the metrics a request asks for and
+// returns the matching snapshots of the history, or answers the request with
+// an error and returns false
+func (s *Server) historyPoints(w http.ResponseWriter, r *http.Request) (time.Duration, []metrics.HistoryPoint, bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return 0, nil, false
+	}
+	if s.history == nil {
+		writeProblem(w, r, http.StatusNotImplemented, "The metrics history is not kept (HistoryRetention is 0)")
+		return 0, nil, false
+	}
+
+	var invalid validationErrors
+	window := defaultHistoryWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			invalid.add("window", "window must be a positive duration, e.g. 1h")
+		}
+		window = d
+	}
+	if reqErr := invalid.err(); reqErr != nil {
+		reqErr.write(w, r)
+		return 0, nil, false
+	}
+	if retention := s.history.Retention(); window > retention {
+		window = retention
+	}
+
+	var names []string
+	for _, name := range strings.Split(r.URL.Query().Get("metrics"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return window, s.history.Points(time.Now().Add(-window), names...), true
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsHistory(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.HistoryInterval = time.Hour // Snapshots are recorded by the test
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	handler := server.createRouter()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/names/A", nil))
+	server.history.Record(time.Now().Add(-2 * time.Hour))
+	server.history.Record(time.Now().Add(-time.Minute))
+
+	get := func(path string) (*httptest.ResponseRecorder, StatsHistoryResponse) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		var response StatsHistoryResponse
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode %s: %v", path, err)
+			}
+		}
+		return rr, response
+	}
+
+	// The last hour is returned by default
+	rr, response := get("/stats/history")
+	if rr.Code != http.StatusOK || response.Interval != "1h0m0s" || response.Window != "1h0m0s" || response.Count != 1 {
+		t.Fatalf("Expected the last snapshot, got %d %+v", rr.Code, response)
+	}
+	if response.Points[0].Values["requests_total"] != 1 {
+		t.Errorf("Expected the request in the snapshot, got %v", response.Points[0].Values)
+	}
+
+	// A wider window, limited to some metrics
+	_, response = get("/stats/history?window=3h&metrics=requests_total,%20goroutines")
+	if response.Count != 2 || len(response.Points[1].Values) != 2 {
+		t.Errorf("Expected 2 snapshots with 2 metrics, got %+v", response)
+	}
+
+	// The window is capped at the retention
+	_, response = get("/stats/history?window=720h")
+	if response.Window != "24h0m0s" {
+		t.Errorf("Expected the window to be capped at 24h, got %s", response.Window)
+	}
+
+	for _, path := range []string{"/stats/history?window=soon", "/stats/history?window=-1h"} {
+		if rr, _ := get(path); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %d", path, rr.Code)
+		}
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/stats/history", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected POST to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestStatsHistoryDisabled(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.HistoryRetention = 0
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	for _, path := range []string{"/stats/history", "/stats/export"} {
+		rr := httptest.NewRecorder()
+		server.createRouter().ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusNotImplemented {
+			t.Errorf("Expected status %d for %s without a history, got %d", http.StatusNotImplemented, path, rr.Code)
+		}
+	}
+}
+
+func TestStatsExport(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.HistoryInterval = time.Hour // Snapshots are recorded by the test
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	handler := server.createRouter()
+	server.history.Record(time.Now().Add(-2 * time.Hour))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/names/A", nil))
+	server.history.Record(time.Now().Add(-time.Minute))
+
+	export := func(path string) (*httptest.ResponseRecorder, [][]string) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK {
+			return rr, nil
+		}
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to read the CSV of %s: %v", path, err)
+		}
+		return rr, records
+	}
+
+	// The last hour is exported as a download, a column per metric
+	rr, records := export("/stats/export")
+	if rr.Header().Get("Content-Type") != "text/csv; charset=utf-8" || !strings.HasPrefix(rr.Header().Get("Content-Disposition"), "attachment; filename=\"metrics-") {
+		t.Errorf("Expected a CSV download, got %s and %s", rr.Header().Get("Content-Type"), rr.Header().Get("Content-Disposition"))
+	}
+	if len(records) != 2 || records[0][0] != "time" {
+		t.Fatalf("Expected a header and a row, got %v", records)
+	}
+	for i, name := range records[0] {
+		if name == "requests_total" && records[1][i] != "1" {
+			t.Errorf("Expected the request in the export, got %s", records[1][i])
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, records[1][0]); err != nil {
+		t.Errorf("Expected the time in RFC 3339, got %s", records[1][0])
+	}
+
+	// A wider window, limited to some metrics
+	_, records = export("/stats/export?window=3h&metrics=requests_total,goroutines")
+	if len(records) != 3 || strings.Join(records[0], ",") != "time,goroutines,requests_total" || records[1][2] != "0" {
+		t.Errorf("Expected 2 rows with 2 metrics, got %v", records)
+	}
+
+	if rr, _ := export("/stats/export?window=soon"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid window to be rejected, got %d", rr.Code)
+	}
+}
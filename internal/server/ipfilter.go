@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// ipFilter decides by client address which requests are served
+// An address on the deny list is always rejected; if the allow list is not
+// empty, only addresses on it are served
+type ipFilter struct {
+	allow  []*net.IPNet
+	deny   []*net.IPNet
+	closed bool          // Set when the lists couldn't be parsed, to reject every request
+	denied atomic.Uint64 // Requests rejected so far
+}
+
+// newIPFilter parses the allow and deny lists, which hold CIDR ranges such as
+// "10.0.0.0/8" or single addresses
+func newIPFilter(allow, deny []string) (*ipFilter, error) {
+	f := &ipFilter{}
+	var err error
+	if f.allow, err = parseCIDRs(allow); err != nil {
+		return nil, fmt.Errorf("allow list: %w", err)
+	}
+	if f.deny, err = parseCIDRs(deny); err != nil {
+		return nil, fmt.Errorf("deny list: %w", err)
+	}
+	return f, nil
+}
+
+// parseCIDRs parses a list of CIDR ranges and addresses, skipping empty entries
+func parseCIDRs(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		// A single address is a range of one
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", entry)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q", entry)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// allowed reports whether requests from ip are served
+func (f *ipFilter) allowed(ip net.IP) bool {
+	if f.closed {
+		return false
+	}
+	if ip == nil {
+		return len(f.allow) == 0 && len(f.deny) == 0
+	}
+	if containsIP(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || containsIP(f.allow, ip)
+}
+
+// containsIP reports whether one of the ranges contains ip
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address a request was sent from
+// Forwarding headers are ignored, since clients can set them to anything
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// ipFilterMiddleware rejects requests from denied addresses with a 403
+// before they count against the rate limit
+func (s *Server) ipFilterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.ipFilter != nil && !s.ipFilter.allowed(clientIP(r)) {
+			s.ipFilter.denied.Add(1)
+			s.requestLogger(r).Warn("Rejected request from denied address", "remote_addr", r.RemoteAddr)
+			writeProblem(w, r, http.StatusForbidden, "Access from this address is not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
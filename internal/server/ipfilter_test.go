@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIPFilter(t *testing.T) {
+	filter, err := newIPFilter([]string{"10.0.0.0/8", "2001:db8::/32", "192.168.1.5"}, []string{"10.0.0.66", " "})
+	if err != nil {
+		t.Fatalf("Failed to parse the lists: %v", err)
+	}
+
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"10.1.2.3", true},
+		{"192.168.1.5", true},
+		{"2001:db8::1", true},
+		{"10.0.0.66", false}, // Denied even though it's in an allowed range
+		{"192.168.1.6", false},
+		{"8.8.8.8", false},
+	}
+	for _, tt := range tests {
+		if allowed := filter.allowed(net.ParseIP(tt.ip)); allowed != tt.allowed {
+			t.Errorf("%s: expected allowed=%v, got %v", tt.ip, tt.allowed, allowed)
+		}
+	}
+
+	// Without an allow list, everything not denied is served
+	denyOnly, _ := newIPFilter(nil, []string{"203.0.113.0/24"})
+	if !denyOnly.allowed(net.ParseIP("8.8.8.8")) || denyOnly.allowed(net.ParseIP("203.0.113.9")) {
+		t.Error("Expected only the denied range to be rejected")
+	}
+
+	// Invalid entries are reported
+	for _, entry := range []string{"10.0.0.0/33", "example.com", "10.0.0"} {
+		if _, err := newIPFilter([]string{entry}, nil); err == nil {
+			t.Errorf("%q: expected an error", entry)
+		}
+	}
+}
+
+func TestIPFilterMiddleware(t *testing.T) {
+	options := DefaultServerOptions()
+	options.IPDenyList = []string{"203.0.113.0/24"}
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/stats/data", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := request("198.51.100.7:4000"); rr.Code != http.StatusOK {
+		t.Errorf("Expected status OK for an allowed address, got %v", rr.Code)
+	}
+	rr := request("203.0.113.9:4000")
+	if rr.Code != http.StatusForbidden || rr.Header().Get("Content-Type") != problemContentType {
+		t.Errorf("Expected a 403 problem for a denied address, got %v %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	// Denied requests are counted
+	if denied := server.metrics.GetCurrentMetrics()["ip_denied"]; denied != uint64(1) {
+		t.Errorf("Expected 1 denied request, got %v", denied)
+	}
+}
+
+func TestIPFilterFailsClosed(t *testing.T) {
+	options := DefaultServerOptions()
+	options.IPAllowList = []string{"not-an-address"}
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	rr := httptest.NewRecorder()
+	server.createRouter().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status Forbidden with an invalid allow list, got %v", rr.Code)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd; 0-2 are stdio
+const listenFDsStart = 3
+
+// activatedListeners returns the sockets passed by systemd socket activation,
+// or nil when the server was not socket-activated, and the socket named admin
+// in LISTEN_FDNAMES, if any, for the admin server
+// The variables are unset so child processes don't pick the sockets up again
+func activatedListeners() ([]net.Listener, net.Listener, error) {
+	return listenersFromEnv(listenFDsStart)
+}
+
+// listenersFromEnv turns LISTEN_FDS descriptors, starting at firstFD, into listeners
+// They are only taken if LISTEN_PID names this process, as the variables may
+// have been inherited from a socket-activated parent, or if the parent handed
+// them over in an upgrade, which can't know the PID in advance
+func listenersFromEnv(firstFD int) ([]net.Listener, net.Listener, error) {
+	if pid := os.Getenv("LISTEN_PID"); pid != strconv.Itoa(os.Getpid()) && (pid != "" || upgradedFrom() == 0) {
+		return nil, nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	listeners := make([]net.Listener, 0, count)
+	var admin net.Listener
+	for fd := firstFD; fd < firstFD+count; fd++ {
+		// FileListener duplicates the descriptor, so the file is closed afterwards
+		file := os.NewFile(uintptr(fd), "systemd-socket-"+strconv.Itoa(fd))
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			if admin != nil {
+				admin.Close()
+			}
+			return nil, nil, fmt.Errorf("file descriptor %d is not a listening socket: %w", fd, err)
+		}
+		if i := fd - firstFD; i < len(names) && names[i] == adminListenerName && admin == nil {
+			admin = listener
+		} else {
+			listeners = append(listeners, listener)
+		}
+	}
+	return listeners, admin, nil
+}
@@ -0,0 +1,149 @@
+//go:build unix
+
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// passListener duplicates the descriptor of a listening socket, as systemd
+// would pass it, and returns the descriptor number
+func passListener(t *testing.T) (int, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	file, err := listener.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	// The raw descriptor belongs to listenersFromEnv, which closes it
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd, listener.Addr().String()
+}
+
+func TestListenersFromEnv(t *testing.T) {
+	fd, address := passListener(t)
+
+	// Variables meant for another process are ignored
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	if listeners, _, err := listenersFromEnv(fd); err != nil || listeners != nil {
+		t.Fatalf("Expected no listeners for another PID, got %v, %v", listeners, err)
+	}
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	listeners, _, err := listenersFromEnv(fd)
+	if err != nil {
+		t.Fatalf("Error taking the passed socket: %v", err)
+	}
+	if len(listeners) != 1 || listeners[0].Addr().String() != address {
+		t.Fatalf("Expected a listener on %s, got %v", address, listeners)
+	}
+	defer listeners[0].Close()
+
+	// The variables are consumed
+	if os.Getenv("LISTEN_FDS") != "" || os.Getenv("LISTEN_PID") != "" {
+		t.Error("Expected LISTEN_FDS and LISTEN_PID to be unset")
+	}
+}
+
+func TestListenersFromEnvInvalid(t *testing.T) {
+	// A descriptor that isn't a socket is an error
+	file, err := os.CreateTemp(t.TempDir(), "notasocket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	if _, _, err := listenersFromEnv(fd); err == nil {
+		t.Error("Expected an error for a descriptor that isn't a socket")
+	}
+
+	// Without the variables the server listens by itself
+	t.Setenv("LISTEN_FDS", "")
+	if listeners, _, err := activatedListeners(); err != nil || listeners != nil {
+		t.Errorf("Expected no listeners, got %v, %v", listeners, err)
+	}
+}
+
+func TestStartWithActivatedSocket(t *testing.T) {
+	fd, address := passListener(t)
+
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+
+	// The passed descriptor is taken directly, as Start would from fd 3
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	listeners, _, err := listenersFromEnv(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- server.serve(listeners[0]) }()
+
+	resp, err := http.Get("http://" + address + "/healthz")
+	if err != nil {
+		t.Fatalf("Error requesting the activated socket: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status OK, got %v", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != http.ErrServerClosed {
+		t.Errorf("Expected ErrServerClosed, got %v", err)
+	}
+}
+
+func TestListenersFromEnvAdmin(t *testing.T) {
+	fd, address := passListener(t)
+	adminFD, adminAddress := passListener(t)
+	if adminFD != fd+1 {
+		t.Skipf("Descriptors %d and %d aren't consecutive", fd, adminFD)
+	}
+
+	// The socket named admin is kept apart for the admin server
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "2")
+	t.Setenv("LISTEN_FDNAMES", "http:admin")
+	listeners, admin, err := listenersFromEnv(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	defer listeners[0].Close()
+	if len(listeners) != 1 || listeners[0].Addr().String() != address {
+		t.Errorf("Expected one listener on %s, got %v", address, listeners)
+	}
+	if admin == nil || admin.Addr().String() != adminAddress {
+		t.Errorf("Expected the admin listener on %s, got %v", adminAddress, admin)
+	}
+}
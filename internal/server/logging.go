@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// requestIDHeader carries the ID of a request, set by the client or generated by the server
+const requestIDHeader = "X-Request-ID"
+
+// newLogger creates the JSON logger of the server
+// The level is held in a LevelVar so it can be changed while the server runs
+func newLogger(options ServerOptions) (*slog.Logger, *slog.LevelVar) {
+	var output io.Writer = os.Stderr
+	if options.LogOutput != nil {
+		output = options.LogOutput
+	}
+	level := new(slog.LevelVar)
+	logger := slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{Level: level}))
+
+	if options.LogLevel != "" {
+		parsed, err := parseLogLevel(options.LogLevel)
+		if err != nil {
+			logger.Warn("Unknown log level, using info", "level", options.LogLevel)
+		}
+		level.Set(parsed)
+	}
+	return logger, level
+}
+
+// parseLogLevel parses a level name such as "debug", "info", "warn" or "error"
+func parseLogLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(strings.TrimSpace(name)))
+	return level, err
+}
+
+// Logger returns the logger of the server, e.g. to make it the default logger
+func (s *Server) Logger() *slog.Logger {
+	return s.logger
+}
+
+// requestLog is the logger of a request, shared by the middleware and the handlers
+// Handlers add fields as they learn them, e.g. the session ID once the body is
+// decoded, and the access log line written at the end of the request carries them
+type requestLog struct {
+	mutex  sync.Mutex
+	base   *slog.Logger
+	fields []interface{} // Key-value pairs describing the request
+}
+
+// requestLogKey is the context key of the requestLog
+type requestLogKey struct{}
+
+// withRequestLog returns a context carrying a logger for the request
+func withRequestLog(ctx context.Context, base *slog.Logger, fields ...interface{}) (context.Context, *requestLog) {
+	log := &requestLog{base: base, fields: fields}
+	return context.WithValue(ctx, requestLogKey{}, log), log
+}
+
+// get returns the logger with the fields added so far
+func (l *requestLog) get() *slog.Logger {
+	return l.base.With(l.snapshot()...)
+}
+
+// snapshot returns a copy of the fields added so far
+func (l *requestLog) snapshot() []interface{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]interface{}(nil), l.fields...)
+}
+
+// requestLogger returns the logger of a request, or the server logger outside of requests
+func (s *Server) requestLogger(r *http.Request) *slog.Logger {
+	if log, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+		return log.get()
+	}
+	return s.logger
+}
+
+// addLogFields adds key-value pairs to the logger of a request
+func addLogFields(r *http.Request, args ...interface{}) {
+	if log, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+		log.mutex.Lock()
+		log.fields = append(log.fields, args...)
+		log.mutex.Unlock()
+	}
+}
+
+// requestID returns the ID the client sent with the request, if it is usable,
+// or a new random ID
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); id != "" && len(id) <= 64 && isPrintableASCII(id) {
+		return id
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// isPrintableASCII reports whether s only contains printable ASCII characters,
+// so it can be echoed in a header and logged as is
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x21 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
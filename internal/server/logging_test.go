@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logBuffer collects the log lines of a server
+type logBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+// entries returns the decoded log lines with the given message
+func (b *logBuffer) entries(t *testing.T, msg string) []map[string]interface{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line %q is not JSON: %v", line, err)
+		}
+		if entry["msg"] == msg {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestRequestLogging(t *testing.T) {
+	logs := &logBuffer{}
+	options := DefaultServerOptions()
+	options.LogOutput = logs
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	// Requests are logged with their ID, session, method, path, status and latency
+	req := httptest.NewRequest(http.MethodPost, "/generate", strings.NewReader(`{"session_id":"abc-123","letter":"A"}`))
+	req.Header.Set(requestIDHeader, "req-1")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Header().Get(requestIDHeader) != "req-1" {
+		t.Errorf("Expected the request ID to be echoed, got %q", rr.Header().Get(requestIDHeader))
+	}
+
+	entries := logs.entries(t, "Request completed")
+	if len(entries) != 1 {
+		t.Fatalf("Expected one access log line, got %d", len(entries))
+	}
+	entry := entries[0]
+	expected := map[string]interface{}{
+		"level":      "INFO",
+		"request_id": "req-1",
+		"session_id": "abc-123",
+		"method":     http.MethodPost,
+		"path":       "/generate",
+		"status":     float64(http.StatusOK),
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("Expected %s to be %v, got %v", key, value, entry[key])
+		}
+	}
+	if _, ok := entry["latency_ms"].(float64); !ok {
+		t.Errorf("Expected a latency, got %v", entry["latency_ms"])
+	}
+
+	// Requests without a usable ID get a new one
+	req = httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	req.Header.Set(requestIDHeader, "bad id\n")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if id := rr.Header().Get(requestIDHeader); id == "" || id == "bad id\n" {
+		t.Errorf("Expected a generated request ID, got %q", id)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	logs := &logBuffer{}
+	options := DefaultServerOptions()
+	options.LogOutput = logs
+	options.LogLevel = "warn"
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	// Requests are logged at info level, below the configured level
+	rr := httptest.NewRecorder()
+	server.createRouter().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if entries := logs.entries(t, "Request completed"); len(entries) != 0 {
+		t.Errorf("Expected info logs to be dropped, got %v", entries)
+	}
+	server.requestLogger(httptest.NewRequest(http.MethodGet, "/", nil)).Warn("Something odd")
+	if entries := logs.entries(t, "Something odd"); len(entries) != 1 {
+		t.Errorf("Expected warnings to be logged, got %v", entries)
+	}
+
+	// The level can be changed while the server runs
+	level, err := parseLogLevel("debug")
+	if err != nil {
+		t.Fatalf("Error parsing level: %v", err)
+	}
+	server.logLevel.Set(level)
+	server.logger.Debug("Details")
+	if entries := logs.entries(t, "Details"); len(entries) != 1 {
+		t.Errorf("Expected debug logs after lowering the level, got %v", entries)
+	}
+
+	if _, err := parseLogLevel("verbose"); err == nil {
+		t.Error("Expected an unknown level to be rejected")
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// LogLevelRequest changes the log level
+type LogLevelRequest struct {
+	Level string `json:"level"` // "debug", "info", "warn" or "error"
+}
+
+// LogLevelResponse reports the active log level
+type LogLevelResponse struct {
+	Level string `json:"level"`
+}
+
+// handleAdminLogLevel handles requests to inspect and change the log level at runtime
+//
+//	GET /admin/loglevel  returns the active level
+//	PUT /admin/loglevel  sets the level given in a LogLevelRequest
+func (s *Server) handleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, LogLevelResponse{Level: strings.ToLower(s.logLevel.Level().String())})
+
+	case http.MethodPut:
+		var request LogLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Level == "" {
+			writeProblem(w, r, http.StatusBadRequest, "Request body must give the level, e.g. {\"level\":\"debug\"}")
+			return
+		}
+		level, err := parseLogLevel(request.Level)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "Level must be debug, info, warn or error")
+			return
+		}
+
+		previous := s.logLevel.Level()
+		s.logLevel.Set(level)
+		// Logged at warn, so the change shows up whatever the new level is
+		s.requestLogger(r).Warn("Log level changed", "from", previous.String(), "to", level.String(), "remote_addr", r.RemoteAddr)
+		s.auditAction(r, "loglevel.change", "", map[string]string{"from": previous.String(), "to": level.String()})
+		writeJSON(w, http.StatusOK, LogLevelResponse{Level: strings.ToLower(level.String())})
+
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleAdminLogLevel(t *testing.T) {
+	logs := &logBuffer{}
+	options := DefaultServerOptions()
+	options.AdminToken = "secret"
+	options.LogOutput = logs
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	request := func(method, body, token string) (*httptest.ResponseRecorder, LogLevelResponse) {
+		req := httptest.NewRequest(method, "/admin/loglevel", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response LogLevelResponse
+		if rr.Code == http.StatusOK {
+			json.NewDecoder(rr.Body).Decode(&response)
+		}
+		return rr, response
+	}
+
+	// The level starts at info
+	if _, response := request(http.MethodGet, "", "secret"); response.Level != "info" {
+		t.Errorf("Expected level info, got %q", response.Level)
+	}
+
+	// Debug logging can be turned on
+	if rr, response := request(http.MethodPut, `{"level":"DEBUG"}`, "secret"); rr.Code != http.StatusOK || response.Level != "debug" {
+		t.Errorf("Expected level debug, got %d %q", rr.Code, response.Level)
+	}
+	if _, response := request(http.MethodGet, "", "secret"); response.Level != "debug" {
+		t.Errorf("Expected level debug after the change, got %q", response.Level)
+	}
+	server.logger.Debug("Debug details")
+	if entries := logs.entries(t, "Debug details"); len(entries) != 1 {
+		t.Error("Expected debug logs to be written")
+	}
+	if entries := logs.entries(t, "Log level changed"); len(entries) != 1 || entries[0]["to"] != "DEBUG" {
+		t.Errorf("Expected the change to be logged, got %v", entries)
+	}
+
+	// Invalid levels, methods and tokens are rejected
+	if rr, _ := request(http.MethodPut, `{"level":"loud"}`, "secret"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest for an unknown level, got %v", rr.Code)
+	}
+	if rr, _ := request(http.MethodPut, `{}`, "secret"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest without a level, got %v", rr.Code)
+	}
+	if rr, _ := request(http.MethodPost, `{"level":"info"}`, "secret"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status MethodNotAllowed, got %v", rr.Code)
+	}
+	if rr, _ := request(http.MethodPut, `{"level":"info"}`, "wrong"); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status Unauthorized, got %v", rr.Code)
+	}
+	if _, response := request(http.MethodGet, "", "secret"); response.Level != "debug" {
+		t.Errorf("Expected rejected changes to keep the level, got %q", response.Level)
+	}
+}
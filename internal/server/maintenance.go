@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// defaultMaintenanceMessage is shown to API clients in maintenance mode when
+// no message is given
+const defaultMaintenanceMessage = "Server is under maintenance, please try again later"
+
+// MaintenanceRequest turns maintenance mode on or off
+type MaintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"` // Shown to API clients; a default one if empty
+}
+
+// MaintenanceResponse reports whether maintenance mode is on
+type MaintenanceResponse struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+// isAPIPath reports whether a path is part of the public API, which is turned
+// away in maintenance mode; the dashboard, the admin API, the documentation
+// and the health checks stay up
+func isAPIPath(path string) bool {
+	return path == "/generate" || path == "/generate/batch" ||
+		strings.HasPrefix(path, "/names/") || strings.HasPrefix(path, "/sessions/")
+}
+
+// maintenanceMiddleware rejects API requests while maintenance mode is on
+func (s *Server) maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if message := s.maintenance.Load(); message != nil && isAPIPath(r.URL.Path) {
+			w.Header().Set("Retry-After", "60")
+			writeProblem(w, r, http.StatusServiceUnavailable, *message)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maintenanceStatus returns whether maintenance mode is on, and its message
+func (s *Server) maintenanceStatus() MaintenanceResponse {
+	if message := s.maintenance.Load(); message != nil {
+		return MaintenanceResponse{Enabled: true, Message: *message}
+	}
+	return MaintenanceResponse{}
+}
+
+// handleAdminMaintenance handles requests to inspect and toggle maintenance mode
+// While it is on, the API answers 503 and /readyz reports maintenance, so load
+// balancers send the traffic to the other instances
+//
+//	GET /admin/maintenance  returns whether maintenance mode is on
+//	PUT /admin/maintenance  turns it on or off as given in a MaintenanceRequest
+func (s *Server) handleAdminMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.maintenanceStatus())
+
+	case http.MethodPut:
+		var request MaintenanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "Request body must say whether to enable it, e.g. {\"enabled\":true}")
+			return
+		}
+
+		if request.Enabled {
+			message := strings.TrimSpace(request.Message)
+			if message == "" {
+				message = defaultMaintenanceMessage
+			}
+			s.maintenance.Store(&message)
+			s.requestLogger(r).Warn("Maintenance mode enabled", "message", message, "remote_addr", r.RemoteAddr)
+			s.auditAction(r, "maintenance.enable", "", map[string]string{"message": message})
+		} else {
+			s.maintenance.Store(nil)
+			s.requestLogger(r).Warn("Maintenance mode disabled", "remote_addr", r.RemoteAddr)
+			s.auditAction(r, "maintenance.disable", "", nil)
+		}
+		writeJSON(w, http.StatusOK, s.maintenanceStatus())
+
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	options := DefaultServerOptions()
+	options.AdminToken = "secret"
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	toggle := func(body string) MaintenanceResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPut, "/admin/maintenance", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status OK for %s, got %d %s", body, rr.Code, rr.Body.String())
+		}
+		var response MaintenanceResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return response
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	// The API is turned away with the message, the operational endpoints stay up
+	if response := toggle(`{"enabled":true,"message":"Back at noon"}`); !response.Enabled || response.Message != "Back at noon" {
+		t.Errorf("Expected maintenance mode on with its message, got %+v", response)
+	}
+	for _, path := range []string{"/names/A", "/sessions/abc"} {
+		rr := get(path)
+		if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" || !strings.Contains(rr.Body.String(), "Back at noon") {
+			t.Errorf("Expected %s to be unavailable with the message, got %d %s", path, rr.Code, rr.Body.String())
+		}
+	}
+	for _, path := range []string{"/healthz", "/stats", "/openapi.json"} {
+		if rr := get(path); rr.Code != http.StatusOK {
+			t.Errorf("Expected %s to be served in maintenance mode, got %d", path, rr.Code)
+		}
+	}
+	var ready ReadyResponse
+	rr := get("/readyz")
+	json.NewDecoder(rr.Body).Decode(&ready)
+	if rr.Code != http.StatusServiceUnavailable || ready.Status != "maintenance" {
+		t.Errorf("Expected /readyz to report maintenance, got %d %+v", rr.Code, ready)
+	}
+
+	// A default message is used without one, and turning it off restores the API
+	if response := toggle(`{"enabled":true}`); response.Message != defaultMaintenanceMessage {
+		t.Errorf("Expected the default message, got %q", response.Message)
+	}
+	if response := toggle(`{"enabled":false}`); response.Enabled {
+		t.Errorf("Expected maintenance mode off, got %+v", response)
+	}
+	if rr := get("/names/A"); rr.Code != http.StatusOK {
+		t.Errorf("Expected the API to be back, got %d", rr.Code)
+	}
+	if rr := get("/readyz"); rr.Code != http.StatusOK {
+		t.Errorf("Expected /readyz to be ready again, got %d", rr.Code)
+	}
+
+	entries := server.auditLog.Recent(0)
+	if len(entries) != 3 || entries[0].Action != "maintenance.disable" || entries[2].Details["message"] != "Back at noon" {
+		t.Errorf("Expected the toggles in the audit log, got %+v", entries)
+	}
+}
+
+func TestMaintenanceAuth(t *testing.T) {
+	options := DefaultServerOptions()
+	options.AdminToken = "secret"
+	options.ReaderToken = "reader"
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	for token, expected := range map[string]int{"": http.StatusUnauthorized, "reader": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodPut, "/admin/maintenance", strings.NewReader(`{"enabled":true}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != expected {
+			t.Errorf("Expected status %d for token %q, got %d", expected, token, rr.Code)
+		}
+	}
+	if server.maintenance.Load() != nil {
+		t.Error("Expected maintenance mode to stay off")
+	}
+
+	// The dashboard shows the controls when the admin API is enabled
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if !strings.Contains(rr.Body.String(), `id="admin-controls"`) || !strings.Contains(rr.Body.String(), `value="2000"`) {
+		t.Error("Expected the dashboard to show the admin controls with the rate limit")
+	}
+	plain := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		plain.Shutdown(ctx)
+	}()
+	rr = httptest.NewRecorder()
+	plain.createRouter().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if strings.Contains(rr.Body.String(), `id="admin-controls"`) {
+		t.Error("Expected no admin controls without admin credentials")
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import "net/http"
+
+// Middleware wraps a handler with behaviour that runs around it, e.g.
+// resolving the tenant of a request before the route handler sees it
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middlewares into one; the first is the outermost, so it sees
+// the request first and the response last
+func Chain(middlewares ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+// Use adds middlewares to the server, in order, and must be called before Start
+// They run inside the built-in middlewares: requests reaching them have been
+// logged, authenticated and rate limited, and the claims of a JWT are on the
+// context (see ClaimsFromContext). The route deadline starts after them
+func (s *Server) Use(middlewares ...Middleware) {
+	s.middlewares = append(s.middlewares, middlewares...)
+	s.httpServer.Handler = s.createRouter()
+}
+
+// middlewareChain returns the middlewares every request passes through, outermost first
+func (s *Server) middlewareChain() []Middleware {
+	chain := []Middleware{
+		s.metricsMiddleware,
+		s.loggingMiddleware,
+		s.recoveryMiddleware,
+		s.drainMiddleware,
+		s.maintenanceMiddleware,
+		s.ipFilterMiddleware,
+		s.authMiddleware,
+		s.rateLimitMiddleware,
+	}
+	chain = append(chain, s.middlewares...)
+	return append(chain, s.timeoutMiddleware)
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingMiddleware appends its name to trace when a request passes in and out
+func recordingMiddleware(name string, trace *[]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*trace = append(*trace, name+">")
+			next.ServeHTTP(w, r)
+			*trace = append(*trace, "<"+name)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	var trace []string
+	handler := Chain(
+		recordingMiddleware("a", &trace),
+		recordingMiddleware("b", &trace),
+		recordingMiddleware("c", &trace),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := strings.Join(trace, " "); got != "a> b> c> handler <c <b <a" {
+		t.Errorf("Expected the first middleware to be the outermost, got %s", got)
+	}
+
+	// An empty chain leaves the handler as is
+	called := false
+	Chain()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !called {
+		t.Error("Expected an empty chain to call the handler")
+	}
+}
+
+func TestUse(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.AuthMode = AuthJWT
+	options.JWTSecret = "secret"
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	// A tenant middleware that relies on the claims of the authenticated token
+	var trace []string
+	var tenant string
+	server.Use(
+		recordingMiddleware("first", &trace),
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				claims, ok := ClaimsFromContext(r.Context())
+				if !ok || claims.String("tenant") == "" {
+					writeProblem(w, r, http.StatusForbidden, "No tenant")
+					return
+				}
+				tenant = claims.String("tenant")
+
+				// The route deadline only starts inside the embedder's middlewares
+				if _, ok := r.Context().Deadline(); ok {
+					t.Error("Expected no deadline before the route handler")
+				}
+				next.ServeHTTP(w, r)
+			})
+		},
+	)
+	server.Use(recordingMiddleware("second", &trace))
+	handler := server.httpServer.Handler
+
+	request := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/names/A", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Unauthenticated requests are turned away before the added middlewares
+	if rr := request(""); rr.Code != http.StatusUnauthorized || len(trace) != 0 {
+		t.Errorf("Expected 401 without reaching the middlewares, got %v with %v", rr.Code, trace)
+	}
+
+	token := signHS256(t, "secret", map[string]interface{}{"sub": "alice", "tenant": "acme"})
+	if rr := request(token); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v: %s", rr.Code, rr.Body)
+	}
+	if tenant != "acme" {
+		t.Errorf("Expected tenant acme, got %q", tenant)
+	}
+	if got := strings.Join(trace, " "); got != "first> second> <second <first" {
+		t.Errorf("Expected the middlewares to run in the order they were added, got %s", got)
+	}
+
+	// A middleware can answer the request itself
+	trace = nil
+	noTenant := signHS256(t, "secret", map[string]interface{}{"sub": "bob"})
+	if rr := request(noTenant); rr.Code != http.StatusForbidden || strings.Contains(strings.Join(trace, " "), "second") {
+		t.Errorf("Expected 403 before the second middleware, got %v with %v", rr.Code, trace)
+	}
+}
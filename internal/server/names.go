@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// handleNames is the REST-style form of /generate: GET /names/{letter}?count=10
+// returns count names starting with the letter
+// The optional query parameters locale, unique, cursor and session_id mean the
+// same as the fields of a generate request, and the format is negotiated the same way
+func (s *Server) handleNames(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	payload := RequestPayload{
+		SessionID: query.Get("session_id"),
+		Letter:    pathParam(r, "letter"),
+		Locale:    query.Get("locale"),
+		Cursor:    query.Get("cursor"),
+	}
+
+	// Requests without a session are tracked under their request ID
+	if payload.SessionID == "" {
+		payload.SessionID = w.Header().Get(requestIDHeader)
+	}
+	if payload.SessionID == "" {
+		payload.SessionID = requestID(r)
+	}
+
+	var invalid validationErrors
+	if count := query.Get("count"); count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 || n > s.options.MaxEntries {
+			invalid.add("count", fmt.Sprintf("count must be between 1 and %d", s.options.MaxEntries))
+		}
+		payload.NumOfEntries = n
+	}
+	if unique := query.Get("unique"); unique != "" {
+		value, err := strconv.ParseBool(unique)
+		if err != nil {
+			invalid.add("unique", "unique must be true or false")
+		}
+		payload.Unique = value
+	}
+	if reqErr := invalid.err(); reqErr != nil {
+		reqErr.write(w, r)
+		return
+	}
+
+	addLogFields(r, "session_id", payload.SessionID)
+	s.respondNames(w, r, payload)
+}
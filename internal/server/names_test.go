@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleNames(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/names/M?count=10&session_id=s1&unique=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v: %s", rr.Code, rr.Body)
+	}
+	var response ResponsePayload
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if response.SessionID != "s1" || len(response.Names) != 10 {
+		t.Errorf("Expected 10 names for session s1, got %+v", response)
+	}
+	seen := map[string]bool{}
+	for _, name := range response.Names {
+		if !strings.HasPrefix(name, "M") || seen[name] {
+			t.Errorf("Expected unique names starting with M, got %v", response.Names)
+			break
+		}
+		seen[name] = true
+	}
+
+	// Without a session the request ID is used, and the count defaults to 1
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/names/a?locale=de", nil))
+	response = ResponsePayload{}
+	json.NewDecoder(rr.Body).Decode(&response)
+	if rr.Code != http.StatusOK || len(response.Names) != 1 || response.Locale != "de" {
+		t.Errorf("Expected one German name, got %v: %+v", rr.Code, response)
+	}
+	if response.SessionID == "" || response.SessionID != rr.Header().Get(requestIDHeader) {
+		t.Errorf("Expected the request ID %q as session, got %q", rr.Header().Get(requestIDHeader), response.SessionID)
+	}
+
+	// The format is negotiated like for /generate
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/names/B?count=2&format=csv", nil))
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Errorf("Expected a CSV response, got %v with %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestHandleNamesErrors(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		field  string
+	}{
+		{"count not a number", http.MethodGet, "/names/A?count=ten", http.StatusBadRequest, "count"},
+		{"count too large", http.MethodGet, "/names/A?count=100000", http.StatusBadRequest, "count"},
+		{"count zero", http.MethodGet, "/names/A?count=0", http.StatusBadRequest, "count"},
+		{"invalid unique", http.MethodGet, "/names/A?unique=maybe", http.StatusBadRequest, "unique"},
+		{"not a letter", http.MethodGet, "/names/1", http.StatusBadRequest, "letter"},
+		{"missing letter", http.MethodGet, "/names/", http.StatusNotFound, ""},
+		{"wrong method", http.MethodPost, "/names/A", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %d, got %v: %s", tt.status, rr.Code, rr.Body)
+			}
+			if tt.field == "" {
+				return
+			}
+			var problem Problem
+			json.NewDecoder(rr.Body).Decode(&problem)
+			if len(problem.Errors) == 0 || problem.Errors[0].Field != tt.field {
+				t.Errorf("Expected an error for field %s, got %+v", tt.field, problem)
+			}
+		})
+	}
+}
@@ -0,0 +1,570 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/namespb"
+	"github.com/amirahmetzanov/go_project/internal/session"
+)
+
+// schema is a JSON object of the OpenAPI document
+type schema map[string]interface{}
+
+// schemaBuilder derives OpenAPI schemas from the payload structs, collecting
+// every struct it meets as a named component
+type schemaBuilder struct {
+	components schema
+}
+
+// fieldSchemas replaces the derived schema of fields whose JSON form is not
+// their Go type, keyed by struct and JSON name
+var fieldSchemas = map[string]schema{
+	"RequestPayload.letter": {
+		"oneOf": []interface{}{
+			schema{"type": "string", "description": "Letter the names start with"},
+			schema{"type": "array", "items": ref("LetterSpec"), "maxItems": maxLettersPerRequest, "description": "Letters to group names by"},
+		},
+	},
+	"ResponsePayload.locale": {"type": "string", "description": "Locale of the dataset the names were taken from"},
+}
+
+// stringForms lists the structs that also accept a plain string in place of an object
+var stringForms = map[string]bool{
+	"LetterSpec": true,
+}
+
+// ref points to a component schema
+func ref(name string) schema {
+	return schema{"$ref": "#/components/schemas/" + name}
+}
+
+// of returns the schema of a type, registering the structs it uses as components
+func (b *schemaBuilder) of(t reflect.Type) schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return schema{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		if _, done := b.components[t.Name()]; !done {
+			b.components[t.Name()] = schema{} // Guards against recursive types
+			b.components[t.Name()] = b.object(t)
+		}
+		return ref(t.Name())
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return schema{"type": "array", "items": b.of(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return schema{"type": "object", "additionalProperties": b.of(t.Elem())}
+	case t.Kind() == reflect.String:
+		return schema{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return schema{"type": "boolean"}
+	case t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64:
+		return schema{"type": "integer", "format": "int64"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint32:
+		return schema{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return schema{"type": "number"}
+	}
+	// interface{} and other types can hold any value
+	return schema{}
+}
+
+// object returns the schema of a struct from its exported, JSON-encoded fields
+// Fields without omitempty are always present, so they are listed as required
+func (b *schemaBuilder) object(t reflect.Type) schema {
+	properties := schema{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		if override, ok := fieldSchemas[t.Name()+"."+name]; ok {
+			properties[name] = override
+		} else {
+			properties[name] = b.of(field.Type)
+		}
+		if options != "omitempty" {
+			required = append(required, name)
+		}
+	}
+
+	object := schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	if stringForms[t.Name()] {
+		return schema{"oneOf": []interface{}{schema{"type": "string"}, object}}
+	}
+	return object
+}
+
+// content describes a body with the same schema in each of the media types
+func content(s schema, mediaTypes ...string) schema {
+	c := schema{}
+	for _, mediaType := range mediaTypes {
+		c[mediaType] = schema{"schema": s}
+	}
+	return c
+}
+
+// errorResponse describes an error, which is sent as an RFC 7807 problem
+func errorResponse(description string) schema {
+	return schema{"description": description, "content": content(ref("Problem"), problemContentType)}
+}
+
+// jsonResponse describes a successful JSON response
+func jsonResponse(description string, s schema) schema {
+	return schema{"description": description, "content": content(s, "application/json")}
+}
+
+// adminOperation describes an operation of the admin API
+// Reading needs the reader role and changes need the admin role
+func adminOperation(summary string, responses schema) schema {
+	responses["401"] = errorResponse("Missing or wrong token")
+	responses["403"] = errorResponse("Admin API is disabled, or the token lacks the role")
+	return schema{
+		"summary":   summary,
+		"tags":      []string{"admin"},
+		"security":  []interface{}{schema{"bearerAuth": []string{}}},
+		"responses": responses,
+	}
+}
+
+// openAPIDocument describes the HTTP API as an OpenAPI 3 document
+func (s *Server) openAPIDocument() schema {
+	b := &schemaBuilder{components: schema{}}
+	request := b.of(reflect.TypeOf(RequestPayload{}))
+	response := b.of(reflect.TypeOf(ResponsePayload{}))
+	b.of(reflect.TypeOf(LetterSpec{})) // Only referenced by the letter array
+	b.of(reflect.TypeOf(Problem{}))    // Referenced by every error response
+	protobuf := func(message string) schema {
+		return schema{"type": "string", "format": "binary", "description": message + " message of names.proto"}
+	}
+
+	// Bounds enforced by the handlers
+	if object, ok := b.components["RequestPayload"].(schema); ok {
+		properties := object["properties"].(schema)
+		properties["num_of_entries"] = schema{"type": "integer", "minimum": 1, "maximum": s.options.MaxEntries, "default": 1}
+		properties["prefix"] = schema{"type": "string", "maxLength": maxPrefixLength, "description": "Longer start of the names; overrides letter"}
+	}
+
+	generate := schema{
+		"summary": "Generate names starting with a letter or prefix",
+		"tags":    []string{"names"},
+		"parameters": []interface{}{schema{
+			"name":        "format",
+			"in":          "query",
+			"description": "Response format; takes precedence over the Accept header",
+			"schema":      schema{"type": "string", "enum": []string{"json", "csv", "xml", "protobuf", "ndjson"}},
+		}},
+		"requestBody": schema{
+			"required": true,
+			"content": schema{
+				"application/json":  schema{"schema": request},
+				namespb.ContentType: schema{"schema": protobuf("GenerateRequest")},
+			},
+		},
+		"responses": schema{
+			"200": schema{
+				"description": "Generated names",
+				"content": schema{
+					"application/json":  schema{"schema": response},
+					"application/xml":   schema{"schema": response},
+					"text/csv":          schema{"schema": schema{"type": "string"}},
+					namespb.ContentType: schema{"schema": protobuf("GenerateResponse")},
+					ndjsonContentType:   schema{"schema": b.of(reflect.TypeOf(NameLine{}))},
+				},
+			},
+			"400": errorResponse("Invalid request, or no names match the letter"),
+			"405": errorResponse("Method not allowed"),
+			"406": errorResponse("None of the accepted formats is supported"),
+			"408": errorResponse("The names weren't generated in time"),
+			"413": errorResponse("Request body too large"),
+			"429": errorResponse("Rate limit exceeded"),
+			"503": errorResponse("Server is overloaded or the request timed out"),
+		},
+	}
+
+	// The GET form answers like POST /generate, but has no body to be too large
+	namesResponses := schema{}
+	for code, response := range generate["responses"].(schema) {
+		if code != "413" {
+			namesResponses[code] = response
+		}
+	}
+	names := schema{
+		"summary":     "Get names starting with a letter",
+		"description": "REST-style form of POST /generate; without session_id the request ID is used",
+		"tags":        []string{"names"},
+		"parameters": []interface{}{
+			schema{"name": "letter", "in": "path", "required": true, "schema": schema{"type": "string", "minLength": 1}},
+			schema{"name": "count", "in": "query", "schema": schema{"type": "integer", "minimum": 1, "maximum": s.options.MaxEntries, "default": 1}},
+			schema{"name": "locale", "in": "query", "schema": schema{"type": "string", "default": "en"}},
+			schema{"name": "unique", "in": "query", "schema": schema{"type": "boolean"}},
+			schema{"name": "cursor", "in": "query", "schema": schema{"type": "string"}},
+			schema{"name": "session_id", "in": "query", "schema": schema{"type": "string"}},
+			generate["parameters"].([]interface{})[0],
+		},
+		"responses": namesResponses,
+	}
+
+	datasetStats := schema{
+		"summary":     "List the locales and letters names are generated for",
+		"description": "Names per letter of each locale, with a version that changes whenever the names of the locale do",
+		"tags":        []string{"names"},
+		"responses": schema{
+			"200": jsonResponse("Loaded datasets", b.of(reflect.TypeOf(DatasetStatsResponse{}))),
+		},
+	}
+
+	batch := schema{
+		"summary":     "Generate names for several requests at once",
+		"description": "Each request succeeds or fails on its own, with a result per request in order",
+		"tags":        []string{"names"},
+		"requestBody": schema{
+			"required": true,
+			"content":  content(schema{"type": "array", "items": request, "minItems": 1, "maxItems": maxBatchSize}, "application/json"),
+		},
+		"responses": schema{
+			"200": jsonResponse("A result per request", schema{"type": "array", "items": b.of(reflect.TypeOf(BatchResult{}))}),
+			"400": errorResponse("Body is not an array of 1-100 requests"),
+			"405": errorResponse("Method not allowed"),
+			"413": errorResponse("Request body too large"),
+			"503": errorResponse("The request timed out, or the server is too busy to take any of the requests"),
+		},
+	}
+
+	sessionSummary := schema{
+		"summary":     "Get the usage of a session",
+		"description": "Requests and letters of a session_id, kept until it has been idle for the session TTL",
+		"tags":        []string{"sessions"},
+		"parameters": []interface{}{
+			schema{"name": "id", "in": "path", "required": true, "schema": schema{"type": "string"}},
+		},
+		"responses": schema{
+			"200": jsonResponse("Usage of the session", b.of(reflect.TypeOf(session.Session{}))),
+			"404": errorResponse("Unknown or expired session"),
+		},
+	}
+
+	sessionHistory := schema{
+		"summary":     "Get the recent responses of a session",
+		"description": "The names last given to a session_id, oldest first, for clients that lost them",
+		"tags":        []string{"sessions"},
+		"parameters":  sessionSummary["parameters"],
+		"responses": schema{
+			"200": jsonResponse("Recent responses", b.of(reflect.TypeOf(SessionHistoryResponse{}))),
+			"404": errorResponse("Unknown or expired session"),
+		},
+	}
+
+	stats := schema{
+		"summary":   "Server statistics dashboard",
+		"tags":      []string{"stats"},
+		"responses": schema{"200": schema{"description": "HTML page", "content": content(schema{"type": "string"}, "text/html")}},
+	}
+
+	statsHistory := schema{
+		"summary":     "Get the history of the metrics",
+		"description": "Snapshots of the numeric metrics taken every HistoryInterval, oldest first",
+		"tags":        []string{"stats"},
+		"parameters": []interface{}{
+			schema{"name": "window", "in": "query", "description": "How far back to go, at most HistoryRetention", "schema": schema{"type": "string", "default": "1h"}},
+			schema{"name": "metrics", "in": "query", "description": "Comma-separated names of the metrics to include; all if omitted", "schema": schema{"type": "string"}},
+		},
+		"responses": schema{
+			"200": jsonResponse("Metrics snapshots", b.of(reflect.TypeOf(StatsHistoryResponse{}))),
+			"400": errorResponse("Invalid window"),
+			"501": errorResponse("The history is disabled"),
+		},
+	}
+
+	statsJSON := schema{
+		"summary":     "Get the numeric metrics of this instance",
+		"description": "Read by the peers for their cluster view",
+		"tags":        []string{"stats"},
+		"responses":   schema{"200": jsonResponse("Metrics by name", b.of(reflect.TypeOf(StatsJSONResponse{})))},
+	}
+
+	statsCluster := schema{
+		"summary":     "Cluster statistics dashboard",
+		"description": "This instance and the StatsPeers, with their totals",
+		"tags":        []string{"stats"},
+		"responses": schema{
+			"200": schema{"description": "HTML page", "content": content(schema{"type": "string"}, "text/html")},
+			"501": errorResponse("No peers are configured"),
+		},
+	}
+
+	statsRequests := schema{
+		"summary":     "Recent requests page",
+		"description": "The recent requests, newest first, filtered by status and path and refreshed live",
+		"tags":        []string{"stats"},
+		"parameters": []interface{}{
+			schema{"name": "status", "in": "query", "description": "Status code, like 404, or class, like 5xx", "schema": schema{"type": "string"}},
+			schema{"name": "path", "in": "query", "description": "Part of the path", "schema": schema{"type": "string"}},
+		},
+		"responses": schema{
+			"200": schema{"description": "HTML page", "content": content(schema{"type": "string"}, "text/html")},
+			"400": errorResponse("Invalid status"),
+			"501": errorResponse("Recent requests are not recorded"),
+		},
+	}
+
+	statsExport := schema{
+		"summary":     "Export the history of the metrics as CSV",
+		"description": "A row per snapshot, oldest first, and a column per metric after the time",
+		"tags":        []string{"stats"},
+		"parameters":  statsHistory["parameters"],
+		"responses": schema{
+			"200": schema{"description": "CSV file", "content": content(schema{"type": "string"}, "text/csv")},
+			"400": errorResponse("Invalid window"),
+			"501": errorResponse("The history is disabled"),
+		},
+	}
+
+	statsStream := schema{
+		"summary":     "Stream the dashboard metrics",
+		"description": "Server-Sent Events, each a JSON object of the metrics that changed, all of them in the first",
+		"tags":        []string{"stats"},
+		"responses":   schema{"200": schema{"description": "Event stream", "content": content(schema{"type": "string"}, "text/event-stream")}},
+	}
+
+	// With JWT authentication the operations outside the admin API need a token
+	securitySchemes := schema{
+		"bearerAuth": schema{"type": "http", "scheme": "bearer", "description": "The admin or reader token, or a JWT with a roles claim"},
+	}
+	if s.options.AuthMode == AuthJWT {
+		securitySchemes["jwtAuth"] = schema{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"}
+		for _, operation := range []schema{generate, names, batch, sessionSummary, sessionHistory, stats, statsJSON, statsCluster, statsRequests, statsHistory, statsExport, statsStream} {
+			operation["security"] = []interface{}{schema{"jwtAuth": []string{}}}
+			operation["responses"].(schema)["401"] = errorResponse("Missing or invalid token")
+		}
+	}
+
+	keyParameter := schema{"name": "key", "in": "path", "required": true, "schema": schema{"type": "string"}}
+	datasetParameters := []interface{}{
+		schema{"name": "locale", "in": "path", "required": true, "schema": schema{"type": "string"}},
+		schema{"name": "file", "in": "path", "required": true, "schema": schema{"type": "string"}},
+	}
+
+	return schema{
+		"openapi": "3.0.3",
+		"info": schema{
+			"title":       "Name Generator API",
+			"version":     "1.0.0",
+			"description": "Generates names starting with a given letter",
+		},
+		"paths": schema{
+			"/generate":              schema{"post": generate},
+			"/generate/batch":        schema{"post": batch},
+			"/names/{letter}":        schema{"get": names},
+			"/datasets/stats":        schema{"get": datasetStats},
+			"/sessions/{id}":         schema{"get": sessionSummary},
+			"/sessions/{id}/history": schema{"get": sessionHistory},
+			"/stats":                 schema{"get": stats},
+			"/stats.json":            schema{"get": statsJSON},
+			"/stats/cluster":         schema{"get": statsCluster},
+			"/stats/requests":        schema{"get": statsRequests},
+			"/stats/history":         schema{"get": statsHistory},
+			"/stats/export":          schema{"get": statsExport},
+			"/stats/stream":          schema{"get": statsStream},
+			"/healthz": schema{"get": schema{
+				"summary": "Health check",
+				"tags":    []string{"health"},
+				"responses": schema{
+					"200": jsonResponse("Serving", b.of(reflect.TypeOf(HealthResponse{}))),
+					"503": jsonResponse("Draining during shutdown", b.of(reflect.TypeOf(HealthResponse{}))),
+				},
+			}},
+			"/readyz": schema{"get": schema{
+				"summary":     "Readiness check",
+				"description": "Checks the worker pools and the cache backend",
+				"tags":        []string{"health"},
+				"responses": schema{
+					"200": jsonResponse("Ready to serve requests", b.of(reflect.TypeOf(ReadyResponse{}))),
+					"503": jsonResponse("A check failed, or the server is draining", b.of(reflect.TypeOf(ReadyResponse{}))),
+				},
+			}},
+			"/admin/cache": schema{
+				"get": adminOperation("List the cached keys", schema{
+					"200": jsonResponse("Cached keys", b.of(reflect.TypeOf(CacheKeysResponse{}))),
+				}),
+				"delete": adminOperation("Flush the cache", schema{"204": schema{"description": "Cache flushed"}}),
+			},
+			"/admin/cache/{key}": schema{
+				"parameters": []interface{}{keyParameter},
+				"get": adminOperation("Get a cache entry", schema{
+					"200": jsonResponse("Cache entry", b.of(reflect.TypeOf(CacheEntryResponse{}))),
+					"404": errorResponse("Key not found"),
+				}),
+				"delete": adminOperation("Delete a cache entry", schema{
+					"204": schema{"description": "Entry deleted"},
+					"404": errorResponse("Key not found"),
+				}),
+			},
+			"/admin/datasets": schema{
+				"get": adminOperation("List the dataset files", schema{
+					"200": jsonResponse("Dataset files", b.of(reflect.TypeOf(DatasetsResponse{}))),
+				}),
+				"post": func() schema {
+					operation := adminOperation("Upload a dataset file", schema{
+						"201": jsonResponse("Dataset loaded", b.of(reflect.TypeOf(DatasetInfo{}))),
+						"400": errorResponse("Invalid dataset"),
+						"413": errorResponse("Dataset too large"),
+						"415": errorResponse("Unsupported Content-Type"),
+					})
+					operation["parameters"] = []interface{}{
+						schema{"name": "name", "in": "query", "required": true, "schema": schema{"type": "string"}},
+						schema{"name": "locale", "in": "query", "schema": schema{"type": "string", "default": "en"}},
+					}
+					operation["requestBody"] = schema{"required": true, "content": content(schema{"type": "string"}, "application/json", "text/csv")}
+					return operation
+				}(),
+			},
+			"/admin/datasets/reload": schema{
+				"post": adminOperation("Reload the dataset files changed outside the API", schema{
+					"200": jsonResponse("Letters whose names changed, by locale", b.of(reflect.TypeOf(DatasetsReloadResponse{}))),
+					"500": errorResponse("Datasets could not be loaded"),
+				}),
+			},
+			"/admin/datasets/{locale}/{file}": schema{
+				"parameters": datasetParameters,
+				"delete": adminOperation("Delete a dataset file", schema{
+					"204": schema{"description": "Dataset deleted"},
+					"404": errorResponse("Dataset not found"),
+				}),
+			},
+			"/admin/loglevel": schema{
+				"get": adminOperation("Get the log level", schema{
+					"200": jsonResponse("Active log level", b.of(reflect.TypeOf(LogLevelResponse{}))),
+				}),
+				"put": func() schema {
+					operation := adminOperation("Change the log level", schema{
+						"200": jsonResponse("New log level", b.of(reflect.TypeOf(LogLevelResponse{}))),
+						"400": errorResponse("Unknown level"),
+					})
+					operation["requestBody"] = schema{"required": true, "content": content(b.of(reflect.TypeOf(LogLevelRequest{})), "application/json")}
+					return operation
+				}(),
+			},
+			"/admin/ratelimit": schema{
+				"get": adminOperation("Get the rate limit", schema{
+					"200": jsonResponse("Active rate limit", b.of(reflect.TypeOf(RateLimitResponse{}))),
+				}),
+				"put": func() schema {
+					operation := adminOperation("Change the rate limit until the server restarts", schema{
+						"200": jsonResponse("New rate limit", b.of(reflect.TypeOf(RateLimitResponse{}))),
+						"400": errorResponse("Invalid limit"),
+					})
+					operation["requestBody"] = schema{"required": true, "content": content(b.of(reflect.TypeOf(RateLimitRequest{})), "application/json")}
+					return operation
+				}(),
+			},
+			"/admin/maintenance": schema{
+				"get": adminOperation("Get whether maintenance mode is on", schema{
+					"200": jsonResponse("Maintenance mode", b.of(reflect.TypeOf(MaintenanceResponse{}))),
+				}),
+				"put": func() schema {
+					operation := adminOperation("Turn maintenance mode on or off; the API answers 503 while it is on", schema{
+						"200": jsonResponse("New maintenance mode", b.of(reflect.TypeOf(MaintenanceResponse{}))),
+						"400": errorResponse("Invalid request body"),
+					})
+					operation["requestBody"] = schema{"required": true, "content": content(b.of(reflect.TypeOf(MaintenanceRequest{})), "application/json")}
+					return operation
+				}(),
+			},
+			"/admin/requests": schema{
+				"get": func() schema {
+					operation := adminOperation("List the most recent requests, newest first", schema{
+						"200": jsonResponse("Recent requests", b.of(reflect.TypeOf(RecentRequestsResponse{}))),
+						"400": errorResponse("Invalid limit"),
+						"501": errorResponse("Recent requests are not recorded"),
+					})
+					operation["parameters"] = []interface{}{
+						schema{"name": "limit", "in": "query", "schema": schema{"type": "integer", "minimum": 1}},
+					}
+					return operation
+				}(),
+			},
+			"/admin/audit": schema{
+				"get": func() schema {
+					operation := adminOperation("List the most recent admin actions, newest first (admin role)", schema{
+						"200": jsonResponse("Audit log entries", b.of(reflect.TypeOf(AuditLogResponse{}))),
+						"400": errorResponse("Invalid limit"),
+					})
+					operation["parameters"] = []interface{}{
+						schema{"name": "limit", "in": "query", "schema": schema{"type": "integer", "minimum": 1}},
+					}
+					return operation
+				}(),
+			},
+			"/admin/blocklist": schema{
+				"get": adminOperation("List the blocked names", schema{
+					"200": jsonResponse("Blocked names", b.of(reflect.TypeOf(BlocklistResponse{}))),
+				}),
+				"post": func() schema {
+					operation := adminOperation("Block names", schema{
+						"200": jsonResponse("Blocked names", b.of(reflect.TypeOf(BlocklistResponse{}))),
+						"400": errorResponse("No names given"),
+					})
+					operation["requestBody"] = schema{"required": true, "content": content(b.of(reflect.TypeOf(BlocklistRequest{})), "application/json")}
+					return operation
+				}(),
+			},
+		},
+		"components": schema{
+			"schemas":         b.components,
+			"securitySchemes": securitySchemes,
+		},
+	}
+}
+
+// handleOpenAPI serves the OpenAPI document of the API
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.openAPIDocument())
+}
+
+// docsHTML is a page to browse and try the API from its OpenAPI document
+// Its script and stylesheet are embedded in the binary, so it works offline
+const docsHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Name Generator API</title>
+    <link rel="stylesheet" href="/static/docs/docs.css">
+</head>
+<body>
+    <div id="docs" data-spec="/openapi.json">Loading the API description…</div>
+    <script src="/static/docs/docs.js"></script>
+</body>
+</html>
+`
+
+// handleDocs serves the API documentation page, to browse and try the API
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(docsHTML))
+}
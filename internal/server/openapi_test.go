@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collectRefs returns the $ref targets found anywhere in a decoded JSON value
+func collectRefs(v interface{}, refs []string) []string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			refs = append(refs, ref)
+		}
+		for _, child := range v {
+			refs = collectRefs(child, refs)
+		}
+	case []interface{}:
+		for _, child := range v {
+			refs = collectRefs(child, refs)
+		}
+	}
+	return refs
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v", rr.Code)
+	}
+	var document map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&document); err != nil {
+		t.Fatalf("Error parsing document: %v", err)
+	}
+	if document["openapi"] != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %v", document["openapi"])
+	}
+
+	// Every endpoint is described
+	paths := document["paths"].(map[string]interface{})
+	for _, path := range []string{"/generate", "/generate/batch", "/names/{letter}", "/datasets/stats", "/sessions/{id}", "/sessions/{id}/history", "/stats", "/stats.json", "/stats/cluster", "/stats/requests", "/stats/history", "/stats/export", "/stats/stream", "/admin/cache", "/admin/cache/{key}", "/admin/datasets", "/admin/datasets/{locale}/{file}", "/admin/blocklist", "/admin/loglevel", "/admin/ratelimit", "/admin/maintenance", "/admin/requests", "/admin/audit"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("Expected path %s to be described", path)
+		}
+	}
+
+	// Schemas are derived from the payload structs, and every reference resolves
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, ref := range collectRefs(document, nil) {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("Unresolved reference %s", ref)
+		}
+	}
+	request := schemas["RequestPayload"].(map[string]interface{})
+	properties := request["properties"].(map[string]interface{})
+	for _, field := range []string{"session_id", "letter", "prefix", "num_of_entries", "locale", "unique", "weighted", "seed", "offset", "cursor"} {
+		if _, ok := properties[field]; !ok {
+			t.Errorf("Expected RequestPayload to have field %s", field)
+		}
+	}
+	if _, ok := properties["Letters"]; ok {
+		t.Error("Expected fields that are not encoded to be left out")
+	}
+	if _, ok := properties["letter"].(map[string]interface{})["oneOf"]; !ok {
+		t.Error("Expected letter to accept a string or an array")
+	}
+	if maximum := properties["num_of_entries"].(map[string]interface{})["maximum"]; maximum != float64(server.options.MaxEntries) {
+		t.Errorf("Expected num_of_entries to be limited to %d, got %v", server.options.MaxEntries, maximum)
+	}
+	required := request["required"].([]interface{})
+	if len(required) != 3 {
+		t.Errorf("Expected session_id, letter and num_of_entries to be required, got %v", required)
+	}
+}
+
+func TestHandleDocs(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	rr := httptest.NewRecorder()
+	server.createRouter().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v", rr.Code)
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html") || !strings.Contains(rr.Body.String(), `data-spec="/openapi.json"`) {
+		t.Errorf("Expected the docs page, got %q", rr.Body.String())
+	}
+
+	// The page only loads embedded assets, which the public server serves
+	// without a token even when the dashboard is on an admin server
+	options := DefaultServerOptions()
+	options.AdminAddr = "127.0.0.1:0"
+	options.AuthMode = AuthJWT
+	public := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		public.Shutdown(ctx)
+	}()
+	for _, attribute := range []string{`src="`, `href="`} {
+		for _, part := range strings.Split(docsHTML, attribute)[1:] {
+			path := part[:strings.Index(part, `"`)]
+			if !strings.HasPrefix(path, "/static/docs/") {
+				t.Errorf("Expected only embedded assets, got %s", path)
+				continue
+			}
+			rr := httptest.NewRecorder()
+			public.httpServer.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+			if rr.Code != http.StatusOK || rr.Body.Len() == 0 {
+				t.Errorf("Expected %s to be served, got %v", path, rr.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package server
+
+import "net/http/pprof"
+
+// registerPprof adds the net/http/pprof handlers under /debug/pprof/, for
+// capturing CPU and heap profiles during load tests
+// Profiles reveal the internals of the server and a CPU profile slows it down,
+// so they need the admin role
+func (s *Server) registerPprof(mux *router) {
+	mux.HandleFunc("/debug/pprof/", s.requireRole(RoleAdmin, pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", s.requireRole(RoleAdmin, pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", s.requireRole(RoleAdmin, pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", s.requireRole(RoleAdmin, pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", s.requireRole(RoleAdmin, pprof.Trace))
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPprof(t *testing.T) {
+	options := DefaultServerOptions()
+	options.AdminToken = "admin-secret"
+	options.ReaderToken = "reader-secret"
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	// Profiling is off unless enabled
+	if rr := adminRequest(server.createRouter(), http.MethodGet, "/debug/pprof/", "admin-secret"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status NotFound with pprof disabled, got %v", rr.Code)
+	}
+
+	server.options.EnablePprof = true
+	router := server.createRouter()
+
+	// Admins get the profiles
+	rr := adminRequest(router, http.MethodGet, "/debug/pprof/", "admin-secret")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "goroutine") {
+		t.Errorf("Expected the profile index, got %v", rr.Code)
+	}
+	if rr := adminRequest(router, http.MethodGet, "/debug/pprof/heap?debug=1", "admin-secret"); rr.Code != http.StatusOK {
+		t.Errorf("Expected a heap profile, got %v", rr.Code)
+	}
+
+	// Everyone else is turned away
+	if rr := adminRequest(router, http.MethodGet, "/debug/pprof/heap", "reader-secret"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status Forbidden for a reader, got %v", rr.Code)
+	}
+	if rr := adminRequest(router, http.MethodGet, "/debug/pprof/heap", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status Unauthorized without a token, got %v", rr.Code)
+	}
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/amirahmetzanov/go_project/internal/metrics"
+)
+
+// problemContentType is the media type of error responses
+const problemContentType = "application/problem+json"
+
+// Problem is an error response as described by RFC 7807
+type Problem struct {
+	Type     string       `json:"type"`   // URI identifying the kind of problem; about:blank when the status says it all
+	Title    string       `json:"title"`  // Short summary of the kind of problem
+	Status   int          `json:"status"` // HTTP status code
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"` // Path of the request that failed
+	Errors   []FieldError `json:"errors,omitempty"`   // Invalid fields of the request
+}
+
+// FieldError reports an invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"` // JSON name of the field, e.g. "letter" or "letter[2]"
+	Message string `json:"message"`
+}
+
+// validationProblemType identifies responses listing invalid request fields
+const validationProblemType = "/problems/validation-error"
+
+// writeProblem writes an error response as application/problem+json
+// It is used by every handler in place of http.Error
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	writeProblemBody(w, Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+}
+
+// writeProblemBody writes a problem, which must have its status set
+func writeProblemBody(w http.ResponseWriter, problem Problem) {
+	// Like http.Error, drop headers set for the body that was meant to be sent
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// requestError is a generate request that failed, with the HTTP status to report it with
+type requestError struct {
+	status  int
+	message string
+	fields  []FieldError // Set when the request failed validation
+}
+
+// Error returns the message of the error
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// write sends the error to the client
+// Requests rejected as bad requests are counted as validation failures
+func (e *requestError) write(w http.ResponseWriter, r *http.Request) {
+	if e.status == http.StatusBadRequest {
+		failRequest(r, metrics.CategorizeError(metrics.CategoryValidation, e))
+	}
+	if e.status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	problem := e.problem()
+	problem.Instance = r.URL.Path
+	writeProblemBody(w, problem)
+}
+
+// problem describes the error as a Problem
+func (e *requestError) problem() Problem {
+	problem := Problem{Type: "about:blank", Title: http.StatusText(e.status), Status: e.status, Detail: e.message}
+	if len(e.fields) > 0 {
+		problem.Type = validationProblemType
+		problem.Title = "Invalid request"
+		problem.Errors = e.fields
+	}
+	return problem
+}
+
+// badRequest returns a requestError for a request that cannot be processed as a whole
+func badRequest(message string) *requestError {
+	return &requestError{status: http.StatusBadRequest, message: message}
+}
+
+// validationErrors collects the invalid fields of a request
+type validationErrors []FieldError
+
+// add records an invalid field
+func (v *validationErrors) add(field, message string) {
+	*v = append(*v, FieldError{Field: field, Message: message})
+}
+
+// err returns the requestError for the invalid fields, or nil if all were valid
+func (v validationErrors) err() *requestError {
+	if len(v) == 0 {
+		return nil
+	}
+	messages := make([]string, len(v))
+	for i, field := range v {
+		messages[i] = field.Message
+	}
+	return &requestError{status: http.StatusBadRequest, message: strings.Join(messages, "; "), fields: v}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidationProblems(t *testing.T) {
+	options := DefaultServerOptions()
+	options.MaxEntries = 50
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	generate := func(body string) (*httptest.ResponseRecorder, Problem) {
+		rr := httptest.NewRecorder()
+		server.handleGenerateNames(rr, httptest.NewRequest(http.MethodPost, "/generate", strings.NewReader(body)))
+		var problem Problem
+		if rr.Code != http.StatusOK {
+			if ct := rr.Header().Get("Content-Type"); ct != problemContentType {
+				t.Errorf("Expected Content-Type %s, got %q", problemContentType, ct)
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+				t.Fatalf("Error parsing problem: %v", err)
+			}
+		}
+		return rr, problem
+	}
+
+	// Every invalid field is reported at once
+	rr, problem := generate(`{"letter":"1","num_of_entries":51,"offset":-1,"locale":"xx"}`)
+	if rr.Code != http.StatusBadRequest || problem.Status != http.StatusBadRequest || problem.Type != validationProblemType || problem.Instance != "/generate" {
+		t.Errorf("Expected a validation problem, got %d %+v", rr.Code, problem)
+	}
+	var fields []string
+	for _, field := range problem.Errors {
+		fields = append(fields, field.Field)
+	}
+	if strings.Join(fields, ",") != "session_id,num_of_entries,offset,letter,locale" {
+		t.Errorf("Expected all invalid fields, got %+v", problem.Errors)
+	}
+
+	tests := []struct {
+		body  string
+		field string // Expected invalid field, empty for a valid request
+	}{
+		{`{"session_id":"s","letter":"A","num_of_entries":50}`, ""},
+		{`{"session_id":"s","letter":"A"}`, ""},
+		{`{"session_id":"s","letter":"ア","locale":"ja"}`, ""},
+		{`{"session_id":"s","prefix":"Ma"}`, ""},
+		{`{"session_id":"s","letter":"A","num_of_entries":-1}`, "num_of_entries"},
+		{`{"session_id":"s"}`, "letter"},
+		{`{"session_id":"s","letter":"AB"}`, "letter"},
+		{`{"session_id":"s","letter":"#"}`, "letter"},
+		{`{"session_id":"s","letter":"A","cursor":"bogus"}`, "cursor"},
+		{`{"session_id":"s","letter":["A","1"]}`, "letter[1]"},
+		{`{"session_id":"s","letter":["A",{"letter":"B","num_of_entries":51}]}`, "letter[1].num_of_entries"},
+		{`{"session_id":"s","letter":["A"],"prefix":"Ma"}`, "prefix"},
+	}
+	for _, tt := range tests {
+		rr, problem := generate(tt.body)
+		if tt.field == "" {
+			if rr.Code != http.StatusOK {
+				t.Errorf("%s: expected status OK, got %d %+v", tt.body, rr.Code, problem)
+			}
+			continue
+		}
+		if rr.Code != http.StatusBadRequest || len(problem.Errors) != 1 || problem.Errors[0].Field != tt.field {
+			t.Errorf("%s: expected %s to be invalid, got %d %+v", tt.body, tt.field, rr.Code, problem)
+		}
+	}
+}
+
+func TestHandlerProblems(t *testing.T) {
+	options := DefaultServerOptions()
+	options.AdminToken = "secret"
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	// Errors of every handler are problems
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "/generate", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/generate", "not json", http.StatusBadRequest},
+		{http.MethodPost, "/generate/batch", "[]", http.StatusBadRequest},
+		{http.MethodGet, "/admin/cache", "", http.StatusUnauthorized},
+		{http.MethodPost, "/openapi.json", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		var problem Problem
+		if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+			t.Errorf("%s %s: error parsing problem: %v", tt.method, tt.path, err)
+			continue
+		}
+		if rr.Code != tt.want || problem.Status != tt.want || problem.Title != http.StatusText(tt.want) || problem.Type != "about:blank" || problem.Detail == "" {
+			t.Errorf("%s %s: expected a %d problem, got %d %+v", tt.method, tt.path, tt.want, rr.Code, problem)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != problemContentType {
+			t.Errorf("%s %s: expected Content-Type %s, got %q", tt.method, tt.path, problemContentType, ct)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/amirahmetzanov/go_project/internal/namespb"
+)
+
+// payloadFromProto converts a protobuf request to the payload of a JSON request
+func payloadFromProto(request *namespb.GenerateRequest) RequestPayload {
+	payload := RequestPayload{
+		SessionID:    request.SessionID,
+		Letter:       request.Letter,
+		Prefix:       request.Prefix,
+		NumOfEntries: int(request.NumOfEntries),
+		Locale:       request.Locale,
+		Unique:       request.Unique,
+		Weighted:     request.Weighted,
+		Seed:         request.Seed,
+		Offset:       int(request.Offset),
+		Cursor:       request.Cursor,
+	}
+	for _, letter := range request.Letters {
+		payload.Letters = append(payload.Letters, LetterSpec{Letter: letter.Letter, NumOfEntries: int(letter.NumOfEntries)})
+	}
+	return payload
+}
+
+// responseToProto converts a response to its protobuf message
+func responseToProto(response ResponsePayload) *namespb.GenerateResponse {
+	message := &namespb.GenerateResponse{
+		SessionID:    response.SessionID,
+		Names:        response.Names,
+		NumOfEntries: int32(response.NumOfEntries),
+		Locale:       response.Locale,
+		Seed:         response.Seed,
+		NextCursor:   response.NextCursor,
+	}
+	for _, group := range response.Groups {
+		message.Groups = append(message.Groups, namespb.LetterGroup{Letter: group.Letter, Names: group.Names, NumOfEntries: int32(group.NumOfEntries)})
+	}
+	return message
+}
+
+// encodeProtobuf writes the response as a GenerateResponse message
+func encodeProtobuf(w http.ResponseWriter, response ResponsePayload) error {
+	_, err := w.Write(responseToProto(response).Marshal())
+	return err
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/namespb"
+)
+
+func TestGenerateNamesProtobuf(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	generate := func(request *namespb.GenerateRequest, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/generate", bytes.NewReader(request.Marshal()))
+		req.Header.Set("Content-Type", namespb.ContentType)
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		server.handleGenerateNames(rr, req)
+		return rr
+	}
+
+	// Protobuf requests are answered in protobuf by default
+	rr := generate(&namespb.GenerateRequest{SessionID: "s", Letter: "A", NumOfEntries: 4, Seed: 3}, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v: %s", rr.Code, rr.Body)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != namespb.ContentType {
+		t.Errorf("Expected Content-Type %s, got %q", namespb.ContentType, ct)
+	}
+	var response namespb.GenerateResponse
+	if err := response.Unmarshal(rr.Body.Bytes()); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if response.SessionID != "s" || len(response.Names) != 4 || response.NumOfEntries != 4 || response.Seed != 3 || response.NextCursor == "" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	// The same request can ask for JSON, and gets the same names
+	rr = generate(&namespb.GenerateRequest{SessionID: "s", Letter: "A", NumOfEntries: 4, Seed: 3}, "application/json")
+	var jsonResponse ResponsePayload
+	if err := json.NewDecoder(rr.Body).Decode(&jsonResponse); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if strings.Join(jsonResponse.Names, ",") != strings.Join(response.Names, ",") {
+		t.Errorf("Expected the same names, got %v and %v", jsonResponse.Names, response.Names)
+	}
+
+	// Letter arrays are grouped
+	rr = generate(&namespb.GenerateRequest{SessionID: "s", NumOfEntries: 2, Letters: []namespb.LetterSpec{{Letter: "B"}, {Letter: "C", NumOfEntries: 1}}}, namespb.ContentType)
+	if err := response.Unmarshal(rr.Body.Bytes()); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if len(response.Groups) != 2 || len(response.Groups[0].Names) != 2 || response.Groups[1].Letter != "C" || len(response.Names) != 3 {
+		t.Errorf("Expected grouped names, got %+v", response)
+	}
+
+	// JSON requests can ask for protobuf
+	req := httptest.NewRequest(http.MethodPost, "/generate", strings.NewReader(`{"session_id":"s","letter":"D","num_of_entries":2}`))
+	req.Header.Set("Accept", namespb.ContentType)
+	rr = httptest.NewRecorder()
+	server.handleGenerateNames(rr, req)
+	if err := response.Unmarshal(rr.Body.Bytes()); err != nil || len(response.Names) != 2 {
+		t.Errorf("Expected 2 names in protobuf, got %+v (err %v)", response, err)
+	}
+
+	// Malformed messages are rejected
+	req = httptest.NewRequest(http.MethodPost, "/generate", bytes.NewReader([]byte{0x0a, 0x05}))
+	req.Header.Set("Content-Type", namespb.ContentType)
+	rr = httptest.NewRecorder()
+	server.handleGenerateNames(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest, got %v", rr.Code)
+	}
+}
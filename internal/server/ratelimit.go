@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// RateLimitRequest changes the rate limit
+type RateLimitRequest struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+}
+
+// RateLimitResponse reports the active rate limit
+type RateLimitResponse struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int64   `json:"burst"` // Requests allowed at once after a quiet spell
+}
+
+// setRequestRateLimit changes the rate limit of the API, keeping the burst and
+// the sliding window in the proportions NewServer sets them up with
+func (s *Server) setRequestRateLimit(rate float64) {
+	s.tokenLimiter.SetRate(rate, int64(rate*30))
+	s.slidingLimiter.SetMaxRequests(int64(rate * 2.0))
+}
+
+// handleAdminRateLimit handles requests to inspect and change the rate limit at runtime
+// The change lasts until the server restarts, which brings back RequestRateLimit
+//
+//	GET /admin/ratelimit  returns the active limit
+//	PUT /admin/ratelimit  sets the limit given in a RateLimitRequest
+func (s *Server) handleAdminRateLimit(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, RateLimitResponse{RequestsPerSecond: s.tokenLimiter.Rate(), Burst: s.tokenLimiter.Capacity()})
+
+	case http.MethodPut:
+		var request RateLimitRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "Request body must give the limit, e.g. {\"requests_per_second\":500}")
+			return
+		}
+		var invalid validationErrors
+		if request.RequestsPerSecond < 1 {
+			invalid.add("requests_per_second", "requests_per_second must be at least 1")
+		}
+		if reqErr := invalid.err(); reqErr != nil {
+			reqErr.write(w, r)
+			return
+		}
+
+		previous := s.tokenLimiter.Rate()
+		s.setRequestRateLimit(request.RequestsPerSecond)
+		from, to := strconv.FormatFloat(previous, 'f', -1, 64), strconv.FormatFloat(request.RequestsPerSecond, 'f', -1, 64)
+		s.requestLogger(r).Warn("Rate limit changed", "from", from, "to", to, "remote_addr", r.RemoteAddr)
+		s.auditAction(r, "ratelimit.change", "", map[string]string{"from": from, "to": to})
+		writeJSON(w, http.StatusOK, RateLimitResponse{RequestsPerSecond: s.tokenLimiter.Rate(), Burst: s.tokenLimiter.Capacity()})
+
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
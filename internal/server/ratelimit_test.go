@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleAdminRateLimit(t *testing.T) {
+	options := DefaultServerOptions()
+	options.AdminToken = "secret"
+	options.ReaderToken = "reader"
+	options.RequestRateLimit = 100
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	request := func(method, body, token string) (*httptest.ResponseRecorder, RateLimitResponse) {
+		req := httptest.NewRequest(method, "/admin/ratelimit", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response RateLimitResponse
+		if rr.Code == http.StatusOK {
+			json.NewDecoder(rr.Body).Decode(&response)
+		}
+		return rr, response
+	}
+
+	// The limit starts at RequestRateLimit, with a burst of 30 seconds' worth
+	if _, response := request(http.MethodGet, "", "reader"); response.RequestsPerSecond != 100 || response.Burst != 3000 {
+		t.Errorf("Expected 100 requests per second in bursts of 3000, got %+v", response)
+	}
+
+	// Admins can change it
+	if rr, response := request(http.MethodPut, `{"requests_per_second":2.5}`, "secret"); rr.Code != http.StatusOK || response.RequestsPerSecond != 2.5 || response.Burst != 75 {
+		t.Errorf("Expected 2.5 requests per second in bursts of 75, got %d %+v", rr.Code, response)
+	}
+	if server.tokenLimiter.Rate() != 2.5 {
+		t.Errorf("Expected the limiter to use the new rate, got %v", server.tokenLimiter.Rate())
+	}
+	entries := server.auditLog.Recent(0)
+	if len(entries) != 1 || entries[0].Action != "ratelimit.change" || entries[0].Details["from"] != "100" || entries[0].Details["to"] != "2.5" {
+		t.Errorf("Expected the change in the audit log, got %+v", entries)
+	}
+
+	// Invalid limits, readers and methods are rejected
+	for _, body := range []string{`{"requests_per_second":0}`, `{"requests_per_second":-5}`, `{}`, `not json`} {
+		if rr, _ := request(http.MethodPut, body, "secret"); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status BadRequest for %s, got %d", body, rr.Code)
+		}
+	}
+	if rr, _ := request(http.MethodPut, `{"requests_per_second":10}`, "reader"); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status Forbidden for a reader, got %d", rr.Code)
+	}
+	if rr, _ := request(http.MethodPost, `{"requests_per_second":10}`, "secret"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status MethodNotAllowed, got %d", rr.Code)
+	}
+	if server.tokenLimiter.Rate() != 2.5 {
+		t.Errorf("Expected the rejected changes to leave the rate alone, got %v", server.tokenLimiter.Rate())
+	}
+}
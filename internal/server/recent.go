@@ -0,0 +1,135 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// dashboardRecentRequests is the number of recent requests shown on the dashboard
+const dashboardRecentRequests = 15
+
+// RecentRequest is an answered request, kept for live debugging
+type RecentRequest struct {
+	Time       time.Time `json:"time"` // When the request arrived
+	RequestID  string    `json:"request_id"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	LatencyMS  float64   `json:"latency_ms"`
+	RemoteAddr string    `json:"remote_addr"`
+	SessionID  string    `json:"session_id,omitempty"`
+}
+
+// RecentRequestsResponse lists the most recent requests, newest first
+type RecentRequestsResponse struct {
+	Count    int             `json:"count"`
+	Requests []RecentRequest `json:"requests"`
+}
+
+// requestRing keeps the last requests in a fixed number of slots
+// Adding a request takes an atomic increment and an atomic store, so the
+// requests don't contend on a lock; readers may miss a request being written
+type requestRing struct {
+	slots []atomic.Pointer[RecentRequest]
+	next  atomic.Uint64 // Requests added so far
+}
+
+// newRequestRing creates a ring of the given size, or nil if size is not positive
+func newRequestRing(size int) *requestRing {
+	if size <= 0 {
+		return nil
+	}
+	return &requestRing{slots: make([]atomic.Pointer[RecentRequest], size)}
+}
+
+// add records a request, replacing the oldest once the ring is full
+func (r *requestRing) add(request RecentRequest) {
+	if r == nil {
+		return
+	}
+	n := r.next.Add(1) - 1
+	r.slots[n%uint64(len(r.slots))].Store(&request)
+}
+
+// recent returns up to limit of the latest requests, newest first
+// A limit of 0 or less returns all of them
+func (r *requestRing) recent(limit int) []RecentRequest {
+	requests := []RecentRequest{}
+	if r == nil {
+		return requests
+	}
+	size := uint64(len(r.slots))
+	if limit <= 0 || uint64(limit) > size {
+		limit = int(size)
+	}
+	next := r.next.Load()
+	for n := next; n > 0 && next-n < size && len(requests) < limit; n-- {
+		if request := r.slots[(n-1)%size].Load(); request != nil {
+			requests = append(requests, *request)
+		}
+	}
+	return requests
+}
+
+// recordRequest adds an answered request to the recent requests
+// The dashboard's updates, the peers reading the stats for their cluster view
+// and reads of the recent requests themselves, including the refreshes of the
+// request log, would crowd out the requests of interest, so they are left out
+func (s *Server) recordRequest(r *http.Request, rw *responseWriter, start time.Time, fields []interface{}) {
+	switch r.URL.Path {
+	case "/stats/data", "/stats/stream", "/stats/cluster/data", "/stats.json", "/stats/requests/data", "/admin/requests":
+		return
+	}
+	request := RecentRequest{
+		Time:       start,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Status:     rw.statusCode,
+		LatencyMS:  float64(time.Since(start).Microseconds()) / 1000,
+		RemoteAddr: r.RemoteAddr,
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		switch fields[i] {
+		case "request_id":
+			request.RequestID = fmt.Sprint(fields[i+1])
+		case "session_id":
+			request.SessionID = fmt.Sprint(fields[i+1])
+		}
+	}
+	s.recentRequests.add(request)
+}
+
+// handleAdminRequests lists the most recent requests, newest first
+//
+//	GET /admin/requests           returns all kept requests
+//	GET /admin/requests?limit=20  returns the latest 20
+func (s *Server) handleAdminRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.recentRequests == nil {
+		writeProblem(w, r, http.StatusNotImplemented, "Recent requests are not recorded (RecentRequestsSize is 0)")
+		return
+	}
+
+	var invalid validationErrors
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			invalid.add("limit", "limit must be a positive integer")
+		}
+		limit = n
+	}
+	if reqErr := invalid.err(); reqErr != nil {
+		reqErr.write(w, r)
+		return
+	}
+	requests := s.recentRequests.recent(limit)
+	writeJSON(w, http.StatusOK, RecentRequestsResponse{Count: len(requests), Requests: requests})
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestRing(t *testing.T) {
+	ring := newRequestRing(3)
+	if got := ring.recent(0); len(got) != 0 {
+		t.Errorf("Expected no requests, got %v", got)
+	}
+	for i := 1; i <= 5; i++ {
+		ring.add(RecentRequest{Path: fmt.Sprintf("/%d", i)})
+	}
+
+	// The oldest requests are replaced, and the newest come first
+	var paths []string
+	for _, request := range ring.recent(0) {
+		paths = append(paths, request.Path)
+	}
+	if strings.Join(paths, " ") != "/5 /4 /3" {
+		t.Errorf("Expected the last 3 requests newest first, got %v", paths)
+	}
+	if got := ring.recent(2); len(got) != 2 || got[0].Path != "/5" {
+		t.Errorf("Expected the 2 latest requests, got %v", got)
+	}
+
+	// A disabled ring records nothing
+	disabled := newRequestRing(0)
+	disabled.add(RecentRequest{Path: "/"})
+	if got := disabled.recent(0); got == nil || len(got) != 0 {
+		t.Errorf("Expected an empty list, got %v", got)
+	}
+}
+
+func TestRequestRingConcurrent(t *testing.T) {
+	ring := newRequestRing(64)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				ring.add(RecentRequest{Status: http.StatusOK})
+				ring.recent(10)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := ring.recent(0); len(got) != 64 || ring.next.Load() != 8000 {
+		t.Errorf("Expected 64 of 8000 requests, got %d of %d", len(got), ring.next.Load())
+	}
+}
+
+func TestHandleAdminRequests(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.AdminToken = "secret"
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	handler := server.httpServer.Handler
+
+	req := httptest.NewRequest(http.MethodGet, "/names/A?session_id=s1", nil)
+	req.Header.Set(requestIDHeader, "req-1")
+	req.RemoteAddr = "10.0.0.1:5000"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	adminRequest(handler, http.MethodGet, "/names/1", "")
+	adminRequest(handler, http.MethodGet, "/stats/data", "")
+
+	rr := adminRequest(handler, http.MethodGet, "/admin/requests", "secret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v: %s", rr.Code, rr.Body)
+	}
+	var response RecentRequestsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	// The dashboard's polling isn't recorded, and the newest request comes first
+	if response.Count != 2 || len(response.Requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %+v", response)
+	}
+	invalid, named := response.Requests[0], response.Requests[1]
+	if invalid.Path != "/names/1" || invalid.Status != http.StatusBadRequest {
+		t.Errorf("Expected the invalid request first, got %+v", invalid)
+	}
+	if named.RequestID != "req-1" || named.SessionID != "s1" || named.Method != http.MethodGet ||
+		named.Status != http.StatusOK || named.RemoteAddr != "10.0.0.1:5000" || named.LatencyMS <= 0 || named.Time.IsZero() {
+		t.Errorf("Unexpected request %+v", named)
+	}
+
+	if rr := adminRequest(handler, http.MethodGet, "/admin/requests?limit=1", "secret"); !strings.Contains(rr.Body.String(), `"count":1`) {
+		t.Errorf("Expected one request with a limit, got %s", rr.Body)
+	}
+	if rr := adminRequest(handler, http.MethodGet, "/admin/requests?limit=0", "secret"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid limit, got %v", rr.Code)
+	}
+	if rr := adminRequest(handler, http.MethodGet, "/admin/requests", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %v", rr.Code)
+	}
+	if rr := adminRequest(handler, http.MethodDelete, "/admin/requests", "secret"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for DELETE, got %v", rr.Code)
+	}
+
+	// The dashboard shows them too
+	rr = adminRequest(handler, http.MethodGet, "/stats/data", "")
+	if !strings.Contains(rr.Body.String(), "Recent Requests") || !strings.Contains(rr.Body.String(), "/names/1") {
+		t.Error("Expected the dashboard to list the recent requests")
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+
+	"github.com/amirahmetzanov/go_project/internal/metrics"
+)
+
+// requestOutcome records why a request failed, so metricsMiddleware can count it as failed
+type requestOutcome struct {
+	mutex sync.Mutex
+	err   error
+}
+
+// requestOutcomeKey is the context key of the requestOutcome
+type requestOutcomeKey struct{}
+
+// withRequestOutcome returns a context in which handlers can report the failure of the request
+func withRequestOutcome(ctx context.Context) (context.Context, *requestOutcome) {
+	outcome := &requestOutcome{}
+	return context.WithValue(ctx, requestOutcomeKey{}, outcome), outcome
+}
+
+// failRequest marks a request as failed; the first error reported is kept
+func failRequest(r *http.Request, err error) {
+	if outcome, ok := r.Context().Value(requestOutcomeKey{}).(*requestOutcome); ok {
+		outcome.mutex.Lock()
+		if outcome.err == nil {
+			outcome.err = err
+		}
+		outcome.mutex.Unlock()
+	}
+}
+
+// failure returns the error the request failed with, or nil
+func (o *requestOutcome) failure() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.err
+}
+
+// statusFailure returns the error of a request answered with an error status
+// without reporting why, categorized by the status, or nil for other statuses
+func statusFailure(status int) error {
+	if status < http.StatusBadRequest {
+		return nil
+	}
+	err := fmt.Errorf("answered with status %d", status)
+	switch {
+	case status == http.StatusTooManyRequests:
+		return metrics.CategorizeError(metrics.CategoryRateLimited, err)
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return metrics.CategorizeError(metrics.CategoryTimeout, err)
+	case status < http.StatusInternalServerError:
+		return metrics.CategorizeError(metrics.CategoryValidation, err)
+	default:
+		return metrics.CategorizeError(metrics.CategoryInternal, err)
+	}
+}
+
+// logPanic logs a panic of a handler with its stack trace
+func (s *Server) logPanic(r *http.Request, msg string, p interface{}, stack []byte) {
+	s.requestLogger(r).Error(msg, "panic", fmt.Sprint(p), "stack", string(stack))
+}
+
+// recoveryMiddleware turns a panic in a handler into a 500 response
+// The panic is logged with its stack trace and counted as a failed request,
+// and the connection is kept instead of being torn down by net/http
+func (s *Server) recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			// Handlers panic with ErrAbortHandler to drop the connection on purpose
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			// Panics raised again by timeoutMiddleware carry the stack of the handler
+			stack := debug.Stack()
+			if hp, ok := p.(*handlerPanic); ok {
+				p, stack = hp.value, hp.stack
+			}
+			failRequest(r, fmt.Errorf("panic: %v", p))
+			s.logPanic(r, "Handler panicked", p, stack)
+
+			// A response that has started can't be replaced, so it is left truncated
+			if rw, ok := w.(*responseWriter); ok && rw.wroteHeader {
+				return
+			}
+			writeProblem(w, r, http.StatusInternalServerError, "The server failed to process the request")
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/metrics"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	logs := &logBuffer{}
+	options := DefaultServerOptions()
+	options.LogOutput = logs
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	// Wrap a panicking handler in the middleware chain of the router
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("something broke")
+	})
+	mux.HandleFunc("/panic-late", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		panic("something broke late")
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	handler := server.metricsMiddleware(server.loggingMiddleware(server.recoveryMiddleware(mux)))
+
+	// The panic is answered with a problem
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(requestIDHeader, "req-9")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status InternalServerError, got %v", rr.Code)
+	}
+	var problem Problem
+	if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil || problem.Status != http.StatusInternalServerError {
+		t.Errorf("Expected a 500 problem, got %+v (err %v)", problem, err)
+	}
+
+	// It is logged with the stack trace and the request ID, and counted as failed
+	entries := logs.entries(t, "Handler panicked")
+	if len(entries) != 1 {
+		t.Fatalf("Expected the panic to be logged, got %d entries", len(entries))
+	}
+	if entries[0]["request_id"] != "req-9" || entries[0]["panic"] != "something broke" || !strings.Contains(entries[0]["stack"].(string), "recovery_test.go") {
+		t.Errorf("Unexpected panic log: %v", entries[0])
+	}
+	if completed := logs.entries(t, "Request completed"); len(completed) != 1 || completed[0]["status"] != float64(http.StatusInternalServerError) {
+		t.Errorf("Expected the request to be logged with status 500, got %v", completed)
+	}
+	if failed := server.metrics.GetRequestFailed(); failed != 1 || server.metrics.GetFailureCount(metrics.CategoryInternal) != 1 {
+		t.Errorf("Expected 1 failed request, got %d", failed)
+	}
+	if concurrent := server.metrics.GetCurrentConcurrent(); concurrent != 0 {
+		t.Errorf("Expected no requests in flight, got %d", concurrent)
+	}
+
+	// A response that has started is left as it is
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/panic-late", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "partial" {
+		t.Errorf("Expected the partial response, got %d %q", rr.Code, rr.Body.String())
+	}
+	if failed := server.metrics.GetRequestFailed(); failed != 2 {
+		t.Errorf("Expected 2 failed requests, got %d", failed)
+	}
+
+	// Successful requests are not counted as failed
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if failed := server.metrics.GetRequestFailed(); failed != 2 {
+		t.Errorf("Expected still 2 failed requests, got %d", failed)
+	}
+}
+
+func TestStatusFailure(t *testing.T) {
+	for _, tc := range []struct {
+		status   int
+		failed   bool
+		category metrics.ErrorCategory
+	}{
+		{http.StatusOK, false, 0},
+		{http.StatusNotModified, false, 0},
+		{http.StatusBadRequest, true, metrics.CategoryValidation},
+		{http.StatusNotFound, true, metrics.CategoryValidation},
+		{http.StatusTooManyRequests, true, metrics.CategoryRateLimited},
+		{http.StatusGatewayTimeout, true, metrics.CategoryTimeout},
+		{http.StatusInternalServerError, true, metrics.CategoryInternal},
+		{http.StatusServiceUnavailable, true, metrics.CategoryInternal},
+	} {
+		err := statusFailure(tc.status)
+		if (err != nil) != tc.failed {
+			t.Errorf("Expected status %d to fail: %v, got %v", tc.status, tc.failed, err)
+			continue
+		}
+		if err != nil && metrics.ErrorCategoryOf(err) != tc.category {
+			t.Errorf("Expected status %d to be a %v failure, got %v", tc.status, tc.category, metrics.ErrorCategoryOf(err))
+		}
+	}
+}
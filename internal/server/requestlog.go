@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/amirahmetzanov/go_project/internal/ui"
+)
+
+// RequestLogView is the recent requests shown on /stats/requests, with the
+// filters they were picked by
+type RequestLogView struct {
+	Status   string          // Status code, like 404, or class, like 5xx; empty for all
+	Path     string          // Part of the path; empty for all
+	Kept     int             // Recent requests before filtering
+	Requests []RecentRequest // Newest first
+}
+
+// validStatusFilter reports whether a status filter is a code from 100 to 599
+// or a class from 1xx to 5xx
+func validStatusFilter(value string) bool {
+	if len(value) != 3 {
+		return false
+	}
+	if value[0] < '1' || value[0] > '5' {
+		return false
+	}
+	if strings.ToLower(value[1:]) == "xx" {
+		return true
+	}
+	_, err := strconv.Atoi(value)
+	return err == nil
+}
+
+// matchesStatus reports whether a status code passes a status filter
+func matchesStatus(status int, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	code := strconv.Itoa(status)
+	if strings.ToLower(filter[1:]) == "xx" {
+		return code[0] == filter[0]
+	}
+	return code == filter
+}
+
+// handleStatsRequests serves the recent requests, filtered by status and path:
+// the page on /stats/requests, the table on /stats/requests/data for the page
+// to refresh
+//
+//	GET /stats/requests?status=5xx        failed requests
+//	GET /stats/requests?status=404&path=/names
+func (s *Server) handleStatsRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.recentRequests == nil {
+		writeProblem(w, r, http.StatusNotImplemented, "Recent requests are not recorded (RecentRequestsSize is 0)")
+		return
+	}
+
+	query := r.URL.Query()
+	view := RequestLogView{Status: strings.TrimSpace(query.Get("status")), Path: strings.TrimSpace(query.Get("path"))}
+	var invalid validationErrors
+	if view.Status != "" && !validStatusFilter(view.Status) {
+		invalid.add("status", "status must be a status code, like 404, or a class, like 5xx")
+	}
+	if reqErr := invalid.err(); reqErr != nil {
+		reqErr.write(w, r)
+		return
+	}
+
+	requests := s.recentRequests.recent(0)
+	view.Kept = len(requests)
+	view.Requests = []RecentRequest{}
+	for _, request := range requests {
+		if matchesStatus(request.Status, view.Status) && strings.Contains(request.Path, view.Path) {
+			view.Requests = append(view.Requests, request)
+		}
+	}
+
+	name := "statsRequests"
+	if r.URL.Path == "/stats/requests/data" {
+		name = "statsRequestsData"
+	}
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	if err := ui.StatsTemplate.ExecuteTemplate(w, name, view); err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to render the request log")
+		s.requestLogger(r).Error("Error rendering the request log", "error", err)
+	}
+}
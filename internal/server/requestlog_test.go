@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsRequests(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	handler := server.createRouter()
+	for _, path := range []string{"/names/A", "/names/B", "/names/1", "/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+		excluded []string
+	}{
+		{"", []string{"/names/A", "/names/1", "/missing", "4 of 4 recent requests<"}, nil},
+		{"?status=200", []string{"/names/A", "/names/B", "2 of 4 recent requests match"}, []string{"/names/1", "/missing"}},
+		{"?status=4xx", []string{"/names/1", "/missing"}, []string{"/names/A"}},
+		{"?status=4XX&path=/names", []string{"/names/1"}, []string{"/missing", "/names/A"}},
+		{"?path=/admin", []string{"No requests to show"}, []string{"/names/"}},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stats/requests/data"+tt.query, nil))
+		body := rr.Body.String()
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d for %q, got %d %s", http.StatusOK, tt.query, rr.Code, body)
+		}
+		for _, expected := range tt.expected {
+			if !strings.Contains(body, expected) {
+				t.Errorf("Expected %q to show %s, got %s", tt.query, expected, body)
+			}
+		}
+		for _, excluded := range tt.excluded {
+			if strings.Contains(body, excluded) {
+				t.Errorf("Expected %q to leave out %s", tt.query, excluded)
+			}
+		}
+	}
+
+	// The page keeps its filters, and its refreshes aren't recorded
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stats/requests?status=5xx&path=/names", nil))
+	body := rr.Body.String()
+	if !strings.Contains(body, "<html") || !strings.Contains(body, `value="5xx"`) || !strings.Contains(body, `value="/names"`) {
+		t.Errorf("Expected the page with its filters, got %s", body)
+	}
+	if requests := server.recentRequests.recent(0); len(requests) != 5 || requests[0].Path != "/stats/requests" {
+		t.Errorf("Expected only the page to be recorded, got %+v", requests)
+	}
+}
+
+func TestStatsRequestsErrors(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	handler := server.createRouter()
+	for _, status := range []string{"600", "4x", "abc", "x04", "0xx"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stats/requests?status="+status, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for status=%s, got %d", http.StatusBadRequest, status, rr.Code)
+		}
+	}
+
+	options := DefaultServerOptions()
+	options.RecentRequestsSize = 0
+	disabled := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		disabled.Shutdown(ctx)
+	}()
+	rr := httptest.NewRecorder()
+	disabled.createRouter().ServeHTTP(rr, httptest.NewRequest("GET", "/stats/requests", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status %d without recent requests, got %d", http.StatusNotImplemented, rr.Code)
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Roles of the callers of the admin API
+const (
+	RoleReader = "reader" // May read the admin API, e.g. list the cache and the datasets
+	RoleAdmin  = "admin"  // May also change the server, e.g. flush the cache or upload datasets
+)
+
+// rolesClaim is the JWT claim listing the roles of the token holder, as an
+// array or a space-separated string
+const rolesClaim = "roles"
+
+// actorKey is the context key of the caller of the admin API, as named in the audit log
+type actorKey struct{}
+
+// requestRoles returns the roles granted by the bearer token of a request, and
+// who holds the token: "admin token", "reader token" or "jwt:" and the subject
+// The admin and reader tokens grant their role; a JWT grants the roles in its
+// roles claim. ok is false if the request carries no valid credentials
+func (s *Server) requestRoles(r *http.Request) (roles []string, actor string, ok bool) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return nil, "", false
+	}
+
+	// Compare in constant time so the tokens cannot be guessed byte by byte
+	if s.options.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.options.AdminToken)) == 1 {
+		return []string{RoleAdmin}, "admin token", true
+	}
+	if s.options.ReaderToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.options.ReaderToken)) == 1 {
+		return []string{RoleReader}, "reader token", true
+	}
+
+	// The admin API is outside of authMiddleware, so the JWT is verified here
+	if s.options.AuthMode == AuthJWT && s.jwtVerifier != nil {
+		claims, err := s.jwtVerifier.verify(strings.TrimSpace(token))
+		if err != nil {
+			s.requestLogger(r).Debug("Rejected admin token", "error", err)
+			return nil, "", false
+		}
+		actor = "jwt"
+		if claims.Subject != "" {
+			addLogFields(r, "subject", claims.Subject)
+			actor = "jwt:" + claims.Subject
+		}
+		return claimedRoles(claims), actor, true
+	}
+	return nil, "", false
+}
+
+// requestActor returns who made an admin API request, or "" outside of it
+func requestActor(r *http.Request) string {
+	actor, _ := r.Context().Value(actorKey{}).(string)
+	return actor
+}
+
+// claimedRoles returns the roles listed in the roles claim of a token
+func claimedRoles(claims *Claims) []string {
+	switch roles := claims.Values[rolesClaim].(type) {
+	case string:
+		return strings.Fields(roles)
+	case []interface{}:
+		var names []string
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// hasRole reports whether roles include role; admins have the reader role too
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role || r == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// adminEnabled reports whether any credentials for the admin API are configured
+func (s *Server) adminEnabled() bool {
+	return s.options.AdminToken != "" || s.options.ReaderToken != "" || s.options.AuthMode == AuthJWT
+}
+
+// requireRole only lets requests through whose credentials grant the role
+// Requests without valid credentials get a 401, and those lacking the role a 403
+func (s *Server) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.adminEnabled() {
+			writeProblem(w, r, http.StatusForbidden, "Admin API is disabled")
+			return
+		}
+
+		roles, actor, ok := s.requestRoles(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeProblem(w, r, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		if !hasRole(roles, role) {
+			writeProblem(w, r, http.StatusForbidden, "The "+role+" role is required")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, actor)))
+	}
+}
+
+// adminAuth guards an admin API handler: reading needs the reader role, and
+// every other method the admin role
+// The admin API is disabled entirely when no credentials are configured
+func (s *Server) adminAuth(next http.HandlerFunc) http.HandlerFunc {
+	read, write := s.requireRole(RoleReader, next), s.requireRole(RoleAdmin, next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			read(w, r)
+		} else {
+			write(w, r)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAdminRoles(t *testing.T) {
+	options := DefaultServerOptions()
+	options.AdminToken = "admin-secret"
+	options.ReaderToken = "reader-secret"
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"reader lists the cache", http.MethodGet, "/admin/cache", "reader-secret", http.StatusOK},
+		{"reader reads the log level", http.MethodGet, "/admin/loglevel", "reader-secret", http.StatusOK},
+		{"reader can't flush the cache", http.MethodDelete, "/admin/cache", "reader-secret", http.StatusForbidden},
+		{"reader can't upload datasets", http.MethodPost, "/admin/datasets?name=x.json", "reader-secret", http.StatusForbidden},
+		{"admin lists the cache", http.MethodGet, "/admin/cache", "admin-secret", http.StatusOK},
+		{"admin flushes the cache", http.MethodDelete, "/admin/cache", "admin-secret", http.StatusNoContent},
+		{"anonymous can't read", http.MethodGet, "/admin/cache", "", http.StatusUnauthorized},
+		{"stats stay open", http.MethodGet, "/stats", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rr := adminRequest(router, tt.method, tt.path, tt.token); rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rr.Code)
+		}
+	}
+}
+
+func TestAdminRolesFromJWT(t *testing.T) {
+	options := DefaultServerOptions()
+	options.AuthMode = AuthJWT
+	options.JWTSecret = "secret"
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	admin := signHS256(t, "secret", map[string]interface{}{"sub": "ops", "roles": []string{"admin"}})
+	reader := signHS256(t, "secret", map[string]interface{}{"sub": "dashboard", "roles": "reader"})
+	none := signHS256(t, "secret", map[string]interface{}{"sub": "user-1"})
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		status int
+	}{
+		{"admin claim flushes", http.MethodDelete, admin, http.StatusNoContent},
+		{"reader claim reads", http.MethodGet, reader, http.StatusOK},
+		{"reader claim can't flush", http.MethodDelete, reader, http.StatusForbidden},
+		{"no roles can't read", http.MethodGet, none, http.StatusForbidden},
+		{"forged token", http.MethodGet, signHS256(t, "guess", map[string]interface{}{"roles": "admin"}), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if rr := adminRequest(router, tt.method, "/admin/cache", tt.token); rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, rr.Code)
+		}
+	}
+
+	// Any valid token may read the stats
+	if rr := adminRequest(router, http.MethodGet, "/stats", none); rr.Code != http.StatusOK {
+		t.Errorf("Expected the stats to be readable with any token, got %v", rr.Code)
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// router dispatches requests by method and path
+// Patterns are matched like http.ServeMux patterns, except that a {name}
+// segment matches any one path segment and captures it as a path parameter,
+// e.g. "/names/{letter}". A pattern ending in a slash matches every path below it
+type router struct {
+	routes []route
+}
+
+// route is a pattern registered on a router
+type route struct {
+	method  string // Empty for any method, which the handler then checks itself
+	pattern string
+	handler http.Handler
+}
+
+// pathParamsKey is the context key of the path parameters of a request
+type pathParamsKey struct{}
+
+// newRouter creates an empty router
+func newRouter() *router {
+	return &router{}
+}
+
+// HandleFunc registers a handler for a pattern and any method
+func (rt *router) HandleFunc(pattern string, handler http.HandlerFunc) {
+	rt.HandleMethod("", pattern, handler)
+}
+
+// HandleMethod registers a handler for a method and a pattern
+// GET handlers also answer HEAD requests
+func (rt *router) HandleMethod(method, pattern string, handler http.HandlerFunc) {
+	rt.routes = append(rt.routes, route{method: method, pattern: pattern, handler: handler})
+}
+
+// ServeHTTP dispatches a request to the most specific route matching its path
+// and method; paths no route matches get a 404, and paths matched only for
+// other methods a 405 listing the allowed methods
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var best *route
+	var bestParams map[string]string
+	bestScore := -1
+	allowed := map[string]bool{}
+	for i := range rt.routes {
+		route := &rt.routes[i]
+		params, score, ok := matchRoute(route.pattern, r.URL.Path)
+		if !ok {
+			continue
+		}
+		if !route.allows(r.Method) {
+			allowed[route.method] = true
+			if route.method == http.MethodGet {
+				allowed[http.MethodHead] = true
+			}
+			continue
+		}
+		if score > bestScore {
+			best, bestParams, bestScore = route, params, score
+		}
+	}
+
+	if best == nil {
+		if len(allowed) == 0 {
+			writeProblem(w, r, http.StatusNotFound, "Not found")
+			return
+		}
+		methods := make([]string, 0, len(allowed))
+		for method := range allowed {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if len(bestParams) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, bestParams))
+	}
+	best.handler.ServeHTTP(w, r)
+}
+
+// allows reports whether the route handles a method
+func (rt *route) allows(method string) bool {
+	return rt.method == "" || rt.method == method || (rt.method == http.MethodGet && method == http.MethodHead)
+}
+
+// pathParam returns a path parameter of the route that matched the request
+func pathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+// matchPattern reports whether a path matches a pattern and returns its path parameters
+func matchPattern(pattern, path string) (map[string]string, bool) {
+	params, _, ok := matchRoute(pattern, path)
+	return params, ok
+}
+
+// matchRoute matches a path against a pattern
+// The score ranks the matching patterns: patterns without a trailing slash beat
+// prefixes, more literal segments beat parameters, and longer prefixes beat shorter ones
+func matchRoute(pattern, path string) (map[string]string, int, bool) {
+	const exact = 1 << 20
+
+	if strings.HasSuffix(pattern, "/") && !strings.Contains(pattern, "{") {
+		if !strings.HasPrefix(path, pattern) {
+			return nil, 0, false
+		}
+		return nil, len(pattern), true
+	}
+
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternSegments) != len(pathSegments) || strings.HasSuffix(pattern, "/") != strings.HasSuffix(path, "/") {
+		return nil, 0, false
+	}
+
+	var params map[string]string
+	score := exact
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if pathSegments[i] == "" {
+				return nil, 0, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[segment[1:len(segment)-1]] = pathSegments[i]
+			continue
+		}
+		if segment != pathSegments[i] {
+			return nil, 0, false
+		}
+		score++
+	}
+	return params, score, true
+}
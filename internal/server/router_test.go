@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + ":" + pathParam(r, "id") + pathParam(r, "letter")))
+		}
+	}
+	rt := newRouter()
+	rt.HandleFunc("/generate", handler("generate"))
+	rt.HandleFunc("/admin/cache/", handler("cache"))
+	rt.HandleFunc("/admin/", handler("admin"))
+	rt.HandleMethod(http.MethodGet, "/names/{letter}", handler("names"))
+	rt.HandleMethod(http.MethodGet, "/names/random", handler("random"))
+	rt.HandleMethod(http.MethodDelete, "/items/{id}", handler("delete"))
+	rt.HandleMethod(http.MethodPut, "/items/{id}", handler("put"))
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{http.MethodPost, "/generate", http.StatusOK, "generate:"},
+		{http.MethodGet, "/generate/", http.StatusNotFound, ""},
+		{http.MethodGet, "/names/A", http.StatusOK, "names:A"},
+		{http.MethodHead, "/names/A", http.StatusOK, "names:A"},
+		{http.MethodGet, "/names/random", http.StatusOK, "random:"}, // Literal segments win
+		{http.MethodGet, "/names/A/B", http.StatusNotFound, ""},
+		{http.MethodGet, "/admin/cache/A:1", http.StatusOK, "cache:"}, // Longest prefix wins
+		{http.MethodGet, "/admin/datasets", http.StatusOK, "admin:"},
+		{http.MethodDelete, "/items/42", http.StatusOK, "delete:42"},
+		{http.MethodPut, "/items/42", http.StatusOK, "put:42"},
+		{http.MethodPost, "/items/42", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/unknown", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		rt.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+		if rr.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, rr.Code)
+			continue
+		}
+		if tt.body != "" && rr.Body.String() != tt.body {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.path, tt.body, rr.Body.String())
+		}
+	}
+
+	// A 405 lists the methods the path has
+	rr := httptest.NewRecorder()
+	rt.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/items/42", nil))
+	if allow := rr.Header().Get("Allow"); allow != "DELETE, PUT" {
+		t.Errorf("Expected Allow: DELETE, PUT, got %q", allow)
+	}
+	rr = httptest.NewRecorder()
+	rt.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/names/A", nil))
+	if allow := rr.Header().Get("Allow"); allow != "GET, HEAD" {
+		t.Errorf("Expected Allow: GET, HEAD, got %q", allow)
+	}
+}
+
+func TestRouteTimeoutPattern(t *testing.T) {
+	server := &Server{options: DefaultServerOptions()}
+	if timeout, ok := server.routeTimeout("/names/A"); !ok || timeout != server.options.RouteTimeouts["/names/{letter}"] {
+		t.Errorf("Expected /names/A to have the deadline of /names/{letter}, got %v", timeout)
+	}
+	if _, ok := server.routeTimeout("/names/A/B"); ok {
+		t.Error("Expected no deadline for a path no pattern matches")
+	}
+}
@@ -1,96 +1,432 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/amirahmetzanov/go_project/internal/acme"
+	"github.com/amirahmetzanov/go_project/internal/audit"
 	"github.com/amirahmetzanov/go_project/internal/cache"
 	"github.com/amirahmetzanov/go_project/internal/generator"
 	"github.com/amirahmetzanov/go_project/internal/metrics"
 	"github.com/amirahmetzanov/go_project/internal/ratelimit"
+	"github.com/amirahmetzanov/go_project/internal/session"
 	"github.com/amirahmetzanov/go_project/internal/ui"
+	"github.com/amirahmetzanov/go_project/internal/workerpool"
 )
 
+// maxPrefixLength is the longest name prefix a request may search for, in characters
+const maxPrefixLength = 32
+
+// maxLettersPerRequest is the largest number of letters a request may group
+const maxLettersPerRequest = 32
+
 // RequestPayload represents the JSON payload in the incoming request
 type RequestPayload struct {
 	SessionID     string `json:"session_id"`
 	Letter        string `json:"letter"`
+	Prefix        string `json:"prefix,omitempty"` // Longer start of the names, e.g. "Ma"; overrides letter
 	NumOfEntries  int    `json:"num_of_entries"`
+	Locale        string `json:"locale,omitempty"`   // Name dataset to use, e.g. "de" or "tr-TR" (default "en")
+	Unique        bool   `json:"unique,omitempty"`   // Return each name at most once
+	Weighted      *bool  `json:"weighted,omitempty"` // Sample by popularity weight (default true)
+	Seed          int64  `json:"seed,omitempty"`     // Returns a deterministic sequence of names that can be paged through
+	Offset        int    `json:"offset,omitempty"`   // Position in the seeded sequence of the first name
+	Cursor        string `json:"cursor,omitempty"`   // next_cursor of a previous page; replaces seed and offset
+
+	// Letters holds the letters of a request whose letter field is an array
+	Letters []LetterSpec `json:"-"`
+}
+
+// LetterSpec is an entry of a letter array: a letter (or prefix) and, optionally,
+// its own number of names
+type LetterSpec struct {
+	Letter       string `json:"letter"`
+	NumOfEntries int    `json:"num_of_entries,omitempty"` // Defaults to the request's num_of_entries
+}
+
+// UnmarshalJSON accepts either a plain letter or an object with a letter and a count
+func (l *LetterSpec) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &l.Letter); err == nil {
+		return nil
+	}
+
+	type plain LetterSpec
+	return json.Unmarshal(data, (*plain)(l))
+}
+
+// UnmarshalJSON accepts the letter field as a single letter or as an array of letters
+func (p *RequestPayload) UnmarshalJSON(data []byte) error {
+	type plain RequestPayload
+	payload := struct {
+		*plain
+		Letter json.RawMessage `json:"letter"`
+	}{plain: (*plain)(p)}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	letter := bytes.TrimSpace(payload.Letter)
+	if len(letter) > 0 && letter[0] == '[' {
+		return json.Unmarshal(letter, &p.Letters)
+	}
+	if len(letter) > 0 && string(letter) != "null" {
+		return json.Unmarshal(letter, &p.Letter)
+	}
+	return nil
 }
 
 // ResponsePayload represents the JSON response sent back to the client
 type ResponsePayload struct {
-	SessionID     string   `json:"session_id"`
-	Names         []string `json:"names"`
-	NumOfEntries  int      `json:"num_of_entries"`
+	SessionID     string        `json:"session_id" xml:"session_id"`
+	Names         []string      `json:"names" xml:"names>name"`
+	NumOfEntries  int           `json:"num_of_entries" xml:"num_of_entries"`
+	Locale        string        `json:"locale,omitempty" xml:"locale,omitempty"`
+	Seed          int64         `json:"seed,omitempty" xml:"seed,omitempty"`               // Seed of a paged sequence
+	NextCursor    string        `json:"next_cursor,omitempty" xml:"next_cursor,omitempty"` // Cursor of the next page, absent after the last page
+	Groups        []LetterGroup `json:"groups,omitempty" xml:"groups>group,omitempty"`     // Names by letter, for requests with a letter array
+}
+
+// LetterGroup holds the names generated for one letter of a letter array
+type LetterGroup struct {
+	Letter       string   `json:"letter" xml:"letter,attr"`
+	Names        []string `json:"names" xml:"name"`
+	NumOfEntries int      `json:"num_of_entries" xml:"num_of_entries,attr"`
 }
 
 // ServerOptions represents configuration options for the server
 type ServerOptions struct {
+	Port                  int     // Port to listen on (default $PORT, else 8080, or 443 with TLS)
+	AdminAddr             string  // Address of a separate server for /stats, /debug and /admin, e.g. "127.0.0.1:9090" (empty serves them on Port)
+	Workers               int     // Workers of the name generator, or the number it starts with when autoscaling
+	MinWorkers            int     // Fewest workers of the name generator when autoscaling (default 1)
+	MaxWorkers            int     // Most workers of the name generator; above Workers, the workers follow the load (0 keeps Workers fixed)
 	MaxConcurrentRequests int64
 	RequestRateLimit      float64 // Requests per second
+	MaxEntries            int     // Largest num_of_entries a request may ask for
+	MaxRequestBodySize    int64   // Largest request body of /generate and /generate/batch, in bytes
 	CacheSize             int
 	CacheExpiration       time.Duration
 	ReadTimeout           time.Duration
 	WriteTimeout          time.Duration
 	IdleTimeout           time.Duration
+	AdmissionQueueSize    int64         // Requests allowed to wait for rate limit capacity (0 disables queueing)
+	AdmissionQueueTimeout time.Duration // How long a queued request waits before being rejected
+	CacheBackend          string        // "memory" (default) or "redis"
+	CacheEvictionPolicy   string        // "lru" (default), "lfu" or "arc" for the memory backend
+	CacheMaxBytes         int64         // Approximate memory budget of the LRU memory backend (0 means no limit)
+	NegativeCacheTTL      time.Duration // How long empty results (e.g. unknown letters) are cached (0 disables it)
+	StaleWhileRevalidate  time.Duration // Grace period in which expired names are served while being refreshed (0 disables it)
+	SessionTTL            time.Duration // How long a session's activity is kept after its last request (0 keeps it until evicted)
+	MaxSessions           int           // Sessions tracked at most; the one idle the longest is dropped first (0 means no limit)
+	SessionHistorySize    int           // Recent responses kept per session for /sessions/{id}/history (0 disables the history)
+	RecentRequestsSize    int           // Answered requests kept for /admin/requests and the dashboard (0 disables it)
+	RedisAddr             string        // Redis server address used by the redis cache backend
+	RedisPassword         string
+	RedisDB               int
+	RedisKeyPrefix        string // Namespace for cache keys in a shared Redis database
+	AdminToken            string // Bearer token with the admin role on the /admin endpoints
+	ReaderToken           string // Bearer token with the reader role, which may only read the /admin endpoints
+	NamesDir              string // Directory with JSON/CSV name lists (empty uses the built-in lists)
+	NamesWatchInterval    time.Duration // How often NamesDir is checked for changed files to reload (0 disables it)
+	BlocklistFile         string    // File of names never to return, one per line; runtime additions are appended
+	LogLevel              string    // "debug", "info" (default), "warn" or "error"
+	LogOutput             io.Writer // Where the JSON logs are written (default os.Stderr)
+	AccessLogFormat       string    // "json" (default), "common" or "combined"
+	AccessLogFile         string    // File the access log is written to instead of LogOutput, rotated by size
+	AccessLogMaxSize      int64     // Size in bytes at which the access log file is rotated (default 100 MB)
+	AccessLogMaxBackups   int       // Rotated access log files kept (default 5)
+	AuditLogFile          string    // File admin actions are appended to as a hash-chained log (empty keeps them in memory only)
+	AuthMode              string    // "none" (default) or "jwt" to require a JWT bearer token on the public API
+	JWTSecret             string    // HMAC secret of HS256, HS384 and HS512 tokens
+	JWTPublicKeyFile      string    // PEM file with the RSA public key of RS256 tokens
+	JWTIssuer             string    // Required iss claim (empty accepts any issuer)
+	JWTAudience           string    // Audience the aud claim must contain (empty accepts any audience)
+	IPAllowList           []string  // CIDR ranges or addresses allowed to connect (empty allows all)
+	IPDenyList            []string  // CIDR ranges or addresses always rejected with 403
+	RouteTimeouts         map[string]time.Duration // Handler deadline per path; routes without one run until done
+	EnablePprof           bool      // Serve net/http/pprof under /debug/pprof/ to admins
+	TLSDomains            []string  // Domains to serve HTTPS for with certificates from ACME (empty serves plain HTTP)
+	ACMECacheDir          string    // Directory the ACME account key and certificates are kept in across restarts
+	ACMEDirectoryURL      string    // ACME directory of the CA (default Let's Encrypt)
+	ACMEEmail             string    // Contact address for the ACME account
+	StatsDAddr            string        // host:port of a StatsD or DogStatsD server to push metrics to over UDP (empty disables it)
+	StatsDPrefix          string        // Prefix of the pushed metric names
+	StatsDInterval        time.Duration // How often metrics are pushed
+	StatsDTags            []string      // DogStatsD tags added to every metric, e.g. "env:prod"
+	InfluxURL             string        // InfluxDB write endpoint to push metric snapshots to in line protocol (empty disables it)
+	InfluxToken           string        // API token of the InfluxDB endpoint
+	InfluxMeasurement     string        // Measurement of the pushed points
+	InfluxInterval        time.Duration // How often a snapshot is pushed
+	InfluxTags            []string      // Tags of every point as key=value, e.g. "host=web-1"
+	LatencySamples        int           // Response times sampled from all requests for the latency percentiles
+	AlertRules            []string      // Thresholds that raise alerts, e.g. "p99>250ms", "error_rate>5%" or "memory>512MB"
+	AlertWebhookURL       string        // Endpoint alerts are posted to (empty only logs them)
+	AlertWebhookFormat    string        // "slack" for a Slack incoming webhook, otherwise "json"
+	AlertInterval         time.Duration // How often the alert rules are evaluated
+	AlertCooldown         time.Duration // Least time between two notifications that a rule fires
+	HistoryInterval       time.Duration // How often a metrics snapshot is kept for /stats/history
+	HistoryRetention      time.Duration // How long the snapshots are kept (0 disables the history)
+	StatsPeers            []string      // Base URLs of other instances shown with this one on /stats/cluster, e.g. "http://10.0.0.2:8080"
+	StatsPeerTimeout      time.Duration // How long the stats of a peer are waited for
+	StatsPeerToken        string        // Bearer token sent to the peers for /stats.json, and accepted there without a JWT
+	TemplateDir           string        // Directory with templates replacing those of the dashboard (empty uses the built-in ones)
 }
 
 // DefaultServerOptions returns the default server options
 func DefaultServerOptions() ServerOptions {
 	return ServerOptions{
+		Workers:               16,           // Increased from 8 to 16 workers
 		MaxConcurrentRequests: 5000,         // Significantly increased from 2000 to 5000
 		RequestRateLimit:      2000,         // Doubled from 1000 to 2000 requests per second
+		MaxEntries:            1000,         // Larger pages can be fetched with cursors
+		MaxRequestBodySize:    1 << 20,      // 1 MB, far more than a batch of requests needs
 		CacheSize:             5000,         // Significantly increased cache size for high concurrency
 		CacheExpiration:       10 * time.Minute, // Doubled cache expiration to reduce computation
 		ReadTimeout:           15 * time.Second, // Increased for very high concurrent load
 		WriteTimeout:          20 * time.Second, // Increased for very high concurrent load
 		IdleTimeout:           60 * time.Second,
+		AdmissionQueueSize:    0,                      // Queueing disabled by default
+		AdmissionQueueTimeout: 500 * time.Millisecond, // Bounded wait when queueing is enabled
+		CacheBackend:          "memory",
+		CacheEvictionPolicy:   "lru",
+		NegativeCacheTTL:      30 * time.Second, // Much shorter than CacheExpiration
+		SessionTTL:            30 * time.Minute,
+		MaxSessions:           100000, // Requests without a session_id on /names/{letter} count as sessions too
+		SessionHistorySize:    10,
+		RecentRequestsSize:    100,
+		RedisAddr:             "localhost:6379",
+		RedisKeyPrefix:        "namegen:",
+		RouteTimeouts:         defaultRouteTimeouts(),
+		StatsDPrefix:          "namegen",
+		StatsDInterval:        10 * time.Second,
+		InfluxMeasurement:     "namegen",
+		InfluxInterval:        10 * time.Second,
+		LatencySamples:        10000,
+		AlertWebhookFormat:    "json",
+		AlertInterval:         30 * time.Second,
+		AlertCooldown:         5 * time.Minute,
+		HistoryInterval:       10 * time.Second,
+		HistoryRetention:      24 * time.Hour,
+		StatsPeerTimeout:      2 * time.Second,
+		NamesWatchInterval:    10 * time.Second,
 	}
 }
 
-// responseWriter is a custom ResponseWriter that captures the status code
+// alertRules parses the alert rules, skipping invalid ones
+func alertRules(rules []string, logger *slog.Logger) []metrics.AlertRule {
+	parsed := make([]metrics.AlertRule, 0, len(rules))
+	for _, rule := range rules {
+		alertRule, err := metrics.ParseAlertRule(rule)
+		if err != nil {
+			logger.Warn("Ignoring invalid alert rule", "rule", rule, "error", err)
+			continue
+		}
+		parsed = append(parsed, alertRule)
+	}
+	return parsed
+}
+
+// influxTags parses the key=value tags of the InfluxDB points, skipping invalid ones
+func influxTags(tags []string, logger *slog.Logger) map[string]string {
+	parsed := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			logger.Warn("Ignoring InfluxDB tag, expected key=value", "tag", tag)
+			continue
+		}
+		parsed[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return parsed
+}
+
+// responseWriter is a custom ResponseWriter that captures the status code and the body size
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode  int
+	bytes       int64 // Bytes of the body written so far
+	wroteHeader bool  // Whether the response has started
 }
 
 // WriteHeader captures the status code
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
+	rw.wroteHeader = true
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Write counts the bytes of the body
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Flush sends buffered data to the client, so streaming handlers work behind the middleware
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, so http.ResponseController reaches it
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Server represents our web server instance
 type Server struct {
 	metrics        *metrics.MetricsCollector
 	nameGenerator  *generator.NameGenerator
-	cache          *cache.ConcurrentLRUCache
+	cache          cache.Cache
+	flight         cache.Group            // Coalesces concurrent generation of the same cache key
+	revalidating   sync.Map               // Cache keys with a background refresh in progress
+	background     sync.WaitGroup         // Background refreshes, waited for on shutdown
+	datasetsMutex  sync.Mutex             // Serializes changes to the dataset files in NamesDir
+	blocklistMutex sync.Mutex             // Serializes appends to BlocklistFile
+	batchPool      *workerpool.WorkerPool // Runs the requests of /generate/batch concurrently
+	sessions       *session.Tracker       // Activity per session_id, served on /sessions/{id}
+	recentRequests *requestRing           // Last answered requests; nil when disabled
+	auditLog       *audit.Log             // Admin actions, served on /admin/audit
+	statsd         *metrics.StatsDEmitter // Pushes the metrics to StatsDAddr; nil when disabled
+	influx         *metrics.InfluxEmitter // Pushes metric snapshots to InfluxURL; nil when disabled
+	alerter        *metrics.Alerter       // Evaluates AlertRules; nil when there are none
+	history        *metrics.History       // Metrics snapshots served on /stats/history; nil when disabled
+	namesWatcher   *generator.Watcher     // Reloads NamesDir when its files change; nil when disabled
+	logger         *slog.Logger
+	logLevel       *slog.LevelVar // Level of logger, adjustable at runtime
+	accessLog      *accessLog
+	jwtVerifier    *jwtVerifier // Set when JWT authentication is on and its keys could be loaded
+	ipFilter       *ipFilter    // Set when an allow or deny list is configured
+	draining       atomic.Bool  // Set once Shutdown starts; new requests are rejected
+	maintenance    atomic.Pointer[string] // Message of the maintenance mode; nil when off
+	vars           *expvar.Map  // Counters served on /debug/vars
+	certManager    *acme.Manager // Set when TLS certificates are obtained via ACME
+	upgrading      atomic.Bool   // Set while a new binary takes over the listeners
+	listeners      []net.Listener
+	listenersMutex sync.Mutex
+	middlewares    []Middleware // Added with Use, run after the built-in middlewares
 	rateLimiter    ratelimit.RateLimiter
+	tokenLimiter   *ratelimit.TokenBucketLimiter   // Adjusted with the sliding window on /admin/ratelimit
+	slidingLimiter *ratelimit.SlidingWindowLimiter
+	admissionQueue *ratelimit.AdmissionQueue
 	httpServer     *http.Server
+	adminServer    *http.Server // Set when AdminAddr is configured
+	adminListener  net.Listener
 	options        ServerOptions
 }
 
 // NewServer creates a new server instance with the given options
+// Options that don't pass Validate are logged and replaced by their fallbacks
+// like other unusable settings, so check them first to refuse to start instead
 func NewServer(options ServerOptions) *Server {
+	// Options built without DefaultServerOptions still get a usable page size
+	if options.MaxEntries <= 0 {
+		options.MaxEntries = DefaultServerOptions().MaxEntries
+	}
+	if options.Workers <= 0 {
+		options.Workers = DefaultServerOptions().Workers
+	}
+	if options.MaxRequestBodySize <= 0 {
+		options.MaxRequestBodySize = DefaultServerOptions().MaxRequestBodySize
+	}
+	
+	// Create the logger first, so loading the data below can report problems
+	logger, logLevel := newLogger(options)
+	if err := options.Validate(); err != nil {
+		logger.Error("Invalid options, falling back for the invalid settings", "error", err)
+	}
+	
+	// Fall back to JSON access logs with the server logs if the configured ones can't be used
+	accessLog, err := newAccessLog(options, logger)
+	if err != nil {
+		logger.Error("Error setting up the access log, logging requests to the server log", "error", err)
+		options.AccessLogFormat, options.AccessLogFile = AccessLogJSON, ""
+		accessLog, _ = newAccessLog(options, logger)
+	}
+	
+	// Authentication fails closed: an unknown mode is taken as JWT, and without
+	// usable keys JWT authentication stays on and rejects every token
+	options.AuthMode = strings.ToLower(options.AuthMode)
+	if options.AuthMode != "" && options.AuthMode != AuthNone && options.AuthMode != AuthJWT {
+		logger.Error("Unknown auth mode, using JWT authentication", "mode", options.AuthMode)
+		options.AuthMode = AuthJWT
+	}
+	var verifier *jwtVerifier
+	if options.AuthMode == AuthJWT {
+		verifier, err = newJWTVerifier(options)
+		if err != nil {
+			logger.Error("Error setting up JWT authentication, rejecting all tokens", "error", err)
+		}
+	}
+	
+	// Like authentication, the IP filter fails closed if its lists are invalid
+	var filter *ipFilter
+	if len(options.IPAllowList) > 0 || len(options.IPDenyList) > 0 {
+		filter, err = newIPFilter(options.IPAllowList, options.IPDenyList)
+		if err != nil {
+			logger.Error("Error parsing the IP filter, rejecting all requests", "error", err)
+			filter = &ipFilter{closed: true}
+		}
+	}
+	
 	// Create a metrics collector
-	metricsCollector := metrics.NewMetricsCollector(options.MaxConcurrentRequests)
+	metricsCollector := metrics.NewMetricsCollectorWithSamples(options.MaxConcurrentRequests, options.LatencySamples)
 	
-	// Create a name generator with many more workers for extreme concurrency
-	nameGenerator := generator.NewNameGenerator(16) // Increased from 8 to 16 workers
+	// Create a name generator with many more workers for extreme concurrency,
+	// adding and stopping workers with the load if MaxWorkers allows more
+	var poolOptions []workerpool.Option
+	if options.MaxWorkers > options.Workers {
+		poolOptions = append(poolOptions, workerpool.WithAutoscaling(workerpool.AutoscaleConfig{
+			MinWorkers: options.MinWorkers,
+			MaxWorkers: options.MaxWorkers,
+			OnScale: func(event workerpool.ScaleEvent) {
+				logger.Info("Scaled the generator workers", "from", event.From, "to", event.To,
+					"queued", event.Queued, "wait_ms", float64(event.Wait.Microseconds())/1000)
+			},
+		}))
+	}
+	nameGenerator := generator.NewNameGenerator(options.Workers, poolOptions...)
 	
-	// Create a cache with many more shards for extreme concurrency
-	cacheInstance := cache.NewConcurrentLRUCache(
-		options.CacheSize,
-		64, // Significantly increased from 32 to 64 shards for extreme concurrency
-		options.CacheExpiration,
-		options.CacheExpiration/2, // Cleanup at half the expiration time
-	)
+	// Load the name lists supplied by the operator, keeping the built-in lists if that fails
+	if options.NamesDir != "" {
+		if err := nameGenerator.LoadNames(options.NamesDir); err != nil {
+			logger.Error("Error loading names, using built-in names", "dir", options.NamesDir, "error", err)
+		} else {
+			logger.Info("Loaded names", "dir", options.NamesDir)
+		}
+	}
+	
+	// Filter out blocked names; a missing file starts an empty blocklist
+	if options.BlocklistFile != "" {
+		blocked, err := generator.LoadBlocklist(options.BlocklistFile)
+		if err != nil && !os.IsNotExist(err) {
+			logger.Error("Error loading blocklist", "file", options.BlocklistFile, "error", err)
+		}
+		nameGenerator.SetBlocklist(blocked)
+	}
+	
+	// Create the cache backend
+	cacheInstance := newCache(options, logger)
 	
 	// Create a rate limiter
 	// Use a token bucket rate limiter with 30x burst capacity - extreme burst capacity
@@ -112,68 +448,347 @@ func NewServer(options ServerOptions) *Server {
 		nameGenerator: nameGenerator,
 		cache:         cacheInstance,
 		rateLimiter:   compositeLimiter,
+		tokenLimiter:  tokenLimiter,
+		slidingLimiter: slidingLimiter,
+		batchPool:     workerpool.New(8, workerpool.WithQueueTimeout(batchQueueTimeout)),
+		sessions:      session.NewTracker(options.SessionTTL, options.MaxSessions, options.SessionTTL/2,
+			session.WithHistory(options.SessionHistorySize)),
+		logger:        logger,
+		logLevel:      logLevel,
+		accessLog:     accessLog,
+		jwtVerifier:   verifier,
+		ipFilter:      filter,
 		options:       options,
 	}
 	
-	// Get port from environment variable with fallback to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Put a bounded admission queue in front of the rate limiter if enabled
+	if options.AdmissionQueueSize > 0 {
+		server.admissionQueue = ratelimit.NewAdmissionQueue(
+			compositeLimiter,
+			options.AdmissionQueueSize,
+			options.AdmissionQueueTimeout,
+		)
+		server.rateLimiter = server.admissionQueue
+	}
+	
+	// Expose the worker pools, and the scaling of the generator's, on the dashboard
+	registerPoolGauges(metricsCollector, "generator", nameGenerator.Stats)
+	registerPoolGauges(metricsCollector, "batch", server.batchPool.Stats)
+	metricsCollector.RegisterGauge("generator_scale_ups", func() interface{} {
+		up, _ := nameGenerator.ScaleEvents()
+		return up
+	})
+	metricsCollector.RegisterGauge("generator_scale_downs", func() interface{} {
+		_, down := nameGenerator.ScaleEvents()
+		return down
+	})
+	metricsCollector.RegisterGauge("generator_task_panics", func() interface{} {
+		return nameGenerator.Panics()
+	})
+	
+	// Expose the admission queue state on the dashboard
+	metricsCollector.RegisterGauge("queue_depth", func() interface{} {
+		if server.admissionQueue == nil {
+			return int64(0)
+		}
+		return server.admissionQueue.Depth()
+	})
+	metricsCollector.RegisterGauge("queue_capacity", func() interface{} {
+		return options.AdmissionQueueSize
+	})
+	
+	// Count the requests rejected by the IP filter
+	metricsCollector.RegisterGauge("ip_denied", func() interface{} {
+		if server.ipFilter == nil {
+			return uint64(0)
+		}
+		return server.ipFilter.denied.Load()
+	})
+	
+	// Expose the cache usage counters on the dashboard
+	server.registerCacheGauges()
+	metricsCollector.RegisterGauge("sessions", func() interface{} {
+		return server.sessions.Len()
+	})
+	
+	// Publish the main counters for /debug/vars
+	server.vars = server.newVars()
+	
+	// Keep the last requests for /admin/requests and the dashboard
+	server.recentRequests = newRequestRing(options.RecentRequestsSize)
+	
+	// Record admin actions in the audit log
+	server.auditLog = openAuditLog(options, logger)
+	
+	// Push the metrics to StatsD if configured
+	if options.StatsDAddr != "" {
+		interval := options.StatsDInterval
+		if interval <= 0 {
+			interval = DefaultServerOptions().StatsDInterval
+		}
+		server.statsd, err = metrics.NewStatsDEmitter(metricsCollector, options.StatsDAddr, options.StatsDPrefix, interval, options.StatsDTags)
+		if err != nil {
+			logger.Error("Error setting up the StatsD emitter, not pushing metrics", "addr", options.StatsDAddr, "error", err)
+		} else {
+			server.statsd.Start()
+		}
+	}
+	
+	// Push metric snapshots to InfluxDB if configured
+	if options.InfluxURL != "" {
+		server.influx = metrics.NewInfluxEmitter(metricsCollector, metrics.InfluxConfig{
+			URL:         options.InfluxURL,
+			Token:       options.InfluxToken,
+			Measurement: options.InfluxMeasurement,
+			Tags:        influxTags(options.InfluxTags, logger),
+			Interval:    options.InfluxInterval,
+			OnError: func(err error) {
+				logger.Warn("Error pushing metrics to InfluxDB", "error", err)
+			},
+		})
+		server.influx.Start()
+	}
+	
+	// Keep snapshots of the metrics to show their trends
+	if options.HistoryRetention > 0 {
+		server.history = metrics.NewHistory(metricsCollector, options.HistoryInterval, options.HistoryRetention)
+		server.history.Start()
+	}
+	
+	// Raise alerts when the metrics cross their thresholds
+	if rules := alertRules(options.AlertRules, logger); len(rules) > 0 {
+		server.alerter = metrics.NewAlerter(metricsCollector, rules, metrics.AlertConfig{
+			WebhookURL: options.AlertWebhookURL,
+			Format:     options.AlertWebhookFormat,
+			Interval:   options.AlertInterval,
+			Cooldown:   options.AlertCooldown,
+			OnNotify: func(n metrics.AlertNotification) {
+				if n.Status == "resolved" {
+					logger.Info("Alert resolved", "rule", n.Rule, "value", n.Value, "started_at", n.StartedAt)
+				} else {
+					logger.Warn("Alert firing", "rule", n.Rule, "value", n.Value)
+				}
+			},
+			OnError: func(err error) {
+				logger.Warn("Error raising an alert", "error", err)
+			},
+		})
+		server.alerter.Start()
 	}
 	
+	// Pick up the dataset files changed in the names directory while running
+	if options.NamesDir != "" && options.NamesWatchInterval > 0 {
+		server.namesWatcher = generator.NewWatcher(nameGenerator, options.NamesDir, options.NamesWatchInterval, server.namesReloaded)
+		server.namesWatcher.Start()
+	}
+	
+	// Initialize UI templates so the stats handlers can render, with those
+	// supplied by the operator in place of the built-in ones
+	if options.TemplateDir != "" {
+		if files, err := ui.InitializeWithOverrides(options.TemplateDir); err != nil {
+			logger.Error("Error loading templates, using built-in templates", "dir", options.TemplateDir, "error", err)
+		} else {
+			logger.Info("Loaded templates", "dir", options.TemplateDir, "files", files)
+		}
+	} else {
+		ui.Initialize()
+	}
+	
+	// Obtain certificates automatically when TLS domains are configured
+	server.certManager = newCertManager(options, logger)
+	
 	// Create the HTTP server
 	server.httpServer = &http.Server{
-		Addr:         ":" + port,
+		Addr:         ":" + server.listenPort(),
 		Handler:      server.createRouter(),
 		ReadTimeout:  options.ReadTimeout,
 		WriteTimeout: options.WriteTimeout,
 		IdleTimeout:  options.IdleTimeout,
 	}
+	if server.certManager != nil {
+		server.httpServer.TLSConfig = server.certManager.TLSConfig()
+	}
+	
+	// Operational endpoints get a server of their own when an admin address is set
+	if options.AdminAddr != "" {
+		server.adminServer = &http.Server{
+			Addr:         options.AdminAddr,
+			Handler:      server.createAdminRouter(),
+			ReadTimeout:  options.ReadTimeout,
+			WriteTimeout: options.WriteTimeout,
+			IdleTimeout:  options.IdleTimeout,
+		}
+	}
 	
 	return server
 }
 
+// newCache creates the cache backend selected in the options
+func newCache(options ServerOptions, logger *slog.Logger) cache.Cache {
+	switch options.CacheBackend {
+	case "redis":
+		// Share the cache with other replicas through Redis
+		return cache.NewRedisCache(cache.RedisOptions{
+			Addr:              options.RedisAddr,
+			Password:          options.RedisPassword,
+			DB:                options.RedisDB,
+			KeyPrefix:         options.RedisKeyPrefix,
+			DefaultExpiration: options.CacheExpiration,
+			Logger:            logger,
+			Codec: cache.JSONCodec{
+				New: func() interface{} { return new(cachedNames) },
+			},
+		})
+	case "", "memory":
+	default:
+		logger.Error("Unknown cache backend, falling back to memory", "backend", options.CacheBackend)
+	}
+	policy := cache.EvictionPolicy(options.CacheEvictionPolicy)
+	if !policy.Valid() {
+		logger.Error("Unknown cache eviction policy, falling back to lru", "policy", options.CacheEvictionPolicy)
+		policy = cache.EvictionLRU
+	}
+	
+	// Create a cache with many more shards for extreme concurrency
+	return cache.NewConcurrentLRUCache(
+		options.CacheSize,
+		64, // Significantly increased from 32 to 64 shards for extreme concurrency
+		options.CacheExpiration,
+		options.CacheExpiration/2, // Cleanup at half the expiration time
+		cache.WithEvictionPolicy(policy),
+		cache.WithMaxBytes(options.CacheMaxBytes),
+	)
+}
+
+// registerPoolGauges publishes the stats of a worker pool through the metrics
+// collector, under names starting with prefix
+func registerPoolGauges(collector *metrics.MetricsCollector, prefix string, stats func() workerpool.Stats) {
+	collector.RegisterGauge(prefix+"_workers", func() interface{} {
+		return stats().Workers
+	})
+	collector.RegisterGauge(prefix+"_tasks_queued", func() interface{} {
+		return stats().Queued
+	})
+	collector.RegisterGauge(prefix+"_tasks_running", func() interface{} {
+		return stats().Running
+	})
+	collector.RegisterGauge(prefix+"_tasks_completed", func() interface{} {
+		return stats().Completed
+	})
+	collector.RegisterGauge(prefix+"_tasks_failed", func() interface{} {
+		return stats().Failed
+	})
+	collector.RegisterGauge(prefix+"_tasks_rejected", func() interface{} {
+		return stats().Rejected
+	})
+	collector.RegisterGauge(prefix+"_task_duration_avg_ms", func() interface{} {
+		return math.Round(float64(stats().AvgDuration)/float64(time.Millisecond)*1000) / 1000
+	})
+	collector.RegisterGauge(prefix+"_utilization_percent", func() interface{} {
+		return math.Round(stats().Utilization * 1000) / 10
+	})
+}
+
+// registerCacheGauges publishes the cache usage counters through the metrics collector
+func (s *Server) registerCacheGauges() {
+	provider, ok := s.cache.(cache.StatsProvider)
+	if !ok {
+		return
+	}
+	
+	// Reading the stats locks every shard of the memory cache, so all
+	// gauges come from a single reading
+	s.metrics.RegisterGauges(func() map[string]interface{} {
+		stats := provider.Stats()
+		return map[string]interface{}{
+			"cache_entries":   stats.Entries,
+			"cache_capacity":  stats.Capacity,
+			"cache_bytes":     fmt.Sprintf("%.2f MB", float64(stats.Bytes)/1024/1024),
+			"cache_hits":      stats.Hits,
+			"cache_misses":    stats.Misses,
+			"cache_evictions": stats.Evictions,
+			"cache_expired":   stats.Expired,
+			"cache_hit_ratio": fmt.Sprintf("%.2f%%", stats.HitRatio()*100),
+		}
+	})
+}
+
 // createRouter creates the HTTP router for the server
 func (s *Server) createRouter() http.Handler {
-	mux := http.NewServeMux()
+	mux := newRouter()
 	
 	// Register the routes
 	mux.HandleFunc("/generate", s.handleGenerateNames)
-	mux.HandleFunc("/stats", s.handleStats)
-	mux.HandleFunc("/stats/data", s.handleStats)
-	
-	// Create a middleware chain
-	handler := s.metricsMiddleware(
-		s.loggingMiddleware(
-			s.rateLimitMiddleware(
-				mux,
-			),
-		),
-	)
+	mux.HandleFunc("/generate/batch", s.handleGenerateBatch)
+	mux.HandleMethod(http.MethodGet, "/names/{letter}", s.handleNames)
+	mux.HandleMethod(http.MethodGet, "/datasets/stats", s.handleDatasetStats)
+	mux.HandleMethod(http.MethodGet, "/sessions/{id}", s.handleSession)
+	mux.HandleMethod(http.MethodGet, "/sessions/{id}/history", s.handleSessionHistory)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	mux.HandleFunc("/docs", s.handleDocs)
+	mux.HandleMethod(http.MethodGet, "/static/docs/", ui.StaticHandler().ServeHTTP)
+	
+	// Without an admin server the operational endpoints share the port
+	if s.options.AdminAddr == "" {
+		s.registerAdminRoutes(mux)
+	}
 	
-	return handler
+	// Wrap the routes in the middleware chain
+	return Chain(s.middlewareChain()...)(mux)
 }
 
 // metricsMiddleware tracks request metrics
 func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The dashboard's stream stays open as long as the page, so it would
+		// hold up draining and skew the response times
+		if r.URL.Path == "/stats/stream" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		
 		// Record the start of the request
 		done := s.metrics.RecordRequest()
 		
-		// Call the next handler
-		next.ServeHTTP(w, r)
+		// Call the next handler, which can report a failure through the context,
+		// capturing the status code to count it by its class
+		ctx, outcome := withRequestOutcome(r.Context())
+		responseWriter := &responseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+		next.ServeHTTP(responseWriter, r.WithContext(ctx))
 		
-		// Record the end of the request
-		done(nil)
+		// Record the end of the request, as failed if the handler reported an
+		// error or answered with an error status
+		err := outcome.failure()
+		if err == nil {
+			err = statusFailure(responseWriter.statusCode)
+		}
+		done(err)
+		s.metrics.RecordStatus(responseWriter.statusCode)
 	})
 }
 
-// loggingMiddleware logs request information
+// loggingMiddleware gives each request a logger carrying its ID, method and path,
+// and logs the request once it has been answered
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Record the start time
 		start := time.Now()
 		
+		// Tag the request with an ID, which is echoed so clients can refer to it
+		id := requestID(r)
+		w.Header().Set(requestIDHeader, id)
+		ctx, log := withRequestLog(r.Context(), s.logger,
+			"request_id", id,
+			"method", r.Method,
+			"path", r.URL.Path,
+		)
+		r = r.WithContext(ctx)
+		
 		// Create a custom response writer to capture the status code
 		responseWriter := &responseWriter{
 			ResponseWriter: w,
@@ -183,15 +798,10 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 		// Call the next handler
 		next.ServeHTTP(responseWriter, r)
 		
-		// Log the request
-		log.Printf("[%s] %s %s %s %d %s",
-			r.RemoteAddr,
-			r.Method,
-			r.URL.Path,
-			r.Proto,
-			responseWriter.statusCode,
-			time.Since(start),
-		)
+		// Log the request with the fields the handlers added
+		fields := log.snapshot()
+		s.accessLog.write(r, responseWriter, start, fields)
+		s.recordRequest(r, responseWriter, start, fields)
 	})
 }
 
@@ -199,17 +809,23 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Create a context with a timeout - increased to 2 seconds
-		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		// When the admission queue is enabled its own deadline bounds the wait
+		timeout := 2 * time.Second
+		if s.admissionQueue != nil {
+			timeout = s.options.AdmissionQueueTimeout
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
 		defer cancel()
 		
 		// Check the rate limiter
 		if !s.rateLimiter.Allow(ctx) {
 			// Return a more informative error message with retry-after header
 			w.Header().Set("Retry-After", "1") // Suggest client to retry after 1 second
-			http.Error(w, "Rate limit exceeded, please try again later", http.StatusTooManyRequests)
+			writeProblem(w, r, http.StatusTooManyRequests, "Rate limit exceeded, please try again later")
+			failRequest(r, metrics.CategorizeError(metrics.CategoryRateLimited, nil))
 			
 			// Log rate limiting events to help diagnose issues
-			log.Printf("Rate limit exceeded for request from %s to %s", r.RemoteAddr, r.URL.Path)
+			s.requestLogger(r).Warn("Rate limit exceeded", "remote_addr", r.RemoteAddr)
 			return
 		}
 		
@@ -219,90 +835,412 @@ func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
 }
 
 // getCacheKey generates a cache key for the given request
-func getCacheKey(letter string, count int) string {
-	return fmt.Sprintf("%s:%d", letter, count)
+func getCacheKey(letter string, count int, opts generator.Options) string {
+	key := fmt.Sprintf("%s:%s:%d", opts.Locale, letter, count)
+	if opts.Unique {
+		key += ":unique"
+	}
+	if opts.Unweighted {
+		key += ":unweighted"
+	}
+	if opts.Seed != 0 {
+		key += fmt.Sprintf(":%d@%d", opts.Seed, opts.Offset)
+	}
+	return key
 }
 
-// handleGenerateNames handles the name generation request
-func (s *Server) handleGenerateNames(w http.ResponseWriter, r *http.Request) {
-	// Check if the request method is POST
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// cacheBypassed reports whether names are generated without the cache
+// Seeded pages are regenerated cheaply from their seed, and most seeds are
+// drawn by the server for a single request, so caching them would only push
+// the names of unseeded requests out of the cache
+func cacheBypassed(opts generator.Options) bool {
+	return opts.Seed != 0
+}
+
+// cachedNames is the value stored in the cache for a letter and count
+type cachedNames struct {
+	Names      []string  `json:"names"`
+	FreshUntil time.Time `json:"fresh_until"`       // Zero means the names never go stale
+	Unknown    bool      `json:"unknown,omitempty"` // No names match the letter
+}
+
+// stale reports whether the names are past their expiration and should be refreshed
+func (c cachedNames) stale() bool {
+	return !c.FreshUntil.IsZero() && time.Now().After(c.FreshUntil)
+}
+
+// Size returns the approximate size of the names in bytes
+func (c cachedNames) Size() int64 {
+	size := int64(24 + 24) // Slice header and timestamp
+	for _, name := range c.Names {
+		size += int64(16 + len(name))
+	}
+	return size
+}
+
+// lookupNames gets the cached names for a key
+func (s *Server) lookupNames(cacheKey string) (cachedNames, bool) {
+	value, found := s.cache.Get(cacheKey)
+	if !found {
+		return cachedNames{}, false
+	}
+	entry, ok := value.(cachedNames)
+	return entry, ok
+}
+
+// storeNames caches names for the given duration
+// The entry is kept for the stale-while-revalidate grace period after it goes stale
+func (s *Server) storeNames(cacheKey string, names []string, ttl time.Duration) {
+	entry := cachedNames{Names: names}
+	if ttl <= 0 {
+		// Never stale, let the backend apply its default expiration
+		s.cache.Set(cacheKey, entry)
 		return
 	}
+	
+	entry.FreshUntil = time.Now().Add(ttl)
+	s.cache.SetWithExpiration(cacheKey, entry, ttl+s.options.StaleWhileRevalidate)
+}
 
-	// Parse the request body
-	var payload RequestPayload
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&payload); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+// storeUnknown briefly remembers that no names match the letter of a key, so
+// repeated invalid requests stay cheap without pinning them in the cache
+func (s *Server) storeUnknown(cacheKey string) {
+	if s.options.NegativeCacheTTL <= 0 {
 		return
 	}
+	entry := cachedNames{Names: []string{}, Unknown: true, FreshUntil: time.Now().Add(s.options.NegativeCacheTTL)}
+	s.cache.SetWithExpiration(cacheKey, entry, s.options.NegativeCacheTTL)
+}
 
-	// Validate the request payload
-	if payload.SessionID == "" {
-		http.Error(w, "Session ID is required", http.StatusBadRequest)
+// generateNames generates and caches names for a key, with the priority of
+// the generator's tasks
+// Concurrent calls for the same key share a single generation
+func (s *Server) generateNames(cacheKey, letter string, count int, opts generator.Options, priority workerpool.Priority) ([]string, error) {
+	result, err, _ := s.flight.Do(cacheKey, func() (interface{}, error) {
+		// Create a context with a timeout for name generation
+		// It is not tied to a request, since other requests may be waiting for the result
+		ctx, cancel := context.WithTimeout(workerpool.WithPriority(context.Background(), priority), 2*time.Second)
+		defer cancel()
+
+		// Generate names with the context
+		names, err := s.nameGenerator.GenerateWithOptions(ctx, letter, count, opts)
+		if cacheBypassed(opts) {
+			return names, err
+		}
+		if errors.Is(err, generator.ErrUnknownLetter) {
+			s.storeUnknown(cacheKey)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Cache the generated names
+		s.storeNames(cacheKey, names, s.options.CacheExpiration)
+		return names, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]string), nil
+}
+
+// revalidate refreshes stale names in the background, once per key at a time
+// The refresh has a low priority, so it waits while the generator is busy with requests
+func (s *Server) revalidate(cacheKey, letter string, count int, opts generator.Options) {
+	if _, busy := s.revalidating.LoadOrStore(cacheKey, true); busy {
 		return
 	}
 	
-	if payload.NumOfEntries <= 0 {
-		payload.NumOfEntries = 1 // Default to 1 if not specified
-	} else if payload.NumOfEntries > 100 {
-		payload.NumOfEntries = 100 // Limit to 100 to prevent abuse
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		defer s.revalidating.Delete(cacheKey)
+		
+		if _, err := s.generateNames(cacheKey, letter, count, opts, workerpool.PriorityLow); err != nil {
+			s.logger.Error("Error refreshing cached names", "key", cacheKey, "error", err)
+		}
+	}()
+}
+
+// newSeed returns a random non-zero seed for a paged sequence
+func newSeed() int64 {
+	for {
+		if seed := rand.Int63(); seed != 0 {
+			return seed
+		}
+	}
+}
+
+// newResponse builds the response to a generate request
+// Paged responses carry their seed and, unless the sequence ended, a cursor to the next page
+func newResponse(payload RequestPayload, locale string, names []string, paged bool) ResponsePayload {
+	response := ResponsePayload{
+		SessionID:    payload.SessionID,
+		Names:        names,
+		NumOfEntries: len(names),
+		Locale:       locale,
 	}
+	if paged {
+		response.Seed = payload.Seed
+		if len(names) == payload.NumOfEntries {
+			response.NextCursor = encodeCursor(payload.Seed, payload.Offset+len(names))
+		}
+	}
+	return response
+}
 
-	// Generate the cache key
-	cacheKey := getCacheKey(payload.Letter, payload.NumOfEntries)
+// newGroupedResponse builds the response to a generate request with a letter array
+// The names of all groups are also returned together, in the order of the letters
+func newGroupedResponse(payload RequestPayload, locale string, groups []LetterGroup) ResponsePayload {
+	response := ResponsePayload{
+		SessionID: payload.SessionID,
+		Names:     []string{},
+		Locale:    locale,
+		Groups:    groups,
+	}
+	for _, group := range groups {
+		response.Names = append(response.Names, group.Names...)
+	}
+	response.NumOfEntries = len(response.Names)
+	return response
+}
+
+// getNames returns the names for a letter or prefix from the cache, generating them on a miss
+func (s *Server) getNames(query string, count int, opts generator.Options) ([]string, error) {
+	cacheKey := getCacheKey(query, count, opts)
+	if cacheBypassed(opts) {
+		return s.generateNames(cacheKey, query, count, opts, workerpool.PriorityHigh)
+	}
 
 	// Try to get the names from the cache
-	if cachedNames, found := s.cache.Get(cacheKey); found {
-		// Found in cache, return the cached names
-		response := ResponsePayload{
-			SessionID:    payload.SessionID,
-			Names:        cachedNames.([]string),
-			NumOfEntries: len(cachedNames.([]string)),
+	if entry, found := s.lookupNames(cacheKey); found {
+		if entry.Unknown {
+			return nil, generator.ErrUnknownLetter
+		}
+		// Stale names are still served, but refreshed for the next request
+		if entry.stale() {
+			s.revalidate(cacheKey, query, count, opts)
 		}
+		return entry.Names, nil
+	}
 
-		// Set the content type header
-		w.Header().Set("Content-Type", "application/json")
+	// Not found in cache, generate new names ahead of background work
+	return s.generateNames(cacheKey, query, count, opts, workerpool.PriorityHigh)
+}
 
-		// Encode the response
-		encoder := json.NewEncoder(w)
-		if err := encoder.Encode(response); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+// getLetterGroups returns the names for each letter of a letter array
+// Letters without a count of their own get the request's num_of_entries
+func (s *Server) getLetterGroups(payload RequestPayload, opts generator.Options) ([]LetterGroup, error) {
+	groups := make([]LetterGroup, len(payload.Letters))
+	for i, spec := range payload.Letters {
+		count := spec.NumOfEntries
+		if count <= 0 {
+			count = payload.NumOfEntries
+		}
+		
+		names, err := s.getNames(spec.Letter, count, opts)
+		if err != nil {
+			return nil, err
 		}
+		groups[i] = LetterGroup{Letter: spec.Letter, Names: names, NumOfEntries: len(names)}
+	}
+	return groups, nil
+}
+
+// handleGenerateNames handles the name generation request
+func (s *Server) handleGenerateNames(w http.ResponseWriter, r *http.Request) {
+	// Check if the request method is POST
+	if r.Method != http.MethodPost {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	// Not found in cache, generate new names
-	// Create a context with a timeout for name generation
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	// Parse the request body
+	s.limitBody(w, r)
+	payload, err := decodePayload(r)
+	if err != nil {
+		s.writeBodyError(w, r, err, "Invalid request body")
+		return
+	}
 
-	// Generate names with the context
-	names := s.nameGenerator.GenerateWithContext(ctx, payload.Letter, payload.NumOfEntries)
+	addLogFields(r, "session_id", payload.SessionID)
+	s.respondNames(w, r, payload)
+}
 
-	// Cache the generated names
-	s.cache.Set(cacheKey, names)
+// respondNames generates the names of a request and writes them in the format
+// the client asked for
+func (s *Server) respondNames(w http.ResponseWriter, r *http.Request, payload RequestPayload) {
+	// Pick the response format from the format parameter or the Accept header
+	w.Header().Add("Vary", "Accept")
+	format, ok := negotiateFormat(r)
+	if !ok {
+		writeProblem(w, r, http.StatusNotAcceptable, "Supported formats are json, csv, xml, protobuf and ndjson")
+		return
+	}
 
-	// Prepare the response
-	response := ResponsePayload{
-		SessionID:    payload.SessionID,
-		Names:        names,
-		NumOfEntries: len(names),
+	// Large responses can be streamed as they are generated
+	if format.streamed() {
+		s.streamNames(w, r, payload)
+		return
 	}
 
-	// Set the content type header
-	w.Header().Set("Content-Type", "application/json")
+	// Generate the names
+	response, reqErr := s.generate(payload)
+	if reqErr != nil {
+		reqErr.write(w, r)
+		return
+	}
 
 	// Encode the response
-	encoder := json.NewEncoder(w)
-	if err := encoder.Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	if err := writeResponse(w, format, response); err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to encode response")
 		return
 	}
 }
 
+// generateRequest is a validated generate request
+type generateRequest struct {
+	payload RequestPayload // With defaults applied and cursors decoded
+	query   string         // Letter or prefix to generate names for
+	locale  string
+	opts    generator.Options
+	paged   bool // Whether the names are a page of a seeded sequence
+}
+
+// generate validates a generate request and returns the names for it
+func (s *Server) generate(payload RequestPayload) (ResponsePayload, *requestError) {
+	req, reqErr := s.prepare(payload)
+	if reqErr != nil {
+		return ResponsePayload{}, reqErr
+	}
+	s.recordSession(req)
+
+	var response ResponsePayload
+	if len(req.payload.Letters) > 0 {
+		groups, err := s.getLetterGroups(req.payload, req.opts)
+		if err != nil {
+			return ResponsePayload{}, nameError(err)
+		}
+		response = newGroupedResponse(req.payload, req.locale, groups)
+	} else {
+		names, err := s.getNames(req.query, req.payload.NumOfEntries, req.opts)
+		if err != nil {
+			return ResponsePayload{}, nameError(err)
+		}
+		response = newResponse(req.payload, req.locale, names, req.paged)
+	}
+	s.recordResponse(req, response.Names)
+	return response, nil
+}
+
+// nameError returns the response to a request whose names couldn't be generated
+func nameError(err error) *requestError {
+	switch {
+	case errors.Is(err, generator.ErrUnknownLetter):
+		return &requestError{status: http.StatusBadRequest, message: "No names match the requested letter"}
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return &requestError{status: http.StatusRequestTimeout, message: "Name generation timed out"}
+	case errors.Is(err, generator.ErrPoolSaturated):
+		return &requestError{status: http.StatusServiceUnavailable, message: "Server is too busy, please try again later"}
+	}
+	return &requestError{status: http.StatusInternalServerError, message: "Failed to generate names"}
+}
+
+// prepare validates a generate request and applies its defaults
+// All invalid fields are reported at once
+func (s *Server) prepare(payload RequestPayload) (generateRequest, *requestError) {
+	var invalid validationErrors
+	if payload.SessionID == "" {
+		invalid.add("session_id", "session_id is required")
+	}
+	
+	// A missing count defaults to a single name
+	if payload.NumOfEntries == 0 {
+		payload.NumOfEntries = 1
+	} else if payload.NumOfEntries < 0 || payload.NumOfEntries > s.options.MaxEntries {
+		invalid.add("num_of_entries", fmt.Sprintf("num_of_entries must be between 1 and %d", s.options.MaxEntries))
+	}
+
+	// Seeds, offsets and cursors page through a deterministic sequence of names
+	paged := payload.Seed != 0 || payload.Offset != 0 || payload.Cursor != ""
+	if payload.Cursor != "" {
+		if seed, position, err := decodeCursor(payload.Cursor); err != nil {
+			invalid.add("cursor", "cursor is not a next_cursor returned by the server")
+		} else {
+			payload.Seed, payload.Offset = seed, position
+		}
+	}
+	if payload.Offset < 0 {
+		invalid.add("offset", "offset must not be negative")
+	}
+	if paged && payload.Seed == 0 {
+		payload.Seed = newSeed()
+	}
+
+	// Letter arrays are answered with a group of names per letter
+	query := payload.Letter
+	if len(payload.Letters) > 0 {
+		if len(payload.Letters) > maxLettersPerRequest {
+			invalid.add("letter", fmt.Sprintf("letter must list at most %d letters", maxLettersPerRequest))
+		}
+		if payload.Prefix != "" {
+			invalid.add("prefix", "prefix cannot be combined with a letter array")
+		}
+		if paged {
+			invalid.add("letter", "letter arrays cannot be paged with seed, offset or cursor")
+		}
+		for i, spec := range payload.Letters {
+			field := fmt.Sprintf("letter[%d]", i)
+			if spec.Letter == "" || utf8.RuneCountInString(spec.Letter) > maxPrefixLength || !isLetters(spec.Letter) {
+				invalid.add(field, fmt.Sprintf("%s must be 1-%d letters", field, maxPrefixLength))
+			}
+			if spec.NumOfEntries < 0 || spec.NumOfEntries > s.options.MaxEntries {
+				invalid.add(field+".num_of_entries", fmt.Sprintf("%s.num_of_entries must be between 1 and %d", field, s.options.MaxEntries))
+			}
+		}
+	} else if payload.Prefix != "" {
+		// A prefix narrows the names down beyond their first letter
+		if utf8.RuneCountInString(payload.Prefix) > maxPrefixLength {
+			invalid.add("prefix", fmt.Sprintf("prefix must be at most %d characters", maxPrefixLength))
+		}
+		query = payload.Prefix
+	} else if payload.Letter == "" {
+		invalid.add("letter", "letter is required")
+	} else if utf8.RuneCountInString(payload.Letter) != 1 || !isLetters(payload.Letter) {
+		invalid.add("letter", "letter must be a single letter, e.g. A-Z")
+	}
+
+	// Resolve the locale, e.g. "de-DE" to "de"
+	locale, ok := s.nameGenerator.ResolveLocale(payload.Locale)
+	if !ok {
+		invalid.add("locale", fmt.Sprintf("locale %q is not supported", payload.Locale))
+	}
+	if err := invalid.err(); err != nil {
+		return generateRequest{}, err
+	}
+	
+	opts := generator.Options{
+		Locale:     locale,
+		Unique:     payload.Unique,
+		Unweighted: payload.Weighted != nil && !*payload.Weighted,
+		Seed:       payload.Seed,
+		Offset:     payload.Offset,
+	}
+
+	return generateRequest{payload: payload, query: query, locale: locale, opts: opts, paged: paged}, nil
+}
+
+// isLetters reports whether s consists of letters only, in any script
+// Marks are allowed too, for names written with combining characters
+func isLetters(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsMark(r) {
+			return false
+		}
+	}
+	return true
+}
+
 // handleStats handles the statistics display request
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	// Force metrics update before responding
@@ -311,11 +1249,11 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	
 	// Check if this is a request for the HTML page or for the stats data
 	if r.URL.Path == "/stats/data" {
-		// Return just the stats data for HTMX to update
+		// Return just the stats data, rendered as on the dashboard
 		w.Header().Set("Content-Type", "text/html")
 		
-		// Get the stats data
-		metrics := s.metrics.GetCurrentMetrics()
+		// Get the stats data, with the latest requests for the panel
+		metrics := s.dashboardMetrics()
 		
 		// Set cache control headers to prevent caching
 		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
@@ -324,8 +1262,8 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		
 		// Execute the template with the stats data
 		if err := ui.StatsTemplate.ExecuteTemplate(w, "statsData", metrics); err != nil {
-			http.Error(w, "Failed to render stats data", http.StatusInternalServerError)
-			log.Printf("Error rendering stats data: %v", err)
+			writeProblem(w, r, http.StatusInternalServerError, "Failed to render stats data")
+			s.requestLogger(r).Error("Error rendering stats data", "error", err)
 		}
 		return
 	}
@@ -338,46 +1276,167 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Pragma", "no-cache")
 	w.Header().Set("Expires", "0")
 	
-	// Execute the template with the stats data
-	metrics := s.metrics.GetCurrentMetrics()
+	// Execute the template with the stats data, linking the cluster view if there are peers
+	// and showing the admin controls if the admin API is enabled
+	metrics := s.dashboardMetrics()
+	metrics["cluster_peers"] = len(s.options.StatsPeers)
+	metrics["admin_enabled"] = s.adminEnabled()
+	metrics["rate_limit"] = s.tokenLimiter.Rate()
+	metrics["maintenance"] = s.maintenance.Load() != nil
 	if err := ui.StatsTemplate.Execute(w, metrics); err != nil {
-		http.Error(w, "Failed to render stats page", http.StatusInternalServerError)
-		log.Printf("Error rendering stats page: %v", err)
+		writeProblem(w, r, http.StatusInternalServerError, "Failed to render stats page")
+		s.requestLogger(r).Error("Error rendering stats page", "error", err)
 	}
 }
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
-	// Initialize UI templates
-	ui.Initialize()
+	// Under systemd socket activation the sockets are inherited, and systemd keeps
+	// accepting connections on them while the service restarts
+	listeners, adminListener, err := activatedListeners()
+	if err != nil {
+		return err
+	}
+	if len(listeners) > 0 {
+		addresses := make([]string, len(listeners))
+		for i, listener := range listeners {
+			addresses[i] = listener.Addr().String()
+		}
+		s.logger.Info("Starting server with socket activation", "addresses", addresses)
+	} else {
+		listener, err := net.Listen("tcp", s.httpServer.Addr)
+		if err != nil {
+			return err
+		}
+		listeners = []net.Listener{listener}
+		s.logger.Info("Starting server", "port", s.listenPort())
+	}
+	if s.certManager != nil {
+		s.logger.Info("Serving TLS with certificates from ACME", "domains", s.options.TLSDomains)
+	}
 	
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// The admin server listens on its own socket, which may have been passed too
+	if s.adminServer == nil && adminListener != nil {
+		listeners, adminListener = append(listeners, adminListener), nil
+	}
+	if s.adminServer != nil && adminListener == nil {
+		if adminListener, err = net.Listen("tcp", s.adminServer.Addr); err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return err
+		}
 	}
 	
-	log.Printf("Starting server on port %s", port)
-	return s.httpServer.ListenAndServe()
+	// Every socket is served; the first to stop ends Start
+	s.setListeners(listeners)
+	errs := make(chan error, len(listeners)+1)
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			errs <- s.serve(listener)
+		}(listener)
+	}
+	if adminListener != nil {
+		s.setAdminListener(adminListener)
+		s.logger.Info("Starting admin server", "address", adminListener.Addr().String())
+		go func() {
+			errs <- s.adminServer.Serve(adminListener)
+		}()
+	}
+	
+	// After an upgrade, the previous instance can stop now that the sockets are served
+	if parent := upgradedFrom(); parent != 0 {
+		s.logger.Info("Took over the listeners, stopping the previous instance", "pid", parent)
+		if err := notifyUpgradeParent(parent); err != nil {
+			s.logger.Error("Error stopping the previous instance", "pid", parent, "error", err)
+		}
+	}
+	return <-errs
+}
+
+// serve accepts connections on a listener until the server shuts down
+func (s *Server) serve(listener net.Listener) error {
+	if s.certManager != nil {
+		// Certificates come from the TLS config, obtained on the first handshake
+		return s.httpServer.ServeTLS(listener, "", "")
+	}
+	return s.httpServer.Serve(listener)
 }
 
 // Shutdown gracefully shuts down the server
+// It first drains the server: new requests are rejected and /healthz reports
+// draining until the requests in flight have finished, or ctx is done. Only then
+// are the listener, the workers and the cache closed
+// After an Upgrade the listener is closed first instead, as the new instance
+// serves new connections on it
 func (s *Server) Shutdown(ctx context.Context) error {
-	log.Println("Shutting down server...")
+	if s.upgrading.Load() {
+		// The new instance shares the listeners, so they are closed right away
+		// rather than answering 503 to connections it could serve
+		s.logger.Info("Shutting down server after the upgrade",
+			"in_flight", s.metrics.GetCurrentConcurrent())
+	} else {
+		s.logger.Info("Shutting down server, draining requests",
+			"in_flight", s.metrics.GetCurrentConcurrent())
+		s.draining.Store(true)
+		
+		if err := s.drain(ctx); err != nil {
+			s.logger.Warn("Requests still in flight at the shutdown deadline",
+				"in_flight", s.metrics.GetCurrentConcurrent())
+		}
+	}
 
 	// Shutdown the HTTP server
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		return err
 	}
+	
+	// The admin server stays reachable until the requests have drained
+	if s.adminServer != nil {
+		if err := s.adminServer.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Wait for background cache refreshes to finish
+	s.background.Wait()
 
-	// Shutdown the metrics collector
+	// Push the metrics a last time and shutdown the metrics collector
+	if s.alerter != nil {
+		s.alerter.Stop()
+	}
+	if s.history != nil {
+		s.history.Stop()
+	}
+	if s.namesWatcher != nil {
+		s.namesWatcher.Stop()
+	}
+	if s.statsd != nil {
+		s.statsd.Stop()
+	}
+	if s.influx != nil {
+		if err := s.influx.Stop(); err != nil {
+			s.logger.Warn("Error pushing the last metrics to InfluxDB", "error", err)
+		}
+	}
 	s.metrics.Shutdown()
 
-	// Shutdown the name generator
+	// Shutdown the batch workers and the name generator
+	s.batchPool.Shutdown()
 	s.nameGenerator.Shutdown()
 
-	// Shutdown the cache
+	// Shutdown the cache and the session tracker
 	s.cache.Shutdown()
+	s.sessions.Shutdown()
+	
+	// Close the access log and audit log files
+	if err := s.accessLog.Close(); err != nil {
+		s.logger.Error("Error closing access log", "error", err)
+	}
+	if err := s.auditLog.Close(); err != nil {
+		s.logger.Error("Error closing audit log", "error", err)
+	}
 
-	log.Println("Server stopped")
+	s.logger.Info("Server stopped")
 	return nil
 }
@@ -4,10 +4,25 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+	"github.com/amirahmetzanov/go_project/internal/generator"
+	"github.com/amirahmetzanov/go_project/internal/metrics"
+	"github.com/amirahmetzanov/go_project/internal/ui"
+	"github.com/amirahmetzanov/go_project/internal/workerpool"
 )
 
 func TestNewServer(t *testing.T) {
@@ -142,8 +157,8 @@ func TestHandleStats(t *testing.T) {
 	}
 	
 	// Check the content type
-	if contentType := rr.Header().Get("Content-Type"); contentType != "text/plain" {
-		t.Errorf("Handler returned wrong content type: got %v want %v", contentType, "text/plain")
+	if contentType := rr.Header().Get("Content-Type"); contentType != "text/html" {
+		t.Errorf("Handler returned wrong content type: got %v want %v", contentType, "text/html")
 	}
 	
 	// Check that the response contains some stats
@@ -241,3 +256,812 @@ func TestIntegration(t *testing.T) {
 		t.Errorf("Expected status OK or TooManyRequests, got %v", resp.Status)
 	}
 }
+
+func TestAdmissionQueueOverflow(t *testing.T) {
+	// Create a server with a tiny rate limit and a single queue slot
+	options := DefaultServerOptions()
+	options.RequestRateLimit = 1
+	options.AdmissionQueueSize = 1
+	options.AdmissionQueueTimeout = 200 * time.Millisecond
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	// Drain the limiter so that every further request has to queue
+	for server.rateLimiter.TryAllow() {
+	}
+	
+	handler := server.createRouter()
+	
+	// Occupy the only queue slot with a request that waits for capacity
+	queued := make(chan int, 1)
+	go func() {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stats/data", nil))
+		queued <- rr.Code
+	}()
+	
+	// Wait for the request to enter the queue
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for server.admissionQueue.Depth() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	
+	// The queue depth should be visible in the metrics
+	if depth := server.metrics.GetCurrentMetrics()["queue_depth"]; depth != int64(1) {
+		t.Errorf("Expected queue_depth to be 1, got %v", depth)
+	}
+	
+	// A request arriving while the queue is full is rejected straight away
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stats/data", nil))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d for queue overflow, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	
+	// The queued request gives up once its deadline passes
+	if code := <-queued; code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d for timed out request, got %d", http.StatusTooManyRequests, code)
+	}
+	
+	// Both rejections are counted as 429s and as 4xx responses
+	if server.metrics.GetTooManyRequests() != 2 || server.metrics.GetStatusClassCount(4) != 2 {
+		t.Errorf("Expected 2 responses with status 429, got %d (%d 4xx)", server.metrics.GetTooManyRequests(), server.metrics.GetStatusClassCount(4))
+	}
+	if failed := server.metrics.GetFailureCount(metrics.CategoryRateLimited); failed != 2 {
+		t.Errorf("Expected 2 rate limited failures, got %d", failed)
+	}
+}
+
+func TestStatsDPush(t *testing.T) {
+	// A UDP socket stands in for the StatsD server
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+	
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.StatsDAddr = conn.LocalAddr().String()
+	options.StatsDInterval = time.Hour
+	server := NewServer(options)
+	server.createRouter().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/names/A", nil))
+	
+	// The metrics are pushed a last time on shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	
+	var received []string
+	buf := make([]byte, 65536)
+	for {
+		conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err != nil {
+			break
+		}
+		received = append(received, string(buf[:n]))
+	}
+	pushed := strings.Join(received, "\n")
+	for _, expected := range []string{"namegen.requests_total:1|c", "namegen.responses_2xx:1|c", "namegen.response_time:"} {
+		if !strings.Contains(pushed, expected) {
+			t.Errorf("Expected %s to be pushed, got:\n%s", expected, pushed)
+		}
+	}
+}
+
+func TestInfluxPush(t *testing.T) {
+	// Keep the points written to a stand-in InfluxDB
+	var mutex sync.Mutex
+	var written []string
+	influx := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		written = append(written, r.Header.Get("Authorization")+" "+string(body))
+		mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer influx.Close()
+	
+	options := DefaultServerOptions()
+	logs := &logBuffer{}
+	options.LogOutput = logs
+	options.InfluxURL = influx.URL + "/api/v2/write?org=acme&bucket=namegen"
+	options.InfluxToken = "secret"
+	options.InfluxInterval = time.Hour
+	options.InfluxTags = []string{"host=web-1", "invalid"}
+	server := NewServer(options)
+	server.createRouter().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/names/A", nil))
+	
+	// A last snapshot is pushed on shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(written) != 1 || !strings.HasPrefix(written[0], "Token secret namegen,host=web-1 ") || !strings.Contains(written[0], "requests_total=1u") {
+		t.Errorf("Expected a point with the request, got %q", written)
+	}
+	if len(logs.entries(t, "Ignoring InfluxDB tag, expected key=value")) != 1 {
+		t.Error("Expected the invalid tag to be reported")
+	}
+}
+
+func TestAlertWebhook(t *testing.T) {
+	// Keep the alerts posted to a stand-in webhook
+	posted := make(chan string, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		posted <- string(body)
+	}))
+	defer webhook.Close()
+	
+	options := DefaultServerOptions()
+	logs := &logBuffer{}
+	options.LogOutput = logs
+	options.AlertRules = []string{"requests_total>0", "p99"}
+	options.AlertWebhookURL = webhook.URL
+	options.AlertWebhookFormat = "slack"
+	options.AlertInterval = 10 * time.Millisecond
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	server.createRouter().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/names/A", nil))
+	
+	// The rule fires once the request is counted
+	select {
+	case body := <-posted:
+		if body != "{\"text\":\"[FIRING] requests_total>0, now 1.00\"}\n" {
+			t.Errorf("Expected a Slack message for the rule, got %q", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected an alert to be posted")
+	}
+	if len(logs.entries(t, "Ignoring invalid alert rule")) != 1 {
+		t.Error("Expected the invalid rule to be reported")
+	}
+	if len(logs.entries(t, "Alert firing")) != 1 {
+		t.Error("Expected the alert to be logged")
+	}
+}
+
+func TestStatusClassMetrics(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	handler := server.createRouter()
+	
+	// Answered requests are counted by the class of their status code
+	for _, path := range []string{"/names/A", "/names/B", "/names/1", "/no-such-path"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	metrics := server.metrics.GetCurrentMetrics()
+	if metrics["responses_2xx"] != uint64(2) || metrics["responses_4xx"] != uint64(2) || metrics["responses_5xx"] != uint64(0) {
+		t.Errorf("Expected 2 2xx and 2 4xx responses, got %v, %v and %v", metrics["responses_2xx"], metrics["responses_4xx"], metrics["responses_5xx"])
+	}
+}
+
+func TestFailureCategories(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	handler := server.createRouter()
+	
+	// Invalid requests fail as validation errors
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/generate", strings.NewReader(`{"letter":"AB","num_of_entries":-1}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/names/A", nil))
+	
+	// Requests answered with an error status fail even if the handler reported nothing
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/no-such-path", nil))
+	
+	metrics := server.metrics.GetCurrentMetrics()
+	if metrics["requests_failed"] != uint64(2) || metrics["requests_failed_validation"] != uint64(2) || metrics["requests_failed_internal"] != uint64(0) {
+		t.Errorf("Expected 2 validation failures, got %v failed, %v validation, %v internal", metrics["requests_failed"], metrics["requests_failed_validation"], metrics["requests_failed_internal"])
+	}
+	if metrics["requests_succeeded"] != uint64(1) {
+		t.Errorf("Expected 1 request to succeed, got %v", metrics["requests_succeeded"])
+	}
+}
+
+func TestNewCacheBackend(t *testing.T) {
+	// The memory backend is the default
+	options := DefaultServerOptions()
+	memory := newCache(options, slog.Default())
+	defer memory.Shutdown()
+	if _, ok := memory.(*cache.ConcurrentLRUCache); !ok {
+		t.Errorf("Expected memory backend to be a ConcurrentLRUCache, got %T", memory)
+	}
+	
+	// The redis backend is selected through the options
+	options.CacheBackend = "redis"
+	redis := newCache(options, slog.Default())
+	defer redis.Shutdown()
+	if _, ok := redis.(*cache.RedisCache); !ok {
+		t.Errorf("Expected redis backend to be a RedisCache, got %T", redis)
+	}
+}
+
+func TestCacheMetrics(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	// Send the same request twice: one miss followed by one hit
+	payload := []byte(`{"session_id":"test-session","letter":"C","num_of_entries":3}`)
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", bytes.NewReader(payload)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status OK, got %d", rr.Code)
+		}
+	}
+	
+	// The cache counters should be surfaced through the metrics collector
+	metrics := server.metrics.GetCurrentMetrics()
+	if hits := metrics["cache_hits"]; hits != uint64(1) {
+		t.Errorf("Expected 1 cache hit, got %v", hits)
+	}
+	if misses := metrics["cache_misses"]; misses != uint64(1) {
+		t.Errorf("Expected 1 cache miss, got %v", misses)
+	}
+	if ratio := metrics["cache_hit_ratio"]; ratio != "50.00%" {
+		t.Errorf("Expected cache hit ratio of 50.00%%, got %v", ratio)
+	}
+	if _, ok := metrics["cache_bytes"].(string); !ok {
+		t.Errorf("Expected cache size to be reported, got %v", metrics["cache_bytes"])
+	}
+}
+
+func TestGenerateNamesCoalescing(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	// Fire many concurrent requests that miss the cache for the same key
+	payload := []byte(`{"session_id":"test-session","letter":"D","num_of_entries":20}`)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", bytes.NewReader(payload)))
+			if rr.Code != http.StatusOK {
+				t.Errorf("Expected status OK, got %d", rr.Code)
+			}
+		}()
+	}
+	wg.Wait()
+	
+	// Every request got names, and all of them ended up under a single cache key
+	stats := server.cache.(cache.StatsProvider).Stats()
+	if stats.Hits+stats.Misses != 50 {
+		t.Errorf("Expected 50 lookups, got %d", stats.Hits+stats.Misses)
+	}
+	if stats.Entries != 1 {
+		t.Errorf("Expected 1 cache entry, got %d", stats.Entries)
+	}
+}
+
+func TestNegativeCaching(t *testing.T) {
+	options := DefaultServerOptions()
+	options.NegativeCacheTTL = 50 * time.Millisecond
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	generate := func(letter string) int {
+		payload := []byte(`{"session_id":"test-session","letter":"` + letter + `","num_of_entries":5}`)
+		rr := httptest.NewRecorder()
+		server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", bytes.NewReader(payload)))
+		return rr.Code
+	}
+	
+	// A letter without names is a bad request, and the result is cached
+	if code := generate("Ø"); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a letter without names, got %d", code)
+	}
+	if _, found := server.cache.Get(getCacheKey("Ø", 5, generator.Options{Locale: "en"})); !found {
+		t.Error("Expected the empty result to be cached")
+	}
+	if code := generate("Ø"); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a cached letter without names, got %d", code)
+	}
+	
+	// The empty result expires after the negative cache TTL
+	time.Sleep(100 * time.Millisecond)
+	if _, found := server.cache.Get(getCacheKey("Ø", 5, generator.Options{Locale: "en"})); found {
+		t.Error("Expected the empty result to expire after the negative cache TTL")
+	}
+	
+	// Negative caching can be disabled
+	server.options.NegativeCacheTTL = 0
+	generate("Ω")
+	if _, found := server.cache.Get(getCacheKey("Ω", 5, generator.Options{Locale: "en"})); found {
+		t.Error("Expected the empty result not to be cached")
+	}
+}
+
+func TestNameError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{generator.ErrUnknownLetter, http.StatusBadRequest},
+		{context.DeadlineExceeded, http.StatusRequestTimeout},
+		{context.Canceled, http.StatusRequestTimeout},
+		{fmt.Errorf("%w: %w", generator.ErrPoolSaturated, workerpool.ErrQueueFull), http.StatusServiceUnavailable},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := nameError(tt.err).status; got != tt.want {
+			t.Errorf("nameError(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	options := DefaultServerOptions()
+	options.CacheExpiration = 50 * time.Millisecond
+	options.StaleWhileRevalidate = time.Second
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	generate := func() {
+		payload := []byte(`{"session_id":"test-session","letter":"E","num_of_entries":3}`)
+		rr := httptest.NewRecorder()
+		server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", bytes.NewReader(payload)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status OK, got %d", rr.Code)
+		}
+	}
+	cacheKey := getCacheKey("E", 3, generator.Options{Locale: "en"})
+	
+	// The first request fills the cache
+	generate()
+	first, found := server.lookupNames(cacheKey)
+	if !found || first.stale() {
+		t.Fatalf("Expected fresh names to be cached, got %+v (found: %v)", first, found)
+	}
+	
+	// After the expiration the stale names are still served from the cache
+	time.Sleep(80 * time.Millisecond)
+	if entry, found := server.lookupNames(cacheKey); !found || !entry.stale() {
+		t.Fatalf("Expected stale names to be kept during the grace period, got %+v (found: %v)", entry, found)
+	}
+	generate()
+	
+	// ... and refreshed in the background
+	server.background.Wait()
+	refreshed, found := server.lookupNames(cacheKey)
+	if !found || refreshed.stale() || !refreshed.FreshUntil.After(first.FreshUntil) {
+		t.Errorf("Expected the names to be refreshed, got %+v (found: %v)", refreshed, found)
+	}
+	
+	// Without a grace period expired names are not served
+	server.options.StaleWhileRevalidate = 0
+	server.storeNames(cacheKey, []string{"Emma"}, 20*time.Millisecond)
+	time.Sleep(40 * time.Millisecond)
+	if _, found := server.lookupNames(cacheKey); found {
+		t.Error("Expected expired names to be dropped without a grace period")
+	}
+}
+
+func TestGenerateNamesLocale(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	// A Turkish request for dotted i returns names starting with İ
+	payload := []byte(`{"session_id":"test-session","letter":"i","num_of_entries":3,"locale":"tr-TR"}`)
+	rr := httptest.NewRecorder()
+	server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", bytes.NewReader(payload)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d", rr.Code)
+	}
+	var response ResponsePayload
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if response.Locale != "tr" || len(response.Names) != 3 {
+		t.Errorf("Expected 3 tr names, got %+v", response)
+	}
+	for _, name := range response.Names {
+		if !strings.HasPrefix(name, "İ") {
+			t.Errorf("Expected name starting with İ, got %q", name)
+		}
+	}
+	
+	// Names of different locales are cached separately
+	if _, found := server.cache.Get(getCacheKey("i", 3, generator.Options{Locale: "tr"})); !found {
+		t.Error("Expected the names to be cached under the tr locale")
+	}
+	
+	// Unsupported locales are rejected
+	payload = []byte(`{"session_id":"test-session","letter":"A","num_of_entries":3,"locale":"xx"}`)
+	rr = httptest.NewRecorder()
+	server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", bytes.NewReader(payload)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest, got %d", rr.Code)
+	}
+}
+
+func TestGenerateNamesUnique(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	// Ask for more unique names than the letter has
+	payload := []byte(`{"session_id":"test-session","letter":"F","num_of_entries":30,"unique":true}`)
+	rr := httptest.NewRecorder()
+	server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", bytes.NewReader(payload)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d", rr.Code)
+	}
+	var response ResponsePayload
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	
+	// The response is truncated to the available names, without duplicates
+	if response.NumOfEntries != 20 || len(response.Names) != 20 {
+		t.Errorf("Expected 20 names, got %d (num_of_entries %d)", len(response.Names), response.NumOfEntries)
+	}
+	seen := make(map[string]bool)
+	for _, name := range response.Names {
+		if seen[name] {
+			t.Errorf("Duplicate name %q in unique response", name)
+		}
+		seen[name] = true
+	}
+	
+	// Unique and regular names are cached separately
+	if _, found := server.cache.Get(getCacheKey("F", 30, generator.Options{Locale: "en", Unique: true})); !found {
+		t.Error("Expected the unique names to be cached")
+	}
+	if _, found := server.cache.Get(getCacheKey("F", 30, generator.Options{Locale: "en"})); found {
+		t.Error("Expected no regular names to be cached")
+	}
+}
+
+func TestGenerateNamesUnweighted(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	payload := []byte(`{"session_id":"test-session","letter":"F","num_of_entries":3,"weighted":false}`)
+	rr := httptest.NewRecorder()
+	server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", bytes.NewReader(payload)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d", rr.Code)
+	}
+	
+	// Unweighted and weighted names are cached separately
+	if _, found := server.cache.Get(getCacheKey("F", 3, generator.Options{Locale: "en", Unweighted: true})); !found {
+		t.Error("Expected the unweighted names to be cached")
+	}
+	if _, found := server.cache.Get(getCacheKey("F", 3, generator.Options{Locale: "en"})); found {
+		t.Error("Expected no weighted names to be cached")
+	}
+}
+
+func TestGenerateNamesPrefix(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	// The prefix overrides the letter
+	payload := []byte(`{"session_id":"test-session","letter":"A","prefix":"Mar","num_of_entries":10,"unique":true}`)
+	rr := httptest.NewRecorder()
+	server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", bytes.NewReader(payload)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d", rr.Code)
+	}
+	var response ResponsePayload
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if len(response.Names) != 4 {
+		t.Errorf("Expected the 4 names starting with Mar, got %v", response.Names)
+	}
+	for _, name := range response.Names {
+		if !strings.HasPrefix(name, "Mar") {
+			t.Errorf("Expected name starting with Mar, got %q", name)
+		}
+	}
+	if _, found := server.cache.Get(getCacheKey("Mar", 10, generator.Options{Locale: "en", Unique: true})); !found {
+		t.Error("Expected the names to be cached under the prefix")
+	}
+	
+	// Overly long prefixes are rejected
+	payload = []byte(`{"session_id":"test-session","prefix":"` + strings.Repeat("a", maxPrefixLength+1) + `"}`)
+	rr = httptest.NewRecorder()
+	server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", bytes.NewReader(payload)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest, got %d", rr.Code)
+	}
+}
+
+func TestGenerateNamesPaging(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	generate := func(body string) (*httptest.ResponseRecorder, ResponsePayload) {
+		rr := httptest.NewRecorder()
+		server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", strings.NewReader(body)))
+		var response ResponsePayload
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatalf("Error parsing response: %v", err)
+			}
+		}
+		return rr, response
+	}
+	
+	// Seeded requests may ask for more than 100 names
+	_, all := generate(`{"session_id":"s","letter":"C","num_of_entries":300,"seed":5}`)
+	if len(all.Names) != 300 || all.Seed != 5 || all.NextCursor == "" {
+		t.Fatalf("Expected 300 names with seed 5 and a cursor, got %d names, seed %d, cursor %q", len(all.Names), all.Seed, all.NextCursor)
+	}
+	
+	// Following the cursors returns the same sequence
+	_, page := generate(`{"session_id":"s","letter":"C","num_of_entries":100,"seed":5}`)
+	names := page.Names
+	for len(names) < 300 {
+		_, page = generate(`{"session_id":"s","letter":"C","num_of_entries":100,"cursor":"` + page.NextCursor + `"}`)
+		if len(page.Names) == 0 {
+			t.Fatal("Expected another page")
+		}
+		names = append(names, page.Names...)
+	}
+	if strings.Join(names, ",") != strings.Join(all.Names, ",") {
+		t.Errorf("Expected the pages to match the sequence")
+	}
+	
+	// Pages are regenerated from the seed rather than cached
+	if count := server.cache.Count(); count != 0 {
+		t.Errorf("Expected no seeded pages in the cache, got %d entries", count)
+	}
+	
+	// An offset without a seed starts a new sequence
+	if _, response := generate(`{"session_id":"s","letter":"C","num_of_entries":5,"offset":10}`); response.Seed == 0 || response.NextCursor == "" {
+		t.Errorf("Expected a random seed and a cursor, got %+v", response)
+	}
+	
+	// The last page of a unique sequence has no cursor
+	_, response := generate(`{"session_id":"s","letter":"D","num_of_entries":15,"seed":3,"unique":true}`)
+	_, response = generate(`{"session_id":"s","letter":"D","num_of_entries":15,"unique":true,"cursor":"` + response.NextCursor + `"}`)
+	if len(response.Names) != 5 || response.NextCursor != "" {
+		t.Errorf("Expected the last 5 names without a cursor, got %+v", response)
+	}
+	
+	// Invalid cursors and offsets are rejected
+	if rr, _ := generate(`{"session_id":"s","letter":"C","cursor":"bogus"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest for an invalid cursor, got %d", rr.Code)
+	}
+	if rr, _ := generate(`{"session_id":"s","letter":"C","offset":-1}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest for a negative offset, got %d", rr.Code)
+	}
+}
+
+func TestGenerateNamesLetterArray(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	// Letters can be plain or carry their own count, and prefixes work too
+	payload := []byte(`{"session_id":"test-session","letter":["A",{"letter":"B","num_of_entries":4},"Ma"],"num_of_entries":2}`)
+	rr := httptest.NewRecorder()
+	server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", bytes.NewReader(payload)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", rr.Code, rr.Body)
+	}
+	var response ResponsePayload
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	
+	expected := []struct {
+		letter string
+		count  int
+	}{{"A", 2}, {"B", 4}, {"Ma", 2}}
+	if len(response.Groups) != len(expected) {
+		t.Fatalf("Expected %d groups, got %+v", len(expected), response.Groups)
+	}
+	for i, want := range expected {
+		group := response.Groups[i]
+		if group.Letter != want.letter || group.NumOfEntries != want.count || len(group.Names) != want.count {
+			t.Errorf("Expected %d names for %s, got %+v", want.count, want.letter, group)
+		}
+		for _, name := range group.Names {
+			if !strings.HasPrefix(name, want.letter) {
+				t.Errorf("Expected name starting with %s, got %q", want.letter, name)
+			}
+		}
+	}
+	
+	// All names are also returned together
+	if response.NumOfEntries != 8 || len(response.Names) != 8 || response.Names[2] != response.Groups[1].Names[0] {
+		t.Errorf("Expected the 8 names of all groups, got %+v", response)
+	}
+	
+	// Invalid letter arrays are rejected
+	for _, body := range []string{
+		`{"session_id":"s","letter":[""]}`,
+		`{"session_id":"s","letter":[1]}`,
+		`{"session_id":"s","letter":["A","B"],"prefix":"Ma"}`,
+		`{"session_id":"s","letter":["A","B"],"seed":1}`,
+		`{"session_id":"s","letter":[` + strings.Repeat(`"A",`, maxLettersPerRequest) + `"B"]}`,
+	} {
+		rr := httptest.NewRecorder()
+		server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status BadRequest for %s, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestTemplateDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "stats_data.tmpl"), []byte(`<p>ACME names</p>`), 0o644); err != nil {
+		t.Fatalf("Failed to write the template: %v", err)
+	}
+	
+	logs := &logBuffer{}
+	options := DefaultServerOptions()
+	options.TemplateDir = dir
+	options.LogOutput = logs
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+		ui.Initialize()
+	}()
+	if entries := logs.entries(t, "Loaded templates"); len(entries) != 1 {
+		t.Errorf("Expected the templates to be logged, got %v", entries)
+	}
+	
+	// The dashboard renders the operator's template
+	rr := httptest.NewRecorder()
+	server.createRouter().ServeHTTP(rr, httptest.NewRequest("GET", "/stats/data", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "<p>ACME names</p>") {
+		t.Errorf("Expected the operator's template, got %d %s", rr.Code, rr.Body.String())
+	}
+	
+	// A template that doesn't parse leaves the built-in ones in place
+	if err := os.WriteFile(filepath.Join(dir, "stats_data.tmpl"), []byte(`{{end}}`), 0o644); err != nil {
+		t.Fatalf("Failed to write the template: %v", err)
+	}
+	logs = &logBuffer{}
+	options.LogOutput = logs
+	broken := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		broken.Shutdown(ctx)
+	}()
+	if entries := logs.entries(t, "Error loading templates, using built-in templates"); len(entries) != 1 {
+		t.Errorf("Expected the error to be logged, got %v", entries)
+	}
+	rr = httptest.NewRecorder()
+	broken.createRouter().ServeHTTP(rr, httptest.NewRequest("GET", "/stats/data", nil))
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "ACME") {
+		t.Errorf("Expected the built-in template, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGeneratorAutoscaling(t *testing.T) {
+	options := DefaultServerOptions()
+	options.Workers = 4
+	options.MinWorkers = 2
+	options.MaxWorkers = 32
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	// The generator starts with Workers, and its scaling is on the dashboard
+	metrics := server.metrics.GetCurrentMetrics()
+	if metrics["generator_workers"] != 4 || metrics["generator_scale_ups"] != uint64(0) || metrics["generator_scale_downs"] != uint64(0) {
+		t.Errorf("Expected 4 workers that haven't scaled, got %v %v %v", metrics["generator_workers"], metrics["generator_scale_ups"], metrics["generator_scale_downs"])
+	}
+	if metrics["generator_task_panics"] != uint64(0) {
+		t.Errorf("Expected no panicked tasks, got %v", metrics["generator_task_panics"])
+	}
+	
+	// Without MaxWorkers above Workers, the generator keeps its workers
+	fixed := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		fixed.Shutdown(ctx)
+	}()
+	if workers := fixed.nameGenerator.Workers(); workers != 16 {
+		t.Errorf("Expected 16 fixed workers, got %d", workers)
+	}
+}
+
+func TestWorkerPoolGauges(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	
+	rr := httptest.NewRecorder()
+	server.handleGenerateNames(rr, httptest.NewRequest("POST", "/generate", strings.NewReader(`{"session_id":"123-456","letter":"A","num_of_entries":5}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d %s", rr.Code, rr.Body.String())
+	}
+	
+	// The generator ran a task per name, and both pools are on the dashboard
+	metrics := server.metrics.GetCurrentMetrics()
+	if completed, ok := metrics["generator_tasks_completed"].(uint64); !ok || completed < 5 {
+		t.Errorf("Expected at least 5 completed generator tasks, got %v", metrics["generator_tasks_completed"])
+	}
+	if metrics["generator_tasks_failed"] != uint64(0) || metrics["generator_tasks_running"] != 0 {
+		t.Errorf("Expected no failed or running generator tasks, got %v and %v", metrics["generator_tasks_failed"], metrics["generator_tasks_running"])
+	}
+	for _, name := range []string{"generator_task_duration_avg_ms", "generator_utilization_percent", "batch_workers", "batch_tasks_queued", "batch_tasks_completed", "batch_utilization_percent"} {
+		if _, ok := metrics[name]; !ok {
+			t.Errorf("Expected the gauge %s", name)
+		}
+	}
+	if metrics["batch_workers"] != 8 {
+		t.Errorf("Expected 8 batch workers, got %v", metrics["batch_workers"])
+	}
+}
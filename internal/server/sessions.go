@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/session"
+)
+
+// SessionHistoryResponse lists the recent responses of a session, oldest first
+type SessionHistoryResponse struct {
+	SessionID string             `json:"session_id"`
+	Responses []session.Response `json:"responses"`
+}
+
+// recordSession counts a validated generate request towards its session
+func (s *Server) recordSession(req generateRequest) {
+	s.sessions.Record(req.payload.SessionID, requestedLetters(req)...)
+}
+
+// recordResponse keeps the names given for a request in its session's history
+func (s *Server) recordResponse(req generateRequest, names []string) {
+	s.sessions.AddResponse(req.payload.SessionID, session.Response{
+		Time:    time.Now(),
+		Letters: requestedLetters(req),
+		Locale:  req.locale,
+		Names:   names,
+	})
+}
+
+// requestedLetters returns the letter, prefix or letters a request asked for
+func requestedLetters(req generateRequest) []string {
+	if len(req.payload.Letters) == 0 {
+		return []string{req.query}
+	}
+	letters := make([]string, len(req.payload.Letters))
+	for i, spec := range req.payload.Letters {
+		letters[i] = spec.Letter
+	}
+	return letters
+}
+
+// handleSession returns the usage summary of a session: GET /sessions/{id}
+// Sessions are forgotten SessionTTL after their last request
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	id := pathParam(r, "id")
+	summary, ok := s.sessions.Get(id)
+	if !ok {
+		writeProblem(w, r, http.StatusNotFound, "Session not found or expired")
+		return
+	}
+	addLogFields(r, "session_id", id)
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// handleSessionHistory returns the names recently given to a session, e.g. for
+// a client that lost them: GET /sessions/{id}/history
+// Only the last SessionHistorySize responses are kept
+func (s *Server) handleSessionHistory(w http.ResponseWriter, r *http.Request) {
+	id := pathParam(r, "id")
+	history, ok := s.sessions.History(id)
+	if !ok {
+		writeProblem(w, r, http.StatusNotFound, "Session not found or expired")
+		return
+	}
+	addLogFields(r, "session_id", id)
+	writeJSON(w, http.StatusOK, SessionHistoryResponse{SessionID: id, Responses: history})
+}
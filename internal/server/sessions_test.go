@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/session"
+)
+
+func TestHandleSession(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	request := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Every way of generating names counts towards the session
+	request(http.MethodPost, "/generate", `{"session_id": "s1", "letter": "A", "num_of_entries": 2}`)
+	request(http.MethodPost, "/generate", `{"session_id": "s1", "prefix": "ma"}`)
+	request(http.MethodPost, "/generate", `{"session_id": "s1", "letter": ["A", "B"]}`)
+	request(http.MethodGet, "/names/C?session_id=s1", "")
+	request(http.MethodGet, "/names/C?session_id=s1&format=ndjson", "")
+	request(http.MethodPost, "/generate/batch", `[{"session_id": "s1", "letter": "D"}, {"session_id": "s2", "letter": "E"}]`)
+
+	// Invalid requests don't
+	request(http.MethodPost, "/generate", `{"session_id": "s1", "letter": "A", "num_of_entries": -1}`)
+
+	rr := request(http.MethodGet, "/sessions/s1", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v: %s", rr.Code, rr.Body)
+	}
+	var summary session.Session
+	if err := json.NewDecoder(rr.Body).Decode(&summary); err != nil {
+		t.Fatalf("Error parsing the session: %v", err)
+	}
+	if summary.ID != "s1" || summary.Requests != 6 {
+		t.Errorf("Expected 6 requests for s1, got %+v", summary)
+	}
+	expected := map[string]int64{"A": 2, "B": 1, "C": 2, "D": 1, "M": 1}
+	if !reflect.DeepEqual(summary.Letters, expected) {
+		t.Errorf("Expected letters %v, got %v", expected, summary.Letters)
+	}
+	if summary.LastSeen.IsZero() || !summary.ExpiresAt.Equal(summary.LastSeen.Add(options.SessionTTL)) {
+		t.Errorf("Expected the session to expire %v after its last request, got %+v", options.SessionTTL, summary)
+	}
+
+	if rr := request(http.MethodGet, "/sessions/unknown", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown session, got %v", rr.Code)
+	}
+	if rr := request(http.MethodDelete, "/sessions/s1", ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for DELETE, got %v", rr.Code)
+	}
+}
+
+func TestSessionTTL(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.SessionTTL = 50 * time.Millisecond
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/names/A?session_id=short", nil))
+	time.Sleep(100 * time.Millisecond)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/sessions/short", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected the session to have expired, got %v", rr.Code)
+	}
+}
+
+func TestHandleSessionHistory(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.SessionHistorySize = 2
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+	get("/names/A?session_id=s1&count=3")
+	streamed := get("/names/B?session_id=s1&count=2&format=ndjson").Body.String()
+	third := get("/names/C?session_id=s1&count=4&locale=de")
+	var last ResponsePayload
+	json.NewDecoder(third.Body).Decode(&last)
+
+	rr := get("/sessions/s1/history")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v: %s", rr.Code, rr.Body)
+	}
+	var history SessionHistoryResponse
+	if err := json.NewDecoder(rr.Body).Decode(&history); err != nil {
+		t.Fatalf("Error parsing the history: %v", err)
+	}
+
+	// Only the last two responses are kept, oldest first
+	if history.SessionID != "s1" || len(history.Responses) != 2 {
+		t.Fatalf("Expected 2 responses of s1, got %+v", history)
+	}
+	first, second := history.Responses[0], history.Responses[1]
+	if !reflect.DeepEqual(first.Letters, []string{"B"}) || len(first.Names) != 2 {
+		t.Errorf("Expected the streamed response first, got %+v", first)
+	}
+	for _, name := range first.Names {
+		if !strings.Contains(streamed, name) {
+			t.Errorf("Expected %s to have been streamed, got %s", name, streamed)
+		}
+	}
+	if !reflect.DeepEqual(second.Names, last.Names) || second.Locale != "de" || second.Time.IsZero() {
+		t.Errorf("Expected the last response %v, got %+v", last.Names, second)
+	}
+
+	if rr := get("/sessions/unknown/history"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown session, got %v", rr.Code)
+	}
+}
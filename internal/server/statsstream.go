@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+)
+
+const (
+	// statsStreamInterval is how often /stats/stream looks for changed metrics
+	statsStreamInterval = time.Second
+
+	// statsStreamHeartbeat is the longest /stats/stream stays silent; proxies
+	// tend to close connections that are idle for longer
+	statsStreamHeartbeat = 15 * time.Second
+)
+
+// CacheShard is the occupancy of a cache shard, as shown on the dashboard
+type CacheShard struct {
+	Entries  int     `json:"entries"`
+	Capacity int     `json:"capacity"`
+	Percent  float64 `json:"percent"` // Share of the capacity in use
+}
+
+// dashboardMetrics returns the metrics shown on the dashboard, with the latest
+// requests and the occupancy of the cache shards for its panels
+func (s *Server) dashboardMetrics() map[string]interface{} {
+	metrics := s.metrics.GetCurrentMetrics()
+	metrics["recent_requests"] = s.recentRequests.recent(dashboardRecentRequests)
+	if provider, ok := s.cache.(cache.StatsProvider); ok {
+		metrics["cache_shards"] = cacheShards(provider.Stats())
+	}
+	return metrics
+}
+
+// cacheShards returns the occupancy of the shards of a cache, none if it
+// isn't sharded
+func cacheShards(stats cache.Stats) []CacheShard {
+	shards := make([]CacheShard, len(stats.Shards))
+	for i, shard := range stats.Shards {
+		shards[i] = CacheShard{Entries: shard.Entries, Capacity: shard.Capacity}
+		if shard.Capacity > 0 {
+			shards[i].Percent = math.Round(float64(shard.Entries)/float64(shard.Capacity)*1000) / 10
+		}
+	}
+	return shards
+}
+
+// handleStatsStream pushes the dashboard metrics as Server-Sent Events
+// The first event holds all of them, the later ones only those that changed,
+// as a JSON object keyed by metric name; a comment is sent when nothing changed
+// for a while, so the connection isn't taken for idle. The stream ends when
+// the client goes away or the server drains
+func (s *Server) handleStatsStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// The stream lasts longer than the WriteTimeout of the server allows
+	controller := http.NewResponseController(w)
+	_ = controller.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(statsStreamInterval)
+	defer ticker.Stop()
+
+	sent := make(map[string]string)
+	lastWrite := time.Now()
+	for {
+		s.metrics.UpdateMemoryUsage()
+		s.metrics.UpdateCPUUsage()
+
+		changed := make(map[string]json.RawMessage)
+		for name, value := range s.dashboardMetrics() {
+			encoded, err := json.Marshal(value)
+			if err != nil || sent[name] == string(encoded) {
+				continue
+			}
+			sent[name] = string(encoded)
+			changed[name] = encoded
+		}
+
+		var message string
+		switch {
+		case len(changed) > 0:
+			data, _ := json.Marshal(changed)
+			message = "data: " + string(data) + "\n\n"
+		case time.Since(lastWrite) >= statsStreamHeartbeat:
+			message = ": ping\n\n"
+		}
+		if message != "" {
+			if _, err := io.WriteString(w, message); err != nil {
+				return
+			}
+			if err := controller.Flush(); err != nil {
+				return
+			}
+			lastWrite = time.Now()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+		if s.draining.Load() || s.upgrading.Load() {
+			return
+		}
+	}
+}
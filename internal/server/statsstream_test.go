@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent reads the data of the next event of a stream, skipping comments
+func readEvent(t *testing.T, reader *bufio.Reader) map[string]json.RawMessage {
+	t.Helper()
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read the stream: %v", err)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var event map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				t.Fatalf("Failed to decode event %q: %v", data, err)
+			}
+			return event
+		}
+	}
+}
+
+func TestStatsStream(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	ts := httptest.NewServer(server.createRouter())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/stats/stream")
+	if err != nil {
+		t.Fatalf("Failed to open the stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(resp.Body)
+
+	// The first event holds all the dashboard metrics; the stream itself isn't counted
+	event := readEvent(t, reader)
+	for _, name := range []string{"uptime", "requests_total", "max_concurrent", "recent_requests"} {
+		if _, ok := event[name]; !ok {
+			t.Errorf("Expected %s in the first event, got %v", name, event)
+		}
+	}
+	if string(event["requests_total"]) != "0" {
+		t.Errorf("Expected no requests counted, got %s", event["requests_total"])
+	}
+
+	// Later events only hold what changed
+	names, err := http.Get(ts.URL + "/names/A")
+	if err != nil {
+		t.Fatalf("Failed to request names: %v", err)
+	}
+	io.Copy(io.Discard, names.Body)
+	names.Body.Close()
+	for {
+		event = readEvent(t, reader)
+		if _, ok := event["max_concurrent"]; ok {
+			t.Fatalf("Expected unchanged metrics to be left out, got %v", event)
+		}
+		if string(event["requests_total"]) == "1" {
+			break
+		}
+	}
+	var recent []RecentRequest
+	if err := json.Unmarshal(event["recent_requests"], &recent); err != nil || len(recent) != 1 || recent[0].Path != "/names/A" {
+		t.Errorf("Expected only the request for names in the recent requests, got %s", event["recent_requests"])
+	}
+
+	// The stream ends when the server drains
+	server.draining.Store(true)
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, reader)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the stream to end when the server drains")
+	}
+}
+
+func TestStatsStreamMethod(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	rr := httptest.NewRecorder()
+	server.createRouter().ServeHTTP(rr, httptest.NewRequest("POST", "/stats/stream", nil))
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "GET" {
+		t.Errorf("Expected POST to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestDashboardCacheShards(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	options.CacheSize = 640
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	server.cache.Set("key", "value")
+
+	// Every shard of the default cache is shown with its share of the capacity
+	metrics := server.dashboardMetrics()
+	shards, ok := metrics["cache_shards"].([]CacheShard)
+	if !ok || len(shards) != 64 {
+		t.Fatalf("Expected the 64 cache shards, got %v", metrics["cache_shards"])
+	}
+	entries := 0
+	for _, shard := range shards {
+		if shard.Capacity != 10 {
+			t.Errorf("Expected a capacity of 10 per shard, got %d", shard.Capacity)
+		}
+		if shard.Entries == 1 && shard.Percent != 10 {
+			t.Errorf("Expected a shard with 1 of 10 entries to be 10%% full, got %v", shard.Percent)
+		}
+		entries += shard.Entries
+	}
+	if entries != 1 || metrics["cache_capacity"] != 640 {
+		t.Errorf("Expected 1 entry in a capacity of 640, got %d in %v", entries, metrics["cache_capacity"])
+	}
+}
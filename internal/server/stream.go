@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/amirahmetzanov/go_project/internal/generator"
+	"github.com/amirahmetzanov/go_project/internal/workerpool"
+)
+
+// ndjsonContentType is the media type of newline-delimited JSON responses
+const ndjsonContentType = "application/x-ndjson"
+
+// NameLine is a line of a streamed response
+type NameLine struct {
+	Name string `json:"name"`
+}
+
+// streamNames answers a generate request with one JSON line per name, flushing
+// each name to the client as soon as the worker pool produces it
+// Cached names are streamed from the cache, and freshly generated names are
+// cached once the whole response has been sent, except for seeded pages
+// The status is only sent with the first name, so requests that get no names
+// fail with the same problem as in the other formats
+func (s *Server) streamNames(w http.ResponseWriter, r *http.Request, payload RequestPayload) {
+	req, reqErr := s.prepare(payload)
+	if reqErr != nil {
+		reqErr.write(w, r)
+		return
+	}
+	if len(req.payload.Letters) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, "Streaming is not supported with a letter array")
+		return
+	}
+	s.recordSession(req)
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	// start sends the headers of a successful response
+	start := func() {
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.Header().Set("X-Locale", req.locale)
+		if req.paged {
+			// The seed lets clients continue the sequence with later offsets
+			w.Header().Set("X-Seed", strconv.FormatInt(req.payload.Seed, 10))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+
+	// write sends a name to the client, reporting whether the client is still there
+	write := func(name string) bool {
+		if err := encoder.Encode(NameLine{Name: name}); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	count := req.payload.NumOfEntries
+	cacheKey := getCacheKey(req.query, count, req.opts)
+	bypass := cacheBypassed(req.opts)
+	var entry cachedNames
+	var found bool
+	if !bypass {
+		entry, found = s.lookupNames(cacheKey)
+	}
+	if found {
+		if entry.Unknown {
+			nameError(generator.ErrUnknownLetter).write(w, r)
+			return
+		}
+		if entry.stale() {
+			s.revalidate(cacheKey, req.query, count, req.opts)
+		}
+		start()
+		for _, name := range entry.Names {
+			if !write(name) {
+				return
+			}
+		}
+		s.recordResponse(req, entry.Names)
+		return
+	}
+
+	names := make([]string, 0, count)
+	var err error
+	for result := range s.nameGenerator.Stream(workerpool.WithPriority(r.Context(), workerpool.PriorityHigh), req.query, count, req.opts) {
+		if result.Err != nil {
+			err = result.Err
+			break
+		}
+		if len(names) == 0 {
+			start()
+		}
+		if !write(result.Value) {
+			return
+		}
+		names = append(names, result.Value)
+	}
+	if r.Context().Err() != nil {
+		return
+	}
+	if errors.Is(err, generator.ErrUnknownLetter) && !bypass {
+		s.storeUnknown(cacheKey)
+	}
+	if err != nil && len(names) == 0 {
+		nameError(err).write(w, r)
+		return
+	}
+	if err != nil {
+		// The status has been sent, so the client only sees the names end early
+		s.requestLogger(r).Warn("Error streaming names", "count", len(names), "query", req.query, "error", err)
+		return
+	}
+	if len(names) == 0 {
+		// Pages past the end of a sequence have no names
+		start()
+	}
+	s.recordResponse(req, names)
+
+	// Only complete responses are cached, so later requests get all their names
+	if !bypass {
+		s.storeNames(cacheKey, names, s.options.CacheExpiration)
+	}
+	s.requestLogger(r).Debug("Streamed names", "count", len(names), "query", req.query)
+}
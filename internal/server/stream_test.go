@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/generator"
+)
+
+func TestGenerateNamesStream(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	stream := func(body string) (*httptest.ResponseRecorder, []string) {
+		req := httptest.NewRequest(http.MethodPost, "/generate", strings.NewReader(body))
+		req.Header.Set("Accept", "application/x-ndjson")
+		rr := httptest.NewRecorder()
+		server.handleGenerateNames(rr, req)
+
+		var names []string
+		scanner := bufio.NewScanner(strings.NewReader(rr.Body.String()))
+		for rr.Code == http.StatusOK && scanner.Scan() {
+			var line NameLine
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Fatalf("Error parsing line %q: %v", scanner.Text(), err)
+			}
+			names = append(names, line.Name)
+		}
+		return rr, names
+	}
+
+	// Names are sent one per line
+	rr, names := stream(`{"session_id":"s","letter":"A","num_of_entries":5}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v: %s", rr.Code, rr.Body)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != ndjsonContentType {
+		t.Errorf("Expected Content-Type %s, got %q", ndjsonContentType, ct)
+	}
+	if !rr.Flushed {
+		t.Error("Expected the response to be flushed")
+	}
+	if len(names) != 5 {
+		t.Errorf("Expected 5 names, got %v", names)
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, "A") {
+			t.Errorf("Expected name to start with A, got %q", name)
+		}
+	}
+
+	// Seeded streams match the buffered response, and are regenerated
+	// from the seed instead of being cached
+	rr, names = stream(`{"session_id":"s","letter":"C","num_of_entries":150,"seed":5}`)
+	if rr.Header().Get("X-Seed") != "5" {
+		t.Errorf("Expected X-Seed 5, got %q", rr.Header().Get("X-Seed"))
+	}
+	buffered := httptest.NewRecorder()
+	server.handleGenerateNames(buffered, httptest.NewRequest(http.MethodPost, "/generate", strings.NewReader(`{"session_id":"s","letter":"C","num_of_entries":150,"seed":5}`)))
+	var response ResponsePayload
+	if err := json.NewDecoder(buffered.Body).Decode(&response); err != nil {
+		t.Fatalf("Error parsing response: %v", err)
+	}
+	if strings.Join(names, ",") != strings.Join(response.Names, ",") {
+		t.Errorf("Expected the streamed names to match the response, got %v and %v", names, response.Names)
+	}
+	if _, again := stream(`{"session_id":"s","letter":"C","num_of_entries":150,"seed":5}`); strings.Join(again, ",") != strings.Join(names, ",") {
+		t.Error("Expected the same names for the same seed")
+	}
+	if _, cached := server.cache.Get(getCacheKey("C", 150, generator.Options{Locale: "en", Seed: 5})); cached {
+		t.Error("Expected seeded names not to be cached")
+	}
+
+	// Invalid requests and letter arrays are rejected before streaming
+	if rr, _ := stream(`{"letter":"A"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest without a session, got %v", rr.Code)
+	}
+	if rr, _ := stream(`{"session_id":"s","letter":["A","B"]}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest for a letter array, got %v", rr.Code)
+	}
+}
+
+func TestGenerateNamesStreamErrors(t *testing.T) {
+	server := NewServer(DefaultServerOptions())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	generate := func(accept, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/generate", strings.NewReader(body))
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		server.handleGenerateNames(rr, req)
+		return rr
+	}
+
+	// Letters without names fail like in JSON, also once the miss is cached
+	body := `{"session_id":"s","letter":"X","locale":"tr","num_of_entries":3}`
+	expected := generate("application/json", body)
+	for i := 0; i < 2; i++ {
+		rr := generate(ndjsonContentType, body)
+		if rr.Code != http.StatusBadRequest || rr.Code != expected.Code {
+			t.Fatalf("Expected status %v, got %v", expected.Code, rr.Code)
+		}
+		if rr.Body.String() != expected.Body.String() {
+			t.Errorf("Expected the JSON problem %s, got %s", expected.Body, rr.Body)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != problemContentType {
+			t.Errorf("Expected Content-Type %s, got %q", problemContentType, ct)
+		}
+	}
+
+	// Names the pool can't pick fail the request, and nothing is cached
+	server.nameGenerator.Shutdown()
+	rr := generate(ndjsonContentType, `{"session_id":"s","letter":"D","num_of_entries":5}`)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status ServiceUnavailable, got %v: %s", rr.Code, rr.Body)
+	}
+	if _, cached := server.cache.Get(getCacheKey("D", 5, generator.Options{Locale: "en"})); cached {
+		t.Error("Expected no names to be cached")
+	}
+}
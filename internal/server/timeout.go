@@ -0,0 +1,226 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/metrics"
+)
+
+// defaultRouteTimeouts are the handler deadlines of the routes that have one
+func defaultRouteTimeouts() map[string]time.Duration {
+	return map[string]time.Duration{
+		"/generate":       2 * time.Second,
+		"/generate/batch": 5 * time.Second,
+		"/names/{letter}": 2 * time.Second,
+		"/stats/data":     500 * time.Millisecond,
+	}
+}
+
+// routeTimeout returns the deadline of the route a path belongs to; the keys of
+// RouteTimeouts are paths or router patterns such as "/names/{letter}"
+func (s *Server) routeTimeout(path string) (time.Duration, bool) {
+	if timeout, ok := s.options.RouteTimeouts[path]; ok {
+		return timeout, true
+	}
+	for pattern, timeout := range s.options.RouteTimeouts {
+		if strings.Contains(pattern, "{") {
+			if _, ok := matchPattern(pattern, path); ok {
+				return timeout, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// timeoutMiddleware gives the handler of a route a deadline, set on the request
+// context, and answers with 503 if the handler hasn't responded by then
+// Until the deadline the response is buffered, so it can still be replaced by
+// the 503; a handler that flushes, e.g. to stream names, commits its response
+// and is then only stopped through the context
+func (s *Server) timeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, ok := s.routeTimeout(r.URL.Path)
+		if !ok || timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{w: w, header: w.Header().Clone(), code: http.StatusOK}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				// The stack is taken here, where it still shows the handler
+				if p != http.ErrAbortHandler {
+					p = &handlerPanic{value: p, stack: debug.Stack()}
+				}
+
+				// Nobody waits for the handler after its deadline, so a
+				// panic then is logged here
+				tw.mutex.Lock()
+				defer tw.mutex.Unlock()
+				if !tw.timedOut {
+					panicked <- p
+				} else if hp, ok := p.(*handlerPanic); ok {
+					s.logPanic(r, "Handler panicked after its deadline", hp.value, hp.stack)
+				}
+			}()
+			next.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			tw.finish()
+		case p := <-panicked:
+			// Let recoveryMiddleware handle the panic on the request goroutine
+			panic(p)
+		case <-ctx.Done():
+			if tw.timeOut() {
+				failRequest(r, metrics.CategorizeError(metrics.CategoryTimeout, fmt.Errorf("handler exceeded its deadline of %v", timeout)))
+				s.requestLogger(r).Warn("Request timed out", "timeout", timeout.String())
+				writeProblem(w, r, http.StatusServiceUnavailable, fmt.Sprintf("The request took longer than %v", timeout))
+
+				// A panic handed over just before the deadline is logged too
+				select {
+				case p := <-panicked:
+					if hp, ok := p.(*handlerPanic); ok {
+						s.logPanic(r, "Handler panicked after its deadline", hp.value, hp.stack)
+					}
+				default:
+				}
+				return
+			}
+
+			// The response was committed, so wait for the handler to stop writing it
+			select {
+			case <-done:
+			case p := <-panicked:
+				panic(p)
+			}
+		}
+	})
+}
+
+// handlerPanic is a panic of a handler run by timeoutMiddleware, raised again
+// on the request goroutine with the stack of the handler
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *handlerPanic) String() string {
+	return fmt.Sprint(p.value)
+}
+
+// timeoutWriter buffers the response of a handler running under a deadline
+type timeoutWriter struct {
+	mutex     sync.Mutex
+	w         http.ResponseWriter
+	header    http.Header
+	body      bytes.Buffer
+	code      int
+	wroteCode bool
+	committed bool // The response was flushed and is written to w directly
+	timedOut  bool // The deadline passed first; later writes are discarded
+}
+
+// Header returns the headers of the buffered response
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+// WriteHeader records the status code, or sends it if the response is committed
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.timedOut || tw.wroteCode {
+		return
+	}
+	tw.code, tw.wroteCode = code, true
+	if tw.committed {
+		tw.w.WriteHeader(code)
+	}
+}
+
+// Write buffers the body, or sends it if the response is committed
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.wroteCode = true
+	if tw.committed {
+		return tw.w.Write(b)
+	}
+	return tw.body.Write(b)
+}
+
+// Flush commits the response: what was buffered is sent, and so is everything after it
+func (tw *timeoutWriter) Flush() {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if !tw.committed {
+		tw.send()
+		tw.committed = true
+	}
+	if flusher, ok := tw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish sends the buffered response once the handler has returned in time
+func (tw *timeoutWriter) finish() {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if !tw.committed {
+		tw.send()
+	}
+}
+
+// send copies the buffered headers, status and body to the real writer
+func (tw *timeoutWriter) send() {
+	dst := tw.w.Header()
+	for key := range dst {
+		if _, ok := tw.header[key]; !ok {
+			dst.Del(key)
+		}
+	}
+	for key, values := range tw.header {
+		dst[key] = values
+	}
+	tw.w.WriteHeader(tw.code)
+	tw.w.Write(tw.body.Bytes())
+	tw.body.Reset()
+}
+
+// timeOut marks the response as timed out, unless it has been committed
+// It reports whether the caller may write the timeout response
+func (tw *timeoutWriter) timeOut() bool {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.committed {
+		return false
+	}
+	tw.timedOut = true
+	return true
+}
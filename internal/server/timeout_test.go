@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amirahmetzanov/go_project/internal/metrics"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	options := DefaultServerOptions()
+	options.RouteTimeouts = map[string]time.Duration{
+		"/slow":    50 * time.Millisecond,
+		"/fast":    time.Second,
+		"/stream":  50 * time.Millisecond,
+		"/panic":   time.Second,
+		"/late":    50 * time.Millisecond,
+		"/limited": 0,
+	}
+	logs := &logBuffer{}
+	options.LogOutput = logs
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	// slow waits for its deadline, reporting whether it saw the context end
+	canceled := make(chan bool, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Partial", "yes")
+		w.Write([]byte("too late"))
+		select {
+		case <-r.Context().Done():
+			canceled <- true
+		case <-time.After(time.Second):
+			canceled <- false
+		}
+	})
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("Expected the handler to have a deadline")
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	})
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		w.Write([]byte("cut\n"))
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		breakHandler()
+	})
+	late := make(chan struct{})
+	mux.HandleFunc("/late", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		defer close(late)
+		panic("broke after the deadline")
+	})
+	mux.HandleFunc("/limited", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("Expected no deadline for a route without a timeout")
+		}
+	})
+	handler := server.metricsMiddleware(server.loggingMiddleware(server.recoveryMiddleware(server.timeoutMiddleware(mux))))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	// A handler past its deadline is answered with a 503 problem, without its partial response
+	rr := serve("/slow")
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Content-Type") != problemContentType {
+		t.Errorf("Expected a 503 problem, got %v %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if rr.Header().Get("X-Partial") != "" || rr.Header().Get(requestIDHeader) == "" {
+		t.Errorf("Expected only the headers set before the handler, got %v", rr.Header())
+	}
+	if !<-canceled {
+		t.Error("Expected the handler's context to be canceled")
+	}
+	if failed := server.metrics.GetRequestFailed(); failed != 1 || server.metrics.GetFailureCount(metrics.CategoryTimeout) != 1 {
+		t.Errorf("Expected the timeout to count as a failure, got %d failed", failed)
+	}
+
+	// A handler within its deadline keeps its status, headers and body
+	rr = serve("/fast")
+	if rr.Code != http.StatusCreated || rr.Header().Get("Content-Type") != "text/plain" || rr.Body.String() != "done" {
+		t.Errorf("Expected the handler's response, got %v %v %q", rr.Code, rr.Header(), rr.Body.String())
+	}
+
+	// A flushed response is committed and only cut short by the deadline
+	rr = serve("/stream")
+	if rr.Code != http.StatusOK || rr.Body.String() != "first\ncut\n" {
+		t.Errorf("Expected the streamed response, got %v %q", rr.Code, rr.Body.String())
+	}
+
+	// Panics reach the recovery middleware, with the stack of the handler
+	if rr = serve("/panic"); rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status InternalServerError for a panic, got %v", rr.Code)
+	}
+	entries := logs.entries(t, "Handler panicked")
+	if len(entries) != 1 || !strings.Contains(entries[0]["stack"].(string), "breakHandler") {
+		t.Errorf("Expected the panic to be logged with the handler's stack, got %v", entries)
+	}
+
+	// Panics after the deadline are logged by the handler's goroutine
+	if rr = serve("/late"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status ServiceUnavailable, got %v", rr.Code)
+	}
+	<-late
+	deadline := time.Now().Add(time.Second)
+	for len(logs.entries(t, "Handler panicked after its deadline")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if entries := logs.entries(t, "Handler panicked after its deadline"); len(entries) != 1 || entries[0]["panic"] != "broke after the deadline" {
+		t.Errorf("Expected the late panic to be logged, got %v", entries)
+	}
+
+	serve("/limited")
+}
+
+// breakHandler panics, to find the handler in the stack of a panic
+func breakHandler() {
+	panic("something broke")
+}
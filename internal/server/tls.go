@@ -0,0 +1,39 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/amirahmetzanov/go_project/internal/acme"
+)
+
+// newCertManager returns the ACME manager obtaining certificates for the
+// configured TLS domains, or nil when the server serves plain HTTP
+func newCertManager(options ServerOptions, logger *slog.Logger) *acme.Manager {
+	if len(options.TLSDomains) == 0 {
+		return nil
+	}
+	return &acme.Manager{
+		Domains:      options.TLSDomains,
+		CacheDir:     options.ACMECacheDir,
+		DirectoryURL: options.ACMEDirectoryURL,
+		Email:        options.ACMEEmail,
+		Logger:       logger,
+	}
+}
+
+// listenPort returns the port of the options or the PORT environment variable,
+// or the default of the protocol: 443 with automatic TLS, 8080 otherwise
+func (s *Server) listenPort() string {
+	if s.options.Port > 0 {
+		return strconv.Itoa(s.options.Port)
+	}
+	if port := os.Getenv("PORT"); port != "" {
+		return port
+	}
+	if s.certManager != nil {
+		return "443"
+	}
+	return "8080"
+}
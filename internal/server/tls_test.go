@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func TestAutomaticTLS(t *testing.T) {
+	t.Setenv("PORT", "")
+
+	// Plain HTTP unless TLS domains are configured
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	plain := NewServer(options)
+	defer plain.Shutdown(context.Background())
+	if plain.certManager != nil || plain.httpServer.TLSConfig != nil {
+		t.Error("Expected no TLS without domains")
+	}
+	if plain.httpServer.Addr != ":8080" {
+		t.Errorf("Expected port 8080 for plain HTTP, got %s", plain.httpServer.Addr)
+	}
+
+	options.TLSDomains = []string{"example.com"}
+	options.ACMECacheDir = t.TempDir()
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	if server.certManager == nil || server.certManager.CacheDir != options.ACMECacheDir {
+		t.Fatal("Expected a certificate manager using the cache directory")
+	}
+	config := server.httpServer.TLSConfig
+	if config == nil || config.GetCertificate == nil {
+		t.Fatal("Expected the TLS config to get certificates from the manager")
+	}
+	if server.httpServer.Addr != ":443" {
+		t.Errorf("Expected port 443 with TLS, got %s", server.httpServer.Addr)
+	}
+
+	// Only configured domains get a certificate; others fail without contacting the CA
+	if _, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.com"}); err == nil {
+		t.Error("Expected no certificate for an unconfigured domain")
+	}
+
+	// PORT still takes precedence
+	t.Setenv("PORT", "8443")
+	if port := server.listenPort(); port != "8443" {
+		t.Errorf("Expected PORT to override the TLS port, got %s", port)
+	}
+
+	// The Port option takes precedence over PORT
+	server.options.Port = 9443
+	if port := server.listenPort(); port != "9443" {
+		t.Errorf("Expected the Port option to be used, got %s", port)
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// upgradeParentEnv names the process a new binary takes the sockets over from;
+// it is told to shut down once the new binary serves them
+const upgradeParentEnv = "UPGRADE_PARENT_PID"
+
+// Upgrade starts a new instance of the server binary that inherits the
+// listening sockets, for deploying a new version without refusing connections
+// Both instances accept connections until the new one signals it is serving;
+// this one then stops accepting and finishes its requests (see Shutdown)
+// It returns the process ID of the new instance
+func (s *Server) Upgrade() (int, error) {
+	if !s.upgrading.CompareAndSwap(false, true) {
+		return 0, errors.New("an upgrade is already in progress")
+	}
+	cmd, err := s.upgradeCommand()
+	if err != nil {
+		s.upgrading.Store(false)
+		return 0, err
+	}
+	err = cmd.Start()
+	// The new instance has its own copies of the descriptors
+	for _, file := range cmd.ExtraFiles {
+		file.Close()
+	}
+	if err != nil {
+		s.upgrading.Store(false)
+		return 0, err
+	}
+
+	// If the new instance exits instead of taking over, this one keeps serving
+	go func() {
+		err := cmd.Wait()
+		if s.upgrading.CompareAndSwap(true, false) {
+			s.logger.Error("New server instance exited during the upgrade", "pid", cmd.Process.Pid, "error", err)
+		}
+	}()
+	s.logger.Info("Started new server instance", "pid", cmd.Process.Pid)
+	return cmd.Process.Pid, nil
+}
+
+// upgradeCommand prepares the new instance: the same binary and arguments, with
+// the listening sockets passed as descriptors 3 and up like systemd does, and
+// the socket of the admin server last, named in LISTEN_FDNAMES
+func (s *Server) upgradeCommand() (*exec.Cmd, error) {
+	s.listenersMutex.Lock()
+	listeners := s.listeners
+	admin := s.adminListener
+	s.listenersMutex.Unlock()
+	if len(listeners) == 0 {
+		return nil, errors.New("the server is not listening")
+	}
+	names := make([]string, len(listeners))
+	for i := range names {
+		names[i] = "http"
+	}
+	if admin != nil {
+		listeners = append(listeners[:len(listeners):len(listeners)], admin)
+		names = append(names, adminListenerName)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*os.File, 0, len(listeners))
+	for _, listener := range listeners {
+		filer, ok := listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			err = errors.New("listener " + listener.Addr().String() + " can't be passed on")
+		} else {
+			var file *os.File
+			if file, err = filer.File(); err == nil {
+				files = append(files, file)
+			}
+		}
+		if err != nil {
+			for _, file := range files {
+				file.Close()
+			}
+			return nil, err
+		}
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	for _, variable := range os.Environ() {
+		if !strings.HasPrefix(variable, "LISTEN_") && !strings.HasPrefix(variable, upgradeParentEnv+"=") {
+			cmd.Env = append(cmd.Env, variable)
+		}
+	}
+	cmd.Env = append(cmd.Env,
+		"LISTEN_FDS="+strconv.Itoa(len(files)),
+		"LISTEN_FDNAMES="+strings.Join(names, ":"),
+		upgradeParentEnv+"="+strconv.Itoa(os.Getpid()))
+	return cmd, nil
+}
+
+// upgradedFrom returns the process the sockets were inherited from during an
+// upgrade, or 0
+func upgradedFrom() int {
+	pid, err := strconv.Atoi(os.Getenv(upgradeParentEnv))
+	if err != nil || pid != os.Getppid() {
+		return 0
+	}
+	return pid
+}
+
+// notifyUpgradeParent tells the previous instance that this one serves the
+// sockets now, which makes it shut down
+func notifyUpgradeParent(pid int) error {
+	os.Unsetenv(upgradeParentEnv)
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(syscall.SIGTERM)
+}
+
+// setListeners records the listeners the server accepts connections on
+func (s *Server) setListeners(listeners []net.Listener) {
+	s.listenersMutex.Lock()
+	s.listeners = listeners
+	s.listenersMutex.Unlock()
+}
@@ -0,0 +1,157 @@
+//go:build unix
+
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestUpgradeCommand(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer server.Shutdown(context.Background())
+
+	// Nothing to hand over before the server listens
+	if _, err := server.Upgrade(); err == nil {
+		t.Error("Expected an error upgrading a server that isn't listening")
+	}
+	if server.upgrading.Load() {
+		t.Error("Expected a failed upgrade to be cleared")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	server.setListeners([]net.Listener{listener})
+
+	// Variables of an earlier activation aren't passed on
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDNAMES", "old")
+	cmd, err := server.upgradeCommand()
+	if err != nil {
+		t.Fatalf("Error preparing the new instance: %v", err)
+	}
+	defer func() {
+		for _, file := range cmd.ExtraFiles {
+			file.Close()
+		}
+	}()
+
+	if len(cmd.ExtraFiles) != 1 {
+		t.Fatalf("Expected the listener to be passed, got %d files", len(cmd.ExtraFiles))
+	}
+	env := map[string]bool{}
+	for _, variable := range cmd.Env {
+		env[variable] = true
+	}
+	if !env["LISTEN_FDS=1"] || !env[upgradeParentEnv+"="+strconv.Itoa(os.Getpid())] {
+		t.Errorf("Expected LISTEN_FDS and %s, got %v", upgradeParentEnv, cmd.Env)
+	}
+	if !env["LISTEN_FDNAMES=http"] || env["LISTEN_PID=1"] || env["LISTEN_FDNAMES=old"] {
+		t.Errorf("Expected stale LISTEN_ variables to be removed, got %v", cmd.Env)
+	}
+
+	// The passed descriptor is the same socket
+	inherited, err := net.FileListener(cmd.ExtraFiles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inherited.Close()
+	if inherited.Addr().String() != listener.Addr().String() {
+		t.Errorf("Expected %s, got %s", listener.Addr(), inherited.Addr())
+	}
+
+	// The admin server's socket is passed last, under its name
+	admin, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	server.setAdminListener(admin)
+	withAdmin, err := server.upgradeCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range withAdmin.ExtraFiles {
+		file.Close()
+	}
+	env = map[string]bool{}
+	for _, variable := range withAdmin.Env {
+		env[variable] = true
+	}
+	if len(withAdmin.ExtraFiles) != 2 || !env["LISTEN_FDS=2"] || !env["LISTEN_FDNAMES=http:admin"] {
+		t.Errorf("Expected the admin socket to be passed, got %d files and %v", len(withAdmin.ExtraFiles), withAdmin.Env)
+	}
+}
+
+func TestListenersFromUpgrade(t *testing.T) {
+	fd, address := passListener(t)
+
+	// The new instance has no LISTEN_PID, but knows its parent
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv(upgradeParentEnv, strconv.Itoa(os.Getppid()))
+	if upgradedFrom() != os.Getppid() {
+		t.Fatalf("Expected the parent %d to be recognized", os.Getppid())
+	}
+	listeners, _, err := listenersFromEnv(fd)
+	if err != nil || len(listeners) != 1 {
+		t.Fatalf("Expected the handed over listener, got %v, %v", listeners, err)
+	}
+	defer listeners[0].Close()
+	if listeners[0].Addr().String() != address {
+		t.Errorf("Expected a listener on %s, got %s", address, listeners[0].Addr())
+	}
+
+	// Another process's sockets are left alone
+	t.Setenv(upgradeParentEnv, strconv.Itoa(os.Getppid()+1))
+	if upgradedFrom() != 0 {
+		t.Error("Expected an upgrade from a process other than the parent to be ignored")
+	}
+}
+
+func TestNotifyUpgradeParent(t *testing.T) {
+	terminated := make(chan os.Signal, 1)
+	signal.Notify(terminated, syscall.SIGTERM)
+	defer signal.Stop(terminated)
+
+	t.Setenv(upgradeParentEnv, strconv.Itoa(os.Getpid()))
+	if err := notifyUpgradeParent(os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-terminated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected SIGTERM to be sent to the previous instance")
+	}
+	if os.Getenv(upgradeParentEnv) != "" {
+		t.Errorf("Expected %s to be unset", upgradeParentEnv)
+	}
+}
+
+func TestShutdownAfterUpgrade(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+
+	// With a successor serving the sockets, the server doesn't report draining
+	server.upgrading.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if server.draining.Load() {
+		t.Error("Expected no draining phase after an upgrade")
+	}
+}
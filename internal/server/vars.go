@@ -0,0 +1,72 @@
+package server
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+
+	"github.com/amirahmetzanov/go_project/internal/cache"
+	"github.com/amirahmetzanov/go_project/internal/metrics"
+)
+
+// newVars creates the expvar counters of the server
+// They are kept in a map of their own instead of being published, since
+// expvar.Publish is global and a process may run several servers
+func (s *Server) newVars() *expvar.Map {
+	vars := new(expvar.Map).Init()
+	vars.Set("requests_total", expvar.Func(func() interface{} { return s.metrics.GetRequestTotal() }))
+	vars.Set("requests_succeeded", expvar.Func(func() interface{} { return s.metrics.GetRequestSucceeded() }))
+	vars.Set("requests_failed", expvar.Func(func() interface{} { return s.metrics.GetRequestFailed() }))
+	for _, category := range metrics.ErrorCategories {
+		category := category
+		vars.Set("requests_failed_"+category.String(), expvar.Func(func() interface{} { return s.metrics.GetFailureCount(category) }))
+	}
+	vars.Set("requests_in_flight", expvar.Func(func() interface{} { return s.metrics.GetCurrentConcurrent() }))
+	for class := 2; class <= 5; class++ {
+		class := class
+		vars.Set(fmt.Sprintf("responses_%dxx", class), expvar.Func(func() interface{} { return s.metrics.GetStatusClassCount(class) }))
+	}
+	vars.Set("responses_429", expvar.Func(func() interface{} { return s.metrics.GetTooManyRequests() }))
+	vars.Set("generator_queue_depth", expvar.Func(func() interface{} { return s.nameGenerator.Queued() }))
+	vars.Set("generator_workers", expvar.Func(func() interface{} { return s.nameGenerator.Workers() }))
+	vars.Set("generator_task_panics", expvar.Func(func() interface{} { return s.nameGenerator.Panics() }))
+	vars.Set("generator_task_timeouts", expvar.Func(func() interface{} { return s.nameGenerator.Timeouts() }))
+	vars.Set("batch_queue_depth", expvar.Func(func() interface{} { return s.batchPool.Queued() }))
+	vars.Set("batch_task_panics", expvar.Func(func() interface{} { return s.batchPool.Panics() }))
+	vars.Set("batch_task_timeouts", expvar.Func(func() interface{} { return s.batchPool.Timeouts() }))
+	vars.Set("admission_queue_depth", expvar.Func(func() interface{} {
+		if s.admissionQueue == nil {
+			return int64(0)
+		}
+		return s.admissionQueue.Depth()
+	}))
+	vars.Set("ip_denied", expvar.Func(func() interface{} {
+		if s.ipFilter == nil {
+			return uint64(0)
+		}
+		return s.ipFilter.denied.Load()
+	}))
+	if provider, ok := s.cache.(cache.StatsProvider); ok {
+		vars.Set("cache_hits", expvar.Func(func() interface{} { return provider.Stats().Hits }))
+		vars.Set("cache_misses", expvar.Func(func() interface{} { return provider.Stats().Misses }))
+		vars.Set("cache_evictions", expvar.Func(func() interface{} { return provider.Stats().Evictions }))
+		vars.Set("cache_entries", expvar.Func(func() interface{} { return provider.Stats().Entries }))
+	}
+	return vars
+}
+
+// handleVars serves the expvar variables of the process, such as memstats, and
+// the counters of the server under "namegen", in the format of expvar.Handler
+func (s *Server) handleVars(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, "{\n")
+	expvar.Do(func(kv expvar.KeyValue) {
+		fmt.Fprintf(w, "%q: %s,\n", kv.Key, kv.Value)
+	})
+	fmt.Fprintf(w, "%q: %s\n}\n", "namegen", s.vars)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleVars(t *testing.T) {
+	options := DefaultServerOptions()
+	options.LogOutput = &logBuffer{}
+	server := NewServer(options)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	router := server.createRouter()
+
+	// Generate the same names twice, so the second request hits the cache
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/generate", strings.NewReader(`{"session_id":"s1","letter":"A","num_of_entries":3}`))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %v", rr.Code)
+	}
+
+	var vars struct {
+		Memstats map[string]interface{} `json:"memstats"`
+		Namegen  map[string]float64     `json:"namegen"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&vars); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if len(vars.Memstats) == 0 {
+		t.Error("Expected the process variables, such as memstats")
+	}
+
+	// The requests so far are counted; /debug/vars itself is still in flight
+	want := map[string]float64{
+		"requests_total":     3,
+		"requests_succeeded": 2,
+		"requests_in_flight": 1,
+		"responses_2xx":      2,
+		"responses_5xx":      0,
+		"cache_hits":         1,
+		"cache_misses":       1,
+	}
+	for name, value := range want {
+		if got, ok := vars.Namegen[name]; !ok || got != value {
+			t.Errorf("Expected %s to be %v, got %v", name, value, vars.Namegen[name])
+		}
+	}
+	for _, name := range []string{"generator_queue_depth", "generator_workers", "generator_task_panics", "generator_task_timeouts", "batch_queue_depth", "batch_task_panics", "batch_task_timeouts", "admission_queue_depth", "ip_denied", "responses_3xx", "responses_4xx", "responses_429"} {
+		if _, ok := vars.Namegen[name]; !ok {
+			t.Errorf("Expected %s to be published", name)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package session
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Session is the activity recorded for a session ID
+type Session struct {
+	ID        string           `json:"session_id"`
+	Requests  int64            `json:"requests"`
+	FirstSeen time.Time        `json:"first_seen"`
+	LastSeen  time.Time        `json:"last_seen"`
+	ExpiresAt time.Time        `json:"expires_at"` // Zero if sessions don't expire
+	Letters   map[string]int64 `json:"letters"`    // Requests per requested letter
+}
+
+// Response is a response given to a session, kept in its history
+type Response struct {
+	Time    time.Time `json:"time"`
+	Letters []string  `json:"letters"` // Letter, prefix or letters the request asked for
+	Locale  string    `json:"locale"`
+	Names   []string  `json:"names"`
+}
+
+// entry is a tracked session with its most recent responses
+type entry struct {
+	session Session
+	history []Response // Ring buffer of at most historySize responses
+	next    int        // Position of the next response once the buffer is full
+}
+
+// Tracker records the activity of sessions in memory
+// A session expires once it has been idle for the TTL, and when more than
+// maxSessions are tracked the one idle the longest is dropped
+type Tracker struct {
+	mu          sync.Mutex
+	sessions    map[string]*list.Element // Values are *entry
+	idle        *list.List               // Most recently seen first
+	ttl         time.Duration
+	maxSessions int
+	historySize int
+	stopCleanup chan bool
+	cleanup     bool
+}
+
+// Option configures optional Tracker behavior
+type Option func(*Tracker)
+
+// WithHistory keeps the last size responses of every session (see AddResponse)
+func WithHistory(size int) Option {
+	return func(t *Tracker) {
+		t.historySize = size
+	}
+}
+
+// NewTracker creates a tracker whose sessions expire after ttl without
+// requests, removing expired sessions every cleanupInterval
+// A maxSessions of 0 doesn't bound the number of sessions
+func NewTracker(ttl time.Duration, maxSessions int, cleanupInterval time.Duration, opts ...Option) *Tracker {
+	tracker := &Tracker{
+		sessions:    make(map[string]*list.Element),
+		idle:        list.New(),
+		ttl:         ttl,
+		maxSessions: maxSessions,
+		stopCleanup: make(chan bool),
+		cleanup:     cleanupInterval > 0,
+	}
+	for _, opt := range opts {
+		opt(tracker)
+	}
+
+	// Start the cleanup goroutine
+	if tracker.cleanup {
+		go tracker.startCleanupTimer(cleanupInterval)
+	}
+
+	return tracker
+}
+
+// startCleanupTimer removes expired sessions until the tracker is shut down
+func (t *Tracker) startCleanupTimer(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.DeleteExpired()
+		case <-t.stopCleanup:
+			return
+		}
+	}
+}
+
+// Record counts a request of a session for the given letters or prefixes;
+// only their first letter is recorded, upper-cased
+func (t *Tracker) Record(id string, letters ...string) {
+	if id == "" {
+		return
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	element, found := t.sessions[id]
+	if found && t.expired(element.Value.(*entry), now) {
+		t.remove(element)
+		found = false
+	}
+	if !found {
+		element = t.idle.PushFront(&entry{session: Session{
+			ID:        id,
+			FirstSeen: now,
+			Letters:   make(map[string]int64),
+		}})
+		t.sessions[id] = element
+
+		// Make room by dropping the session idle the longest
+		if t.maxSessions > 0 && t.idle.Len() > t.maxSessions {
+			t.remove(t.idle.Back())
+		}
+	} else {
+		t.idle.MoveToFront(element)
+	}
+
+	session := &element.Value.(*entry).session
+	session.Requests++
+	session.LastSeen = now
+	for _, letter := range letters {
+		if r, _ := utf8.DecodeRuneInString(letter); r != utf8.RuneError {
+			session.Letters[strings.ToUpper(string(r))]++
+		}
+	}
+}
+
+// AddResponse adds a response to the history of a recorded session, replacing
+// the oldest one once the history is full
+// Responses of unknown or expired sessions, or without a history, are dropped
+func (t *Tracker) AddResponse(id string, response Response) {
+	if t.historySize <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := t.lookup(id, time.Now())
+	if e == nil {
+		return
+	}
+	if len(e.history) < t.historySize {
+		e.history = append(e.history, response)
+		return
+	}
+	e.history[e.next] = response
+	e.next = (e.next + 1) % t.historySize
+}
+
+// History returns the responses kept for a session, oldest first, or false if
+// the session is unknown or has expired
+func (t *Tracker) History(id string) ([]Response, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := t.lookup(id, time.Now())
+	if e == nil {
+		return nil, false
+	}
+	history := make([]Response, 0, len(e.history))
+	history = append(history, e.history[e.next:]...)
+	return append(history, e.history[:e.next]...), true
+}
+
+// Get returns a copy of a session, or false if it is unknown or has expired
+func (t *Tracker) Get(id string) (Session, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := t.lookup(id, time.Now())
+	if e == nil {
+		return Session{}, false
+	}
+	summary := e.session
+	if t.ttl > 0 {
+		summary.ExpiresAt = summary.LastSeen.Add(t.ttl)
+	}
+	summary.Letters = make(map[string]int64, len(e.session.Letters))
+	for letter, count := range e.session.Letters {
+		summary.Letters[letter] = count
+	}
+	return summary, true
+}
+
+// Len returns the number of tracked sessions, including expired ones that
+// haven't been removed yet
+func (t *Tracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.idle.Len()
+}
+
+// DeleteExpired removes the sessions that have been idle for longer than the TTL
+func (t *Tracker) DeleteExpired() {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The list is ordered by last activity, so expired sessions are at the back
+	for element := t.idle.Back(); element != nil; element = t.idle.Back() {
+		if !t.expired(element.Value.(*entry), now) {
+			return
+		}
+		t.remove(element)
+	}
+}
+
+// Shutdown stops the cleanup goroutine
+func (t *Tracker) Shutdown() {
+	if t.cleanup {
+		t.stopCleanup <- true
+	}
+}
+
+// lookup returns a session that hasn't expired, removing it if it has, or nil;
+// the caller holds the lock
+func (t *Tracker) lookup(id string, now time.Time) *entry {
+	element, found := t.sessions[id]
+	if !found {
+		return nil
+	}
+	if e := element.Value.(*entry); !t.expired(e, now) {
+		return e
+	}
+	t.remove(element)
+	return nil
+}
+
+// expired reports whether a session has been idle for longer than the TTL
+func (t *Tracker) expired(e *entry, now time.Time) bool {
+	return t.ttl > 0 && now.Sub(e.session.LastSeen) > t.ttl
+}
+
+// remove drops a session; the caller holds the lock
+func (t *Tracker) remove(element *list.Element) {
+	t.idle.Remove(element)
+	delete(t.sessions, element.Value.(*entry).session.ID)
+}
@@ -0,0 +1,182 @@
+package session
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	tracker := NewTracker(time.Minute, 0, 0)
+	defer tracker.Shutdown()
+
+	tracker.Record("s1", "A")
+	tracker.Record("s1", "ben", "Éva")
+	tracker.Record("s2", "C")
+	tracker.Record("", "D")
+
+	session, ok := tracker.Get("s1")
+	if !ok {
+		t.Fatal("Expected session s1 to be tracked")
+	}
+	if session.ID != "s1" || session.Requests != 2 {
+		t.Errorf("Expected 2 requests for s1, got %+v", session)
+	}
+	expected := map[string]int64{"A": 1, "B": 1, "É": 1}
+	if !reflect.DeepEqual(session.Letters, expected) {
+		t.Errorf("Expected letters %v, got %v", expected, session.Letters)
+	}
+	if session.LastSeen.Before(session.FirstSeen) || !session.ExpiresAt.Equal(session.LastSeen.Add(time.Minute)) {
+		t.Errorf("Unexpected times %+v", session)
+	}
+
+	// Sessions without an ID aren't tracked
+	if tracker.Len() != 2 {
+		t.Errorf("Expected 2 sessions, got %d", tracker.Len())
+	}
+	if _, ok := tracker.Get("unknown"); ok {
+		t.Error("Expected an unknown session not to be found")
+	}
+
+	// The summary is a copy
+	session.Letters["Z"] = 5
+	if again, _ := tracker.Get("s1"); again.Letters["Z"] != 0 {
+		t.Error("Expected changes to the summary not to affect the tracker")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	tracker := NewTracker(50*time.Millisecond, 0, 0)
+	defer tracker.Shutdown()
+
+	tracker.Record("old", "A")
+	time.Sleep(30 * time.Millisecond)
+	tracker.Record("new", "B")
+	time.Sleep(30 * time.Millisecond)
+
+	// Idle sessions expire, and DeleteExpired removes them
+	if _, ok := tracker.Get("old"); ok {
+		t.Error("Expected the idle session to have expired")
+	}
+	tracker.DeleteExpired()
+	if tracker.Len() != 1 {
+		t.Errorf("Expected only the recent session to remain, got %d", tracker.Len())
+	}
+
+	// Activity keeps a session alive
+	tracker.Record("new", "B")
+	time.Sleep(30 * time.Millisecond)
+	if session, ok := tracker.Get("new"); !ok || session.Requests != 2 {
+		t.Errorf("Expected the active session to be kept, got %+v, %v", session, ok)
+	}
+
+	// An expired session starts over
+	time.Sleep(60 * time.Millisecond)
+	tracker.Record("new", "C")
+	if session, _ := tracker.Get("new"); session.Requests != 1 || session.Letters["B"] != 0 {
+		t.Errorf("Expected a new session, got %+v", session)
+	}
+}
+
+func TestCleanupTimer(t *testing.T) {
+	tracker := NewTracker(10*time.Millisecond, 0, 10*time.Millisecond)
+	defer tracker.Shutdown()
+
+	tracker.Record("s1", "A")
+	time.Sleep(50 * time.Millisecond)
+	if tracker.Len() != 0 {
+		t.Errorf("Expected the cleanup to remove the expired session, got %d", tracker.Len())
+	}
+}
+
+func TestMaxSessions(t *testing.T) {
+	tracker := NewTracker(time.Minute, 2, 0)
+	defer tracker.Shutdown()
+
+	tracker.Record("s1", "A")
+	tracker.Record("s2", "B")
+	tracker.Record("s1", "A")
+	tracker.Record("s3", "C")
+
+	// The session idle the longest makes room
+	if _, ok := tracker.Get("s2"); ok {
+		t.Error("Expected s2 to be dropped")
+	}
+	for _, id := range []string{"s1", "s3"} {
+		if _, ok := tracker.Get(id); !ok {
+			t.Errorf("Expected %s to be kept", id)
+		}
+	}
+}
+
+func TestConcurrentRecord(t *testing.T) {
+	tracker := NewTracker(time.Minute, 0, 0)
+	defer tracker.Shutdown()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				tracker.Record(fmt.Sprintf("s%d", i%5), "A")
+				tracker.Get("s0")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 5; i++ {
+		if session, _ := tracker.Get(fmt.Sprintf("s%d", i)); session.Requests != 1000 {
+			t.Errorf("Expected 1000 requests for s%d, got %d", i, session.Requests)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	tracker := NewTracker(time.Minute, 0, 0, WithHistory(3))
+	defer tracker.Shutdown()
+
+	// Only recorded sessions have a history
+	tracker.AddResponse("unknown", Response{Names: []string{"Anna"}})
+	if _, ok := tracker.History("unknown"); ok {
+		t.Error("Expected no history for an unknown session")
+	}
+
+	tracker.Record("s1", "A")
+	if history, ok := tracker.History("s1"); !ok || len(history) != 0 {
+		t.Errorf("Expected an empty history, got %v, %v", history, ok)
+	}
+	for _, name := range []string{"Anna", "Ben", "Carl", "Dora", "Emil"} {
+		tracker.AddResponse("s1", Response{Letters: []string{name[:1]}, Names: []string{name}})
+	}
+
+	// The oldest responses make room, and the rest come oldest first
+	history, _ := tracker.History("s1")
+	var names []string
+	for _, response := range history {
+		names = append(names, response.Names...)
+	}
+	if !reflect.DeepEqual(names, []string{"Carl", "Dora", "Emil"}) {
+		t.Errorf("Expected the last 3 responses, got %v", names)
+	}
+
+	// The history is a copy
+	history[0] = Response{}
+	if again, _ := tracker.History("s1"); again[0].Names[0] != "Carl" {
+		t.Error("Expected changes to the history not to affect the tracker")
+	}
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	tracker := NewTracker(time.Minute, 0, 0)
+	defer tracker.Shutdown()
+
+	tracker.Record("s1", "A")
+	tracker.AddResponse("s1", Response{Names: []string{"Anna"}})
+	if history, ok := tracker.History("s1"); !ok || len(history) != 0 {
+		t.Errorf("Expected no responses to be kept, got %v, %v", history, ok)
+	}
+}
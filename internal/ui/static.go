@@ -0,0 +1,22 @@
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// staticFiles holds the scripts and stylesheets of the dashboard, so it works
+// without reaching out to the internet
+//
+//go:embed static
+var staticFiles embed.FS
+
+// StaticHandler serves the dashboard assets under /static/
+func StaticHandler() http.Handler {
+	files, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/static/", http.FileServer(http.FS(files)))
+}
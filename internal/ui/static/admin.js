@@ -0,0 +1,58 @@
+const adminToken = document.getElementById('admin-token');
+const adminResult = document.getElementById('admin-result');
+
+// The token is kept for the browser tab only
+adminToken.value = sessionStorage.getItem('admin-token') || '';
+adminToken.addEventListener('change', () => {
+    sessionStorage.setItem('admin-token', adminToken.value);
+});
+
+// Function to call the admin API with the token, reporting the outcome
+function callAdmin(method, path, body, done) {
+    const options = {method: method, headers: {'Authorization': 'Bearer ' + adminToken.value}};
+    if (body !== undefined) {
+        options.headers['Content-Type'] = 'application/json';
+        options.body = JSON.stringify(body);
+    }
+    fetch(path, options)
+        .then(response => {
+            if (!response.ok) {
+                return response.json()
+                    .catch(() => ({}))
+                    .then(problem => {
+                        throw new Error(problem.detail || problem.title || response.statusText);
+                    });
+            }
+            return response.status === 204 ? null : response.json();
+        })
+        .then(result => {
+            adminResult.classList.remove('status-error');
+            adminResult.textContent = done(result);
+        })
+        .catch(error => {
+            adminResult.classList.add('status-error');
+            adminResult.textContent = 'Failed: ' + error.message;
+        });
+}
+
+document.getElementById('admin-flush-cache').addEventListener('click', () => {
+    if (confirm('Flush the whole cache?')) {
+        callAdmin('DELETE', '/admin/cache', undefined, () => 'Cache flushed');
+    }
+});
+
+document.getElementById('admin-rate-limit').addEventListener('submit', event => {
+    event.preventDefault();
+    const rate = Number(event.target.elements.requests_per_second.value);
+    callAdmin('PUT', '/admin/ratelimit', {requests_per_second: rate}, result =>
+        'Rate limit set to ' + result.requests_per_second + ' requests per second, bursts of ' + result.burst);
+});
+
+document.getElementById('admin-maintenance').addEventListener('submit', event => {
+    event.preventDefault();
+    const form = event.target.elements;
+    callAdmin('PUT', '/admin/maintenance', {enabled: form.enabled.checked, message: form.message.value}, result => {
+        form.enabled.checked = result.enabled;
+        return result.enabled ? 'Maintenance mode on: ' + result.message : 'Maintenance mode off';
+    });
+});
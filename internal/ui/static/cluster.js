@@ -0,0 +1,19 @@
+// Function to refresh the instances and totals of the cluster view
+function updateCluster() {
+    fetch('/stats/cluster/data')
+        .then(response => {
+            if (!response.ok) {
+                throw new Error('Server returned an error');
+            }
+            return response.text();
+        })
+        .then(html => {
+            document.getElementById('cluster-container').innerHTML = html;
+        })
+        .catch(error => {
+            // The view keeps its last figures while this instance is offline
+        });
+}
+
+// Refresh the view every 5 seconds
+setInterval(updateCluster, 5000);
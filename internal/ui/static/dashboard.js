@@ -0,0 +1,184 @@
+// Keep the theme chosen with the toggle, and follow the system in auto
+const themeSelect = document.getElementById('theme-select');
+themeSelect.value = localStorage.getItem('dashboard-theme') || 'auto';
+themeSelect.addEventListener('change', () => {
+    localStorage.setItem('dashboard-theme', themeSelect.value);
+    applyTheme(themeSelect.value);
+});
+themeQuery.addEventListener('change', () => applyTheme(themeSelect.value));
+
+// Function to show whether the server is online
+function setServerStatus(online) {
+    const statusElement = document.getElementById('server-state');
+    statusElement.className = 'server-state ' + (online ? 'server-online' : 'server-offline');
+    statusElement.textContent = 'Server Status: ' + (online ? 'ONLINE' : 'OFFLINE');
+}
+
+// Function to format the time of a request as 15:04:05.000
+function formatTime(value) {
+    const date = new Date(value);
+    const pad = (n, width) => String(n).padStart(width, '0');
+    return pad(date.getHours(), 2) + ':' + pad(date.getMinutes(), 2) + ':' +
+        pad(date.getSeconds(), 2) + '.' + pad(date.getMilliseconds(), 3);
+}
+
+// Function to rebuild the recent requests panel
+function updateRecentRequests(requests) {
+    const panel = document.querySelector('[data-recent-requests]');
+    if (!panel) {
+        return;
+    }
+    const group = panel.querySelector('.stat-group');
+    panel.replaceChildren(group);
+    if (!requests || requests.length === 0) {
+        const empty = document.createElement('div');
+        empty.className = 'stat-name';
+        empty.textContent = 'No requests recorded yet';
+        panel.appendChild(empty);
+        return;
+    }
+    const table = document.createElement('table');
+    const addRow = (cells, tag) => {
+        const row = table.insertRow();
+        cells.forEach(text => {
+            const cell = document.createElement(tag);
+            cell.textContent = text;
+            row.appendChild(cell);
+        });
+        return row;
+    };
+    addRow(['Time', 'Method', 'Path', 'Status', 'Latency', 'Remote Address', 'Session'], 'th');
+    requests.forEach(request => {
+        const row = addRow([
+            formatTime(request.time), request.method, request.path, request.status,
+            request.latency_ms.toFixed(2) + ' ms', request.remote_addr, request.session_id || ''
+        ], 'td');
+        if (request.status >= 400) {
+            row.cells[3].className = 'status-error';
+        }
+    });
+    panel.appendChild(table);
+}
+
+// Function to redraw the occupancy of the cache shards
+function updateCacheShards(shards) {
+    const panel = document.querySelector('[data-cache-shards]');
+    if (!panel || !shards) {
+        return;
+    }
+    panel.replaceChildren(...shards.map((shard, i) => {
+        const bar = document.createElement('span');
+        bar.className = 'cache-shard';
+        bar.title = 'Shard ' + i + ': ' + shard.entries + ' / ' + shard.capacity;
+        const fill = document.createElement('span');
+        fill.style.height = shard.percent + '%';
+        bar.appendChild(fill);
+        return bar;
+    }));
+}
+
+// Function to apply the metrics that changed to the page
+function applyMetrics(changed) {
+    Object.entries(changed).forEach(([name, value]) => {
+        if (name === 'recent_requests') {
+            updateRecentRequests(value);
+            return;
+        }
+        if (name === 'cache_shards') {
+            updateCacheShards(value);
+            return;
+        }
+        document.querySelectorAll('[data-metric="' + name + '"]').forEach(element => {
+            element.textContent = value;
+        });
+        document.querySelectorAll('[data-metric-value="' + name + '"]').forEach(element => {
+            element.value = value;
+        });
+        document.querySelectorAll('[data-metric-max="' + name + '"]').forEach(element => {
+            element.max = value;
+        });
+    });
+}
+
+// The server pushes the metrics that changed; the browser reconnects on its own
+const stream = new EventSource('/stats/stream');
+stream.onopen = () => setServerStatus(true);
+stream.onerror = () => setServerStatus(false);
+stream.onmessage = event => applyMetrics(JSON.parse(event.data));
+
+// The charts and the history metrics they show, with their line colors
+const charts = [
+    {id: 'chart-rps', series: [{metric: 'requests_per_second_1m', color: '#4361ee'}]},
+    {id: 'chart-latency', series: [
+        {metric: 'response_time_p50_ms', color: '#48bb78'},
+        {metric: 'response_time_p90_ms', color: '#ed8936'},
+        {metric: 'response_time_p99_ms', color: '#e53e3e'}
+    ]},
+    {id: 'chart-concurrency', series: [{metric: 'concurrent_requests', color: '#9f7aea'}]}
+];
+const chartMetrics = charts.flatMap(chart => chart.series.map(series => series.metric));
+
+// Function to draw the lines of a chart into its SVG element
+function drawChart(chart, points) {
+    const svg = document.getElementById(chart.id);
+    const width = 600, height = 200, left = 50, bottom = 20, top = 10;
+    let max = 0;
+    points.forEach(point => chart.series.forEach(series => {
+        max = Math.max(max, point.values[series.metric] || 0);
+    }));
+    max = max > 0 ? max * 1.1 : 1;
+
+    const x = i => left + (points.length > 1 ? i / (points.length - 1) : 0) * (width - left);
+    const y = v => top + (1 - v / max) * (height - top - bottom);
+    const label = v => v >= 100 ? v.toFixed(0) : v.toFixed(2);
+    const time = point => new Date(point.time).toLocaleTimeString();
+
+    let content = '';
+    for (let i = 0; i <= 4; i++) {
+        const v = max * i / 4;
+        content += '<line class="grid-line" x1="' + left + '" x2="' + width + '" y1="' + y(v) + '" y2="' + y(v) + '"/>';
+        content += '<text class="axis-label" x="' + (left - 5) + '" y="' + (y(v) + 4) + '" text-anchor="end">' + label(v) + '</text>';
+    }
+    if (points.length === 0) {
+        content += '<text class="axis-label" x="' + (width / 2) + '" y="' + (height / 2) + '" text-anchor="middle">No data yet</text>';
+    } else {
+        content += '<text class="axis-label" x="' + left + '" y="' + (height - 4) + '">' + time(points[0]) + '</text>';
+        content += '<text class="axis-label" x="' + width + '" y="' + (height - 4) + '" text-anchor="end">' + time(points[points.length - 1]) + '</text>';
+    }
+    chart.series.forEach(series => {
+        const line = points.map((point, i) => x(i) + ',' + y(point.values[series.metric] || 0)).join(' ');
+        content += '<polyline stroke="' + series.color + '" points="' + line + '"/>';
+    });
+    svg.innerHTML = content;
+}
+
+// Function to fetch the history of the chosen window and redraw the charts
+function updateCharts() {
+    const chartWindow = document.getElementById('chart-window').value;
+    document.getElementById('chart-export').href = '/stats/export?window=' + chartWindow;
+    fetch('/stats/history?window=' + chartWindow + '&metrics=' + chartMetrics.join(','))
+        .then(response => {
+            // Without a history there is nothing to chart
+            if (response.status === 501) {
+                document.getElementById('charts').hidden = true;
+                return null;
+            }
+            if (!response.ok) {
+                throw new Error('Server returned an error');
+            }
+            return response.json();
+        })
+        .then(history => {
+            if (history) {
+                charts.forEach(chart => drawChart(chart, history.points));
+            }
+        })
+        .catch(error => {
+            // The charts keep their last lines while the server is offline
+        });
+}
+
+// Update the charts initially, every 10 seconds and when the window changes
+updateCharts();
+setInterval(updateCharts, 10000);
+document.getElementById('chart-window').addEventListener('change', updateCharts);
@@ -0,0 +1,252 @@
+// Renders the OpenAPI document of the server, with a form to try each
+// operation against it; the document is read from the URL in data-spec
+const root = document.getElementById('docs');
+const methods = ['get', 'put', 'post', 'delete', 'patch', 'head', 'options'];
+let spec;
+
+// Function to create an element with a class and text
+function element(tag, className, text) {
+    const node = document.createElement(tag);
+    if (className) {
+        node.className = className;
+    }
+    if (text !== undefined) {
+        node.textContent = text;
+    }
+    return node;
+}
+
+// Function to follow a $ref to its schema in the components
+function resolve(schema) {
+    while (schema && schema.$ref) {
+        schema = schema.$ref.replace(/^#\//, '').split('/').reduce((node, key) => node && node[key], spec);
+    }
+    return schema || {};
+}
+
+// Function to describe the type of a schema in a few words
+function typeName(schema) {
+    if (schema.$ref) {
+        return schema.$ref.split('/').pop();
+    }
+    if (schema.oneOf) {
+        return schema.oneOf.map(typeName).join(' | ');
+    }
+    if (schema.type === 'array') {
+        return typeName(schema.items || {}) + '[]';
+    }
+    let name = schema.type || 'any';
+    if (schema.format) {
+        name += ' (' + schema.format + ')';
+    }
+    if (schema.enum) {
+        name += ': ' + schema.enum.join(', ');
+    }
+    return name;
+}
+
+// Function to build an example value of a schema, for the request body form
+function example(schema, depth) {
+    schema = resolve(schema);
+    if (depth > 4) {
+        return null;
+    }
+    if (schema.example !== undefined) {
+        return schema.example;
+    }
+    if (schema.default !== undefined) {
+        return schema.default;
+    }
+    if (schema.enum) {
+        return schema.enum[0];
+    }
+    if (schema.oneOf) {
+        return example(schema.oneOf[0], depth + 1);
+    }
+    switch (schema.type) {
+    case 'object': {
+        const value = {};
+        Object.entries(schema.properties || {}).forEach(([name, property]) => {
+            if ((schema.required || []).includes(name)) {
+                value[name] = example(property, depth + 1);
+            }
+        });
+        return value;
+    }
+    case 'array':
+        return [example(schema.items || {}, depth + 1)];
+    case 'integer':
+    case 'number':
+        return schema.minimum || 0;
+    case 'boolean':
+        return false;
+    case 'string':
+        return '';
+    }
+    return null;
+}
+
+// Function to render the properties of a schema as a table
+function schemaTable(schema) {
+    const resolved = resolve(schema);
+    if (!resolved.properties) {
+        return element('div', 'schema-type', typeName(schema));
+    }
+    const table = element('table', 'schema');
+    Object.entries(resolved.properties).forEach(([name, property]) => {
+        const row = table.insertRow();
+        const required = (resolved.required || []).includes(name);
+        row.appendChild(element('td', required ? 'field required' : 'field', name));
+        row.appendChild(element('td', 'schema-type', typeName(property)));
+        row.appendChild(element('td', 'description', resolve(property).description || property.description || ''));
+    });
+    return table;
+}
+
+// Function to render the form that sends a request for an operation
+function tryForm(method, path, operation) {
+    const form = element('form', 'try');
+    const inputs = {};
+    (operation.parameters || []).forEach(parameter => {
+        const label = element('label', '', parameter.name + (parameter.required ? ' *' : '') + ' (' + parameter.in + ')');
+        const input = element('input');
+        input.name = parameter.name;
+        input.required = !!parameter.required;
+        input.placeholder = typeName(parameter.schema || {});
+        inputs[parameter.name] = {parameter, input};
+        label.appendChild(input);
+        form.appendChild(label);
+    });
+
+    let body, contentType;
+    const content = operation.requestBody && operation.requestBody.content;
+    if (content) {
+        contentType = content['application/json'] ? 'application/json' : Object.keys(content)[0];
+        body = element('textarea');
+        body.rows = 6;
+        if (contentType === 'application/json') {
+            body.value = JSON.stringify(example(content[contentType].schema, 0), null, 2);
+        }
+        const label = element('label', '', 'Body (' + contentType + ')');
+        label.appendChild(body);
+        form.appendChild(label);
+    }
+
+    const send = element('button', '', 'Send');
+    send.type = 'submit';
+    form.appendChild(send);
+    const output = element('pre', 'response');
+    output.hidden = true;
+    form.appendChild(output);
+
+    form.addEventListener('submit', async event => {
+        event.preventDefault();
+        let url = path;
+        const query = new URLSearchParams();
+        Object.values(inputs).forEach(({parameter, input}) => {
+            if (input.value === '') {
+                return;
+            }
+            if (parameter.in === 'path') {
+                url = url.replace('{' + parameter.name + '}', encodeURIComponent(input.value));
+            } else if (parameter.in === 'query') {
+                query.append(parameter.name, input.value);
+            }
+        });
+        if (query.toString()) {
+            url += '?' + query;
+        }
+        const headers = {};
+        const token = document.getElementById('token').value.trim();
+        if (token) {
+            headers.Authorization = 'Bearer ' + token;
+        }
+        const request = {method: method.toUpperCase(), headers};
+        if (body) {
+            headers['Content-Type'] = contentType;
+            request.body = body.value;
+        }
+
+        output.hidden = false;
+        output.textContent = request.method + ' ' + url + '\n\n…';
+        try {
+            const response = await fetch(url, request);
+            let text = await response.text();
+            try {
+                text = JSON.stringify(JSON.parse(text), null, 2);
+            } catch (e) {
+                // Not JSON, shown as it is
+            }
+            output.textContent = request.method + ' ' + url + '\n\n' + response.status + ' ' + response.statusText + '\n' + text;
+        } catch (e) {
+            output.textContent = request.method + ' ' + url + '\n\n' + e;
+        }
+    });
+    return form;
+}
+
+// Function to render an operation as a collapsible section
+function operationSection(method, path, operation) {
+    const section = element('details', 'operation method-' + method);
+    const summary = element('summary');
+    summary.appendChild(element('span', 'method', method.toUpperCase()));
+    summary.appendChild(element('span', 'path', path));
+    summary.appendChild(element('span', 'summary', operation.summary || ''));
+    section.appendChild(summary);
+
+    if (operation.description) {
+        section.appendChild(element('p', 'description', operation.description));
+    }
+    if (operation.requestBody && operation.requestBody.content) {
+        section.appendChild(element('h4', '', 'Request body'));
+        Object.entries(operation.requestBody.content).forEach(([type, media]) => {
+            section.appendChild(element('div', 'content-type', type));
+            section.appendChild(schemaTable(media.schema || {}));
+        });
+    }
+    section.appendChild(element('h4', '', 'Responses'));
+    const responses = element('table', 'responses');
+    Object.entries(operation.responses || {}).forEach(([status, response]) => {
+        const row = responses.insertRow();
+        row.appendChild(element('td', 'status', status));
+        row.appendChild(element('td', 'description', response.description || ''));
+        row.appendChild(element('td', 'content-type', Object.keys(response.content || {}).join(', ')));
+    });
+    section.appendChild(responses);
+    section.appendChild(element('h4', '', 'Try it'));
+    section.appendChild(tryForm(method, path, operation));
+    return section;
+}
+
+// Function to render the whole document
+function render() {
+    document.title = spec.info.title;
+    root.replaceChildren();
+    const header = element('header');
+    header.appendChild(element('h1', '', spec.info.title + ' ' + spec.info.version));
+    header.appendChild(element('p', 'description', spec.info.description || ''));
+    const token = element('label', 'token', 'Bearer token ');
+    const input = element('input');
+    input.id = 'token';
+    input.type = 'password';
+    input.autocomplete = 'off';
+    token.appendChild(input);
+    header.appendChild(token);
+    root.appendChild(header);
+
+    Object.entries(spec.paths).forEach(([path, item]) => {
+        methods.filter(method => item[method]).forEach(method => {
+            root.appendChild(operationSection(method, path, item[method]));
+        });
+    });
+}
+
+fetch(root.dataset.spec)
+    .then(response => response.json())
+    .then(doc => {
+        spec = doc;
+        render();
+    })
+    .catch(error => {
+        root.replaceChildren(element('p', 'error', 'Failed to load the API description: ' + error));
+    });
@@ -0,0 +1,33 @@
+const filters = document.getElementById('request-filters');
+
+// Function to refresh the requests matching the filters
+function updateRequests() {
+    const query = new URLSearchParams(new FormData(filters)).toString();
+    fetch('/stats/requests/data?' + query)
+        .then(response => {
+            if (!response.ok) {
+                throw new Error('Server returned an error');
+            }
+            return response.text();
+        })
+        .then(html => {
+            document.getElementById('requests-container').innerHTML = html;
+        })
+        .catch(error => {
+            // The page keeps the last requests while the filters are invalid
+            // or the server is offline
+        });
+}
+
+// Apply the filters as they are typed, keeping them in the address for reloads
+function applyFilters(event) {
+    event.preventDefault();
+    const query = new URLSearchParams(new FormData(filters)).toString();
+    history.replaceState(null, '', '/stats/requests' + (query ? '?' + query : ''));
+    updateRequests();
+}
+filters.addEventListener('input', applyFilters);
+filters.addEventListener('submit', applyFilters);
+
+// Refresh the requests every 2 seconds
+setInterval(updateRequests, 2000);
@@ -0,0 +1,8 @@
+// Apply the chosen theme before the page is drawn, so it doesn't flash
+// in the other one; "auto" follows the system setting
+const themeQuery = window.matchMedia('(prefers-color-scheme: dark)');
+function applyTheme(choice) {
+    const theme = choice === 'auto' ? (themeQuery.matches ? 'dark' : 'light') : choice;
+    document.documentElement.dataset.theme = theme;
+}
+applyTheme(localStorage.getItem('dashboard-theme') || 'auto');
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// staticFile returns the content of an embedded asset
+func staticFile(t *testing.T, name string) string {
+	t.Helper()
+	content, err := staticFiles.ReadFile("static/" + name)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", name, err)
+	}
+	return string(content)
+}
+
+func TestStaticHandler(t *testing.T) {
+	Initialize()
+	handler := StaticHandler()
+
+	for path, contentType := range map[string]string{
+		"/static/dashboard.js":  "text/javascript",
+		"/static/dashboard.css": "text/css",
+		"/static/theme.js":      "text/javascript",
+		"/static/theme.css":     "text/css",
+		"/static/requests.js":   "text/javascript",
+		"/static/admin.js":      "text/javascript",
+		"/static/docs/docs.js":  "text/javascript",
+		"/static/docs/docs.css": "text/css",
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), contentType) || rr.Body.Len() == 0 {
+			t.Errorf("Expected %s to be served as %s, got %d %s", path, contentType, rr.Code, rr.Header().Get("Content-Type"))
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/static/missing.js", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected a missing asset to be 404, got %d", rr.Code)
+	}
+
+	// Every asset the page refers to is embedded, and nothing is loaded from elsewhere
+	var page strings.Builder
+	if err := StatsTemplate.Execute(&page, map[string]string{}); err != nil {
+		t.Fatalf("Failed to render main template: %v", err)
+	}
+	for _, attribute := range []string{`src="`, `href="`} {
+		for _, part := range strings.Split(page.String(), attribute)[1:] {
+			path := part[:strings.Index(part, `"`)]
+			switch {
+			case strings.Contains(path, "//"):
+				t.Errorf("Expected only embedded assets, got %s", path)
+			case strings.HasPrefix(path, "/static/"):
+				staticFile(t, strings.TrimPrefix(path, "/static/"))
+			}
+		}
+	}
+}
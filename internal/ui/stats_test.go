@@ -2,8 +2,11 @@ package ui
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseStatsReport(t *testing.T) {
@@ -13,19 +16,19 @@ func TestParseStatsReport(t *testing.T) {
 ### requests_total - 6
 ### requests_succeeded - 5
 ### requests_failed - 0
-### requests_per_second - 0.19`
+### requests_per_second_1m - 0.19`
 
 	// Parse the stats report
 	stats := ParseStatsReport(statsReport)
 
 	// Check the parsed stats
 	expected := map[string]string{
-		"uptime":             "32.069411s",
-		"requests_total":     "6",
-		"requests_succeeded": "5",
-		"requests_failed":    "0",
-		"requests_per_second": "0.19",
-		"status":             "ONLINE",
+		"uptime":                 "32.069411s",
+		"requests_total":         "6",
+		"requests_succeeded":     "5",
+		"requests_failed":        "0",
+		"requests_per_second_1m": "0.19",
+		"status":                 "ONLINE",
 	}
 
 	// Compare the parsed stats with the expected stats
@@ -50,20 +53,21 @@ func TestTemplateRendering(t *testing.T) {
 
 	// Create a sample data map for the template
 	data := map[string]string{
-		"uptime":              "32.069411s",
-		"requests_total":      "6",
-		"requests_succeeded":  "5",
-		"requests_failed":     "0",
-		"requests_per_second": "0.19",
-		"success_rate":        "83.33%",
-		"concurrent_requests": "1",
-		"max_concurrent":      "1000",
-		"server_load":         "0.01/10",
-		"memory_usage":        "0.35 MB",
-		"cpu_usage":           "0.72%",
-		"p50_response_time":   "110.083µs",
-		"p90_response_time":   "318.875µs",
-		"p99_response_time":   "318.875µs",
+		"uptime":                 "32.069411s",
+		"requests_total":         "6",
+		"requests_succeeded":     "5",
+		"requests_failed":        "0",
+		"requests_per_second_1m": "0.19",
+		"requests_per_second_5m": "0.07",
+		"success_rate":           "83.33%",
+		"concurrent_requests":    "1",
+		"max_concurrent":         "1000",
+		"server_load":            "0.01/10",
+		"memory_usage":           "0.35 MB",
+		"cpu_usage":              "0.72%",
+		"p50_response_time":      "110.083µs",
+		"p90_response_time":      "318.875µs",
+		"p99_response_time":      "318.875µs",
 	}
 
 	// Try to render the template
@@ -104,3 +108,314 @@ func TestTemplateRendering(t *testing.T) {
 		}
 	}
 }
+
+func TestRecentRequestsPanel(t *testing.T) {
+	Initialize()
+
+	// The panel reads the fields of the recent requests
+	type recentRequest struct {
+		Time       time.Time
+		Method     string
+		Path       string
+		Status     int
+		LatencyMS  float64
+		RemoteAddr string
+		SessionID  string
+	}
+	data := map[string]interface{}{
+		"recent_requests": []recentRequest{
+			{Time: time.Date(2024, 5, 1, 12, 30, 15, 0, time.UTC), Method: "GET", Path: "/names/A", Status: 200, LatencyMS: 1.234, RemoteAddr: "10.0.0.1:5000", SessionID: "s1"},
+			{Method: "POST", Path: "/generate", Status: 429},
+		},
+	}
+	var buf bytes.Buffer
+	if err := StatsTemplate.ExecuteTemplate(&buf, "statsData", data); err != nil {
+		t.Fatalf("Failed to render statsData template: %v", err)
+	}
+	rendered := buf.String()
+	for _, expected := range []string{"Recent Requests", "12:30:15.000", "/names/A", "1.23 ms", "10.0.0.1:5000", "s1", `class="status-error">429`} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected the panel to contain %s", expected)
+		}
+	}
+
+	// Without requests the panel says so
+	buf.Reset()
+	if err := StatsTemplate.ExecuteTemplate(&buf, "statsData", map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "No requests recorded yet") {
+		t.Error("Expected an empty panel")
+	}
+}
+
+func TestStatusPanel(t *testing.T) {
+	Initialize()
+
+	data := map[string]interface{}{
+		"responses_total": uint64(10),
+		"responses_2xx":   uint64(6),
+		"responses_3xx":   uint64(0),
+		"responses_4xx":   uint64(3),
+		"responses_5xx":   uint64(1),
+		"responses_429":   uint64(2),
+	}
+	var buf bytes.Buffer
+	if err := StatsTemplate.ExecuteTemplate(&buf, "statsData", data); err != nil {
+		t.Fatalf("Failed to render statsData template: %v", err)
+	}
+	rendered := buf.String()
+	for _, expected := range []string{
+		"Responses by Status",
+		`<meter value="6" max="10" data-metric-value="responses_2xx" data-metric-max="responses_total">`,
+		`<meter value="2" max="10" data-metric-value="responses_429" data-metric-max="responses_total">`,
+		`class="status-error" data-metric="responses_5xx">1<`,
+	} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected the panel to contain %s", expected)
+		}
+	}
+}
+
+func TestRuntimeCards(t *testing.T) {
+	Initialize()
+
+	data := map[string]interface{}{
+		"goroutines":     5012,
+		"heap_in_use":    "48.20 MB",
+		"next_gc":        "64.00 MB",
+		"heap_objects":   uint64(230411),
+		"gc_runs":        uint32(37),
+		"gc_pause_total": "12.4ms",
+		"gc_last_pause":  "310µs",
+	}
+	var buf bytes.Buffer
+	if err := StatsTemplate.ExecuteTemplate(&buf, "statsData", data); err != nil {
+		t.Fatalf("Failed to render statsData template: %v", err)
+	}
+	rendered := buf.String()
+	for _, expected := range []string{"Goroutines", "5012", `<span data-metric="heap_in_use">48.20 MB</span> / <span data-metric="next_gc">64.00 MB</span>`, `<span data-metric="heap_objects">230411</span> objects`, `<span data-metric="gc_pause_total">12.4ms</span> / <span data-metric="gc_runs">37</span>`, `Last pause <span data-metric="gc_last_pause">310µs</span>`} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected the runtime cards to contain %s", expected)
+		}
+	}
+}
+
+func TestHistoryCharts(t *testing.T) {
+	Initialize()
+
+	// The charts sit outside the refreshed stats, and are drawn from the history
+	var buf bytes.Buffer
+	if err := StatsTemplate.Execute(&buf, map[string]string{}); err != nil {
+		t.Fatalf("Failed to render main template: %v", err)
+	}
+	rendered := buf.String()
+	charts := strings.Index(rendered, `id="charts"`)
+	if charts < 0 || charts > strings.Index(rendered, `id="stats-container"`) {
+		t.Fatal("Expected the charts before the refreshed stats")
+	}
+	for _, expected := range []string{`id="chart-rps"`, `id="chart-latency"`, `id="chart-concurrency"`, `id="chart-window"`, `id="chart-export" href="/stats/export?window=15m" download`} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected the page to contain %s", expected)
+		}
+	}
+	script := staticFile(t, "dashboard.js")
+	for _, expected := range []string{"/stats/history?window=", "/stats/export?window=", "requests_per_second_1m", "response_time_p99_ms", "concurrent_requests"} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("Expected the script to contain %s", expected)
+		}
+	}
+
+	// The refreshed stats don't redraw the charts
+	buf.Reset()
+	if err := StatsTemplate.ExecuteTemplate(&buf, "statsData", map[string]string{}); err != nil {
+		t.Fatalf("Failed to render statsData template: %v", err)
+	}
+	if strings.Contains(buf.String(), "chart-") {
+		t.Error("Expected no charts in the refreshed stats")
+	}
+}
+
+func TestLiveUpdates(t *testing.T) {
+	Initialize()
+
+	// The page follows the pushed changes instead of polling
+	var buf bytes.Buffer
+	if err := StatsTemplate.Execute(&buf, map[string]interface{}{"requests_total": 5}); err != nil {
+		t.Fatalf("Failed to render main template: %v", err)
+	}
+	rendered := buf.String()
+	if !strings.Contains(rendered, `<script src="/static/dashboard.js">`) || !strings.Contains(staticFile(t, "dashboard.js"), "new EventSource('/stats/stream')") {
+		t.Error("Expected the page to subscribe to /stats/stream")
+	}
+	if strings.Contains(rendered, "hx-get") || strings.Contains(rendered, "htmx") {
+		t.Error("Expected no polling of /stats/data")
+	}
+
+	// The values are tagged with the metric they show, the first ones rendered by the server
+	for _, expected := range []string{`data-metric="requests_total">5<`, `data-metric="uptime"`, `data-metric-value="responses_2xx" data-metric-max="responses_total"`, "data-recent-requests"} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected the page to contain %s", expected)
+		}
+	}
+}
+
+func TestThemes(t *testing.T) {
+	Initialize()
+
+	var buf bytes.Buffer
+	if err := StatsTemplate.Execute(&buf, map[string]string{}); err != nil {
+		t.Fatalf("Failed to render main template: %v", err)
+	}
+	rendered := buf.String()
+
+	// Both themes define the colors the rules use, and the choice is kept
+	for _, expected := range []string{`id="theme-select"`, `<option value="auto">`, `href="/static/theme.css"`} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected the page to contain %s", expected)
+		}
+	}
+	for file, expected := range map[string][]string{
+		"theme.css":     {`:root {`, `:root[data-theme="dark"] {`, "--card-background"},
+		"dashboard.css": {"background-color: var(--card-background)"},
+		"theme.js":      {"applyTheme(localStorage", "prefers-color-scheme: dark"},
+		"dashboard.js":  {"localStorage.setItem('dashboard-theme'"},
+	} {
+		content := staticFile(t, file)
+		for _, e := range expected {
+			if !strings.Contains(content, e) {
+				t.Errorf("Expected %s to contain %s", file, e)
+			}
+		}
+	}
+
+	// The theme is applied before the page is drawn
+	if strings.Index(rendered, `<script src="/static/theme.js">`) > strings.Index(rendered, "<body>") {
+		t.Error("Expected the theme to be applied in the head")
+	}
+}
+
+func TestCachePanel(t *testing.T) {
+	Initialize()
+
+	type shard struct {
+		Entries, Capacity int
+		Percent           float64
+	}
+	data := map[string]interface{}{
+		"cache_entries":   30,
+		"cache_capacity":  100,
+		"cache_hit_ratio": "75.00%",
+		"cache_evictions": uint64(4),
+		"cache_shards":    []shard{{Entries: 25, Capacity: 50, Percent: 50}, {Entries: 5, Capacity: 50, Percent: 10}},
+	}
+	var buf bytes.Buffer
+	if err := StatsTemplate.ExecuteTemplate(&buf, "statsData", data); err != nil {
+		t.Fatalf("Failed to render statsData template: %v", err)
+	}
+	rendered := buf.String()
+	for _, expected := range []string{
+		`<span data-metric="cache_entries">30</span> / <span data-metric="cache_capacity">100</span>`,
+		`data-metric="cache_hit_ratio">75.00%<`,
+		`<span data-metric="cache_evictions">4</span>`,
+		`title="Shard 0: 25 / 50"><span style="height: 50%">`,
+		`title="Shard 1: 5 / 50"><span style="height: 10%">`,
+	} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected the cache panel to contain %s", expected)
+		}
+	}
+	if !strings.Contains(staticFile(t, "dashboard.js"), "updateCacheShards") {
+		t.Error("Expected the shards to be redrawn on updates")
+	}
+
+	// Without shards there is no occupancy to show
+	buf.Reset()
+	delete(data, "cache_shards")
+	if err := StatsTemplate.ExecuteTemplate(&buf, "statsData", data); err != nil {
+		t.Fatalf("Failed to render statsData template: %v", err)
+	}
+	if strings.Contains(buf.String(), "Occupancy by Shard") {
+		t.Error("Expected no occupancy without shards")
+	}
+}
+
+func TestAdminControls(t *testing.T) {
+	Initialize()
+	
+	// The controls are only shown when the admin API is enabled
+	var buf bytes.Buffer
+	if err := StatsTemplate.Execute(&buf, map[string]interface{}{"admin_enabled": false}); err != nil {
+		t.Fatalf("Failed to render main template: %v", err)
+	}
+	if strings.Contains(buf.String(), "admin-controls") {
+		t.Error("Expected no admin controls without the admin API")
+	}
+	
+	buf.Reset()
+	if err := StatsTemplate.Execute(&buf, map[string]interface{}{"admin_enabled": true, "rate_limit": 250.0, "maintenance": true}); err != nil {
+		t.Fatalf("Failed to render main template: %v", err)
+	}
+	rendered := buf.String()
+	for _, expected := range []string{`id="admin-flush-cache"`, `name="requests_per_second" min="1" step="any" value="250"`, `name="enabled" checked`, `<script src="/static/admin.js">`} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("Expected the admin controls to contain %s", expected)
+		}
+	}
+	
+	// The controls call the admin endpoints with the token
+	script := staticFile(t, "admin.js")
+	for _, expected := range []string{"'DELETE', '/admin/cache'", "'PUT', '/admin/ratelimit'", "'PUT', '/admin/maintenance'", "'Bearer ' + adminToken.value"} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("Expected admin.js to contain %s", expected)
+		}
+	}
+}
+
+func TestTemplateOverrides(t *testing.T) {
+	defer Initialize()
+	
+	// A replacement of the data template using a panel defined in a file of its own
+	dir := t.TempDir()
+	files := map[string]string{
+		"stats_data.tmpl": `<div class="branded">{{template "panel" .}}</div>`,
+		"panel.tmpl":      `{{define "panel"}}Requests: {{.requests_total}}{{end}}`,
+		"notes.txt":       `{{not a template`,
+	}
+	for name, text := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	overridden, err := InitializeWithOverrides(dir)
+	if err != nil {
+		t.Fatalf("Failed to load the templates: %v", err)
+	}
+	if len(overridden) != 2 || overridden[0] != "stats_data.tmpl" || overridden[1] != "panel.tmpl" {
+		t.Errorf("Expected the data template and the panel to be taken, got %v", overridden)
+	}
+	
+	// The page keeps its embedded layout around the replacement
+	var buf bytes.Buffer
+	if err := StatsTemplate.Execute(&buf, map[string]interface{}{"requests_total": 5}); err != nil {
+		t.Fatalf("Failed to render main template: %v", err)
+	}
+	if rendered := buf.String(); !strings.Contains(rendered, `<div class="branded">Requests: 5</div>`) || !strings.Contains(rendered, "Real-time Server Statistics") {
+		t.Errorf("Expected the page with the replaced data template, got %s", rendered)
+	}
+	
+	// A template that doesn't parse keeps the embedded ones
+	if err := os.WriteFile(filepath.Join(dir, "cluster.tmpl"), []byte(`{{if}}`), 0o644); err != nil {
+		t.Fatalf("Failed to write cluster.tmpl: %v", err)
+	}
+	if _, err := InitializeWithOverrides(dir); err == nil || !strings.Contains(err.Error(), "cluster.tmpl") {
+		t.Errorf("Expected an error naming cluster.tmpl, got %v", err)
+	}
+	buf.Reset()
+	if err := StatsTemplate.ExecuteTemplate(&buf, "statsData", map[string]interface{}{}); err != nil || strings.Contains(buf.String(), "branded") {
+		t.Errorf("Expected the embedded data template after the error, got %v %s", err, buf.String())
+	}
+	if _, err := InitializeWithOverrides(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
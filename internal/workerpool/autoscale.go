@@ -0,0 +1,138 @@
+package workerpool
+
+import (
+	"time"
+)
+
+// Defaults of AutoscaleConfig
+const (
+	DefaultScaleInterval = 250 * time.Millisecond
+	DefaultMaxWait       = 20 * time.Millisecond
+)
+
+// AutoscaleConfig lets the number of workers of a pool follow its load
+// Every Interval, workers are added when tasks queue up while all workers are
+// busy or when a task waited longer than MaxWait for a worker, as many as
+// there are tasks queued. Workers that stayed idle since the last check are
+// stopped, half of them at a time, once the queue is empty
+type AutoscaleConfig struct {
+	MinWorkers int
+	MaxWorkers int
+	Interval   time.Duration    // How often the load is checked (DefaultScaleInterval if 0)
+	MaxWait    time.Duration    // Longest wait for a worker before workers are added (DefaultMaxWait if 0)
+	OnScale    func(ScaleEvent) // Called after workers were added or stopped, e.g. to log it
+}
+
+// ScaleEvent describes a change of the number of workers of a pool
+type ScaleEvent struct {
+	From   int
+	To     int
+	Queued int           // Tasks waiting for a worker when the pool scaled
+	Wait   time.Duration // Longest wait for a worker since the previous check
+}
+
+// WithAutoscaling lets the number of workers follow the load between
+// config.MinWorkers and config.MaxWorkers, starting with the number given to New
+func WithAutoscaling(config AutoscaleConfig) Option {
+	return func(wp *WorkerPool) {
+		if config.MinWorkers < 1 {
+			config.MinWorkers = 1
+		}
+		if config.MaxWorkers < config.MinWorkers {
+			config.MaxWorkers = config.MinWorkers
+		}
+		if config.Interval <= 0 {
+			config.Interval = DefaultScaleInterval
+		}
+		if config.MaxWait <= 0 {
+			config.MaxWait = DefaultMaxWait
+		}
+		wp.numWorkers = min(max(wp.numWorkers, config.MinWorkers), config.MaxWorkers)
+		wp.autoscale = &config
+	}
+}
+
+// ScaleEvents returns how many times workers were added and stopped
+func (wp *WorkerPool) ScaleEvents() (up, down uint64) {
+	return wp.scaleUps.Load(), wp.scaleDowns.Load()
+}
+
+// recordWait keeps the longest time a task waited for a worker
+func (wp *WorkerPool) recordWait(wait time.Duration) {
+	for {
+		longest := wp.maxWait.Load()
+		if int64(wait) <= longest || wp.maxWait.CompareAndSwap(longest, int64(wait)) {
+			return
+		}
+	}
+}
+
+// recordBusy keeps the most workers busy at once
+func (wp *WorkerPool) recordBusy(busy int64) {
+	for {
+		peak := wp.peakBusy.Load()
+		if busy <= peak || wp.peakBusy.CompareAndSwap(peak, busy) {
+			return
+		}
+	}
+}
+
+// autoscaler checks the load every Interval until the pool shuts down
+func (wp *WorkerPool) autoscaler() {
+	defer wp.wg.Done()
+
+	ticker := time.NewTicker(wp.autoscale.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-wp.ctx.Done():
+			return
+		case <-ticker.C:
+			wp.scale()
+		}
+	}
+}
+
+// scale adds or stops workers for the load since the previous check
+func (wp *WorkerPool) scale() {
+	config := wp.autoscale
+	workers := wp.Workers()
+	queued := wp.Queued()
+	wait := time.Duration(wp.maxWait.Swap(0))
+	peak := int(wp.peakBusy.Swap(wp.busy.Load()))
+
+	event := ScaleEvent{From: workers, To: workers, Queued: queued, Wait: wait}
+	switch {
+	case workers < config.MaxWorkers && (wait > config.MaxWait || (queued > 0 && peak >= workers)):
+		event.To = min(workers+max(queued, 1), config.MaxWorkers)
+		wp.addWorkers(event.To - workers)
+		wp.scaleUps.Add(1)
+
+	case workers > config.MinWorkers && queued == 0 && peak < workers:
+		stop := min(max((workers-peak)/2, 1), workers-config.MinWorkers)
+		for event.To > workers-stop && wp.stopIdleWorker() {
+			event.To--
+		}
+		if event.To == workers {
+			return
+		}
+		wp.scaleDowns.Add(1)
+
+	default:
+		return
+	}
+
+	if config.OnScale != nil {
+		config.OnScale(event)
+	}
+}
+
+// stopIdleWorker stops a worker waiting for a task, if there is one
+func (wp *WorkerPool) stopIdleWorker() bool {
+	select {
+	case wp.quit <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
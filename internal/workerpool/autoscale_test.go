@@ -0,0 +1,100 @@
+package workerpool
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForWorkers waits until the pool runs the given number of workers
+func waitForWorkers(t *testing.T, wp *WorkerPool, workers int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for wp.Workers() != workers {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d workers, got %d", workers, wp.Workers())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAutoscaling(t *testing.T) {
+	var mutex sync.Mutex
+	var events []ScaleEvent
+	wp := New(1, WithAutoscaling(AutoscaleConfig{
+		MinWorkers: 2,
+		MaxWorkers: 6,
+		Interval:   10 * time.Millisecond,
+		OnScale: func(event ScaleEvent) {
+			mutex.Lock()
+			events = append(events, event)
+			mutex.Unlock()
+		},
+	}))
+	defer wp.Shutdown()
+
+	// The pool starts with at least MinWorkers
+	if wp.Workers() != 2 {
+		t.Errorf("Expected to start with 2 workers, got %d", wp.Workers())
+	}
+
+	// Tasks queuing up behind busy workers add workers, up to MaxWorkers
+	release := make(chan struct{})
+	results := make([]<-chan Result, 10)
+	for i := range results {
+		results[i] = wp.Submit(func() interface{} {
+			<-release
+			return nil
+		})
+	}
+	waitForWorkers(t, wp, 6)
+
+	// Once the tasks are done, the idle workers are stopped, down to MinWorkers
+	close(release)
+	for _, result := range results {
+		<-result
+	}
+	waitForWorkers(t, wp, 2)
+
+	up, down := wp.ScaleEvents()
+	if up == 0 || down == 0 {
+		t.Errorf("Expected the pool to scale up and down, got %d and %d", up, down)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(events) != int(up+down) || events[0].From != 2 || events[0].To <= 2 || events[0].Queued == 0 {
+		t.Errorf("Expected an event per change, starting with workers added for queued tasks, got %+v", events)
+	}
+	for _, event := range events {
+		if event.To < 2 || event.To > 6 {
+			t.Errorf("Expected the workers to stay between 2 and 6, got %+v", event)
+		}
+	}
+}
+
+func TestAutoscalingWait(t *testing.T) {
+	// A single worker kept busy makes the next task wait longer than MaxWait
+	wp := New(1, WithAutoscaling(AutoscaleConfig{MinWorkers: 1, MaxWorkers: 3, Interval: time.Hour, MaxWait: time.Millisecond}))
+	defer wp.Shutdown()
+
+	first := wp.Submit(func() interface{} {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	second := wp.Submit(func() interface{} { return nil })
+	<-first
+	<-second
+
+	wp.scale()
+	if wp.Workers() != 2 {
+		t.Errorf("Expected a worker to be added after a long wait, got %d workers", wp.Workers())
+	}
+}
+
+func TestFixedPoolDoesNotScale(t *testing.T) {
+	wp := New(3)
+	defer wp.Shutdown()
+	if wp.Workers() != 3 || wp.autoscale != nil {
+		t.Errorf("Expected 3 workers without autoscaling, got %d", wp.Workers())
+	}
+}
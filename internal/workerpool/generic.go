@@ -0,0 +1,148 @@
+package workerpool
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// ResultOf is the typed result of a task submitted with Submit or SubmitBatch
+// Err is the error the task returned, or a PanicError, ErrTaskTimeout,
+// ErrQueueFull, ErrPoolClosed, or the error of the task's context if it was
+// done before the task ran
+type ResultOf[T any] struct {
+	Value T
+	Err   error
+}
+
+// job is a queued task, run by a worker on behalf of the caller that submitted it
+// run returns a deliver function passing the task's value and error to the
+// caller, which the worker calls once the task finished in time. A task that
+// didn't finish, because it panicked or timed out, its context was done, or
+// the pool shut down without running it, is passed to report instead. Every
+// job reaches its caller exactly once, through one or the other
+// The result is handed back by run rather than kept in the job, since a task
+// that timed out still runs while its failure is reported
+type job interface {
+	run() (deliver func(), err error) // deliver passes the task's value and error err to the caller
+	report(err error)                 // Reports a task that didn't finish
+}
+
+// batch collects the results of the tasks submitted together
+type batch[T any] struct {
+	results chan ResultOf[T] // Has room for all results, closed after the last one
+	pending atomic.Int64
+}
+
+// deliver adds a result to the batch, closing its channel after the last one
+func (b *batch[T]) deliver(result ResultOf[T]) {
+	b.results <- result
+	if b.pending.Add(-1) == 0 {
+		close(b.results)
+	}
+}
+
+// typedJob is a task of a batch
+type typedJob[T any] struct {
+	task  func() (T, error)
+	batch *batch[T]
+}
+
+func (j *typedJob[T]) run() (func(), error) {
+	value, err := j.task()
+	return func() {
+		j.batch.deliver(ResultOf[T]{Value: value, Err: err})
+	}, err
+}
+
+func (j *typedJob[T]) report(err error) {
+	j.batch.deliver(ResultOf[T]{Err: err})
+}
+
+// funcJob is a task whose result is passed to a function, see SubmitFunc
+type funcJob[T any] struct {
+	task func() (T, error)
+	done func(ResultOf[T])
+}
+
+func (j *funcJob[T]) run() (func(), error) {
+	value, err := j.task()
+	return func() {
+		j.done(ResultOf[T]{Value: value, Err: err})
+	}, err
+}
+
+func (j *funcJob[T]) report(err error) {
+	j.done(ResultOf[T]{Err: err})
+}
+
+// Submit adds a typed task to the worker pool and returns a channel that will
+// receive its result
+func Submit[T any](wp *WorkerPool, task func() (T, error)) <-chan ResultOf[T] {
+	return SubmitBatch(context.Background(), wp, []func() (T, error){task})
+}
+
+// SubmitWait runs a typed task on the worker pool for ctx and returns its
+// result once it finished
+// Like SubmitBatch, the task gets the priority of ctx and isn't run once ctx is done
+func SubmitWait[T any](ctx context.Context, wp *WorkerPool, task func() (T, error)) (T, error) {
+	result := <-SubmitBatch(ctx, wp, []func() (T, error){task})
+	return result.Value, result.Err
+}
+
+// SubmitFunc adds a typed task to the worker pool for ctx and passes its result
+// to done, without a channel to wait on
+// Like SubmitBatch, the task gets the priority of ctx and isn't run once ctx is
+// done. done is called exactly once: on the worker after the task, or right away if the task
+// can't be queued, so it should be quick
+func SubmitFunc[T any](ctx context.Context, wp *WorkerPool, task func() (T, error), done func(ResultOf[T])) {
+	j := &funcJob[T]{task: task, done: done}
+	if err := wp.enqueue(PriorityFromContext(ctx), queuedTask{job: j, ctx: ctx, queued: time.Now(), timeout: wp.taskTimeout}, wp.queueDeadline()); err != nil {
+		j.report(err)
+	}
+}
+
+// SubmitBatch submits typed tasks to the worker pool for ctx and returns a
+// channel that will receive all results, closed after the last one
+// The tasks get the priority of ctx (see WithPriority), and those still queued
+// when ctx is done fail with its error without running. Running tasks aren't
+// given up on at the deadline of ctx, only after the pool's WithTaskTimeout.
+// Unlike SubmitBatchPriority, the values aren't boxed
+// Tasks that find the queue full fail with ErrQueueFull, see WithQueueTimeout
+func SubmitBatch[T any](ctx context.Context, wp *WorkerPool, tasks []func() (T, error)) <-chan ResultOf[T] {
+	return submitJobs(ctx, wp, tasks, PriorityFromContext(ctx), wp.taskTimeout)
+}
+
+// submitJobs queues tasks of the given priority for ctx with their timeout
+// Each task delivers its result on the channel of its batch, so results can't
+// reach other callers
+func submitJobs[T any](ctx context.Context, wp *WorkerPool, tasks []func() (T, error), priority Priority, timeout time.Duration) <-chan ResultOf[T] {
+	b := &batch[T]{results: make(chan ResultOf[T], len(tasks))}
+	if len(tasks) == 0 {
+		close(b.results)
+		return b.results
+	}
+	b.pending.Store(int64(len(tasks)))
+
+	priority = validPriority(priority)
+	deadline := wp.queueDeadline()
+	jobs := make([]typedJob[T], len(tasks))
+	enqueue := func() {
+		for i, task := range tasks {
+			jobs[i] = typedJob[T]{task: task, batch: b}
+			if err := wp.enqueue(priority, queuedTask{job: &jobs[i], ctx: ctx, queued: time.Now(), timeout: timeout}, deadline); err != nil {
+				jobs[i].report(err)
+			}
+		}
+	}
+
+	// A single task is queued right away, so tasks submitted one after another
+	// are queued in order; a batch is queued by a goroutine of its own
+	if len(tasks) == 1 {
+		enqueue()
+	} else {
+		go enqueue()
+	}
+
+	return b.results
+}
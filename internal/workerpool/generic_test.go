@@ -0,0 +1,241 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubmitTyped(t *testing.T) {
+	wp := New(2)
+	defer wp.Shutdown()
+
+	result := <-Submit(wp, func() (string, error) { return "Anna", nil })
+	if result.Value != "Anna" || result.Err != nil {
+		t.Errorf("Expected Anna, got %q %v", result.Value, result.Err)
+	}
+
+	// Errors, panics and timeouts all come back as Err
+	failure := errors.New("no names")
+	if result := <-Submit(wp, func() (int, error) { return 0, failure }); !errors.Is(result.Err, failure) {
+		t.Errorf("Expected the task's error, got %v", result.Err)
+	}
+	var panicked *PanicError
+	if result := <-Submit(wp, func() (int, error) { panic("boom") }); !errors.As(result.Err, &panicked) {
+		t.Errorf("Expected a PanicError, got %v", result.Err)
+	}
+}
+
+func TestSubmitBatchTyped(t *testing.T) {
+	wp := New(4)
+	defer wp.Shutdown()
+
+	tasks := make([]func() (int, error), 50)
+	for i := range tasks {
+		i := i
+		tasks[i] = func() (int, error) { return i * i, nil }
+	}
+	seen := make(map[int]bool)
+	for result := range SubmitBatch(context.Background(), wp, tasks) {
+		if result.Err != nil {
+			t.Fatalf("Unexpected error: %v", result.Err)
+		}
+		seen[result.Value] = true
+	}
+	for i := range tasks {
+		if !seen[i*i] {
+			t.Errorf("Expected the result %d", i*i)
+		}
+	}
+
+	// An empty batch is closed right away
+	if _, ok := <-SubmitBatch[int](context.Background(), wp, nil); ok {
+		t.Error("Expected no results for no tasks")
+	}
+}
+
+func TestSubmitBatchContext(t *testing.T) {
+	wp := New(1)
+	defer wp.Shutdown()
+
+	// Tasks still queued at the deadline of the context fail without running,
+	// while running tasks aren't given up on
+	release := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ran := false
+	results := SubmitBatch(ctx, wp, []func() (int, error){
+		func() (int, error) { <-release; return 1, nil },
+		func() (int, error) { ran = true; return 2, nil },
+	})
+	<-ctx.Done()
+	close(release)
+	if result := <-results; result.Value != 1 || result.Err != nil {
+		t.Errorf("Expected the running task to finish, got %v %v", result.Value, result.Err)
+	}
+	if result := <-results; !errors.Is(result.Err, context.DeadlineExceeded) || result.Value != 0 || ran {
+		t.Errorf("Expected the queued task to be skipped, got %v %v", result.Value, result.Err)
+	}
+	if wp.Timeouts() != 0 {
+		t.Errorf("Expected no timeouts, got %d", wp.Timeouts())
+	}
+
+	// The tasks are queued with the priority of the context
+	started := make(chan struct{})
+	block := make(chan struct{})
+	<-Submit(wp, func() (bool, error) { return true, nil })
+	go wp.Submit(func() interface{} { close(started); <-block; return nil })
+	<-started
+	results = SubmitBatch(WithPriority(context.Background(), PriorityHigh), wp, []func() (int, error){func() (int, error) { return 1, nil }})
+	for wp.QueuedPriority(PriorityHigh) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(block)
+	if result := <-results; result.Value != 1 {
+		t.Errorf("Expected the high-priority task to run, got %v %v", result.Value, result.Err)
+	}
+}
+
+func TestSubmitBatchShutdown(t *testing.T) {
+	wp := New(1)
+
+	// Tasks the pool shuts down without running fail with ErrPoolClosed
+	release := make(chan struct{})
+	started := make(chan struct{})
+	tasks := []func() (int, error){
+		func() (int, error) { close(started); <-release; return 1, nil },
+		func() (int, error) { return 2, nil },
+	}
+	results := SubmitBatch(context.Background(), wp, tasks)
+	<-started
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	wp.ShutdownNow()
+
+	done := make(chan []ResultOf[int])
+	go func() {
+		var received []ResultOf[int]
+		for result := range results {
+			received = append(received, result)
+		}
+		done <- received
+	}()
+	select {
+	case received := <-done:
+		values, closed := 0, 0
+		for _, result := range received {
+			if result.Value == 1 && result.Err == nil {
+				values++
+			} else if errors.Is(result.Err, ErrPoolClosed) {
+				closed++
+			}
+		}
+		if values != 1 || closed != 1 {
+			t.Errorf("Expected the running task's value and ErrPoolClosed for the queued one, got %+v", received)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the results channel to be closed after the shutdown")
+	}
+
+	// So do tasks submitted afterwards
+	if _, err := SubmitWait(context.Background(), wp, func() (int, error) { return 3, nil }); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Expected ErrPoolClosed after the shutdown, got %v", err)
+	}
+}
+
+func TestSubmitWait(t *testing.T) {
+	wp := New(2)
+	defer wp.Shutdown()
+
+	if value, err := wp.SubmitWait(func() interface{} { return 42 }); value != 42 || err != nil {
+		t.Errorf("Expected 42, got %v %v", value, err)
+	}
+	if name, err := SubmitWait(context.Background(), wp, func() (string, error) { return "Anna", nil }); name != "Anna" || err != nil {
+		t.Errorf("Expected Anna, got %q %v", name, err)
+	}
+	failure := errors.New("no names")
+	if _, err := SubmitWait(context.Background(), wp, func() (string, error) { return "", failure }); !errors.Is(err, failure) {
+		t.Errorf("Expected the task's error, got %v", err)
+	}
+}
+
+func TestSubmitFunc(t *testing.T) {
+	wp := New(4)
+	defer wp.Shutdown()
+
+	// Each callback gets the result of its own task
+	var wg sync.WaitGroup
+	squares := make([]int, 100)
+	for i := range squares {
+		i := i
+		wg.Add(1)
+		SubmitFunc(context.Background(), wp, func() (int, error) { return i * i, nil }, func(result ResultOf[int]) {
+			defer wg.Done()
+			squares[i] = result.Value
+		})
+	}
+	wg.Wait()
+	for i, square := range squares {
+		if square != i*i {
+			t.Fatalf("Expected %d at %d, got %d", i*i, i, square)
+		}
+	}
+
+	// Panics are passed on too
+	done := make(chan Result, 1)
+	wp.SubmitFunc(func() interface{} { panic("boom") }, func(result Result) { done <- result })
+	var panicked *PanicError
+	if result := <-done; !errors.As(result.Err, &panicked) {
+		t.Errorf("Expected a PanicError, got %v", result.Err)
+	}
+}
+
+func TestSubmitFuncShutdown(t *testing.T) {
+	wp := New(1)
+	wp.Shutdown()
+
+	// The callback of a task the pool can't run is called right away
+	called := false
+	wp.SubmitFunc(func() interface{} { return nil }, func(result Result) {
+		called = errors.Is(result.Err, ErrPoolClosed)
+	})
+	if !called {
+		t.Error("Expected the callback to be called with ErrPoolClosed")
+	}
+}
+
+func BenchmarkSubmitBatch(b *testing.B) {
+	wp := New(4)
+	defer wp.Shutdown()
+
+	tasks := make([]Task, 100)
+	for i := range tasks {
+		i := i
+		tasks[i] = func() interface{} { return i }
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for range wp.SubmitBatch(tasks) {
+		}
+	}
+}
+
+func BenchmarkSubmitBatchTyped(b *testing.B) {
+	wp := New(4)
+	defer wp.Shutdown()
+
+	tasks := make([]func() (int, error), 100)
+	for i := range tasks {
+		i := i
+		tasks[i] = func() (int, error) { return i, nil }
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for range SubmitBatch(context.Background(), wp, tasks) {
+		}
+	}
+}
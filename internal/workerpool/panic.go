@@ -0,0 +1,35 @@
+package workerpool
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is the Result.Err of a task that panicked
+// The worker that ran the task recovers and goes on with the next one
+type PanicError struct {
+	Value interface{} // The value the task panicked with
+	Stack []byte      // The stack of the task when it panicked
+}
+
+// Error describes the panic
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v", e.Value)
+}
+
+// run runs a task, turning a panic into a PanicError
+// A task that panicked has no deliver, since it has no value
+func (wp *WorkerPool) run(task queuedTask) (deliver func(), err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			wp.panics.Add(1)
+			err = &PanicError{Value: recovered, Stack: debug.Stack()}
+		}
+	}()
+	return task.job.run()
+}
+
+// Panics returns how many tasks panicked
+func (wp *WorkerPool) Panics() uint64 {
+	return wp.panics.Load()
+}
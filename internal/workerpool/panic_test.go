@@ -0,0 +1,57 @@
+package workerpool
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPanicRecovery(t *testing.T) {
+	wp := New(1)
+	defer wp.Shutdown()
+
+	// The panic comes back as the error of the task
+	result := <-wp.Submit(func() interface{} { panic("boom") })
+	var panicked *PanicError
+	if !errors.As(result.Err, &panicked) || panicked.Value != "boom" {
+		t.Fatalf("Expected a PanicError with the value boom, got %v", result.Err)
+	}
+	if result.Value != nil || panicked.Error() != "task panicked: boom" || !strings.Contains(string(panicked.Stack), "panic") {
+		t.Errorf("Expected no value, a description and the stack, got %v %q %q", result.Value, panicked.Error(), panicked.Stack)
+	}
+
+	// The only worker survives it
+	if result := <-wp.Submit(func() interface{} { return 42 }); result.Value != 42 || result.Err != nil {
+		t.Errorf("Expected the worker to run the next task, got %v %v", result.Value, result.Err)
+	}
+	if wp.Workers() != 1 || wp.Panics() != 1 {
+		t.Errorf("Expected 1 worker and 1 panic, got %d and %d", wp.Workers(), wp.Panics())
+	}
+}
+
+func TestPanicRecoveryBatch(t *testing.T) {
+	wp := New(2)
+	defer wp.Shutdown()
+
+	tasks := make([]Task, 10)
+	for i := range tasks {
+		i := i
+		tasks[i] = func() interface{} {
+			if i%2 == 0 {
+				panic(i)
+			}
+			return i
+		}
+	}
+	values, failures := 0, 0
+	for result := range wp.SubmitBatch(tasks) {
+		if result.Err != nil {
+			failures++
+		} else {
+			values++
+		}
+	}
+	if values != 5 || failures != 5 || wp.Panics() != 5 {
+		t.Errorf("Expected 5 values and 5 panics, got %d, %d and %d", values, failures, wp.Panics())
+	}
+}
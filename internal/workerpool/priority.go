@@ -0,0 +1,44 @@
+package workerpool
+
+import "context"
+
+// Priority decides which waiting tasks workers take first: all queued tasks
+// of a higher priority run before those of a lower one
+// A saturated pool therefore keeps serving interactive work while background
+// work waits, for as long as the pool stays saturated
+type Priority int
+
+// Priorities of tasks
+const (
+	PriorityLow    Priority = iota // Background work, e.g. refreshing caches
+	PriorityNormal                 // Tasks submitted without a priority
+	PriorityHigh                   // Interactive work, e.g. answering a request
+)
+
+// String returns the name of the priority
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	}
+	return "normal"
+}
+
+// priorityKey is the context key of the priority of the work done for a context
+type priorityKey struct{}
+
+// WithPriority returns a context whose work is submitted with the given priority
+// by code that submits tasks on behalf of a context, e.g. the name generator
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFromContext returns the priority set with WithPriority, or PriorityNormal
+func PriorityFromContext(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return priority
+	}
+	return PriorityNormal
+}
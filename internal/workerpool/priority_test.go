@@ -0,0 +1,89 @@
+package workerpool
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestPriorities(t *testing.T) {
+	wp := New(1)
+	defer wp.Shutdown()
+
+	// Keep the only worker busy while tasks of every priority queue up
+	release := make(chan struct{})
+	started := make(chan struct{})
+	wp.Submit(func() interface{} {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	var mutex sync.Mutex
+	var order []Priority
+	var results []<-chan Result
+	for _, priority := range []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityLow, PriorityHigh} {
+		priority := priority
+		results = append(results, wp.SubmitPriority(func() interface{} {
+			mutex.Lock()
+			order = append(order, priority)
+			mutex.Unlock()
+			return nil
+		}, priority))
+	}
+	for wp.Queued() < 5 {
+		// The tasks are queued by their own goroutines
+	}
+	if wp.QueuedPriority(PriorityHigh) != 2 || wp.QueuedPriority(PriorityNormal) != 1 || wp.QueuedPriority(PriorityLow) != 2 {
+		t.Errorf("Expected 2 high, 1 normal and 2 low tasks queued, got %d, %d and %d",
+			wp.QueuedPriority(PriorityHigh), wp.QueuedPriority(PriorityNormal), wp.QueuedPriority(PriorityLow))
+	}
+
+	// The worker takes them by priority
+	close(release)
+	for _, result := range results {
+		<-result
+	}
+	expected := []Priority{PriorityHigh, PriorityHigh, PriorityNormal, PriorityLow, PriorityLow}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for i := range expected {
+		if i >= len(order) || order[i] != expected[i] {
+			t.Fatalf("Expected the tasks to run in the order %v, got %v", expected, order)
+		}
+	}
+}
+
+func TestSubmitBatchPriority(t *testing.T) {
+	wp := New(2)
+	defer wp.Shutdown()
+
+	tasks := make([]Task, 10)
+	for i := range tasks {
+		i := i
+		tasks[i] = func() interface{} { return i }
+	}
+	count := 0
+	for range wp.SubmitBatchPriority(tasks, PriorityLow) {
+		count++
+	}
+	if count != len(tasks) {
+		t.Errorf("Expected %d results, got %d", len(tasks), count)
+	}
+
+	// Unknown priorities are taken as normal
+	if result := <-wp.SubmitPriority(func() interface{} { return 42 }, Priority(7)); result.Value != 42 {
+		t.Errorf("Expected a task of an unknown priority to run, got %v", result.Value)
+	}
+}
+
+func TestPriorityFromContext(t *testing.T) {
+	if priority := PriorityFromContext(context.Background()); priority != PriorityNormal {
+		t.Errorf("Expected normal priority by default, got %v", priority)
+	}
+	ctx := WithPriority(context.Background(), PriorityHigh)
+	if priority := PriorityFromContext(ctx); priority != PriorityHigh || priority.String() != "high" {
+		t.Errorf("Expected high priority, got %v", priority)
+	}
+}
@@ -0,0 +1,108 @@
+package workerpool
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrQueueFull is the Result.Err of a task turned away because the queue of
+// its priority stayed full, see WithQueueTimeout
+var ErrQueueFull = errors.New("worker pool queue is full")
+
+// ErrPoolClosed is the Result.Err of a task the pool shut down without running
+var ErrPoolClosed = errors.New("worker pool is shut down")
+
+// WithQueueSize sets how many tasks of each priority can wait for a worker
+// (10 per worker by default, or per MaxWorkers when autoscaling)
+func WithQueueSize(size int) Option {
+	return func(wp *WorkerPool) {
+		wp.queueSize = size
+	}
+}
+
+// WithQueueTimeout sets how long submitting to a full queue waits for room
+// before the task fails with ErrQueueFull, for the tasks of a batch together
+// Without it, submitting waits for as long as the pool runs
+func WithQueueTimeout(timeout time.Duration) Option {
+	return func(wp *WorkerPool) {
+		wp.queueTimeout = timeout
+	}
+}
+
+// Rejected returns how many tasks failed with ErrQueueFull
+func (wp *WorkerPool) Rejected() uint64 {
+	return wp.rejected.Load()
+}
+
+// queueDeadline returns until when tasks submitted now may wait for room in
+// a full queue, or the zero time to wait for as long as the pool runs
+func (wp *WorkerPool) queueDeadline() time.Time {
+	if wp.queueTimeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(wp.queueTimeout)
+}
+
+// enqueue adds a task to the queue of its priority in the next shard with room,
+// waiting for room until deadline when all are full
+func (wp *WorkerPool) enqueue(priority Priority, task queuedTask, deadline time.Time) error {
+	wp.closing.RLock()
+	defer wp.closing.RUnlock()
+	if wp.ctx.Err() != nil {
+		return ErrPoolClosed
+	}
+	priority = validPriority(priority)
+	// Counted before it's queued, so workers never miss a queued task
+	wp.pending[priority].Add(1)
+	first := int(wp.nextShard.Add(1) % uint64(len(wp.shards)))
+	for i := range wp.shards {
+		select {
+		case wp.shards[(first+i)%len(wp.shards)].queues[priority] <- task:
+			wp.notify()
+			return nil
+		default:
+		}
+	}
+
+	// The queue is full, so wait for a worker to take a task
+	queue := wp.shards[first].queues[priority]
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-wp.ctx.Done():
+		wp.pending[priority].Add(-1)
+		return ErrPoolClosed
+	case queue <- task:
+		wp.notify()
+		return nil
+	case <-expired:
+		wp.pending[priority].Add(-1)
+		wp.rejected.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// discardQueued reports ErrPoolClosed for the tasks left in the queues of a
+// pool that shuts down
+// Tasks being queued meanwhile see the pool closed, so no task is left behind
+func (wp *WorkerPool) discardQueued() {
+	wp.closing.Lock()
+	defer wp.closing.Unlock()
+	for _, shard := range wp.shards {
+		for priority, queue := range shard.queues {
+			for discarding := true; discarding; {
+				select {
+				case task := <-queue:
+					wp.pending[priority].Add(-1)
+					task.job.report(ErrPoolClosed)
+				default:
+					discarding = false
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockWorker keeps the only worker of a pool busy until the returned function
+// is called
+func blockWorker(wp *WorkerPool) func() {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	wp.Submit(func() interface{} {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	return func() { close(release) }
+}
+
+func TestQueueFull(t *testing.T) {
+	wp := New(1, WithQueueSize(2), WithQueueTimeout(20*time.Millisecond))
+	defer wp.Shutdown()
+	release := blockWorker(wp)
+
+	// Two tasks fit in the queue, the third is turned away
+	queued := []<-chan Result{
+		wp.Submit(func() interface{} { return 1 }),
+		wp.Submit(func() interface{} { return 2 }),
+	}
+	if result := <-wp.Submit(func() interface{} { return 3 }); !errors.Is(result.Err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v %v", result.Value, result.Err)
+	}
+	if wp.Rejected() != 1 || wp.Stats().Rejected != 1 {
+		t.Errorf("Expected 1 rejected task, got %d", wp.Rejected())
+	}
+
+	release()
+	for _, result := range queued {
+		if r := <-result; r.Err != nil {
+			t.Errorf("Expected the queued tasks to run, got %v", r.Err)
+		}
+	}
+}
+
+func TestQueueFullBatch(t *testing.T) {
+	wp := New(1, WithQueueSize(2), WithQueueTimeout(20*time.Millisecond))
+	defer wp.Shutdown()
+	release := blockWorker(wp)
+
+	// The tasks that don't fit fail once the batch's wait for room is over
+	tasks := make([]Task, 5)
+	for i := range tasks {
+		i := i
+		tasks[i] = func() interface{} { return i }
+	}
+	results := wp.SubmitBatch(tasks)
+	time.Sleep(40 * time.Millisecond)
+	release()
+	values, full := 0, 0
+	for result := range results {
+		if errors.Is(result.Err, ErrQueueFull) {
+			full++
+		} else {
+			values++
+		}
+	}
+	if values != 2 || full != 3 {
+		t.Errorf("Expected 2 values and 3 rejected tasks, got %d and %d", values, full)
+	}
+
+	// The same goes for typed batches
+	release = blockWorker(wp)
+	typed := make([]func() (int, error), 4)
+	for i := range typed {
+		i := i
+		typed[i] = func() (int, error) { return i, nil }
+	}
+	typedResults := SubmitBatch(context.Background(), wp, typed)
+	time.Sleep(40 * time.Millisecond)
+	release()
+	values, full = 0, 0
+	for result := range typedResults {
+		if errors.Is(result.Err, ErrQueueFull) {
+			full++
+		} else {
+			values++
+		}
+	}
+	if values != 2 || full != 2 {
+		t.Errorf("Expected 2 typed values and 2 rejected tasks, got %d and %d", values, full)
+	}
+}
+
+func TestQueueWait(t *testing.T) {
+	// Without a queue timeout, batches larger than the queue wait for room
+	wp := New(2, WithQueueSize(2))
+	defer wp.Shutdown()
+
+	tasks := make([]Task, 100)
+	for i := range tasks {
+		i := i
+		tasks[i] = func() interface{} { return i }
+	}
+	count := 0
+	for result := range wp.SubmitBatch(tasks) {
+		if result.Err != nil {
+			t.Fatalf("Unexpected error: %v", result.Err)
+		}
+		count++
+	}
+	if count != len(tasks) || wp.Rejected() != 0 {
+		t.Errorf("Expected %d results and no rejected tasks, got %d and %d", len(tasks), count, wp.Rejected())
+	}
+}
@@ -0,0 +1,34 @@
+package workerpool
+
+import "time"
+
+// Stats is a reading of the load of a pool and of the tasks it ran
+type Stats struct {
+	Workers     int
+	Queued      int           // Tasks waiting for a worker
+	Running     int           // Tasks being run
+	Completed   uint64        // Tasks that returned a value
+	Failed      uint64        // Tasks that returned an error, panicked or timed out
+	Rejected    uint64        // Tasks turned away by a full queue
+	AvgDuration time.Duration // Average run time of the finished tasks
+	Utilization float64       // Share of the workers running a task, 0-1
+}
+
+// Stats returns the current load of the pool and totals of the tasks it ran
+func (wp *WorkerPool) Stats() Stats {
+	stats := Stats{
+		Workers:   wp.Workers(),
+		Queued:    wp.Queued(),
+		Running:   int(wp.busy.Load()),
+		Completed: wp.completed.Load(),
+		Failed:    wp.errored.Load() + wp.panics.Load() + wp.timeouts.Load(),
+		Rejected:  wp.rejected.Load(),
+	}
+	if finished := stats.Completed + stats.Failed; finished > 0 {
+		stats.AvgDuration = time.Duration(wp.runTime.Load() / int64(finished))
+	}
+	if stats.Workers > 0 {
+		stats.Utilization = min(float64(stats.Running)/float64(stats.Workers), 1)
+	}
+	return stats
+}
@@ -0,0 +1,51 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	wp := New(2)
+	defer wp.Shutdown()
+
+	if stats := wp.Stats(); stats != (Stats{Workers: 2}) {
+		t.Errorf("Expected an idle pool of 2 workers, got %+v", stats)
+	}
+
+	// One worker runs a task while the other is idle
+	release := make(chan struct{})
+	started := make(chan struct{})
+	running := wp.Submit(func() interface{} {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	if stats := wp.Stats(); stats.Running != 1 || stats.Utilization != 0.5 {
+		t.Errorf("Expected 1 running task and half the workers busy, got %+v", stats)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	<-running
+
+	// The finished tasks are counted with their run time
+	<-wp.Submit(func() interface{} { panic("boom") })
+	stats := wp.Stats()
+	if stats.Completed != 1 || stats.Failed != 1 || stats.Running != 0 || stats.Queued != 0 {
+		t.Errorf("Expected 1 completed and 1 failed task, got %+v", stats)
+	}
+	if stats.AvgDuration < 5*time.Millisecond {
+		t.Errorf("Expected the average run time to include the 10ms task, got %v", stats.AvgDuration)
+	}
+
+	// Tasks returning an error failed rather than completed
+	if _, err := SubmitWait(context.Background(), wp, func() (int, error) { return 0, errors.New("boom") }); err == nil {
+		t.Error("Expected the task's error")
+	}
+	if stats := wp.Stats(); stats.Completed != 1 || stats.Failed != 2 {
+		t.Errorf("Expected 1 completed and 2 failed tasks, got %+v", stats)
+	}
+}
@@ -0,0 +1,65 @@
+package workerpool
+
+// shard is one of the queues of a pool, by Priority
+// Tasks are spread over the shards, so submitters and workers don't all
+// contend for a single channel. Each worker takes tasks from a shard of its
+// own first and steals from the others when its own is empty
+type shard struct {
+	queues [3]chan queuedTask
+}
+
+// WithQueueShards sets how many shards the queue of a pool is split into
+// (a single one by default, which takes tasks in the order they were queued)
+// Every worker scans all shards for a task, so more shards than cores only
+// adds to the cost of taking one
+func WithQueueShards(shards int) Option {
+	return func(wp *WorkerPool) {
+		wp.numShards = shards
+	}
+}
+
+// newShards returns n shards with room for size tasks of each priority in all
+func newShards(n, size int) []*shard {
+	perShard := max((size+n-1)/n, 1)
+	shards := make([]*shard, n)
+	for i := range shards {
+		shards[i] = &shard{}
+		for p := range shards[i].queues {
+			shards[i].queues[p] = make(chan queuedTask, perShard)
+		}
+	}
+	return shards
+}
+
+// take takes the next task without waiting, the tasks of a higher priority
+// first: from the worker's own shard, or else stolen from another
+func (wp *WorkerPool) take(own int) (queuedTask, bool) {
+	for priority := PriorityHigh; priority >= PriorityLow; priority-- {
+		if wp.pending[priority].Load() == 0 {
+			continue
+		}
+		for i := range wp.shards {
+			select {
+			case task := <-wp.shards[(own+i)%len(wp.shards)].queues[priority]:
+				wp.pending[priority].Add(-1)
+				return task, true
+			default:
+			}
+		}
+	}
+	return queuedTask{}, false
+}
+
+// notify wakes an idle worker for a task just queued, if any worker is idle
+// A worker only sleeps after it found all shards empty while counted as idle,
+// so it either sees the task or gets woken for it
+func (wp *WorkerPool) notify() {
+	if wp.idle.Load() == 0 {
+		return
+	}
+	select {
+	case wp.wake <- struct{}{}:
+	default:
+		// Enough workers are being woken already
+	}
+}
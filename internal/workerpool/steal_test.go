@@ -0,0 +1,102 @@
+package workerpool
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestWorkStealing(t *testing.T) {
+	// A single worker owns one of the shards and steals the tasks of the others
+	wp := New(1, WithQueueShards(4), WithQueueSize(20))
+	defer wp.Shutdown()
+	release := blockWorker(wp)
+
+	results := make([]<-chan Result, 20)
+	for i := range results {
+		i := i
+		results[i] = wp.Submit(func() interface{} { return i })
+	}
+	if queued := wp.Queued(); queued != len(results) {
+		t.Errorf("Expected %d queued tasks, got %d", len(results), queued)
+	}
+	release()
+	for i, result := range results {
+		if r := <-result; r.Err != nil || r.Value != i {
+			t.Errorf("Expected %d, got %v %v", i, r.Value, r.Err)
+		}
+	}
+}
+
+func TestWorkStealingPriority(t *testing.T) {
+	// Tasks of a higher priority run first, whatever shard they're in
+	wp := New(1, WithQueueShards(4), WithQueueSize(20))
+	defer wp.Shutdown()
+	release := blockWorker(wp)
+
+	var mu sync.Mutex
+	var order []Priority
+	var results []<-chan Result
+	for _, priority := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		priority := priority
+		for i := 0; i < 4; i++ {
+			results = append(results, wp.SubmitPriority(func() interface{} {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, priority)
+				return nil
+			}, priority))
+		}
+	}
+	if queued := wp.QueuedPriority(PriorityHigh); queued != 4 {
+		t.Errorf("Expected 4 queued high priority tasks, got %d", queued)
+	}
+	release()
+	for _, result := range results {
+		<-result
+	}
+
+	for i := 1; i < len(order); i++ {
+		if order[i] > order[i-1] {
+			t.Fatalf("Expected tasks by priority, got %v", order)
+		}
+	}
+}
+
+func TestWorkStealingIdleWorkers(t *testing.T) {
+	// Workers sleeping on empty shards are woken for tasks queued elsewhere
+	wp := New(8)
+	defer wp.Shutdown()
+
+	for round := 0; round < 100; round++ {
+		results := make([]<-chan Result, 3)
+		for i := range results {
+			results[i] = wp.Submit(func() interface{} { return nil })
+		}
+		for _, result := range results {
+			if r := <-result; r.Err != nil {
+				t.Fatalf("Expected no error, got %v", r.Err)
+			}
+		}
+	}
+}
+
+// BenchmarkQueueShards compares a single shared queue with one shard per worker
+// Run with -cpu to spread submitters and workers over several cores
+func BenchmarkQueueShards(b *testing.B) {
+	for _, workers := range []int{4, 16, 32, 64} {
+		for _, shards := range []int{1, workers} {
+			b.Run(fmt.Sprintf("workers=%d/shards=%d", workers, shards), func(b *testing.B) {
+				wp := New(workers, WithQueueShards(shards))
+				defer wp.Shutdown()
+				b.SetParallelism(4)
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						wp.SubmitWait(func() interface{} { return nil })
+					}
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package workerpool
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTaskTimeout is the Result.Err of a task that ran longer than its timeout
+var ErrTaskTimeout = errors.New("task timed out")
+
+// WithTaskTimeout sets the timeout of tasks submitted without one
+// A task that runs longer fails with ErrTaskTimeout and its worker moves on.
+// The task itself can't be stopped, so it keeps running in the background and
+// its value is dropped; tasks that can should also watch a context
+func WithTaskTimeout(timeout time.Duration) Option {
+	return func(wp *WorkerPool) {
+		wp.taskTimeout = timeout
+	}
+}
+
+// SubmitTimeout adds a task of the given priority to the worker pool, which
+// gives up on it after timeout (the pool's WithTaskTimeout if 0), and returns
+// a channel that will receive the result
+func (wp *WorkerPool) SubmitTimeout(task Task, priority Priority, timeout time.Duration) <-chan Result {
+	if timeout <= 0 {
+		timeout = wp.taskTimeout
+	}
+	return wp.submit(task, priority, timeout)
+}
+
+// SubmitBatchTimeout submits multiple tasks of the given priority to the
+// worker pool, which gives up on each of them after timeout (the pool's
+// WithTaskTimeout if 0), and returns a channel that will receive all results
+func (wp *WorkerPool) SubmitBatchTimeout(tasks []Task, priority Priority, timeout time.Duration) <-chan Result {
+	if timeout <= 0 {
+		timeout = wp.taskTimeout
+	}
+	return wp.submitBatch(tasks, priority, timeout)
+}
+
+// Timeouts returns how many tasks timed out
+func (wp *WorkerPool) Timeouts() uint64 {
+	return wp.timeouts.Load()
+}
+
+// runTimeout runs a task like run, but gives up on it after its timeout, if any
+// The result of a task that timed out is dropped when it finishes, so deliver
+// is only set for tasks that returned in time, with the error they returned
+func (wp *WorkerPool) runTimeout(task queuedTask) (deliver func(), err error) {
+	if task.timeout <= 0 {
+		return wp.run(task)
+	}
+
+	type outcome struct {
+		deliver func()
+		err     error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		deliver, err := wp.run(task)
+		done <- outcome{deliver, err}
+	}()
+
+	timer := time.NewTimer(task.timeout)
+	defer timer.Stop()
+	select {
+	case finished := <-done:
+		return finished.deliver, finished.err
+	case <-timer.C:
+		wp.timeouts.Add(1)
+		return nil, ErrTaskTimeout
+	}
+}
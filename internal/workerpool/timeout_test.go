@@ -0,0 +1,110 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTaskTimeout(t *testing.T) {
+	wp := New(1)
+	defer wp.Shutdown()
+
+	// The only worker gives up on the slow task, so it can run the next one
+	release := make(chan struct{})
+	defer close(release)
+	slow := wp.SubmitTimeout(func() interface{} {
+		<-release
+		return "late"
+	}, PriorityNormal, 20*time.Millisecond)
+	if result := <-slow; !errors.Is(result.Err, ErrTaskTimeout) || result.Value != nil {
+		t.Errorf("Expected the slow task to time out, got %v %v", result.Value, result.Err)
+	}
+	if result := <-wp.Submit(func() interface{} { return 42 }); result.Value != 42 || result.Err != nil {
+		t.Errorf("Expected the next task to run, got %v %v", result.Value, result.Err)
+	}
+	if wp.Timeouts() != 1 {
+		t.Errorf("Expected 1 timeout, got %d", wp.Timeouts())
+	}
+
+	// Tasks that finish in time aren't affected
+	if result := <-wp.SubmitTimeout(func() interface{} { return "quick" }, PriorityHigh, time.Second); result.Value != "quick" || result.Err != nil {
+		t.Errorf("Expected the quick task's value, got %v %v", result.Value, result.Err)
+	}
+}
+
+func TestBatchTimeout(t *testing.T) {
+	wp := New(4, WithTaskTimeout(time.Hour))
+	defer wp.Shutdown()
+
+	// The batch's timeout applies to each of its tasks, and overrides the pool's
+	release := make(chan struct{})
+	defer close(release)
+	tasks := []Task{
+		func() interface{} { return 1 },
+		func() interface{} { <-release; return 2 },
+		func() interface{} { return 3 },
+	}
+	values, timeouts := 0, 0
+	start := time.Now()
+	for result := range wp.SubmitBatchTimeout(tasks, PriorityNormal, 20*time.Millisecond) {
+		if errors.Is(result.Err, ErrTaskTimeout) {
+			timeouts++
+		} else {
+			values++
+		}
+	}
+	if values != 2 || timeouts != 1 {
+		t.Errorf("Expected 2 values and 1 timeout, got %d and %d", values, timeouts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the batch to finish at the timeout, took %v", elapsed)
+	}
+}
+
+func TestPoolTaskTimeout(t *testing.T) {
+	wp := New(1, WithTaskTimeout(20*time.Millisecond))
+	defer wp.Shutdown()
+
+	release := make(chan struct{})
+	defer close(release)
+	if result := <-wp.Submit(func() interface{} { <-release; return nil }); !errors.Is(result.Err, ErrTaskTimeout) {
+		t.Errorf("Expected the pool's timeout to apply, got %v", result.Err)
+	}
+}
+
+func TestTimedOutTaskFinishesLate(t *testing.T) {
+	wp := New(1, WithTaskTimeout(10*time.Millisecond))
+	defer wp.Shutdown()
+
+	// The tasks return after their timeout was reported, which must not race
+	// with the report (run with -race)
+	finished := make(chan struct{}, 2)
+	late := func() (int, error) {
+		defer func() { finished <- struct{}{} }()
+		time.Sleep(30 * time.Millisecond)
+		return 42, errors.New("late")
+	}
+	results := SubmitBatch(context.Background(), wp, []func() (int, error){late})
+	if result := <-results; !errors.Is(result.Err, ErrTaskTimeout) || result.Value != 0 {
+		t.Errorf("Expected the task to time out, got %v %v", result.Value, result.Err)
+	}
+	var got ResultOf[int]
+	done := make(chan struct{})
+	SubmitFunc(context.Background(), wp, late, func(result ResultOf[int]) {
+		got = result
+		close(done)
+	})
+	<-done
+	if !errors.Is(got.Err, ErrTaskTimeout) || got.Value != 0 {
+		t.Errorf("Expected the function's task to time out, got %+v", got)
+	}
+
+	// The late values are dropped
+	<-finished
+	<-finished
+	if result, ok := <-results; ok {
+		t.Errorf("Expected no result after the timeout, got %+v", result)
+	}
+}
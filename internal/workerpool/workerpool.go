@@ -3,164 +3,303 @@ package workerpool
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Task represents a function that can be executed by a worker
 type Task func() interface{}
 
 // Result represents the result of a task execution
-type Result struct {
-	Value interface{}
-	Err   error
-}
+type Result = ResultOf[interface{}]
 
 // WorkerPool manages a pool of workers for concurrent task execution
 type WorkerPool struct {
 	numWorkers int
-	tasks      chan Task
-	results    chan Result
+	numShards  int
+	shards     []*shard // Tasks waiting for a worker
 	wg         sync.WaitGroup
 	ctx        context.Context
 	cancel     context.CancelFunc
+	
+	// The load, and the workers following it when autoscaling
+	autoscale  *AutoscaleConfig // Nil for a fixed number of workers
+	workers    atomic.Int64
+	busy       atomic.Int64 // Workers running a task
+	peakBusy   atomic.Int64 // Most workers busy at once since the last check
+	maxWait    atomic.Int64 // Longest wait for a worker since the last check, in nanoseconds
+	quit       chan struct{} // Each value received stops an idle worker
+	idle       atomic.Int64 // Workers waiting for a task
+	wake       chan struct{} // Each value received wakes an idle worker to look for tasks
+	pending    [3]atomic.Int64 // Tasks in or on their way into the shards, by Priority
+	nextShard  atomic.Uint64 // Round robin over the shards, for tasks and workers
+	scaleUps   atomic.Uint64
+	scaleDowns atomic.Uint64
+	panics     atomic.Uint64 // Tasks that panicked
+	timeouts   atomic.Uint64 // Tasks that timed out
+	rejected   atomic.Uint64 // Tasks turned away by a full queue
+	completed  atomic.Uint64 // Tasks that returned a value
+	errored    atomic.Uint64 // Tasks that returned an error
+	runTime    atomic.Int64 // Total run time of the finished tasks, in nanoseconds
+	
+	// Timeout of tasks submitted without one, none if 0
+	taskTimeout time.Duration
+	
+	// Room for tasks of each priority, and how long submitting waits for it
+	queueSize    int
+	queueTimeout time.Duration
+	closing      sync.RWMutex // Held to queue tasks, and exclusively to discard them at shutdown
 }
 
+// queuedTask is a submitted task with the time it was queued, to measure how
+// long tasks wait for a worker
+type queuedTask struct {
+	job     job
+	ctx     context.Context // Context of the caller, the task is skipped once it's done
+	queued  time.Time
+	timeout time.Duration // Run time after which the worker gives up on it, none if 0
+}
+
+// Option configures optional WorkerPool behavior
+type Option func(*WorkerPool)
+
 // New creates a new worker pool with the specified number of workers
-func New(numWorkers int) *WorkerPool {
+// With WithAutoscaling, numWorkers is only the number it starts with
+func New(numWorkers int, opts ...Option) *WorkerPool {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	wp := &WorkerPool{
 		numWorkers: numWorkers,
-		tasks:      make(chan Task, numWorkers*10), // Buffer tasks channel to avoid blocking
-		results:    make(chan Result, numWorkers*10), // Buffer results channel to avoid blocking
 		ctx:        ctx,
 		cancel:     cancel,
+		quit:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(wp)
 	}
 	
+	// Buffer the channels for the most workers there can be, to avoid blocking
+	capacity := wp.numWorkers
+	if wp.autoscale != nil {
+		capacity = wp.autoscale.MaxWorkers
+	}
+	queueSize := wp.queueSize
+	if queueSize <= 0 {
+		queueSize = capacity * 10
+	}
+	wp.numShards = max(wp.numShards, 1)
+	wp.shards = newShards(wp.numShards, queueSize)
+	wp.wake = make(chan struct{}, wp.numShards)
+	
 	wp.start()
 	
 	return wp
 }
 
-// start launches the worker goroutines
+// start launches the worker goroutines, and the autoscaler if enabled
 func (wp *WorkerPool) start() {
-	// Start the workers
-	for i := 0; i < wp.numWorkers; i++ {
+	wp.addWorkers(wp.numWorkers)
+	if wp.autoscale != nil {
 		wp.wg.Add(1)
-		go func(workerID int) {
-			defer wp.wg.Done()
-			
-			for {
-				select {
-				case <-wp.ctx.Done():
-					// Context canceled, exit worker
-					return
-				case task, ok := <-wp.tasks:
-					if !ok {
-						// Channel closed, exit worker
-						return
-					}
-					
-					// Execute the task
-					result := task()
-					
-					// Send the result
-					select {
-					case <-wp.ctx.Done():
-						// Context canceled, don't send result
-						return
-					case wp.results <- Result{Value: result}:
-						// Result sent successfully
-					}
-				}
-			}
-		}(i)
+		go wp.autoscaler()
 	}
 }
 
-// Submit adds a task to the worker pool and returns a channel that will receive the result
-func (wp *WorkerPool) Submit(task Task) <-chan Result {
-	resultCh := make(chan Result, 1)
-	
-	// Wrap the task to capture its result
-	wrappedTask := func() interface{} {
-		return task()
+// addWorkers launches n more workers
+func (wp *WorkerPool) addWorkers(n int) {
+	wp.workers.Add(int64(n))
+	wp.wg.Add(n)
+	for i := 0; i < n; i++ {
+		go wp.worker(int(wp.nextShard.Add(1) % uint64(len(wp.shards))))
 	}
+}
+
+// worker runs tasks, from the shard it owns first, until the pool shuts down
+// or the worker is told to quit
+// A task that panics doesn't stop it, see PanicError
+func (wp *WorkerPool) worker(own int) {
+	defer wp.wg.Done()
+	defer wp.workers.Add(-1)
 	
-	// Submit the task
-	select {
-	case <-wp.ctx.Done():
-		// Pool is shutting down, return empty result
-		close(resultCh)
-	case wp.tasks <- wrappedTask:
-		// Wait for the result in a separate goroutine
-		go func() {
-			result := <-wp.results
-			resultCh <- result
-			close(resultCh)
-		}()
+	for {
+		task, ok := wp.next(own)
+		if !ok {
+			return
+		}
+		wp.recordWait(time.Since(task.queued))
+		
+		// Tasks whose caller has given up aren't run
+		if err := task.ctx.Err(); err != nil {
+			task.job.report(err)
+			continue
+		}
+		
+		// Execute the task
+		wp.recordBusy(wp.busy.Add(1))
+		started := time.Now()
+		deliver, err := wp.runTimeout(task)
+		wp.runTime.Add(int64(time.Since(started)))
+		switch {
+		case deliver == nil:
+			// Panics and timeouts are counted where they happen
+		case err != nil:
+			wp.errored.Add(1)
+		default:
+			wp.completed.Add(1)
+		}
+		wp.busy.Add(-1)
+		
+		// The task delivers its result to the caller that submitted it
+		if deliver == nil {
+			task.job.report(err)
+		} else {
+			deliver()
+		}
 	}
-	
-	return resultCh
+}
+
+// next waits for the next task, taking the tasks of a higher priority first
+// It returns false once the pool shuts down or the worker is told to quit
+func (wp *WorkerPool) next(own int) (queuedTask, bool) {
+	for {
+		if task, ok := wp.take(own); ok {
+			return task, true
+		}
+		
+		// Look once more as an idle worker, so a task queued meanwhile wakes it
+		wp.idle.Add(1)
+		if task, ok := wp.take(own); ok {
+			wp.idle.Add(-1)
+			return task, true
+		}
+		
+		// All queues are empty, so wait to be woken for a task
+		select {
+		case <-wp.ctx.Done():
+			// Context canceled, exit worker
+			wp.idle.Add(-1)
+			return queuedTask{}, false
+		case <-wp.quit:
+			// Not needed anymore, exit worker
+			wp.idle.Add(-1)
+			return queuedTask{}, false
+		case <-wp.wake:
+			wp.idle.Add(-1)
+		}
+	}
+}
+
+// Submit adds a task to the worker pool and returns a channel that will receive the result
+func (wp *WorkerPool) Submit(task Task) <-chan Result {
+	return wp.SubmitPriority(task, PriorityNormal)
+}
+
+// SubmitPriority adds a task of the given priority to the worker pool and
+// returns a channel that will receive the result
+// A task that finds the queue full fails with ErrQueueFull, see WithQueueTimeout
+func (wp *WorkerPool) SubmitPriority(task Task, priority Priority) <-chan Result {
+	return wp.submit(task, priority, wp.taskTimeout)
+}
+
+// submit queues a task of the given priority with its timeout
+func (wp *WorkerPool) submit(task Task, priority Priority, timeout time.Duration) <-chan Result {
+	return wp.submitBatch([]Task{task}, priority, timeout)
+}
+
+// SubmitWait runs a task on the worker pool and returns its value once it
+// finished, or why it failed
+func (wp *WorkerPool) SubmitWait(task Task) (interface{}, error) {
+	result := <-wp.Submit(task)
+	return result.Value, result.Err
+}
+
+// SubmitFunc adds a task to the worker pool and passes its result to done,
+// without a channel to wait on
+// done is called exactly once: on the worker after the task, or right away if
+// the task can't be queued, so it should be quick
+func (wp *WorkerPool) SubmitFunc(task Task, done func(Result)) {
+	SubmitFunc(context.Background(), wp, func() (interface{}, error) {
+		return task(), nil
+	}, done)
 }
 
 // SubmitBatch submits multiple tasks to the worker pool and returns a channel that will receive all results
 func (wp *WorkerPool) SubmitBatch(tasks []Task) <-chan Result {
-	resultCh := make(chan Result, len(tasks))
-	
-	// Create a wait group to wait for all tasks to complete
-	var wg sync.WaitGroup
-	wg.Add(len(tasks))
-	
-	// Submit each task
-	for _, task := range tasks {
-		// Capture the task variable
-		t := task
-		
-		// Submit the task to the worker pool
-		go func() {
-			defer wg.Done()
-			
-			// Create a wrapped task that returns the result
-			wrappedTask := func() interface{} {
-				return t()
-			}
-			
-			// Submit the task
-			select {
-			case <-wp.ctx.Done():
-				// Pool is shutting down, skip this task
-				return
-			case wp.tasks <- wrappedTask:
-				// Task submitted, wait for result
-				select {
-				case <-wp.ctx.Done():
-					// Pool is shutting down, skip this task
-					return
-				case result := <-wp.results:
-					// Send the result to the result channel
-					resultCh <- result
-				}
-			}
-		}()
+	return wp.SubmitBatchPriority(tasks, PriorityNormal)
+}
+
+// SubmitBatchPriority submits multiple tasks of the given priority to the
+// worker pool and returns a channel that will receive all results
+// Tasks that find the queue full fail with ErrQueueFull, see WithQueueTimeout
+func (wp *WorkerPool) SubmitBatchPriority(tasks []Task, priority Priority) <-chan Result {
+	return wp.submitBatch(tasks, priority, wp.taskTimeout)
+}
+
+// submitBatch queues multiple tasks of the given priority with their timeout
+func (wp *WorkerPool) submitBatch(tasks []Task, priority Priority, timeout time.Duration) <-chan Result {
+	boxed := make([]func() (interface{}, error), len(tasks))
+	for i, task := range tasks {
+		task := task
+		boxed[i] = func() (interface{}, error) {
+			return task(), nil
+		}
 	}
-	
-	// Wait for all tasks to complete in a separate goroutine
-	go func() {
-		wg.Wait()
-		close(resultCh)
-	}()
-	
-	return resultCh
+	return submitJobs(context.Background(), wp, boxed, priority, timeout)
+}
+
+// validPriority returns a priority, PriorityNormal for unknown ones
+func validPriority(priority Priority) Priority {
+	if priority < PriorityLow || priority > PriorityHigh {
+		return PriorityNormal
+	}
+	return priority
+}
+
+// Queued returns the number of submitted tasks waiting for a worker
+func (wp *WorkerPool) Queued() int {
+	queued := 0
+	for _, shard := range wp.shards {
+		for _, queue := range shard.queues {
+			queued += len(queue)
+		}
+	}
+	return queued
+}
+
+// QueuedPriority returns the number of submitted tasks of a priority waiting for a worker
+func (wp *WorkerPool) QueuedPriority(priority Priority) int {
+	priority = validPriority(priority)
+	queued := 0
+	for _, shard := range wp.shards {
+		queued += len(shard.queues[priority])
+	}
+	return queued
+}
+
+// Workers returns the number of workers running
+func (wp *WorkerPool) Workers() int {
+	return int(wp.workers.Load())
+}
+
+// Running reports whether the pool still runs tasks, i.e. it hasn't been shut down
+func (wp *WorkerPool) Running() bool {
+	return wp.ctx.Err() == nil
 }
 
 // Shutdown gracefully shuts down the worker pool
-// It stops accepting new tasks and waits for all pending tasks to complete
+// New tasks fail with ErrPoolClosed. Running tasks finish, and busy workers
+// keep taking queued tasks until the queues are empty, while idle workers exit
+// right away. Tasks still queued once all workers have exited fail with
+// ErrPoolClosed instead of running
 func (wp *WorkerPool) Shutdown() {
 	// Signal workers to stop
 	wp.cancel()
 	
 	// Wait for all workers to exit
 	wp.wg.Wait()
+	
+	// Fail the tasks queued while the workers were exiting
+	wp.discardQueued()
 }
 
 // ShutdownNow immediately shuts down the worker pool
@@ -169,11 +308,10 @@ func (wp *WorkerPool) ShutdownNow() {
 	// Signal workers to stop
 	wp.cancel()
 	
-	// Clear the tasks channel
-	for len(wp.tasks) > 0 {
-		<-wp.tasks
-	}
+	// Clear the queues, failing the pending tasks
+	wp.discardQueued()
 	
 	// Wait for all workers to exit
 	wp.wg.Wait()
+	wp.discardQueued()
 }
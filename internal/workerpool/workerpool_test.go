@@ -1,6 +1,7 @@
 package workerpool
 
 import (
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -131,3 +132,87 @@ func TestWorkerPool(t *testing.T) {
 		}
 	})
 }
+
+func TestRunning(t *testing.T) {
+	wp := New(2)
+	if !wp.Running() {
+		t.Error("Expected a new pool to be running")
+	}
+	
+	wp.Shutdown()
+	if wp.Running() {
+		t.Error("Expected the pool to stop running after Shutdown")
+	}
+}
+
+func TestQueued(t *testing.T) {
+	wp := New(1)
+	defer wp.Shutdown()
+	
+	// Keep the only worker busy, so further tasks wait in the queue
+	release := make(chan struct{})
+	started := make(chan struct{})
+	wp.Submit(func() interface{} {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	
+	for i := 0; i < 3; i++ {
+		wp.Submit(func() interface{} { return nil })
+	}
+	if queued := wp.Queued(); queued != 3 {
+		t.Errorf("Expected 3 queued tasks, got %d", queued)
+	}
+	
+	close(release)
+}
+
+func TestResultRouting(t *testing.T) {
+	wp := New(8)
+	defer wp.Shutdown()
+	
+	// Every caller gets the results of its own tasks, however many submit at once
+	var wg sync.WaitGroup
+	errs := make(chan string, 100)
+	for caller := 0; caller < 50; caller++ {
+		caller := caller
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				want := caller*1000 + i
+				if result := <-wp.Submit(func() interface{} { return want }); result.Value != want {
+					errs <- fmt.Sprintf("caller %d: expected %d, got %v", caller, want, result.Value)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			tasks := make([]Task, 20)
+			for i := range tasks {
+				value := -(caller*1000 + i + 1)
+				tasks[i] = func() interface{} { return value }
+			}
+			seen := make(map[int]bool)
+			for result := range wp.SubmitBatch(tasks) {
+				value, ok := result.Value.(int)
+				if !ok || value > -(caller*1000+1) || value < -(caller*1000+len(tasks)) || seen[value] {
+					errs <- fmt.Sprintf("batch %d: unexpected result %v", caller, result.Value)
+					return
+				}
+				seen[value] = true
+			}
+			if len(seen) != len(tasks) {
+				errs <- fmt.Sprintf("batch %d: expected %d results, got %d", caller, len(tasks), len(seen))
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}